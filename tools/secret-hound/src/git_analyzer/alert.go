@@ -0,0 +1,279 @@
+/**
+ * @file alert.go
+ * @brief Paging the on-call for live critical secrets (--alert).
+ *
+ * Most findings can wait for triage, but a working credential sitting in the
+ * current tree of a repository cannot. With --alert pagerduty or opsgenie
+ * (or both, comma-separated), a finding opens an incident when it is
+ *   - verified: --auto-revoke found the secret live (revocation revoked or
+ *     would_revoke), or an imported report marked it verified;
+ *   - present at HEAD: its blob is in the tree at HEAD (or the worktree);
+ *   - at least --alert-severity (critical by default).
+ * Scans verify secrets only through --auto-revoke, so --alert requires it.
+ *
+ * Each secret is sent once per run, with its fingerprint (see trend.go) as
+ * the PagerDuty dedup_key and the Opsgenie alias, so rescans of a secret
+ * whose incident is still open add to it instead of paging again. Secrets
+ * the --secret-index marks triaged or false-positive are not sent at all.
+ * Alerts carry the rule, location and the secret masked to its first
+ * characters, never the secret. PAGERDUTY_ROUTING_KEY holds the Events API
+ * v2 integration key; OPSGENIE_API_KEY the API key, with OPSGENIE_API_URL
+ * for the EU instance. A failed alert is a sink error and does not stop the
+ * scan.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertHTTPTimeout bounds each call to an alerting API.
+const alertHTTPTimeout = 30 * time.Second
+
+// Default API endpoints of the alerting services.
+const (
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieAPIURL     = "https://api.opsgenie.com"
+)
+
+// alertTarget is a service incidents are opened in.
+type alertTarget interface {
+	// name is the identifier used by --alert.
+	name() string
+	// open opens (or adds to) the incident identified by key.
+	open(client *http.Client, key string, f *finding) error
+}
+
+/**
+ * @struct alerter
+ * @brief Opens incidents for findings that qualify, once per secret.
+ */
+type alerter struct {
+	client      *http.Client
+	targets     []alertTarget
+	minSeverity string
+	head        *headTree
+	index       *secretIndex // Statuses of known secrets (nil = none)
+
+	mu   sync.Mutex
+	sent map[string]*alertOutcome // By fingerprint
+}
+
+// alertOutcome records the services an incident was opened in for a secret.
+type alertOutcome struct {
+	once   sync.Once
+	opened string // Comma-separated service names ("" if every call failed)
+}
+
+/**
+ * @brief Creates the alerter for an --alert value.
+ * @param spec "none", or a comma-separated list of pagerduty and opsgenie.
+ * @param minSeverity The --alert-severity value.
+ * @param revoker The --auto-revoke revokers, which verify secrets (nil = none).
+ * @param index The secret index, for triaged secrets (may be nil).
+ * @return The alerter (nil for "none"), and an error for an unknown service,
+ *         a missing key, or no --auto-revoke.
+ */
+func newAlerter(spec, minSeverity string, revoker *autoRevoker, index *secretIndex) (*alerter, error) {
+	if spec = strings.TrimSpace(spec); spec == "" || spec == "none" {
+		return nil, nil
+	}
+	if revoker == nil {
+		return nil, fmt.Errorf("--alert pages only for secrets --auto-revoke verifies as live; add --auto-revoke")
+	}
+	if _, ok := severityRanks[minSeverity]; !ok {
+		return nil, fmt.Errorf("--alert-severity: unknown severity %q", minSeverity)
+	}
+	a := &alerter{client: &http.Client{Timeout: alertHTTPTimeout}, minSeverity: minSeverity, head: newHeadTree(), index: index, sent: make(map[string]*alertOutcome)}
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(name) {
+		case "pagerduty":
+			key := os.Getenv("PAGERDUTY_ROUTING_KEY")
+			if key == "" {
+				return nil, fmt.Errorf("--alert pagerduty needs PAGERDUTY_ROUTING_KEY")
+			}
+			a.targets = append(a.targets, pagerDuty{url: pagerDutyEventsURL, routingKey: key})
+		case "opsgenie":
+			key := os.Getenv("OPSGENIE_API_KEY")
+			if key == "" {
+				return nil, fmt.Errorf("--alert opsgenie needs OPSGENIE_API_KEY")
+			}
+			a.targets = append(a.targets, opsgenie{api: strings.TrimRight(orDefault(os.Getenv("OPSGENIE_API_URL"), opsgenieAPIURL), "/"), apiKey: key})
+		default:
+			return nil, fmt.Errorf("--alert: unknown service %q (available: opsgenie, pagerduty)", name)
+		}
+	}
+	return a, nil
+}
+
+// verifiedSecret reports whether a finding's secret is known to work.
+func verifiedSecret(f *finding) bool {
+	switch f.Metadata["revocation"] {
+	case revocationRevoked, revocationWouldRevoke:
+		return true
+	}
+	return f.Metadata["verified"] == "true"
+}
+
+/**
+ * @brief Opens an incident for a finding if it qualifies.
+ * @param f The finding, after enrichment, policy and revocation.
+ * @param blob The blob it was found in.
+ */
+func (a *alerter) handle(f *finding, blob fileBlob) {
+	if a == nil || severityRanks[findingSeverity(f)] < severityRanks[a.minSeverity] || !verifiedSecret(f) || !a.head.contains(blob) {
+		return
+	}
+	fingerprint := secretFingerprint(f)
+	switch a.index.status(fingerprint) {
+	case incidentTriaged, incidentFalsePositive:
+		return
+	}
+	a.mu.Lock()
+	rec, ok := a.sent[fingerprint]
+	if !ok {
+		rec = &alertOutcome{}
+		a.sent[fingerprint] = rec
+	}
+	a.mu.Unlock()
+
+	rec.once.Do(func() { rec.opened = a.open(fingerprint, f, blob) })
+	setMetadata(f, "alerted", rec.opened)
+}
+
+// open opens the incident of a secret seen for the first time in every service.
+func (a *alerter) open(fingerprint string, f *finding, blob fileBlob) string {
+	var opened []string
+	for _, target := range a.targets {
+		if err := target.open(a.client, "secret-hound-"+fingerprint, f); err != nil {
+			scanFailures.record(&sinkError{sink: target.name(), blob: blob, err: err})
+			continue
+		}
+		opened = append(opened, target.name())
+	}
+	if len(opened) > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: alert: opened %s incident for %s at %s %s:%d\n", strings.Join(opened, " and "), f.RuleID, shortHash(f.Commit), f.OriginalPath, f.Line)
+	}
+	return strings.Join(opened, ",")
+}
+
+// alertSummary is the one-line title of an incident.
+func alertSummary(f *finding) string {
+	where := f.OriginalPath
+	if f.Repository != "" {
+		where = f.Repository + ": " + where
+	}
+	return fmt.Sprintf("Live %s secret (%s) at HEAD in %s", findingSeverity(f), f.RuleID, where)
+}
+
+// alertDetails are the fields attached to an incident; the secret is masked.
+func alertDetails(f *finding) map[string]string {
+	details := map[string]string{
+		"rule_id":     f.RuleID,
+		"repository":  f.Repository,
+		"commit":      f.Commit,
+		"path":        f.OriginalPath,
+		"line":        fmt.Sprint(f.Line),
+		"severity":    findingSeverity(f),
+		"match":       maskSecret(f.Match),
+		"fingerprint": secretFingerprint(f),
+	}
+	for _, key := range []string{"revocation", "author", "repository_visibility"} {
+		if value := f.Metadata[key]; value != "" {
+			details[key] = value
+		}
+	}
+	return details
+}
+
+// postAlertJSON sends a JSON body and fails on any status other than 2xx.
+func postAlertJSON(client *http.Client, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// pagerDuty triggers events through the PagerDuty Events API v2.
+type pagerDuty struct {
+	url        string
+	routingKey string
+}
+
+func (pagerDuty) name() string { return "pagerduty" }
+
+func (p pagerDuty) open(client *http.Client, key string, f *finding) error {
+	severity := findingSeverity(f)
+	switch severity {
+	case "high":
+		severity = "error"
+	case "medium", "low", "info":
+		severity = "warning"
+	}
+	return postAlertJSON(client, p.url, nil, map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    key,
+		"payload": map[string]interface{}{
+			"summary":        alertSummary(f),
+			"source":         orDefault(f.Repository, "secret-hound"),
+			"severity":       severity,
+			"component":      f.OriginalPath,
+			"class":          f.RuleID,
+			"custom_details": alertDetails(f),
+		},
+	})
+}
+
+// opsgenie creates alerts through the Opsgenie Alert API.
+type opsgenie struct {
+	api    string
+	apiKey string
+}
+
+func (opsgenie) name() string { return "opsgenie" }
+
+func (o opsgenie) open(client *http.Client, key string, f *finding) error {
+	priority := map[string]string{"critical": "P1", "high": "P2", "medium": "P3", "low": "P4"}[findingSeverity(f)]
+	details := alertDetails(f)
+	var lines []string
+	for name, value := range details {
+		lines = append(lines, name+": "+value)
+	}
+	sort.Strings(lines)
+	return postAlertJSON(client, o.api+"/v2/alerts", map[string]string{"Authorization": "GenieKey " + o.apiKey}, map[string]interface{}{
+		"message":     alertSummary(f),
+		"alias":       key,
+		"description": strings.Join(lines, "\n"),
+		"source":      "secret-hound",
+		"priority":    orDefault(priority, "P5"),
+		"tags":        []string{"secret-hound", f.RuleID},
+		"details":     details,
+	})
+}
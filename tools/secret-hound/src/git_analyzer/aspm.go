@@ -0,0 +1,145 @@
+/**
+ * @file aspm.go
+ * @brief Findings as importable documents for vulnerability management tools.
+ *
+ * Teams that triage in DefectDojo or another application security posture
+ * management (ASPM) tool want scan results to arrive there without a
+ * conversion script. Two --output-format values write one JSON document
+ * instead of a stream:
+ *   - "defectdojo" follows DefectDojo's Generic Findings Import format
+ *     ({"findings": [...]} with title, severity, file_path, line, cwe,
+ *     unique_id_from_tool, ...), to be imported with the scan type
+ *     "Generic Findings Import";
+ *   - "aspm" is a tool-neutral document ({"tool": ..., "findings": [...]})
+ *     with the location, rule, severity and a stable fingerprint of each
+ *     finding, for ASPM platforms with a custom JSON mapping.
+ * Neither carries the secret itself: the match is masked to its first
+ * characters, and unique_id_from_tool/fingerprint identify it across runs
+ * so re-imports update existing findings instead of duplicating them.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hardcodedCredentialsCWE is CWE-798, Use of Hard-coded Credentials.
+const hardcodedCredentialsCWE = 798
+
+/**
+ * @brief Returns the text written around the records of a document output format.
+ * @return The opening, the separator between records and the closing (all nil for streams).
+ */
+func documentFraming(format string) (open, separator, end []byte) {
+	switch format {
+	case "defectdojo":
+		return []byte(`{"findings":[` + "\n"), []byte(",\n"), []byte("\n]}\n")
+	case "aspm":
+		tool, _ := json.Marshal(map[string]string{"name": "secret-hound", "version": analyzerVersion})
+		return []byte(`{"schema":"secret-hound-aspm/1","tool":` + string(tool) + `,"findings":[` + "\n"), []byte(",\n"), []byte("\n]}\n")
+	}
+	return nil, nil, nil
+}
+
+// maskSecret keeps the first characters of a secret, enough to recognize it when rotating.
+func maskSecret(match string) string {
+	runes := []rune(strings.TrimSpace(match))
+	if len(runes) < 12 {
+		return redactionMarker
+	}
+	return string(runes[:4]) + redactionMarker
+}
+
+// findingLocationID identifies a secret at a location across runs: rule, value, repository and path.
+func findingLocationID(f *finding) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.RuleID, f.Match, f.Repository, f.OriginalPath}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+/**
+ * @brief Encodes a finding as a DefectDojo Generic Findings Import entry.
+ */
+func marshalDefectDojo(f *finding) ([]byte, error) {
+	severity := map[string]string{"critical": "Critical", "high": "High", "medium": "Medium", "low": "Low"}[findingSeverity(f)]
+	var description strings.Builder
+	fmt.Fprintf(&description, "**Rule:** %s\n\n", f.RuleID)
+	if f.Repository != "" {
+		fmt.Fprintf(&description, "**Repository:** %s\n\n", f.Repository)
+	}
+	fmt.Fprintf(&description, "**Commit:** %s\n\n**Location:** %s:%d\n\n", f.Commit, f.OriginalPath, f.Line)
+	if f.KeyPath != "" {
+		fmt.Fprintf(&description, "**Key:** %s\n\n", f.KeyPath)
+	}
+	fmt.Fprintf(&description, "**Secret:** `%s`\n\n**Confidence:** %s", maskSecret(f.Match), orDefault(f.Confidence, "unknown"))
+	keys := make([]string, 0, len(f.Metadata))
+	for key := range f.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&description, "\n\n**%s:** %s", key, f.Metadata[key])
+	}
+
+	entry := map[string]interface{}{
+		"title":               fmt.Sprintf("%s in %s", f.Description, f.OriginalPath),
+		"description":         description.String(),
+		"severity":            orDefault(severity, "Info"),
+		"file_path":           f.OriginalPath,
+		"line":                f.Line,
+		"cwe":                 hardcodedCredentialsCWE,
+		"vuln_id_from_tool":   f.RuleID,
+		"unique_id_from_tool": findingLocationID(f),
+		"mitigation":          "Revoke and rotate the secret, then remove it from the repository history.",
+		"static_finding":      true,
+		"dynamic_finding":     false,
+	}
+	if f.Category == "pii" {
+		delete(entry, "cwe")
+		entry["mitigation"] = "Remove the personal data from the repository history."
+	}
+	return json.Marshal(entry)
+}
+
+/**
+ * @brief Encodes a finding as an entry of the tool-neutral ASPM document.
+ */
+func marshalASPM(f *finding) ([]byte, error) {
+	location := map[string]interface{}{
+		"commit": f.Commit,
+		"path":   f.OriginalPath,
+		"line":   f.Line,
+	}
+	if f.Repository != "" {
+		location["repository"] = f.Repository
+	}
+	if f.Column > 0 {
+		location["column"] = f.Column
+	}
+	entry := map[string]interface{}{
+		"id":            findingLocationID(f),
+		"fingerprint":   secretFingerprint(f),
+		"rule_id":       f.RuleID,
+		"title":         f.Description,
+		"category":      orDefault(f.Category, "secret"),
+		"severity":      findingSeverity(f),
+		"confidence":    strings.ToLower(f.Confidence),
+		"location":      location,
+		"secret_masked": maskSecret(f.Match),
+	}
+	if f.KeyPath != "" {
+		entry["key_path"] = f.KeyPath
+	}
+	if len(f.Metadata) > 0 {
+		entry["metadata"] = f.Metadata
+	}
+	if len(f.Occurrences) > 0 {
+		entry["occurrences"] = len(f.Occurrences)
+	}
+	return json.Marshal(entry)
+}
@@ -0,0 +1,285 @@
+/**
+ * @file attest.go
+ * @brief in-toto attestation of a scan (--attestation).
+ *
+ * A release's provenance says how it was built; "its history was scanned for
+ * secrets, and this is what was found" belongs next to it, in a form policy
+ * engines already verify. With --attestation the analyzer writes an in-toto
+ * Statement (v1) whose subjects are
+ *   - the findings output, by the sha256 of the findings as encoded by
+ *     --output-format (before any --compress or --encrypt-to), and
+ *   - each scanned repository, by the gitCommit digest of its HEAD,
+ * and whose predicate (scanPredicateType) records the scanner (analyzer and
+ * core version, engine, the digest of the rules and of the whole scanner
+ * configuration), the commit range of each repository (HEAD, the --base it
+ * was compared to, requested depth, commits covered, scope), and the result
+ * (number of findings, policy failures, whether the scan was partial).
+ *
+ * With --attestation-key (a PEM PKCS#8 Ed25519 private key) the statement is
+ * wrapped in a signed DSSE envelope, the format `cosign verify-attestation`
+ * and SLSA verifiers consume; without it the bare statement is written, for
+ * signing by the pipeline's own tooling. A failed scan writes no attestation.
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of the attestation.
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	inTotoPayloadType   = "application/vnd.in-toto+json"
+	scanPredicateType   = "https://github.com/limearch/sniper/secret-hound/scan-attestation/v1"
+)
+
+// findingsAttestation collects the --attestation of the run (nil = off).
+var findingsAttestation *scanAttestation
+
+/**
+ * @struct scanAttestation
+ * @brief What the attestation of a run states, collected while it runs.
+ */
+type scanAttestation struct {
+	path    string
+	key     ed25519.PrivateKey // DSSE signing key (nil = unsigned statement)
+	output  string             // Name of the findings subject
+	started time.Time
+	digest  hash.Hash // sha256 of the findings output
+
+	mu    sync.Mutex
+	repos []attestedRepository
+}
+
+/**
+ * @struct attestedRepository
+ * @brief The commit range of one scanned repository.
+ */
+type attestedRepository struct {
+	Name    string `json:"name"`
+	Source  string `json:"source,omitempty"` // Remote URL, credentials redacted
+	Head    string `json:"head,omitempty"`
+	Ref     string `json:"ref,omitempty"`
+	Base    string `json:"base,omitempty"` // Commit of --base; the range is base..head
+	Depth   int    `json:"depth_requested,omitempty"`
+	Commits int    `json:"commits_covered"`
+	Shallow bool   `json:"shallow,omitempty"`
+	Scope   string `json:"scope,omitempty"`
+	Blobs   int    `json:"blobs_scanned"`
+	Nesting int    `json:"nesting,omitempty"` // Level of a repository committed inside another
+}
+
+/**
+ * @brief Prepares the attestation of a run.
+ * @param path The file the statement or envelope is written to.
+ * @param keyFile PEM PKCS#8 Ed25519 private key ("" = unsigned).
+ * @param outputPath The --output file ("" = stdout), naming the findings subject.
+ * @param format The --output-format.
+ * @return The attestation, and an error if the key cannot be read.
+ */
+func newScanAttestation(path, keyFile, outputPath, format string) (*scanAttestation, error) {
+	s := &scanAttestation{path: path, started: time.Now().UTC(), digest: sha256.New(),
+		output: "findings" + reportMediaTypes[format][1]}
+	if outputPath != "" && !isObjectURL(outputPath) {
+		s.output = filepath.Base(outputPath)
+	}
+	if keyFile == "" {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM private key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", keyFile, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", keyFile)
+	}
+	s.key = key
+	return s, nil
+}
+
+/**
+ * @struct attestedOutput
+ * @brief Passes the findings output on and hashes it.
+ */
+type attestedOutput struct {
+	dest   io.Writer
+	digest hash.Hash
+}
+
+// wrap returns a writer that hashes what goes to dest.
+func (s *scanAttestation) wrap(dest io.Writer) io.Writer {
+	return &attestedOutput{dest: dest, digest: s.digest}
+}
+
+func (o *attestedOutput) Write(data []byte) (int, error) {
+	o.digest.Write(data)
+	return o.dest.Write(data)
+}
+
+func (o *attestedOutput) Close() error {
+	if closer, ok := o.dest.(io.Closer); ok && o.dest != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+/**
+ * @brief Records the commit range of a scanned repository.
+ * @param repo The repository, while it still exists.
+ * @param opts The run options (--base).
+ * @param coverage What of its history was walked.
+ * @param blobs The number of blobs scanned.
+ */
+func (s *scanAttestation) repositoryScanned(repo *repository, opts options, coverage historyCoverage, blobs int) {
+	if s == nil {
+		return
+	}
+	r := attestedRepository{
+		Name:    orDefault(repo.label, orDefault(repo.gitDir, ".")),
+		Depth:   coverage.requested,
+		Commits: coverage.available,
+		Shallow: coverage.shallow,
+		Scope:   coverage.scope,
+		Blobs:   blobs,
+		Nesting: repo.nesting,
+	}
+	if out, err := repo.command("rev-parse", "--verify", "-q", "HEAD").Output(); err == nil {
+		r.Head = strings.TrimSpace(string(out))
+	}
+	if out, err := repo.command("symbolic-ref", "-q", "HEAD").Output(); err == nil {
+		r.Ref = strings.TrimSpace(string(out))
+	}
+	if out, err := repo.command("config", "--get", "remote.origin.url").Output(); err == nil {
+		r.Source = redactArguments([]string{strings.TrimSpace(string(out))})[0]
+	}
+	if opts.base != "" && repo.nesting == 0 {
+		if out, err := repo.command("rev-parse", "--verify", "-q", opts.base+"^{commit}").Output(); err == nil {
+			r.Base = strings.TrimSpace(string(out))
+		}
+	}
+	s.mu.Lock()
+	s.repos = append(s.repos, r)
+	s.mu.Unlock()
+}
+
+/**
+ * @brief Writes the statement, signed if a key was given.
+ * @param a The analyzer, for the scanner configuration.
+ * @param findings The number of findings written.
+ * @param partial Whether the --time-budget ran out.
+ */
+func (s *scanAttestation) write(a *analyzer, findings int64, partial bool) error {
+	if s == nil {
+		return nil
+	}
+	type subject struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	}
+	subjects := []subject{{Name: s.output, Digest: map[string]string{"sha256": hex.EncodeToString(s.digest.Sum(nil))}}}
+	for _, r := range s.repos {
+		if r.Head != "" && r.Nesting == 0 {
+			subjects = append(subjects, subject{Name: orDefault(r.Source, r.Name), Digest: map[string]string{"gitCommit": r.Head}})
+		}
+	}
+	scanner := map[string]interface{}{
+		"uri":              "https://github.com/limearch/sniper/tree/main/tools/secret-hound",
+		"analyzer_version": analyzerVersion,
+		"engine":           a.opts.engine,
+		"rules_digest":     map[string]string{"sha256": rulesDigest(a)},
+		"config_digest":    map[string]string{"sha256": scannerFingerprint(a)},
+	}
+	if a.core != nil {
+		scanner["core_version"] = a.core.Version
+	}
+	var policyFailures int64
+	if a.policy != nil {
+		policyFailures = a.policy.failures.Load()
+	}
+	statement := map[string]interface{}{
+		"_type":         inTotoStatementType,
+		"subject":       subjects,
+		"predicateType": scanPredicateType,
+		"predicate": map[string]interface{}{
+			"scanner":      scanner,
+			"repositories": s.repos,
+			"result": map[string]interface{}{
+				"findings":        findings,
+				"policy_failures": policyFailures,
+				"blob_errors":     scanFailures.count(),
+				"partial":         partial,
+			},
+			"started_on":  s.started.Format(time.RFC3339),
+			"finished_on": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return err
+	}
+	data := payload
+	if s.key != nil {
+		data, err = dsseEnvelope(s.key, payload)
+		if err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(s.path, append(data, '\n'), 0o644)
+}
+
+/**
+ * @brief Signs an in-toto statement into a DSSE envelope.
+ * The signature covers the DSSE pre-authentication encoding of the payload.
+ */
+func dsseEnvelope(key ed25519.PrivateKey, payload []byte) ([]byte, error) {
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(inTotoPayloadType), inTotoPayloadType, len(payload), payload)
+	public, ok := key.Public().(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	keyID := sha256.Sum256(public)
+	return json.Marshal(map[string]interface{}{
+		"payloadType": inTotoPayloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures": []map[string]string{{
+			"keyid": hex.EncodeToString(keyID[:]),
+			"sig":   base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(pae))),
+		}},
+	})
+}
+
+// rulesDigest is the sha256 of the --rules file, or of the built-in rules as used.
+func rulesDigest(a *analyzer) string {
+	data, err := ioutil.ReadFile(a.opts.rulesPath)
+	if a.opts.rulesPath == "" || err != nil {
+		data, _ = json.Marshal(a.rules)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestDSSEEnvelopeVerifies(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statement := []byte(`{"_type":"` + inTotoStatementType + `"}`)
+	data, err := dsseEnvelope(private, statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || len(envelope.Signatures) != 1 {
+		t.Fatalf("envelope %s (%v)", data, err)
+	}
+	payload, _ := base64.StdEncoding.DecodeString(envelope.Payload)
+	sig, _ := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(envelope.PayloadType), envelope.PayloadType, len(payload), payload)
+	if envelope.PayloadType != inTotoPayloadType || string(payload) != string(statement) || !ed25519.Verify(public, []byte(pae), sig) {
+		t.Errorf("envelope does not verify: %s", data)
+	}
+}
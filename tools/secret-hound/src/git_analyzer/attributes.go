@@ -0,0 +1,240 @@
+/**
+ * @file attributes.go
+ * @brief Honours linguist-vendored and linguist-generated markers in .gitattributes.
+ *
+ * GitHub hides paths marked `linguist-vendored` or `linguist-generated` from
+ * language statistics and diffs; they are third-party or machine-written code
+ * and a steady source of false positives. The markers are read from the
+ * .gitattributes files of each scanned commit (so a path that was vendored
+ * only for part of its history is classified correctly), and from the files
+ * on disk for working tree blobs. --linguist-attributes=false scans them anyway.
+ */
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// linguistAttributes are the attributes that exclude a path from scanning.
+var linguistAttributes = []string{"linguist-vendored", "linguist-generated"}
+
+// linguistBatch is the number of commits searched per `git grep` invocation.
+const linguistBatch = 64
+
+/**
+ * @struct attributeRule
+ * @brief One linguist setting from a .gitattributes line.
+ */
+type attributeRule struct {
+	dir     string // Directory holding the .gitattributes file ("" for the root)
+	line    int    // Line number, for ordering rules within a file
+	pattern string // Path pattern, relative to dir
+	attr    string // "linguist-vendored" or "linguist-generated"
+	set     bool   // Whether the attribute is set (true) or unset/false
+}
+
+/**
+ * @brief Drops blobs whose paths are marked vendored or generated.
+ * @param repo The repository the blobs come from.
+ * @param blobs The blobs collected for scanning.
+ * @return The remaining blobs and the dropped ones.
+ */
+func filterLinguistBlobs(repo *repository, blobs []fileBlob) ([]fileBlob, []fileBlob) {
+	var commits []string
+	seen := make(map[string]bool)
+	var worktreePaths []string
+	for _, blob := range blobs {
+		if blob.commit == worktreeCommit {
+			worktreePaths = append(worktreePaths, blob.path)
+		} else if !seen[blob.commit] {
+			seen[blob.commit] = true
+			commits = append(commits, blob.commit)
+		}
+	}
+	rules := readLinguistRules(repo, commits)
+	worktreeExcluded := checkWorktreeLinguist(repo, worktreePaths)
+
+	kept := blobs[:0:0]
+	var dropped []fileBlob
+	for _, blob := range blobs {
+		var excluded bool
+		if blob.commit == worktreeCommit {
+			excluded = worktreeExcluded[blob.path]
+		} else {
+			excluded = linguistExcluded(rules[blob.commit], blob.path)
+		}
+		if excluded {
+			dropped = append(dropped, blob)
+		} else {
+			kept = append(kept, blob)
+		}
+	}
+	return kept, dropped
+}
+
+/**
+ * @brief Collects the linguist rules of every .gitattributes file in the given commits.
+ * Only lines mentioning "linguist-" are read, via `git grep`, which works on
+ * bare repositories and avoids listing whole trees.
+ * @param repo The repository.
+ * @param commits The commits to inspect.
+ * @return The rules of each commit, in precedence order (later wins).
+ */
+func readLinguistRules(repo *repository, commits []string) map[string][]attributeRule {
+	rules := make(map[string][]attributeRule)
+	for start := 0; start < len(commits); start += linguistBatch {
+		end := start + linguistBatch
+		if end > len(commits) {
+			end = len(commits)
+		}
+		args := []string{"grep", "--null", "-n", "-F", "-e", "linguist-"}
+		args = append(args, commits[start:end]...)
+		args = append(args, "--", ".gitattributes", "*/.gitattributes")
+		// `git grep` exits 1 when nothing matches; the output is simply empty.
+		output, _ := repo.command(args...).Output()
+		for _, line := range strings.Split(string(output), "\n") {
+			// Each line is "<commit>:<path>\0<line number>\0<text>".
+			fields := strings.SplitN(line, "\x00", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			commit, file, ok := cutRevisionPath(fields[0])
+			if !ok {
+				continue
+			}
+			number, _ := strconv.Atoi(fields[1])
+			rules[commit] = append(rules[commit], parseAttributeLine(path.Dir(file), number, fields[2])...)
+		}
+	}
+	for commit := range rules {
+		sortAttributeRules(rules[commit])
+	}
+	return rules
+}
+
+/**
+ * @brief Splits a `git grep` "<commit>:<path>" prefix.
+ */
+func cutRevisionPath(s string) (string, string, bool) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+/**
+ * @brief Parses the linguist attributes set on one .gitattributes line.
+ * @param dir The directory of the .gitattributes file ("." for the root).
+ * @param number The line number.
+ * @param text The line.
+ * @return One rule per linguist attribute on the line.
+ */
+func parseAttributeLine(dir string, number int, text string) []attributeRule {
+	fields := strings.Fields(text)
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+	if dir == "." {
+		dir = ""
+	}
+	var rules []attributeRule
+	for _, field := range fields[1:] {
+		set := true
+		name := field
+		switch {
+		case strings.HasPrefix(field, "-"), strings.HasPrefix(field, "!"):
+			set, name = false, field[1:]
+		case strings.Contains(field, "="):
+			i := strings.IndexByte(field, '=')
+			name = field[:i]
+			set = field[i+1:] != "false"
+		}
+		if containsString(linguistAttributes, name) {
+			rules = append(rules, attributeRule{dir: dir, line: number, pattern: fields[0], attr: name, set: set})
+		}
+	}
+	return rules
+}
+
+/**
+ * @brief Orders rules so that deeper .gitattributes files and later lines come last.
+ */
+func sortAttributeRules(rules []attributeRule) {
+	depth := func(r attributeRule) int {
+		if r.dir == "" {
+			return 0
+		}
+		return strings.Count(r.dir, "/") + 1
+	}
+	// Insertion sort: the rule lists are short.
+	for i := 1; i < len(rules); i++ {
+		for j := i; j > 0; j-- {
+			a, b := rules[j-1], rules[j]
+			if depth(a) < depth(b) || (depth(a) == depth(b) && (a.dir < b.dir || (a.dir == b.dir && a.line <= b.line))) {
+				break
+			}
+			rules[j-1], rules[j] = b, a
+		}
+	}
+}
+
+/**
+ * @brief Reports whether the last matching rule for either attribute sets it.
+ * @param rules Rules in precedence order.
+ * @param file The repository path of the blob.
+ */
+func linguistExcluded(rules []attributeRule, file string) bool {
+	state := make(map[string]bool)
+	for _, r := range rules {
+		rel := file
+		if r.dir != "" {
+			if !strings.HasPrefix(file, r.dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(file, r.dir+"/")
+		}
+		if matchPathGlob(r.pattern, rel) {
+			state[r.attr] = r.set
+		}
+	}
+	for _, attr := range linguistAttributes {
+		if state[attr] {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Resolves linguist attributes of working tree files with `git check-attr`.
+ * @param repo The repository.
+ * @param paths Working tree paths.
+ * @return The set of paths marked vendored or generated.
+ */
+func checkWorktreeLinguist(repo *repository, paths []string) map[string]bool {
+	excluded := make(map[string]bool)
+	if len(paths) == 0 {
+		return excluded
+	}
+	args := append([]string{"check-attr", "-z", "--stdin"}, linguistAttributes...)
+	cmd := repo.command(args...)
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	output, err := cmd.Output()
+	if err != nil {
+		return excluded
+	}
+	// Output is a sequence of "<path>\0<attribute>\0<value>\0" records.
+	fields := bytes.Split(output, []byte{0})
+	for i := 0; i+2 < len(fields); i += 3 {
+		value := string(fields[i+2])
+		if value != "unspecified" && value != "unset" && value != "false" {
+			excluded[string(fields[i])] = true
+		}
+	}
+	return excluded
+}
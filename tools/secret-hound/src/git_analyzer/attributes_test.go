@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAttributeLine(t *testing.T) {
+	got := parseAttributeLine("web", 3, "gen/* text linguist-generated -linguist-vendored diff=js")
+	want := []attributeRule{
+		{dir: "web", line: 3, pattern: "gen/*", attr: "linguist-generated", set: true},
+		{dir: "web", line: 3, pattern: "gen/*", attr: "linguist-vendored", set: false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAttributeLine = %+v", got)
+	}
+	if rules := parseAttributeLine(".", 1, "docs/** linguist-documentation=false linguist-vendored=false"); len(rules) != 1 || rules[0].dir != "" || rules[0].set {
+		t.Errorf("explicit false: %+v", rules)
+	}
+	if rules := parseAttributeLine(".", 1, "# vendor/** linguist-vendored"); rules != nil {
+		t.Errorf("comment: %+v", rules)
+	}
+}
+
+func TestFilterLinguistBlobsFollowsHistory(t *testing.T) {
+	fx := newFixtureRepo(t)
+	before := fx.commit("initial", map[string]string{
+		"vendor/lib.js": "v1",
+		"src/app.js":    "app",
+	})
+	after := fx.commit("mark vendored and generated code", map[string]string{
+		".gitattributes":     "vendor/** linguist-vendored\nvendor/ours/** -linguist-vendored\n",
+		"web/.gitattributes": "gen/* linguist-generated\n",
+		"vendor/lib.js":      "v2",
+		"vendor/ours/own.js": "own",
+		"web/gen/api.js":     "generated",
+	})
+	fx.write(map[string]string{"vendor/new.js": "uncommitted"})
+
+	blobs := []fileBlob{
+		{commit: before, path: "vendor/lib.js"},
+		{commit: before, path: "src/app.js"},
+		{commit: after, path: "vendor/lib.js"},
+		{commit: after, path: "vendor/ours/own.js"},
+		{commit: after, path: "web/gen/api.js"},
+		{commit: worktreeCommit, path: "vendor/new.js"},
+		{commit: worktreeCommit, path: "src/app.js"},
+	}
+	t.Chdir(fx.dir)
+	kept, skipped := filterLinguistBlobs(&repository{}, blobs)
+
+	var got []string
+	for _, blob := range kept {
+		label := "after"
+		switch blob.commit {
+		case before:
+			label = "before"
+		case worktreeCommit:
+			label = "worktree"
+		}
+		got = append(got, label+":"+blob.path)
+	}
+	want := []string{"before:vendor/lib.js", "before:src/app.js", "after:vendor/ours/own.js", "worktree:src/app.js"}
+	if !reflect.DeepEqual(got, want) || len(skipped) != 3 {
+		t.Errorf("kept %v (skipped %d), want %v (skipped 3)", got, len(skipped), want)
+	}
+}
@@ -0,0 +1,281 @@
+/**
+ * @file auditlog.go
+ * @brief Hash-chained audit log of scan runs (--audit-log).
+ *
+ * Compliance reviews ask what was scanned, when, with which configuration
+ * and by whom, long after the findings were triaged. --audit-log appends one
+ * JSON record per action to a local file: the start of a run (user, host, CI
+ * actor, arguments with URL credentials redacted, configuration
+ * fingerprint), every repository scanned (HEAD ref and commit, commits
+ * covered, scope, blobs scanned) and the end of the run (status, findings,
+ * duration). Findings themselves are never written to it, and nothing is
+ * sent anywhere. A push let through by a break-glass bypass (see bypass.go)
+ * adds a "bypass" record with priority high.
+ *
+ * Each record carries the SHA-256 of the previous record and its own hash
+ * over its content, so deleting, reordering or editing a record breaks the
+ * chain. A run refuses to append to a log whose chain is already broken,
+ * and `git_analyzer audit-verify <file>` checks a log on its own.
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * @struct auditRecord
+ * @brief One entry of the audit log.
+ */
+type auditRecord struct {
+	Seq    int                    `json:"seq"`
+	Time   string                 `json:"time"`
+	Action string                 `json:"action"` // run_started, repository_scanned, bypass or run_finished
+	Data   map[string]interface{} `json:"data"`
+	Prev   string                 `json:"prev"`           // Hash of the previous record ("" for the first)
+	Hash   string                 `json:"hash,omitempty"` // SHA-256 of the record encoded without this field
+}
+
+// digest computes the record's hash over its encoding without the hash field.
+func (r auditRecord) digest() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * @struct auditLog
+ * @brief An open audit log; methods are no-ops on a nil log.
+ */
+type auditLog struct {
+	mu      sync.Mutex
+	file    *os.File
+	seq     int
+	prev    string
+	started time.Time
+}
+
+/**
+ * @brief Opens an audit log for appending, verifying the existing chain.
+ * @param path The log file (created if missing).
+ * @return The log and an error if it cannot be opened or its chain is broken.
+ */
+func openAuditLog(path string) (*auditLog, error) {
+	seq, prev, err := verifyAuditLog(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file, seq: seq, prev: prev, started: time.Now()}, nil
+}
+
+/**
+ * @brief Checks the hash chain of an audit log.
+ * @param path The log file.
+ * @return The number of records, the hash of the last one, and an error
+ *         naming the first record that breaks the chain.
+ */
+func verifyAuditLog(path string) (int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	seq, prev := 0, ""
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return 0, "", fmt.Errorf("%s: record %d is not valid JSON", path, seq+1)
+		}
+		if r.Seq != seq+1 || r.Prev != prev || r.Hash != r.digest() {
+			return 0, "", fmt.Errorf("%s: hash chain broken at record %d", path, seq+1)
+		}
+		seq, prev = r.Seq, r.Hash
+	}
+	return seq, prev, scanner.Err()
+}
+
+/**
+ * @brief Appends a record to the log.
+ * A failed write is reported once per call on stderr; the scan goes on.
+ */
+func (l *auditLog) append(action string, data map[string]interface{}) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := auditRecord{Seq: l.seq + 1, Time: time.Now().UTC().Format(time.RFC3339), Action: action, Data: data, Prev: l.prev}
+	r.Hash = r.digest()
+	line, _ := json.Marshal(r)
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: writing --audit-log: %v\n", err)
+		return
+	}
+	l.seq, l.prev = r.Seq, r.Hash
+}
+
+/**
+ * @brief Records the start of a run: who ran it, where, and with which configuration.
+ */
+func (l *auditLog) runStarted(a *analyzer) {
+	if l == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"analyzer_version": analyzerVersion,
+		"engine":           a.opts.engine,
+		"arguments":        redactArguments(os.Args[1:]),
+		"config":           scannerFingerprint(a),
+		"pid":              os.Getpid(),
+	}
+	if u, err := user.Current(); err == nil {
+		data["user"] = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		data["host"] = host
+	}
+	if dir, err := os.Getwd(); err == nil {
+		data["directory"] = dir
+	}
+	for _, name := range []string{"GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_USER_ID", "CIRCLE_USERNAME"} {
+		if actor := os.Getenv(name); actor != "" {
+			data["ci_actor"] = actor
+			break
+		}
+	}
+	l.append("run_started", data)
+}
+
+/**
+ * @brief Records a scanned repository and what of its history was covered.
+ */
+func (l *auditLog) repositoryScanned(repo *repository, coverage historyCoverage, blobs int) {
+	if l == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"repository":        orDefault(repo.label, orDefault(repo.gitDir, ".")),
+		"commits_requested": coverage.requested,
+		"commits_covered":   coverage.available,
+		"shallow":           coverage.shallow,
+		"blobs_scanned":     blobs,
+	}
+	if coverage.scope != "" {
+		data["scope"] = coverage.scope
+	}
+	if out, err := repo.command("rev-parse", "HEAD").Output(); err == nil {
+		data["head"] = strings.TrimSpace(string(out))
+	}
+	if out, err := repo.command("symbolic-ref", "-q", "HEAD").Output(); err == nil {
+		data["ref"] = strings.TrimSpace(string(out))
+	}
+	l.append("repository_scanned", data)
+}
+
+/**
+ * @brief Records a push let through despite its findings by a break-glass bypass.
+ * Only the location, rule and fingerprint of each finding are recorded.
+ */
+func (l *auditLog) bypassUsed(hook, pusher string, tickets map[string]string, findings []rejectedFinding) {
+	if l == nil {
+		return
+	}
+	var bypassed []map[string]interface{}
+	for _, f := range findings {
+		bypassed = append(bypassed, map[string]interface{}{
+			"commit":      f.Commit,
+			"path":        f.Path,
+			"line":        f.Line,
+			"rule_id":     f.RuleID,
+			"severity":    f.Severity,
+			"fingerprint": f.Fingerprint,
+		})
+	}
+	l.append("bypass", map[string]interface{}{
+		"priority": "high",
+		"hook":     hook,
+		"pusher":   pusher,
+		"tickets":  tickets,
+		"findings": bypassed,
+	})
+}
+
+/**
+ * @brief Records the end of the run and closes the log.
+ * @param err The run's error (nil if it succeeded).
+ * @param partial Whether --time-budget cut the scan short.
+ */
+func (l *auditLog) runFinished(err error, partial bool) {
+	if l == nil {
+		return
+	}
+	data := map[string]interface{}{
+		"status":           "ok",
+		"partial":          partial,
+		"findings":         debugFindings.Value(),
+		"duration_seconds": time.Since(l.started).Round(time.Millisecond).Seconds(),
+	}
+	if err != nil {
+		data["status"] = "error"
+		data["error"] = err.Error()
+	}
+	l.append("run_finished", data)
+	l.file.Close()
+}
+
+// redactArguments replaces the password of URLs in command-line arguments (e.g. --coordination-store).
+func redactArguments(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		flagName, value, hasFlag := strings.Cut(arg, "=")
+		if !hasFlag || !strings.HasPrefix(flagName, "-") {
+			value = arg
+		}
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), "REDACTED")
+			} else {
+				u.User = url.User("REDACTED")
+			}
+			out[i] = strings.TrimSuffix(arg, value) + u.String()
+		}
+	}
+	return out
+}
+
+/**
+ * @brief Implements `git_analyzer audit-verify <file>`.
+ * @return The exit code: 0 if the chain is intact, 1 otherwise.
+ */
+func runAuditVerify(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer audit-verify <audit log>")
+		return 2
+	}
+	n, _, err := verifyAuditLog(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s: %d record(s), hash chain intact\n", args[0], n)
+	return 0
+}
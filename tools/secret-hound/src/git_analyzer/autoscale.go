@@ -0,0 +1,181 @@
+/**
+ * @file autoscale.go
+ * @brief Adaptive worker count driven by scan latency and system load (--autoscale).
+ *
+ * The best --workers value depends on the machine and on the mix of blobs:
+ * too few leave cores idle while the core scanner starts up, too many make
+ * small machines thrash. With --autoscale the analyzer starts at --workers
+ * and a controller resizes the global worker budget every few seconds:
+ *   - it shrinks by a quarter when the load average per CPU is above
+ *     autoscaleHighLoad, or when the last step up made throughput drop;
+ *   - it grows by one while load is below autoscaleLowLoad and the mean
+ *     scan latency stays within autoscaleLatencySlack of the best seen,
+ *     i.e. adding workers is not just queueing work on saturated CPUs.
+ * The budget stays between 1 and --max-workers. Load average comes from
+ * /proc/loadavg; where it is unavailable the controller uses latency alone.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Controller tuning.
+const (
+	autoscaleInterval     = 2 * time.Second
+	autoscaleHighLoad     = 1.5  // Load average per CPU above which workers are removed
+	autoscaleLowLoad      = 1.0  // Load average per CPU below which workers may be added
+	autoscaleLatencySlack = 1.5  // Tolerated growth of mean latency over the best window
+	autoscaleGainFloor    = 1.05 // A step up must raise throughput by 5% to be kept
+)
+
+/**
+ * @struct autoscaler
+ * @brief Resizes the scheduler's worker budget from observed scan latency.
+ */
+type autoscaler struct {
+	sched *scheduler
+	max   int
+
+	mu       sync.Mutex
+	scans    int           // Scans completed in the current window
+	latency  time.Duration // Total latency of those scans
+	workers  int           // Current budget
+	lowest   int           // Range of budgets used, for the final report
+	highest  int
+	bestMean time.Duration // Lowest mean latency of any window
+	lastRate float64       // Throughput of the previous window (scans/s)
+	grew     bool          // Whether the previous step was a step up
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+/**
+ * @brief Starts the controller.
+ * @param sched The scheduler whose budget is resized.
+ * @param start The initial budget (--workers).
+ * @param max The largest budget (--max-workers).
+ * @return The running autoscaler.
+ */
+func startAutoscaler(sched *scheduler, start, max int) *autoscaler {
+	if max < start {
+		max = start
+	}
+	s := &autoscaler{sched: sched, max: max, workers: start, lowest: start, highest: start,
+		stop: make(chan struct{}), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+/**
+ * @brief Records the latency of one scan.
+ */
+func (s *autoscaler) observe(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.scans++
+	s.latency += d
+	s.mu.Unlock()
+}
+
+// run adjusts the budget once per interval until close is called.
+func (s *autoscaler) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(autoscaleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.adjust(loadPerCPU())
+		}
+	}
+}
+
+// adjust takes one control step from the window just ended.
+func (s *autoscaler) adjust(load float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scans, latency := s.scans, s.latency
+	s.scans, s.latency = 0, 0
+	if scans == 0 {
+		return // Idle, e.g. between repositories of a sweep
+	}
+	rate := float64(scans) / autoscaleInterval.Seconds()
+	mean := latency / time.Duration(scans)
+	if s.bestMean == 0 || mean < s.bestMean {
+		s.bestMean = mean
+	}
+
+	next := s.workers
+	switch {
+	case load > autoscaleHighLoad:
+		next = s.workers * 3 / 4
+	case s.grew && rate < s.lastRate*autoscaleGainFloor:
+		next = s.workers - 1 // The last step did not pay off
+	case (load < 0 || load < autoscaleLowLoad) && mean <= time.Duration(float64(s.bestMean)*autoscaleLatencySlack):
+		next = s.workers + 1
+	}
+	if next < 1 {
+		next = 1
+	}
+	if next > s.max {
+		next = s.max
+	}
+	s.grew = next > s.workers
+	s.lastRate = rate
+	if next != s.workers {
+		s.workers = next
+		s.sched.resize(next)
+		if next < s.lowest {
+			s.lowest = next
+		}
+		if next > s.highest {
+			s.highest = next
+		}
+	}
+}
+
+/**
+ * @brief Stops the controller and reports the range of budgets it used.
+ */
+func (s *autoscaler) close() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	fmt.Fprintf(os.Stderr, "Go analyzer: autoscale: used %d to %d workers, ended at %d\n", s.lowest, s.highest, s.workers)
+}
+
+/**
+ * @brief Returns the 1-minute load average divided by the CPU count.
+ * @return The load per CPU, or -1 where /proc/loadavg is not available.
+ */
+func loadPerCPU() float64 {
+	data, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return -1
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return -1
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return -1
+	}
+	return load / float64(runtime.NumCPU())
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestAutoscaler returns an autoscaler without its control loop, stepped by hand.
+func newTestAutoscaler(start, max int) *autoscaler {
+	return &autoscaler{sched: newScheduler(start), max: max, workers: start, lowest: start, highest: start}
+}
+
+// step records scans of the given latency and takes one control step.
+func (s *autoscaler) step(scans int, latency time.Duration, load float64) int {
+	for i := 0; i < scans; i++ {
+		s.observe(latency)
+	}
+	s.adjust(load)
+	return s.workers
+}
+
+func TestAutoscalerGrowsWhileThroughputRises(t *testing.T) {
+	s := newTestAutoscaler(2, 4)
+	for i, want := range []int{3, 4, 4} {
+		if got := s.step(10*(i+1), 10*time.Millisecond, 0.2); got != want {
+			t.Fatalf("step %d: %d workers, want %d", i, got, want)
+		}
+	}
+	if capacity, _ := s.sched.usage(); capacity != 4 {
+		t.Errorf("scheduler capacity %d, want 4", capacity)
+	}
+	if s.step(0, 0, 0.2) != 4 {
+		t.Error("an idle window changed the budget")
+	}
+}
+
+func TestAutoscalerBacksOff(t *testing.T) {
+	s := newTestAutoscaler(8, 16)
+	if got := s.step(10, 10*time.Millisecond, 2.0); got != 6 {
+		t.Errorf("high load: %d workers, want 6", got)
+	}
+
+	s = newTestAutoscaler(4, 16)
+	s.step(20, 10*time.Millisecond, 0.2) // Up to 5
+	if got := s.step(20, 10*time.Millisecond, 0.2); got != 4 {
+		t.Errorf("a step up without a throughput gain: %d workers, want 4", got)
+	}
+
+	s = newTestAutoscaler(4, 16)
+	s.step(20, 10*time.Millisecond, -1) // Latency alone where load is unknown: up to 5
+	if got := s.step(30, 50*time.Millisecond, -1); got != 5 {
+		t.Errorf("latency well above the best: %d workers, want 5", got)
+	}
+	if s.lowest != 4 || s.highest != 5 {
+		t.Errorf("range %d to %d", s.lowest, s.highest)
+	}
+
+	s = newTestAutoscaler(1, 4)
+	if got := s.step(10, time.Millisecond, 9); got != 1 {
+		t.Errorf("the budget dropped below 1: %d", got)
+	}
+}
+
+func TestAutoscalerStops(t *testing.T) {
+	s := startAutoscaler(newScheduler(2), 2, 1)
+	if s.max != 2 {
+		t.Errorf("max %d below the start", s.max)
+	}
+	s.close()
+	var off *autoscaler
+	off.observe(time.Second)
+	off.close()
+}
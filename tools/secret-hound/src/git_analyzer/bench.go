@@ -0,0 +1,376 @@
+/**
+ * @file bench.go
+ * @brief The bench subcommand: scan throughput by backend and worker count.
+ *
+ * `git_analyzer bench` reads a sample of blobs from a repository's history
+ * into memory once, then scans that sample with every combination of backend
+ * and worker count, and prints blobs/s and MB/s for each run. Reading is
+ * measured separately, so the table shows whether git IO or scanning is the
+ * bottleneck on the machine. Backends:
+ *   exec    the C++ core scanner, one process per blob (stdin or a temp file)
+ *   native  the rule engine built into the analyzer
+ * The core has no batch or persistent (daemon) mode yet; exec is the only way
+ * the analyzer drives it. With --profile-dir a CPU and a heap profile are
+ * written for every run (analyzer side only: core processes are not profiled).
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+/**
+ * @struct benchResult
+ * @brief Throughput of one benchmark run.
+ */
+type benchResult struct {
+	Backend     string  `json:"backend"` // "git-read" for the content read phase
+	Workers     int     `json:"workers"`
+	Blobs       int     `json:"blobs"`
+	Bytes       int64   `json:"bytes"`
+	Seconds     float64 `json:"seconds"`
+	BlobsPerSec float64 `json:"blobs_per_sec"`
+	MBPerSec    float64 `json:"mb_per_sec"`
+	Findings    int64   `json:"findings"`
+}
+
+// newBenchResult derives the rates of a run.
+func newBenchResult(backend string, workers, blobs int, bytes int64, elapsed time.Duration, findings int64) benchResult {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		seconds = 1e-9
+	}
+	return benchResult{
+		Backend:     backend,
+		Workers:     workers,
+		Blobs:       blobs,
+		Bytes:       bytes,
+		Seconds:     elapsed.Round(time.Millisecond).Seconds(),
+		BlobsPerSec: float64(blobs) / seconds,
+		MBPerSec:    float64(bytes) / (1 << 20) / seconds,
+		Findings:    findings,
+	}
+}
+
+/**
+ * @brief Parses a comma-separated list of positive worker counts.
+ */
+func parseWorkerCounts(spec string) ([]int, error) {
+	var counts []int
+	for _, field := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid worker count %q", field)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
+
+/**
+ * @brief Runs the bench subcommand.
+ * @param args The arguments following "bench".
+ * @return The process exit code.
+ */
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	gitDir := fs.String("git-dir", "", "Repository to sample (default: discover from the current directory)")
+	backendList := fs.String("backends", "", "Backends to compare: exec, native (default: both if a core path is given, else native)")
+	workerList := fs.String("workers", defaultBenchWorkers(), "Comma-separated worker counts to try")
+	depth := fs.Int("depth", 100, "Number of commits to sample blobs from")
+	maxBlobs := fs.Int("max-blobs", 2000, "Maximum number of blobs in the sample (0 = all)")
+	rulesPath := fs.String("rules", "", "Rules file for both backends (default: the core's rules, or the built-in ones)")
+	tmpDir := fs.String("tmp-dir", "", "Directory for temporary files of the exec backend")
+	profileDir := fs.String("profile-dir", "", "Write a CPU and a heap pprof profile of every run to this directory")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer bench [options] [path_to_hound_core]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	corePath := fs.Arg(0)
+	backends := []string{"native"}
+	if corePath != "" {
+		backends = []string{"exec", "native"}
+	}
+	if *backendList != "" {
+		backends = strings.Split(*backendList, ",")
+	}
+	workers, err := parseWorkerCounts(*workerList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --workers: %v\n", err)
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: --format: expected text or json, got %q\n", *format)
+		return 2
+	}
+	if *profileDir != "" {
+		if err := os.MkdirAll(*profileDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --profile-dir: %v\n", err)
+			return 1
+		}
+	}
+	resolvedTmp, err := resolveTempDir(*tmpDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --tmp-dir: %v\n", err)
+		return 1
+	}
+
+	// Build one analyzer per backend up front so a bad core fails before any timing.
+	analyzers := make(map[string]*analyzer)
+	for i, backend := range backends {
+		backend = strings.TrimSpace(backend)
+		backends[i] = backend
+		a, err := newBenchAnalyzer(backend, corePath, *rulesPath, resolvedTmp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --backends: %s: %v\n", backend, err)
+			return 1
+		}
+		analyzers[backend] = a
+	}
+
+	repo := &repository{gitDir: *gitDir}
+	if _, err := repo.check(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	blobs, err := benchSample(repo, *depth, *maxBlobs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	maxWorkers := 0
+	for _, n := range workers {
+		if n > maxWorkers {
+			maxWorkers = n
+		}
+	}
+	contents, readResult := benchRead(blobs, maxWorkers)
+	fmt.Fprintf(os.Stderr, "Go analyzer: bench: %d blobs, %.1f MB sampled from up to %d commits\n",
+		len(blobs), float64(readResult.Bytes)/(1<<20), *depth)
+
+	results := []benchResult{readResult}
+	for _, backend := range backends {
+		for _, n := range workers {
+			stop := startBenchProfile(*profileDir, backend, n)
+			result := benchScan(analyzers[backend], backend, blobs, contents, n)
+			stop()
+			results = append(results, result)
+			fmt.Fprintf(os.Stderr, "Go analyzer: bench: %s with %d workers: %.1f MB/s\n", backend, n, result.MBPerSec)
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, r := range results {
+			encoder.Encode(r)
+		}
+		return 0
+	}
+	printBench(os.Stdout, results)
+	return 0
+}
+
+// defaultBenchWorkers doubles from 1 up to twice the CPU count.
+func defaultBenchWorkers() string {
+	var counts []string
+	for n := 1; n <= 2*runtime.NumCPU(); n *= 2 {
+		counts = append(counts, strconv.Itoa(n))
+	}
+	return strings.Join(counts, ",")
+}
+
+/**
+ * @brief Sets up an analyzer that scans with one backend and nothing else.
+ * Enrichment, policy and output are left out: only scanning is measured.
+ */
+func newBenchAnalyzer(backend, corePath, rulesPath, tmpDir string) (*analyzer, error) {
+	opts := options{
+		engine:          "native",
+		houndCorePath:   corePath,
+		rulesPath:       rulesPath,
+		tmpDir:          tmpDir,
+		decodeMinLength: 32,
+	}
+	var rules *ruleSet
+	var err error
+	switch {
+	case rulesPath != "":
+		rules, err = loadRuleSet(rulesPath)
+	case corePath != "":
+		rules, err = loadRuleSet(defaultRulesPath(corePath))
+		if os.IsNotExist(err) {
+			rules, err = embeddedRuleSet()
+		}
+	default:
+		rules, err = embeddedRuleSet()
+	}
+	if err != nil {
+		return nil, err
+	}
+	a := &analyzer{opts: opts, rules: rules, detectors: builtinDetectors()}
+	switch backend {
+	case "native":
+		a.engine = newNativeEngine(rules)
+	case "exec":
+		if corePath == "" {
+			return nil, fmt.Errorf("needs a path_to_hound_core argument")
+		}
+		a.opts.engine = "core"
+		if a.core, err = handshakeCore(corePath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown backend (expected exec or native)")
+	}
+	return a, nil
+}
+
+/**
+ * @brief Picks the unique, non-symlink blobs of the last commits.
+ */
+func benchSample(repo *repository, depth, maxBlobs int) ([]fileBlob, error) {
+	cache, _ := loadCommitCache("")
+	blobs, err := getGitBlobs(repo, depth, cache, mergePolicy{})
+	if err != nil {
+		return nil, fmt.Errorf("getting git blobs: %v", err)
+	}
+	seen := make(map[string]bool)
+	sample := blobs[:0:0]
+	for _, blob := range blobs {
+		if blob.mode == modeSymlink || seen[blob.hash] {
+			continue
+		}
+		seen[blob.hash] = true
+		sample = append(sample, blob)
+		if maxBlobs > 0 && len(sample) == maxBlobs {
+			break
+		}
+	}
+	return sample, nil
+}
+
+/**
+ * @brief Reads the sample into memory, measuring git read throughput.
+ */
+func benchRead(blobs []fileBlob, workers int) ([][]byte, benchResult) {
+	contents := make([][]byte, len(blobs))
+	var total int64
+	start := time.Now()
+	runBenchWorkers(len(blobs), workers, func(i int) {
+		content, err := readBlobContent(blobs[i])
+		if err == nil {
+			contents[i] = content
+			atomic.AddInt64(&total, int64(len(content)))
+		}
+	})
+	return contents, newBenchResult("git-read", workers, len(blobs), total, time.Since(start), 0)
+}
+
+/**
+ * @brief Scans the in-memory sample with one backend and worker count.
+ */
+func benchScan(a *analyzer, backend string, blobs []fileBlob, contents [][]byte, workers int) benchResult {
+	var total, findings int64
+	start := time.Now()
+	runBenchWorkers(len(blobs), workers, func(i int) {
+		found, err := a.scanContent(blobs[i], contents[i])
+		scanFailures.record(err)
+		found = append(found, a.unwrapEncoded(blobs[i], contents[i])...)
+		atomic.AddInt64(&total, int64(len(contents[i])))
+		atomic.AddInt64(&findings, int64(len(found)))
+	})
+	return newBenchResult(backend, workers, len(blobs), total, time.Since(start), findings)
+}
+
+// runBenchWorkers calls work for every index in [0, n) from the given number of goroutines.
+func runBenchWorkers(n, workers int, work func(i int)) {
+	jobs := make(chan int, n)
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+/**
+ * @brief Starts a CPU profile for a run if --profile-dir is set.
+ * @return A function that stops it and writes the heap profile.
+ */
+func startBenchProfile(dir, backend string, workers int) func() {
+	if dir == "" {
+		return func() {}
+	}
+	name := fmt.Sprintf("%s-%dw", backend, workers)
+	cpu, err := os.Create(filepath.Join(dir, "cpu-"+name+".pprof"))
+	if err == nil {
+		err = pprof.StartCPUProfile(cpu)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: bench: CPU profile: %v\n", err)
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+		}
+		heap, err := os.Create(filepath.Join(dir, "heap-"+name+".pprof"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: bench: heap profile: %v\n", err)
+			return
+		}
+		runtime.GC()
+		pprof.WriteHeapProfile(heap)
+		heap.Close()
+	}
+}
+
+/**
+ * @brief Prints the results as a table, followed by the fastest configuration.
+ */
+func printBench(w io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "BACKEND\tWORKERS\tBLOBS\tSECONDS\tBLOBS/S\tMB/S\tFINDINGS\t")
+	var best *benchResult
+	for i, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.3f\t%.1f\t%.2f\t%d\t\n", r.Backend, r.Workers, r.Blobs, r.Seconds, r.BlobsPerSec, r.MBPerSec, r.Findings)
+		if r.Backend != "git-read" && (best == nil || r.MBPerSec > best.MBPerSec) {
+			best = &results[i]
+		}
+	}
+	tw.Flush()
+	if best != nil {
+		engine := "--engine native"
+		if best.Backend == "exec" {
+			engine = "--engine core"
+		}
+		fmt.Fprintf(w, "\nFastest: %s with %d workers (%s --workers %d)\n", best.Backend, best.Workers, engine, best.Workers)
+	}
+}
@@ -0,0 +1,117 @@
+/**
+ * @file blame.go
+ * @brief Line ownership of findings with git blame (--blame).
+ *
+ * The author enricher names the author of the commit that introduced the
+ * blob, which is not always who wrote the secret: a merge commit, a squash
+ * by a maintainer or a bulk reformat introduces a blob full of lines other
+ * people wrote. With --blame, a blame scoped to the line of the secret is
+ * run at the introducing commit (`git blame -L <line>,<line> <commit>`),
+ * following moves within the file, and the commit that last changed that
+ * line and its author are added as blame_commit and blame_author. That is
+ * the person to notify in merge-heavy workflows. The blame costs one git
+ * process per finding, hence the flag; working tree findings are not blamed.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/**
+ * @struct lineOwner
+ * @brief The commit that last changed a line, and its author.
+ */
+type lineOwner struct {
+	commit string
+	author string // "Name <email>"
+}
+
+// lineKey identifies a line of a blob at a commit in the blame cache.
+type lineKey struct {
+	commitKey
+	path string
+	line int
+}
+
+/**
+ * @struct blameEnricher
+ * @brief Adds the owner of the secret's line (metadata blame_author, blame_commit).
+ */
+type blameEnricher struct {
+	enabled bool
+
+	mu     sync.Mutex
+	owners map[lineKey]lineOwner
+}
+
+func (*blameEnricher) name() string { return "blame" }
+
+func (e *blameEnricher) enrich(f *finding, in *enrichInput) {
+	if !e.enabled || f.Line <= 0 || in.blob.commit == "" || in.blob.commit == worktreeCommit {
+		return
+	}
+	key := lineKey{commitKey{in.blob.repo, in.blob.commit}, in.blob.path, f.Line}
+	e.mu.Lock()
+	owner, ok := e.owners[key]
+	e.mu.Unlock()
+	if !ok {
+		owner = blameLine(in.blob.repo, in.blob.commit, in.blob.path, f.Line)
+		e.mu.Lock()
+		e.owners[key] = owner
+		e.mu.Unlock()
+	}
+	setMetadata(f, "blame_commit", owner.commit)
+	setMetadata(f, "blame_author", owner.author)
+}
+
+/**
+ * @brief Blames one line of a file at a commit.
+ * @param repo The repository.
+ * @param commit The commit the file is blamed at.
+ * @param path The path of the file in that commit.
+ * @param line The 1-based line number.
+ * @return The owner of the line, or the zero value if git cannot blame it.
+ */
+func blameLine(repo *repository, commit, path string, line int) lineOwner {
+	output, err := repo.command("blame", "--porcelain", "-M", "-L", fmt.Sprintf("%d,%d", line, line), commit, "--", path).Output()
+	if err != nil {
+		return lineOwner{}
+	}
+	return parseBlamePorcelain(output)
+}
+
+/**
+ * @brief Reads the owner of the first line of `git blame --porcelain` output.
+ * The first header line is "<commit> <original line> <final line> <count>",
+ * followed by "author" and "author-mail" lines.
+ */
+func parseBlamePorcelain(output []byte) lineOwner {
+	var owner lineOwner
+	var name, mail string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case owner.commit == "":
+			if fields := strings.Fields(text); len(fields) >= 3 {
+				owner.commit = fields[0]
+			}
+		case strings.HasPrefix(text, "author "):
+			name = strings.TrimPrefix(text, "author ")
+		case strings.HasPrefix(text, "author-mail "):
+			mail = strings.TrimPrefix(text, "author-mail ")
+		case strings.HasPrefix(text, "\t"):
+			// The content line ends the first entry.
+			owner.author = strings.TrimSpace(name + " " + mail)
+			return owner
+		}
+	}
+	owner.author = strings.TrimSpace(name + " " + mail)
+	return owner
+}
@@ -0,0 +1,330 @@
+/**
+ * @file bundle.go
+ * @brief Scanning git bundles and emailed patch series (--bundle, --mailbox).
+ *
+ * Not every history lives in a repository one can point --git-dir at:
+ * backups are kept as `git bundle` files, and projects that review by email
+ * exchange `git format-patch` series. Both are unpacked into a temporary
+ * repository that is then scanned like any other, with the file name as the
+ * repository label of the findings.
+ *
+ * A bundle is cloned as it is. A mailbox (an mbox file, or a single patch)
+ * usually does not apply anywhere the scanner can reach, since its base
+ * commit is missing, so it is replayed instead: every email becomes one
+ * commit with the email's author, date and subject, whose files hold the
+ * lines the patch adds at their line numbers in the patched file, with
+ * blank lines in between. Findings therefore point at the right line of the
+ * file as the patch leaves it. Context and removed lines are not scanned:
+ * they were not introduced by the series.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * @brief Returns the repository to scan: --git-dir, or an unpacked --bundle or --mailbox.
+ * @param opts The run's options (tmpDir resolved).
+ * @return The repository, the temporary directory to remove after the scan
+ *         ("" if nothing was unpacked), and an error for conflicting inputs or
+ *         an input that cannot be unpacked.
+ */
+func unpackInput(opts options) (*repository, string, error) {
+	input, flagName, unpack := opts.bundle, "--bundle", unpackBundle
+	if opts.mailbox != "" {
+		input, flagName, unpack = opts.mailbox, "--mailbox", importMailbox
+	}
+	switch {
+	case input == "":
+		return &repository{gitDir: opts.gitDir}, "", nil
+	case opts.bundle != "" && opts.mailbox != "":
+		return nil, "", fmt.Errorf("--bundle and --mailbox are mutually exclusive")
+	case opts.gitDir != "" || len(opts.remotes) > 0 || opts.includeWorktree:
+		return nil, "", fmt.Errorf("%s cannot be combined with --git-dir, --remote or --include-worktree", flagName)
+	}
+	dir, gitDir, err := unpack(input, opts.tmpDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", flagName, err)
+	}
+	return &repository{gitDir: gitDir, label: filepath.Base(input)}, dir, nil
+}
+
+/**
+ * @brief Clones a git bundle into a temporary bare repository.
+ * @param bundle The bundle file.
+ * @param tmpDir The directory to create the repository in.
+ * @return The temporary directory, the repository's git directory, and an
+ *         error if the bundle is invalid.
+ */
+func unpackBundle(bundle, tmpDir string) (string, string, error) {
+	dir, err := ioutil.TempDir(tmpDir, "secret-hound-bundle-")
+	if err != nil {
+		return "", "", err
+	}
+	if output, err := exec.Command("git", "clone", "-q", "--mirror", bundle, dir).CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", "", fmt.Errorf("%s: %s", bundle, strings.TrimSpace(string(output)))
+	}
+	// A bundle without HEAD is walked from its first ref.
+	repo := &repository{gitDir: dir}
+	if repo.command("rev-parse", "--verify", "-q", "HEAD").Run() != nil {
+		refs, _ := repo.command("for-each-ref", "--format=%(refname)", "--count=1").Output()
+		if ref := strings.TrimSpace(string(refs)); ref != "" {
+			repo.command("symbolic-ref", "HEAD", ref).Run()
+		}
+	}
+	return dir, dir, nil
+}
+
+/**
+ * @struct mailPatch
+ * @brief One email of a patch series.
+ */
+type mailPatch struct {
+	author  string // "Name <email>"
+	date    time.Time
+	subject string
+	files   map[string][]string // Path -> lines of the patched file (only added lines set)
+}
+
+// patchSubjectPrefix matches "[PATCH v2 3/7] " and similar tags.
+var patchSubjectPrefix = regexp.MustCompile(`^(\[[^\]]*\]\s*)+`)
+
+// hunkHeader matches "@@ -12,7 +12,9 @@" and captures the new start line.
+var hunkHeader = regexp.MustCompile(`^@@+ (?:-\d+(?:,\d+)? )+\+(\d+)(?:,\d+)? @@`)
+
+/**
+ * @brief Replays the patches of a mailbox as commits of a temporary repository.
+ * @param mailbox The mbox file or single patch.
+ * @param tmpDir The directory to create the repository in.
+ * @return The temporary directory, the repository's git directory, and an
+ *         error if nothing could be replayed.
+ */
+func importMailbox(mailbox, tmpDir string) (string, string, error) {
+	data, err := ioutil.ReadFile(mailbox)
+	if err != nil {
+		return "", "", err
+	}
+	patches := parseMailbox(data, filepath.Base(mailbox))
+	if len(patches) == 0 {
+		return "", "", fmt.Errorf("%s: no patches found", mailbox)
+	}
+
+	dir, err := ioutil.TempDir(tmpDir, "secret-hound-mailbox-")
+	if err != nil {
+		return "", "", err
+	}
+	git := func(env []string, args ...string) error {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), env...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if err := git(nil, "init", "-q"); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	for _, p := range patches {
+		for name, lines := range p.files {
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(file), 0o700); err == nil {
+				err = ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+			}
+			if err != nil {
+				os.RemoveAll(dir)
+				return "", "", err
+			}
+		}
+		name, email := p.author, ""
+		if addr, err := mail.ParseAddress(p.author); err == nil {
+			name, email = addr.Name, addr.Address
+		}
+		date := p.date.Format(time.RFC3339)
+		env := []string{"GIT_AUTHOR_NAME=" + name, "GIT_AUTHOR_EMAIL=" + email, "GIT_AUTHOR_DATE=" + date,
+			"GIT_COMMITTER_NAME=" + name, "GIT_COMMITTER_EMAIL=" + email, "GIT_COMMITTER_DATE=" + date}
+		if err := git(nil, "add", "-A"); err == nil {
+			err = git(env, "-c", "commit.gpgsign=false", "commit", "-q", "--allow-empty", "--no-verify", "-m", p.subject)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", "", err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: replayed %d patch(es) from %s\n", len(patches), mailbox)
+	return dir, filepath.Join(dir, ".git"), nil
+}
+
+/**
+ * @brief Splits a mailbox into its patches.
+ * Messages are separated by mbox "From " lines; input without one is a
+ * single patch, e.g. plain `git diff` output.
+ * @param data The mailbox content.
+ * @param fallbackSubject The subject of patches without headers.
+ * @return The patches that change at least one file, in mailbox order.
+ */
+func parseMailbox(data []byte, fallbackSubject string) []*mailPatch {
+	var messages [][]byte
+	var current []byte
+	start := true
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if start && bytes.HasPrefix(line, []byte("From ")) {
+			if len(current) > 0 {
+				messages = append(messages, current)
+			}
+			current = nil
+			start = false
+			continue
+		}
+		current = append(current, line...)
+		start = len(bytes.TrimSpace(line)) == 0
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+
+	var patches []*mailPatch
+	for _, message := range messages {
+		p := &mailPatch{author: "unknown <unknown@invalid>", subject: fallbackSubject}
+		body := message
+		if msg, err := mail.ReadMessage(bytes.NewReader(message)); err == nil && msg.Header.Get("From") != "" {
+			decoder := new(mime.WordDecoder)
+			if from, err := decoder.DecodeHeader(msg.Header.Get("From")); err == nil {
+				p.author = from
+			}
+			if subject, err := decoder.DecodeHeader(msg.Header.Get("Subject")); err == nil && subject != "" {
+				p.subject = patchSubjectPrefix.ReplaceAllString(subject, "")
+			}
+			if date, err := msg.Header.Date(); err == nil {
+				p.date = date
+			}
+			body = mailBody(msg)
+		}
+		if p.date.IsZero() {
+			p.date = time.Unix(0, 0).UTC()
+		}
+		if p.files = patchedLines(body); len(p.files) > 0 {
+			patches = append(patches, p)
+		}
+	}
+	return patches
+}
+
+/**
+ * @brief Returns the decoded text of an email, joining the parts of a multipart message.
+ */
+func mailBody(msg *mail.Message) []byte {
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var text []byte
+		reader := multipart.NewReader(msg.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart() // Decodes quoted-printable parts itself
+			if err != nil {
+				break
+			}
+			content, _ := ioutil.ReadAll(decodeTransfer(part, part.Header.Get("Content-Transfer-Encoding")))
+			text = append(append(text, content...), '\n')
+		}
+		return text
+	}
+	content, _ := ioutil.ReadAll(decodeTransfer(msg.Body, msg.Header.Get("Content-Transfer-Encoding")))
+	return content
+}
+
+// decodeTransfer undoes a Content-Transfer-Encoding.
+func decodeTransfer(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+/**
+ * @brief Collects the added lines of every file in a unified diff.
+ * @param diff The patch text.
+ * @return For each added or modified file, its lines in the patched file;
+ *         lines the patch does not add are empty.
+ */
+func patchedLines(diff []byte) map[string][]string {
+	files := make(map[string][]string)
+	var lines []string
+	var name string
+	next := 0 // 1-based line number in the patched file of the next hunk line
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name = patchPath(strings.TrimPrefix(line, "+++ "))
+			lines = files[name]
+			next = 0
+		case strings.HasPrefix(line, "@@"):
+			if m := hunkHeader.FindStringSubmatch(line); m != nil && name != "" {
+				next, _ = strconv.Atoi(m[1])
+			} else {
+				next = 0
+			}
+		case next > 0 && strings.HasPrefix(line, "+"):
+			for len(lines) < next {
+				lines = append(lines, "")
+			}
+			lines[next-1] = line[1:]
+			files[name] = lines
+			next++
+		case next > 0 && strings.HasPrefix(line, " "):
+			next++
+		case next > 0 && (strings.HasPrefix(line, "-") || strings.HasPrefix(line, `\`)):
+			// Removed line or "\ No newline at end of file": not in the patched file
+		default:
+			next = 0 // End of the hunk, e.g. the signature or the next file's header
+		}
+	}
+	return files
+}
+
+/**
+ * @brief Extracts a safe repository path from a "+++ b/<path>" header.
+ * @return The path, or "" for /dev/null and paths escaping the repository.
+ */
+func patchPath(header string) string {
+	header = strings.TrimSpace(strings.SplitN(header, "\t", 2)[0])
+	if header == "/dev/null" {
+		return ""
+	}
+	if unquoted, err := strconv.Unquote(header); err == nil {
+		header = unquoted
+	}
+	if i := strings.IndexByte(header, '/'); i >= 0 && (header[:i] == "b" || header[:i] == "a") {
+		header = header[i+1:]
+	}
+	clean := path.Clean(header)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.HasPrefix(clean, ".git/") {
+		return ""
+	}
+	return clean
+}
@@ -0,0 +1,158 @@
+/**
+ * @file bypass.go
+ * @brief Break-glass bypass of push hooks (--allow-bypass).
+ *
+ * A hook that rejects every secret also blocks the emergency fix that has to
+ * ship now, with a test key a reviewer already accepted. With --allow-bypass
+ * the pusher can let such a push through by adding a trailer to the tip
+ * commit of every pushed ref:
+ *
+ *     Hound-Bypass: SEC-1234
+ *
+ * The value is a ticket reference, checked against --bypass-ticket-pattern
+ * if one is given. A push whose tips all carry a valid trailer is accepted
+ * despite its findings, the pusher is told the bypass was recorded, and a
+ * "bypass" record with priority high goes to the --audit-log, which
+ * --allow-bypass therefore requires: who pushed which refs, the tickets, and
+ * the findings it let through (location, rule and fingerprint, no secrets).
+ * A trailer on a push without findings is ignored and not recorded.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bypassTrailer is the commit trailer that carries the ticket of a break-glass push.
+const bypassTrailer = "Hound-Bypass"
+
+/**
+ * @struct breakGlass
+ * @brief The bypass tickets of a push; nil when bypasses are not allowed.
+ */
+type breakGlass struct {
+	pattern *regexp.Regexp    // Valid ticket references (nil = any non-empty value)
+	tickets map[string]string // Ticket of each pushed ref whose tip has a valid trailer
+	missing []string          // Pushed refs whose tip has no valid trailer
+}
+
+/**
+ * @brief Enables bypasses.
+ * @param pattern A regular expression a ticket must match entirely ("" = any).
+ * @return The configuration, and an error if the pattern does not compile.
+ */
+func newBreakGlass(pattern string) (*breakGlass, error) {
+	b := &breakGlass{tickets: make(map[string]string)}
+	if pattern != "" {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		b.pattern = re
+	}
+	return b, nil
+}
+
+/**
+ * @brief Reads the bypass trailer of the tip commit of every pushed ref.
+ * @param repo The repository the hook runs in.
+ * @param updates The ref updates of the push.
+ * @return An error if git cannot read a tip commit.
+ */
+func (b *breakGlass) readTrailers(repo *repository, updates []refUpdate) error {
+	if b == nil {
+		return nil
+	}
+	for _, u := range updates {
+		if zeroRev(u.newRev) {
+			continue
+		}
+		format := "--format=%(trailers:key=" + bypassTrailer + ",valueonly,separator=%x00)"
+		output, err := repo.command("log", "--no-walk", format, u.newRev).Output()
+		if err != nil {
+			return fmt.Errorf("reading the trailers of %s: %v", u.newRev, err)
+		}
+		ticket := ""
+		for _, value := range strings.Split(strings.TrimSpace(string(output)), "\x00") {
+			if value = strings.TrimSpace(value); value != "" && (b.pattern == nil || b.pattern.MatchString(value)) {
+				ticket = value
+				break
+			}
+		}
+		if ticket == "" {
+			b.missing = append(b.missing, u.ref)
+		} else {
+			b.tickets[u.ref] = ticket
+		}
+	}
+	return nil
+}
+
+// readBypass reads the bypass tickets of the push, if bypasses are allowed.
+func (h *hookSession) readBypass(repo *repository) error {
+	if h == nil {
+		return nil
+	}
+	return h.bypass.readTrailers(repo, h.updates)
+}
+
+// granted reports whether every pushed ref carries a valid bypass ticket.
+func (b *breakGlass) granted() bool {
+	return b != nil && len(b.tickets) > 0 && len(b.missing) == 0
+}
+
+/**
+ * @brief Lets a push with findings through if it carries bypass tickets, and records it.
+ * Must run before the audit log is closed.
+ * @param audit The audit log the bypass record goes to.
+ * @param w Where the pusher sees the notice (stderr).
+ * @return True if the push is accepted despite its findings.
+ */
+func (h *hookSession) breakGlass(audit *auditLog, w io.Writer) bool {
+	if h == nil || !h.bypass.granted() {
+		return false
+	}
+	h.mu.Lock()
+	findings := h.findings
+	h.mu.Unlock()
+	if len(findings) == 0 {
+		return false
+	}
+	audit.bypassUsed(h.hook, pusherIdentity(), h.bypass.tickets, findings)
+	fmt.Fprintln(w, messages.text("reject.bypassed", "count", len(findings), "ticket", strings.Join(h.bypass.ticketList(), ", ")))
+	return true
+}
+
+// pusherIdentity names who is pushing, from the Git server's environment or the local user.
+func pusherIdentity() string {
+	for _, name := range []string{"GL_USERNAME", "GITHUB_USER_LOGIN", "GITEA_PUSHER_NAME", "BITBUCKET_USER_NAME", "REMOTE_USER"} {
+		if pusher := os.Getenv(name); pusher != "" {
+			return pusher
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// ticketList returns the distinct tickets of the push, sorted.
+func (b *breakGlass) ticketList() []string {
+	seen := make(map[string]bool)
+	var tickets []string
+	for _, ticket := range b.tickets {
+		if !seen[ticket] {
+			seen[ticket] = true
+			tickets = append(tickets, ticket)
+		}
+	}
+	sort.Strings(tickets)
+	return tickets
+}
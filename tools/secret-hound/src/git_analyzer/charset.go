@@ -0,0 +1,124 @@
+/**
+ * @file charset.go
+ * @brief Detects non-UTF-8 text blobs and transcodes them before scanning.
+ *
+ * The core scanner and the native detectors work on UTF-8. A UTF-16 file
+ * (common for Windows tooling output, .reg files and PowerShell scripts)
+ * interleaves NUL bytes with every ASCII character, so no pattern matches,
+ * and Latin-1 text breaks UTF-8 aware matching. With --transcode (the
+ * default) such blobs are converted to UTF-8 first and findings record the
+ * original encoding in their "source_encoding" metadata. Line structure is
+ * preserved, so reported line numbers still refer to the original file.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings reported by detectEncoding.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingCP1252  = "windows-1252"
+	encodingBinary  = "binary"
+)
+
+// cp1252High maps bytes 0x80-0x9F of Windows-1252 to Unicode; the rest of
+// the code page coincides with Latin-1. Undefined bytes map to U+FFFD.
+var cp1252High = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+/**
+ * @brief Guesses the character encoding of a blob.
+ * A byte order mark is authoritative; otherwise UTF-16 is recognised by NUL
+ * bytes concentrated on odd (little-endian) or even (big-endian) offsets,
+ * other content with NUL bytes is binary, valid UTF-8 is UTF-8, and anything
+ * else is taken to be Windows-1252 (a superset of printable Latin-1).
+ * @param content The blob content.
+ * @return One of the encoding constants.
+ */
+func detectEncoding(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE
+	}
+
+	sample := content
+	if len(sample) > 8192 {
+		sample = sample[:8192]
+	}
+	var evenNUL, oddNUL int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+	}
+	if evenNUL+oddNUL > 0 {
+		half := len(sample) / 2
+		switch {
+		case oddNUL*10 >= half*7 && evenNUL*10 < half:
+			return encodingUTF16LE
+		case evenNUL*10 >= half*7 && oddNUL*10 < half:
+			return encodingUTF16BE
+		}
+		return encodingBinary
+	}
+	if utf8.Valid(content) {
+		return encodingUTF8
+	}
+	return encodingCP1252
+}
+
+/**
+ * @brief Converts text in the given encoding to UTF-8.
+ * A leading byte order mark is dropped so line 1 is not shifted.
+ * @param content The blob content.
+ * @param encoding An encoding returned by detectEncoding.
+ * @return The UTF-8 content (the input itself for UTF-8 and binary blobs).
+ */
+func transcodeToUTF8(content []byte, encoding string) []byte {
+	switch encoding {
+	case encodingUTF16LE, encodingUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		if encoding == encodingUTF16BE {
+			order = binary.BigEndian
+		}
+		units := make([]uint16, 0, len(content)/2)
+		for i := 0; i+1 < len(content); i += 2 {
+			units = append(units, order.Uint16(content[i:]))
+		}
+		if len(units) > 0 && units[0] == 0xFEFF {
+			units = units[1:]
+		}
+		return []byte(string(utf16.Decode(units)))
+	case encodingCP1252:
+		var out bytes.Buffer
+		out.Grow(len(content) + len(content)/8)
+		for _, b := range content {
+			switch {
+			case b < 0x80:
+				out.WriteByte(b)
+			case b < 0xA0:
+				out.WriteRune(cp1252High[b-0x80])
+			default:
+				out.WriteRune(rune(b))
+			}
+		}
+		return out.Bytes()
+	}
+	return content
+}
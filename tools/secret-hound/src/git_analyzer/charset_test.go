@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as UTF-16 with a byte order mark if bom is set.
+func utf16Bytes(s string, order binary.ByteOrder, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	out := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(out[2*i:], u)
+	}
+	return out
+}
+
+func TestDetectAndTranscode(t *testing.T) {
+	text := "$password = \"Sécret-123\"\r\n$user = 'svc'\r\n"
+	for _, tc := range []struct {
+		name     string
+		content  []byte
+		encoding string
+		want     string
+	}{
+		{"utf-16le with BOM", utf16Bytes(text, binary.LittleEndian, true), encodingUTF16LE, text},
+		{"utf-16be with BOM", utf16Bytes(text, binary.BigEndian, true), encodingUTF16BE, text},
+		{"utf-16le without BOM", utf16Bytes(text, binary.LittleEndian, false), encodingUTF16LE, text},
+		{"utf-16be without BOM", utf16Bytes(text, binary.BigEndian, false), encodingUTF16BE, text},
+		{"windows-1252", []byte("pass=\x80uro caf\xe9 \x93q\x94"), encodingCP1252, "pass=€uro café “q”"},
+		{"utf-8", []byte(text), encodingUTF8, text},
+		{"binary", []byte{0x7f, 'E', 'L', 'F', 0, 0, 0, 1, 2, 0, 3, 4}, encodingBinary, "\x7fELF\x00\x00\x00\x01\x02\x00\x03\x04"},
+	} {
+		encoding := detectEncoding(tc.content)
+		if encoding != tc.encoding {
+			t.Errorf("%s: detected %s", tc.name, encoding)
+			continue
+		}
+		if got := string(transcodeToUTF8(tc.content, encoding)); got != tc.want {
+			t.Errorf("%s: transcoded to %q", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/**
+ * @file chunk.go
+ * @brief Scanning very large blobs in overlapping windows (--chunk-size).
+ *
+ * SQL dumps, logs and data exports of hundreds of megabytes are where
+ * forgotten credentials tend to sit, but reading one whole holds all of it in
+ * memory at once (and the core scanner gets a file of the same size). Blobs
+ * larger than --chunk-size are instead streamed from git and cut into
+ * windows of about that size:
+ *   - a window ends after its last complete line, so matches are not cut;
+ *     only a single line longer than half a window is split mid-line;
+ *   - consecutive windows overlap by --chunk-overlap bytes (rounded to a line
+ *     start where possible), so a secret spanning a window boundary, such as
+ *     a PEM key, is whole in one of them;
+ *   - every window goes through the scan and enrich stages like a blob of its
+ *     own, with findings renumbered to lines of the whole file, and a secret
+ *     found in two overlapping windows is reported once.
+ * The memory budget is taken per window. Windows bypass the result cache and
+ * are not copied by --export-blobs, and UTF-16 content is scanned as stored.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+ * @struct chunkedBlob
+ * @brief State shared by the windows of one large blob.
+ */
+type chunkedBlob struct {
+	pending atomic.Int32 // Windows in the pipeline, plus one while windows are still being cut
+	log     bool         // The blob is a log collapsed by --normalize-logs, judged on its first window
+
+	mu      sync.Mutex
+	seen    map[string]bool // Findings already reported by an overlapping window
+	settled int             // Windows that went through settle
+	turn    *sync.Cond      // Signalled when a window is settled
+}
+
+// newChunkedBlob returns the state of a blob about to be cut into windows.
+func newChunkedBlob() *chunkedBlob {
+	c := &chunkedBlob{seen: make(map[string]bool)}
+	c.turn = sync.NewCond(&c.mu)
+	c.pending.Store(1)
+	return c
+}
+
+// release marks a window (or the cutting) finished and reports whether it was the last.
+func (c *chunkedBlob) release() bool {
+	return c.pending.Add(-1) == 0
+}
+
+/**
+ * @brief Renumbers a window's findings to lines of the whole file and drops repeats.
+ * @param findings The findings of the window, numbered from its first line.
+ * @param firstLine The number of lines before the window.
+ * @return The findings not already reported by an earlier window.
+ */
+func (c *chunkedBlob) place(findings []*finding, firstLine int) []*finding {
+	kept := findings[:0]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range findings {
+		f.Line += firstLine
+		key := f.RuleID + "\x00" + strconv.Itoa(f.Line) + "\x00" + f.Match
+		if !c.seen[key] {
+			c.seen[key] = true
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+/**
+ * @brief Settles a scanned window, in window order: in a collapsed log (see
+ * logs.go), drops the findings whose secret an earlier window reported at
+ * any line. Waiting for the earlier windows makes the reported line the
+ * first one whatever order the scan workers finish in. Every window must be
+ * settled, whether or not it was kept.
+ */
+func (c *chunkedBlob) settle(w *blobWork) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.settled != w.window {
+		c.turn.Wait()
+	}
+	if c.log {
+		kept := w.findings[:0]
+		for _, f := range w.findings {
+			key := f.RuleID + "\x00" + f.Match
+			if !c.seen[key] {
+				c.seen[key] = true
+				kept = append(kept, f)
+			}
+		}
+		w.findings = kept
+	}
+	c.settled++
+	c.turn.Broadcast()
+}
+
+/**
+ * @struct blobChunker
+ * @brief Cuts a stream into overlapping windows of whole lines.
+ */
+type blobChunker struct {
+	r       *bufio.Reader
+	size    int
+	overlap int
+
+	carry     []byte // Tail of the previous window that starts the next one
+	firstLine int    // Lines before the start of carry
+	started   bool   // A window has been returned
+	done      bool
+}
+
+/**
+ * @brief Returns the next window.
+ * @return The window, the number of lines before it, and io.EOF after the last one.
+ */
+func (c *blobChunker) next() ([]byte, int, error) {
+	if c.done {
+		return nil, 0, io.EOF
+	}
+	buf := make([]byte, c.size)
+	n := copy(buf, c.carry)
+	read, err := io.ReadFull(c.r, buf[n:])
+	buf = buf[:n+read]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		c.done = true
+		if len(buf) == 0 || (c.started && len(buf) == len(c.carry)) {
+			return nil, 0, io.EOF // Nothing past the overlap already scanned
+		}
+		c.started = true
+		return buf, c.firstLine, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	if end < len(buf)/2 {
+		end = len(buf) // A very long line: cut it
+	}
+	window, first := buf[:end], c.firstLine
+	start := end - c.overlap
+	if start <= 0 {
+		start = end
+	} else if nl := bytes.IndexByte(window[start:], '\n'); nl >= 0 && start+nl+1 < end {
+		start += nl + 1
+	}
+	c.firstLine += bytes.Count(buf[:start], []byte("\n"))
+	c.carry = append([]byte(nil), buf[start:]...)
+	c.started = true
+	return window, first, nil
+}
+
+/**
+ * @brief Reports whether a blob is scanned in windows.
+ */
+func (a *analyzer) chunked(blob fileBlob, size int64) bool {
+	return a.opts.chunkSize > 0 && size > a.opts.chunkSize && blob.mode != modeSymlink
+}
+
+/**
+ * @brief Fetch stage of a large blob: streams it and queues its windows.
+ * @param blob The blob.
+ * @param queue The scan queue.
+ * @param done Called once the last window has left the pipeline.
+ */
+func (a *analyzer) fetchChunks(blob fileBlob, queue chan<- *blobWork, done func(fileBlob)) {
+	chunk := newChunkedBlob()
+	defer func() {
+		if chunk.release() {
+			done(blob)
+		}
+	}()
+	stream, wait, err := openBlobStream(blob)
+	if err != nil {
+		a.coverage.skip(blob, "unreadable")
+		a.incidents.skip(blob)
+		scanFailures.record(&gitError{op: "read", blob: blob, err: err})
+		return
+	}
+	c := &blobChunker{r: bufio.NewReaderSize(stream, 64*1024), size: int(a.opts.chunkSize), overlap: int(a.opts.chunkOverlap)}
+	transcode := false
+	windows := 0
+	for first := true; ; first = false {
+		window, firstLine, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			scanFailures.record(&gitError{op: "read", blob: blob, err: err})
+			break
+		}
+		debugBytesScanned.Add(int64(len(window)))
+		if first {
+			debugBlobsScanned.Add(1)
+			transcode = a.opts.transcode && detectEncoding(window) == encodingCP1252
+			chunk.log = a.opts.normalizeLogs && isLogLike(blob.path, window)
+		}
+		w := &blobWork{blob: blob, raw: window, content: window, chunk: chunk, window: windows, firstLine: firstLine}
+		windows++
+		if transcode {
+			w.content, w.sourceEncoding = transcodeToUTF8(window, encodingCP1252), encodingCP1252
+		}
+		a.budget.acquire(int64(len(window)))
+		chunk.pending.Add(1)
+		queue <- w
+	}
+	io.Copy(io.Discard, stream) // Let git finish writing before waiting for it
+	if err := wait(); err != nil {
+		scanFailures.record(&gitError{op: "read", blob: blob, err: err})
+	}
+}
+
+/**
+ * @brief Opens the content of a blob as a stream.
+ * @return The stream, a function that closes it and returns any read error, and an error if it cannot be opened.
+ */
+func openBlobStream(blob fileBlob) (io.Reader, func() error, error) {
+	if blob.diskPath != "" {
+		file, err := os.Open(longPath(blob.diskPath))
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, file.Close, nil
+	}
+	cmd := blob.repo.command("cat-file", "-p", blob.hash)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, func() error {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("git cat-file: %v", err)
+		}
+		return nil
+	}, nil
+}
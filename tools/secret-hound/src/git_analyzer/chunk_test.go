@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBlobChunkerWindowsOverlapOnLines(t *testing.T) {
+	var content strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&content, "line %d of the dump\n", i)
+	}
+	content.WriteString(strings.Repeat("x", 3000)) // A long last line without a newline
+
+	c := &blobChunker{r: bufio.NewReader(strings.NewReader(content.String())), size: 4096, overlap: 512}
+	covered := 0
+	windows := 0
+	for {
+		window, firstLine, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		windows++
+		lines := strings.Split(string(window), "\n")
+		if firstLine < 5000 && lines[0] != fmt.Sprintf("line %d of the dump", firstLine+1) {
+			t.Fatalf("window %d starts with %q, want line %d", windows, lines[0], firstLine+1)
+		}
+		if windows > 1 && firstLine > covered { // Equal when the last window cut a long line
+			t.Fatalf("window %d starts at line %d, not overlapping the %d lines before", windows, firstLine+1, covered)
+		}
+		covered = firstLine + bytes.Count(window, []byte("\n"))
+		if len(window) > 4096 {
+			t.Fatalf("window of %d bytes", len(window))
+		}
+	}
+	if covered != 5000 || !c.done {
+		t.Errorf("windows cover %d lines, want all 5000 and the last", covered)
+	}
+}
+
+func TestChunkedBlobPlacesFindingsOnce(t *testing.T) {
+	c := newChunkedBlob()
+	first := c.place([]*finding{{RuleID: "R", Line: 90, Match: "s3cr3t"}}, 0)
+	second := c.place([]*finding{{RuleID: "R", Line: 10, Match: "s3cr3t"}, {RuleID: "R", Line: 20, Match: "other"}}, 80)
+	if len(first) != 1 || len(second) != 1 || second[0].Line != 100 {
+		t.Errorf("placed %v then %v, want the overlap reported once and line 100", first, second)
+	}
+}
+
+func TestChunkedBlobSettlesLogWindowsInOrder(t *testing.T) {
+	c := newChunkedBlob()
+	c.log = true
+	token := func(line int) *finding { return &finding{RuleID: "TOKEN", Match: "t0k3n", Line: line} }
+	first := &blobWork{chunk: c, window: 0, findings: []*finding{token(1)}}
+	second := &blobWork{chunk: c, window: 1, findings: []*finding{token(204)}}
+
+	// The second window finishes scanning first; it waits for the first one.
+	settled := make(chan struct{})
+	go func() {
+		c.settle(second)
+		close(settled)
+	}()
+	c.settle(first)
+	<-settled
+	if len(first.findings) != 1 || len(second.findings) != 0 {
+		t.Errorf("kept %d and %d finding(s), want the secret at line 1 only", len(first.findings), len(second.findings))
+	}
+}
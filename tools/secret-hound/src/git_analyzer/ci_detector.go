@@ -0,0 +1,191 @@
+/**
+ * @file ci_detector.go
+ * @brief Detects credentials in CI pipeline definitions.
+ *
+ * CI configurations are meant to reference secrets from the CI system's
+ * store (${{ secrets.X }}, $VAR, credentials('id')), and a literal value in
+ * them is a leak to everyone who can read the repository. The detector reads
+ * GitHub Actions workflows, .gitlab-ci.yml, CircleCI configs and Jenkinsfiles
+ * and reports:
+ *   CI_INLINE_SECRET   a literal value in an env/variables/environment block
+ *                      under a secret-like name
+ *   CI_BASE64_ENV      a base64 blob in such a block, whatever its name, since
+ *                      encoded kubeconfigs and service account keys are pasted
+ *                      there to survive YAML quoting
+ *   CI_ECHOED_SECRET   a script step printing a secret to the build log
+ * The echo check reports the print command, not a value: the secret itself
+ * is in the CI system, but every build log since the commit contains it.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ciBase64Value matches a value that is a single long base64 string.
+	ciBase64Value = regexp.MustCompile(`^[A-Za-z0-9+/]{40,}={0,2}$`)
+	// ciEchoedSecret matches a print command whose arguments expand a secret.
+	ciEchoedSecret = regexp.MustCompile(`(?i)\b(echo|printf|print|println|write-host)\b[^|>\n]*?(\$\{\{\s*secrets\.[A-Za-z0-9_]+\s*\}\}|\$\{?[A-Za-z0-9_]*(token|secret|password|passwd|api_?key|credentials?)[A-Za-z0-9_]*\}?)`)
+	// jenkinsAssignment matches NAME = 'literal' in a Jenkinsfile environment block.
+	jenkinsAssignment = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(?:'([^']*)'|"([^"]*)")\s*$`)
+)
+
+/**
+ * @struct ciDetector
+ * @brief The "ci" detector.
+ */
+type ciDetector struct{}
+
+func (ciDetector) name() string { return "ci" }
+
+func (ciDetector) detect(filePath string, content []byte) []detection {
+	system := ciSystem(filePath)
+	var found []detection
+	switch system {
+	case "":
+		return nil
+	case "jenkins":
+		found = detectJenkinsEnvironment(content)
+	default:
+		for _, v := range parseYAMLConfig(content) {
+			if d, ok := ciVariableDetection(v); ok {
+				found = append(found, d)
+			}
+		}
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		loc := ciEchoedSecret.FindStringSubmatchIndex(line)
+		// Piping or redirecting the output (echo $T | docker login --password-stdin) keeps it out of the log.
+		if loc == nil || strings.ContainsAny(line[loc[1]:], "|>") || strings.Contains(line, "::add-mask::") {
+			continue
+		}
+		found = append(found, detection{
+			ruleID:      "CI_ECHOED_SECRET",
+			description: "CI step prints the secret " + line[loc[4]:loc[5]] + " to the build log",
+			line:        i + 1,
+			match:       line[loc[0]:loc[1]],
+			confidence:  "Medium",
+			severity:    "medium",
+		})
+	}
+	for i := range found {
+		if found[i].metadata == nil {
+			found[i].metadata = make(map[string]string)
+		}
+		found[i].metadata["ci_system"] = system
+	}
+	return found
+}
+
+/**
+ * @brief Identifies the CI system a file configures from its path.
+ * @return "github", "gitlab", "circleci", "jenkins", or "" for other files.
+ */
+func ciSystem(filePath string) string {
+	base := path.Base(filePath)
+	dir := path.Dir(filePath)
+	yaml := strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")
+	switch {
+	case yaml && (dir == ".github/workflows" || strings.HasSuffix(dir, "/.github/workflows")):
+		return "github"
+	case base == ".gitlab-ci.yml" || (yaml && strings.Contains(base, "gitlab-ci")):
+		return "gitlab"
+	case yaml && (dir == ".circleci" || strings.HasSuffix(dir, "/.circleci")):
+		return "circleci"
+	case base == "Jenkinsfile" || strings.HasPrefix(base, "Jenkinsfile.") || strings.HasSuffix(base, ".jenkinsfile"):
+		return "jenkins"
+	}
+	return ""
+}
+
+/**
+ * @brief Checks a YAML value that sits in an env, variables or environment block.
+ * GitLab's expanded form (VAR: {value: ..., description: ...}) is named after VAR.
+ */
+func ciVariableDetection(v configValue) (detection, bool) {
+	segments := strings.Split(v.keyPath, ".")
+	inBlock := false
+	for _, s := range segments[:len(segments)-1] {
+		if s == "env" || s == "variables" || s == "environment" {
+			inBlock = true
+		}
+	}
+	name := segments[len(segments)-1]
+	if name == "value" && len(segments) > 1 {
+		name = segments[len(segments)-2]
+	}
+	if !inBlock || strings.Contains(v.value, "${{") {
+		return detection{}, false
+	}
+	if ciBase64Value.MatchString(v.value) {
+		if decoded, err := base64.StdEncoding.DecodeString(v.value); err == nil && utf8.Valid(decoded) && !bytes.ContainsRune(decoded, 0) {
+			return detection{
+				ruleID:      "CI_BASE64_ENV",
+				description: "Base64-encoded value in CI variable '" + name + "'",
+				line:        v.line,
+				match:       v.value,
+				keyPath:     v.keyPath,
+				confidence:  "Medium",
+				severity:    "high",
+				metadata:    map[string]string{"decoded_length": strconv.Itoa(len(decoded))},
+			}, true
+		}
+	}
+	if !isSecretAssignment(name, v.value) {
+		return detection{}, false
+	}
+	return detection{
+		ruleID:      "CI_INLINE_SECRET",
+		description: "Secret hard-coded in CI variable '" + name + "'",
+		line:        v.line,
+		match:       v.value,
+		keyPath:     v.keyPath,
+		confidence:  "Medium",
+		severity:    "high",
+	}, true
+}
+
+/**
+ * @brief Reports literal secrets assigned in the environment blocks of a Jenkinsfile.
+ * Values from credentials('id') and other expressions do not match.
+ */
+func detectJenkinsEnvironment(content []byte) []detection {
+	var found []detection
+	depth := -1 // Brace depth of the environment block being read (-1 = outside)
+	braces := 0
+	for i, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if depth < 0 && strings.HasPrefix(trimmed, "environment") && strings.HasSuffix(trimmed, "{") {
+			depth = braces
+		} else if depth >= 0 {
+			if m := jenkinsAssignment.FindStringSubmatch(line); m != nil {
+				value := m[2] + m[3]
+				if isSecretAssignment(m[1], value) {
+					found = append(found, detection{
+						ruleID:      "CI_INLINE_SECRET",
+						description: "Secret hard-coded in Jenkins environment variable '" + m[1] + "'",
+						line:        i + 1,
+						match:       value,
+						keyPath:     "environment." + m[1],
+						confidence:  "Medium",
+						severity:    "high",
+					})
+				}
+			}
+		}
+		braces += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth >= 0 && braces <= depth {
+			depth = -1
+		}
+	}
+	return found
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCISystem(t *testing.T) {
+	for path, want := range map[string]string{
+		".github/workflows/ci.yml": "github", "sub/.github/workflows/release.yaml": "github",
+		".gitlab-ci.yml": "gitlab", "ci/deploy.gitlab-ci.yml": "gitlab", ".circleci/config.yml": "circleci",
+		"Jenkinsfile": "jenkins", "Jenkinsfile.release": "jenkins", "build.jenkinsfile": "jenkins",
+		".github/dependabot.yml": "", "config.yml": "",
+	} {
+		if got := ciSystem(path); got != want {
+			t.Errorf("ciSystem(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestGitHubWorkflowDetections(t *testing.T) {
+	kubeconfig := base64.StdEncoding.EncodeToString([]byte("apiVersion: v1\nkind: Config\nclusters: []\n"))
+	workflow := `on: push
+env:
+  DEPLOY_TOKEN: ghp_l1t3r4lT0k3nValue
+  FROM_STORE: ${{ secrets.DEPLOY_TOKEN }}
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    env:
+      KUBECONFIG_DATA: ` + kubeconfig + `
+    steps:
+      - run: echo "token is ${{ secrets.DEPLOY_TOKEN }}"
+      - run: echo $API_TOKEN | docker login --password-stdin
+      - run: echo "::add-mask::$API_TOKEN"
+      - run: echo "building"
+`
+	found := ciDetector{}.detect(".github/workflows/deploy.yml", []byte(workflow))
+	rules := make(map[string]detection)
+	for _, d := range found {
+		rules[d.ruleID] = d
+		if d.metadata["ci_system"] != "github" {
+			t.Errorf("%s: ci_system %q", d.ruleID, d.metadata["ci_system"])
+		}
+	}
+	if len(found) != 3 {
+		t.Fatalf("%d detections, want 3: %+v", len(found), found)
+	}
+	if d := rules["CI_INLINE_SECRET"]; d.match != "ghp_l1t3r4lT0k3nValue" || d.line != 3 {
+		t.Errorf("inline secret %+v", d)
+	}
+	if d := rules["CI_BASE64_ENV"]; d.keyPath != "jobs.deploy.env.KUBECONFIG_DATA" || d.metadata["decoded_length"] == "" {
+		t.Errorf("base64 env %+v", d)
+	}
+	if d := rules["CI_ECHOED_SECRET"]; d.line != 11 || !strings.Contains(d.description, "secrets.DEPLOY_TOKEN") {
+		t.Errorf("echoed secret %+v", d)
+	}
+}
+
+func TestGitLabExpandedVariables(t *testing.T) {
+	config := `variables:
+  DB_PASSWORD:
+    value: hunter2hunter2
+    description: The database password
+  LOG_LEVEL: debug
+`
+	found := ciDetector{}.detect(".gitlab-ci.yml", []byte(config))
+	if len(found) != 1 || found[0].ruleID != "CI_INLINE_SECRET" || found[0].match != "hunter2hunter2" || !strings.Contains(found[0].description, "'DB_PASSWORD'") {
+		t.Errorf("gitlab detections %+v", found)
+	}
+}
+
+func TestJenkinsEnvironment(t *testing.T) {
+	jenkinsfile := `pipeline {
+  environment {
+    NEXUS_PASSWORD = 'hunter2hunter2'
+    FROM_STORE = credentials('nexus')
+    VERSION = "1.0"
+  }
+  stages {
+    stage('build') {
+      steps {
+        sh 'API_TOKEN = "not an environment block"'
+      }
+    }
+  }
+}
+`
+	found := ciDetector{}.detect("Jenkinsfile", []byte(jenkinsfile))
+	if len(found) != 1 || found[0].keyPath != "environment.NEXUS_PASSWORD" || found[0].line != 3 || found[0].metadata["ci_system"] != "jenkins" {
+		t.Errorf("jenkins detections %+v", found)
+	}
+	if found := (ciDetector{}).detect("docs/ci.yml", []byte("env:\n  API_TOKEN: hunter2hunter2\n")); found != nil {
+		t.Errorf("a file outside CI configuration was read: %+v", found)
+	}
+}
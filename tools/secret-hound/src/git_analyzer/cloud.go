@@ -0,0 +1,152 @@
+/**
+ * @file cloud.go
+ * @brief Enriches cloud credentials with the account or project they belong to.
+ *
+ * Security teams route findings to the team that owns the affected cloud
+ * account, so every AWS, GCP or Azure credential gets "cloud_provider" and
+ * "cloud_account" metadata when the owner can be derived offline:
+ *   - AWS access key IDs encode the 12-digit account ID (newer keys only).
+ *   - GCP service account key files name their project and service account.
+ *   - Azure storage connection strings and blob URLs name the storage account;
+ *     login.microsoftonline.com URLs name the tenant.
+ */
+
+package main
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	awsKeyIDPattern      = regexp.MustCompile(`\b((?:AKIA|ASIA|AIDA|AROA|ANPA|AGPA)[A-Z2-7]{16})\b`)
+	gcpAPIKeyPattern     = regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`)
+	azureAccountPattern  = regexp.MustCompile(`AccountName=([a-z0-9]{3,24})`)
+	azureBlobHostPattern = regexp.MustCompile(`([a-z0-9]{3,24})\.(?:blob|file|queue|table|dfs)\.core\.windows\.net`)
+	azureTenantPattern   = regexp.MustCompile(`login\.microsoftonline\.com/([0-9a-fA-F-]{36})`)
+)
+
+// awsKeyTypes describes the principal behind each AWS key ID prefix.
+var awsKeyTypes = map[string]string{
+	"AKIA": "access_key",
+	"ASIA": "temporary_access_key",
+	"AIDA": "iam_user",
+	"AROA": "iam_role",
+	"ANPA": "managed_policy",
+	"AGPA": "iam_group",
+}
+
+/**
+ * @brief Adds cloud ownership metadata to a finding when it can be derived.
+ * @param f The finding to enrich.
+ * @param content The content of the blob the finding came from.
+ */
+func enrichCloudAccount(f *finding, content []byte) {
+	if m := awsKeyIDPattern.FindStringSubmatch(f.Match); m != nil {
+		setMetadata(f, "cloud_provider", "aws")
+		setMetadata(f, "aws_key_type", awsKeyTypes[m[1][:4]])
+		if account, err := awsAccountFromKeyID(m[1]); err == nil {
+			setMetadata(f, "cloud_account", account)
+		}
+		return
+	}
+	if gcpAPIKeyPattern.MatchString(f.Match) {
+		setMetadata(f, "cloud_provider", "gcp")
+		setMetadata(f, "gcp_key_type", "api_key")
+		return
+	}
+	if project, email, ok := gcpServiceAccount(content); ok {
+		setMetadata(f, "cloud_provider", "gcp")
+		setMetadata(f, "cloud_account", project)
+		setMetadata(f, "gcp_service_account", email)
+		return
+	}
+
+	// Azure identifiers usually sit on the matched line rather than in the match.
+	line := lineOf(content, f.Line)
+	for _, text := range []string{f.Match, line} {
+		if m := azureAccountPattern.FindStringSubmatch(text); m != nil {
+			setMetadata(f, "cloud_provider", "azure")
+			setMetadata(f, "cloud_account", m[1])
+			return
+		}
+		if m := azureBlobHostPattern.FindStringSubmatch(text); m != nil {
+			setMetadata(f, "cloud_provider", "azure")
+			setMetadata(f, "cloud_account", m[1])
+			return
+		}
+	}
+	if m := azureTenantPattern.FindSubmatch(content); m != nil {
+		setMetadata(f, "cloud_provider", "azure")
+		setMetadata(f, "azure_tenant_id", string(m[1]))
+	}
+}
+
+/**
+ * @brief Decodes the AWS account ID embedded in an access key ID.
+ * Characters 5-20 are base32; bits 1-40 of the decoded prefix (after
+ * dropping the lowest 7 bits) are the account number.
+ * @param keyID The 20-character key ID.
+ * @return The zero-padded 12-digit account ID and an error for undecodable keys.
+ */
+func awsAccountFromKeyID(keyID string) (string, error) {
+	if len(keyID) != 20 {
+		return "", fmt.Errorf("invalid key id length")
+	}
+	decoded, err := base32.StdEncoding.DecodeString(keyID[4:])
+	if err != nil || len(decoded) < 6 {
+		return "", fmt.Errorf("undecodable key id")
+	}
+	var buf [8]byte
+	copy(buf[2:], decoded[:6])
+	account := (binary.BigEndian.Uint64(buf[:]) & 0x7fffffffff80) >> 7
+	return fmt.Sprintf("%012d", account), nil
+}
+
+/**
+ * @brief Extracts the project and service account from a GCP key file.
+ * @param content The blob content.
+ * @return The project ID, the client email, and true if the content is a service account key.
+ */
+func gcpServiceAccount(content []byte) (string, string, bool) {
+	if !strings.Contains(string(content), "service_account") {
+		return "", "", false
+	}
+	var key struct {
+		Type        string `json:"type"`
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+	}
+	if json.Unmarshal(content, &key) != nil || key.Type != "service_account" {
+		return "", "", false
+	}
+	return key.ProjectID, key.ClientEmail, true
+}
+
+/**
+ * @brief Returns the 1-based line of content, or "" if out of range.
+ */
+func lineOf(content []byte, line int) string {
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return lines[line-1]
+}
+
+/**
+ * @brief Sets a metadata key on a finding, creating the map if needed.
+ */
+func setMetadata(f *finding, key, value string) {
+	if value == "" {
+		return
+	}
+	if f.Metadata == nil {
+		f.Metadata = make(map[string]string)
+	}
+	f.Metadata[key] = value
+}
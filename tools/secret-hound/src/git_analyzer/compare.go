@@ -0,0 +1,200 @@
+/**
+ * @file compare.go
+ * @brief Side-by-side comparison with another scanner (--compare-with).
+ *
+ * Teams adopting the analyzer next to, or instead of, Gitleaks or TruffleHog
+ * want to know what each tool finds that the other misses before they switch.
+ * With --compare-with the analyzer scans as usual and then runs the other
+ * tool, if it is installed, on the same repository and the same commit range
+ * (the --depth newest commits from HEAD), reads its report with the importer
+ * of `git_analyzer import`, and prints on stderr how many secrets both found
+ * and every secret only one of them found. Findings are aligned by location
+ * and value rather than by rule, since the tools name their rules
+ * differently: a secret counts as found by both when both report it in the
+ * same file, with equal values or one containing the other (one tool may
+ * report the whole token, the other only its secret part). The findings
+ * output is unchanged.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// comparableTools lists the values accepted by --compare-with.
+var comparableTools = []string{"gitleaks", "trufflehog"}
+
+/**
+ * @struct crossCheck
+ * @brief Collects the analyzer's findings for comparison with another tool (nil = off).
+ */
+type crossCheck struct {
+	tool string
+
+	mu     sync.Mutex
+	native []*finding
+}
+
+/**
+ * @brief Prepares a comparison with another scanner.
+ * @param tool The --compare-with value.
+ * @return The comparison and an error if the tool is unknown or not installed.
+ */
+func newCrossCheck(tool string) (*crossCheck, error) {
+	known := false
+	for _, t := range comparableTools {
+		known = known || t == tool
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown scanner %q (want %s)", tool, strings.Join(comparableTools, " or "))
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%s is not installed or not in PATH", tool)
+	}
+	return &crossCheck{tool: tool}, nil
+}
+
+/**
+ * @brief Records a finding written by the analyzer.
+ */
+func (c *crossCheck) record(f *finding) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.native = append(c.native, f)
+	c.mu.Unlock()
+}
+
+/**
+ * @brief Runs the other scanner on a repository and prints the comparison.
+ * @param repo The repository the analyzer scanned.
+ * @param depth The number of commits scanned from HEAD.
+ * @param tmpDir The directory for the other tool's report.
+ * @return An error if the other tool failed or its report could not be read.
+ */
+func (c *crossCheck) run(repo *repository, depth int, tmpDir string) error {
+	if c == nil {
+		return nil
+	}
+	source, err := filepath.Abs(orDefault(repo.gitDir, "."))
+	if err != nil {
+		return err
+	}
+	var other []*finding
+	switch c.tool {
+	case "gitleaks":
+		report, err := ioutil.TempFile(tmpDir, "secret-hound-gitleaks-*.json")
+		if err != nil {
+			return err
+		}
+		report.Close()
+		defer os.Remove(report.Name())
+		cmd := exec.Command("gitleaks", "detect", "--source", source, "--report-format", "json", "--report-path", report.Name(),
+			"--no-banner", "--exit-code", "0", "--log-opts", "--max-count="+strconv.Itoa(depth))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if _, err := runTracked(cmd); err != nil {
+			return fmt.Errorf("gitleaks: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		data, err := ioutil.ReadFile(report.Name())
+		if err != nil {
+			return err
+		}
+		if other, err = importGitleaks(data); err != nil {
+			return fmt.Errorf("gitleaks: %v", err)
+		}
+	case "trufflehog":
+		cmd := exec.Command("trufflehog", "git", "file://"+filepath.ToSlash(source), "--json", "--no-update", "--max-depth", strconv.Itoa(depth))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := runTracked(cmd)
+		if err != nil {
+			return fmt.Errorf("trufflehog: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		if other, err = importTrufflehog(output); err != nil {
+			return fmt.Errorf("trufflehog: %v", err)
+		}
+	}
+	c.mu.Lock()
+	native := c.native
+	c.mu.Unlock()
+	c.print(native, dedupeImported(other))
+	return nil
+}
+
+/**
+ * @brief Aligns the findings of both tools and prints the result on stderr.
+ */
+func (c *crossCheck) print(native, other []*finding) {
+	matched := make([]bool, len(other))
+	var onlyNative []*finding
+	both := 0
+	for _, n := range native {
+		found := false
+		for i, o := range other {
+			if n.OriginalPath == o.OriginalPath && sameSecret(n.Match, o.Match) {
+				found, matched[i] = true, true
+			}
+		}
+		if found {
+			both++
+		} else {
+			onlyNative = append(onlyNative, n)
+		}
+	}
+	var onlyOther []*finding
+	for i, o := range other {
+		if !matched[i] {
+			onlyOther = append(onlyOther, o)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Go analyzer: comparison with %s: %d finding(s) also found by %s, %d only by git_analyzer, %d only by %s\n",
+		c.tool, both, c.tool, len(onlyNative), len(onlyOther), c.tool)
+	printComparisonSide("git_analyzer", onlyNative)
+	printComparisonSide(c.tool, onlyOther)
+}
+
+// printComparisonSide lists the findings only one tool reported, by location.
+func printComparisonSide(tool string, findings []*finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].OriginalPath != findings[j].OriginalPath {
+			return findings[i].OriginalPath < findings[j].OriginalPath
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	for _, f := range findings {
+		rule := f.RuleID
+		if source := f.Metadata["source_rule"]; source != "" {
+			rule = source
+		}
+		commit := f.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		fmt.Fprintf(os.Stderr, "  only %s: %s:%d %s (commit %s)\n", tool, f.OriginalPath, f.Line, rule, commit)
+	}
+}
+
+// sameSecret reports whether two matches are the same secret, one possibly containing the other.
+func sameSecret(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return a != ""
+	}
+	if len(a) < 8 || len(b) < 8 {
+		return false
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
@@ -0,0 +1,339 @@
+/**
+ * @file config_detector.go
+ * @brief Detects secrets in structured configuration files.
+ *
+ * Supported formats are .env, JSON, YAML, INI (including .properties/.cfg) and
+ * TOML. Each parser walks the keys of the document and reports values stored
+ * under secret-like keys (password, token, private_key, ...) even when the value
+ * itself has low entropy, together with the full key path (e.g. "db.password").
+ * The YAML, INI and TOML parsers are intentionally lightweight line-based
+ * readers: they understand the key structure, not every corner of each spec.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/**
+ * @struct configDetector
+ * @brief The "config" detector.
+ */
+type configDetector struct{}
+
+func (configDetector) name() string { return "config" }
+
+/**
+ * @struct configValue
+ * @brief A scalar value found in a config file with its key path and line.
+ */
+type configValue struct {
+	keyPath string
+	value   string
+	line    int
+}
+
+func (configDetector) detect(filePath string, content []byte) []detection {
+	format := configFormat(filePath)
+	var values []configValue
+	switch format {
+	case "env":
+		values = parseEnvFile(content)
+	case "json":
+		values = parseJSONConfig(content)
+	case "yaml":
+		values = parseYAMLConfig(content)
+	case "ini":
+		values = parseINIConfig(content)
+	case "toml":
+		values = parseTOMLConfig(content)
+	default:
+		return nil
+	}
+
+	var found []detection
+	for _, v := range values {
+		key := v.keyPath[strings.LastIndexAny(v.keyPath, ".")+1:]
+		if !isSecretAssignment(key, v.value) {
+			continue
+		}
+		found = append(found, detection{
+			ruleID:      "CONFIG_SECRET_VALUE",
+			description: "Secret stored under '" + v.keyPath + "' in " + strings.ToUpper(format) + " config",
+			line:        v.line,
+			match:       v.value,
+			keyPath:     v.keyPath,
+			confidence:  "Medium",
+		})
+	}
+	return found
+}
+
+/**
+ * @brief Determines the config format of a file from its name.
+ * @param filePath The repository path.
+ * @return "env", "json", "yaml", "ini", "toml", or "" if not a config file.
+ */
+func configFormat(filePath string) string {
+	base := strings.ToLower(path.Base(filePath))
+	switch {
+	case base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env"):
+		return "env"
+	case strings.HasSuffix(base, ".json") && !strings.HasSuffix(base, "lock.json"):
+		return "json"
+	case strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml"):
+		return "yaml"
+	case strings.HasSuffix(base, ".ini") || strings.HasSuffix(base, ".cfg") ||
+		strings.HasSuffix(base, ".conf") || strings.HasSuffix(base, ".properties"):
+		return "ini"
+	case strings.HasSuffix(base, ".toml"):
+		return "toml"
+	}
+	return ""
+}
+
+/**
+ * @brief Removes matching surrounding quotes and a trailing inline comment.
+ * @param raw The raw value text.
+ * @return The cleaned value.
+ */
+func unquoteConfigValue(raw string) string {
+	v := strings.TrimSpace(raw)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			inner := v[1 : end+1]
+			if v[0] == '"' {
+				if unq, err := strconv.Unquote(`"` + inner + `"`); err == nil {
+					return unq
+				}
+			}
+			return inner
+		}
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+func parseEnvFile(content []byte) []configValue {
+	var values []configValue
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values = append(values, configValue{strings.TrimSpace(key), unquoteConfigValue(value), i + 1})
+	}
+	return values
+}
+
+func parseINIConfig(content []byte) []configValue {
+	var values []configValue
+	section := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+		if line[0] == '[' && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:sep])
+		if section != "" {
+			key = section + "." + key
+		}
+		values = append(values, configValue{key, unquoteConfigValue(line[sep+1:]), i + 1})
+	}
+	return values
+}
+
+func parseTOMLConfig(content []byte) []configValue {
+	var values []configValue
+	table := ""
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			table = strings.Trim(line, "[] ")
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if table != "" {
+			key = table + "." + key
+		}
+		values = append(values, configValue{key, unquoteConfigValue(value), i + 1})
+	}
+	return values
+}
+
+/**
+ * @brief Extracts scalar mappings from YAML using indentation to track nesting.
+ * Sequence items contribute their index to the key path ("users.0.password").
+ * Block scalars (| and >) are skipped.
+ */
+func parseYAMLConfig(content []byte) []configValue {
+	type level struct {
+		indent int
+		key    string
+	}
+	var values []configValue
+	var stack []level
+	seqIndex := make(map[string]int)
+	blockIndent := -1
+
+	for i, raw := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(raw)
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1].key
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			// A sequence item: push the index as a path segment.
+			idx := seqIndex[parent]
+			seqIndex[parent] = idx + 1
+			itemKey := joinKeyPath(parent, strconv.Itoa(idx))
+			stack = append(stack, level{indent, itemKey})
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			indent += 2
+			parent = itemKey
+			if trimmed == "" {
+				continue
+			}
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || strings.ContainsAny(key, "{[") {
+			continue
+		}
+		fullKey := joinKeyPath(parent, strings.Trim(strings.TrimSpace(key), `"'`))
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "" || strings.HasPrefix(value, "#"):
+			stack = append(stack, level{indent, fullKey})
+			seqIndex[fullKey] = 0
+		case value[0] == '|' || value[0] == '>':
+			blockIndent = indent
+		case value[0] == '&' || value[0] == '*':
+			// Anchors and aliases carry no literal value.
+		default:
+			values = append(values, configValue{fullKey, unquoteConfigValue(value), i + 1})
+		}
+	}
+	return values
+}
+
+/**
+ * @brief Walks a JSON document with a token decoder, tracking key paths and lines.
+ */
+func parseJSONConfig(content []byte) []configValue {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	var values []configValue
+
+	type frame struct {
+		isObject bool
+		path     string
+		index    int
+		key      string
+		expected bool // For objects: the next string token is a key
+	}
+	var stack []*frame
+
+	lineAt := func(offset int64) int {
+		return bytes.Count(content[:offset], []byte("\n")) + 1
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF || err != nil {
+			return values
+		}
+		offset := dec.InputOffset()
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if top != nil && top.isObject && top.expected {
+			if key, ok := tok.(string); ok {
+				top.key = key
+				top.expected = false
+				continue
+			}
+		}
+
+		currentPath := ""
+		if top != nil {
+			if top.isObject {
+				currentPath = joinKeyPath(top.path, top.key)
+			} else {
+				currentPath = joinKeyPath(top.path, strconv.Itoa(top.index))
+				top.index++
+			}
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, &frame{isObject: true, path: currentPath, expected: true})
+			case '[':
+				stack = append(stack, &frame{path: currentPath})
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 && stack[len(stack)-1].isObject {
+					stack[len(stack)-1].expected = true
+				}
+			}
+			continue
+		case string:
+			values = append(values, configValue{currentPath, t, lineAt(offset)})
+		}
+		if top != nil && top.isObject {
+			top.expected = true
+		}
+	}
+}
+
+func joinKeyPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
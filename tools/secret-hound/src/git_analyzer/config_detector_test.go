@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// detectedKeys runs the config detector and returns "keyPath@line" for each detection.
+func detectedKeys(path, content string) []string {
+	var keys []string
+	for _, d := range (configDetector{}).detect(path, []byte(content)) {
+		keys = append(keys, fmt.Sprintf("%s@%d", d.keyPath, d.line))
+	}
+	return keys
+}
+
+func TestConfigDetectorFormats(t *testing.T) {
+	for _, tc := range []struct {
+		path, content string
+		want          []string
+	}{
+		{".env.production", "# comment\nexport DB_PASSWORD=\"hunter2hunter2\"\nDB_HOST=db.internal\nAPI_TOKEN=${TOKEN}\n", []string{"DB_PASSWORD@2"}},
+		{"config/app.json", "{\n  \"db\": {\n    \"password\": \"s3cr3t-value\",\n    \"password_min_length\": \"12\"\n  }\n}\n", []string{"db.password@3"}},
+		{"deploy.yaml", "service:\n  name: api\n  auth:\n    clientSecret: 'abcd1234efgh'\n    enabled: true\n", []string{"service.auth.clientSecret@4"}},
+		{"settings.ini", "[database]\nhost = localhost\npassword = letmein123\n", []string{"database.password@3"}},
+		{"Cargo.toml", "[registry]\ntoken = \"crates-io-token-1\"\nurl = \"https://example.com\"\n", []string{"registry.token@2"}},
+		{"package-lock.json", "{\"password\": \"s3cr3t-value\"}", nil},
+		{"main.go", "password := \"s3cr3t-value\"", nil},
+	} {
+		if got := detectedKeys(tc.path, tc.content); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: detected %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestIsSecretAssignment(t *testing.T) {
+	for _, tc := range []struct {
+		key, value string
+		want       bool
+	}{
+		{"password", "correct-horse", true},
+		{"dbPassword", "correct-horse", true},
+		{"api_key", "AKIA1234567890", true},
+		{"password", "abc", false},                // Too short
+		{"password_policy", "strict-mode", false}, // Describes a secret
+		{"token_url", "https://auth.example", false},
+		{"secret", "{{ vault.secret }}", false}, // Reference
+		{"secret", "ENC[abcdef]", false},
+		{"password", "required", false},
+		{"username", "administrator", false},
+	} {
+		if got := isSecretAssignment(tc.key, tc.value); got != tc.want {
+			t.Errorf("isSecretAssignment(%q, %q) = %v, want %v", tc.key, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestSelectDetectors(t *testing.T) {
+	if all, err := selectDetectors("all"); err != nil || len(all) != len(builtinDetectors()) {
+		t.Errorf("all: %v, %v", all, err)
+	}
+	if none, err := selectDetectors("none"); err != nil || none != nil {
+		t.Errorf("none: %v, %v", none, err)
+	}
+	if picked, err := selectDetectors(" config "); err != nil || len(picked) != 1 || picked[0].name() != "config" {
+		t.Errorf("config: %v, %v", picked, err)
+	}
+	if _, err := selectDetectors("config,bogus"); err == nil {
+		t.Error("an unknown detector was accepted")
+	}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	if shannonEntropy("") != 0 || shannonEntropy("aaaa") != 0 {
+		t.Error("a constant string has no entropy")
+	}
+	if got := shannonEntropy("abcd"); got != 2 {
+		t.Errorf("entropy of four distinct bytes = %v, want 2", got)
+	}
+}
@@ -0,0 +1,89 @@
+/**
+ * @file context.go
+ * @brief Attaches the lines surrounding a finding, with the secret redacted.
+ *
+ * With --context N each finding carries up to N lines before and after the
+ * matched line, so triagers can tell a test fixture from a production config
+ * without opening the repository. The matched secret is replaced by
+ * redactionMarker in every context line, including the matched line itself.
+ */
+
+package main
+
+import "strings"
+
+const (
+	redactionMarker    = "[REDACTED]"
+	maxContextLineRune = 400 // Longer context lines are truncated (minified code)
+)
+
+/**
+ * @struct contextLine
+ * @brief One line of source around a finding.
+ */
+type contextLine struct {
+	Line int    `json:"line" proto:"1"`
+	Text string `json:"text" proto:"2"`
+}
+
+/**
+ * @brief Sets the context lines of a finding from the blob content.
+ * @param f The finding; its Line and Match must be set.
+ * @param content The content of the blob the finding was found in.
+ * @param n The number of lines to include on each side of the match.
+ */
+func attachContext(f *finding, content []byte, n int) {
+	if n <= 0 || f.Line < 1 {
+		return
+	}
+	lines := strings.Split(string(content), "\n")
+	if f.Line > len(lines) {
+		return
+	}
+	first, last := f.Line-n, f.Line+n
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	f.Context = nil
+	for number := first; number <= last; number++ {
+		text := redactSecret(strings.TrimRight(lines[number-1], "\r"), f)
+		f.Context = append(f.Context, contextLine{Line: number, Text: truncateRunes(text, maxContextLineRune)})
+	}
+}
+
+/**
+ * @brief Replaces every occurrence of a finding's secret in a line.
+ * Each line of a multi-line match (e.g. a PEM body) is redacted on its own;
+ * for a secret found inside a base64/hex payload the encoded run is redacted.
+ */
+func redactSecret(text string, f *finding) string {
+	for _, part := range strings.Split(f.Match, "\n") {
+		part = strings.TrimSpace(part)
+		if len(part) >= 4 {
+			text = strings.ReplaceAll(text, part, redactionMarker)
+		}
+	}
+	if f.Metadata["encoding"] != "" {
+		text = base64RunPattern.ReplaceAllStringFunc(text, func(run string) string {
+			if len(run) >= 16 {
+				return redactionMarker
+			}
+			return run
+		})
+	}
+	return text
+}
+
+/**
+ * @brief Shortens s to at most max runes, marking the cut with "…".
+ */
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max]) + "…"
+}
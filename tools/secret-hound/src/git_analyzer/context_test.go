@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttachContextRedactsTheSecret(t *testing.T) {
+	content := "# database\nhost: db\npassword: hunter2hunter2\nport: 5432\r\n"
+	f := &finding{Line: 3, Match: "hunter2hunter2"}
+	attachContext(f, []byte(content), 1)
+	want := []contextLine{{2, "host: db"}, {3, "password: [REDACTED]"}, {4, "port: 5432"}}
+	if !reflect.DeepEqual(f.Context, want) {
+		t.Errorf("context = %+v, want %+v", f.Context, want)
+	}
+
+	// The window is clipped at both ends of the file.
+	f = &finding{Line: 1, Match: "database"}
+	attachContext(f, []byte(content), 10)
+	if len(f.Context) != 5 || f.Context[0].Text != "# [REDACTED]" {
+		t.Errorf("clipped context = %+v", f.Context)
+	}
+
+	f = &finding{Line: 3, Match: "hunter2hunter2"}
+	attachContext(f, []byte(content), 0)
+	if f.Context != nil {
+		t.Error("context attached with --context 0")
+	}
+}
+
+func TestRedactSecretMultilineAndEncoded(t *testing.T) {
+	pemMatch := &finding{Match: "-----BEGIN KEY-----\nMIIBOgIBAAJBAK\n-----END KEY-----"}
+	if got := redactSecret("  MIIBOgIBAAJBAK", pemMatch); got != "  [REDACTED]" {
+		t.Errorf("PEM body line = %q", got)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("password=hunter2hunter2"))
+	decoded := &finding{Match: "hunter2hunter2", Metadata: map[string]string{"encoding": "base64"}}
+	if got := redactSecret("BUNDLE="+encoded, decoded); strings.Contains(got, encoded) {
+		t.Errorf("encoded payload left in context: %q", got)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	if got := truncateRunes("äöüß", 2); got != "äö…" {
+		t.Errorf("truncateRunes = %q", got)
+	}
+	if got := truncateRunes("short", 10); got != "short" {
+		t.Errorf("truncateRunes = %q", got)
+	}
+}
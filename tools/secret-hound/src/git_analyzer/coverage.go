@@ -0,0 +1,244 @@
+/**
+ * @file coverage.go
+ * @brief Per-repository record of what a scan covered (--coverage-report).
+ *
+ * "We scanned the repository" is a claim auditors want to check. With
+ * --coverage-report the analyzer writes a JSON artifact that states, for
+ * every repository, exactly what was walked and what was left out:
+ *   - the HEAD ref and commit, the walk (depth, first-parent, merges) or the
+ *     snapshot/release/image scope, and the commits requested and covered;
+ *   - the filters in effect: skipped commits (.hound-ignore-revs),
+ *     .gitattributes linguist filtering, --generated handling, the working
+ *     tree and a --shard split;
+ *   - every blob that was not scanned, with the reason: linguist (vendored
+ *     or generated per .gitattributes), generated (--generated skip) or
+ *     unreadable; blobs skipped as duplicates of already scanned content or
+ *     left to other shards are counted, not listed.
+ * The analyzer has no size or binary cut-off of its own: every blob not
+ * listed was read in full and handed to the rule engine. The report is
+ * written even when the scan fails or --time-budget cuts it short, with
+ * "complete": false.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * @struct skippedBlob
+ * @brief A blob left out of the scan and why.
+ */
+type skippedBlob struct {
+	Hash   string `json:"hash"`
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	Reason string `json:"reason"`
+}
+
+/**
+ * @struct repoCoverage
+ * @brief The coverage of one repository.
+ */
+type repoCoverage struct {
+	Repository       string                 `json:"repository"`
+	Ref              string                 `json:"ref,omitempty"`
+	Head             string                 `json:"head,omitempty"`
+	Walk             map[string]interface{} `json:"walk"`
+	CommitsRequested int                    `json:"commits_requested"`
+	CommitsCovered   int                    `json:"commits_covered"`
+	Shallow          bool                   `json:"shallow"`
+	Filters          map[string]interface{} `json:"filters"`
+	BlobsFound       int                    `json:"blobs_found"`
+	BlobsScanned     int                    `json:"blobs_scanned"`
+	SkippedCounts    map[string]int         `json:"skipped_counts"`
+	Skipped          []skippedBlob          `json:"skipped"`
+}
+
+/**
+ * @struct scanCoverage
+ * @brief Collects the coverage of every repository of a run (nil = off).
+ */
+type scanCoverage struct {
+	mu    sync.Mutex
+	path  string
+	repos []*repoCoverage
+	byKey map[*repository]*repoCoverage
+}
+
+func newScanCoverage(path string) *scanCoverage {
+	return &scanCoverage{path: path, byKey: make(map[*repository]*repoCoverage)}
+}
+
+/**
+ * @brief Starts the coverage record of a repository.
+ * @param repo The repository.
+ * @param history What part of the history was collected.
+ * @param walk How it was collected (rev, depth, scope, ...).
+ * @param filters The filters in effect.
+ * @param found The number of blobs collected before filtering.
+ * @return The record (nil if the report is off).
+ */
+func (c *scanCoverage) begin(repo *repository, history historyCoverage, walk, filters map[string]interface{}, found int) *repoCoverage {
+	if c == nil {
+		return nil
+	}
+	rc := &repoCoverage{
+		Repository:       orDefault(repo.label, orDefault(repo.gitDir, ".")),
+		Walk:             walk,
+		CommitsRequested: history.requested,
+		CommitsCovered:   history.available,
+		Shallow:          history.shallow,
+		Filters:          filters,
+		BlobsFound:       found,
+		SkippedCounts:    make(map[string]int),
+		Skipped:          []skippedBlob{},
+	}
+	if history.scope != "" {
+		rc.Walk["scope"] = history.scope
+	}
+	if out, err := repo.command("rev-parse", "-q", "--verify", "HEAD").Output(); err == nil {
+		rc.Head = strings.TrimSpace(string(out))
+	}
+	if out, err := repo.command("symbolic-ref", "-q", "HEAD").Output(); err == nil {
+		rc.Ref = strings.TrimSpace(string(out))
+	}
+	c.mu.Lock()
+	c.repos = append(c.repos, rc)
+	c.byKey[repo] = rc
+	c.mu.Unlock()
+	return rc
+}
+
+/**
+ * @brief Records a blob left out of the scan.
+ * @param blob The blob; its repository must have been started with begin.
+ * @param reason Why it was skipped.
+ */
+func (c *scanCoverage) skip(blob fileBlob, reason string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rc := c.byKey[blob.repo]; rc != nil {
+		rc.Skipped = append(rc.Skipped, skippedBlob{blob.hash, blob.path, blob.commit, reason})
+		rc.SkippedCounts[reason]++
+	}
+}
+
+/**
+ * @brief Records a number of blobs skipped without listing them.
+ */
+func (c *scanCoverage) skipCount(repo *repository, reason string, n int) {
+	if c == nil || n == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rc := c.byKey[repo]; rc != nil {
+		rc.SkippedCounts[reason] += n
+	}
+}
+
+/**
+ * @brief Records the number of blobs that went into the scan pipeline.
+ * Called after the pipeline ran, so the blobs it skipped (see skip) are subtracted.
+ */
+func (c *scanCoverage) scanned(repo *repository, n int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rc := c.byKey[repo]; rc != nil {
+		rc.BlobsScanned = n - rc.SkippedCounts["generated"] - rc.SkippedCounts["unreadable"]
+	}
+}
+
+/**
+ * @brief Writes the report.
+ * @param complete Whether the scan finished without an error.
+ * @return An error if the file could not be written.
+ */
+func (c *scanCoverage) save(complete bool) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rc := range c.repos {
+		sort.Slice(rc.Skipped, func(i, j int) bool {
+			if rc.Skipped[i].Reason != rc.Skipped[j].Reason {
+				return rc.Skipped[i].Reason < rc.Skipped[j].Reason
+			}
+			return rc.Skipped[i].Path < rc.Skipped[j].Path
+		})
+	}
+	report := struct {
+		GeneratedAt  string          `json:"generated_at"`
+		Analyzer     string          `json:"analyzer_version"`
+		Complete     bool            `json:"complete"`
+		Repositories []*repoCoverage `json:"repositories"`
+	}{time.Now().UTC().Format(time.RFC3339), analyzerVersion, complete, c.repos}
+	if report.Repositories == nil {
+		report.Repositories = []*repoCoverage{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(data, '\n'), 0o644)
+}
+
+/**
+ * @brief Describes how a repository's history is walked, for the coverage report.
+ */
+func coverageWalk(opts options) map[string]interface{} {
+	switch {
+	case opts.snapshot != "":
+		return map[string]interface{}{"snapshot": opts.snapshot}
+	case opts.release.to != "":
+		return map[string]interface{}{"release": opts.release.String()}
+	case opts.staged:
+		return map[string]interface{}{"staged": true}
+	}
+	return map[string]interface{}{
+		"rev":          strings.Join(historyRevs(opts), " "),
+		"unreachable":  opts.unreachable,
+		"max_count":    opts.depth,
+		"first_parent": opts.merges.firstParent,
+		"merge_diffs":  map[bool]string{true: "all parents", false: "first parent"}[opts.merges.allParents],
+	}
+}
+
+/**
+ * @brief Describes the filters applied to a repository, for the coverage report.
+ */
+func coverageFilters(opts options, repo *repository) map[string]interface{} {
+	ignored := make([]string, 0, len(repo.ignoreRevs))
+	for commit := range repo.ignoreRevs {
+		ignored = append(ignored, commit)
+	}
+	sort.Strings(ignored)
+	filters := map[string]interface{}{
+		"ignored_commits":     ignored,
+		"linguist_attributes": opts.linguist,
+		"generated":           opts.generated,
+		"not_generated":       []string(opts.notGenerated),
+		"include_worktree":    opts.includeWorktree,
+	}
+	if opts.notGenerated == nil {
+		filters["not_generated"] = []string{}
+	}
+	if opts.shard.count > 1 {
+		filters["shard"] = opts.shard.String()
+	}
+	return filters
+}
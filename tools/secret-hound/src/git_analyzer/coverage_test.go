@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoverageReport(t *testing.T) {
+	fx := newFixtureRepo(t)
+	head := fx.commit("first", map[string]string{"a": "1\n"})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git"), label: "app", ignoreRevs: map[string]bool{"c2": true, "c1": true}}
+	path := filepath.Join(t.TempDir(), "coverage.json")
+	c := newScanCoverage(path)
+
+	opts := options{depth: 50, linguist: true, shard: shardSpec{index: 2, count: 4}}
+	rc := c.begin(repo, historyCoverage{requested: 50, available: 12, shallow: true}, coverageWalk(opts), coverageFilters(opts, repo), 30)
+	if rc.Head != head || rc.Ref != "refs/heads/main" {
+		t.Errorf("head %q ref %q", rc.Head, rc.Ref)
+	}
+	c.skip(fileBlob{hash: "b2", path: "vendor/z.js", commit: "c3", repo: repo}, "linguist")
+	c.skip(fileBlob{hash: "b1", path: "gen/a.pb.go", commit: "c3", repo: repo}, "generated")
+	c.skip(fileBlob{hash: "b3", path: "vendor/a.js", commit: "c3", repo: repo}, "linguist")
+	c.skip(fileBlob{hash: "b4", path: "other", repo: &repository{}}, "generated") // Not begun: ignored
+	c.skipCount(repo, "duplicate", 10)
+	c.skipCount(repo, "shard", 0)
+	c.scanned(repo, 18)
+	if err := c.save(false); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct {
+		Complete     bool           `json:"complete"`
+		Repositories []repoCoverage `json:"repositories"`
+	}
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Complete || len(report.Repositories) != 1 {
+		t.Fatalf("report %s", data)
+	}
+	got := report.Repositories[0]
+	if got.BlobsFound != 30 || got.BlobsScanned != 17 || got.CommitsCovered != 12 || !got.Shallow {
+		t.Errorf("counts %+v", got)
+	}
+	if got.SkippedCounts["linguist"] != 2 || got.SkippedCounts["duplicate"] != 10 || got.SkippedCounts["generated"] != 1 {
+		t.Errorf("skipped counts %v", got.SkippedCounts)
+	}
+	if _, ok := got.SkippedCounts["shard"]; ok {
+		t.Error("an empty skip count was recorded")
+	}
+	if len(got.Skipped) != 3 || got.Skipped[0].Path != "gen/a.pb.go" || got.Skipped[1].Path != "vendor/a.js" {
+		t.Errorf("skipped blobs are not sorted by reason and path: %+v", got.Skipped)
+	}
+	if got.Walk["max_count"] != float64(50) || got.Walk["merge_diffs"] != "first parent" {
+		t.Errorf("walk %v", got.Walk)
+	}
+	if got.Filters["shard"] != "2/4" || len(got.Filters["ignored_commits"].([]interface{})) != 2 || got.Filters["not_generated"] == nil {
+		t.Errorf("filters %v", got.Filters)
+	}
+}
+
+func TestCoverageWalkScopes(t *testing.T) {
+	if walk := coverageWalk(options{snapshot: "v1.0"}); walk["snapshot"] != "v1.0" || walk["rev"] != nil {
+		t.Errorf("snapshot walk %v", walk)
+	}
+	if walk := coverageWalk(options{release: releaseRange{from: "v1", to: "v2"}}); walk["release"] == nil {
+		t.Errorf("release walk %v", walk)
+	}
+	var off *scanCoverage
+	if off.begin(&repository{}, historyCoverage{}, nil, nil, 0) != nil || off.save(true) != nil {
+		t.Error("a disabled report recorded something")
+	}
+}
+
+func TestEmptyCoverageReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "coverage.json")
+	if err := newScanCoverage(path).save(true); err != nil {
+		t.Fatal(err)
+	}
+	var report map[string]interface{}
+	data, _ := os.ReadFile(path)
+	json.Unmarshal(data, &report)
+	if report["complete"] != true || report["repositories"] == nil {
+		t.Errorf("report %s", data)
+	}
+}
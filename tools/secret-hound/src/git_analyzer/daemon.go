@@ -0,0 +1,394 @@
+/**
+ * @file daemon.go
+ * @brief Scheduled scanning without an external scheduler (`git_analyzer daemon`).
+ *
+ * Small setups should not need Kubernetes CronJobs or a CI schedule to rescan
+ * their repositories every night. The daemon subcommand stays up and runs
+ * scans on cron schedules:
+ *   git_analyzer daemon --schedule "0 2 * * *" -- --remotes-file repos.txt --engine native 500
+ * runs that scan every night at 02:00, and with --schedule-file each line
+ * registers one scan with its own schedule and arguments, added to the
+ * common ones after "--":
+ *   0 2 * * *     --remote https://github.com/acme/site
+ *   @hourly       --remote https://github.com/acme/payments --profile deep-audit
+ * (fields separated by whitespace, no quoting; # starts a comment).
+ *
+ * Every scan runs as a child process with exactly the arguments a one-off run
+ * would get, so findings stream to its configured sinks: stdout (passed on),
+ * --output, --state-url, --history-file, --secret-index and the rest. In the
+ * arguments, {time} is replaced with the start time of the run (UTC, e.g.
+ * 20261016T020000Z), so `--output reports/{time}.jsonl` keeps every run.
+ *
+ * Schedules have the five standard cron fields (minute, hour, day of month,
+ * month, day of week; *, lists, ranges, steps and three-letter names; a day
+ * matches if either day field does when both are restricted) or one of
+ * @yearly, @monthly, @weekly, @daily, @midnight and @hourly, in local time
+ * (set TZ to change it). A scan still running when it is due again is
+ * skipped, not stacked. On SIGINT or SIGTERM running scans are interrupted,
+ * which makes them flush what they found, and the daemon exits.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cronMacros are the schedule shorthands and their cron fields.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronNames are the names accepted in the month and day of week fields.
+var cronNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+/**
+ * @struct cronSchedule
+ * @brief A parsed cron expression: one bit per allowed value of each field.
+ */
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // The day field is *, so only the other one restricts
+}
+
+/**
+ * @brief Parses a five-field cron expression or a macro.
+ * @param expr The expression, e.g. "0 2 * * 1-5" or "@daily".
+ * @return The schedule and an error naming the invalid field.
+ */
+func parseCron(expr string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q: want 5 fields (minute hour day-of-month month day-of-week) or a macro such as @daily", expr)
+	}
+	s := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	bounds := []struct {
+		name     string
+		min, max int
+		into     *uint64
+	}{
+		{"minute", 0, 59, &s.minute},
+		{"hour", 0, 23, &s.hour},
+		{"day of month", 1, 31, &s.dom},
+		{"month", 1, 12, &s.month},
+		{"day of week", 0, 7, &s.dow},
+	}
+	for i, b := range bounds {
+		bits, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %v", b.name, fields[i], err)
+		}
+		*b.into = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is Sunday too
+	}
+	return s, nil
+}
+
+// parseCronField parses one comma-separated cron field into a bit set.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		span, stepText, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if span != "*" {
+			from, to, ranged := strings.Cut(span, "-")
+			var err error
+			if lo, err = cronValue(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if ranged {
+				if hi, err = cronValue(to); err != nil {
+					return 0, err
+				}
+			} else if stepped {
+				hi = max // "5/15" is 5-max/15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronValue parses a number or a month or weekday name.
+func cronValue(text string) (int, error) {
+	if v, ok := cronNames[strings.ToLower(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", text)
+	}
+	return v, nil
+}
+
+// matchesDay reports whether a day satisfies the day of month and day of week fields.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+/**
+ * @brief Returns the first time after t the schedule fires.
+ * @return The time, or the zero time if it never fires (e.g. February 30).
+ */
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+/**
+ * @struct scheduledScan
+ * @brief One registered scan: its schedule and the arguments it adds.
+ */
+type scheduledScan struct {
+	name     string // --schedule, or <file>:<line>
+	schedule *cronSchedule
+	args     []string
+
+	mu      sync.Mutex
+	running *exec.Cmd
+}
+
+/**
+ * @brief Reads the scans of a --schedule-file.
+ * @return The scans and an error naming the first invalid line.
+ */
+func loadScheduleFile(path string) ([]*scheduledScan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scans []*scheduledScan
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		n := 5
+		if strings.HasPrefix(fields[0], "@") {
+			n = 1
+		}
+		if len(fields) < n {
+			n = len(fields)
+		}
+		schedule, err := parseCron(strings.Join(fields[:n], " "))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		scans = append(scans, &scheduledScan{name: fmt.Sprintf("%s:%d", path, i+1), schedule: schedule, args: fields[n:]})
+	}
+	return scans, nil
+}
+
+/**
+ * @brief Implements `git_analyzer daemon`.
+ * @param args The arguments following "daemon".
+ * @return The process exit code.
+ */
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	schedule := fs.String("schedule", "", "Cron expression the scan runs on, e.g. \"0 2 * * *\" or @daily")
+	scheduleFile := fs.String("schedule-file", "", "File of scans, one per line: a cron expression followed by the scan's own arguments")
+	runNow := fs.Bool("run-now", false, "Also run every scan once at startup")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer daemon (--schedule <cron> | --schedule-file <file>) [--run-now] [-- scan arguments]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var scans []*scheduledScan
+	if *schedule != "" {
+		s, err := parseCron(*schedule)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --schedule: %v\n", err)
+			return 2
+		}
+		scans = append(scans, &scheduledScan{name: "--schedule", schedule: s})
+	}
+	if *scheduleFile != "" {
+		loaded, err := loadScheduleFile(*scheduleFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --schedule-file: %v\n", err)
+			return 2
+		}
+		scans = append(scans, loaded...)
+	}
+	if len(scans) == 0 {
+		fs.Usage()
+		return 2
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	d := &scanDaemon{self: self, common: fs.Args()}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, terminationSignals...)
+	if *runNow {
+		for _, s := range scans {
+			d.start(s, time.Now())
+		}
+	}
+	for {
+		now := time.Now()
+		var due time.Time
+		for _, s := range scans {
+			if next := s.schedule.next(now); !next.IsZero() && (due.IsZero() || next.Before(due)) {
+				due = next
+			}
+		}
+		if due.IsZero() {
+			fmt.Fprintln(os.Stderr, "Go analyzer: no schedule fires again; exiting")
+			d.wait()
+			return 0
+		}
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case sig := <-signals:
+			timer.Stop()
+			fmt.Fprintf(os.Stderr, "Go analyzer: %v: stopping scheduled scans\n", sig)
+			d.stop(scans)
+			d.wait()
+			return 0
+		case <-timer.C:
+		}
+		for _, s := range scans {
+			// Fire every scan due in the minute the timer was set for.
+			if s.schedule.next(due.Add(-time.Minute)).Equal(due) {
+				d.start(s, due)
+			}
+		}
+	}
+}
+
+/**
+ * @struct scanDaemon
+ * @brief Runs scheduled scans as child processes.
+ */
+type scanDaemon struct {
+	self   string   // The analyzer executable
+	common []string // Arguments of every scan (after "--")
+	wg     sync.WaitGroup
+}
+
+/**
+ * @brief Starts a scan unless its previous run is still going.
+ * @param s The scan.
+ * @param at The time it is run for, substituted for {time}.
+ */
+func (d *scanDaemon) start(s *scheduledScan, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s: previous scan still running; skipping the %s run\n", s.name, at.Format("2006-01-02 15:04"))
+		return
+	}
+	stamp := at.UTC().Format("20060102T150405Z")
+	var args []string
+	for _, arg := range append(append([]string{}, d.common...), s.args...) {
+		args = append(args, strings.ReplaceAll(arg, "{time}", stamp))
+	}
+	cmd := exec.Command(d.self, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s: %v\n", s.name, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: %s: scan started (pid %d)\n", s.name, cmd.Process.Pid)
+	s.running = cmd
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		started := time.Now()
+		err := cmd.Wait()
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+		status := "finished"
+		if err != nil {
+			status = err.Error() // e.g. exit status 3 when findings failed the policy
+		}
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s: scan %s after %s\n", s.name, status, time.Since(started).Round(time.Second))
+	}()
+}
+
+// stop interrupts every running scan (or kills it where interrupts cannot be sent).
+func (d *scanDaemon) stop(scans []*scheduledScan) {
+	for _, s := range scans {
+		s.mu.Lock()
+		if s.running != nil {
+			if err := s.running.Process.Signal(os.Interrupt); err != nil {
+				s.running.Process.Kill()
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// wait waits for every running scan to exit.
+func (d *scanDaemon) wait() {
+	d.wg.Wait()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	for _, c := range []struct{ expr, from, want string }{
+		{"0 2 * * *", "2026-10-16 01:59", "2026-10-16 02:00"},
+		{"0 2 * * *", "2026-10-16 02:00", "2026-10-17 02:00"},
+		{"*/15 * * * *", "2026-10-16 10:07", "2026-10-16 10:15"},
+		{"30 9 * * mon-fri", "2026-10-16 10:00", "2026-10-19 09:30"}, // Friday -> Monday
+		{"0 0 1,15 * 7", "2026-10-16 00:00", "2026-10-18 00:00"},     // Either day field
+		{"@monthly", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"0 12 29 feb *", "2026-03-01 00:00", "2028-02-29 12:00"},
+	} {
+		s, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		if got := s.next(at(c.from)); !got.Equal(at(c.want)) {
+			t.Errorf("%s after %s = %s, want %s", c.expr, c.from, got.Format("2006-01-02 15:04"), c.want)
+		}
+	}
+	s, _ := parseCron("0 0 30 2 *")
+	if got := s.next(at("2026-01-01 00:00")); !got.IsZero() {
+		t.Errorf("February 30 fires at %s", got)
+	}
+	for _, bad := range []string{"0 2 * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestLoadScheduleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules")
+	content := "# nightly sweeps\n0 2 * * * --remote https://example.com/acme/site.git\n\n@hourly --remote https://example.com/acme/api.git --profile ci\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scans, err := loadScheduleFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 2 || len(scans[0].args) != 2 || len(scans[1].args) != 4 || scans[1].name != path+":4" {
+		t.Fatalf("scans %+v", scans)
+	}
+	if err := os.WriteFile(path, []byte("0 2 * --remote x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadScheduleFile(path); err == nil {
+		t.Error("line with three cron fields accepted")
+	}
+}
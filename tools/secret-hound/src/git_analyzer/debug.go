@@ -0,0 +1,74 @@
+/**
+ * @file debug.go
+ * @brief Runtime diagnostics endpoint of long-running scans (--debug-addr).
+ *
+ * Sweeps of many repositories run for hours. With --debug-addr the analyzer
+ * serves net/http/pprof under /debug/pprof/ and expvar under /debug/vars, so
+ * operators can take a CPU or heap profile of a running scan, e.g.
+ *   go tool pprof http://localhost:6060/debug/pprof/profile?seconds=30
+ * without restarting it. Besides the standard memstats and cmdline, expvar
+ * exposes scan progress: repositories, blobs_scanned, bytes_scanned and
+ * findings counters, and the memory_in_flight, workers and workers_busy gauges.
+ * The endpoint has no authentication: bind it to localhost or a pod-internal
+ * address only.
+ */
+
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+)
+
+// Scan progress counters published under /debug/vars.
+var (
+	debugRepositories = expvar.NewInt("repositories")
+	debugBlobsScanned = expvar.NewInt("blobs_scanned")
+	debugBytesScanned = expvar.NewInt("bytes_scanned")
+	debugFindings     = expvar.NewInt("findings")
+)
+
+/**
+ * @brief Starts the diagnostics endpoint in the background.
+ * @param addr The listen address, e.g. "localhost:6060".
+ * @param a The analyzer whose gauges are published.
+ * @return An error if the address cannot be bound.
+ */
+func startDebugServer(addr string, a *analyzer) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	expvar.Publish("memory_in_flight", expvar.Func(func() interface{} {
+		a.budget.mu.Lock()
+		defer a.budget.mu.Unlock()
+		return a.budget.inUse
+	}))
+	expvar.Publish("workers_busy", expvar.Func(func() interface{} {
+		_, busy := a.sched.usage()
+		return busy
+	}))
+	expvar.Publish("workers", expvar.Func(func() interface{} {
+		capacity, _ := a.sched.usage()
+		return capacity
+	}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: --debug-addr: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Go analyzer: diagnostics on http://%s/debug/pprof/ and /debug/vars\n", listener.Addr())
+	return nil
+}
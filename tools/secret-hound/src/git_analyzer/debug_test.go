@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestDebugServerPublishesScanProgress(t *testing.T) {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := free.Addr().String()
+	free.Close()
+
+	a := &analyzer{opts: options{workers: 4}, budget: newMemoryBudget(1 << 20), sched: newScheduler(4)}
+	if err := startDebugServer(addr, a); err != nil {
+		t.Fatal(err)
+	}
+	if err := startDebugServer(addr, a); err == nil {
+		t.Error("a second server on the same address started")
+	}
+	debugFindings.Add(2)
+
+	resp, err := http.Get("http://" + addr + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var vars map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars["findings"].(float64) < 2 || vars["workers_busy"] != float64(0) || vars["memory_in_flight"] != float64(0) {
+		t.Errorf("findings %v, workers_busy %v, memory_in_flight %v", vars["findings"], vars["workers_busy"], vars["memory_in_flight"])
+	}
+
+	profile, err := http.Get("http://" + addr + "/debug/pprof/heap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.Body.Close()
+	if profile.StatusCode != http.StatusOK {
+		t.Errorf("heap profile: HTTP %d", profile.StatusCode)
+	}
+}
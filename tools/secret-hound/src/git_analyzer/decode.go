@@ -0,0 +1,75 @@
+/**
+ * @file decode.go
+ * @brief The `git_analyzer decode` subcommand.
+ *
+ * Converts proto or msgpack output produced with --output-format back into
+ * JSON Lines, so downstream tools and humans can inspect compact scan results.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+/**
+ * @brief Runs the decode subcommand.
+ * @param args The arguments following "decode".
+ * @return The process exit code.
+ */
+func runDecode(args []string) int {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	format := fs.String("format", "proto", "Input format: proto or msgpack")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer decode [--format proto|msgpack] [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var input io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+	reader := bufio.NewReader(input)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	encoder := json.NewEncoder(out)
+
+	for count := 1; ; count++ {
+		var record interface{}
+		var err error
+		switch *format {
+		case "proto":
+			var msg []byte
+			if msg, err = readDelimited(reader); err == nil {
+				f := &finding{}
+				err = unmarshalProto(msg, f)
+				record = f
+			}
+		case "msgpack":
+			record, err = readMsgpack(reader)
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *format)
+			return 1
+		}
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: record %d: %v\n", count, err)
+			return 1
+		}
+		encoder.Encode(record)
+	}
+}
@@ -0,0 +1,185 @@
+/**
+ * @file detectors.go
+ * @brief Native (in-Go) detectors that complement the core scanner.
+ *
+ * The core scanner matches rules line by line. Some secrets are better found by
+ * understanding the file: a value under a "password" key is a secret whatever
+ * its entropy. Detectors receive the whole blob and return detections, which
+ * are turned into findings with the same git context as core findings and go
+ * through the same profiles and output path.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/**
+ * @struct detection
+ * @brief A secret found by a native detector, before git context is attached.
+ */
+type detection struct {
+	ruleID      string
+	description string
+	line        int
+	match       string
+	keyPath     string // Dotted path of the key holding the value (structured formats)
+	confidence  string
+	severity    string            // "low", "medium", "high" or "critical" ("" = not assessed)
+	metadata    map[string]string // Detector-specific facts about the secret
+	category    string            // Finding category ("" = secret, "pii")
+}
+
+/**
+ * @interface detector
+ * @brief A native detector run on the full content of every scanned blob.
+ */
+type detector interface {
+	// name is the identifier used by --detectors.
+	name() string
+	// detect returns the secrets found in the content of the file at path.
+	detect(path string, content []byte) []detection
+}
+
+/**
+ * @brief Lists every built-in detector, in the order they are run.
+ * @return The detectors.
+ */
+func builtinDetectors() []detector {
+	return []detector{
+		configDetector{},
+		pemDetector{},
+		jwtDetector{},
+		dockerDetector{},
+		terraformDetector{},
+		ciDetector{},
+	}
+}
+
+/**
+ * @brief Selects detectors from a comma-separated --detectors value.
+ * @param spec "all", "none", or a comma-separated list of detector names.
+ * @return The selected detectors and an error naming any unknown detector.
+ */
+func selectDetectors(spec string) ([]detector, error) {
+	all := builtinDetectors()
+	switch {
+	case allDetectors(spec):
+		return all, nil
+	case strings.TrimSpace(spec) == "none":
+		return nil, nil
+	}
+
+	byName := make(map[string]detector, len(all))
+	var names []string
+	for _, d := range all {
+		byName[d.name()] = d
+		names = append(names, d.name())
+	}
+	sort.Strings(names)
+
+	var selected []detector
+	for _, name := range strings.Split(spec, ",") {
+		d, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q (available: %s)", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, d)
+	}
+	return selected, nil
+}
+
+// allDetectors reports whether a --detectors value selects every detector.
+func allDetectors(spec string) bool {
+	spec = strings.TrimSpace(spec)
+	return spec == "" || spec == "all"
+}
+
+/**
+ * @brief Converts a detection into a finding with the blob's git context.
+ * @param d The detection.
+ * @param blob The blob the detection was made in.
+ * @return The finding.
+ */
+func newDetectorFinding(d detection, blob fileBlob) *finding {
+	return &finding{
+		SchemaVersion: findingSchemaVersion,
+		Repository:    blob.repo.label,
+		Commit:        blob.commit,
+		OriginalPath:  blob.path,
+		File:          blob.path,
+		Line:          d.line,
+		RuleID:        d.ruleID,
+		Description:   d.description,
+		Match:         d.match,
+		Entropy:       shannonEntropy(d.match),
+		Confidence:    d.confidence,
+		KeyPath:       d.keyPath,
+		Severity:      d.severity,
+		Metadata:      d.metadata,
+		Category:      d.category,
+	}
+}
+
+/**
+ * @brief Calculates the Shannon entropy of a string in bits per byte.
+ * Mirrors Scanner::calculate_shannon_entropy in the core.
+ * @param s The string to analyze.
+ * @return The entropy (0 for an empty string).
+ */
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var freqs [256]int
+	for i := 0; i < len(s); i++ {
+		freqs[s[i]]++
+	}
+	entropy := 0.0
+	length := float64(len(s))
+	for _, count := range freqs {
+		if count > 0 {
+			p := float64(count) / length
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+var (
+	// secretKeyPattern matches key names whose values are credentials.
+	secretKeyPattern = regexp.MustCompile(`(?i)(^|[._-])(pass(word|wd|phrase)?|pwd|secret|token|api[_-]?key|apikey|access[_-]?key|private[_-]?key|client[_-]?secret|credentials?|auth[_-]?key)($|[._-])`)
+	// nonSecretKeySuffix matches keys that merely describe a secret (e.g. "password_min_length").
+	nonSecretKeySuffix = regexp.MustCompile(`(?i)[._-](url|uri|endpoint|path|file|length|len|min|max|policy|hint|field|name|type|expiry|expires|ttl|header|prefix|regex|pattern|enabled|required)$`)
+	// camelBoundary finds lower-to-upper transitions in camelCase keys.
+	camelBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	// referenceValue matches values that point to a secret instead of containing it.
+	referenceValue = regexp.MustCompile(`^(\$\{[^}]*\}|\$[A-Za-z_][A-Za-z0-9_]*|\{\{.*\}\}|%\([^)]*\)s|<[^>]*>|\(\(.*\)\)|env\(.*\)|ENC\[.*\])$`)
+)
+
+/**
+ * @brief Decides whether a key/value pair from a config file holds a secret.
+ * @param key The key name (last segment of the key path).
+ * @param value The unquoted value.
+ * @return True if the key is secret-like and the value looks like a literal.
+ */
+func isSecretAssignment(key, value string) bool {
+	value = strings.TrimSpace(value)
+	key = camelBoundary.ReplaceAllString(key, "${1}_${2}") // dbPassword -> db_Password
+	if len(value) < 4 || !secretKeyPattern.MatchString(key) || nonSecretKeySuffix.MatchString(key) {
+		return false
+	}
+	if referenceValue.MatchString(value) {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "null", "none", "nil", "yes", "no", "required", "optional":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,801 @@
+/**
+ * @file distributed.go
+ * @brief Scanning one repository with many analyzer instances (--coordinator / --worker).
+ *
+ * The history of a giant monorepo takes hours to scan on one machine. The
+ * work splits naturally at the blob: a coordinator walks the history and
+ * deduplicates blobs as usual, then publishes one work item per blob to a
+ * queue instead of scanning:
+ *   git_analyzer --coordinator redis://queue:6379/0?prefix=mono --engine native
+ * Any number of workers, each with its own clone of the same repository,
+ * consume the items, scan them and write findings to their own --output:
+ *   git_analyzer --worker redis://queue:6379/0?prefix=mono --output part.jsonl <core>
+ * Supported queues:
+ *   redis://[:password@]host[:port][/db][?prefix=name]   (rediss:// for TLS)
+ *       A list per run; each worker moves items to its own processing list
+ *       while it scans them and removes each one once its findings are
+ *       flushed. A worker holds a lease it renews while it runs; the items
+ *       of a worker whose lease expired go back on the queue when another
+ *       worker starts or runs out of work.
+ *   sqs://sqs.<region>.amazonaws.com/<account>/<queue>
+ *       Amazon SQS; unacknowledged items reappear after the visibility
+ *       timeout. AWS_ENDPOINT_URL selects a compatible endpoint (e.g. ElasticMQ).
+ * Delivery is at least once, so a blob can be scanned twice when a worker
+ * dies mid-batch. Findings are made exactly-once at the sink: before writing
+ * a finding a worker claims its fingerprint in a Redis set shared by all
+ * workers, and a finding another worker already claimed is dropped. A batch
+ * is acknowledged only once its findings are flushed to --output; claims of
+ * findings that could not be written are released so that the redelivered
+ * items write them. The
+ * claims and the coordinator's "all published" marker live in the Redis
+ * given by --coordination-store, which defaults to the queue for redis:// and
+ * is required with sqs://. A coordinator resets the run's claims when it
+ * starts, so a prefix (or queue) hosts one run at a time. Workers exit once
+ * the coordinator has finished and the queue is empty.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// distributedPoll is how long a worker waits for new items before checking
+// whether the coordinator has finished.
+const distributedPoll = 5 * time.Second
+
+// workerLease is how long a worker's processing list is safe from requeueing
+// without the worker renewing its lease (every workerLease/3).
+const workerLease = 60 * time.Second
+
+/**
+ * @struct workItem
+ * @brief One blob published by the coordinator.
+ */
+type workItem struct {
+	Hash   string `json:"hash"`
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
+	Mode   string `json:"mode,omitempty"`
+}
+
+/**
+ * @brief A queue of work items shared by a coordinator and its workers.
+ */
+type workQueue interface {
+	// publish appends items to the queue.
+	publish(items []workItem) error
+	// receive takes up to max items, waiting up to distributedPoll for the
+	// first. The returned function acknowledges them once they are scanned.
+	receive(max int) ([]workItem, func() error, error)
+	// close gives up the worker's share of the queue once it is done.
+	close() error
+}
+
+/**
+ * @struct distributed
+ * @brief The role of this instance in a distributed scan and its connections.
+ */
+type distributed struct {
+	coordinator bool
+	queueURL    string
+	queue       workQueue
+	store       *redisClient // Claims and the done marker
+
+	mu       sync.Mutex
+	claimed  []string // Fingerprints claimed since the last acknowledged batch
+	warnOnce sync.Once
+}
+
+/**
+ * @brief Connects to the queue and coordination store of a distributed scan.
+ * @param coordinator Whether this instance publishes (true) or scans (false).
+ * @param queueURL The queue URL (see the file comment).
+ * @param storeURL The Redis URL of the coordination store ("" = the queue's Redis).
+ * @return The connections, or an error for an unsupported or unreachable URL.
+ */
+func openDistributed(coordinator bool, queueURL, storeURL string) (*distributed, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return nil, err
+	}
+	d := &distributed{coordinator: coordinator, queueURL: queueURL}
+	switch u.Scheme {
+	case "redis", "rediss":
+		queue, err := dialRedis(u)
+		if err != nil {
+			return nil, err
+		}
+		d.queue = &redisQueue{client: queue, owner: workerID(), stop: make(chan struct{})}
+		if storeURL == "" {
+			storeURL = queueURL
+		}
+	case "sqs":
+		if d.queue, err = newSQSQueue(u); err != nil {
+			return nil, err
+		}
+		if storeURL == "" {
+			return nil, fmt.Errorf("an sqs:// queue needs a redis:// --coordination-store")
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported scheme %q (expected redis, rediss or sqs)", queueURL, u.Scheme)
+	}
+
+	su, err := url.Parse(storeURL)
+	if err == nil && su.Scheme != "redis" && su.Scheme != "rediss" {
+		err = fmt.Errorf("%s: the coordination store must be a redis:// URL", storeURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.store, err = dialRedis(su); err != nil {
+		return nil, err
+	}
+	if coordinator {
+		if _, err := d.store.do("DEL", d.store.key("seen"), d.store.key("done")); err != nil {
+			return nil, err
+		}
+	} else if q, ok := d.queue.(*redisQueue); ok {
+		if err := q.register(); err != nil {
+			return nil, err
+		}
+		if _, err := q.recover(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// workerID names this instance's processing list, unique even among workers on one host.
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+/**
+ * @brief Publishes a repository's blobs for the workers.
+ * @param blobs The deduplicated blobs of the walk.
+ * @return An error if the queue rejected them.
+ */
+func (d *distributed) publish(blobs []fileBlob) error {
+	const batch = 100
+	items := make([]workItem, 0, batch)
+	for i, blob := range blobs {
+		items = append(items, workItem{Hash: blob.hash, Path: blob.path, Commit: blob.commit, Mode: blob.mode})
+		if len(items) == batch || i == len(blobs)-1 {
+			if err := d.queue.publish(items); err != nil {
+				return err
+			}
+			items = items[:0]
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: published %d blob(s) to %s\n", len(blobs), d.queueURL)
+	return nil
+}
+
+/**
+ * @brief Tells the workers that everything has been published.
+ */
+func (d *distributed) finish() error {
+	_, err := d.store.do("SET", d.store.key("done"), "1")
+	return err
+}
+
+// claimKey returns the fingerprint a finding is claimed under.
+func claimKey(f *finding) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d\x00%s\x00%s", f.Commit, f.OriginalPath, f.Line, f.Column, f.RuleID, f.Match)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/**
+ * @brief Claims a finding for this worker's output.
+ * If the store cannot be reached the finding is kept: a duplicate is
+ * cheaper than a lost secret.
+ * @return False if another delivery of the same blob already wrote it.
+ */
+func (d *distributed) claim(f *finding) bool {
+	if d == nil {
+		return true
+	}
+	key := claimKey(f)
+	reply, err := d.store.do("SADD", d.store.key("seen"), key)
+	if err != nil {
+		d.warnOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "Go analyzer: coordination store: %v (findings may be duplicated)\n", err)
+		})
+		return true
+	}
+	if added, _ := reply.(int64); added != 1 {
+		return false
+	}
+	d.mu.Lock()
+	d.claimed = append(d.claimed, key)
+	d.mu.Unlock()
+	return true
+}
+
+/**
+ * @brief Gives up the claim of a finding that could not be written, so that
+ * a redelivery of its blob writes it.
+ */
+func (d *distributed) release(f *finding) {
+	if d != nil {
+		d.store.do("SREM", d.store.key("seen"), claimKey(f))
+	}
+}
+
+/**
+ * @brief Settles the claims of a batch: kept once its findings are flushed,
+ * released if they could not be.
+ * @param written Whether the batch's findings reached the output.
+ */
+func (d *distributed) settle(written bool) {
+	d.mu.Lock()
+	claimed := d.claimed
+	d.claimed = nil
+	d.mu.Unlock()
+	if written || len(claimed) == 0 {
+		return
+	}
+	d.store.do(append([]string{"SREM", d.store.key("seen")}, claimed...)...)
+}
+
+/**
+ * @brief Scans the blobs published for a repository until the coordinator is done.
+ * @param repo The worker's clone of the coordinator's repository.
+ * @return An error if the repository or the queue fails.
+ */
+func (a *analyzer) runWorker(repo *repository) error {
+	if _, err := repo.check(); err != nil {
+		return err
+	}
+	a.runlog.begin(repo)
+	a.incidents.begin(repo)
+	debugRepositories.Add(1)
+	numWorkers := a.opts.workers
+	if a.scaler != nil {
+		numWorkers = a.scaler.max
+	}
+	defer a.dist.queue.close()
+	scanned := 0
+	for {
+		items, ack, err := a.dist.queue.receive(4 * numWorkers)
+		if err != nil {
+			return fmt.Errorf("--worker: %v", err)
+		}
+		if len(items) == 0 {
+			done, err := a.dist.store.do("EXISTS", a.dist.store.key("done"))
+			if err != nil {
+				return fmt.Errorf("--coordination-store: %v", err)
+			}
+			if n, _ := done.(int64); n == 1 {
+				break
+			}
+			continue
+		}
+
+		blobs := make([]fileBlob, 0, len(items))
+		hashes := make([]string, 0, len(items))
+		for _, item := range items {
+			blobs = append(blobs, fileBlob{hash: item.Hash, path: item.Path, commit: item.Commit, mode: item.Mode, repo: repo})
+			hashes = append(hashes, item.Hash)
+		}
+		var blobSizes map[string]int64
+		if a.opts.maxMemory > 0 {
+			if blobSizes, err = getBlobSizes(repo, hashes); err != nil {
+				return fmt.Errorf("getting blob sizes: %v", err)
+			}
+		}
+		a.runPipeline(repo, blobs, blobSizes, numWorkers)
+		// Acknowledged items are not redelivered: their findings must be out first.
+		if err := findingsSink.flush(); err != nil {
+			a.dist.settle(false)
+			return fmt.Errorf("--worker: writing findings: %v", err)
+		}
+		a.dist.settle(true)
+		if err := ack(); err != nil {
+			return fmt.Errorf("--worker: acknowledging: %v", err)
+		}
+		scanned += len(items)
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: worker scanned %d blob(s) from %s\n", scanned, a.dist.queueURL)
+	return nil
+}
+
+/**
+ * @struct redisClient
+ * @brief A minimal RESP2 client: one connection, one command at a time.
+ */
+type redisClient struct {
+	addr     string
+	tls      bool
+	password string
+	db       string
+	prefix   string // Namespace of the run's keys
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+/**
+ * @brief Connects to the Redis server named by a redis:// or rediss:// URL.
+ */
+func dialRedis(u *url.URL) (*redisClient, error) {
+	c := &redisClient{addr: u.Host, tls: u.Scheme == "rediss", db: strings.Trim(u.Path, "/"), prefix: u.Query().Get("prefix")}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+	}
+	if c.prefix == "" {
+		c.prefix = "secret-hound"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.addr, err)
+	}
+	return c, nil
+}
+
+// key returns the name of one of the run's keys.
+func (c *redisClient) key(name string) string {
+	return c.prefix + ":" + name
+}
+
+// connect opens the connection, authenticates and selects the database. c.mu must be held.
+func (c *redisClient) connect() error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var err error
+	if c.tls {
+		c.conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, nil)
+	} else {
+		c.conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.r = bufio.NewReader(c.conn)
+	if c.password != "" {
+		if _, err := c.roundTrip("AUTH", c.password); err != nil {
+			return err
+		}
+	}
+	if c.db != "" && c.db != "0" {
+		if _, err := c.roundTrip("SELECT", c.db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * @brief Runs one command, reconnecting once if the connection was lost.
+ * @return The reply: string, int64, []byte (nil for a null bulk), or []interface{}.
+ */
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		reply, err := c.roundTrip(args...)
+		var serverErr redisError
+		if err == nil || errors.As(err, &serverErr) {
+			return reply, err
+		}
+		c.conn.Close()
+		c.conn = nil
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.roundTrip(args...)
+}
+
+// redisError is an error reply from the server (as opposed to a network error).
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// roundTrip writes a command and reads its reply. c.mu must be held.
+func (c *redisClient) roundTrip(args ...string) (interface{}, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply parses one RESP2 reply.
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []interface{}(nil), err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				var serverErr redisError
+				if !errors.As(err, &serverErr) {
+					return nil, err
+				}
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+/**
+ * @struct redisQueue
+ * @brief A work queue on a Redis list, with a processing list per worker.
+ */
+type redisQueue struct {
+	client *redisClient
+	owner  string        // Worker ID naming the processing list and lease
+	stop   chan struct{} // Closed to stop renewing the lease
+}
+
+func (q *redisQueue) processing() string {
+	return q.client.key("processing:" + q.owner)
+}
+
+// leaseKey returns the key that exists while a worker is alive.
+func (q *redisQueue) leaseKey(owner string) string {
+	return q.client.key("lease:" + owner)
+}
+
+// renew extends the worker's lease.
+func (q *redisQueue) renew() error {
+	_, err := q.client.do("SET", q.leaseKey(q.owner), "1", "EX", strconv.Itoa(int(workerLease/time.Second)))
+	return err
+}
+
+/**
+ * @brief Takes the lease of a worker and keeps renewing it until close.
+ * @return An error if the queue's Redis cannot be reached.
+ */
+func (q *redisQueue) register() error {
+	if err := q.renew(); err != nil {
+		return err
+	}
+	if _, err := q.client.do("SADD", q.client.key("workers"), q.owner); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(workerLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-ticker.C:
+				q.renew() // A missed renewal is retried on the next tick
+			}
+		}
+	}()
+	return nil
+}
+
+func (q *redisQueue) close() error {
+	select {
+	case <-q.stop:
+		return nil
+	default:
+	}
+	close(q.stop)
+	// Items of a batch that failed go back for the other workers.
+	if _, err := q.requeue(q.owner); err != nil {
+		return err
+	}
+	_, err := q.client.do("DEL", q.leaseKey(q.owner))
+	return err
+}
+
+func (q *redisQueue) publish(items []workItem) error {
+	args := []string{"LPUSH", q.client.key("queue")}
+	for _, item := range items {
+		data, _ := json.Marshal(item)
+		args = append(args, string(data))
+	}
+	_, err := q.client.do(args...)
+	return err
+}
+
+func (q *redisQueue) receive(max int) ([]workItem, func() error, error) {
+	var items []workItem
+	var raw []string
+	for len(items) < max {
+		var reply interface{}
+		var err error
+		if len(items) == 0 {
+			reply, err = q.client.do("BRPOPLPUSH", q.client.key("queue"), q.processing(), strconv.Itoa(int(distributedPoll/time.Second)))
+		} else {
+			reply, err = q.client.do("RPOPLPUSH", q.client.key("queue"), q.processing())
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, _ := reply.([]byte)
+		if data == nil {
+			if len(items) == 0 {
+				// Idle: take over the items of workers that died, if any.
+				if requeued, err := q.recover(); err != nil || requeued > 0 {
+					if err != nil {
+						return nil, nil, err
+					}
+					continue
+				}
+			}
+			break
+		}
+		var item workItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, nil, fmt.Errorf("malformed work item: %v", err)
+		}
+		items = append(items, item)
+		raw = append(raw, string(data))
+	}
+	// Only this batch's items leave the processing list.
+	ack := func() error {
+		for _, data := range raw {
+			if _, err := q.client.do("LREM", q.processing(), "1", data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return items, ack, nil
+}
+
+// requeue moves a worker's processing list back to the queue and forgets the worker.
+func (q *redisQueue) requeue(owner string) (int, error) {
+	requeued := 0
+	for {
+		reply, err := q.client.do("RPOPLPUSH", q.client.key("processing:"+owner), q.client.key("queue"))
+		if err != nil {
+			return requeued, err
+		}
+		if data, _ := reply.([]byte); data == nil {
+			break
+		}
+		requeued++
+	}
+	_, err := q.client.do("SREM", q.client.key("workers"), owner)
+	return requeued, err
+}
+
+/**
+ * @brief Puts the unfinished items of workers whose lease expired back on the queue.
+ * @return The number of items requeued.
+ */
+func (q *redisQueue) recover() (int, error) {
+	reply, err := q.client.do("SMEMBERS", q.client.key("workers"))
+	if err != nil {
+		return 0, err
+	}
+	members, _ := reply.([]interface{})
+	requeued := 0
+	for _, member := range members {
+		data, _ := member.([]byte)
+		owner := string(data)
+		if owner == q.owner {
+			continue
+		}
+		alive, err := q.client.do("EXISTS", q.leaseKey(owner))
+		if err != nil {
+			return requeued, err
+		}
+		if n, _ := alive.(int64); n == 1 {
+			continue
+		}
+		n, err := q.requeue(owner)
+		if requeued += n; err != nil {
+			return requeued, err
+		}
+	}
+	if requeued > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: requeued %d unfinished blob(s) of workers whose lease expired\n", requeued)
+	}
+	return requeued, nil
+}
+
+/**
+ * @struct sqsQueue
+ * @brief A work queue on Amazon SQS through its JSON protocol.
+ */
+type sqsQueue struct {
+	client   *http.Client
+	queueURL string // https:// URL of the queue
+	endpoint string // Where requests are sent
+	region   string
+	creds    awsCredentials
+}
+
+/**
+ * @brief Creates an SQS queue client from an sqs:// URL.
+ */
+func newSQSQueue(u *url.URL) (*sqsQueue, error) {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	q := &sqsQueue{client: &http.Client{Timeout: distributedPoll + 30*time.Second},
+		queueURL: "https://" + u.Host + u.Path, endpoint: "https://" + u.Host + "/",
+		region: awsRegionFromEnv("us-east-1"), creds: creds}
+	// sqs.<region>.amazonaws.com names its own region.
+	if parts := strings.Split(u.Hostname(), "."); len(parts) == 4 && parts[0] == "sqs" {
+		q.region = parts[1]
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		q.endpoint = strings.TrimRight(endpoint, "/") + "/"
+		q.queueURL = strings.TrimRight(endpoint, "/") + u.Path
+	}
+	return q, nil
+}
+
+// call invokes one SQS action.
+func (q *sqsQueue) call(action string, input, output interface{}) error {
+	body, _ := json.Marshal(input)
+	req, err := http.NewRequest("POST", q.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	if err := signAWSRequest(req, q.creds, q.region, "sqs", time.Now()); err != nil {
+		return err
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: HTTP %d: %s", action, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if output == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+// sqsBatchResult is the reply of the batch actions.
+type sqsBatchResult struct {
+	Failed []struct {
+		ID      string `json:"Id"`
+		Message string `json:"Message"`
+	} `json:"Failed"`
+}
+
+func (r *sqsBatchResult) err(action string) error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %d entries failed: %s", action, len(r.Failed), r.Failed[0].Message)
+}
+
+func (q *sqsQueue) publish(items []workItem) error {
+	type entry struct {
+		ID          string `json:"Id"`
+		MessageBody string `json:"MessageBody"`
+	}
+	for start := 0; start < len(items); start += 10 {
+		end := start + 10
+		if end > len(items) {
+			end = len(items)
+		}
+		entries := make([]entry, 0, end-start)
+		for i, item := range items[start:end] {
+			data, _ := json.Marshal(item)
+			entries = append(entries, entry{ID: strconv.Itoa(i), MessageBody: string(data)})
+		}
+		var result sqsBatchResult
+		if err := q.call("SendMessageBatch", map[string]interface{}{"QueueUrl": q.queueURL, "Entries": entries}, &result); err != nil {
+			return err
+		}
+		if err := result.err("SendMessageBatch"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *sqsQueue) receive(max int) ([]workItem, func() error, error) {
+	if max > 10 {
+		max = 10 // The SQS limit per request
+	}
+	var reply struct {
+		Messages []struct {
+			Body          string `json:"Body"`
+			ReceiptHandle string `json:"ReceiptHandle"`
+		} `json:"Messages"`
+	}
+	input := map[string]interface{}{"QueueUrl": q.queueURL, "MaxNumberOfMessages": max,
+		"WaitTimeSeconds": int(distributedPoll / time.Second)}
+	if err := q.call("ReceiveMessage", input, &reply); err != nil {
+		return nil, nil, err
+	}
+	items := make([]workItem, 0, len(reply.Messages))
+	type entry struct {
+		ID            string `json:"Id"`
+		ReceiptHandle string `json:"ReceiptHandle"`
+	}
+	receipts := make([]entry, 0, len(reply.Messages))
+	for i, msg := range reply.Messages {
+		var item workItem
+		if err := json.Unmarshal([]byte(msg.Body), &item); err != nil {
+			return nil, nil, fmt.Errorf("malformed work item: %v", err)
+		}
+		items = append(items, item)
+		receipts = append(receipts, entry{ID: strconv.Itoa(i), ReceiptHandle: msg.ReceiptHandle})
+	}
+	ack := func() error {
+		if len(receipts) == 0 {
+			return nil
+		}
+		var result sqsBatchResult
+		if err := q.call("DeleteMessageBatch", map[string]interface{}{"QueueUrl": q.queueURL, "Entries": receipts}, &result); err != nil {
+			return err
+		}
+		return result.err("DeleteMessageBatch")
+	}
+	return items, ack, nil
+}
+
+func (q *sqsQueue) close() error { return nil }
@@ -0,0 +1,274 @@
+/**
+ * @file docker_detector.go
+ * @brief Detects credentials hard-coded in Dockerfiles and compose files.
+ *
+ * An ENV value is baked into every container of the image and an ARG default
+ * is recorded in the image history, so a credential in either ships with the
+ * image even when the Dockerfile is later fixed. The detector parses the
+ * instructions of Dockerfiles (Dockerfile, Dockerfile.*, *.dockerfile,
+ * Containerfile), the environment and build args of compose services, and
+ * `docker build --build-arg NAME=value` invocations in any file (scripts,
+ * Makefiles, CI configs). Values under secret-like names are reported with
+ * their own rule ids, so they can be triaged and overridden apart from the
+ * generic config and pattern rules; references such as $TOKEN are not.
+ */
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// buildArgPattern matches `--build-arg NAME=value` on a docker build command line.
+var buildArgPattern = regexp.MustCompile(`--build-arg[= ]+([A-Za-z_][A-Za-z0-9_]*)=("[^"\n]*"|'[^'\n]*'|[^\s"'\\]+)`)
+
+/**
+ * @struct dockerDetector
+ * @brief The "docker" detector.
+ */
+type dockerDetector struct{}
+
+func (dockerDetector) name() string { return "docker" }
+
+func (dockerDetector) detect(filePath string, content []byte) []detection {
+	var found []detection
+	switch dockerFileKind(filePath) {
+	case "dockerfile":
+		found = detectDockerfile(content)
+	case "compose":
+		found = detectCompose(content)
+	}
+	if !bytes.Contains(content, []byte("--build-arg")) {
+		return found
+	}
+	for _, m := range buildArgPattern.FindAllSubmatchIndex(content, -1) {
+		name, value := string(content[m[2]:m[3]]), unquoteConfigValue(string(content[m[4]:m[5]]))
+		if !isSecretAssignment(name, value) {
+			continue
+		}
+		found = append(found, detection{
+			ruleID:      "DOCKER_BUILD_ARG_SECRET",
+			description: "Secret passed as build arg '" + name + "' on a docker build command line",
+			line:        bytes.Count(content[:m[0]], []byte("\n")) + 1,
+			match:       value,
+			keyPath:     name,
+			confidence:  "Medium",
+			severity:    "medium",
+		})
+	}
+	return found
+}
+
+/**
+ * @brief Determines whether a file is a Dockerfile or a compose file from its name.
+ * @return "dockerfile", "compose", or "".
+ */
+func dockerFileKind(filePath string) string {
+	base := strings.ToLower(path.Base(filePath))
+	switch {
+	case base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") ||
+		base == "containerfile" || strings.HasPrefix(base, "containerfile."):
+		return "dockerfile"
+	case (strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose")) &&
+		(strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")):
+		return "compose"
+	}
+	return ""
+}
+
+/**
+ * @brief Reports ENV values and ARG defaults under secret-like names.
+ * Continuation lines are joined; a finding points at the instruction's first
+ * line and names the build stage it belongs to.
+ */
+func detectDockerfile(content []byte) []detection {
+	var found []detection
+	stage := ""
+	lines := strings.Split(string(content), "\n")
+	for i := 0; i < len(lines); i++ {
+		start := i
+		instruction := strings.TrimSpace(lines[i])
+		if instruction == "" || instruction[0] == '#' {
+			continue
+		}
+		for strings.HasSuffix(instruction, "\\") && i+1 < len(lines) {
+			i++
+			next := strings.TrimSpace(lines[i])
+			if strings.HasPrefix(next, "#") {
+				continue // Comments inside a continued instruction are dropped
+			}
+			instruction = strings.TrimSuffix(instruction, "\\") + " " + next
+		}
+		keyword, args, _ := strings.Cut(instruction, " ")
+		keyword = strings.ToUpper(keyword)
+		words := splitDockerWords(args)
+
+		var pairs [][2]string
+		switch keyword {
+		case "FROM":
+			stage = "" // An unnamed stage
+			for j, w := range words {
+				if strings.EqualFold(w, "AS") && j+1 < len(words) {
+					stage = words[j+1]
+				}
+			}
+		case "ARG":
+			for _, w := range words {
+				if name, value, ok := strings.Cut(w, "="); ok {
+					pairs = append(pairs, [2]string{name, value})
+				}
+			}
+		case "ENV":
+			if len(words) > 0 && !strings.Contains(words[0], "=") {
+				// Legacy form: ENV NAME the rest of the line is the value
+				_, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+				pairs = append(pairs, [2]string{words[0], unquoteConfigValue(value)})
+				break
+			}
+			for _, w := range words {
+				if name, value, ok := strings.Cut(w, "="); ok {
+					pairs = append(pairs, [2]string{name, value})
+				}
+			}
+		}
+
+		for _, p := range pairs {
+			if !isSecretAssignment(p[0], p[1]) {
+				continue
+			}
+			d := detection{
+				ruleID:      "DOCKERFILE_ENV_SECRET",
+				description: "Secret hard-coded in Dockerfile ENV '" + p[0] + "'",
+				line:        start + 1,
+				match:       p[1],
+				keyPath:     p[0],
+				confidence:  "Medium",
+				severity:    "high",
+				metadata:    map[string]string{"instruction": keyword},
+			}
+			if keyword == "ARG" {
+				d.ruleID = "DOCKERFILE_ARG_SECRET"
+				d.description = "Secret hard-coded as Dockerfile ARG '" + p[0] + "' default"
+				d.severity = "medium"
+			}
+			if stage != "" {
+				d.metadata["stage"] = stage
+			}
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+/**
+ * @brief Splits instruction arguments into words, honoring quotes and escapes.
+ * Quotes are removed, so `A="x y"` is the single word `A=x y`.
+ */
+func splitDockerWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != '\'' && c == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case quote != 0:
+			word.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+/**
+ * @brief Reports secret-like entries of compose services' environment and build args.
+ * Both the mapping form (NAME: value) and the list form (- NAME=value) are read.
+ */
+func detectCompose(content []byte) []detection {
+	type level struct {
+		indent int
+		key    string
+	}
+	var found []detection
+	var stack []level
+	for i, raw := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		// Only entries directly under services.<name>.environment or services.<name>.build.args count.
+		var section, service string
+		switch {
+		case len(stack) == 3 && stack[0].key == "services" && stack[2].key == "environment":
+			section, service = "environment", stack[1].key
+		case len(stack) == 4 && stack[0].key == "services" && stack[2].key == "build" && stack[3].key == "args":
+			section, service = "args", stack[1].key
+		}
+
+		var name, value string
+		if item := strings.TrimPrefix(trimmed, "- "); item != trimmed {
+			var ok bool
+			if name, value, ok = strings.Cut(unquoteConfigValue(item), "="); !ok {
+				continue
+			}
+		} else {
+			key, rest, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				continue
+			}
+			name, value = strings.Trim(strings.TrimSpace(key), `"'`), strings.TrimSpace(rest)
+			if value == "" || value[0] == '#' {
+				stack = append(stack, level{indent, name})
+				continue
+			}
+			value = unquoteConfigValue(value)
+		}
+		if section == "" || !isSecretAssignment(name, value) {
+			continue
+		}
+		d := detection{
+			ruleID:      "COMPOSE_ENV_SECRET",
+			description: "Secret hard-coded in the environment of compose service '" + service + "'",
+			line:        i + 1,
+			match:       value,
+			keyPath:     "services." + service + "." + section + "." + name,
+			confidence:  "Medium",
+			severity:    "medium",
+			metadata:    map[string]string{"service": service},
+		}
+		if section == "args" {
+			d.ruleID = "COMPOSE_BUILD_ARG_SECRET"
+			d.description = "Secret hard-coded as build arg of compose service '" + service + "'"
+			d.keyPath = "services." + service + ".build.args." + name
+		}
+		found = append(found, d)
+	}
+	return found
+}
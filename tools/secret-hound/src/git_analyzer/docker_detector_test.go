@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestDockerFileKind(t *testing.T) {
+	for path, want := range map[string]string{
+		"Dockerfile": "dockerfile", "build/Dockerfile.prod": "dockerfile", "api.dockerfile": "dockerfile",
+		"Containerfile": "dockerfile", "docker-compose.yml": "compose", "deploy/compose.prod.yaml": "compose",
+		"compose.json": "", "Makefile": "",
+	} {
+		if got := dockerFileKind(path); got != want {
+			t.Errorf("dockerFileKind(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestDockerfileDetections(t *testing.T) {
+	dockerfile := `FROM golang:1.22 AS build
+ARG GITHUB_TOKEN=ghp_s3cr3tT0k3nValue
+ARG VERSION=1.0
+# ENV DB_PASSWORD=commented
+ENV API_KEY="k3y with spaces" \
+    # a comment inside the instruction
+    DB_PASSWORD=hunter2hunter2
+ENV LEGACY_SECRET the whole rest
+ENV FROM_ENV=$API_SECRET
+FROM alpine
+ENV AUTH_TOKEN=abcd1234abcd
+`
+	found := dockerDetector{}.detect("Dockerfile", []byte(dockerfile))
+	want := []struct {
+		rule, key, match, stage string
+		line                    int
+	}{
+		{"DOCKERFILE_ARG_SECRET", "GITHUB_TOKEN", "ghp_s3cr3tT0k3nValue", "build", 2},
+		{"DOCKERFILE_ENV_SECRET", "API_KEY", "k3y with spaces", "build", 5},
+		{"DOCKERFILE_ENV_SECRET", "DB_PASSWORD", "hunter2hunter2", "build", 5},
+		{"DOCKERFILE_ENV_SECRET", "LEGACY_SECRET", "the whole rest", "build", 8},
+		{"DOCKERFILE_ENV_SECRET", "AUTH_TOKEN", "abcd1234abcd", "", 11},
+	}
+	if len(found) != len(want) {
+		t.Fatalf("%d detections, want %d: %+v", len(found), len(want), found)
+	}
+	for i, w := range want {
+		d := found[i]
+		if d.ruleID != w.rule || d.keyPath != w.key || d.match != w.match || d.metadata["stage"] != w.stage || d.line != w.line {
+			t.Errorf("detection %d = %s %s %q stage %q line %d, want %+v", i, d.ruleID, d.keyPath, d.match, d.metadata["stage"], d.line, w)
+		}
+	}
+}
+
+func TestComposeDetections(t *testing.T) {
+	compose := `services:
+  api:
+    environment:
+      DB_PASSWORD: "hunter2hunter2"
+      LOG_LEVEL: debug
+      API_TOKEN: ${API_TOKEN}
+    build:
+      context: .
+      args:
+        - NPM_TOKEN=npm_s3cr3tValue
+  worker:
+    environment:
+      - SECRET_KEY=w0rk3rS3cr3t
+x-defaults:
+  environment:
+    PASSWORD: notInAService
+`
+	found := dockerDetector{}.detect("docker-compose.yml", []byte(compose))
+	want := map[string]string{
+		"services.api.environment.DB_PASSWORD":   "COMPOSE_ENV_SECRET",
+		"services.api.build.args.NPM_TOKEN":      "COMPOSE_BUILD_ARG_SECRET",
+		"services.worker.environment.SECRET_KEY": "COMPOSE_ENV_SECRET",
+	}
+	if len(found) != len(want) {
+		t.Fatalf("%d detections, want %d: %+v", len(found), len(want), found)
+	}
+	for _, d := range found {
+		if want[d.keyPath] != d.ruleID {
+			t.Errorf("unexpected %s at %s", d.ruleID, d.keyPath)
+		}
+	}
+}
+
+func TestBuildArgDetections(t *testing.T) {
+	script := "docker build --build-arg VERSION=1 \\\n  --build-arg NPM_TOKEN='npm_s3cr3tValue' --build-arg REF=$TOKEN .\n"
+	found := dockerDetector{}.detect("ci/build.sh", []byte(script))
+	if len(found) != 1 || found[0].ruleID != "DOCKER_BUILD_ARG_SECRET" || found[0].match != "npm_s3cr3tValue" || found[0].line != 2 {
+		t.Errorf("build arg detections %+v", found)
+	}
+}
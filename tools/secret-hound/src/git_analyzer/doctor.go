@@ -0,0 +1,287 @@
+/**
+ * @file doctor.go
+ * @brief The doctor subcommand: checks the environment a scan depends on.
+ *
+ * `git_analyzer doctor` runs the same preconditions a scan relies on — git,
+ * the core scanner handshake, the repository, the temporary directory and
+ * the commit cache — and prints one line per check with a hint on how to
+ * fix a failure. It exits with 1 if any check failed, so CI jobs can run it
+ * as a first step and fail with a readable reason instead of a scan error.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minGitVersion is the oldest git providing every plumbing command the
+// analyzer uses (rev-parse --is-shallow-repository needs 2.15).
+var minGitVersion = [2]int{2, 15}
+
+/**
+ * @struct doctorReport
+ * @brief Collects and prints the outcome of the doctor checks.
+ */
+type doctorReport struct {
+	out    io.Writer
+	failed int
+}
+
+/**
+ * @brief Records a passed check.
+ */
+func (r *doctorReport) ok(check, format string, args ...interface{}) {
+	fmt.Fprintf(r.out, "[ OK ] %-12s %s\n", check, fmt.Sprintf(format, args...))
+}
+
+/**
+ * @brief Records a check that passed with a caveat.
+ */
+func (r *doctorReport) warn(check, detail, hint string) {
+	fmt.Fprintf(r.out, "[WARN] %-12s %s\n", check, detail)
+	fmt.Fprintf(r.out, "       %-12s -> %s\n", "", hint)
+}
+
+/**
+ * @brief Records a failed check with a hint on how to fix it.
+ */
+func (r *doctorReport) fail(check, detail, hint string) {
+	r.failed++
+	fmt.Fprintf(r.out, "[FAIL] %-12s %s\n", check, detail)
+	fmt.Fprintf(r.out, "       %-12s -> %s\n", "", hint)
+}
+
+/**
+ * @brief Runs the doctor subcommand.
+ * @param args The arguments following "doctor".
+ * @return The process exit code.
+ */
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	gitDir := fs.String("git-dir", "", "Repository to check (default: discover from the current directory)")
+	engine := fs.String("engine", "core", "Rule engine that will be used: core or native")
+	rulesPath := fs.String("rules", "", "Rules file that will be passed to the scan")
+	cachePath := fs.String("commit-cache", "", "Commit cache file that will be passed to the scan")
+	tmpDir := fs.String("tmp-dir", "", "Temporary directory that will be passed to the scan")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer doctor [options] [path_to_hound_core]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	r := &doctorReport{out: os.Stdout}
+	gitOK := checkGit(r)
+
+	var core *coreInfo
+	if *engine == "native" {
+		r.ok("core", "not needed with --engine native")
+	} else if fs.NArg() == 0 {
+		r.fail("core", "no core scanner path given",
+			"pass the hound-core executable, e.g. `git_analyzer doctor bin/hound-core`, or use --engine native")
+	} else {
+		core = checkCore(r, fs.Arg(0))
+	}
+	checkRules(r, *engine, *rulesPath, fs.Arg(0), core)
+
+	if gitOK {
+		checkRepository(r, &repository{gitDir: *gitDir})
+	}
+	checkTempDir(r, *tmpDir)
+	checkCommitCache(r, *cachePath)
+
+	if r.failed > 0 {
+		fmt.Fprintf(r.out, "%d check(s) failed\n", r.failed)
+		return 1
+	}
+	fmt.Fprintln(r.out, "All checks passed")
+	return 0
+}
+
+/**
+ * @brief Checks that git is installed and recent enough.
+ * @return Whether git can be used for the remaining checks.
+ */
+func checkGit(r *doctorReport) bool {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		r.fail("git", "git not found in PATH", "install git or add it to PATH")
+		return false
+	}
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		r.fail("git", fmt.Sprintf("%s --version failed: %v", path, err), "check the git installation")
+		return false
+	}
+	version := strings.TrimSpace(string(output))
+	match := regexp.MustCompile(`(\d+)\.(\d+)`).FindStringSubmatch(version)
+	if match == nil {
+		r.warn("git", fmt.Sprintf("unrecognized version %q", version), "make sure git is a standard git build")
+		return true
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	if major < minGitVersion[0] || (major == minGitVersion[0] && minor < minGitVersion[1]) {
+		r.fail("git", fmt.Sprintf("%s is too old", version),
+			fmt.Sprintf("upgrade to git %d.%d or newer", minGitVersion[0], minGitVersion[1]))
+		return true
+	}
+	r.ok("git", "%s (%s)", version, path)
+	return true
+}
+
+/**
+ * @brief Checks that the core scanner runs and speaks a compatible protocol.
+ * @return The handshake result, or nil if the check failed.
+ */
+func checkCore(r *doctorReport, path string) *coreInfo {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		r.fail("core", err.Error(), "build it with `make` or pass the correct path")
+		return nil
+	case info.IsDir() || info.Mode()&0111 == 0:
+		r.fail("core", path+" is not an executable file", "pass the hound-core binary, not its directory")
+		return nil
+	}
+	core, err := handshakeCore(path)
+	if err != nil {
+		r.fail("core", err.Error(), "rebuild hound-core and git_analyzer from the same checkout")
+		return nil
+	}
+	r.ok("core", "%s %s, protocol %d, capabilities: %s",
+		core.Name, core.Version, core.Protocol, strings.Join(core.Capabilities, ", "))
+	if !core.has("stdin") {
+		r.warn("core", "core cannot read stdin; every blob is staged in a temporary file",
+			"upgrade hound-core to 1.1 or newer")
+	}
+	return core
+}
+
+/**
+ * @brief Checks that the rules the scan would use load and compile.
+ */
+func checkRules(r *doctorReport, engine, rulesPath, corePath string, core *coreInfo) {
+	var set *ruleSet
+	var err error
+	switch {
+	case rulesPath != "":
+		set, err = loadRuleSet(rulesPath)
+		if err == nil && core != nil && !core.has("rules") {
+			r.fail("rules", "the core scanner does not accept --rules", "upgrade hound-core or drop --rules")
+			return
+		}
+	case engine == "core" && corePath != "":
+		set, err = loadRuleSet(defaultRulesPath(corePath))
+		if os.IsNotExist(err) {
+			set, err = embeddedRuleSet()
+		}
+	default:
+		set, err = embeddedRuleSet()
+	}
+	if err != nil {
+		r.fail("rules", err.Error(), "fix the JSON or point --rules at a valid rules file")
+		return
+	}
+	if engine == "native" {
+		if skipped := len(set.Rules) - len(newNativeEngine(set).rules); skipped > 0 {
+			r.warn("rules", fmt.Sprintf("%d of %d rules cannot be compiled by the native engine", skipped, len(set.Rules)),
+				"use --engine core for those rules")
+			return
+		}
+	}
+	r.ok("rules", "%d rules, %d profiles from %s", len(set.Rules), len(set.Profiles), set.path)
+}
+
+/**
+ * @brief Checks that the repository is valid and has history to scan.
+ */
+func checkRepository(r *doctorReport, repo *repository) {
+	bare, err := repo.check()
+	if err != nil {
+		r.fail("repository", err.Error(), "run inside a clone or pass --git-dir")
+		return
+	}
+	if err := repo.command("rev-parse", "--verify", "--quiet", "HEAD^{commit}").Run(); err != nil {
+		r.warn("repository", "HEAD does not point to a commit (empty repository?)", "commit something or check out a branch")
+		return
+	}
+	kind := "working tree"
+	if bare {
+		kind = "bare"
+	}
+	if isShallowRepository(repo) {
+		r.warn("repository", kind+", shallow clone", "scans are truncated; fetch full history or use --auto-deepen")
+		return
+	}
+	r.ok("repository", "%s, HEAD %s", kind, strings.TrimSpace(outputOf(repo.command("rev-parse", "--short", "HEAD"))))
+}
+
+/**
+ * @brief Checks that temporary files can be created.
+ */
+func checkTempDir(r *doctorReport, dir string) {
+	resolved, err := resolveTempDir(dir)
+	if err != nil {
+		r.fail("temp dir", err.Error(), "pass a writable --tmp-dir or set TMPDIR")
+		return
+	}
+	matches, _ := filepath.Glob(filepath.Join(resolved, tempFilePattern))
+	if len(matches) > 0 {
+		r.warn("temp dir", fmt.Sprintf("%s is writable; %d secret-hound-git-* file(s) present", resolved, len(matches)),
+			"leftovers of crashed runs are removed by the next scan after an hour")
+		return
+	}
+	r.ok("temp dir", "%s is writable", resolved)
+}
+
+/**
+ * @brief Checks that the commit cache file, if any, is readable and current.
+ */
+func checkCommitCache(r *doctorReport, path string) {
+	if path == "" {
+		r.ok("state", "no --commit-cache given")
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		r.ok("state", "%s does not exist yet; it is created by the first scan", path)
+		return
+	}
+	if err != nil {
+		r.fail("state", err.Error(), "fix the file permissions")
+		return
+	}
+	var file commitCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		r.fail("state", fmt.Sprintf("%s is corrupt: %v", path, err), "delete the file; it is rebuilt by the next scan")
+		return
+	}
+	if file.Format != commitCacheFormat {
+		r.warn("state", fmt.Sprintf("%s has format %d, expected %d; it will be discarded", path, file.Format, commitCacheFormat),
+			"nothing to do; the next scan rewrites it")
+		return
+	}
+	r.ok("state", "%s: %d commits cached", path, len(file.Commits))
+}
+
+/**
+ * @brief Returns a command's standard output, or "" if it failed.
+ */
+func outputOf(cmd *exec.Cmd) string {
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return string(output)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// doctorOutput runs one doctor check and returns its output and failure count.
+func doctorOutput(check func(r *doctorReport)) (string, int) {
+	var out bytes.Buffer
+	r := &doctorReport{out: &out}
+	check(r)
+	return out.String(), r.failed
+}
+
+func TestDoctorCommitCacheCheck(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.json")
+	os.WriteFile(current, []byte(fmt.Sprintf(`{"format": %d, "commits": {"a": [], "b": []}}`, commitCacheFormat)), 0o600)
+	old := filepath.Join(dir, "old.json")
+	os.WriteFile(old, []byte(`{"format": 1, "commits": {}}`), 0o600)
+	corrupt := filepath.Join(dir, "corrupt.json")
+	os.WriteFile(corrupt, []byte(`{"format":`), 0o600)
+
+	for _, tc := range []struct {
+		path, prefix string
+		failed       int
+	}{
+		{"", "[ OK ]", 0},
+		{filepath.Join(dir, "missing.json"), "[ OK ]", 0},
+		{current, "[ OK ]", 0},
+		{old, "[WARN]", 0},
+		{corrupt, "[FAIL]", 1},
+	} {
+		out, failed := doctorOutput(func(r *doctorReport) { checkCommitCache(r, tc.path) })
+		if !strings.HasPrefix(out, tc.prefix) || failed != tc.failed {
+			t.Errorf("%s: %d failed\n%s", tc.path, failed, out)
+		}
+	}
+	if out, _ := doctorOutput(func(r *doctorReport) { checkCommitCache(r, current) }); !strings.Contains(out, "2 commits cached") {
+		t.Errorf("current cache:\n%s", out)
+	}
+}
+
+func TestDoctorRepositoryCheck(t *testing.T) {
+	fx := newFixtureRepo(t)
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRepository(r, repo) }); !strings.HasPrefix(out, "[WARN]") || failed != 0 {
+		t.Errorf("empty repository:\n%s", out)
+	}
+	fx.commit("first", map[string]string{"a": "a"})
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRepository(r, repo) }); !strings.HasPrefix(out, "[ OK ]") || failed != 0 {
+		t.Errorf("repository:\n%s", out)
+	}
+	missing := &repository{gitDir: filepath.Join(t.TempDir(), "nope")}
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRepository(r, missing) }); failed != 1 {
+		t.Errorf("not a repository:\n%s", out)
+	}
+}
+
+func TestDoctorCoreAndRulesChecks(t *testing.T) {
+	legacy := fakeCore(t, `exit 1`)
+	var core *coreInfo
+	out, failed := doctorOutput(func(r *doctorReport) { core = checkCore(r, legacy) })
+	if failed != 0 || core == nil || !strings.Contains(out, "[WARN]") {
+		t.Errorf("legacy core:\n%s", out)
+	}
+	if out, failed := doctorOutput(func(r *doctorReport) { checkCore(r, t.TempDir()) }); failed != 1 {
+		t.Errorf("directory as core:\n%s", out)
+	}
+
+	rules := writeRules(t, `[{"id": "OK", "regex": "\\d+"}, {"id": "LOOKBEHIND", "regex": "(?<=a)b"}]`)
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRules(r, "native", rules, "", nil) }); failed != 0 || !strings.Contains(out, "1 of 2 rules") {
+		t.Errorf("native engine rules:\n%s", out)
+	}
+	noRules := &coreInfo{Capabilities: []string{"scan-file"}}
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRules(r, "core", rules, legacy, noRules) }); failed != 1 {
+		t.Errorf("--rules with a core that does not accept it:\n%s", out)
+	}
+	if out, failed := doctorOutput(func(r *doctorReport) { checkRules(r, "native", "", "", nil) }); failed != 0 || !strings.Contains(out, embeddedRulesPath) {
+		t.Errorf("built-in rules:\n%s", out)
+	}
+}
@@ -0,0 +1,343 @@
+/**
+ * @file email.go
+ * @brief The summary report sent by email after a run (--email-to).
+ *
+ * Teams without a chat-ops integration still want to hear about a nightly
+ * sweep without reading its output. With --email-to, the findings of the run
+ * are collected like those of the pretty format and, when the run ends, sent
+ * through the SMTP server of --smtp-server to every recipient: as HTML with
+ * the pretty report as its plain-text alternative (--email-format html), or
+ * as the plain-text report alone (text). Secrets are masked the way the
+ * pretty format masks them. By default mail is only sent when something was
+ * found; --email-on always also reports clean runs.
+ *
+ * --smtp-tls selects how the connection is protected: starttls (the
+ * default, usually port 587) upgrades a plain connection and refuses servers
+ * that do not offer it, tls connects with implicit TLS (port 465), and none
+ * sends in the clear, for a relay on the same host. Credentials come from
+ * SMTP_USERNAME and SMTP_PASSWORD (PLAIN authentication, never sent without
+ * TLS except to localhost). A run that fails, stops early (--time-budget) or
+ * is scoped to part of the history (see scanScope) is not reported, and a
+ * failed delivery is reported on stderr without failing the run.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+// Values of --smtp-tls.
+const (
+	smtpStartTLS = "starttls"
+	smtpTLS      = "tls"
+	smtpNoTLS    = "none"
+)
+
+// smtpTimeout bounds connecting to the SMTP server.
+const smtpTimeout = 30 * time.Second
+
+/**
+ * @struct emailReport
+ * @brief Findings collected for the email sent at the end of the run.
+ */
+type emailReport struct {
+	report *prettyReport // Uncolored, rendered as the text body
+	from   string
+	to     []string
+	server string // host:port
+	tls    string
+	html   bool // Send HTML with a text alternative
+	always bool // Also send when nothing was found
+
+	tlsConfig *tls.Config // Overrides the system roots (tests)
+}
+
+/**
+ * @brief Validates the email options.
+ * @param opts The parsed options.
+ * @return The report (nil without --email-to), and an error for an invalid option.
+ */
+func newEmailReport(opts *options) (*emailReport, error) {
+	if len(opts.emailTo) == 0 {
+		return nil, nil
+	}
+	m := &emailReport{report: &prettyReport{}, from: opts.emailFrom, server: opts.smtpServer, tls: opts.smtpTLS}
+	for _, address := range opts.emailTo {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("--email-to %q: %v", address, err)
+		}
+		m.to = append(m.to, parsed.Address)
+	}
+	if _, err := mail.ParseAddress(m.from); err != nil {
+		return nil, fmt.Errorf("--email-from %q: %v", m.from, err)
+	}
+	if _, _, err := net.SplitHostPort(m.server); err != nil {
+		return nil, fmt.Errorf("--smtp-server %q: want host:port", m.server)
+	}
+	switch m.tls {
+	case smtpStartTLS, smtpTLS, smtpNoTLS:
+	default:
+		return nil, fmt.Errorf("--smtp-tls: unknown mode %q (want starttls, tls or none)", m.tls)
+	}
+	switch opts.emailFormat {
+	case "html":
+		m.html = true
+	case "text":
+	default:
+		return nil, fmt.Errorf("--email-format: unknown format %q (want html or text)", opts.emailFormat)
+	}
+	switch opts.emailOn {
+	case "always":
+		m.always = true
+	case "findings":
+	default:
+		return nil, fmt.Errorf("--email-on: unknown condition %q (want findings or always)", opts.emailOn)
+	}
+	return m, nil
+}
+
+/**
+ * @brief Keeps a finding for the email.
+ */
+func (m *emailReport) record(f *finding) {
+	if m != nil {
+		m.report.add(f)
+	}
+}
+
+/**
+ * @brief Sends the report at the end of a successful run of the whole history.
+ * @return An error if the message could not be delivered.
+ */
+func (m *emailReport) send() error {
+	if m == nil {
+		return nil
+	}
+	m.report.mu.Lock()
+	count := len(m.report.findings)
+	m.report.mu.Unlock()
+	if count == 0 && !m.always {
+		return nil
+	}
+	subject := messages.text("email.subject", "findings", messages.text("pretty.summary.findings", "count", count))
+	message, err := m.compose(subject)
+	if err != nil {
+		return err
+	}
+	if err := m.deliver(message); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: emailed the report to %s\n", strings.Join(m.to, ", "))
+	return nil
+}
+
+/**
+ * @brief Builds the MIME message.
+ * @param subject The subject, before encoding.
+ * @return The message with its headers, CRLF line endings throughout.
+ */
+func (m *emailReport) compose(subject string) ([]byte, error) {
+	var text bytes.Buffer
+	if err := m.report.render(&text); err != nil {
+		return nil, err
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", m.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(m.to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	if !m.html {
+		message.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		err := writeQuotedPrintable(&message, text.Bytes())
+		return message.Bytes(), err
+	}
+
+	var html, body bytes.Buffer
+	if err := m.renderHTML(&html); err != nil {
+		return nil, err
+	}
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{{"text/plain; charset=utf-8", text.Bytes()}, {"text/html; charset=utf-8", html.Bytes()}} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}, "Content-Transfer-Encoding": {"quoted-printable"}})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// writeQuotedPrintable writes a body in quoted-printable encoding.
+func writeQuotedPrintable(w io.Writer, body []byte) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// emailHTML is the HTML body: the pretty report's grouping as tables.
+var emailHTML = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; color: #222">
+<h2>{{.Title}}</h2>
+<p><strong>{{.Summary}}</strong></p>
+{{- range .Commits}}
+<h3 style="margin-bottom: 4px">{{.Header}}{{if .Author}} <span style="color: #777; font-weight: normal">{{.Author}}</span>{{end}}</h3>
+<table style="border-collapse: collapse; margin-bottom: 16px">
+{{- range .Findings}}
+<tr>
+<td style="padding: 2px 8px"><code>{{.Path}}:{{.Position}}</code></td>
+<td style="padding: 2px 8px; color: {{.Color}}; font-weight: bold">{{.Severity}}</td>
+<td style="padding: 2px 8px">{{.RuleID}}</td>
+<td style="padding: 2px 8px"><code>{{.Match}}</code></td>
+</tr>
+{{- end}}
+</table>
+{{- end}}
+</body></html>
+`))
+
+// severityHTMLColors are the colors of each severity in the HTML report.
+var severityHTMLColors = map[string]string{
+	"critical": "#b00020",
+	"high":     "#d32f2f",
+	"medium":   "#b26a00",
+	"low":      "#1565c0",
+	"info":     "#777777",
+}
+
+/**
+ * @brief Writes the HTML body of the email.
+ */
+func (m *emailReport) renderHTML(w io.Writer) error {
+	type row struct {
+		Path, Position, Severity, Color, RuleID, Match string
+	}
+	type commit struct {
+		Header, Author string
+		Findings       []row
+	}
+	r := m.report
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	commits, counts, files := r.group()
+	var summary strings.Builder
+	if len(commits) == 0 {
+		summary.WriteString(messages.text("pretty.no_findings"))
+	} else {
+		r.renderSummary(&summary, counts, files, len(commits))
+	}
+	data := struct {
+		Title, Summary string
+		Commits        []commit
+	}{Title: messages.text("report.title"), Summary: strings.TrimSpace(summary.String())}
+	for _, group := range commits {
+		c := commit{Header: messages.text("pretty.commit", "commit", shortCommit(group.commit)),
+			Author: group.files[group.paths[0]][0].Metadata["author"]}
+		if group.repository != "" {
+			c.Header = group.repository + " " + c.Header
+		}
+		for _, path := range group.paths {
+			for _, f := range group.files[path] {
+				position := fmt.Sprintf("%d", f.Line)
+				if f.Column > 0 {
+					position = fmt.Sprintf("%d:%d", f.Line, f.Column)
+				}
+				severity := findingSeverity(f)
+				c.Findings = append(c.Findings, row{Path: path, Position: position, Severity: strings.ToUpper(messages.text("severity." + severity)),
+					Color: severityHTMLColors[severity], RuleID: f.RuleID, Match: maskSecret(f.Match)})
+			}
+		}
+		data.Commits = append(data.Commits, c)
+	}
+	return emailHTML.Execute(w, data)
+}
+
+/**
+ * @brief Connects to the SMTP server and sends a message to every recipient.
+ */
+func (m *emailReport) deliver(message []byte) error {
+	host, _, _ := net.SplitHostPort(m.server)
+	config := &tls.Config{ServerName: host}
+	if m.tlsConfig != nil {
+		config = m.tlsConfig.Clone()
+		config.ServerName = host
+	}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if m.tls == smtpTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", m.server, config)
+	} else {
+		conn, err = dialer.Dial("tcp", m.server)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if m.tls == smtpStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not offer STARTTLS (use --smtp-tls tls or none)", m.server)
+		}
+		if err := client.StartTLS(config); err != nil {
+			return err
+		}
+	}
+	if user := os.Getenv("SMTP_USERNAME"); user != "" {
+		if err := client.Auth(smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("%s: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,27 @@
+/**
+ * @file embed.go
+ * @brief The default rule set compiled into the analyzer binary.
+ *
+ * default_rules.json is a copy of rules/default.json kept in sync by the
+ * Makefile. It is used whenever no rules file is given and none is found next
+ * to the core scanner, so that a lone `git_analyzer` binary (together with
+ * --engine native) can run a complete scan on a machine that only has git.
+ */
+
+package main
+
+import _ "embed"
+
+//go:embed default_rules.json
+var embeddedRules []byte
+
+// embeddedRulesPath is reported in place of a file name for the built-in rules.
+const embeddedRulesPath = "(built-in)"
+
+/**
+ * @brief Parses the built-in default rule set.
+ * @return The rule set; the embedded file is validated by the build.
+ */
+func embeddedRuleSet() (*ruleSet, error) {
+	return parseRuleSet(embeddedRules, embeddedRulesPath)
+}
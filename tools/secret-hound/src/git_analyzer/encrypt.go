@@ -0,0 +1,118 @@
+/**
+ * @file encrypt.go
+ * @brief Encryption of the findings file to a recipient's public key (--encrypt-to).
+ *
+ * A findings report points at live secrets and is itself sensitive. With
+ * --encrypt-to the --output file is encrypted as it is written, so plaintext
+ * never reaches the disk. The recipient file decides the tool: an age
+ * recipient (age1... or an SSH public key) is handled by `age`, an OpenPGP
+ * public key by `gpg`. Like zstd, both are delegated to their command-line
+ * tools, which must be installed. Compression, if requested, is applied
+ * before encryption.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Encryption schemes selected from the recipient file.
+const (
+	encryptAge = "age"
+	encryptPGP = "pgp"
+)
+
+// encryptionExtensions maps each scheme to the extension added to --output.
+var encryptionExtensions = map[string]string{
+	encryptAge: ".age",
+	encryptPGP: ".gpg",
+}
+
+/**
+ * @brief Determines the encryption scheme from a recipient file.
+ * @param path The --encrypt-to file.
+ * @return "age" or "pgp", and an error if the file is unreadable or unrecognized.
+ */
+func detectEncryptionScheme(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(text, "-----BEGIN PGP PUBLIC KEY BLOCK-----"):
+		return encryptPGP, nil
+	case len(data) > 0 && data[0]&0x80 != 0:
+		return encryptPGP, nil // Binary OpenPGP packets always have the high bit set
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "age1") || strings.HasPrefix(line, "ssh-") {
+			return encryptAge, nil
+		}
+		break
+	}
+	return "", fmt.Errorf("%s is neither an age recipient nor an OpenPGP public key", path)
+}
+
+/**
+ * @brief Builds the command that encrypts stdin to stdout for the recipient.
+ * @param scheme The scheme from detectEncryptionScheme.
+ * @param recipientFile The --encrypt-to file.
+ * @return The prepared command, or an error if the tool is not installed.
+ */
+func encryptionCommand(scheme, recipientFile string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	switch scheme {
+	case encryptAge:
+		if _, err := exec.LookPath("age"); err != nil {
+			return nil, fmt.Errorf("an age recipient requires the age command-line tool")
+		}
+		cmd = exec.Command("age", "--encrypt", "-R", recipientFile)
+	default:
+		if _, err := exec.LookPath("gpg"); err != nil {
+			return nil, fmt.Errorf("an OpenPGP key requires the gpg command-line tool")
+		}
+		cmd = exec.Command("gpg", "--batch", "--quiet", "--yes", "--trust-model", "always",
+			"--recipient-file", recipientFile, "--encrypt", "--output", "-")
+	}
+	cmd.Stderr = os.Stderr
+	return cmd, nil
+}
+
+/**
+ * @brief Adds the scheme's extension to the output path if it is missing.
+ */
+func encryptedPath(path, scheme string) string {
+	if ext := encryptionExtensions[scheme]; !strings.HasSuffix(path, ext) {
+		return path + ext
+	}
+	return path
+}
+
+/**
+ * @brief Checks that the encryption tool accepts the recipient before any scanning.
+ * The tool encrypts an empty input; a bad key fails here rather than at exit.
+ */
+func verifyRecipient(scheme, recipientFile string) error {
+	cmd, err := encryptionCommand(scheme, recipientFile)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(nil)
+	cmd.Stdout = nil
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s rejected %s: %s", cmd.Args[0], recipientFile, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
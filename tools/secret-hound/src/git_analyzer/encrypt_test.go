@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEncryptionScheme(t *testing.T) {
+	dir := t.TempDir()
+	for content, want := range map[string]string{
+		"# created: 2026-01-01\nage1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p\n": encryptAge,
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIE user@host\n":                                      encryptAge,
+		"-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmQINBF\n-----END PGP PUBLIC KEY BLOCK-----\n":    encryptPGP,
+		"\x99\x01\x0d\x04":                      encryptPGP,
+		"not a key\nage1ql3z7hjy54pw3hyww5ay\n": "",
+	} {
+		path := filepath.Join(dir, "recipient")
+		os.WriteFile(path, []byte(content), 0o600)
+		got, err := detectEncryptionScheme(path)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("%q: %q, %v, want %q", content, got, err, want)
+		}
+	}
+	if _, err := detectEncryptionScheme(filepath.Join(dir, "missing")); err == nil {
+		t.Error("a missing recipient file was accepted")
+	}
+}
+
+func TestEncryptedPath(t *testing.T) {
+	for _, tc := range []struct{ path, scheme, want string }{
+		{"out.jsonl", encryptAge, "out.jsonl.age"},
+		{"out.jsonl.gz", encryptPGP, "out.jsonl.gz.gpg"},
+		{"out.jsonl.age", encryptAge, "out.jsonl.age"},
+	} {
+		if got := encryptedPath(tc.path, tc.scheme); got != tc.want {
+			t.Errorf("encryptedPath(%q, %q) = %q, want %q", tc.path, tc.scheme, got, tc.want)
+		}
+	}
+}
+
+func TestSinkCompressesBeforeEncrypting(t *testing.T) {
+	// The fake encryptor marks its output so the order of the stages shows.
+	encryptor := fakeCore(t, `printf 'ENCRYPTED\n'; cat`)
+	path := filepath.Join(t.TempDir(), "findings.jsonl.gz.age")
+	sink, err := openFileSink(path, "gzip", exec.Command(encryptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ := newFindingWriter(sink, "json")
+	for _, f := range sampleFindings() {
+		fw.write(f)
+	}
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	compressed, ok := bytes.CutPrefix(data, []byte("ENCRYPTED\n"))
+	if !ok {
+		t.Fatalf("the output did not pass through the encryptor: %q", data)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(t, r); n != 2 {
+		t.Errorf("%d findings decrypted and decompressed, want 2", n)
+	}
+}
+
+func TestFailingEncryptorFailsTheSink(t *testing.T) {
+	encryptor := fakeCore(t, `cat >/dev/null; echo "no such recipient" >&2; exit 1`)
+	sink, err := openFileSink(filepath.Join(t.TempDir(), "findings.jsonl.age"), "", exec.Command(encryptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Write([]byte("{}\n"))
+	if err := sink.Close(); err == nil {
+		t.Error("close succeeded although the encryptor failed")
+	}
+}
@@ -0,0 +1,179 @@
+/**
+ * @file engine.go
+ * @brief A native Go implementation of the core scanner's rule matching.
+ *
+ * With --engine native the analyzer evaluates the rules itself instead of
+ * starting the C++ core for every blob: no external executable and no
+ * temporary files are needed. Matching mirrors Scanner::scan_file in the core:
+ * every rule is applied to every line, each non-overlapping match is
+ * reported, and rules with a min_entropy only report matches at least that
+ * random (the entropy is 0 for rules without a threshold).
+ *
+ * Go regular expressions (RE2) have no lookaround. The rules file uses
+ * leading lookaheads of the form (?=.*X) to require that X follows; the core
+ * evaluates them where a match starts, so X must occur between the start of
+ * the match and the end of the line. They are translated into separate
+ * checks that bound where on a line a match may start. Rules using any
+ * other unsupported syntax are skipped with a warning.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+/**
+ * @struct engineRule
+ * @brief A rule compiled for the native engine.
+ */
+type engineRule struct {
+	def       ruleDef
+	pattern   *regexp.Regexp
+	lookahead []*regexp.Regexp // Each must match between the start of a match and the end of the line
+}
+
+/**
+ * @struct nativeEngine
+ * @brief The compiled rules of the native engine.
+ */
+type nativeEngine struct {
+	rules []engineRule
+}
+
+// leadingLookahead matches one "(?=.*X)" group at the start of a pattern.
+var leadingLookahead = regexp.MustCompile(`^\(\?=\.\*((?:[^()\\]|\\.|\[[^\]]*\])+)\)`)
+
+/**
+ * @brief Compiles a rule set for the native engine.
+ * @param set The rule set (nil yields an engine without rules).
+ * @return The engine; unsupported rules are reported on stderr and skipped.
+ */
+func newNativeEngine(set *ruleSet) *nativeEngine {
+	e := &nativeEngine{}
+	if set == nil {
+		return e
+	}
+	for _, def := range set.Rules {
+		rule, err := compileEngineRule(def)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: native engine: skipping rule %s: %v\n", def.ID, err)
+			continue
+		}
+		e.rules = append(e.rules, rule)
+	}
+	return e
+}
+
+/**
+ * @brief Compiles one rule, translating leading (?=.*X) lookaheads.
+ */
+func compileEngineRule(def ruleDef) (engineRule, error) {
+	rule := engineRule{def: def}
+	pattern := def.Regex
+	for {
+		m := leadingLookahead.FindStringSubmatch(pattern)
+		if m == nil {
+			break
+		}
+		re, err := regexp.Compile(m[1])
+		if err != nil {
+			return rule, err
+		}
+		rule.lookahead = append(rule.lookahead, re)
+		pattern = pattern[len(m[0]):]
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return rule, err
+	}
+	rule.pattern = re
+	return rule, nil
+}
+
+/**
+ * @brief Scans content and returns findings in the core scanner's format.
+ * @param content The content to scan.
+ * @param blob The blob the content belongs to.
+ * @return One finding per match, with the blob's git context attached.
+ */
+func (e *nativeEngine) scan(content []byte, blob fileBlob) []*finding {
+	var findings []*finding
+	for i, line := range strings.Split(string(content), "\n") {
+		for _, rule := range e.rules {
+			for _, match := range rule.lineMatches(line) {
+				entropy := 0.0
+				if rule.def.MinEntropy > 0 {
+					entropy = shannonEntropy(match)
+					if entropy < rule.def.MinEntropy {
+						continue
+					}
+				}
+				findings = append(findings, &finding{
+					SchemaVersion: findingSchemaVersion,
+					Repository:    blob.repo.label,
+					Commit:        blob.commit,
+					OriginalPath:  blob.path,
+					File:          blob.path,
+					Line:          i + 1,
+					RuleID:        rule.def.ID,
+					Description:   rule.def.Description,
+					Match:         match,
+					Entropy:       entropy,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+/**
+ * @brief Returns a rule's matches in a line as the core's repeated search
+ * finds them: each match starts where all translated lookaheads hold.
+ */
+func (r engineRule) lineMatches(line string) []string {
+	limit := r.lookaheadLimit(line)
+	if limit < 0 {
+		return nil
+	}
+	var matches []string
+	for _, loc := range r.pattern.FindAllStringIndex(line, -1) {
+		if loc[0] > limit {
+			break // No lookahead holds from here on
+		}
+		matches = append(matches, line[loc[0]:loc[1]])
+	}
+	return matches
+}
+
+/**
+ * @brief Returns the last position of a line where all translated
+ * lookaheads hold, i.e. the latest start of each one's X; -1 if one never does.
+ */
+func (r engineRule) lookaheadLimit(line string) int {
+	limit := len(line)
+	for _, re := range r.lookahead {
+		last := -1
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			last = loc[0]
+		}
+		if last < 0 {
+			return -1
+		}
+		// An occurrence overlapping the last non-overlapping one starts later.
+		for last < len(line) {
+			loc := re.FindStringIndex(line[last+1:])
+			if loc == nil {
+				break
+			}
+			last += 1 + loc[0]
+		}
+		if last < limit {
+			limit = last
+		}
+	}
+	return limit
+}
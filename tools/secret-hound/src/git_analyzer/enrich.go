@@ -0,0 +1,291 @@
+/**
+ * @file enrich.go
+ * @brief The enrichment chain run on every finding before it is written.
+ *
+ * Once a finding has passed its scanning profile it goes through an ordered
+ * chain of enrichers, each adding facts to it: the source encoding, the
+ * verified position, redacted context, a severity, allowlist demotion,
+ * escalation for public and internal repositories in sweeps, the commit
+ * author, the author of the secret's line (with --blame), the commit
+ * signature and identity flags, and the code owners of the file. New steps
+ * implement the enricher interface and are added to builtinEnrichers;
+ * --enrichers selects which ones run.
+ *
+ * The git context (repository, commit, path) is not an enrichment step: it
+ * is attached when a finding is created because profiles depend on it. Cloud
+ * account details are likewise added during scanning, while the content of a
+ * decoded base64/hex payload is still at hand.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * @struct enrichInput
+ * @brief What an enricher knows about the blob a finding came from.
+ */
+type enrichInput struct {
+	blob           fileBlob
+	content        []byte // The scanned (possibly transcoded) content
+	sourceEncoding string // Encoding the blob was transcoded from ("" if none)
+	firstLine      int    // Lines of the blob before content, for windows of a large blob
+}
+
+/**
+ * @interface enricher
+ * @brief One step of the enrichment chain.
+ */
+type enricher interface {
+	// name is the identifier used by --enrichers.
+	name() string
+	// enrich adds facts to a finding; it must be safe for concurrent use.
+	enrich(f *finding, in *enrichInput)
+}
+
+/**
+ * @brief Lists every built-in enricher, in the order they are run.
+ * Position checking comes before context, which is cut around the final line.
+ * @param opts The run options some enrichers are configured from.
+ * @param allow The allowlist of known test and placeholder secrets.
+ * @param managed The secrets manager inventory (nil if none is configured).
+ * @return Fresh enrichers (the caching ones hold per-run state).
+ */
+func builtinEnrichers(opts options, allow *allowlist, managed *managedInventory) []enricher {
+	return []enricher{
+		encodingEnricher{},
+		positionEnricher{},
+		contextEnricher{lines: opts.contextLines},
+		severityEnricher{},
+		allowlistEnricher{list: allow},
+		managedEnricher{inv: managed},
+		&visibilityEnricher{client: &http.Client{Timeout: visibilityHTTPTimeout}, repos: make(map[*repository]*repoVisibility)},
+		&authorEnricher{authors: make(map[commitKey]string)},
+		&blameEnricher{enabled: opts.blame, owners: make(map[lineKey]lineOwner)},
+		&identityEnricher{domains: normalizeDomains(opts.corporateDomains), commits: make(map[commitKey]commitIdentity)},
+		&ownersEnricher{rules: make(map[*repository][]ownerRule)},
+	}
+}
+
+/**
+ * @brief Selects enrichers from a comma-separated --enrichers value.
+ * The chain keeps the built-in order whatever order the names are given in.
+ * @param spec "all", "none", or a comma-separated list of enricher names.
+ * @param opts The run options.
+ * @param allow The allowlist of known test and placeholder secrets.
+ * @param managed The secrets manager inventory (nil if none is configured).
+ * @return The selected enrichers and an error naming any unknown enricher.
+ */
+func selectEnrichers(spec string, opts options, allow *allowlist, managed *managedInventory) ([]enricher, error) {
+	all := builtinEnrichers(opts, allow, managed)
+	switch strings.TrimSpace(spec) {
+	case "", "all":
+		return all, nil
+	case "none":
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		wanted[strings.TrimSpace(name)] = true
+	}
+	var selected []enricher
+	var names []string
+	for _, e := range all {
+		names = append(names, e.name())
+		if wanted[e.name()] {
+			selected = append(selected, e)
+			delete(wanted, e.name())
+		}
+	}
+	for name := range wanted {
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown enricher %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return selected, nil
+}
+
+/**
+ * @brief Runs the enrichment chain on a finding.
+ * @param f The finding to enrich.
+ * @param in The blob the finding came from.
+ */
+func (a *analyzer) enrich(f *finding, in *enrichInput) {
+	for _, e := range a.enrichers {
+		e.enrich(f, in)
+	}
+}
+
+// encodingEnricher records the encoding a transcoded blob was read in.
+type encodingEnricher struct{}
+
+func (encodingEnricher) name() string { return "encoding" }
+
+func (encodingEnricher) enrich(f *finding, in *enrichInput) {
+	setMetadata(f, "source_encoding", in.sourceEncoding)
+}
+
+// positionEnricher checks the reported line against the content and adds the column.
+type positionEnricher struct{}
+
+func (positionEnricher) name() string { return "position" }
+
+func (positionEnricher) enrich(f *finding, in *enrichInput) {
+	f.Line -= in.firstLine
+	validatePosition(f, in.content)
+	f.Line += in.firstLine
+	if reported, err := strconv.Atoi(f.Metadata["reported_line"]); err == nil && in.firstLine > 0 {
+		f.Metadata["reported_line"] = strconv.Itoa(reported + in.firstLine)
+	}
+}
+
+// contextEnricher attaches redacted surrounding lines (--context).
+type contextEnricher struct{ lines int }
+
+func (contextEnricher) name() string { return "context" }
+
+func (e contextEnricher) enrich(f *finding, in *enrichInput) {
+	f.Line -= in.firstLine
+	attachContext(f, in.content, e.lines)
+	f.Line += in.firstLine
+	for i := range f.Context {
+		f.Context[i].Line += in.firstLine
+	}
+}
+
+// severityEnricher derives a severity from the confidence when no detector assessed one.
+type severityEnricher struct{}
+
+func (severityEnricher) name() string { return "severity" }
+
+func (severityEnricher) enrich(f *finding, in *enrichInput) {
+	if f.Severity == "" {
+		f.Severity = strings.ToLower(f.Confidence)
+	}
+}
+
+// commitKey identifies a commit of one repository in per-run caches.
+type commitKey struct {
+	repo   *repository
+	commit string
+}
+
+/**
+ * @struct authorEnricher
+ * @brief Adds the author of the commit that introduced the blob (metadata author).
+ */
+type authorEnricher struct {
+	mu      sync.Mutex
+	authors map[commitKey]string
+}
+
+func (*authorEnricher) name() string { return "author" }
+
+func (e *authorEnricher) enrich(f *finding, in *enrichInput) {
+	if in.blob.commit == "" || in.blob.commit == worktreeCommit {
+		return
+	}
+	key := commitKey{in.blob.repo, in.blob.commit}
+	e.mu.Lock()
+	author, ok := e.authors[key]
+	e.mu.Unlock()
+	if !ok {
+		output, _ := in.blob.repo.command("show", "-s", "--format=%an <%ae>", in.blob.commit).Output()
+		author = strings.TrimSpace(string(output))
+		e.mu.Lock()
+		e.authors[key] = author
+		e.mu.Unlock()
+	}
+	setMetadata(f, "author", author)
+}
+
+// codeownersPaths are the CODEOWNERS locations, in the order GitHub looks them up.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+/**
+ * @struct ownerRule
+ * @brief One CODEOWNERS line: a path pattern and its owners.
+ */
+type ownerRule struct {
+	pattern string
+	owners  []string
+}
+
+/**
+ * @struct ownersEnricher
+ * @brief Adds the code owners of the file from CODEOWNERS (metadata owners).
+ * The CODEOWNERS file is read at HEAD even for old commits: the current
+ * owners are the ones who have to rotate a leaked secret.
+ */
+type ownersEnricher struct {
+	mu    sync.Mutex
+	rules map[*repository][]ownerRule
+}
+
+func (*ownersEnricher) name() string { return "owners" }
+
+func (e *ownersEnricher) enrich(f *finding, in *enrichInput) {
+	e.mu.Lock()
+	rules, ok := e.rules[in.blob.repo]
+	if !ok {
+		rules = loadCodeowners(in.blob.repo, "HEAD")
+		e.rules[in.blob.repo] = rules
+	}
+	e.mu.Unlock()
+	// The last matching pattern takes precedence.
+	for i := len(rules) - 1; i >= 0; i-- {
+		if matchPathGlob(rules[i].pattern, in.blob.path) {
+			setMetadata(f, "owners", strings.Join(rules[i].owners, " "))
+			return
+		}
+	}
+}
+
+/**
+ * @brief Reads the CODEOWNERS file of a commit.
+ * @param repo The repository.
+ * @param commit The commit whose tree is searched.
+ * @return The rules in file order, or nil if the commit has no CODEOWNERS.
+ */
+func loadCodeowners(repo *repository, commit string) []ownerRule {
+	for _, path := range codeownersPaths {
+		content, err := repo.command("cat-file", "blob", commit+":"+path).Output()
+		if err != nil {
+			continue
+		}
+		return parseCodeowners(content)
+	}
+	return nil
+}
+
+/**
+ * @brief Parses CODEOWNERS content.
+ * Lines without owners are kept: they clear ownership for their pattern.
+ * @param content The file content.
+ * @return The rules in file order.
+ */
+func parseCodeowners(content []byte) []ownerRule {
+	var rules []ownerRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, ownerRule{pattern: fields[0], owners: fields[1:]})
+	}
+	return rules
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSelectEnrichersKeepsChainOrder(t *testing.T) {
+	names := func(chain []enricher) []string {
+		var out []string
+		for _, e := range chain {
+			out = append(out, e.name())
+		}
+		return out
+	}
+	all, err := selectEnrichers("all", options{}, nil, nil)
+	if err != nil || !reflect.DeepEqual(names(all), []string{"encoding", "position", "context", "severity", "allowlist", "managed", "visibility", "author", "blame", "identity", "owners"}) {
+		t.Errorf("all: %v, %v", names(all), err)
+	}
+	picked, err := selectEnrichers("owners, position", options{}, nil, nil)
+	if err != nil || !reflect.DeepEqual(names(picked), []string{"position", "owners"}) {
+		t.Errorf("picked: %v, %v", names(picked), err)
+	}
+	if none, err := selectEnrichers("none", options{}, nil, nil); none != nil || err != nil {
+		t.Errorf("none: %v, %v", names(none), err)
+	}
+	if _, err := selectEnrichers("position,spelling", options{}, nil, nil); err == nil {
+		t.Error("an unknown enricher was accepted")
+	}
+}
+
+func TestEnrichmentChain(t *testing.T) {
+	fx := newFixtureRepo(t)
+	commit := fx.commit("add config", map[string]string{
+		".github/CODEOWNERS": "# owners\n*           @org/everyone\n/config/   @org/platform @alice\n/config/public.env\n",
+		"config/app.env":     "# app\nTOKEN=abcd1234\n",
+	})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+	a := &analyzer{}
+	a.enrichers, _ = selectEnrichers("all", options{contextLines: 1}, nil, nil)
+
+	in := &enrichInput{
+		blob:           fileBlob{commit: commit, path: "config/app.env", repo: repo},
+		content:        []byte("# app\nTOKEN=abcd1234\n"),
+		sourceEncoding: encodingCP1252,
+	}
+	f := &finding{Line: 1, Match: "abcd1234", Confidence: "High"}
+	a.enrich(f, in)
+
+	if f.Line != 2 || f.Column != 7 || len(f.Context) != 3 || f.Severity != "high" {
+		t.Errorf("position, context and severity: %+v", f)
+	}
+	for k, v := range map[string]string{
+		"source_encoding": encodingCP1252,
+		"author":          "fixture <fixture@example.com>",
+		"owners":          "@org/platform @alice",
+	} {
+		if f.Metadata[k] != v {
+			t.Errorf("%s = %q, want %q", k, f.Metadata[k], v)
+		}
+	}
+
+	// A detector's own severity is kept; a CODEOWNERS line without owners clears ownership.
+	in.blob.path = "config/public.env"
+	f = &finding{Line: 2, Match: "abcd1234", Confidence: "Low", Severity: "critical"}
+	a.enrich(f, in)
+	if f.Severity != "critical" || f.Metadata["owners"] != "" {
+		t.Errorf("finding in an unowned file: %+v", f)
+	}
+}
+
+func TestParseCodeowners(t *testing.T) {
+	rules := parseCodeowners([]byte("# comment\n\n*.go @go-team # inline\ndocs/ @docs @writers\n"))
+	want := []ownerRule{{"*.go", []string{"@go-team"}}, {"docs/", []string{"@docs", "@writers"}}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("parseCodeowners = %+v", rules)
+	}
+}
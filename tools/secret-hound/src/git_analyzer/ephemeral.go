@@ -0,0 +1,195 @@
+/**
+ * @file ephemeral.go
+ * @brief Support for stateless runs on ephemeral runners (Kubernetes Jobs, CI).
+ *
+ * Three pieces make a scan independent of the machine it runs on:
+ *   - Configuration from the environment: every flag can be given as
+ *     SECRET_HOUND_<FLAG> (upper case, dashes as underscores, e.g.
+ *     SECRET_HOUND_GIT_DIR), and the positional arguments as SECRET_HOUND_CORE
+ *     and SECRET_HOUND_DEPTH, so a Job spec needs no command line. Flags on
+ *     the command line override the environment; repeatable flags take a
+ *     comma-separated list and add to the command line values.
+ *   - --state-url s3://bucket/prefix (or gs://): the commit cache, the scan
+ *     history, the secret index and the core quarantine list are downloaded
+ *     before the scan and uploaded after it, unless --commit-cache,
+ *     --history-file, --secret-index or --quarantine-file name local files
+ *     explicitly.
+ *   - --output s3://bucket/key (or gs://): findings are streamed to the
+ *     bucket (see objstore.go).
+ * Local state files only live in --tmp-dir for the duration of the run.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// envPrefix is the prefix of environment variables that set flags.
+const envPrefix = "SECRET_HOUND_"
+
+// Objects kept under --state-url.
+const (
+	stateCommitCache = "commit-cache.json"
+	stateHistory     = "history.jsonl"
+	stateQuarantine  = "quarantine.json"
+	stateSecretIndex = "secret-index.json"
+)
+
+/**
+ * @brief Returns the environment variable that sets a flag.
+ */
+func flagEnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+/**
+ * @brief Sets flags from SECRET_HOUND_* environment variables.
+ * Must run before parsing the command line, which then takes precedence.
+ * @param fs The flag set.
+ * @return An error naming the variable with an invalid value.
+ */
+func applyEnvFlags(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(flagEnvName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		values := []string{value}
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if setErr := fs.Set(f.Name, strings.TrimSpace(v)); setErr != nil {
+				err = fmt.Errorf("%s: %v", flagEnvName(f.Name), setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+/**
+ * @brief Returns the positional arguments from the environment.
+ * Used when none are given on the command line.
+ * @param engine The --engine value; the native engine takes no core path.
+ */
+func envPositionalArgs(engine string) []string {
+	var args []string
+	if core := os.Getenv(envPrefix + "CORE"); core != "" && engine == "core" {
+		args = append(args, core)
+	}
+	if depth := os.Getenv(envPrefix + "DEPTH"); depth != "" {
+		args = append(args, depth)
+	}
+	return args
+}
+
+/**
+ * @struct remoteState
+ * @brief State files mirrored between --tmp-dir and an object store prefix.
+ */
+type remoteState struct {
+	store  objectStore
+	prefix string
+	dir    string          // Local directory holding the downloaded files
+	synced map[string]bool // Object names in use this run
+}
+
+/**
+ * @brief Connects to the --state-url location.
+ * @param rawURL The s3:// or gs:// URL of the state prefix.
+ * @param enc The server-side encryption for uploads.
+ * @param tmpDir The directory for the local copies.
+ * @return The state and an error if the URL is invalid or the directory cannot be created.
+ */
+func openRemoteState(rawURL string, enc objectEncryption, tmpDir string) (*remoteState, error) {
+	if !isObjectURL(rawURL) {
+		return nil, fmt.Errorf("%s: expected an s3:// or gs:// URL", rawURL)
+	}
+	store, prefix, err := openObjectURL(rawURL, enc)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(tmpDir, "secret-hound-state-")
+	if err != nil {
+		return nil, err
+	}
+	return &remoteState{store: store, prefix: prefix, dir: dir, synced: make(map[string]bool)}, nil
+}
+
+/**
+ * @brief Downloads a state object and returns its local path.
+ * A missing object is fine: the first run creates it.
+ * @param name The object name under the state prefix.
+ * @return The local path and an error if the download failed.
+ */
+func (s *remoteState) file(name string) (string, error) {
+	local := filepath.Join(s.dir, name)
+	s.synced[name] = true
+	data, err := s.store.get(objectKey(s.prefix, name))
+	if err == errObjectNotFound {
+		return local, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return local, ioutil.WriteFile(local, data, 0o600)
+}
+
+/**
+ * @brief Uploads the state files that exist and removes the local copies.
+ * @return The first upload error.
+ */
+func (s *remoteState) save() error {
+	if s == nil {
+		return nil
+	}
+	defer os.RemoveAll(s.dir)
+	for name := range s.synced {
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = s.store.put(objectKey(s.prefix, name), data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.store.url(objectKey(s.prefix, name)), err)
+		}
+	}
+	return nil
+}
+
+/**
+ * @brief Opens a sink that streams to an s3:// or gs:// --output.
+ * @param rawURL The final object URL (see resolveSinkPath).
+ * @param enc The server-side encryption for the upload.
+ * @param compress The compression to use ("" for none).
+ * @param encryptCmd The encryption process to pipe the output through (nil for none).
+ */
+func openObjectSink(rawURL string, enc objectEncryption, compress string, encryptCmd *exec.Cmd) (*fileSink, error) {
+	store, key, err := openObjectURL(rawURL, enc)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("%s: missing object name", rawURL)
+	}
+	upload, err := store.create(key)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newSink(upload, compress, encryptCmd)
+	if err != nil {
+		upload.abort()
+	}
+	return sink, err
+}
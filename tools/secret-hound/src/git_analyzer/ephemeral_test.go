@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnvFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	gitDir := fs.String("git-dir", "", "")
+	full := fs.Bool("full-history", false, "")
+	var repos stringList
+	fs.Var(&repos, "repo", "")
+
+	t.Setenv("SECRET_HOUND_GIT_DIR", "/env/repo.git")
+	t.Setenv("SECRET_HOUND_FULL_HISTORY", "true")
+	t.Setenv("SECRET_HOUND_REPO", "a, b")
+	if err := applyEnvFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--git-dir", "/cli/repo.git", "--repo", "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if *gitDir != "/cli/repo.git" || !*full || !reflect.DeepEqual([]string(repos), []string{"a", "b", "c"}) {
+		t.Errorf("git-dir %q, full-history %v, repo %v", *gitDir, *full, repos)
+	}
+
+	t.Setenv("SECRET_HOUND_FULL_HISTORY", "sometimes")
+	if err := applyEnvFlags(fs); err == nil {
+		t.Error("an invalid boolean was accepted")
+	}
+}
+
+func TestEnvPositionalArgs(t *testing.T) {
+	t.Setenv("SECRET_HOUND_CORE", "/opt/hound-core")
+	t.Setenv("SECRET_HOUND_DEPTH", "50")
+	if got := envPositionalArgs("core"); !reflect.DeepEqual(got, []string{"/opt/hound-core", "50"}) {
+		t.Errorf("core engine: %v", got)
+	}
+	if got := envPositionalArgs("native"); !reflect.DeepEqual(got, []string{"50"}) {
+		t.Errorf("native engine: %v", got)
+	}
+}
+
+func TestRemoteStateRoundTrip(t *testing.T) {
+	store := newMemoryStore()
+	store.put("scans/commit-cache.json", []byte("cached"))
+	state := &remoteState{store: store, prefix: "scans/", dir: t.TempDir(), synced: make(map[string]bool)}
+
+	cache, err := state.file(stateCommitCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(cache); string(data) != "cached" {
+		t.Errorf("downloaded %q", data)
+	}
+	history, err := state.file(stateHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(history); !os.IsNotExist(err) {
+		t.Error("a missing object was created locally")
+	}
+	os.WriteFile(cache, []byte("updated"), 0o600)
+	os.WriteFile(history, []byte("run\n"), 0o600)
+	if err := state.save(); err != nil {
+		t.Fatal(err)
+	}
+	if string(store.objects["scans/commit-cache.json"]) != "updated" || string(store.objects["scans/history.jsonl"]) != "run\n" {
+		t.Errorf("uploaded %v", store.objects)
+	}
+	if _, err := os.Stat(state.dir); !os.IsNotExist(err) {
+		t.Error("the local state was left behind")
+	}
+	if _, err := openRemoteState("/var/state", objectEncryption{}, t.TempDir()); err == nil {
+		t.Error("a local path was accepted as --state-url")
+	}
+}
+
+func TestObjectSinkStreamsToTheBucket(t *testing.T) {
+	s3 := newFakeS3(t)
+	if _, err := openObjectSink("s3://bucket/scans/", objectEncryption{}, "", nil); err == nil {
+		t.Error("an --output prefix without an object name was accepted")
+	}
+	sink, err := openObjectSink("s3://bucket/scans/findings.jsonl.gz", objectEncryption{}, "gzip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ := newFindingWriter(sink, "json")
+	for _, f := range sampleFindings() {
+		fw.write(f)
+	}
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(s3.objects["/bucket/scans/findings.jsonl.gz"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(t, r); n != 2 {
+		t.Errorf("%d findings uploaded, want 2", n)
+	}
+}
@@ -0,0 +1,164 @@
+/**
+ * @file errors.go
+ * @brief Blob-level errors and the end-of-run error report (--strict).
+ *
+ * A blob that cannot be read, that a scanner fails on, or whose findings
+ * cannot be written does not stop the run: the workers log it and move on.
+ * That used to mean one stderr line among thousands and a run that reported
+ * success, so nobody noticed that part of the history went unscanned. Worker
+ * code now returns a typed error instead of returning silently:
+ *   - gitError: Git could not produce the blob (the blob is skipped);
+ *   - scannerError: the core, an external detector or the output parser
+ *     failed on the blob (its findings from that scanner are lost, or come
+ *     from the native fallback);
+ *   - sinkError: a finding or an exported blob could not be written.
+ * Every error is collected, the end of the run lists them grouped by kind,
+ * and with --strict a run with any of them exits with strictFailExitCode
+ * after writing everything else.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// strictFailExitCode is the exit code of a --strict run in which a blob-level error occurred.
+const strictFailExitCode = 4
+
+/**
+ * @struct gitError
+ * @brief Git could not produce a blob; the blob was skipped.
+ */
+type gitError struct {
+	op   string // What was attempted, e.g. "read"
+	blob fileBlob
+	err  error
+}
+
+func (e *gitError) Error() string {
+	return fmt.Sprintf("git: %s %s: %v", e.op, describeBlob(e.blob), e.err)
+}
+
+func (e *gitError) Unwrap() error { return e.err }
+
+/**
+ * @struct scannerError
+ * @brief A scanner failed on a blob; its findings from that scanner are incomplete.
+ */
+type scannerError struct {
+	scanner string // "core", "core output" or the external detector's name
+	blob    fileBlob
+	err     error
+}
+
+func (e *scannerError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.scanner, describeBlob(e.blob), e.err)
+}
+
+func (e *scannerError) Unwrap() error { return e.err }
+
+/**
+ * @struct sinkError
+ * @brief A finding or an exported blob could not be written.
+ */
+type sinkError struct {
+	sink string // "findings output" or "export"
+	blob fileBlob
+	err  error
+}
+
+func (e *sinkError) Error() string {
+	if e.blob.hash == "" {
+		return fmt.Sprintf("%s: %v", e.sink, e.err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.sink, describeBlob(e.blob), e.err)
+}
+
+func (e *sinkError) Unwrap() error { return e.err }
+
+// describeBlob names a blob in error messages: hash, repository and path.
+func describeBlob(blob fileBlob) string {
+	label := ""
+	if blob.repo != nil {
+		label = blob.repo.label
+	}
+	return fmt.Sprintf("blob %s (%s%s)", orDefault(blob.hash, "-"), labelPrefix(label), blob.path)
+}
+
+/**
+ * @struct failureLog
+ * @brief The blob-level errors of a run.
+ */
+type failureLog struct {
+	mu     sync.Mutex
+	errors []error
+}
+
+// scanFailures collects the blob-level errors of the run.
+var scanFailures = &failureLog{}
+
+/**
+ * @brief Logs a blob-level error on stderr and keeps it for the final report.
+ * @param err The error (nil is ignored).
+ */
+func (l *failureLog) record(err error) {
+	if err == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: %v\n", err)
+	l.mu.Lock()
+	l.errors = append(l.errors, err)
+	l.mu.Unlock()
+}
+
+/**
+ * @brief Returns the number of errors recorded.
+ */
+func (l *failureLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.errors)
+}
+
+/**
+ * @brief Prints the errors of the run on stderr, grouped by kind.
+ */
+func (l *failureLog) report() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errors) == 0 {
+		return
+	}
+	var git, scanner, sink, other []error
+	for _, err := range l.errors {
+		switch err.(type) {
+		case *gitError:
+			git = append(git, err)
+		case *scannerError:
+			scanner = append(scanner, err)
+		case *sinkError:
+			sink = append(sink, err)
+		default:
+			other = append(other, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: %d blob-level error(s); the results are incomplete:\n", len(l.errors))
+	printFailureGroup("skipped, unreadable", git)
+	printFailureGroup("scanner failed", scanner)
+	printFailureGroup("not written", sink)
+	printFailureGroup("other", other)
+}
+
+// printFailureGroup lists the errors of one kind under a heading.
+func printFailureGroup(heading string, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  %s (%d):\n", heading, len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "    %v\n", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunCoreReturnsScannerErrors(t *testing.T) {
+	rules, _ := embeddedRuleSet()
+	q, _ := loadCoreQuarantine("", rules)
+	blob := fileBlob{hash: "c0ffee", path: "deploy.env", repo: &repository{label: "app"}}
+	a := &analyzer{opts: options{houndCorePath: fakeCore(t, `cat >/dev/null; exit 3`)}, core: &coreInfo{Capabilities: []string{"scan-file", "stdin"}}, quarantine: q}
+
+	var findings []*finding
+	var err error
+	captureStderr(t, func() { findings, err = a.runCore(blob, []byte("AWS_KEY=AKIAY34FZKBOKMUTVV01\n")) })
+	var scanErr *scannerError
+	if !errors.As(err, &scanErr) || scanErr.scanner != "core" || scanErr.blob.hash != "c0ffee" {
+		t.Fatalf("err = %v, want a core scannerError", err)
+	}
+	if !strings.Contains(err.Error(), "core: blob c0ffee (app: deploy.env): exit status 3; quarantined") {
+		t.Errorf("err = %q", err)
+	}
+	if len(findings) == 0 {
+		t.Error("the native fallback's findings were dropped with the error")
+	}
+
+	a.opts.houndCorePath = fakeCore(t, `cat >/dev/null; echo 'not json'`)
+	captureStderr(t, func() { _, err = a.runCore(fileBlob{hash: "b2", path: "b.env", repo: &repository{}}, []byte("x")) })
+	if !errors.As(err, &scanErr) || scanErr.scanner != "core output" {
+		t.Errorf("unparsable core output: err = %v", err)
+	}
+}
+
+func TestFailureLogReport(t *testing.T) {
+	cause := errors.New("broken pipe")
+	l := &failureLog{}
+	output := captureStderr(t, func() {
+		l.record(nil)
+		l.record(&gitError{op: "read", blob: fileBlob{hash: "a1", path: "a.env", repo: &repository{}}, err: fmt.Errorf("exit status 128")})
+		l.record(&sinkError{sink: "findings output", err: cause})
+		l.record(&scannerError{scanner: "trufflehog", blob: fileBlob{path: "b.env"}, err: fmt.Errorf("timed out after 1s")})
+		l.record(&sinkError{sink: "export", blob: fileBlob{hash: "c3", path: "c.env"}, err: cause})
+	})
+	if l.count() != 4 {
+		t.Fatalf("count = %d, want 4 (nil is not an error)", l.count())
+	}
+	if !errors.Is(l.errors[1], cause) {
+		t.Error("sinkError does not unwrap to its cause")
+	}
+	if strings.Count(output, "Go analyzer: ") != 4 {
+		t.Errorf("every error is logged when it happens:\n%s", output)
+	}
+
+	output = captureStderr(t, l.report)
+	for _, want := range []string{
+		"4 blob-level error(s); the results are incomplete:",
+		"  skipped, unreadable (1):\n    git: read blob a1 (a.env): exit status 128\n",
+		"  scanner failed (1):\n    trufflehog: blob - (b.env): timed out after 1s\n",
+		"  not written (2):\n    findings output: broken pipe\n    export: blob c3 (c.env): broken pipe\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("report lacks %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "other") {
+		t.Errorf("empty groups are listed:\n%s", output)
+	}
+	if output := captureStderr(t, (&failureLog{}).report); output != "" {
+		t.Errorf("a run without errors reported %q", output)
+	}
+}
+
+func TestStrictFailExitCodeIsDistinct(t *testing.T) {
+	// CI tells a --strict failure from a fatal error (1) and a --fail-on policy failure.
+	if strictFailExitCode <= 1 || strictFailExitCode == policyFailExitCode {
+		t.Errorf("strictFailExitCode = %d, policyFailExitCode = %d", strictFailExitCode, policyFailExitCode)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlobExporterWritesCopiesAndManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "export")
+	e, err := newBlobExporter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := fileBlob{hash: "b10b", path: "config/app.env", commit: "c0ffee", repo: &repository{label: "svc"}}
+	findings := []*finding{{RuleID: "JWT"}, {RuleID: "AWS_KEY"}, {RuleID: "JWT"}}
+	if err := e.export(blob, []byte("TOKEN=abc\n"), findings); err != nil {
+		t.Fatal(err)
+	}
+	// A blob reported again (e.g. from another commit) is exported once.
+	blob.commit = "decade"
+	if err := e.export(blob, []byte("TOKEN=abc\n"), findings); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("export directory: %v, %v", info.Mode(), err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "b10b")); err != nil || string(content) != "TOKEN=abc\n" {
+		t.Errorf("blob copy: %q, %v", content, err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(dir, "manifest.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(manifest)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("manifest has %d lines, want 1", len(lines))
+	}
+	var entry exportEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	want := exportEntry{Blob: "b10b", File: "b10b", Repository: "svc", Commit: "c0ffee", Path: "config/app.env", Size: 10, Findings: 3, RuleIDs: []string{"AWS_KEY", "JWT"}}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("manifest entry = %+v, want %+v", entry, want)
+	}
+}
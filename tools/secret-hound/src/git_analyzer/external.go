@@ -0,0 +1,109 @@
+/**
+ * @file external.go
+ * @brief User-supplied detector commands (--external-detector).
+ *
+ * Teams with proprietary detection logic (internal token formats, a
+ * classifier service) can plug it into the sweep without forking the
+ * analyzer. --external-detector names a command, split on spaces into the
+ * program and its arguments, that is run once per scanned blob with the
+ * blob's content on stdin and its repository path in HOUND_FILE_PATH. It
+ * prints zero or more findings, one JSON object per line, using the fields
+ * of the finding schema (--print-schema) that describe a match: rule_id,
+ * line and match are required; description, confidence, severity, key_path,
+ * category and metadata are optional, and git context is filled in by the
+ * analyzer. The findings then go through the same profiles, overrides,
+ * enrichers, deduplication and sinks as the built-in detectors. A command
+ * that fails, times out (--external-detector-timeout) or prints a malformed
+ * line contributes nothing for that blob, with a warning on stderr.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/**
+ * @struct externalDetector
+ * @brief A detector implemented by an external command.
+ */
+type externalDetector struct {
+	argv    []string
+	timeout time.Duration
+}
+
+func (e *externalDetector) name() string { return "external:" + filepath.Base(e.argv[0]) }
+
+/**
+ * @brief Creates the detectors of the --external-detector values.
+ * @param commands The command lines.
+ * @param timeout The longest a command may run on one blob.
+ * @return The detectors and an error for an empty command or one not found.
+ */
+func newExternalDetectors(commands []string, timeout time.Duration) ([]detector, error) {
+	var detectors []detector
+	for _, command := range commands {
+		argv := strings.Fields(command)
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, &externalDetector{argv: argv, timeout: timeout})
+	}
+	return detectors, nil
+}
+
+func (e *externalDetector) detect(filePath string, content []byte) []detection {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.argv[0], e.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Env = append(os.Environ(), "HOUND_FILE_PATH="+filePath)
+	output, err := runTracked(cmd)
+	if ctx.Err() != nil {
+		scanFailures.record(&scannerError{scanner: e.name(), blob: fileBlob{path: filePath}, err: fmt.Errorf("timed out after %s", e.timeout)})
+		return nil
+	}
+	if err != nil {
+		scanFailures.record(&scannerError{scanner: e.name(), blob: fileBlob{path: filePath}, err: err})
+		return nil
+	}
+
+	var found []detection
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var f finding
+		if err := json.Unmarshal(line, &f); err != nil || f.RuleID == "" || f.Line < 1 || f.Match == "" {
+			scanFailures.record(&scannerError{scanner: e.name(), blob: fileBlob{path: filePath}, err: fmt.Errorf("output line %d is not a finding (rule_id, line and match are required)", n)})
+			return nil
+		}
+		found = append(found, detection{
+			ruleID:      f.RuleID,
+			description: orDefault(f.Description, f.RuleID),
+			line:        f.Line,
+			match:       f.Match,
+			keyPath:     f.KeyPath,
+			confidence:  orDefault(f.Confidence, "Medium"),
+			severity:    f.Severity,
+			metadata:    f.Metadata,
+			category:    f.Category,
+		})
+	}
+	return found
+}
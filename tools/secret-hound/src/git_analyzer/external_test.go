@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExternalDetector(t *testing.T) {
+	// Reports every line containing "TOKEN-" with the path it was given.
+	script := fakeCore(t, `grep -n 'TOKEN-' | while IFS=: read n rest; do
+  printf '{"rule_id": "ACME_TOKEN", "line": %d, "match": "%s", "metadata": {"path": "%s"}, "category": "internal"}\n' "$n" "$rest" "$HOUND_FILE_PATH"
+done`)
+	detectors, err := newExternalDetectors([]string{script + " --strict"}, 5*time.Second)
+	if err != nil || len(detectors) != 1 || detectors[0].name() != "external:hound-core" {
+		t.Fatalf("newExternalDetectors: %v, %v", detectors, err)
+	}
+	found := detectors[0].detect("conf/app.env", []byte("A=1\nTOKEN-abc\n"))
+	if len(found) != 1 {
+		t.Fatalf("detections %+v", found)
+	}
+	d := found[0]
+	if d.ruleID != "ACME_TOKEN" || d.line != 2 || d.match != "TOKEN-abc" || d.description != "ACME_TOKEN" ||
+		d.confidence != "Medium" || d.metadata["path"] != "conf/app.env" || d.category != "internal" {
+		t.Errorf("detection %+v", d)
+	}
+}
+
+func TestExternalDetectorFailures(t *testing.T) {
+	for name, script := range map[string]string{
+		"exit status": `echo '{"rule_id": "A", "line": 1, "match": "x"}'; exit 2`,
+		"malformed":   `echo '{"rule_id": "A", "line": 1, "match": "x"}'; echo 'not json'`,
+		"no match":    `echo '{"rule_id": "A", "line": 1}'`,
+		"timeout":     `exec sleep 5`,
+	} {
+		e := &externalDetector{argv: []string{fakeCore(t, script)}, timeout: 200 * time.Millisecond}
+		if found := e.detect("a", []byte("x\n")); found != nil {
+			t.Errorf("%s: detections %+v", name, found)
+		}
+	}
+	for _, commands := range [][]string{{"  "}, {"/no/such/detector"}} {
+		if _, err := newExternalDetectors(commands, time.Second); err == nil {
+			t.Errorf("--external-detector %q was accepted", commands)
+		}
+	}
+}
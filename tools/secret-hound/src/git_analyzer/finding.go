@@ -0,0 +1,231 @@
+/**
+ * @file finding.go
+ * @brief The finding record emitted by the analyzer and its versioned schema.
+ *
+ * Every line written to stdout is one JSON-encoded finding. The record carries
+ * a schema_version so the Python reporter and third-party consumers can detect
+ * incompatible changes. The `proto` tags give the field numbers used by the
+ * protobuf output format and must stay in sync with finding.proto. Adding optional fields is a minor version bump;
+ * removing or renaming fields, or changing their meaning, is a major bump.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// findingSchemaVersion is the version of the finding record described by findingSchema.
+const findingSchemaVersion = "1.6"
+
+/**
+ * @struct finding
+ * @brief A single secret detected in a blob, enriched with its git context.
+ */
+type finding struct {
+	SchemaVersion string `json:"schema_version" proto:"1"`
+
+	// Git context added by the analyzer.
+	Repository   string `json:"repository,omitempty" proto:"2"`
+	Commit       string `json:"commit" proto:"3"`
+	OriginalPath string `json:"original_path" proto:"4"`
+
+	// Fields reported by the core scanner.
+	File        string  `json:"file" proto:"5"`
+	Line        int     `json:"line" proto:"6"`
+	RuleID      string  `json:"rule_id" proto:"7"`
+	Description string  `json:"description" proto:"8"`
+	Match       string  `json:"match" proto:"9"`
+	Entropy     float64 `json:"entropy" proto:"10"`
+	Confidence  string  `json:"confidence,omitempty" proto:"11"`
+
+	// Fields set by native detectors.
+	KeyPath  string            `json:"key_path,omitempty" proto:"12"`
+	Severity string            `json:"severity,omitempty" proto:"13"`
+	Metadata map[string]string `json:"metadata,omitempty" proto:"14"`
+
+	// Set by the analyzer with --context.
+	Context []contextLine `json:"context,omitempty" proto:"15"`
+
+	// Set by the analyzer after checking the position against the blob.
+	Column int `json:"column,omitempty" proto:"16"`
+
+	// Set with --group-by secret: every place the same secret was found.
+	Occurrences []occurrence `json:"occurrences,omitempty" proto:"17"`
+
+	// Set by detectors of non-secret data, e.g. "pii" with --detect-pii.
+	Category string `json:"category,omitempty" proto:"18"`
+}
+
+/**
+ * @brief Decodes one line of core scanner output and attaches the blob's git context.
+ * @param line A JSON object as printed by the core scanner.
+ * @param blob The blob that was scanned.
+ * @return The enriched finding and an error if the line is not a JSON finding.
+ */
+func parseCoreFinding(line string, blob fileBlob) (*finding, error) {
+	f := &finding{}
+	if err := json.Unmarshal([]byte(line), f); err != nil {
+		return nil, fmt.Errorf("malformed core scanner output: %v", err)
+	}
+	if f.RuleID == "" || f.Match == "" {
+		return nil, fmt.Errorf("malformed core scanner output: rule_id and match are required")
+	}
+	f.SchemaVersion = findingSchemaVersion
+	f.Repository = blob.repo.label
+	f.Commit = blob.commit
+	f.OriginalPath = blob.path
+	return f, nil
+}
+
+/**
+ * @brief Decodes the output of a core scanner run on one blob.
+ * Lines that are not findings are skipped, so one malformed line does not
+ * lose the findings around it.
+ * @param output The core's stdout: one JSON finding per line.
+ * @param blob The blob that was scanned.
+ * @return The findings, and the first error if a line could not be decoded.
+ */
+func parseCoreOutput(output []byte, blob fileBlob) ([]*finding, error) {
+	var findings []*finding
+	var firstErr error
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		// Enrich the raw JSON finding with Git context.
+		f, err := parseCoreFinding(scanner.Text(), blob)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if f.File == "-" {
+			f.File = blob.path // Content came from stdin
+		}
+		findings = append(findings, f)
+	}
+	if err := scanner.Err(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return findings, firstErr
+}
+
+// findingSchema is the JSON Schema (draft 2020-12) of one finding line, printed by --print-schema.
+const findingSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/limearch/sniper/secret-hound/finding-1.schema.json",
+  "title": "secret-hound git_analyzer finding",
+  "description": "One line of git_analyzer output (JSON Lines).",
+  "type": "object",
+  "required": ["schema_version", "commit", "original_path", "file", "line", "rule_id", "match"],
+  "properties": {
+    "schema_version": {
+      "description": "Version of this schema. Consumers should reject unknown major versions.",
+      "type": "string",
+      "pattern": "^1\\.[0-9]+$"
+    },
+    "repository": {
+      "description": "Repository URL or name; present only in multi-repository sweeps.",
+      "type": "string"
+    },
+    "commit": {
+      "description": "Hash of the commit that contains the blob, or WORKTREE for files on disk.",
+      "type": "string"
+    },
+    "original_path": {
+      "description": "Path of the file in the repository.",
+      "type": "string"
+    },
+    "file": {
+      "description": "Path of the temporary file that was handed to the core scanner.",
+      "type": "string"
+    },
+    "line": {
+      "description": "1-based line number of the match, verified against the blob content.",
+      "type": "integer",
+      "minimum": 1
+    },
+    "column": {
+      "description": "1-based column of the match in Unicode code points, not counting a byte order mark (since 1.4).",
+      "type": "integer",
+      "minimum": 1
+    },
+    "rule_id": {
+      "description": "Identifier of the detection rule that matched.",
+      "type": "string"
+    },
+    "description": {
+      "description": "Human-readable description of the rule.",
+      "type": "string"
+    },
+    "match": {
+      "description": "The matched text.",
+      "type": "string"
+    },
+    "entropy": {
+      "description": "Shannon entropy of the match (0 if the rule has no entropy threshold).",
+      "type": "number",
+      "minimum": 0
+    },
+    "confidence": {
+      "description": "Confidence level of the rule.",
+      "type": "string",
+      "enum": ["Low", "Medium", "High", "low", "medium", "high"]
+    },
+    "key_path": {
+      "description": "Dotted key path of the value in a structured config file (since 1.1).",
+      "type": "string"
+    },
+    "severity": {
+      "description": "Assessed severity of the finding (since 1.2).",
+      "type": "string",
+      "enum": ["info", "low", "medium", "high", "critical"]
+    },
+    "metadata": {
+      "description": "Detector-specific facts, e.g. key_type and bits for private keys (since 1.2).",
+      "type": "object",
+      "additionalProperties": { "type": "string" }
+    },
+    "context": {
+      "description": "Source lines around the match with the secret redacted; present with --context (since 1.3).",
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["line", "text"],
+        "properties": {
+          "line": { "type": "integer", "minimum": 1 },
+          "text": { "type": "string" }
+        }
+      }
+    },
+    "occurrences": {
+      "description": "Every place the same secret was found; present with --group-by secret (since 1.5).",
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["commit", "path", "line"],
+        "properties": {
+          "repository": { "type": "string" },
+          "commit": { "type": "string" },
+          "path": { "type": "string" },
+          "line": { "type": "integer", "minimum": 1 },
+          "column": { "type": "integer", "minimum": 1 }
+        }
+      }
+    },
+    "category": {
+      "description": "Kind of data found; absent for secrets, \"pii\" for personal data found with --detect-pii (since 1.6).",
+      "type": "string",
+      "enum": ["pii"]
+    }
+  },
+  "additionalProperties": true
+}
+`
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCoreFindingAddsGitContext(t *testing.T) {
+	blob := fileBlob{hash: "b1", path: "config/app.env", commit: "c0ffee", repo: &repository{label: "billing"}}
+	f, err := parseCoreFinding(`{"file": "/tmp/x", "line": 3, "rule_id": "AWS_KEY", "match": "AKIA", "confidence": "High"}`, blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.SchemaVersion != findingSchemaVersion || f.Repository != "billing" || f.Commit != "c0ffee" || f.OriginalPath != "config/app.env" ||
+		f.Line != 3 || f.RuleID != "AWS_KEY" || f.Confidence != "High" {
+		t.Errorf("parsed finding: %+v", f)
+	}
+	if _, err := parseCoreFinding(`{"line": `, blob); err == nil {
+		t.Error("malformed core output was accepted")
+	}
+}
+
+// findingFields are the JSON names of the finding record's fields.
+func findingFields() map[string]bool {
+	fields := make(map[string]bool)
+	typ := reflect.TypeOf(finding{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+func TestFindingSchemaDescribesTheRecord(t *testing.T) {
+	var schema struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(findingSchema), &schema); err != nil {
+		t.Fatalf("--print-schema output is not JSON: %v", err)
+	}
+	fields := findingFields()
+	for name := range fields {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("field %s is missing from the schema", name)
+		}
+	}
+	for name := range schema.Properties {
+		if !fields[name] {
+			t.Errorf("the schema describes %s, which the record does not have", name)
+		}
+	}
+	for _, name := range schema.Required {
+		if !fields[name] {
+			t.Errorf("required field %s does not exist", name)
+		}
+	}
+}
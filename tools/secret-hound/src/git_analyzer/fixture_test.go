@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/**
+ * @struct fixtureRepo
+ * @brief A throwaway git repository built by a test.
+ */
+type fixtureRepo struct {
+	t   *testing.T
+	dir string
+}
+
+// fixtureEnv is the environment of every git command run on a fixture.
+var fixtureEnv = []string{
+	"GIT_CONFIG_GLOBAL=" + os.DevNull,
+	"GIT_CONFIG_NOSYSTEM=1",
+	"GIT_AUTHOR_NAME=fixture", "GIT_AUTHOR_EMAIL=fixture@example.com",
+	"GIT_COMMITTER_NAME=fixture", "GIT_COMMITTER_EMAIL=fixture@example.com",
+}
+
+/**
+ * @brief Creates an empty repository in a temporary directory.
+ * The user's git configuration is ignored so fixtures are reproducible.
+ */
+func newFixtureRepo(t *testing.T) *fixtureRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	r := &fixtureRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	r.git("config", "core.quotePath", "false")
+	return r
+}
+
+/**
+ * @brief Runs git in the fixture and returns its trimmed output.
+ */
+func (r *fixtureRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), fixtureEnv...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+/**
+ * @brief Writes files, commits them and returns the new commit hash.
+ */
+func (r *fixtureRepo) commit(message string, files map[string]string) string {
+	r.t.Helper()
+	r.write(files)
+	r.git("add", "-A")
+	r.git("commit", "-q", "--allow-empty", "-m", message)
+	return r.head()
+}
+
+/**
+ * @brief Writes files into the working tree without committing them.
+ * Content is written as is, so binary files are strings with NUL bytes.
+ */
+func (r *fixtureRepo) write(files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+}
+
+/**
+ * @brief Renames a file, optionally changing its content, and commits.
+ * @param content The new content ("" keeps it, for a pure rename).
+ * @return The new commit hash.
+ */
+func (r *fixtureRepo) rename(message, from, to, content string) string {
+	r.t.Helper()
+	if dir := filepath.Dir(filepath.Join(r.dir, to)); dir != r.dir {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("mv", from, to)
+	if content != "" {
+		r.write(map[string]string{to: content})
+	}
+	return r.commit(message, nil)
+}
+
+/**
+ * @brief Deletes files and commits.
+ * @return The new commit hash.
+ */
+func (r *fixtureRepo) remove(message string, paths ...string) string {
+	r.t.Helper()
+	r.git(append([]string{"rm", "-q", "--"}, paths...)...)
+	return r.commit(message, nil)
+}
+
+/**
+ * @brief Creates a branch at a commit (or HEAD if at is "") and checks it out.
+ */
+func (r *fixtureRepo) branch(name, at string) {
+	r.t.Helper()
+	args := []string{"checkout", "-q", "-b", name}
+	if at != "" {
+		args = append(args, at)
+	}
+	r.git(args...)
+}
+
+/**
+ * @brief Checks out an existing branch.
+ */
+func (r *fixtureRepo) checkout(name string) {
+	r.t.Helper()
+	r.git("checkout", "-q", name)
+}
+
+/**
+ * @brief Merges branches into the current one with a merge commit.
+ * @param files Files added or changed by the merge itself ("evil" merge content).
+ * @return The merge commit hash.
+ */
+func (r *fixtureRepo) merge(message string, files map[string]string, branches ...string) string {
+	r.t.Helper()
+	r.git(append([]string{"merge", "-q", "--no-ff", "--no-commit"}, branches...)...)
+	r.write(files)
+	r.git("add", "-A")
+	r.git("commit", "-q", "-m", message)
+	return r.head()
+}
+
+/**
+ * @brief Returns the hash of HEAD.
+ */
+func (r *fixtureRepo) head() string {
+	r.t.Helper()
+	return r.git("rev-parse", "HEAD")
+}
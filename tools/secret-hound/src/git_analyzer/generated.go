@@ -0,0 +1,135 @@
+/**
+ * @file generated.go
+ * @brief Recognises minified and generated files.
+ *
+ * Minified bundles and generated code (protobuf stubs, source maps, build
+ * output) are dense with random-looking strings and flood the entropy rules
+ * with false positives. A blob is considered generated when its path matches
+ * a well-known build-output pattern, its header carries a "generated" marker,
+ * or its lines are far longer than hand-written code. Depending on
+ * --generated, such blobs are skipped or their findings are down-ranked to
+ * Low confidence and tagged with the reason.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Generated-content handling modes for --generated.
+const (
+	generatedScan     = "scan"     // Treat generated files like any other file
+	generatedDownrank = "downrank" // Report findings with Low confidence
+	generatedSkip     = "skip"     // Do not scan generated files at all
+)
+
+// generatedPaths are build-output and generated-code locations.
+var generatedPaths = []string{
+	"dist/",
+	"build/",
+	"out/",
+	"*.min.js",
+	"*.min.css",
+	"*.bundle.js",
+	"*.chunk.js",
+	"*.map",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.pb.cc",
+	"*.pb.h",
+	"*_generated.go",
+	"*.g.dart",
+	"*.designer.cs",
+}
+
+// generatedMarkers appear in the header of generated source files.
+var generatedMarkers = [][]byte{
+	[]byte("Code generated"),
+	[]byte("@generated"),
+	[]byte("DO NOT EDIT"),
+	[]byte("<auto-generated"),
+	[]byte("This file was automatically generated"),
+}
+
+const (
+	generatedHeaderBytes  = 2048 // Bytes searched for a generated marker
+	minifiedSampleBytes   = 64 << 10
+	minifiedAverageLine   = 300  // Average line length that indicates minified code
+	minifiedLongestLine   = 5000 // A single line this long indicates minified code
+	minifiedMinimumSample = 1024 // Files shorter than this are never considered minified
+)
+
+/**
+ * @brief Validates a --generated mode.
+ */
+func parseGeneratedMode(mode string) (string, error) {
+	switch mode {
+	case generatedScan, generatedDownrank, generatedSkip:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown mode %q (want scan, downrank or skip)", mode)
+}
+
+/**
+ * @brief Explains why a blob looks generated.
+ * @param path The blob's repository path.
+ * @param content The blob's content.
+ * @param overrides Globs of paths that are never treated as generated.
+ * @return A short reason ("path", "marker" or "minified"), or "" for hand-written files.
+ */
+func generatedReason(path string, content []byte, overrides []string) string {
+	if matchAnyGlob(overrides, path) {
+		return ""
+	}
+	if matchAnyGlob(generatedPaths, path) {
+		return "path"
+	}
+	header := content
+	if len(header) > generatedHeaderBytes {
+		header = header[:generatedHeaderBytes]
+	}
+	for _, marker := range generatedMarkers {
+		if bytes.Contains(header, marker) {
+			return "marker"
+		}
+	}
+	if isMinified(content) {
+		return "minified"
+	}
+	return ""
+}
+
+/**
+ * @brief Reports whether content has the line shape of minified code.
+ */
+func isMinified(content []byte) bool {
+	sample := content
+	if len(sample) > minifiedSampleBytes {
+		sample = sample[:minifiedSampleBytes]
+	}
+	if len(sample) < minifiedMinimumSample {
+		return false
+	}
+	lines := bytes.Count(sample, []byte("\n")) + 1
+	longest := 0
+	for _, line := range bytes.Split(sample, []byte("\n")) {
+		if len(line) > longest {
+			longest = len(line)
+		}
+	}
+	return longest >= minifiedLongestLine || len(sample)/lines >= minifiedAverageLine
+}
+
+/**
+ * @brief Lowers the confidence of a finding from generated content.
+ * @param f The finding to down-rank.
+ * @param reason The reason returned by generatedReason.
+ */
+func downrankGenerated(f *finding, reason string) {
+	f.Confidence = "Low"
+	setMetadata(f, "generated", reason)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratedReason(t *testing.T) {
+	handWritten := strings.Repeat("const apiKey = process.env.API_KEY;\n", 100)
+	minified := strings.Repeat("var a=1,b=2;", 500)
+	for _, tc := range []struct {
+		path, content string
+		overrides     []string
+		want          string
+	}{
+		{"src/app.js", handWritten, nil, ""},
+		{"web/dist/app.js", handWritten, nil, "path"},
+		{"vendor/lib.min.js", handWritten, nil, "path"},
+		{"api/service.pb.go", handWritten, nil, "path"},
+		{"api/client.go", "// Code generated by mockgen. DO NOT EDIT.\n" + handWritten, nil, "marker"},
+		{"src/app.js", minified, nil, "minified"},
+		{"src/tiny.js", "var a=1;", nil, ""},
+		{"web/dist/app.js", handWritten, []string{"dist/"}, ""},
+	} {
+		if got := generatedReason(tc.path, []byte(tc.content), tc.overrides); got != tc.want {
+			t.Errorf("generatedReason(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParseGeneratedMode(t *testing.T) {
+	for _, mode := range []string{generatedScan, generatedDownrank, generatedSkip} {
+		if got, err := parseGeneratedMode(mode); err != nil || got != mode {
+			t.Errorf("parseGeneratedMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	if _, err := parseGeneratedMode("ignore"); err == nil {
+		t.Error("an unknown mode was accepted")
+	}
+}
+
+func TestDownrankGenerated(t *testing.T) {
+	f := &finding{Confidence: "High"}
+	downrankGenerated(f, "minified")
+	if f.Confidence != "Low" || f.Metadata["generated"] != "minified" {
+		t.Errorf("down-ranked finding: %+v", f)
+	}
+}
@@ -0,0 +1,71 @@
+/**
+ * @file git.go
+ * @brief Helpers for invoking git against the repository being analyzed.
+ *
+ * Every git invocation goes through repository.command so that the analyzer
+ * can be pointed at a repository other than the current directory, including
+ * bare repositories on a git server that have no working tree at all, and so
+ * that several repositories can be scanned concurrently in one process.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+/**
+ * @struct repository
+ * @brief Identifies one repository under analysis.
+ */
+type repository struct {
+	gitDir string // Path passed as --git-dir to git; empty means "discover from cwd"
+	label  string // Name added to findings in multi-repository sweeps ("" otherwise)
+
+	workers  int // Maximum concurrent scans for this repository (0 = run default)
+	priority int // Scheduling priority relative to other repositories (higher first)
+
+	ignoreRevs map[string]bool // Commits the walk skips (see ignorerevs.go; nil = none)
+	nesting    int             // Levels this repository is nested in the scanned one (see nested.go)
+}
+
+/**
+ * @brief Builds a git command for the repository.
+ * @param args The git subcommand and its arguments.
+ * @return A prepared *exec.Cmd that has not been started yet.
+ */
+func (r *repository) command(args ...string) *exec.Cmd {
+	if r.gitDir != "" {
+		args = append([]string{"--git-dir", r.gitDir}, args...)
+	}
+	return exec.Command("git", args...)
+}
+
+/**
+ * @brief Verifies that the configured location is a git repository.
+ * @return Whether the repository is bare, and an error if it is not a repository.
+ */
+func (r *repository) check() (bool, error) {
+	output, err := r.command("rev-parse", "--is-bare-repository").Output()
+	if err != nil {
+		if r.gitDir != "" {
+			return false, fmt.Errorf("%s is not a git repository", r.gitDir)
+		}
+		return false, fmt.Errorf("not inside a git repository")
+	}
+	return strings.TrimSpace(string(output)) == "true", nil
+}
+
+/**
+ * @brief Formats a repository label for stderr messages.
+ * @param label The repository name in sweeps ("" for none).
+ * @return "label: ", or "" when there is no label.
+ */
+func labelPrefix(label string) string {
+	if label == "" {
+		return ""
+	}
+	return label + ": "
+}
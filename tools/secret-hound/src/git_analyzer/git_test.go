@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestBareRepositoryIsScannable(t *testing.T) {
+	repo := newFixtureRepo(t)
+	commit := repo.commit("init", map[string]string{"config/app.env": "TOKEN=abc\n"})
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	if output, err := exec.Command("git", "clone", "-q", "--bare", repo.dir, bare).CombinedOutput(); err != nil {
+		t.Fatalf("git clone --bare: %v\n%s", err, output)
+	}
+	r := &repository{gitDir: bare}
+
+	if isBare, err := r.check(); err != nil || !isBare {
+		t.Fatalf("check() = %v, %v; want a bare repository", isBare, err)
+	}
+	blobs, err := getGitBlobs(r, 10, &commitCache{commits: make(map[string][]commitChange)}, mergePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 || blobs[0].path != "config/app.env" || blobs[0].commit != commit {
+		t.Fatalf("blobs of the bare repository: %+v", blobs)
+	}
+	content, err := readBlobContent(blobs[0])
+	if err != nil || string(content) != "TOKEN=abc\n" {
+		t.Errorf("content: %q, %v", content, err)
+	}
+}
+
+func TestRepositoryCheckRejectsNonRepository(t *testing.T) {
+	repo := newFixtureRepo(t)
+	if isBare, err := (&repository{gitDir: filepath.Join(repo.dir, ".git")}).check(); err != nil || isBare {
+		t.Errorf("check() on a checkout = %v, %v", isBare, err)
+	}
+	if _, err := (&repository{gitDir: t.TempDir()}).check(); err == nil {
+		t.Error("check() accepted an empty directory")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestGroupBySecretValues(t *testing.T) {
+	if g, err := newSecretGrouper("none"); g != nil || err != nil {
+		t.Errorf("none: %v, %v", g, err)
+	}
+	if g, err := newSecretGrouper("secret"); g == nil || err != nil {
+		t.Errorf("secret: %v, %v", g, err)
+	}
+	if _, err := newSecretGrouper("file"); err == nil {
+		t.Error("--group-by file was accepted")
+	}
+}
+
+func TestSecretsCollapseIntoOneFinding(t *testing.T) {
+	g, _ := newSecretGrouper(groupBySecret)
+	// Added in scheduling order, not output order.
+	for _, f := range []*finding{
+		{Commit: "c2", OriginalPath: "b.env", Line: 3, RuleID: "TOKEN", Match: "s3cr3t", Severity: "critical", Confidence: "Medium"},
+		{Commit: "c1", OriginalPath: "a.env", Line: 1, RuleID: "TOKEN", Match: "s3cr3t", Severity: "medium", Confidence: "High"},
+		{Commit: "c1", OriginalPath: "a.env", Line: 1, RuleID: "TOKEN", Match: "s3cr3t", Severity: "medium", Confidence: "High"},
+		{Commit: "c1", OriginalPath: "a.env", Line: 2, RuleID: "TOKEN", Match: "other", Severity: "low"},
+	} {
+		g.add(f)
+	}
+	grouped := g.collapse()
+	if len(grouped) != 2 {
+		t.Fatalf("%d groups, want 2", len(grouped))
+	}
+	rep := grouped[0]
+	if rep.Match != "s3cr3t" || rep.OriginalPath != "a.env" || rep.Commit != "c1" {
+		t.Errorf("representative %+v, want the first occurrence", rep)
+	}
+	if rep.Severity != "critical" || rep.Confidence != "High" {
+		t.Errorf("severity %q confidence %q, want the highest seen", rep.Severity, rep.Confidence)
+	}
+	want := []occurrence{{Commit: "c1", Path: "a.env", Line: 1}, {Commit: "c2", Path: "b.env", Line: 3}}
+	if !reflect.DeepEqual(rep.Occurrences, want) {
+		t.Errorf("occurrences %+v, want %+v", rep.Occurrences, want)
+	}
+	if grouped[1].Match != "other" || len(grouped[1].Occurrences) != 1 {
+		t.Errorf("second group %+v", grouped[1])
+	}
+}
+
+func TestOccurrencesRoundTripInProto(t *testing.T) {
+	want := &finding{SchemaVersion: findingSchemaVersion, RuleID: "TOKEN", Match: "s3cr3t",
+		Occurrences: []occurrence{{Repository: "billing", Commit: "c1", Path: "a.env", Line: 1, Column: 7}, {Commit: "c2", Path: "b.env", Line: 3}}}
+	var out bytes.Buffer
+	fw, _ := newFindingWriter(&out, "proto")
+	fw.write(want)
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := readDelimited(bufio.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &finding{}
+	if err := unmarshalProto(msg, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The test binary doubles as the analyzer and as a stub core scanner, so the
+// whole pipeline (flags, git walk, core protocol, enrichment, output) runs in
+// end-to-end tests without a C++ build. testRoleEnv selects the role of a
+// re-executed test binary.
+const testRoleEnv = "GIT_ANALYZER_TEST_ROLE"
+
+// stubSecret is what the stub core reports: STUB_SECRET_ and at least 8 alphanumerics.
+var stubSecret = regexp.MustCompile(`STUB_SECRET_[A-Za-z0-9]{8,}`)
+
+// stubCrash makes the stub core fail on a blob, like a core crash.
+const stubCrash = "STUB_CRASH"
+
+// stubInputsEnv names a file the stub core appends the size of every input it scans to.
+const stubInputsEnv = "GIT_ANALYZER_TEST_CORE_INPUTS"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(testRoleEnv) {
+	case "analyzer":
+		// The core scanner the analyzer starts is this binary again.
+		os.Setenv(testRoleEnv, "core")
+		os.Args = append([]string{"git_analyzer"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	case "core":
+		os.Exit(runStubCore(os.Args[1:], os.Stdin, os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+/**
+ * @brief The stub core scanner: speaks the core protocol and reports every stubSecret.
+ * @return The exit code.
+ */
+func runStubCore(args []string, stdin io.Reader, stdout io.Writer) int {
+	if len(args) > 0 && args[0] == "--capabilities" {
+		info, _ := json.Marshal(coreInfo{Name: "stub-core", Version: "test", Protocol: coreProtocol,
+			Capabilities: []string{"scan-file", "stdin", "rules"}})
+		fmt.Fprintln(stdout, string(info))
+		return 0
+	}
+	if len(args) < 2 || args[0] != "--scan-file" {
+		fmt.Fprintln(os.Stderr, "stub core: usage: --scan-file <path|-> [--rules <file>]")
+		return 2
+	}
+	var content []byte
+	var err error
+	if args[1] == "-" {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stub core: %v\n", err)
+		return 1
+	}
+	if inputs := os.Getenv(stubInputsEnv); inputs != "" {
+		if file, err := os.OpenFile(inputs, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err == nil {
+			fmt.Fprintln(file, len(content))
+			file.Close()
+		}
+	}
+	if bytes.Contains(content, []byte(stubCrash)) {
+		fmt.Fprintln(os.Stderr, "stub core: crashing as asked")
+		return 70
+	}
+	encoder := json.NewEncoder(stdout)
+	for i, line := range strings.Split(string(content), "\n") {
+		for _, match := range stubSecret.FindAllString(line, -1) {
+			encoder.Encode(map[string]interface{}{
+				"file":        args[1],
+				"line":        i + 1,
+				"rule_id":     "STUB_SECRET",
+				"description": "Planted test secret",
+				"match":       match,
+				"entropy":     4.5,
+				"confidence":  "High",
+			})
+		}
+	}
+	return 0
+}
+
+/**
+ * @struct scanResult
+ * @brief What an analyzer run on a fixture wrote.
+ */
+type scanResult struct {
+	findings []*finding
+	stderr   string
+	exitCode int
+}
+
+/**
+ * @brief Runs the analyzer on the fixture with the stub core.
+ * @param args Options; the core path and a depth of 1000 commits are appended.
+ * @return The findings and stderr; the test fails if the output is not JSON Lines.
+ */
+func (r *fixtureRepo) scan(args ...string) scanResult {
+	r.t.Helper()
+	args = append([]string{"--tmp-dir", r.t.TempDir()}, args...)
+	stdout, stderr, exitCode := runAnalyzer(r.t, r.dir, append(args, os.Args[0], "1000")...)
+	result := scanResult{stderr: stderr, exitCode: exitCode}
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		f := &finding{}
+		if err := json.Unmarshal(scanner.Bytes(), f); err != nil {
+			r.t.Fatalf("output line is not a finding: %v\n%s", err, scanner.Text())
+		}
+		result.findings = append(result.findings, f)
+	}
+	return result
+}
+
+/**
+ * @brief Returns the sizes of the inputs the stub core scanned, with the
+ * given scan options; see stubInputsEnv.
+ */
+func (r *fixtureRepo) scanInputs(args ...string) (scanResult, []int) {
+	r.t.Helper()
+	inputs := filepath.Join(r.t.TempDir(), "core-inputs")
+	r.t.Setenv(stubInputsEnv, inputs)
+	result := r.scan(args...)
+	data, _ := os.ReadFile(inputs)
+	var sizes []int
+	for _, field := range strings.Fields(string(data)) {
+		size, _ := strconv.Atoi(field)
+		sizes = append(sizes, size)
+	}
+	return result, sizes
+}
+
+/**
+ * @brief Runs the analyzer (this test binary in the analyzer role) in a directory.
+ * @param args The complete command line, including positional arguments.
+ * @return Its stdout, its stderr and its exit code.
+ */
+func runAnalyzer(t *testing.T, dir string, args ...string) ([]byte, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), append(fixtureEnv, testRoleEnv+"=analyzer")...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Fatalf("running the analyzer: %v", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	return stdout.Bytes(), stderr.String(), exitCode
+}
+
+/**
+ * @brief Returns the stub core's findings as sorted "commit path:line match" strings.
+ */
+func (s scanResult) planted() []string {
+	var found []string
+	for _, f := range s.findings {
+		if f.RuleID == "STUB_SECRET" {
+			found = append(found, fmt.Sprintf("%s %s:%d %s", f.Commit, f.OriginalPath, f.Line, f.Match))
+		}
+	}
+	sort.Strings(found)
+	return found
+}
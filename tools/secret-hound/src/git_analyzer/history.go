@@ -0,0 +1,324 @@
+/**
+ * @file history.go
+ * @brief Walks commit history through a cache of per-commit changes.
+ *
+ * Listing the files a commit changed is the expensive part of a history walk,
+ * and the answer never changes for a given commit hash. Every walk therefore
+ * goes through a commitCache shared by the whole process: scanning several
+ * branches or forks of the same repository only diffs each commit once.
+ * With --commit-cache the cache is also persisted as JSON, so repeated runs
+ * over the same repository only diff the commits that are new since last time.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// commitCacheFormat is bumped whenever the meaning of cached changes changes,
+// which invalidates caches written by older versions.
+const commitCacheFormat = 3
+
+/**
+ * @struct commitChange
+ * @brief A file added or modified by a commit.
+ */
+type commitChange struct {
+	Path string `json:"path"`
+	Blob string `json:"blob"`
+	Mode string `json:"mode"` // Octal git object mode, e.g. "100644"
+}
+
+/**
+ * @struct commitCache
+ * @brief Changes of already-walked commits, keyed by commit hash.
+ */
+type commitCache struct {
+	mu      sync.Mutex
+	path    string                    // File the cache is persisted to ("" = memory only)
+	commits map[string][]commitChange // Commit hash -> files it added or modified
+	dirty   bool                      // Whether commits were added since loading
+}
+
+// commitCacheFile is the on-disk layout of a persisted cache.
+type commitCacheFile struct {
+	Format  int                       `json:"format"`
+	Commits map[string][]commitChange `json:"commits"`
+}
+
+/**
+ * @brief Creates a commit cache, loading it from path if the file exists.
+ * A cache written in another format is discarded rather than trusted.
+ * @param path The persistence file ("" keeps the cache in memory only).
+ * @return The cache and an error if an existing file could not be read.
+ */
+func loadCommitCache(path string) (*commitCache, error) {
+	c := &commitCache{path: path, commits: make(map[string][]commitChange)}
+	if path == "" {
+		return c, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file commitCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if file.Format == commitCacheFormat && file.Commits != nil {
+		c.commits = file.Commits
+	}
+	return c, nil
+}
+
+/**
+ * @brief Returns the cached changes of a commit.
+ */
+func (c *commitCache) lookup(commit string) ([]commitChange, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes, ok := c.commits[commit]
+	return changes, ok
+}
+
+/**
+ * @brief Records the changes of a commit.
+ */
+func (c *commitCache) store(commit string, changes []commitChange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if changes == nil {
+		changes = []commitChange{} // Distinguish "no changes" from "not cached" on disk
+	}
+	c.commits[commit] = changes
+	c.dirty = true
+}
+
+/**
+ * @brief Writes the cache to its file if it has one and it changed.
+ * The file is replaced atomically so an interrupted run never corrupts it.
+ * @return An error if the file could not be written.
+ */
+func (c *commitCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(commitCacheFile{Format: commitCacheFormat, Commits: c.commits})
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+/**
+ * @struct commitRef
+ * @brief A commit of the walk and its parents.
+ */
+type commitRef struct {
+	hash    string
+	parents []string
+}
+
+/**
+ * @brief Lists commits with `git rev-list`, newest first.
+ * @param repo The repository to walk.
+ * @param firstParent Follow only the first parent of merge commits.
+ * @param revs Revisions and limits passed to rev-list, e.g. "--max-count=10", "HEAD".
+ * @return The commits.
+ */
+func listCommits(repo *repository, firstParent bool, revs ...string) ([]commitRef, error) {
+	args := []string{"rev-list", "--parents"}
+	if firstParent {
+		args = append(args, "--first-parent")
+	}
+	output, err := repo.command(append(args, revs...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseRevList(output), nil
+}
+
+// parseRevList parses `git rev-list --parents` output.
+func parseRevList(output []byte) []commitRef {
+	var commits []commitRef
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			commits = append(commits, commitRef{hash: fields[0], parents: fields[1:]})
+		}
+	}
+	return commits
+}
+
+/**
+ * @brief Retrieves the file blobs added or modified within the specified commit depth.
+ * Commits already in the cache are not diffed again.
+ * @param repo The repository to walk.
+ * @param depth The maximum number of commits to look back.
+ * @param cache The commit cache shared by all walks.
+ * @param merges Which commits are walked and how merge commits are diffed.
+ * @return A slice of fileBlob structs and an error if one occurred.
+ */
+func getGitBlobs(repo *repository, depth int, cache *commitCache, merges mergePolicy) ([]fileBlob, error) {
+	if repo.command("rev-parse", "--verify", "-q", "HEAD").Run() != nil {
+		return nil, nil // No commits yet
+	}
+	commits, err := listCommits(repo, merges.firstParent, fmt.Sprintf("--max-count=%d", depth), "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return collectCommitBlobs(repo, commits, cache, merges)
+}
+
+/**
+ * @brief Collects the blobs changed by the given commits, diffing uncached ones.
+ * Commits in the repository's ignore-revs set are skipped.
+ * @param repo The repository.
+ * @param commits The commits to collect, newest first.
+ * @param cache The commit cache shared by all walks.
+ * @param merges How merge commits are diffed.
+ * @return A slice of fileBlob structs and an error if one occurred.
+ */
+func collectCommitBlobs(repo *repository, commits []commitRef, cache *commitCache, merges mergePolicy) ([]fileBlob, error) {
+	if len(repo.ignoreRevs) > 0 {
+		kept := commits[:0:0]
+		for _, commit := range commits {
+			if !repo.ignoreRevs[commit.hash] {
+				kept = append(kept, commit)
+			}
+		}
+		if skipped := len(commits) - len(kept); skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Go analyzer: %sskipped %d commit(s) on the ignore-revs list\n", labelPrefix(repo.label), skipped)
+		}
+		commits = kept
+	}
+
+	var missing []commitRef
+	for _, commit := range commits {
+		if _, ok := cache.lookup(merges.cacheKey(commit)); !ok {
+			missing = append(missing, commit)
+		}
+	}
+	if len(missing) > 0 {
+		changes, err := listCommitChanges(repo, missing, merges)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range missing {
+			cache.store(merges.cacheKey(commit), changes[commit.hash])
+		}
+	}
+
+	var blobs []fileBlob
+	gitlinks := 0
+	for _, commit := range commits {
+		changes, _ := cache.lookup(merges.cacheKey(commit))
+		for _, change := range changes {
+			if change.Mode == modeGitlink {
+				gitlinks++ // A submodule commit, not an object of this repository
+				continue
+			}
+			blobs = append(blobs, fileBlob{hash: change.Blob, path: change.Path, commit: commit.hash, repo: repo, mode: change.Mode})
+		}
+	}
+	if gitlinks > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %sskipped %d submodule (gitlink) entries\n", labelPrefix(repo.label), gitlinks)
+	}
+	return blobs, nil
+}
+
+/**
+ * @brief Lists the files each of the given commits added or modified.
+ * The commits are piped through `git diff-tree --stdin -r -z`, whose raw
+ * output carries the new blob hash and mode of every changed path directly,
+ * so no per-file lookups are needed and unusual paths (spaces, quotes,
+ * newlines) survive intact. Root commits are diffed against the empty tree;
+ * merge commits are diffed as the merge policy dictates.
+ * @param repo The repository.
+ * @param commits The commits to inspect.
+ * @param merges How merge commits are diffed.
+ * @return The changes of each commit.
+ */
+func listCommitChanges(repo *repository, commits []commitRef, merges mergePolicy) (map[string][]commitChange, error) {
+	var input strings.Builder
+	for _, commit := range commits {
+		for _, line := range merges.diffRequests(commit) {
+			input.WriteString(line + "\n")
+		}
+	}
+	cmd := repo.command("diff-tree", "--stdin", "-r", "-z", "--root", "--no-renames", "-c")
+	cmd.Stdin = strings.NewReader(input.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseDiffTree(output), nil
+}
+
+/**
+ * @brief Parses `git diff-tree --stdin -r -z -c` output.
+ * The output is a NUL-separated sequence of commit ids, each followed by raw
+ * diff records and their paths. A record against one parent reads
+ * ":<old mode> <new mode> <old hash> <new hash> <status>"; a combined record
+ * for a merge has one colon, mode, hash and status letter per parent.
+ * A path reported against several parents is listed once.
+ * @param output The raw output.
+ * @return The added or modified files of each commit.
+ */
+func parseDiffTree(output []byte) map[string][]commitChange {
+	changes := make(map[string][]commitChange)
+	seen := make(map[string]bool)
+	tokens := strings.Split(string(output), "\x00")
+	var commit string
+	for i := 0; i < len(tokens); i++ {
+		token := strings.TrimPrefix(tokens[i], "\n")
+		if token == "" {
+			continue
+		}
+		if token[0] != ':' {
+			commit = token
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+		i++
+		path := tokens[i]
+		parents := len(token) - len(strings.TrimLeft(token, ":"))
+		fields := strings.Fields(token[parents:])
+		if len(fields) < 2*parents+3 {
+			continue
+		}
+		mode, hash, status := fields[parents], fields[2*parents+1], fields[2*parents+2]
+		// We only care about files added, modified or type-changed in the result.
+		if strings.Trim(hash, "0") == "" || !strings.ContainsAny(status, "AMT") {
+			continue
+		}
+		key := commit + "\x00" + path + "\x00" + hash
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		changes[commit] = append(changes[commit], commitChange{Path: path, Blob: hash, Mode: mode})
+	}
+	return changes
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitCacheAvoidsDiffingKnownCommits(t *testing.T) {
+	fx := newFixtureRepo(t)
+	first := fx.commit("first", map[string]string{"a.txt": "a"})
+	t.Chdir(fx.dir)
+	repo := &repository{}
+	path := filepath.Join(t.TempDir(), "commits.json")
+
+	cache, err := loadCommitCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getGitBlobs(repo, 10, cache, mergePolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached entry is trusted as is: the doctored change proves the commit was not diffed again.
+	reloaded, err := loadCommitCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.store(first, []commitChange{{Path: "cached.txt", Blob: "0123"}})
+	second := fx.commit("second", map[string]string{"b.txt": "b"})
+
+	blobs, err := getGitBlobs(repo, 10, reloaded, mergePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 || blobs[0].commit != second || blobs[0].path != "b.txt" || blobs[1].path != "cached.txt" {
+		t.Errorf("blobs: %+v", blobs)
+	}
+	if changes, ok := reloaded.lookup(second); !ok || len(changes) != 1 {
+		t.Errorf("the new commit was not cached: %+v", changes)
+	}
+}
+
+func TestCommitCacheFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commits.json")
+	if err := os.WriteFile(path, []byte(`{"format": 0, "commits": {"abc": [{"path": "x", "blob": "y"}]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := loadCommitCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.lookup("abc"); ok {
+		t.Error("a cache in an older format was trusted")
+	}
+
+	cache.store("empty", nil)
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := loadCommitCache(path)
+	if changes, ok := reloaded.lookup("empty"); !ok || len(changes) != 0 {
+		t.Errorf("a commit without changes was not persisted: %v, %v", changes, ok)
+	}
+
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := loadCommitCache(path); err == nil {
+		t.Error("a corrupt cache file was accepted")
+	}
+}
+
+func TestListCommitChangesKeepsUnusualPaths(t *testing.T) {
+	fx := newFixtureRepo(t)
+	root := fx.commit("root", map[string]string{
+		"plain.txt":          "a",
+		"with space.txt":     "b",
+		"quote\"d.txt":       "c",
+		"new\nline.txt":      "d",
+		"dir/nested/app.env": "e",
+	})
+	fx.git("rm", "-q", "plain.txt")
+	changed := fx.commit("modify and delete", map[string]string{"with space.txt": "b2"})
+	t.Chdir(fx.dir)
+
+	changes, err := listCommitChanges(&repository{}, []commitRef{{hash: changed}, {hash: root}}, mergePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := func(commit string) map[string]string {
+		out := make(map[string]string)
+		for _, c := range changes[commit] {
+			out[c.Path] = c.Mode
+		}
+		return out
+	}
+	if got := paths(root); len(got) != 5 || got["new\nline.txt"] != "100644" || got["quote\"d.txt"] == "" || got["dir/nested/app.env"] == "" {
+		t.Errorf("root commit changes: %q", got)
+	}
+	if got := paths(changed); len(got) != 1 || got["with space.txt"] == "" {
+		t.Errorf("deletions must be ignored: %q", got)
+	}
+	blob := fx.git("rev-parse", changed+":with space.txt")
+	if changes[changed][0].Blob != blob {
+		t.Errorf("blob %s, want %s", changes[changed][0].Blob, blob)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/**
+ * @brief Walks the fixture and returns the sorted paths found in each commit.
+ */
+func (r *fixtureRepo) walk(merges mergePolicy) map[string][]string {
+	r.t.Helper()
+	cache, _ := loadCommitCache("")
+	blobs, err := getGitBlobs(&repository{gitDir: filepath.Join(r.dir, ".git")}, 100, cache, merges)
+	if err != nil {
+		r.t.Fatalf("getGitBlobs: %v", err)
+	}
+	paths := make(map[string][]string)
+	for _, blob := range blobs {
+		paths[blob.commit] = append(paths[blob.commit], blob.path)
+	}
+	for commit := range paths {
+		sort.Strings(paths[commit])
+	}
+	return paths
+}
+
+func TestRootCommitScansFullTree(t *testing.T) {
+	repo := newFixtureRepo(t)
+	root := repo.commit("root", map[string]string{
+		"README":          "hello\n",
+		"config/app.env":  "TOKEN=abc\n",
+		"deep/a/b/c.json": "{}\n",
+	})
+
+	got := repo.walk(mergePolicy{})[root]
+	want := []string{"README", "config/app.env", "deep/a/b/c.json"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("root commit paths = %v, want %v", got, want)
+	}
+}
+
+func TestUnrelatedRootsAreEachScanned(t *testing.T) {
+	repo := newFixtureRepo(t)
+	first := repo.commit("first root", map[string]string{"one.txt": "1\n"})
+	repo.git("checkout", "-q", "--orphan", "other")
+	repo.git("rm", "-q", "-rf", ".")
+	second := repo.commit("second root", map[string]string{"two.txt": "2\n"})
+	repo.git("checkout", "-q", "main")
+	repo.git("merge", "-q", "--allow-unrelated-histories", "--no-edit", "other")
+
+	paths := repo.walk(mergePolicy{})
+	if got := paths[first]; len(got) != 1 || got[0] != "one.txt" {
+		t.Errorf("first root paths = %v, want [one.txt]", got)
+	}
+	if got := paths[second]; len(got) != 1 || got[0] != "two.txt" {
+		t.Errorf("second root paths = %v, want [two.txt]", got)
+	}
+}
+
+func TestOctopusMerge(t *testing.T) {
+	repo := newFixtureRepo(t)
+	root := repo.commit("root", map[string]string{"base.txt": "base\n"})
+	branches := map[string]string{}
+	for _, name := range []string{"a", "b", "c"} {
+		repo.git("checkout", "-q", "-b", name, root)
+		branches[name] = repo.commit("branch "+name, map[string]string{name + ".txt": name + "\n"})
+	}
+	repo.git("checkout", "-q", "main")
+	mainline := repo.commit("mainline", map[string]string{"main.txt": "main\n"})
+
+	// An octopus merge that also adds a file of its own (an "evil" merge).
+	repo.git("merge", "-q", "--no-commit", "a", "b", "c")
+	repo.write(map[string]string{"evil.txt": "added in the merge\n"})
+	repo.git("add", "evil.txt")
+	repo.git("commit", "-q", "--no-edit")
+	merge := repo.git("rev-parse", "HEAD")
+	if parents := strings.Fields(repo.git("rev-list", "--parents", "-n1", merge)); len(parents) != 5 {
+		t.Fatalf("fixture merge has %d parents, want 4", len(parents)-1)
+	}
+
+	tests := []struct {
+		name          string
+		merges        mergePolicy
+		mergePaths    string
+		walksBranches bool
+	}{
+		{"combined", mergePolicy{}, "evil.txt", true},
+		{"first parent", mergePolicy{firstParent: true}, "a.txt,b.txt,c.txt,evil.txt", false},
+		{"all parents", mergePolicy{allParents: true}, "a.txt,b.txt,c.txt,evil.txt,main.txt", true},
+		{"first parent, all parents", mergePolicy{firstParent: true, allParents: true}, "a.txt,b.txt,c.txt,evil.txt,main.txt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := repo.walk(tt.merges)
+			if got := strings.Join(paths[merge], ","); got != tt.mergePaths {
+				t.Errorf("merge paths = %s, want %s", got, tt.mergePaths)
+			}
+			if got := strings.Join(paths[mainline], ","); got != "main.txt" {
+				t.Errorf("mainline paths = %s, want main.txt", got)
+			}
+			if got := strings.Join(paths[root], ","); got != "base.txt" {
+				t.Errorf("root paths = %s, want base.txt", got)
+			}
+			for name, commit := range branches {
+				_, walked := paths[commit]
+				if walked != tt.walksBranches {
+					t.Errorf("branch %s walked = %v, want %v", name, walked, tt.walksBranches)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDiffTreeCombinedRecords(t *testing.T) {
+	zero := strings.Repeat("0", 40)
+	blob := strings.Repeat("b", 40)
+	output := strings.Join([]string{
+		strings.Repeat("c", 40),
+		// Added relative to all three parents of an octopus merge.
+		":::000000 000000 000000 100644 " + zero + " " + zero + " " + zero + " " + blob + " AAA", "new.txt",
+		// Deleted by the merge: nothing to scan.
+		":::100644 100644 100644 000000 " + blob + " " + blob + " " + blob + " " + zero + " DDD", "gone.txt",
+		"",
+	}, "\x00")
+
+	changes := parseDiffTree([]byte(output))[strings.Repeat("c", 40)]
+	if len(changes) != 1 || changes[0].Path != "new.txt" || changes[0].Blob != blob || changes[0].Mode != "100644" {
+		t.Errorf("changes = %+v, want one added new.txt", changes)
+	}
+}
@@ -0,0 +1,266 @@
+/**
+ * @file hook.go
+ * @brief Server and client push hooks (--hook pre-receive, --hook pre-push)
+ *        and the rejection message shown to the pusher (--reject-template).
+ *
+ * As a pre-receive hook on the Git server, or a pre-push hook on a developer
+ * machine, the analyzer reads the ref updates git passes on stdin and scans
+ * only the commits being pushed: those reachable from the new values and from
+ * no existing ref (pre-receive) or remote-tracking ref (pre-push). Deleted
+ * refs are ignored. Any finding of at least --fail-on severity (low unless
+ * given, so allowlisted examples pass) rejects the push with the policy exit
+ * status. Findings are not written unless --output is given: for a
+ * pre-receive hook, stdout goes back to the pusher.
+ *
+ * The pusher instead sees a rejection message on stderr, which git shows as
+ * "remote:" lines. The built-in message lists the findings with the secrets
+ * masked and comes from the message catalog (see i18n.go). Administrators
+ * can replace it with a Go text/template file (--reject-template) to add
+ * their own remediation links, support channel or bypass instructions. The
+ * template is executed with a rejection value; the function msg looks up a
+ * catalog message, e.g. {{msg "reject.header" "count" .Count}}. An audited
+ * break-glass bypass can let a push with findings through (see bypass.go).
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Values of --hook.
+const (
+	hookPreReceive = "pre-receive"
+	hookPrePush    = "pre-push"
+)
+
+// defaultRejectTemplate is the rejection message without --reject-template.
+const defaultRejectTemplate = `{{msg "reject.header" "count" .Count}}
+{{range .Findings}}  {{.ShortCommit}}  {{.Path}}:{{.Line}}  {{.RuleID}}  {{.Secret}}
+{{end}}
+{{msg "reject.remediation"}}
+{{if .Bypass}}{{msg "reject.bypass_hint" "trailer" .Bypass}}
+{{end}}`
+
+/**
+ * @struct refUpdate
+ * @brief One ref update of a push, as read from the hook's stdin.
+ */
+type refUpdate struct {
+	ref    string // The ref being updated, e.g. refs/heads/main
+	oldRev string // Its current value on the receiving side (zeros if it is created)
+	newRev string // Its pushed value (zeros if it is deleted)
+}
+
+/**
+ * @brief Reads the ref updates git passes to a hook on stdin.
+ * pre-receive gets "<old> <new> <ref>" lines and pre-push gets
+ * "<local ref> <local sha> <remote ref> <remote sha>" lines.
+ * @param hook hookPreReceive or hookPrePush.
+ * @param r The hook's stdin.
+ * @return The updates, and an error for an unknown hook or a malformed line.
+ */
+func parseHookInput(hook string, r io.Reader) ([]refUpdate, error) {
+	if hook != hookPreReceive && hook != hookPrePush {
+		return nil, fmt.Errorf("unknown hook %q (want %s or %s)", hook, hookPreReceive, hookPrePush)
+	}
+	var updates []refUpdate
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 0:
+			continue
+		case hook == hookPreReceive && len(fields) == 3:
+			updates = append(updates, refUpdate{ref: fields[2], oldRev: fields[0], newRev: fields[1]})
+		case hook == hookPrePush && len(fields) == 4:
+			updates = append(updates, refUpdate{ref: fields[2], oldRev: fields[3], newRev: fields[1]})
+		default:
+			return nil, fmt.Errorf("malformed %s input line %q", hook, scanner.Text())
+		}
+	}
+	return updates, scanner.Err()
+}
+
+// zeroRev reports whether a hash is git's all-zero "no object" value.
+func zeroRev(rev string) bool {
+	return strings.Trim(rev, "0") == ""
+}
+
+/**
+ * @brief Returns the rev-list revisions of the commits a push introduces.
+ * @return The pushed values followed by the exclusions, or nil if the push only deletes refs.
+ */
+func hookRevs(hook string, updates []refUpdate) []string {
+	var revs []string
+	for _, u := range updates {
+		if !zeroRev(u.newRev) {
+			revs = append(revs, u.newRev)
+		}
+	}
+	if len(revs) == 0 {
+		return nil
+	}
+	if hook == hookPrePush {
+		return append(revs, "--not", "--remotes")
+	}
+	// The pushed objects are quarantined until the hook accepts them, so no
+	// existing ref reaches the new commits yet.
+	return append(revs, "--not", "--all")
+}
+
+/**
+ * @struct rejection
+ * @brief What a --reject-template is executed with.
+ */
+type rejection struct {
+	Hook     string            // pre-receive or pre-push
+	Refs     []string          // The refs being pushed
+	Count    int               // Number of findings
+	Commits  int               // Number of commits with findings
+	Findings []rejectedFinding // The findings, by commit and path
+	Rules    map[string]int    // Number of findings per rule id
+	Bypass   string            // The trailer of a break-glass bypass ("" if bypasses are off)
+}
+
+/**
+ * @struct rejectedFinding
+ * @brief A finding as shown in a rejection message; the secret is masked.
+ */
+type rejectedFinding struct {
+	Commit      string
+	ShortCommit string
+	Path        string
+	Line        int
+	RuleID      string
+	Description string
+	Severity    string
+	Secret      string
+	Policy      string // The policy rule that failed, e.g. "--fail-on low"
+	Fingerprint string // Hash of rule and secret, as in the scan history
+}
+
+/**
+ * @struct hookSession
+ * @brief The push being checked and the findings that reject it.
+ */
+type hookSession struct {
+	hook     string
+	updates  []refUpdate
+	tmpl     *template.Template
+	bypass   *breakGlass // Break-glass tickets of the push (nil = --allow-bypass off)
+	mu       sync.Mutex
+	findings []rejectedFinding
+}
+
+/**
+ * @brief Prepares a hook run.
+ * @param templateFile A text/template file for the rejection message ("" = built in).
+ * @return The session, and an error if the template cannot be read or parsed.
+ */
+func newHookSession(hook string, updates []refUpdate, templateFile string) (*hookSession, error) {
+	text := defaultRejectTemplate
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := parseRejectTemplate(text)
+	if err != nil {
+		return nil, err
+	}
+	return &hookSession{hook: hook, updates: updates, tmpl: tmpl}, nil
+}
+
+// parseRejectTemplate parses a rejection message template with its functions.
+func parseRejectTemplate(text string) (*template.Template, error) {
+	return template.New("rejection").Funcs(template.FuncMap{
+		"msg":   func(key string, params ...interface{}) string { return messages.text(key, params...) },
+		"upper": strings.ToUpper,
+		"join":  strings.Join,
+	}).Parse(text)
+}
+
+/**
+ * @brief Keeps a written finding for the rejection message if it fails the push.
+ * @param f The finding, after the policies were applied.
+ */
+func (h *hookSession) record(f *finding) {
+	if h == nil || f.Metadata["policy_failed"] == "" {
+		return
+	}
+	rf := rejectedFinding{
+		Commit:      f.Commit,
+		ShortCommit: shortCommit(f.Commit),
+		Path:        f.OriginalPath,
+		Line:        f.Line,
+		RuleID:      f.RuleID,
+		Description: f.Description,
+		Severity:    findingSeverity(f),
+		Secret:      maskSecret(f.Match),
+		Policy:      f.Metadata["policy_failed"],
+		Fingerprint: secretFingerprint(f),
+	}
+	h.mu.Lock()
+	h.findings = append(h.findings, rf)
+	h.mu.Unlock()
+}
+
+/**
+ * @brief Writes the rejection message if any finding fails the push.
+ * A template that fails to execute is reported and replaced by the built-in one.
+ * @param w Where the pusher sees it (stderr).
+ */
+func (h *hookSession) reject(w io.Writer) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.findings) == 0 {
+		return
+	}
+	sort.Slice(h.findings, func(i, j int) bool {
+		x, y := h.findings[i], h.findings[j]
+		if x.Commit != y.Commit {
+			return x.Commit < y.Commit
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Line < y.Line
+	})
+	data := rejection{Hook: h.hook, Count: len(h.findings), Findings: h.findings, Rules: make(map[string]int)}
+	commits := make(map[string]bool)
+	for _, f := range h.findings {
+		commits[f.Commit] = true
+		data.Rules[f.RuleID]++
+	}
+	data.Commits = len(commits)
+	if h.bypass != nil {
+		data.Bypass = bypassTrailer
+	}
+	for _, u := range h.updates {
+		if !zeroRev(u.newRev) {
+			data.Refs = append(data.Refs, u.ref)
+		}
+	}
+
+	var message strings.Builder
+	if err := h.tmpl.Execute(&message, data); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: --reject-template: %v; using the built-in message\n", err)
+		message.Reset()
+		builtin, _ := parseRejectTemplate(defaultRejectTemplate)
+		builtin.Execute(&message, data)
+	}
+	io.WriteString(w, message.String())
+}
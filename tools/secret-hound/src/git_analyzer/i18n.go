@@ -0,0 +1,212 @@
+/**
+ * @file i18n.go
+ * @brief Message catalogs for the text people read (--lang, --messages).
+ *
+ * Findings are data and stay in English, but the text around them is read by
+ * developers: the pretty console report, the HTML report of ui/reporter.py
+ * and the message shown when a push is rejected. Enterprise customers want
+ * that text in their developers' language, so it comes from a catalog
+ * instead of the code.
+ *
+ * Catalogs are JSON objects in locales/<language>.json, compiled into the
+ * binary and read from the same directory by the Python reporter. A value is
+ * either a string or, for text with a count, an object with "one" and
+ * "other" forms. Placeholders are written {name} and filled by name, so a
+ * translation may reorder them. The language is --lang, or the POSIX locale
+ * (LC_ALL, LC_MESSAGES, LANG) if it is not given; "de_AT.UTF-8" tries de-AT,
+ * then de, then English. Keys missing from a translation fall back to
+ * English, and --messages adds an organization's own catalog on top of the
+ * selected one, for wording that no translation ships with.
+ */
+
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// defaultLanguage is the language of the built-in text, and the last fallback.
+const defaultLanguage = "en"
+
+/**
+ * @struct catalogEntry
+ * @brief One message: a single form, or the forms for a count of one and any other count.
+ */
+type catalogEntry struct {
+	One   string `json:"one"`
+	Other string `json:"other"`
+}
+
+// UnmarshalJSON accepts a plain string as a message without plural forms.
+func (e *catalogEntry) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		e.One, e.Other = text, text
+		return nil
+	}
+	type forms catalogEntry
+	var f forms
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("want a string or {\"one\", \"other\"}")
+	}
+	if f.Other == "" {
+		return fmt.Errorf("plural forms without \"other\"")
+	}
+	*e = catalogEntry(f)
+	if e.One == "" {
+		e.One = e.Other
+	}
+	return nil
+}
+
+/**
+ * @struct messageCatalog
+ * @brief The messages of the selected language, with the fallbacks merged in.
+ */
+type messageCatalog struct {
+	lang     string
+	messages map[string]catalogEntry
+}
+
+// messages is the catalog of the run; set from --lang and --messages in main.
+var messages = mustDefaultCatalog()
+
+// mustDefaultCatalog loads the built-in English catalog, which the build guarantees.
+func mustDefaultCatalog() *messageCatalog {
+	c, err := loadCatalog(defaultLanguage, "")
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+/**
+ * @brief Loads the catalog of a language, falling back to its base language and English.
+ * @param lang A language tag (de, pt-BR) or POSIX locale (de_AT.UTF-8); "" uses the environment.
+ * @param overrides A JSON catalog file whose messages replace the built-in ones ("" for none).
+ * @return The catalog, and an error if the override file cannot be read. An
+ * unknown language is not an error: the text is then in English.
+ */
+func loadCatalog(lang, overrides string) (*messageCatalog, error) {
+	if lang == "" {
+		lang = environmentLanguage()
+	}
+	c := &messageCatalog{lang: defaultLanguage, messages: make(map[string]catalogEntry)}
+	candidates := languageCandidates(lang)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		data, err := embeddedLocales.ReadFile(path.Join("locales", candidates[i]+".json"))
+		if err != nil {
+			continue // No translation for this tag
+		}
+		if err := c.merge(data); err != nil {
+			return nil, fmt.Errorf("built-in catalog %s: %v", candidates[i], err)
+		}
+		c.lang = candidates[i]
+	}
+	if overrides != "" {
+		data, err := os.ReadFile(overrides)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.merge(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", overrides, err)
+		}
+	}
+	return c, nil
+}
+
+// merge adds the messages of a JSON catalog, replacing those with the same key.
+func (c *messageCatalog) merge(data []byte) error {
+	var entries map[string]catalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for key, entry := range entries {
+		c.messages[key] = entry
+	}
+	return nil
+}
+
+/**
+ * @brief Returns the tags to try for a language, most specific first, ending with English.
+ * "de_AT.UTF-8@euro" gives de-AT, de, en.
+ */
+func languageCandidates(lang string) []string {
+	lang = strings.SplitN(strings.SplitN(lang, ".", 2)[0], "@", 2)[0]
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	var candidates []string
+	if lang != "" && lang != "c" && lang != "posix" {
+		parts := strings.Split(lang, "-")
+		for i := len(parts); i > 0; i-- {
+			tag := parts[0]
+			if i > 1 {
+				tag += "-" + strings.ToUpper(strings.Join(parts[1:i], "-"))
+			}
+			candidates = append(candidates, tag)
+		}
+	}
+	if len(candidates) == 0 || candidates[len(candidates)-1] != defaultLanguage {
+		candidates = append(candidates, defaultLanguage)
+	}
+	return candidates
+}
+
+// environmentLanguage returns the language of the POSIX locale, in its order of precedence.
+func environmentLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+/**
+ * @brief Returns a message with its placeholders filled in.
+ * @param key The message key, e.g. "pretty.no_findings".
+ * @param params Pairs of placeholder name and value. A "count" parameter
+ * selects the plural form.
+ * @return The text; the key itself if no catalog has it, so a missing
+ * message shows up instead of disappearing.
+ */
+func (c *messageCatalog) text(key string, params ...interface{}) string {
+	if c == nil {
+		c = messages
+	}
+	entry, ok := c.messages[key]
+	if !ok {
+		return key
+	}
+	text := entry.Other
+	var pairs []string
+	for i := 0; i+1 < len(params); i += 2 {
+		name := fmt.Sprint(params[i])
+		if name == "count" && fmt.Sprint(params[i+1]) == "1" {
+			text = entry.One
+		}
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(params[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+/**
+ * @brief Lists the built-in languages, for --lang help and errors.
+ */
+func catalogLanguages() []string {
+	files, _ := embeddedLocales.ReadDir("locales")
+	var langs []string
+	for _, file := range files {
+		langs = append(langs, strings.TrimSuffix(file.Name(), ".json"))
+	}
+	sort.Strings(langs)
+	return langs
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Every built-in translation must have exactly the keys of the English catalog.
+func TestCatalogsMatchEnglish(t *testing.T) {
+	keys := func(lang string) []string {
+		data, err := embeddedLocales.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries map[string]catalogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		var names []string
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	want := keys(defaultLanguage)
+	for _, lang := range catalogLanguages() {
+		if got := keys(lang); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s keys:\n%s\nwant:\n%s", lang, strings.Join(got, " "), strings.Join(want, " "))
+		}
+	}
+}
+
+func TestCatalogFallbacks(t *testing.T) {
+	tests := []struct {
+		lang, key string
+		params    []interface{}
+		want      string
+	}{
+		{"de_AT.UTF-8", "pretty.summary.files", []interface{}{"count", 1}, "1 Datei"},
+		{"de", "pretty.summary.files", []interface{}{"count", 3}, "3 Dateien"},
+		{"C", "pretty.no_findings", nil, "No secrets found."},
+		{"xx-YY", "pretty.commit", []interface{}{"commit", "abc"}, "commit abc"},
+		{"fr", "no.such.key", nil, "no.such.key"},
+	}
+	for _, tt := range tests {
+		c, err := loadCatalog(tt.lang, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.text(tt.key, tt.params...); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/**
+ * @file identity.go
+ * @brief Commit signature and author identity facts of findings (identity enricher).
+ *
+ * Whether a secret was committed by a colleague's mistake or planted by
+ * someone else changes the response. The identity enricher adds what git
+ * knows about the commit that introduced the blob:
+ *   - commit_signature: none, valid, unverified (signed, but the key or
+ *     allowed signers are not available here), bad, expired or revoked, for
+ *     GPG and SSH signatures alike, and commit_signer when git names one;
+ *   - with --corporate-domain, author_external=true when the author email is
+ *     outside those domains (subdomains count as inside), and
+ *     committer_external likewise for the committer.
+ * A finding from an unsigned commit by an external author in a repository
+ * whose history is otherwise signed is the pattern worth a second look.
+ */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// signatureStates maps git's %G? codes to commit_signature values.
+var signatureStates = map[string]string{
+	"G": "valid",
+	"U": "valid", // Good signature of a key with unknown trust
+	"B": "bad",
+	"X": "expired", // Signature expired
+	"Y": "expired", // Key expired
+	"R": "revoked",
+	"E": "unverified",
+	"N": "none",
+}
+
+/**
+ * @struct commitIdentity
+ * @brief The signature and identities of one commit.
+ */
+type commitIdentity struct {
+	signature      string
+	signer         string
+	authorEmail    string
+	committerEmail string
+}
+
+/**
+ * @struct identityEnricher
+ * @brief Adds the commit signature state and corporate-domain flags of the introducing commit.
+ */
+type identityEnricher struct {
+	domains []string // Lower-case corporate email domains (none = no domain flags)
+
+	mu      sync.Mutex
+	commits map[commitKey]commitIdentity
+}
+
+func (*identityEnricher) name() string { return "identity" }
+
+func (e *identityEnricher) enrich(f *finding, in *enrichInput) {
+	if in.blob.commit == "" || in.blob.commit == worktreeCommit {
+		return
+	}
+	key := commitKey{in.blob.repo, in.blob.commit}
+	e.mu.Lock()
+	id, ok := e.commits[key]
+	e.mu.Unlock()
+	if !ok {
+		id = readCommitIdentity(in.blob.repo, in.blob.commit)
+		e.mu.Lock()
+		e.commits[key] = id
+		e.mu.Unlock()
+	}
+	setMetadata(f, "commit_signature", id.signature)
+	setMetadata(f, "commit_signer", id.signer)
+	if len(e.domains) > 0 {
+		setMetadata(f, "author_external", strconv.FormatBool(!e.corporate(id.authorEmail)))
+		setMetadata(f, "committer_external", strconv.FormatBool(!e.corporate(id.committerEmail)))
+	}
+}
+
+// corporate reports whether an email address belongs to one of the corporate domains.
+func (e *identityEnricher) corporate(email string) bool {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return false
+	}
+	for _, d := range e.domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Reads the signature state and identities of a commit.
+ * Checking a signature needs the signer's key (GPG) or gpg.ssh.allowedSignersFile
+ * (SSH); without them a signed commit is reported as unverified. Git reports
+ * an SSH signature it cannot check as no signature at all, so the commit
+ * headers are looked at before settling on "none".
+ */
+func readCommitIdentity(repo *repository, commit string) commitIdentity {
+	output, err := repo.command("show", "-s", "--format=%G?%x00%GS%x00%ae%x00%ce", commit).Output()
+	if err != nil {
+		return commitIdentity{}
+	}
+	fields := strings.SplitN(strings.TrimRight(string(output), "\n"), "\x00", 4)
+	if len(fields) != 4 {
+		return commitIdentity{}
+	}
+	id := commitIdentity{
+		signature:      orDefault(signatureStates[fields[0]], "unverified"),
+		signer:         fields[1],
+		authorEmail:    fields[2],
+		committerEmail: fields[3],
+	}
+	if id.signature == "none" && hasSignatureHeader(repo, commit) {
+		id.signature = "unverified"
+	}
+	return id
+}
+
+// hasSignatureHeader reports whether a commit object carries a gpgsig header.
+func hasSignatureHeader(repo *repository, commit string) bool {
+	output, err := repo.command("cat-file", "commit", commit).Output()
+	if err != nil {
+		return false
+	}
+	headers, _, _ := strings.Cut(string(output), "\n\n")
+	for _, line := range strings.Split(headers, "\n") {
+		if strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDomains lower-cases --corporate-domain values and strips a leading "@" or ".".
+func normalizeDomains(values []string) []string {
+	var domains []string
+	for _, v := range values {
+		if d := strings.Trim(strings.ToLower(strings.TrimSpace(v)), "@."); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
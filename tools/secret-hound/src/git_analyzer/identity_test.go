@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestIdentityEnricher(t *testing.T) {
+	fx := newFixtureRepo(t)
+	unsigned := fx.commit("unsigned", map[string]string{"a.env": "KEY=1\n"})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+	e := &identityEnricher{domains: normalizeDomains([]string{"@Corp.example.com", " "}), commits: make(map[commitKey]commitIdentity)}
+
+	f := &finding{}
+	e.enrich(f, &enrichInput{blob: fileBlob{repo: repo, commit: unsigned}})
+	// The fixture's author is fixture@example.com, outside corp.example.com.
+	if m := f.Metadata; m["commit_signature"] != "none" || m["author_external"] != "true" || m["committer_external"] != "true" || m["commit_signer"] != "" {
+		t.Errorf("unsigned commit: %v", m)
+	}
+	worktree := &finding{}
+	e.enrich(worktree, &enrichInput{blob: fileBlob{repo: repo, commit: worktreeCommit}})
+	if worktree.Metadata != nil {
+		t.Errorf("working tree file: %v", worktree.Metadata)
+	}
+	noDomains := &identityEnricher{commits: make(map[commitKey]commitIdentity)}
+	plain := &finding{}
+	noDomains.enrich(plain, &enrichInput{blob: fileBlob{repo: repo, commit: unsigned}})
+	if _, ok := plain.Metadata["author_external"]; ok {
+		t.Error("author_external was set without --corporate-domain")
+	}
+}
+
+func TestSSHSignedCommits(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	fx := newFixtureRepo(t)
+	key := filepath.Join(t.TempDir(), "key")
+	if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "fixture", "-f", key).CombinedOutput(); err != nil {
+		t.Skipf("ssh-keygen: %v\n%s", err, output)
+	}
+	fx.write(map[string]string{"a": "1\n"})
+	fx.git("add", "-A")
+	fx.git("-c", "gpg.format=ssh", "-c", "user.signingkey="+key, "commit", "-q", "-S", "-m", "signed")
+	signed := fx.git("rev-parse", "HEAD")
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+
+	// Without allowed signers git cannot check the signature.
+	if id := readCommitIdentity(repo, signed); id.signature != "unverified" || id.authorEmail != "fixture@example.com" {
+		t.Errorf("without allowed signers: %+v", id)
+	}
+	pub, _ := os.ReadFile(key + ".pub")
+	allowed := filepath.Join(t.TempDir(), "allowed_signers")
+	os.WriteFile(allowed, append([]byte(`fixture@example.com namespaces="git" `), pub...), 0o644)
+	fx.git("config", "gpg.ssh.allowedSignersFile", allowed)
+	if id := readCommitIdentity(repo, signed); id.signature != "valid" || id.signer != "fixture@example.com" {
+		t.Errorf("with allowed signers: %+v", id)
+	}
+	if id := readCommitIdentity(repo, "0000000000000000000000000000000000000000"); id != (commitIdentity{}) {
+		t.Errorf("unknown commit: %+v", id)
+	}
+}
+
+func TestCorporateDomains(t *testing.T) {
+	e := &identityEnricher{domains: normalizeDomains([]string{"example.com", ".Corp.IO"})}
+	for email, want := range map[string]bool{
+		"dev@example.com": true, "dev@eu.example.com": true, "dev@corp.io": true,
+		"dev@notexample.com": false, "dev@example.com.evil.io": false, "not-an-email": false,
+	} {
+		if got := e.corporate(email); got != want {
+			t.Errorf("corporate(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/**
+ * @file ignorerevs.go
+ * @brief Commits excluded from history walks (.hound-ignore-revs).
+ *
+ * Like git blame's blame.ignoreRevsFile, a repository can list commits that
+ * are known noise, e.g. vendored-import mega-commits or generated fixture
+ * dumps, in a .hound-ignore-revs file at its root. The walk does not diff
+ * these commits at all, so they cost no scan time and produce no findings;
+ * content they introduced is still scanned if a later commit changes it.
+ * The file is read from the HEAD commit, so it works in bare repositories
+ * and mirrors, unless --ignore-revs-file names another file. One revision per
+ * line; blank lines and lines starting with # are ignored, and abbreviated
+ * hashes or other revisions are resolved with git rev-parse. A run that
+ * skips commits has not seen the secrets they contain, so it resolves none
+ * (see scanScope).
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ignoreRevsFile is the in-repository file listing commits to skip.
+const ignoreRevsFile = ".hound-ignore-revs"
+
+/**
+ * @brief Loads the commits to skip in a repository.
+ * @param repo The repository.
+ * @param path The --ignore-revs-file value ("" reads .hound-ignore-revs at HEAD).
+ * @return The set of full commit hashes (nil if there are none) and an error
+ *         if an explicitly named file could not be read.
+ */
+func loadIgnoreRevs(repo *repository, path string) (map[string]bool, error) {
+	var data []byte
+	var err error
+	source := ignoreRevsFile
+	if path != "" {
+		source = path
+		if data, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+	} else if data, err = repo.command("show", "HEAD:"+ignoreRevsFile).Output(); err != nil {
+		return nil, nil // No commits yet, or no file: nothing to skip
+	}
+
+	revs := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rev := strings.Fields(line)[0]
+		output, err := repo.command("rev-parse", "--verify", "-q", rev+"^{commit}").Output()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: %s%s: %s does not name a commit, ignoring it\n", labelPrefix(repo.label), source, rev)
+			continue
+		}
+		revs[strings.TrimSpace(string(output))] = true
+	}
+	if len(revs) == 0 {
+		return nil, nil
+	}
+	return revs, nil
+}
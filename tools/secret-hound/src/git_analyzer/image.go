@@ -0,0 +1,681 @@
+/**
+ * @file image.go
+ * @brief Scanning the layers of a container image (--image).
+ *
+ * Secrets that never reach a repository's history still end up in the images
+ * built from it: a COPY of a local .env, a RUN that writes a token, or a key
+ * deleted in a later layer, which removes it from the final filesystem but
+ * not from the image. --image scans every layer of an image on its own:
+ *   --image registry.example.com/team/app:1.4   pull from a registry (v2 API)
+ *   --image app.tar                             a `docker save` archive
+ * Each layer is extracted to a temporary directory and its files go through
+ * the normal pipeline. A finding's commit is the layer digest, and its
+ * metadata names the layer (layer_digest, layer_index) and, where the image
+ * config records it, the Dockerfile instruction that created the layer
+ * (layer_instruction). Whiteouts are honored: files a later layer deletes or
+ * replaces are still scanned, since they ship with the image, but their
+ * findings carry image_visible=false.
+ *
+ * Registry pulls pick the linux/<GOARCH> manifest of multi-platform images
+ * and authenticate with the registry's bearer token flow, using credentials
+ * from ~/.docker/config.json ("auths" entries; credential helpers are not
+ * supported) or REGISTRY_USERNAME/REGISTRY_PASSWORD. zstd layers need the
+ * zstd command-line tool.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Media types accepted for image manifests.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+/**
+ * @struct imageLayer
+ * @brief One layer of an image and the instruction that created it.
+ */
+type imageLayer struct {
+	digest      string
+	instruction string // Dockerfile instruction from the config history ("" if unknown)
+	open        func() (io.ReadCloser, error)
+}
+
+/**
+ * @struct imageConfig
+ * @brief The parts of an image config the scanner uses.
+ */
+type imageConfig struct {
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+/**
+ * @brief Returns the Dockerfile instruction of each non-empty layer, in layer order.
+ */
+func (c *imageConfig) instructions() []string {
+	var out []string
+	for _, h := range c.History {
+		if !h.EmptyLayer {
+			created := strings.TrimSpace(strings.TrimPrefix(h.CreatedBy, "/bin/sh -c #(nop) "))
+			out = append(out, strings.TrimSpace(strings.TrimSuffix(created, "# buildkit")))
+		}
+	}
+	return out
+}
+
+/**
+ * @struct imageEnricher
+ * @brief Attributes findings in image layers to their layer (see --image).
+ */
+type imageEnricher struct {
+	layers  map[string]int  // Layer digest -> index
+	info    []imageLayer    // Layers in order
+	visible map[string]bool // Disk path -> whether the file is in the final filesystem
+}
+
+func (*imageEnricher) name() string { return "image" }
+
+func (e *imageEnricher) enrich(f *finding, in *enrichInput) {
+	index, ok := e.layers[in.blob.commit]
+	if !ok {
+		return
+	}
+	setMetadata(f, "layer_digest", in.blob.commit)
+	setMetadata(f, "layer_index", fmt.Sprint(index))
+	setMetadata(f, "layer_instruction", e.info[index].instruction)
+	if !e.visible[in.blob.diskPath] {
+		setMetadata(f, "image_visible", "false")
+	}
+}
+
+/**
+ * @brief Scans every layer of a container image.
+ * @param ref A registry reference or the path of a `docker save` archive.
+ * @return An error if the image cannot be read.
+ */
+func (a *analyzer) scanImage(ref string) error {
+	var layers []imageLayer
+	var err error
+	if info, statErr := os.Stat(ref); statErr == nil && !info.IsDir() {
+		layers, err = openImageArchive(ref)
+	} else {
+		layers, err = pullImage(ref)
+	}
+	if err != nil {
+		return fmt.Errorf("--image: %v", err)
+	}
+
+	dir, err := ioutil.TempDir(a.opts.tmpDir, "secret-hound-image-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// Not a git directory: git lookups of the author and owners enrichers find nothing.
+	repo := &repository{gitDir: dir, label: ref}
+
+	enricher := &imageEnricher{layers: make(map[string]int), info: layers, visible: make(map[string]bool)}
+	top := make(map[string]string) // Image path -> disk path of the version in the final filesystem
+	var blobs []fileBlob
+	blobSizes := make(map[string]int64)
+	for i, layer := range layers {
+		enricher.layers[layer.digest] = i
+		files, err := extractLayer(layer, filepath.Join(dir, fmt.Sprint(i)), top)
+		if err != nil {
+			return fmt.Errorf("--image: layer %d (%s): %v", i, layer.digest, err)
+		}
+		for _, file := range files {
+			blobs = append(blobs, fileBlob{hash: file.hash, path: file.path, commit: layer.digest, diskPath: file.diskPath, repo: repo})
+			blobSizes[file.hash] = file.size
+		}
+	}
+	for _, diskPath := range top {
+		enricher.visible[diskPath] = true
+	}
+
+	// The same file in several layers is scanned once, in the lowest layer.
+	seen := make(map[string]bool)
+	unique := blobs[:0:0]
+	for _, blob := range blobs {
+		if !seen[blob.hash] {
+			seen[blob.hash] = true
+			unique = append(unique, blob)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: image %s: %d layers, %d files (%d unique)\n", ref, len(layers), len(blobs), len(unique))
+	if a.opts.dryRun {
+		a.plan.add(ref, len(layers), unique, blobSizes)
+		return nil
+	}
+
+	a.enrichers = append(a.enrichers, enricher)
+	a.runlog.begin(repo)
+	a.incidents.begin(repo)
+	debugRepositories.Add(1)
+	a.metrics.addRepository(len(layers), len(unique))
+	history := historyCoverage{requested: len(layers), available: len(layers), scope: fmt.Sprintf("image %s (%d layers)", ref, len(layers))}
+	a.coverage.begin(repo, history, map[string]interface{}{"image": ref}, map[string]interface{}{}, len(blobs))
+	a.coverage.skipCount(repo, "duplicate", len(blobs)-len(unique))
+	if a.opts.scanOrder == scanOrderRisk {
+		unique = orderByRisk(unique)
+	}
+	unique = a.deadline.plan(repo, unique)
+	scanned := a.runPipeline(repo, unique, blobSizes, a.opts.workers)
+	a.coverage.scanned(repo, scanned)
+	a.audit.repositoryScanned(repo, history, scanned)
+	return nil
+}
+
+/**
+ * @struct layerFile
+ * @brief A regular file extracted from a layer.
+ */
+type layerFile struct {
+	path     string // Path in the image, without a leading slash
+	diskPath string
+	hash     string // SHA-256 of the content
+	size     int64
+}
+
+/**
+ * @brief Extracts the regular files of a layer and applies its whiteouts.
+ * Whiteouts hide paths of lower layers only: they are applied once the
+ * layer is read, before its own files become visible, wherever they appear
+ * in the archive.
+ * @param layer The layer.
+ * @param dir The directory to extract into.
+ * @param top The visible version of every image path so far; updated in place.
+ * @return The extracted files and an error if the layer cannot be read.
+ */
+func extractLayer(layer imageLayer, dir string, top map[string]string) ([]layerFile, error) {
+	rc, err := layer.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	stream, closeStream, err := decompressLayer(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStream()
+
+	var files []layerFile
+	var opaque, whiteouts []string
+	own := make(map[string]string) // This layer's files, visible once its whiteouts are applied
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		dirName, base := path.Split(name)
+		switch {
+		case base == ".wh..wh..opq": // Opaque directory: hides everything below it from lower layers
+			opaque = append(opaque, strings.TrimSuffix(dirName, "/"))
+			continue
+		case strings.HasPrefix(base, ".wh."):
+			whiteouts = append(whiteouts, dirName+strings.TrimPrefix(base, ".wh."))
+			continue
+		case hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA:
+			if hdr.Typeflag != tar.TypeDir {
+				// Replaced by a link or device
+				removeImagePaths(top, name, false)
+				removeImagePaths(own, name, false)
+			}
+			continue
+		}
+
+		diskPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(diskPath), 0o700); err != nil {
+			return nil, err
+		}
+		out, err := os.OpenFile(diskPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		size, err := io.Copy(io.MultiWriter(out, h), tr)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		own[name] = diskPath
+		files = append(files, layerFile{path: name, diskPath: diskPath, hash: hex.EncodeToString(h.Sum(nil)), size: size})
+	}
+
+	for _, name := range opaque {
+		removeImagePaths(top, name, true)
+	}
+	for _, name := range whiteouts {
+		removeImagePaths(top, name, false)
+	}
+	for name, diskPath := range own {
+		top[name] = diskPath
+	}
+	return files, nil
+}
+
+// removeImagePaths deletes an image path and everything below it (or only below it) from the visible set.
+func removeImagePaths(top map[string]string, name string, childrenOnly bool) {
+	for p := range top {
+		if (p == name && !childrenOnly) || strings.HasPrefix(p, name+"/") || name == "" {
+			delete(top, p)
+		}
+	}
+}
+
+/**
+ * @brief Detects and undoes the compression of a layer (gzip, zstd or none).
+ * @return The tar stream and a function releasing the decompressor.
+ */
+func decompressLayer(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, func() { gz.Close() }, nil
+	case len(magic) == 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd:
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return nil, nil, fmt.Errorf("zstd layers require the zstd command-line tool")
+		}
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = br
+		out, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return out, func() { cmd.Process.Kill(); cmd.Wait() }, nil
+	}
+	return br, func() {}, nil
+}
+
+/**
+ * @brief Reads the layers of a `docker save` archive.
+ * Layers are identified by their diff ID (digest of the uncompressed layer).
+ * @param archive The archive path.
+ * @return The layers of the first image in the archive.
+ */
+func openImageArchive(archive string) ([]imageLayer, error) {
+	readEntry := func(name string) ([]byte, error) {
+		file, err := os.Open(archive)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		tr := tar.NewReader(file)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: no %s in the archive", archive, name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if path.Clean(hdr.Name) == name {
+				return ioutil.ReadAll(tr)
+			}
+		}
+	}
+	data, err := readEntry("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	var manifest []struct {
+		Config string   `json:"Config"`
+		Layers []string `json:"Layers"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest) == 0 {
+		return nil, fmt.Errorf("%s: not a docker save archive", archive)
+	}
+	var config imageConfig
+	if data, err = readEntry(path.Clean(manifest[0].Config)); err == nil {
+		json.Unmarshal(data, &config)
+	}
+	instructions := config.instructions()
+
+	layers := make([]imageLayer, 0, len(manifest[0].Layers))
+	for i, name := range manifest[0].Layers {
+		name := path.Clean(name)
+		layer := imageLayer{digest: name, open: func() (io.ReadCloser, error) {
+			file, err := os.Open(archive)
+			if err != nil {
+				return nil, err
+			}
+			tr := tar.NewReader(file)
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					file.Close()
+					if err == io.EOF {
+						err = fmt.Errorf("no %s in the archive", name)
+					}
+					return nil, err
+				}
+				if path.Clean(hdr.Name) == name {
+					return struct {
+						io.Reader
+						io.Closer
+					}{tr, file}, nil
+				}
+			}
+		}}
+		if i < len(config.RootFS.DiffIDs) {
+			layer.digest = config.RootFS.DiffIDs[i]
+		}
+		if i < len(instructions) {
+			layer.instruction = instructions[i]
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+/**
+ * @struct registryClient
+ * @brief Pulls manifests and blobs of one repository from a v2 registry.
+ */
+type registryClient struct {
+	client   *http.Client
+	base     string // https://<registry>/v2/<repository>
+	host     string
+	user     string
+	password string
+	token    string // Bearer token once obtained
+}
+
+/**
+ * @brief Resolves an image reference and lists its layers from the registry.
+ * @param ref e.g. "alpine:3.19", "ghcr.io/org/app@sha256:...".
+ * @return The layers, streamed from the registry when extracted.
+ */
+func pullImage(ref string) ([]imageLayer, error) {
+	c, reference := newRegistryClient(ref, 30*time.Minute)
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := c.getJSON("/manifests/"+reference, strings.Join(manifestMediaTypes, ", "), &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Manifests) > 0 { // An index of per-platform manifests
+		digest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("%s: no linux/%s image", ref, runtime.GOARCH)
+		}
+		manifest.Manifests = nil
+		if err := c.getJSON("/manifests/"+digest, strings.Join(manifestMediaTypes, ", "), &manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	var config imageConfig
+	if err := c.getJSON("/blobs/"+manifest.Config.Digest, "*/*", &config); err != nil {
+		return nil, err
+	}
+	instructions := config.instructions()
+	layers := make([]imageLayer, 0, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		digest := l.Digest
+		layer := imageLayer{digest: digest, open: func() (io.ReadCloser, error) {
+			resp, err := c.get("/blobs/"+digest, "*/*")
+			if err != nil {
+				return nil, err
+			}
+			return resp.Body, nil
+		}}
+		if i < len(instructions) {
+			layer.instruction = instructions[i]
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+/**
+ * @brief Connects to the registry repository of a reference, with its credentials.
+ * @param ref e.g. "alpine:3.19" or "registry.example.com/team/app@sha256:...".
+ * @param timeout Bound of each request.
+ * @return The client and the tag or digest of the reference.
+ */
+func newRegistryClient(ref string, timeout time.Duration) (*registryClient, string) {
+	host, repoName, reference := parseImageRef(ref)
+	c := &registryClient{client: &http.Client{Timeout: timeout}, host: host,
+		base: "https://" + host + "/v2/" + repoName}
+	c.user, c.password = registryCredentials(host)
+	return c, reference
+}
+
+/**
+ * @brief Splits an image reference into registry host, repository and tag or digest.
+ * Docker Hub names are expanded the way docker does ("alpine" is
+ * registry-1.docker.io/library/alpine:latest).
+ */
+func parseImageRef(ref string) (host, repoName, reference string) {
+	name := ref
+	reference = "latest"
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, reference = name[:i], name[i+1:]
+	}
+	host = "registry-1.docker.io"
+	if i := strings.Index(name, "/"); i >= 0 && strings.ContainsAny(name[:i], ".:") || strings.HasPrefix(name, "localhost/") {
+		host, name = name[:i], name[i+1:]
+	} else if !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+	return host, name, reference
+}
+
+/**
+ * @brief Finds credentials for a registry.
+ * @return The user name and password ("" if anonymous).
+ */
+func registryCredentials(host string) (string, string) {
+	if user := os.Getenv("REGISTRY_USERNAME"); user != "" {
+		return user, os.Getenv("REGISTRY_PASSWORD")
+	}
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".docker")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if json.Unmarshal(data, &config) != nil {
+		return "", ""
+	}
+	for key, entry := range config.Auths {
+		keyHost := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+		keyHost = strings.SplitN(keyHost, "/", 2)[0]
+		if keyHost == host || (host == "registry-1.docker.io" && keyHost == "index.docker.io") {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if user, password, ok := strings.Cut(string(decoded), ":"); err == nil && ok {
+				return user, password
+			}
+		}
+	}
+	return "", ""
+}
+
+// get requests a registry path, fetching a bearer token when challenged.
+func (c *registryClient) get(p, accept string) (*http.Response, error) {
+	return c.do("GET", p, http.Header{"Accept": {accept}}, nil)
+}
+
+/**
+ * @brief Sends a request to the registry, fetching a bearer token when challenged.
+ * @param p A path below the repository ("/manifests/..."), a path from the
+ *          registry root ("/v2/...", as in upload locations) or a full URL.
+ * @return The response of any 2xx status; other statuses are errors.
+ */
+func (c *registryClient) do(method, p string, header http.Header, body []byte) (*http.Response, error) {
+	target := c.base + p
+	if strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://") {
+		target = p
+	} else if strings.HasPrefix(p, "/v2/") {
+		target = "https://" + c.host + p
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.user != "" {
+			req.SetBasicAuth(c.user, c.password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode/100 == 2 {
+			return resp, nil
+		}
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := c.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, fmt.Errorf("%s: HTTP %d: %s", c.host+p, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+}
+
+func (c *registryClient) getJSON(p, accept string, out interface{}) error {
+	resp, err := c.get(p, accept)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// authenticate answers a Bearer challenge with a token from the registry's auth service.
+func (c *registryClient) authenticate(challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		if c.user == "" {
+			return errors.New("registry requires credentials")
+		}
+		return errors.New("registry rejected the credentials")
+	}
+	fields := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			fields[key] = strings.Trim(value, `"`)
+		}
+	}
+	query := url.Values{}
+	for _, key := range []string{"service", "scope"} {
+		if fields[key] != "" {
+			query.Set(key, fields[key])
+		}
+	}
+	req, err := http.NewRequest("GET", fields["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token: HTTP %d", resp.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	c.token = orDefault(token.Token, token.AccessToken)
+	return nil
+}
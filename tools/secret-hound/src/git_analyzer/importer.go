@@ -0,0 +1,336 @@
+/**
+ * @file importer.go
+ * @brief The import subcommand: Gitleaks and TruffleHog reports as native findings.
+ *
+ * Teams moving from another scanner have months of triaged results in its
+ * report format. `git_analyzer import` reads a Gitleaks JSON report (a JSON
+ * array, `gitleaks detect --report-format json`) or TruffleHog v3 output
+ * (JSON Lines, `trufflehog git --json`) and writes the findings in the
+ * native schema, as if git_analyzer had found them:
+ *   - rules with a native equivalent are mapped to its id (aws-access-token
+ *     and AWS become AWS_ACCESS_KEY, ...), others keep the tool's name under
+ *     a GITLEAKS_ or TRUFFLEHOG_ prefix;
+ *   - every finding gets metadata fingerprint, the same hash of rule and value
+ *     the history file uses, plus source_tool and the tool's own fingerprint
+ *     or detector; TruffleHog's verification result becomes High confidence
+ *     and metadata verified;
+ *   - findings the other tool reported more than once for the same commit and
+ *     path are written once.
+ * With --history-file the import is also appended to the scan history as a
+ * run of each repository, so `report trend` and later native scans pick up
+ * from the imported state instead of reporting every known secret as new.
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitleaksRules maps Gitleaks rule ids to the equivalent native rules.
+var gitleaksRules = map[string]string{
+	"aws-access-token":        "AWS_ACCESS_KEY",
+	"private-key":             "PRIVATE_KEY_PEM",
+	"github-pat":              "GITHUB_TOKEN",
+	"github-fine-grained-pat": "GITHUB_TOKEN",
+	"github-oauth":            "GITHUB_TOKEN",
+	"github-app-token":        "GITHUB_TOKEN",
+	"github-refresh-token":    "GITHUB_TOKEN",
+	"slack-bot-token":         "SLACK_TOKEN",
+	"slack-user-token":        "SLACK_TOKEN",
+	"slack-app-token":         "SLACK_TOKEN",
+	"slack-legacy-token":      "SLACK_TOKEN",
+	"stripe-access-token":     "STRIPE_API_KEY",
+	"generic-api-key":         "GENERIC_HIGH_ENTROPY",
+}
+
+// trufflehogDetectors maps TruffleHog detector names to the equivalent native rules.
+var trufflehogDetectors = map[string]string{
+	"AWS":        "AWS_ACCESS_KEY",
+	"PrivateKey": "PRIVATE_KEY_PEM",
+	"Github":     "GITHUB_TOKEN",
+	"Slack":      "SLACK_TOKEN",
+	"Stripe":     "STRIPE_API_KEY",
+	"URI":        "BASIC_AUTH_URL",
+}
+
+/**
+ * @struct gitleaksFinding
+ * @brief One entry of a Gitleaks JSON report (the fields used here).
+ */
+type gitleaksFinding struct {
+	RuleID      string
+	Description string
+	StartLine   int
+	StartColumn int
+	Secret      string
+	File        string
+	Commit      string
+	Entropy     float64
+	Author      string
+	Email       string
+	Fingerprint string
+}
+
+/**
+ * @struct trufflehogFinding
+ * @brief One line of TruffleHog v3 JSON output (the fields used here).
+ */
+type trufflehogFinding struct {
+	SourceMetadata struct {
+		Data map[string]struct {
+			Commit     string `json:"commit"`
+			File       string `json:"file"`
+			Email      string `json:"email"`
+			Repository string `json:"repository"`
+			Line       int    `json:"line"`
+		}
+	}
+	DetectorName string
+	DecoderName  string
+	Verified     bool
+	Raw          string
+	RawV2        string
+}
+
+/**
+ * @brief Runs the import subcommand.
+ * @param args The arguments following "import".
+ * @return The process exit code.
+ */
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "auto", "Report format: auto, gitleaks or trufflehog")
+	repository := fs.String("repository", "", "Repository name for the findings and the history file (default: the report's, or the current repository)")
+	historyFile := fs.String("history-file", "", "Append the imported findings as a run to this scan history file")
+	outputPath := fs.String("output", "", "Write the findings to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer import [--format auto|gitleaks|trufflehog] [options] <report>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *format == "auto" {
+		*format = "trufflehog"
+		if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] == '[' {
+			*format = "gitleaks"
+		}
+	}
+	var findings []*finding
+	switch *format {
+	case "gitleaks":
+		findings, err = importGitleaks(data)
+	case "trufflehog":
+		findings, err = importTrufflehog(data)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --format: expected auto, gitleaks or trufflehog, got %q\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+	if *repository != "" {
+		for _, f := range findings {
+			f.Repository = *repository
+		}
+	}
+	findings = dedupeImported(findings)
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "" {
+		file, err := os.OpenFile(*outputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --output: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+	encoder := json.NewEncoder(buffered)
+	for _, f := range findings {
+		encoder.Encode(f)
+	}
+	if err := buffered.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: writing findings: %v\n", err)
+		return 1
+	}
+
+	if *historyFile != "" {
+		stat, _ := os.Stat(fs.Arg(0))
+		if err := saveImportedRun(*historyFile, findings, stat.ModTime()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --history-file: %v\n", err)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: imported %d finding(s) from a %s report\n", len(findings), *format)
+	return 0
+}
+
+/**
+ * @brief Converts a Gitleaks JSON report.
+ */
+func importGitleaks(data []byte) ([]*finding, error) {
+	var report []gitleaksFinding
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("not a Gitleaks JSON report: %v", err)
+	}
+	var findings []*finding
+	for _, g := range report {
+		ruleID, ok := gitleaksRules[g.RuleID]
+		if !ok {
+			ruleID = "GITLEAKS_" + importedRuleName(g.RuleID)
+		}
+		f := importedFinding(ruleID, orDefault(g.Description, g.RuleID), g.Secret, g.Commit, g.File, g.StartLine, "Medium")
+		f.Entropy = g.Entropy
+		f.Column = g.StartColumn
+		setMetadata(f, "source_tool", "gitleaks")
+		setMetadata(f, "source_rule", g.RuleID)
+		setMetadata(f, "source_fingerprint", g.Fingerprint)
+		if g.Email != "" {
+			setMetadata(f, "author", fmt.Sprintf("%s <%s>", g.Author, g.Email))
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
+
+/**
+ * @brief Converts TruffleHog v3 JSON Lines output.
+ */
+func importTrufflehog(data []byte) ([]*finding, error) {
+	var findings []*finding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var t trufflehogFinding
+		if err := json.Unmarshal(line, &t); err != nil || t.DetectorName == "" {
+			if bytes.Contains(line, []byte(`"level"`)) {
+				continue // A log line mixed into the output
+			}
+			return nil, fmt.Errorf("line %d is not a TruffleHog finding", n)
+		}
+		ruleID, ok := trufflehogDetectors[t.DetectorName]
+		if !ok {
+			ruleID = "TRUFFLEHOG_" + importedRuleName(t.DetectorName)
+		}
+		confidence := "Medium"
+		if t.Verified {
+			confidence = "High"
+		}
+		// Only the source the secret was found in is set (git or filesystem).
+		var commit, path, repository, email string
+		line1 := 0
+		for _, source := range t.SourceMetadata.Data {
+			commit, path, repository, email, line1 = source.Commit, source.File, source.Repository, source.Email, source.Line
+		}
+		f := importedFinding(ruleID, t.DetectorName+" secret", orDefault(t.Raw, t.RawV2), orDefault(commit, worktreeCommit), path, line1, confidence)
+		f.Repository = repository
+		setMetadata(f, "source_tool", "trufflehog")
+		setMetadata(f, "source_rule", t.DetectorName)
+		setMetadata(f, "verified", strconv.FormatBool(t.Verified))
+		setMetadata(f, "author", email)
+		if t.DecoderName != "" && t.DecoderName != "PLAIN" {
+			setMetadata(f, "encoding", strings.ToLower(t.DecoderName))
+		}
+		findings = append(findings, f)
+	}
+	return findings, scanner.Err()
+}
+
+// importedFinding creates a native finding from the fields every report has.
+func importedFinding(ruleID, description, secret, commit, path string, line int, confidence string) *finding {
+	if line < 1 {
+		line = 1
+	}
+	f := &finding{
+		SchemaVersion: findingSchemaVersion,
+		Commit:        commit,
+		OriginalPath:  path,
+		File:          path,
+		Line:          line,
+		RuleID:        ruleID,
+		Description:   description,
+		Match:         secret,
+		Confidence:    confidence,
+		Severity:      strings.ToLower(confidence),
+	}
+	setMetadata(f, "fingerprint", secretFingerprint(f))
+	return f
+}
+
+// importedRuleName turns another tool's rule name into a native-style rule id.
+func importedRuleName(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", " ", "_", ".", "_").Replace(name))
+}
+
+/**
+ * @brief Drops findings of the same secret at the same commit and path.
+ * TruffleHog reports a secret once per decoder and line that finds it.
+ */
+func dedupeImported(findings []*finding) []*finding {
+	seen := make(map[string]bool)
+	kept := findings[:0]
+	for _, f := range findings {
+		key := strings.Join([]string{f.Repository, f.Commit, f.OriginalPath, f.RuleID, f.Match}, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+/**
+ * @brief Appends imported findings to the history file as one run per repository.
+ * Findings without a repository belong to the repository in the current directory.
+ * @param at The time of the run (the report's modification time).
+ */
+func saveImportedRun(path string, findings []*finding, at time.Time) error {
+	recorder := &runRecorder{path: path, started: at.UTC(), records: make(map[string]*runRecord)}
+	for _, f := range findings {
+		if _, ok := recorder.records[f.Repository]; !ok {
+			recorder.begin(&repository{label: f.Repository})
+		}
+		recorder.record(f)
+	}
+	if len(findings) == 0 {
+		recorder.begin(&repository{})
+	}
+	labels := make([]string, 0, len(recorder.records))
+	for label := range recorder.records {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		fmt.Fprintf(os.Stderr, "Go analyzer: recorded %d secret(s) of %s in %s\n", len(recorder.records[label].Findings), recorder.records[label].Repository, path)
+	}
+	return recorder.save()
+}
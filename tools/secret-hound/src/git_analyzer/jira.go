@@ -0,0 +1,334 @@
+/**
+ * @file jira.go
+ * @brief Jira issues for leaked secrets, created and kept in step by each run (--jira-url).
+ *
+ * Teams that track remediation in Jira want one issue per leaked secret, not
+ * one per line, and want it to follow the secret: updated when it spreads,
+ * resolved once it is gone. With --jira-url and --jira-project, the findings
+ * of a run are grouped by secret (the fingerprint of rule and value, see
+ * trend.go) and synchronized when a complete run ends (see tracker.go):
+ *   - secrets without an issue get one, created in bulk, of --jira-issue-type
+ *     with the labels secret-hound, secret-hound-<fingerprint> (which is how
+ *     the issue is found again) and every --jira-label;
+ *   - existing issues get their summary, description and mapped fields
+ *     rewritten with the current locations; a resolved issue whose secret
+ *     came back is moved through --jira-reopen-transition, if given;
+ *   - with --secret-index, issues of secrets the run remediated (no longer
+ *     found in any repository, see secretindex.go) are moved through
+ *     --jira-resolve-transition. Without an index nothing is resolved, and
+ *     neither is anything after a run narrowed by --disable-rule,
+ *     --first-parent or another option scanScope names: such a run is not
+ *     synchronized at all.
+ * --jira-field <field>=<template> sets a field, typically a custom field,
+ * from a template over the group (see tracker.go), e.g.
+ * customfield_10042={{.Severity}}; JSON objects and arrays suit select and
+ * multi-value fields.
+ *
+ * The REST API v2 is used, so descriptions are wiki markup. Credentials come
+ * from JIRA_USER and JIRA_API_TOKEN (Jira Cloud) or JIRA_TOKEN (a personal
+ * access token on Jira Server and Data Center). Failures are reported on
+ * stderr and do not fail the run.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// jiraHTTPTimeout bounds each call to the Jira API.
+const jiraHTTPTimeout = 60 * time.Second
+
+// jiraBatch is the most issues created or searched for in one request.
+const jiraBatch = 50
+
+/**
+ * @struct jiraSink
+ * @brief Collects findings by secret and synchronizes them with a Jira project.
+ */
+type jiraSink struct {
+	restAPI
+	project   string
+	issueType string
+	labels    []string
+	fields    []templateField
+	resolve   string // Transition of remediated secrets' issues ("" = leave them)
+	reopen    string // Transition of resolved issues whose secret came back ("" = leave them)
+	index     *secretIndex
+
+	groups secretGroups
+}
+
+// jiraIssue is an issue found by its fingerprint label.
+type jiraIssue struct {
+	Key  string
+	Done bool // In a status of the "done" category
+}
+
+/**
+ * @brief Validates the Jira options.
+ * @param opts The parsed options.
+ * @param index The secret index (may be nil).
+ * @return The sink (nil without --jira-url), and an error for an invalid option.
+ */
+func newJiraSink(opts *options, index *secretIndex) (*jiraSink, error) {
+	if opts.jiraURL == "" {
+		return nil, nil
+	}
+	if opts.jiraProject == "" {
+		return nil, fmt.Errorf("--jira-url requires --jira-project")
+	}
+	j := &jiraSink{restAPI: restAPI{client: &http.Client{Timeout: jiraHTTPTimeout}, url: strings.TrimRight(opts.jiraURL, "/")},
+		project: opts.jiraProject, issueType: opts.jiraIssueType, labels: opts.jiraLabels, resolve: opts.jiraResolve, reopen: opts.jiraReopen, index: index}
+	for _, label := range j.labels {
+		if strings.ContainsAny(label, " \t") {
+			return nil, fmt.Errorf("--jira-label %q: labels cannot contain spaces", label)
+		}
+	}
+	var err error
+	if j.fields, err = parseTemplateFields("--jira-field", opts.jiraFields); err != nil {
+		return nil, err
+	}
+	if user := os.Getenv("JIRA_USER"); user != "" {
+		j.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+os.Getenv("JIRA_API_TOKEN")))
+	} else if token := os.Getenv("JIRA_TOKEN"); token != "" {
+		j.auth = "Bearer " + token
+	}
+	return j, nil
+}
+
+/**
+ * @brief Adds a finding to the group of its secret.
+ * @param f The finding, before it is written (and possibly redacted).
+ */
+func (j *jiraSink) record(f *finding) {
+	if j != nil {
+		j.groups.record(f)
+	}
+}
+
+/**
+ * @brief Creates, updates and resolves the issues of the run's secrets.
+ * Called once a complete run succeeded and the secret index was saved.
+ */
+func (j *jiraSink) sync() {
+	if j == nil {
+		return
+	}
+	groups := j.groups.finish(j.index)
+	resolved := j.index.remediated()
+	fingerprints := append([]string(nil), resolved...)
+	for _, group := range groups {
+		fingerprints = append(fingerprints, group.Fingerprint)
+	}
+
+	issues, err := j.search(fingerprints)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: jira: searching %s: %v\n", j.project, err)
+		return
+	}
+	var create []*secretGroup
+	updated, reopened, closed := 0, 0, 0
+	for _, group := range groups {
+		issue, ok := issues[group.Fingerprint]
+		if !ok {
+			create = append(create, group)
+			continue
+		}
+		if err := j.update(issue.Key, group); err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: jira: updating %s: %v\n", issue.Key, err)
+			continue
+		}
+		updated++
+		if issue.Done && j.reopen != "" {
+			if err := j.transition(issue.Key, j.reopen); err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: jira: reopening %s: %v\n", issue.Key, err)
+			} else {
+				reopened++
+			}
+		}
+	}
+	created := j.create(create)
+	if j.resolve != "" {
+		for _, fingerprint := range resolved {
+			if issue, ok := issues[fingerprint]; ok && !issue.Done {
+				if err := j.transition(issue.Key, j.resolve); err != nil {
+					fmt.Fprintf(os.Stderr, "Go analyzer: jira: resolving %s: %v\n", issue.Key, err)
+				} else {
+					closed++
+				}
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: jira: %s: %d issue(s) created, %d updated, %d reopened, %d resolved\n", j.project, created, updated, reopened, closed)
+}
+
+/**
+ * @brief Finds the managed issues of secrets in the project.
+ * @return The issues by fingerprint.
+ */
+func (j *jiraSink) search(fingerprints []string) (map[string]jiraIssue, error) {
+	issues := make(map[string]jiraIssue)
+	for start := 0; start < len(fingerprints); start += jiraBatch {
+		batch := fingerprints[start:minInt(start+jiraBatch, len(fingerprints))]
+		wanted := make(map[string]string, len(batch)) // Label -> fingerprint
+		quoted := make([]string, len(batch))
+		for i, fingerprint := range batch {
+			wanted[fingerprintLabel(fingerprint)] = fingerprint
+			quoted[i] = `"` + fingerprintLabel(fingerprint) + `"`
+		}
+		query := map[string]interface{}{
+			"jql":        fmt.Sprintf(`project = "%s" AND labels in (%s)`, j.project, strings.Join(quoted, ", ")),
+			"fields":     []string{"labels", "status"},
+			"maxResults": 2 * jiraBatch,
+		}
+		var result struct {
+			Issues []struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Labels []string `json:"labels"`
+					Status struct {
+						Category struct {
+							Key string `json:"key"`
+						} `json:"statusCategory"`
+					} `json:"status"`
+				} `json:"fields"`
+			} `json:"issues"`
+		}
+		if err := j.call("POST", "/rest/api/2/search", query, &result); err != nil {
+			return nil, err
+		}
+		for _, issue := range result.Issues {
+			for _, label := range issue.Fields.Labels {
+				if fingerprint, ok := wanted[label]; ok {
+					if _, dup := issues[fingerprint]; !dup {
+						issues[fingerprint] = jiraIssue{Key: issue.Key, Done: issue.Fields.Status.Category.Key == "done"}
+					}
+				}
+			}
+		}
+	}
+	return issues, nil
+}
+
+/**
+ * @brief Builds the fields of an issue that every sync rewrites.
+ * @return The fields, and an error if a --jira-field template fails.
+ */
+func (j *jiraSink) issueFields(group *secretGroup) (map[string]interface{}, error) {
+	fields := map[string]interface{}{"summary": group.summary(), "description": jiraDescription(group)}
+	if err := applyTemplateFields(j.fields, group, fields); err != nil {
+		return nil, fmt.Errorf("--jira-field %v", err)
+	}
+	return fields, nil
+}
+
+// jiraDescription lists the locations of a secret in wiki markup.
+func jiraDescription(group *secretGroup) string {
+	escape := strings.NewReplacer("|", "\\|", "{", "\\{", "}", "\\}").Replace
+	var b strings.Builder
+	fmt.Fprintf(&b, "Secret Hound found a %s secret ({{%s}}, severity %s) in %d location(s):\n\n", group.RuleID, escape(group.Match), group.Severity, len(group.Locations))
+	b.WriteString("||Repository||Path||Line||Commit||\n")
+	for i, loc := range group.Locations {
+		if i == trackerMaxLocations {
+			fmt.Fprintf(&b, "\n... and %d more.\n", len(group.Locations)-i)
+			break
+		}
+		fmt.Fprintf(&b, "|%s|%s|%d|%s|\n", escape(orDefault(loc.Repository, "-")), escape(loc.Path), loc.Line, shortCommit(loc.Commit))
+	}
+	fmt.Fprintf(&b, "\nFingerprint: %s\n\n", group.Fingerprint)
+	b.WriteString("Rotate the secret first, then remove it from the repositories. This issue is updated by every scan and resolved once no scanned repository contains the secret.")
+	return b.String()
+}
+
+/**
+ * @brief Creates issues in bulk.
+ * @return The number of issues created.
+ */
+func (j *jiraSink) create(groups []*secretGroup) int {
+	created := 0
+	for start := 0; start < len(groups); start += jiraBatch {
+		batch := groups[start:minInt(start+jiraBatch, len(groups))]
+		var updates []map[string]interface{}
+		var sent []*secretGroup
+		for _, group := range batch {
+			fields, err := j.issueFields(group)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: jira: %v\n", err)
+				continue
+			}
+			fields["project"] = map[string]string{"key": j.project}
+			fields["issuetype"] = map[string]string{"name": j.issueType}
+			fields["labels"] = append([]string{trackerLabel, fingerprintLabel(group.Fingerprint)}, j.labels...)
+			updates = append(updates, map[string]interface{}{"fields": fields})
+			sent = append(sent, group)
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		var result struct {
+			Issues []struct {
+				Key string `json:"key"`
+			} `json:"issues"`
+			Errors []struct {
+				Element int             `json:"failedElementNumber"`
+				Details json.RawMessage `json:"elementErrors"`
+			} `json:"errors"`
+		}
+		// Jira answers 201 when all were created and 400 with the same body when some failed.
+		if err := j.call("POST", "/rest/api/2/issue/bulk", map[string]interface{}{"issueUpdates": updates}, &result); err != nil && len(result.Errors) == 0 {
+			fmt.Fprintf(os.Stderr, "Go analyzer: jira: creating issues: %v\n", err)
+			continue
+		}
+		for _, failed := range result.Errors {
+			if failed.Element >= 0 && failed.Element < len(sent) {
+				fmt.Fprintf(os.Stderr, "Go analyzer: jira: creating the issue of %s: %s\n", sent[failed.Element].Fingerprint, failed.Details)
+			}
+		}
+		created += len(result.Issues)
+	}
+	return created
+}
+
+// update rewrites the fields of an existing issue and adds any missing labels.
+func (j *jiraSink) update(key string, group *secretGroup) error {
+	fields, err := j.issueFields(group)
+	if err != nil {
+		return err
+	}
+	var labels []map[string]string
+	for _, label := range append([]string{trackerLabel}, j.labels...) {
+		labels = append(labels, map[string]string{"add": label})
+	}
+	return j.call("PUT", "/rest/api/2/issue/"+key, map[string]interface{}{"fields": fields, "update": map[string]interface{}{"labels": labels}}, nil)
+}
+
+// transition moves an issue through the transition of a name (or of the status it leads to).
+func (j *jiraSink) transition(key, name string) error {
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := j.call("GET", "/rest/api/2/issue/"+key+"/transitions", nil, &available); err != nil {
+		return err
+	}
+	var names []string
+	for _, t := range available.Transitions {
+		if strings.EqualFold(t.Name, name) || strings.EqualFold(t.To.Name, name) {
+			return j.call("POST", "/rest/api/2/issue/"+key+"/transitions", map[string]interface{}{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+		names = append(names, t.Name)
+	}
+	return fmt.Errorf("no transition %q (available: %s)", name, strings.Join(names, ", "))
+}
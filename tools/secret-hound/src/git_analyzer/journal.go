@@ -0,0 +1,207 @@
+/**
+ * @file journal.go
+ * @brief Crash-safe work queue journal (--queue-file).
+ *
+ * A long scan that is killed (out of memory, a preempted CI runner) used to
+ * start over from nothing. With --queue-file every blob is journaled before
+ * it enters the pipeline and again once it has been scanned and its
+ * findings written, so a crash loses at most the blobs that were in flight.
+ * On the next run with the same file, the blobs the interrupted run had
+ * queued but not finished are scanned first, before the history is walked
+ * again, and blobs it finished are not scanned a second time. The journal is
+ * removed when a run completes without error.
+ *
+ * The journal is an append-only JSON Lines file: "queued" records for the
+ * planned blobs of a repository, "done" records as blobs leave the
+ * pipeline. Records are written with a single write each, so a crash leaves
+ * at most a torn last line, which is ignored on replay. The analyzer is
+ * built from the standard library only, so the journal takes the place an
+ * embedded key-value store would have.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+/**
+ * @struct journalRecord
+ * @brief One line of the queue journal.
+ */
+type journalRecord struct {
+	Op         string `json:"op"` // queued or done
+	Repository string `json:"repository"`
+	Hash       string `json:"hash"`
+	Path       string `json:"path"`
+	Commit     string `json:"commit,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	DiskPath   string `json:"disk_path,omitempty"`
+}
+
+/**
+ * @struct blobJournal
+ * @brief The journal of an open --queue-file (nil = off).
+ */
+type blobJournal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	pending map[string][]journalRecord // Queued by an earlier run and not done, per repository
+	done    map[string]bool            // Repository, hash and path of every finished blob
+	names   map[*repository]string     // Journal names of the repositories seen
+}
+
+// journalKey identifies a blob of a repository in the journal.
+func journalKey(repository, hash, path string) string {
+	return repository + "\x00" + hash + "\x00" + path
+}
+
+/**
+ * @brief Opens the queue journal, replaying what an interrupted run left in it.
+ * @param path The --queue-file value.
+ * @return The queue and an error if the file cannot be read or opened for appending.
+ */
+func openBlobJournal(path string) (*blobJournal, error) {
+	q := &blobJournal{path: path, pending: make(map[string][]journalRecord), done: make(map[string]bool), names: make(map[*repository]string)}
+	if file, err := os.Open(path); err == nil {
+		var queued []journalRecord
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var r journalRecord
+			if json.Unmarshal(scanner.Bytes(), &r) != nil {
+				continue // Torn last line of a crashed run
+			}
+			if r.Op == "done" {
+				q.done[journalKey(r.Repository, r.Hash, r.Path)] = true
+			} else {
+				queued = append(queued, r)
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		count := 0
+		for _, r := range queued {
+			if !q.done[journalKey(r.Repository, r.Hash, r.Path)] {
+				q.pending[r.Repository] = append(q.pending[r.Repository], r)
+				count++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Go analyzer: --queue-file: resuming an interrupted run, %d blob(s) left in the queue, %d already scanned\n", count, len(q.done))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	q.file = file
+	return q, nil
+}
+
+// name returns the journal name of a repository (see repositoryName); the caller holds q.mu.
+func (q *blobJournal) name(repo *repository) string {
+	name, ok := q.names[repo]
+	if !ok {
+		name = repositoryName(repo)
+		q.names[repo] = name
+	}
+	return name
+}
+
+// append writes journal records with a single write; the caller holds q.mu.
+func (q *blobJournal) append(records ...journalRecord) {
+	var lines []byte
+	for _, r := range records {
+		line, _ := json.Marshal(r)
+		lines = append(append(lines, line...), '\n')
+	}
+	if _, err := q.file.Write(lines); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: writing --queue-file: %v\n", err)
+	}
+}
+
+/**
+ * @brief Takes the blobs an interrupted run queued for a repository but did not finish.
+ * @param repo The repository about to be scanned.
+ * @return The blobs to scan before walking the history (nil if none).
+ */
+func (q *blobJournal) drain(repo *repository) []fileBlob {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	name := q.name(repo)
+	var blobs []fileBlob
+	for _, r := range q.pending[name] {
+		blobs = append(blobs, fileBlob{hash: r.Hash, path: r.Path, commit: r.Commit, mode: r.Mode, diskPath: r.DiskPath, repo: repo})
+	}
+	delete(q.pending, name)
+	return blobs
+}
+
+/**
+ * @brief Journals the blobs of a repository before they are scanned.
+ * @param repo The repository.
+ * @param blobs The blobs planned for the pipeline.
+ * @return The blobs without those an earlier run (or the drain) already finished.
+ */
+func (q *blobJournal) enqueue(repo *repository, blobs []fileBlob) []fileBlob {
+	if q == nil {
+		return blobs
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	name := q.name(repo)
+	kept := make([]fileBlob, 0, len(blobs))
+	var records []journalRecord
+	for _, blob := range blobs {
+		if q.done[journalKey(name, blob.hash, blob.path)] {
+			continue
+		}
+		kept = append(kept, blob)
+		records = append(records, journalRecord{"queued", name, blob.hash, blob.path, blob.commit, blob.mode, blob.diskPath})
+	}
+	q.append(records...)
+	q.file.Sync()
+	if skipped := len(blobs) - len(kept); skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s%d blob(s) already scanned, skipped (--queue-file)\n", labelPrefix(repo.label), skipped)
+	}
+	return kept
+}
+
+/**
+ * @brief Journals a blob that has left the pipeline, its findings written.
+ */
+func (q *blobJournal) finish(blob fileBlob) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	name := q.name(blob.repo)
+	q.done[journalKey(name, blob.hash, blob.path)] = true
+	q.append(journalRecord{Op: "done", Repository: name, Hash: blob.hash, Path: blob.path})
+}
+
+/**
+ * @brief Closes the journal, removing it if the run completed.
+ * @param complete Whether the run finished without error.
+ */
+func (q *blobJournal) close(complete bool) {
+	if q == nil {
+		return
+	}
+	q.file.Close()
+	if complete {
+		os.Remove(q.path)
+	}
+}
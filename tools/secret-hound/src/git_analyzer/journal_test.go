@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlobJournalResumesAnInterruptedRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	repo := &repository{label: "app"}
+	blobs := []fileBlob{
+		{hash: "a1", path: "a.env", commit: "c1", repo: repo},
+		{hash: "b2", path: "b.env", commit: "c1", repo: repo},
+		{hash: "c3", path: "c.env", commit: "c2", repo: repo},
+	}
+
+	var q *blobJournal
+	captureStderr(t, func() {
+		var err error
+		if q, err = openBlobJournal(path); err != nil {
+			t.Fatal(err)
+		}
+		if kept := q.enqueue(repo, blobs); len(kept) != 3 {
+			t.Fatalf("first run kept %d blob(s), want 3", len(kept))
+		}
+		q.finish(blobs[0])
+		q.close(false) // Crashed
+	})
+	// A crash can leave half a record behind.
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"op":"done","repository":"app","ha`)
+	file.Close()
+
+	resumed := &repository{label: "app"}
+	var pending, kept []fileBlob
+	output := captureStderr(t, func() {
+		var err error
+		if q, err = openBlobJournal(path); err != nil {
+			t.Fatal(err)
+		}
+		pending = q.drain(resumed)
+		for _, blob := range pending {
+			q.finish(blob)
+		}
+		kept = q.enqueue(resumed, append(blobs, fileBlob{hash: "d4", path: "d.env", repo: resumed}))
+	})
+	if len(pending) != 2 || pending[0].hash != "b2" || pending[1].hash != "c3" || pending[1].commit != "c2" || pending[0].repo != resumed {
+		t.Errorf("pending = %+v, want b2 and c3 of the resumed repository", pending)
+	}
+	if q.drain(resumed) != nil {
+		t.Error("the pending blobs were drained twice")
+	}
+	if len(kept) != 1 || kept[0].hash != "d4" {
+		t.Errorf("kept = %+v, want only the blob the interrupted run never queued", kept)
+	}
+	if !strings.Contains(output, "2 blob(s) left in the queue, 1 already scanned") || !strings.Contains(output, "3 blob(s) already scanned, skipped") {
+		t.Errorf("unexpected stderr:\n%s", output)
+	}
+
+	q.close(true)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the journal of a completed run was not removed: %v", err)
+	}
+}
+
+func TestNilBlobJournalIsOff(t *testing.T) {
+	var q *blobJournal
+	blobs := []fileBlob{{hash: "a1", path: "a.env"}}
+	if q.drain(&repository{}) != nil || len(q.enqueue(&repository{}, blobs)) != 1 {
+		t.Error("a nil journal changed the queue")
+	}
+	q.finish(blobs[0])
+	q.close(true)
+}
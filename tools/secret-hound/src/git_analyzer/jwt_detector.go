@@ -0,0 +1,159 @@
+/**
+ * @file jwt_detector.go
+ * @brief Detects JSON Web Tokens and decodes their claims.
+ *
+ * The header and payload are decoded without verifying the signature. The
+ * finding carries the issuer, subject, audience, expiry and scopes so that
+ * responders immediately know the blast radius of the token and whether it
+ * has already expired. Expired tokens are reported with low severity.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// jwtPattern matches the three base64url segments of a JWT whose header and payload are JSON objects.
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)
+
+/**
+ * @struct jwtDetector
+ * @brief The "jwt" detector.
+ */
+type jwtDetector struct{}
+
+func (jwtDetector) name() string { return "jwt" }
+
+func (jwtDetector) detect(filePath string, content []byte) []detection {
+	if !bytes.Contains(content, []byte("eyJ")) {
+		return nil
+	}
+	now := time.Now()
+
+	var found []detection
+	for _, loc := range jwtPattern.FindAllIndex(content, -1) {
+		token := string(content[loc[0]:loc[1]])
+		meta, ok := decodeJWTClaims(token, now)
+		if !ok {
+			continue
+		}
+		severity := "high"
+		if meta["expired"] == "true" {
+			severity = "low"
+		}
+		found = append(found, detection{
+			ruleID:      "JWT",
+			description: "JSON Web Token" + jwtSummary(meta),
+			line:        bytes.Count(content[:loc[0]], []byte("\n")) + 1,
+			match:       token,
+			confidence:  "High",
+			severity:    severity,
+			metadata:    meta,
+		})
+	}
+	return found
+}
+
+/**
+ * @brief Decodes the header and payload of a JWT without verifying it.
+ * @param token The compact-serialized token.
+ * @param now The reference time for the expiry check.
+ * @return The extracted metadata, and false if the token is not decodable JSON.
+ */
+func decodeJWTClaims(token string, now time.Time) (map[string]string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims map[string]interface{}
+	if !decodeJWTSegment(parts[0], &header) || !decodeJWTSegment(parts[1], &claims) {
+		return nil, false
+	}
+
+	meta := map[string]string{"alg": header.Alg}
+	if header.Kid != "" {
+		meta["kid"] = header.Kid
+	}
+	for _, claim := range []string{"iss", "sub", "azp", "client_id"} {
+		if v, ok := claims[claim].(string); ok && v != "" {
+			meta[claim] = v
+		}
+	}
+	if aud := claimStrings(claims["aud"]); len(aud) > 0 {
+		meta["aud"] = strings.Join(aud, " ")
+	}
+	// OAuth uses a space-separated "scope"; Azure AD and others use "scp" or "scopes" arrays.
+	var scopes []string
+	for _, claim := range []string{"scope", "scp", "scopes"} {
+		scopes = append(scopes, claimStrings(claims[claim])...)
+	}
+	if len(scopes) > 0 {
+		meta["scopes"] = strings.Join(scopes, " ")
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		meta["issued_at"] = time.Unix(int64(iat), 0).UTC().Format(time.RFC3339)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		expiry := time.Unix(int64(exp), 0).UTC()
+		meta["expires"] = expiry.Format(time.RFC3339)
+		meta["expired"] = fmt.Sprint(expiry.Before(now))
+	} else {
+		meta["expires"] = "never"
+		meta["expired"] = "false"
+	}
+	return meta, true
+}
+
+func decodeJWTSegment(segment string, v interface{}) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	return err == nil && json.Unmarshal(raw, v) == nil
+}
+
+/**
+ * @brief Normalizes a claim that may be a string, a space-separated list, or an array.
+ */
+func claimStrings(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		return strings.Fields(x)
+	case []interface{}:
+		var out []string
+		for _, item := range x {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+/**
+ * @brief Builds a short description suffix from the most useful claims.
+ */
+func jwtSummary(meta map[string]string) string {
+	var parts []string
+	if iss := meta["iss"]; iss != "" {
+		parts = append(parts, "issuer "+iss)
+	}
+	if meta["expired"] == "true" {
+		parts = append(parts, "expired "+meta["expires"])
+	} else if meta["expires"] == "never" {
+		parts = append(parts, "no expiry")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
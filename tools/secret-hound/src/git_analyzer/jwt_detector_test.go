@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// signedJWT builds a compact JWT with a fake signature.
+func signedJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return segment(map[string]string{"alg": "RS256", "kid": "key-1"}) + "." + segment(claims) + ".c2lnbmF0dXJl"
+}
+
+func TestDecodeJWTClaims(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta, ok := decodeJWTClaims(signedJWT(t, map[string]interface{}{
+		"iss":   "https://login.example.com",
+		"aud":   []string{"api", "admin"},
+		"scope": "read write",
+		"scp":   []string{"admin"},
+		"exp":   now.Add(time.Hour).Unix(),
+	}), now)
+	if !ok {
+		t.Fatal("token not decoded")
+	}
+	for k, v := range map[string]string{
+		"alg": "RS256", "kid": "key-1", "iss": "https://login.example.com", "aud": "api admin",
+		"scopes": "read write admin", "expires": "2026-01-01T01:00:00Z", "expired": "false",
+	} {
+		if meta[k] != v {
+			t.Errorf("%s = %q, want %q", k, meta[k], v)
+		}
+	}
+
+	expired, _ := decodeJWTClaims(signedJWT(t, map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}), now)
+	if expired["expired"] != "true" {
+		t.Errorf("expired token: %v", expired)
+	}
+	forever, _ := decodeJWTClaims(signedJWT(t, map[string]interface{}{"sub": "svc"}), now)
+	if forever["expires"] != "never" || forever["expired"] != "false" {
+		t.Errorf("token without exp: %v", forever)
+	}
+	if _, ok := decodeJWTClaims("eyJub3Q.eyJqc29u.x", now); ok {
+		t.Error("a token with non-JSON segments was decoded")
+	}
+}
+
+func TestJWTDetectorSeverityByExpiry(t *testing.T) {
+	live := signedJWT(t, map[string]interface{}{"iss": "ci", "exp": time.Now().Add(time.Hour).Unix()})
+	stale := signedJWT(t, map[string]interface{}{"iss": "ci", "exp": time.Now().Add(-time.Hour).Unix()})
+	content := "live: " + live + "\n\nstale: \"" + stale + "\"\n"
+
+	found := (jwtDetector{}).detect("tokens.txt", []byte(content))
+	if len(found) != 2 {
+		t.Fatalf("%d detections, want 2", len(found))
+	}
+	if found[0].match != live || found[0].line != 1 || found[0].severity != "high" {
+		t.Errorf("live token: %+v", found[0])
+	}
+	if found[1].match != stale || found[1].line != 3 || found[1].severity != "low" {
+		t.Errorf("expired token: %+v", found[1])
+	}
+	if (jwtDetector{}).detect("a.txt", []byte("no tokens here")) != nil {
+		t.Error("detections in a file without tokens")
+	}
+}
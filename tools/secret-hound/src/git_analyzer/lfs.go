@@ -0,0 +1,110 @@
+/**
+ * @file lfs.go
+ * @brief Scanning the content of Git LFS objects instead of their pointers (--lfs).
+ *
+ * Files tracked by Git LFS are stored in history as small pointer files;
+ * the content lives in .git/lfs/objects once fetched. Large config dumps and
+ * archives end up in LFS as easily as media, so with --lfs a pointer blob is
+ * scanned with the content of the object it points to, if that object has
+ * been fetched (`git lfs fetch --all` makes every version available). Pointers
+ * whose object is missing, or larger than lfsMaxObject, are scanned as they
+ * are, and the run says how many objects were missing.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// lfsMaxObject is the largest LFS object scanned in place of its pointer.
+const lfsMaxObject = 100 << 20
+
+// lfsPointerVersion starts every LFS pointer file.
+var lfsPointerVersion = []byte("version https://git-lfs.github.com/spec/v1\n")
+
+// lfsOID matches the object id line of a pointer.
+var lfsOID = regexp.MustCompile(`(?m)^oid sha256:([0-9a-f]{64})$`)
+
+/**
+ * @struct lfsStore
+ * @brief Resolves LFS pointers to fetched objects (nil = --lfs off).
+ */
+type lfsStore struct {
+	mu      sync.Mutex
+	dirs    map[*repository]string // lfs/objects directory of each repository ("" = none)
+	missing atomic.Int64           // Pointers whose object was not fetched
+}
+
+func newLFSStore() *lfsStore {
+	return &lfsStore{dirs: make(map[*repository]string)}
+}
+
+/**
+ * @brief Returns the content of the LFS object a pointer blob points to.
+ * @param blob The blob.
+ * @param raw The blob's content.
+ * @return The object's content, or raw if the blob is not a pointer or the object is unavailable.
+ */
+func (s *lfsStore) resolve(blob fileBlob, raw []byte) []byte {
+	if s == nil || len(raw) > 1024 || !bytes.HasPrefix(raw, lfsPointerVersion) {
+		return raw
+	}
+	m := lfsOID.FindSubmatch(raw)
+	if m == nil {
+		return raw
+	}
+	oid := string(m[1])
+	dir := s.objectsDir(blob.repo)
+	if dir == "" {
+		s.missing.Add(1)
+		return raw
+	}
+	path := filepath.Join(dir, oid[0:2], oid[2:4], oid)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > lfsMaxObject {
+		s.missing.Add(1)
+		return raw
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		s.missing.Add(1)
+		return raw
+	}
+	return content
+}
+
+// objectsDir returns the lfs/objects directory of a repository, looked up once.
+func (s *lfsStore) objectsDir(repo *repository) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir, ok := s.dirs[repo]
+	if !ok {
+		if output, err := repo.command("rev-parse", "--git-common-dir").Output(); err == nil {
+			dir = filepath.Join(strings.TrimSpace(string(output)), "lfs", "objects")
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				dir = ""
+			}
+		}
+		s.dirs[repo] = dir
+	}
+	return dir
+}
+
+/**
+ * @brief Prints how many LFS objects were not available on stderr.
+ */
+func (s *lfsStore) report() {
+	if s == nil || s.missing.Load() == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: %d Git LFS object(s) not fetched or too large were scanned as pointers; run git lfs fetch --all to scan them\n", s.missing.Load())
+}
@@ -0,0 +1,152 @@
+/**
+ * @file literals.go
+ * @brief String-literal extraction from source files (--string-literals).
+ *
+ * In code-heavy repositories most entropy false positives are identifiers,
+ * hashes in lockfile-like tables and long expressions, while hard-coded
+ * credentials almost always sit in string literals. With --string-literals a
+ * small lexer masks everything in a source file except its string literals
+ * (quotes included) before the rule engine and detectors run. Masked bytes
+ * become spaces and newlines are kept, so lines and columns still point into
+ * the original file, and enrichment still sees the unmasked content. Rules
+ * that look at the code around a value, such as the name of the variable it
+ * is assigned to, only see the literal in this mode.
+ *
+ * The lexer knows comments, quotes, escapes and raw/template strings of the
+ * C family (C, C++, C#, Java, Kotlin, Scala, Swift, Go, Rust, JavaScript,
+ * TypeScript, PHP) and of the hash-comment languages (Python, Ruby, shell,
+ * Perl). Comments are masked as well. Configuration and data files (YAML,
+ * JSON, .env, ...) are not source code and are always scanned whole, as are
+ * files of unknown languages.
+ */
+
+package main
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+/**
+ * @struct literalSyntax
+ * @brief The lexical rules of a language family that delimit string literals.
+ */
+type literalSyntax struct {
+	lineComment  string // Starts a comment running to the end of the line
+	blockComment string // Starts a block comment ("" = none); blockEnd closes it
+	blockEnd     string
+	quotes       string // Characters that open a one-line string literal
+	multiline    string // Characters that open a literal that may span lines
+	rawMultiline bool   // Whether escapes are ignored in multiline literals (Go raw strings)
+	triple       bool   // Python triple-quoted strings
+}
+
+var (
+	cFamilySyntax = &literalSyntax{lineComment: "//", blockComment: "/*", blockEnd: "*/", quotes: `"'`}
+	goSyntax      = &literalSyntax{lineComment: "//", blockComment: "/*", blockEnd: "*/", quotes: `"'`, multiline: "`", rawMultiline: true}
+	jsSyntax      = &literalSyntax{lineComment: "//", blockComment: "/*", blockEnd: "*/", quotes: `"'`, multiline: "`"}
+	pythonSyntax  = &literalSyntax{lineComment: "#", quotes: `"'`, triple: true}
+	hashSyntax    = &literalSyntax{lineComment: "#", quotes: `"'`}
+)
+
+// literalSyntaxes maps source file extensions to their lexical rules.
+var literalSyntaxes = map[string]*literalSyntax{
+	".c": cFamilySyntax, ".h": cFamilySyntax, ".cc": cFamilySyntax, ".cpp": cFamilySyntax,
+	".cxx": cFamilySyntax, ".hpp": cFamilySyntax, ".cs": cFamilySyntax, ".java": cFamilySyntax,
+	".kt": cFamilySyntax, ".kts": cFamilySyntax, ".scala": cFamilySyntax, ".swift": cFamilySyntax,
+	".rs": cFamilySyntax, ".php": cFamilySyntax,
+	".go": goSyntax,
+	".js": jsSyntax, ".jsx": jsSyntax, ".mjs": jsSyntax, ".cjs": jsSyntax, ".ts": jsSyntax, ".tsx": jsSyntax,
+	".py": pythonSyntax,
+	".rb": hashSyntax, ".sh": hashSyntax, ".bash": hashSyntax, ".zsh": hashSyntax, ".pl": hashSyntax, ".pm": hashSyntax,
+}
+
+/**
+ * @brief Returns the lexical rules for a path, or nil if it is not source code.
+ */
+func literalSyntaxFor(filePath string) *literalSyntax {
+	return literalSyntaxes[strings.ToLower(path.Ext(filePath))]
+}
+
+/**
+ * @brief Masks everything but the string literals of a source file.
+ * @param content The source file.
+ * @param syntax The file's lexical rules.
+ * @return A copy of content of the same length in which every byte outside
+ *         a string literal is a space, except newlines.
+ */
+func maskToLiterals(content []byte, syntax *literalSyntax) []byte {
+	out := bytes.Repeat([]byte{' '}, len(content))
+	keep := func(from, to int) { copy(out[from:to], content[from:to]) }
+	for i, b := range content {
+		if b == '\n' {
+			out[i] = '\n'
+		}
+	}
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		switch {
+		case syntax.lineComment != "" && bytes.HasPrefix(rest, []byte(syntax.lineComment)):
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				return out
+			}
+			i += end
+		case syntax.blockComment != "" && bytes.HasPrefix(rest, []byte(syntax.blockComment)):
+			end := bytes.Index(rest[len(syntax.blockComment):], []byte(syntax.blockEnd))
+			if end < 0 {
+				return out
+			}
+			i += len(syntax.blockComment) + end + len(syntax.blockEnd)
+		case syntax.triple && (bytes.HasPrefix(rest, []byte(`"""`)) || bytes.HasPrefix(rest, []byte(`'''`))):
+			end := literalEnd(rest, 3, string(rest[:3]), true, true)
+			keep(i, i+end)
+			i += end
+		case strings.IndexByte(syntax.multiline, content[i]) >= 0:
+			end := literalEnd(rest, 1, string(content[i]), true, !syntax.rawMultiline)
+			keep(i, i+end)
+			i += end
+		case strings.IndexByte(syntax.quotes, content[i]) >= 0:
+			end := literalEnd(rest, 1, string(content[i]), false, true)
+			if end < 0 {
+				i++ // Not a literal, e.g. a Rust lifetime or an apostrophe in a heredoc
+				continue
+			}
+			keep(i, i+end)
+			i += end
+		default:
+			i++
+		}
+	}
+	return out
+}
+
+/**
+ * @brief Finds the end of a string literal.
+ * @param s Input starting at the opening delimiter.
+ * @param open The length of the opening delimiter.
+ * @param delim The closing delimiter.
+ * @param multiline Whether the literal may span lines.
+ * @param escapes Whether a backslash escapes the next byte.
+ * @return The length of the literal including both delimiters; the rest of the
+ *         input for an unterminated multi-line literal, and -1 for a one-line
+ *         literal not closed on its line.
+ */
+func literalEnd(s []byte, open int, delim string, multiline, escapes bool) int {
+	for j := open; j < len(s); j++ {
+		switch {
+		case escapes && s[j] == '\\':
+			j++
+		case s[j] == '\n' && !multiline:
+			return -1
+		case bytes.HasPrefix(s[j:], []byte(delim)):
+			return j + len(delim)
+		}
+	}
+	if multiline {
+		return len(s)
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaskToLiterals(t *testing.T) {
+	for _, tc := range []struct {
+		path, source string
+		want         []string // Kept literals
+		masked       []string // Text that must not survive
+	}{
+		{"main.go", "// key AKIA1\nx := \"AKIA2\" /* AKIA3 */\ny := `raw\\`\nz := 'q'\n",
+			[]string{`"AKIA2"`, "`raw\\`", "'q'"}, []string{"AKIA1", "AKIA3", "x :=", "y :="}},
+		{"app.ts", "const t = `multi\nline ${x}`; // note\nlet s = 'a\\'b';\n",
+			[]string{"`multi\nline ${x}`", `'a\'b'`}, []string{"note", "const", "let"}},
+		{"tool.py", "# comment 'AKIA1'\ndoc = \"\"\"multi\n'line'\"\"\"\nv = 'x'\n",
+			[]string{"\"\"\"multi\n'line'\"\"\"", "'x'"}, []string{"AKIA1", "doc =", "v ="}},
+		{"lib.rs", "fn f<'a>(s: &'a str) { let k = \"AKIA\"; }\n",
+			[]string{`"AKIA"`}, []string{"fn f", "let k"}},
+	} {
+		syntax := literalSyntaxFor(tc.path)
+		if syntax == nil {
+			t.Fatalf("%s: no syntax", tc.path)
+		}
+		got := string(maskToLiterals([]byte(tc.source), syntax))
+		if len(got) != len(tc.source) || strings.Count(got, "\n") != strings.Count(tc.source, "\n") {
+			t.Errorf("%s: masking moved lines or columns:\n%q", tc.path, got)
+		}
+		for _, literal := range tc.want {
+			if !strings.Contains(got, literal) {
+				t.Errorf("%s: literal %q was masked:\n%q", tc.path, literal, got)
+			}
+		}
+		for _, text := range tc.masked {
+			if strings.Contains(got, text) {
+				t.Errorf("%s: %q was not masked:\n%q", tc.path, text, got)
+			}
+		}
+	}
+}
+
+func TestDataFilesAreNotMasked(t *testing.T) {
+	for _, path := range []string{"config.yaml", ".env", "data.json", "Makefile"} {
+		if literalSyntaxFor(path) != nil {
+			t.Errorf("%s would be masked", path)
+		}
+	}
+	if literalSyntaxFor("SRC/Main.JAVA") != cFamilySyntax {
+		t.Error("extensions are not matched case-insensitively")
+	}
+}
+
+func TestUnterminatedLiterals(t *testing.T) {
+	got := string(maskToLiterals([]byte("x = `open\nsecret"), goSyntax))
+	if !strings.HasSuffix(got, "`open\nsecret") {
+		t.Errorf("an unterminated raw string was masked: %q", got)
+	}
+	got = string(maskToLiterals([]byte("it's \"ok\"\n"), hashSyntax))
+	if !strings.Contains(got, `"ok"`) {
+		t.Errorf("a stray quote swallowed the next literal: %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+/**
+ * @file logs.go
+ * @brief Collapsing of repeated secrets in committed log files (--normalize-logs).
+ *
+ * A debug log committed by mistake often prints the same token on thousands
+ * of lines, each stamped with its own time and request ID. Rules that match
+ * around the token (an Authorization header, a "token=" pair) then capture a
+ * different value every time, so neither --group-by secret nor the scan
+ * history see one secret. With --normalize-logs, findings in log-like blobs
+ * have timestamps and request/trace IDs stripped from their match, and
+ * findings of one blob left with the same rule and match are reported once,
+ * at the first line, with metadata log_occurrences and log_last_line. A blob
+ * is log-like when its path looks like a log or most of its first lines start
+ * with a timestamp. In a blob scanned in windows (--chunk-size), the first
+ * window decides whether the blob is a log, and a secret is reported by the
+ * first window that finds it and counted within that window.
+ */
+
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// logPaths are the usual names of log files and log directories.
+var logPaths = []string{
+	"*.log",
+	"*.log.[0-9]*",
+	"logs/",
+	"log/",
+}
+
+const (
+	logSampleLines    = 20 // Lines examined to decide whether content is a log
+	logTimestampShare = 2  // At least 1/logTimestampShare of them must start with a timestamp
+)
+
+// logTimestamp matches ISO 8601, Apache/nginx access log and syslog timestamps, bracketed or not.
+var logTimestamp = regexp.MustCompile(`\[?(?:\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?` +
+	`|\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}(?: [+-]\d{4})?` +
+	`|[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})\]?`)
+
+// logRequestID matches a request, trace or correlation ID together with its key.
+var logRequestID = regexp.MustCompile(`(?i)\b(?:x-)?(?:request|req|trace|span|correlation|transaction)[-_ ]?id["']?\s*[:=]\s*["']?[\w.-]+["']?`)
+
+/**
+ * @brief Reports whether a blob looks like a log file.
+ * @param path The blob's repository path.
+ * @param content The blob's content.
+ */
+func isLogLike(path string, content []byte) bool {
+	if matchAnyGlob(logPaths, path) {
+		return true
+	}
+	lines, stamped := 0, 0
+	for len(content) > 0 && lines < logSampleLines {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		lines++
+		if loc := logTimestamp.FindIndex(line); loc != nil && loc[0] == 0 {
+			stamped++
+		}
+	}
+	return lines > 1 && stamped*logTimestampShare >= lines
+}
+
+/**
+ * @brief Strips timestamps and request IDs from a match found in a log.
+ * @return The normalized match, or the match unchanged if nothing else would be left.
+ */
+func normalizeLogMatch(match string) string {
+	normalized := logRequestID.ReplaceAllString(logTimestamp.ReplaceAllString(match, " "), " ")
+	normalized = strings.Join(strings.Fields(normalized), " ")
+	if normalized == "" {
+		return match
+	}
+	return normalized
+}
+
+/**
+ * @brief Normalizes the findings of a log-like blob and collapses repeats.
+ * @param findings The findings of the blob, in scanner order.
+ * @return One finding per rule and normalized match, at its first line.
+ */
+func collapseLogFindings(findings []*finding) []*finding {
+	first := make(map[string]*finding)
+	count, last := make(map[string]int), make(map[string]int)
+	var kept []*finding
+	for _, f := range findings {
+		if normalized := normalizeLogMatch(f.Match); normalized != f.Match {
+			f.Match = normalized
+			setMetadata(f, "log_normalized", "true")
+		}
+		key := f.RuleID + "\x00" + f.Match
+		count[key]++
+		if f.Line > last[key] {
+			last[key] = f.Line
+		}
+		if rep, ok := first[key]; !ok {
+			first[key] = f
+			kept = append(kept, f)
+		} else if f.Line < rep.Line {
+			rep.Line = f.Line
+		}
+	}
+	for key, n := range count {
+		if n > 1 {
+			setMetadata(first[key], "log_occurrences", strconv.Itoa(n))
+			setMetadata(first[key], "log_last_line", strconv.Itoa(last[key]))
+		}
+	}
+	return kept
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestNormalizeLogMatch(t *testing.T) {
+	tests := []struct{ match, want string }{
+		{"2024-03-01T12:00:07.123Z Authorization: Bearer abc.def.ghi", "Authorization: Bearer abc.def.ghi"},
+		{"[01/Mar/2024:12:00:07 +0000] request_id=7f3a9c token=s3cr3tvalue", "token=s3cr3tvalue"},
+		{"Mar  1 12:00:07 host app: X-Request-Id: 42 key=AKIA1234", "host app: key=AKIA1234"},
+		{"ghp_0123456789abcdef", "ghp_0123456789abcdef"},
+		{"2024-03-01 12:00:07", "2024-03-01 12:00:07"}, // Nothing else: left as found
+	}
+	for _, tt := range tests {
+		if got := normalizeLogMatch(tt.match); got != tt.want {
+			t.Errorf("normalizeLogMatch(%q) = %q, want %q", tt.match, got, tt.want)
+		}
+	}
+}
+
+func TestIsLogLike(t *testing.T) {
+	stamped := []byte("2024-03-01 12:00:07 INFO started\n2024-03-01 12:00:08 DEBUG token=x\n\n2024-03-01 12:00:09 INFO done\n")
+	tests := []struct {
+		path    string
+		content []byte
+		want    bool
+	}{
+		{"var/app.log", nil, true},
+		{"app.log.1", nil, true},
+		{"deploy/logs/output.txt", nil, true},
+		{"notes/session.txt", stamped, true},
+		{"src/main.go", []byte("package main\n\nfunc main() {}\n"), false},
+		{"CHANGELOG.md", []byte("2024-03-01 12:00:07 release\n"), false}, // A single line is not a log
+	}
+	for _, tt := range tests {
+		if got := isLogLike(tt.path, tt.content); got != tt.want {
+			t.Errorf("isLogLike(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -14,14 +14,20 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 /**
@@ -29,170 +35,1582 @@ import (
  * @brief Represents a single version of a file (a blob) from a specific commit.
  */
 type fileBlob struct {
-	hash    string // The Git blob hash of the file content
-	path    string // The original path of the file in the repository
-	commit  string // The hash of the commit this version belongs to
+	hash     string      // The Git blob hash of the file content
+	path     string      // The original path of the file in the repository
+	commit   string      // The hash of the commit this version belongs to
+	diskPath string      // If set, content is read from this file instead of the object store
+	mode     string      // Octal git object mode ("" for working tree files)
+	repo     *repository // The repository the blob belongs to
+}
+
+/**
+ * @struct options
+ * @brief Holds the command-line configuration of a single analyzer run.
+ */
+type options struct {
+	houndCorePath string // Path to the C++ core scanner executable ("" with --engine native)
+	engine        string // Rule engine: "core" (C++ scanner) or "native" (built into the analyzer)
+	rulesPath     string // Custom rules file passed to the core scanner ("" = core default)
+	detectors     string // Native detectors to run: "all", "none" or a comma-separated list
+	enrichers     string // Enrichers to run: "all", "none" or a comma-separated list
+	policyPath    string // Policy file evaluated against every finding ("" = none)
+	groupBy       string // Collapse findings: "none" or "secret"
+	historyFile   string // Scan history file for `report trend` ("" = none)
+	secretIndex   string // Cross-repository index of secrets for `report incidents` ("" = none)
+	auditLog      string // Hash-chained log of the run's actions ("" = none)
+	coveragePath  string // File the coverage report is written to ("" = none)
+	resumeToken   string // Where a time-boxed run left off ("" = start over)
+	metricsOnly   bool   // Write aggregate statistics instead of findings
+	depth         int    // Maximum number of commits to walk
+	maxMemory     int64  // Budget in bytes for blob content in flight (0 = unlimited)
+
+	timeBudget time.Duration // Stop starting new scans after this long, riskiest blobs first (0 = none)
+	scanOrder  string        // Order blobs are scanned in: history or risk
+
+	prefilter       string // Blobs kept from the core when no rule can match: off, safe or aggressive
+	prefilterSample int64  // Bytes at each end of a large blob the aggressive prefilter checks
+
+	chunkSize    int64 // Blobs larger than this are scanned in windows of about this size (0 = whole)
+	chunkOverlap int64 // Bytes shared by consecutive windows
+
+	normalizeLogs bool // Strip timestamps and request IDs from matches in logs and collapse repeats
+
+	emailTo     stringList // Recipients of the summary report sent after the run
+	emailFrom   string     // Sender of the summary report
+	emailFormat string     // html (with a text alternative) or text
+	emailOn     string     // When to send: findings or always
+	smtpServer  string     // SMTP server, host:port
+	smtpTLS     string     // Connection security: starttls, tls or none
+
+	jiraURL       string     // Jira base URL issues are synchronized with ("" = off)
+	jiraProject   string     // Project key of the issues
+	jiraIssueType string     // Issue type of new issues
+	jiraLabels    stringList // Extra labels of new issues
+	jiraFields    stringList // "<field>=<template>" mappings, e.g. custom fields
+	jiraResolve   string     // Transition of issues whose secret was remediated ("" = none)
+	jiraReopen    string     // Transition of resolved issues whose secret came back ("" = none)
+
+	servicenowURL     string     // ServiceNow instance URL records are synchronized with ("" = off)
+	servicenowTable   string     // Table of the records
+	servicenowFields  stringList // "<field>=<template>" mappings
+	servicenowResolve string     // State of records whose secret was remediated ("" = none)
+	servicenowReopen  string     // State of closed records whose secret came back ("" = new record)
+
+	compareWith string // Other scanner run on the same range for comparison ("" = none)
+	queueFile   string // Journal of queued and scanned blobs to resume after a crash ("" = none)
+
+	quarantineFile string // Blobs the core scanner failed on, kept across runs ("" = this run only)
+
+	strict bool // Fail the run if any blob could not be read, scanned or written
+
+	profile     string // Preset the defaults come from (see profile.go; "" = none)
+	staged      bool   // Scan only the changes staged for commit instead of history
+	redact      bool   // Mask secrets in the output to their first characters
+	failOn      string // Fail the run on findings of at least this severity ("" = never)
+	base        string // Walk only commits not reachable from this ref ("" = the whole depth)
+	allRefs     bool   // Walk from every ref instead of HEAD
+	reflog      bool   // Also walk commits only reflogs point at
+	unreachable bool   // Also walk dangling commits
+	lfs         bool   // Scan the content of fetched Git LFS objects instead of their pointers
+
+	hook           string      // Push hook the analyzer runs as: pre-receive or pre-push ("" = none)
+	hookUpdates    []refUpdate // Ref updates of the push, read from stdin in hook mode
+	rejectTemplate string      // text/template file of the rejection message ("" = built in)
+
+	allowBypass   bool   // Let pushes whose tips carry a Hound-Bypass trailer through, audited
+	bypassPattern string // Ticket references accepted in the trailer ("" = any)
+
+	nestedRepos bool // Also scan bundles and repository directories committed in the repository
+
+	blame bool // Attribute each secret line to its author with git blame at the introducing commit
+
+	policyBundle      string // https:// or oci:// location of a signed rules and policy bundle ("" = none)
+	policyBundleKey   string // PEM Ed25519 public key the bundle is signed with
+	policyBundleCache string // Directory keeping the last verified bundle of each location
+
+	disableRules stringList // Rule ids whose findings are dropped
+	ruleSeverity stringList // "<rule id>=<severity>" overrides of default severities
+
+	allowlists       stringList // Extra allowlist files of known test/placeholder secrets
+	defaultAllowlist bool       // Include the built-in allowlist
+
+	managedInventory  string     // JSON Lines file of managed secret hashes ("" = none)
+	vaultPaths        stringList // Vault KV v2 paths whose secrets are managed
+	awsSecretsManager bool       // Treat every AWS Secrets Manager secret as managed
+
+	corporateDomains stringList // Email domains of the organization; other authors are flagged
+
+	objectEnc objectEncryption // Server-side encryption of s3:// and gs:// uploads
+
+	debugAddr string // Address serving pprof and expvar ("" = off)
+
+	autoscale  bool // Adapt the worker budget to scan latency and system load
+	maxWorkers int  // Upper bound of the worker budget with autoscale
+
+	autoRevoke        string // Revokers to run: "all", "none" or a comma-separated list
+	autoRevokeConfirm bool   // Revoke for real instead of reporting what would be revoked
+
+	alert         string // Services paged for live critical secrets at HEAD: "none" or pagerduty,opsgenie
+	alertSeverity string // Lowest severity that pages
+
+	includeWorktree   bool // Also scan the files currently on disk
+	worktreeUntracked bool // Include untracked files in the working tree scan
+	worktreeIgnored   bool // Include ignored files in the working tree scan
+
+	gitDir  string // Repository to analyze instead of the current directory (may be bare)
+	bundle  string // Git bundle unpacked and scanned instead of a repository ("" = none)
+	mailbox string // mbox or patch file replayed and scanned instead of a repository ("" = none)
+	image   string // Container image whose layers are scanned instead of a repository ("" = none)
+
+	autoDeepen bool // Run `git fetch --deepen` when a shallow clone lacks the requested history
+	dryRun     bool // Walk and deduplicate history but report a plan instead of scanning
+
+	commitCache  string       // File persisting the per-commit change cache ("" = memory only)
+	resultCache  string       // File persisting blob scan results across runs ("" = off)
+	resultLimit  int          // Most blobs kept in the result cache
+	stateURL     string       // s3:// or gs:// prefix holding the commit cache and history ("" = none)
+	coordinator  string       // Queue the blobs are published to instead of scanned ("" = off)
+	worker       string       // Queue published blobs are scanned from ("" = off)
+	coordStore   string       // Redis holding the claims of a distributed scan ("" = the queue's)
+	snapshot     string       // Scan the full tree at this ref instead of walking history
+	release      releaseRange // Scan only blobs introduced between two tags (zero = off)
+	shard        shardSpec    // Scan only the blobs of one CI shard (zero = all)
+	ignoreRevs   string       // File listing commits to skip ("" = .hound-ignore-revs at HEAD)
+	exportDir    string       // Directory receiving a copy of every blob with findings
+	contextLines int          // Lines of redacted context attached before and after each match
+	transcode    bool         // Convert UTF-16 and Latin-1 blobs to UTF-8 before scanning
+	merges       mergePolicy  // Which commits are walked and how merge commits are diffed
+	tmpDir       string       // Directory for temporary files (resolved by parseOptions)
+	keepTemp     bool         // Keep the input of failed core runs for debugging
+
+	decodeMinLength int  // Shortest base64/hex run that is decoded and rescanned (0 = off)
+	stringLiterals  bool // Scan only the string literals of source files
+
+	detectPII   string // PII kinds to detect: "all" or a comma-separated list ("" = off)
+	piiPatterns string // JSON file of extra PII patterns ("" = none)
+
+	externalDetectors stringList    // Commands run on every blob as extra detectors
+	externalTimeout   time.Duration // Longest an external detector may run on one blob
+
+	generated    string     // Handling of minified/generated files: scan, downrank or skip
+	notGenerated stringList // Path globs never treated as generated
+	linguist     bool       // Skip paths marked linguist-vendored/-generated in .gitattributes
+
+	remotes     stringList // Remote repository URLs to sweep
+	mirrorCache string     // Directory holding cached bare mirrors of remotes
+
+	workers       int // Global number of concurrent scans across all repositories
+	repoWorkers   int // Default per-repository cap on concurrent scans (0 = no extra cap)
+	parallelRepos int // Number of repositories swept at the same time
+	fetchWorkers  int // Blobs read concurrently per repository (see pipeline.go)
+	enrichWorkers int // Blobs enriched and written concurrently per repository
+}
+
+/**
+ * @struct analyzer
+ * @brief State shared by all repositories scanned in one run.
+ */
+type analyzer struct {
+	opts   options
+	budget *memoryBudget // Process-wide ceiling on blob content in flight
+	sched  *scheduler    // Process-wide worker budget
+	plan   scanPlan      // Totals collected by --dry-run
+	cache  *commitCache  // Changes of commits already walked, shared by all repositories
+	rules  *ruleSet      // Rules and scanning profiles (nil if no rules file was found)
+
+	detectors []detector     // Native detectors run on every blob
+	results   *resultCache   // Findings of blobs scanned by earlier runs (nil = off)
+	enrichers []enricher     // Enrichment chain run on every finding, in order
+	policy    *policySet     // Suppression, severity and gating rules (nil = none)
+	scope     string         // Option limiting the run to part of the history or rules ("" = complete, see scanScope)
+	scopeMu   sync.Mutex     // Guards scope, which a repository's walk may narrow
+	grouper   *secretGrouper // Holds findings for --group-by secret (nil = stream them)
+	mailer    *emailReport   // Findings emailed to --email-to after the run (nil = off)
+	jira      *jiraSink      // Issues synchronized with --jira-url after the run (nil = off)
+	runlog    *runRecorder   // Fingerprints appended to --history-file (nil = off)
+	incidents *secretIndex   // Locations of each secret in --secret-index (nil = off)
+	audit     *auditLog      // Actions appended to --audit-log (nil = off)
+	coverage  *scanCoverage  // Scope of the scan written to --coverage-report (nil = off)
+	metrics   *scanMetrics   // Counters written instead of findings with --metrics-only (nil = off)
+	engine    *nativeEngine  // Rule engine used instead of the core scanner (nil = core)
+	prefilter *corePrefilter // Spares the core blobs no rule can match (nil = off)
+	core      *coreInfo      // Result of the handshake with the core scanner
+	exporter  *blobExporter  // Copies blobs with findings to --export-blobs (nil = off)
+	revoker   *autoRevoker   // Revokes live secrets with --auto-revoke (nil = off)
+	alerter   *alerter       // Pages for live secrets at HEAD with --alert (nil = off)
+	state     *remoteState   // Commit cache and history mirrored to --state-url (nil = local)
+	scaler    *autoscaler    // Adapts the worker budget with --autoscale (nil = fixed)
+	dist      *distributed   // Queue and claims of --coordinator and --worker (nil = local scan)
+	deadline  *timeBudget    // Risk order and deadline of --time-budget (nil = none)
+	compare   *crossCheck    // Findings compared with --compare-with (nil = off)
+	journal   *blobJournal   // Blobs journaled to --queue-file (nil = off)
+	lfs       *lfsStore      // Resolves Git LFS pointers with --lfs (nil = off)
+	hook      *hookSession   // Push checked with --hook and its rejected findings (nil = off)
+
+	servicenow *serviceNowSink // Records synchronized with --servicenow-url after the run (nil = off)
+	quarantine *coreQuarantine // Blobs the core scanner failed on (nil with --engine native)
+}
+
+/**
+ * @struct stringList
+ * @brief A flag.Value collecting every occurrence of a repeatable flag.
+ */
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+/**
+ * @brief Parses command-line flags and positional arguments.
+ * @return The populated options struct. Exits the process on invalid input.
+ */
+func parseOptions() options {
+	var opts options
+	maxMemory := flag.String("max-memory", "", "Pause reading blobs while this much content is in flight (e.g. 512M, 2G)")
+	flag.BoolVar(&opts.includeWorktree, "include-worktree", false, "Also scan the current checkout in addition to history")
+	flag.BoolVar(&opts.worktreeUntracked, "worktree-untracked", true, "With --include-worktree, scan untracked files")
+	flag.BoolVar(&opts.worktreeIgnored, "worktree-ignored", false, "With --include-worktree, scan files matched by .gitignore")
+	flag.StringVar(&opts.gitDir, "git-dir", "", "Path to the repository to scan, e.g. a bare /srv/git/repo.git")
+	flag.StringVar(&opts.bundle, "bundle", "", "Scan the history in this git bundle `file` instead of a repository")
+	flag.StringVar(&opts.mailbox, "mailbox", "", "Scan the lines added by the patches in this mbox or format-patch `file`")
+	flag.StringVar(&opts.image, "image", "", "Scan the layers of this container image (registry `ref` or docker save archive)")
+	flag.BoolVar(&opts.autoDeepen, "auto-deepen", false, "Deepen a shallow clone up to the requested depth before scanning")
+	flag.Var(&opts.remotes, "remote", "Remote repository URL to sweep (repeatable)")
+	remotesFile := flag.String("remotes-file", "", "File with one remote repository URL per line to sweep")
+	flag.StringVar(&opts.mirrorCache, "mirror-cache", defaultMirrorCache(), "Directory for cached bare mirrors of swept remotes")
+	flag.IntVar(&opts.workers, "workers", 4, "Global number of concurrent blob scans")
+	flag.BoolVar(&opts.autoscale, "autoscale", false, "Start at --workers and adapt the worker count to scan latency and system load")
+	flag.IntVar(&opts.maxWorkers, "max-workers", 2*runtime.NumCPU(), "Upper bound of the worker count with --autoscale")
+	flag.IntVar(&opts.repoWorkers, "repo-workers", 0, "Default cap on concurrent scans per repository in sweeps (0 = --workers)")
+	flag.IntVar(&opts.parallelRepos, "parallel-repos", 2, "Number of repositories swept concurrently")
+	flag.IntVar(&opts.fetchWorkers, "fetch-workers", 2, "Blobs read from git concurrently per repository, ahead of the scan workers")
+	flag.IntVar(&opts.enrichWorkers, "enrich-workers", 2, "Blobs enriched and written to the output concurrently per repository")
+	outputFormat := flag.String("output-format", "json", "Finding encoding: json (JSON Lines), proto (length-delimited protobuf), msgpack, defectdojo (Generic Findings Import), aspm (one JSON document) or pretty (grouped, for people)")
+	lang := flag.String("lang", "", "Language of the pretty output: "+strings.Join(catalogLanguages(), ", ")+" (default: from LC_ALL, LC_MESSAGES or LANG)")
+	messagesFile := flag.String("messages", "", "JSON message catalog whose text replaces the built-in messages, e.g. an organization's wording")
+	outputPath := flag.String("output", "", "Write findings to this file, or upload them to an s3:// or gs:// URL, instead of stdout")
+	attestation := flag.String("attestation", "", "Write an in-toto statement of the scanned commit range, scanner and rules digest and findings digest to this `file`")
+	attestationKey := flag.String("attestation-key", "", "Sign the --attestation as a DSSE envelope with this PEM PKCS#8 Ed25519 private key `file`")
+	publish := flag.String("publish", "", "Also push the findings and their provenance to an OCI registry as an artifact, e.g. oci://registry/reports/repo:<commit>")
+	encryptTo := flag.String("encrypt-to", "", "Encrypt the --output file to this age recipient or OpenPGP public key file")
+	objectSSE := flag.String("object-sse", "", "Server-side encryption of s3:// and gs:// uploads: AES256 or aws:kms (default: the bucket's setting)")
+	objectKMSKey := flag.String("object-kms-key", "", "KMS key for object store uploads: an AWS key ID/ARN (implies aws:kms) or a GCS key resource name")
+	compress := flag.String("compress", "", "Compress the --output file: gzip or zstd (inferred from a .gz/.zst name)")
+	printVersionFlag := flag.Bool("version", false, "Print version and build information and exit")
+	printSchema := flag.Bool("print-schema", false, "Print the JSON Schema of the finding output and exit")
+	flag.StringVar(&opts.snapshot, "snapshot", "", "Scan every file in the tree at this ref (e.g. a release tag) instead of history")
+	betweenTags := flag.String("between-tags", "", "Scan only blobs introduced between two release tags: --between-tags <old> <new>")
+	flag.StringVar(&opts.ignoreRevs, "ignore-revs-file", "", "Skip the commits listed in this file instead of the repository's .hound-ignore-revs")
+	shard := flag.String("shard", "", "Scan only shard k of n of the blobs, partitioned by hash, e.g. 3/8 in the third of eight parallel jobs")
+	flag.BoolVar(&opts.merges.firstParent, "first-parent", false, "Walk only the first-parent chain and scan each merge against its first parent")
+	flag.BoolVar(&opts.merges.allParents, "include-merge-diffs", false, "Also scan each merge against every one of its parents")
+	flag.StringVar(&opts.commitCache, "commit-cache", "", "Persist the per-commit change cache in this file to speed up repeated walks")
+	flag.StringVar(&opts.resultCache, "result-cache", "", "Persist the findings of every scanned blob in this file and reuse them on later runs")
+	flag.IntVar(&opts.resultLimit, "result-cache-entries", 200000, "Most blobs kept in --result-cache; the least recently used are evicted")
+	flag.StringVar(&opts.stateURL, "state-url", "", "Keep the commit cache and scan history under this s3:// or gs:// prefix, for runners without persistent disk")
+	flag.StringVar(&opts.coordinator, "coordinator", "", "Publish the blobs to this redis:// or sqs:// queue for --worker instances instead of scanning them")
+	flag.StringVar(&opts.worker, "worker", "", "Scan blobs published by a --coordinator from this redis:// or sqs:// queue")
+	flag.StringVar(&opts.coordStore, "coordination-store", "", "Redis URL holding the finding claims of a distributed scan (default: the redis:// queue)")
+	flag.StringVar(&opts.debugAddr, "debug-addr", "", "Serve pprof and expvar diagnostics of the running scan on this address, e.g. localhost:6060")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "Walk history and report how much would be scanned, without running the scanner")
+	flag.StringVar(&opts.exportDir, "export-blobs", "", "Copy every blob with findings into this directory, with a manifest.jsonl")
+	flag.IntVar(&opts.contextLines, "context", 0, "Attach this many lines before and after each match, with the secret redacted")
+	flag.BoolVar(&opts.blame, "blame", false, "Run git blame on the line of each finding at the introducing commit and add its author (blame_author, blame_commit)")
+	flag.BoolVar(&opts.transcode, "transcode", true, "Detect UTF-16 and Latin-1 (Windows-1252) blobs and convert them to UTF-8 before scanning")
+	flag.StringVar(&opts.tmpDir, "tmp-dir", "", "Directory for temporary files, e.g. a tmpfs like /dev/shm (default $TMPDIR or the system temp dir)")
+	flag.BoolVar(&opts.keepTemp, "keep-temp-on-failure", false, "Keep the input of failed core scanner runs in --tmp-dir for debugging")
+	flag.StringVar(&opts.engine, "engine", "core", "Rule engine: core (the C++ scanner) or native (built in; no core path argument)")
+	flag.StringVar(&opts.prefilter, "prefilter", prefilterOff, "Check blobs against the rules in Go before starting the core: off, safe (skip only blobs no rule can match) or aggressive (check a sample of large blobs)")
+	prefilterSample := flag.String("prefilter-sample", "32K", "Bytes at each end of a large blob checked by --prefilter aggressive")
+	chunkSize := flag.String("chunk-size", "16M", "Stream blobs larger than this and scan them in overlapping windows of about this size (0 = read every blob whole)")
+	chunkOverlap := flag.String("chunk-overlap", "16K", "Bytes consecutive windows of a --chunk-size blob share, so secrets at a boundary are found")
+	flag.BoolVar(&opts.normalizeLogs, "normalize-logs", false, "In log files, strip timestamps and request IDs from matches and report each secret once per file")
+	flag.StringVar(&opts.rulesPath, "rules", "", "Rules file (JSON) for the core scanner and scanning profiles")
+	flag.Var(&opts.disableRules, "disable-rule", "Drop the findings of this rule id, from the core, the native engine or a detector (repeatable)")
+	flag.Var(&opts.ruleSeverity, "rule-severity", "Override the default severity of a rule: <rule id>=<severity>, e.g. GENERIC_HIGH_ENTROPY=low (repeatable)")
+	flag.IntVar(&opts.decodeMinLength, "decode-min-length", 32, "Decode and rescan base64/hex runs at least this long (0 disables)")
+	flag.BoolVar(&opts.stringLiterals, "string-literals", false, "In source files, scan only string literals and mask code and comments")
+	flag.StringVar(&opts.detectPII, "detect-pii", "", "Also report personal data: all, or a comma-separated list (email, phone, us-ssn, uk-nino, iban, credit-card)")
+	flag.StringVar(&opts.piiPatterns, "pii-patterns", "", "JSON `file` of extra PII patterns for --detect-pii ([{\"id\", \"description\", \"regex\", \"severity\"}])")
+	flag.Var(&opts.externalDetectors, "external-detector", "Run this `command` on every blob (content on stdin) and read findings from its JSON Lines output (repeatable)")
+	flag.DurationVar(&opts.externalTimeout, "external-detector-timeout", 30*time.Second, "Longest an --external-detector command may run on one blob")
+	flag.StringVar(&opts.generated, "generated", generatedDownrank, "Minified/generated files: scan, downrank (Low confidence) or skip")
+	flag.Var(&opts.notGenerated, "not-generated", "Path glob never treated as minified/generated (repeatable)")
+	flag.BoolVar(&opts.linguist, "linguist-attributes", true, "Skip paths marked linguist-vendored or linguist-generated in .gitattributes")
+	flag.StringVar(&opts.enrichers, "enrichers", "all", "Enrichers to run: all, none, or a comma-separated list (encoding, position, context, severity, allowlist, managed, visibility, author, blame, identity, owners)")
+	flag.Var(&opts.corporateDomains, "corporate-domain", "Email domain of the organization, e.g. example.com; findings from commits by authors outside it get author_external=true (repeatable)")
+	flag.Var(&opts.allowlists, "allowlist", "Allowlist file (JSON) of test/placeholder secrets demoted to info severity (repeatable)")
+	flag.BoolVar(&opts.defaultAllowlist, "default-allowlist", true, "Apply the built-in allowlist of documentation example keys and placeholders")
+	flag.StringVar(&opts.managedInventory, "managed-inventory", "", "JSON Lines file of {\"sha256\", \"source\"} hashes of secrets held in a secrets manager; matches become critical")
+	flag.Var(&opts.vaultPaths, "vault-path", "Vault KV v2 path (mount/path) whose secrets are matched against findings, using VAULT_ADDR and VAULT_TOKEN (repeatable)")
+	flag.BoolVar(&opts.awsSecretsManager, "aws-secrets-manager", false, "Match findings against every secret in AWS Secrets Manager, using AWS_* credentials from the environment")
+	flag.StringVar(&opts.alert, "alert", "none", "Open a PagerDuty and/or Opsgenie incident for secrets --auto-revoke finds live at HEAD: none, or pagerduty,opsgenie (PAGERDUTY_ROUTING_KEY, OPSGENIE_API_KEY)")
+	flag.StringVar(&opts.alertSeverity, "alert-severity", "critical", "Lowest severity of a live secret at HEAD that --alert pages for")
+	flag.StringVar(&opts.autoRevoke, "auto-revoke", "none", "Revoke live secrets as they are found: none, all, or a comma-separated list (github, aws); a dry run without --auto-revoke-confirm")
+	flag.BoolVar(&opts.autoRevokeConfirm, "auto-revoke-confirm", false, "Confirm --auto-revoke: actually revoke live secrets instead of reporting what would be revoked")
+	flag.StringVar(&opts.groupBy, "group-by", groupByNone, "Collapse findings: none, or secret (one finding per secret with all its occurrences)")
+	flag.BoolVar(&opts.metricsOnly, "metrics-only", false, "Write only aggregate statistics (counts by rule, severity, confidence) with no secret material")
+	flag.StringVar(&opts.historyFile, "history-file", "", "Append this run's secret fingerprints to a `file` read by git_analyzer report trend")
+	flag.Var(&opts.emailTo, "email-to", "Email the summary report to this `address` after the run (repeatable; SMTP_USERNAME and SMTP_PASSWORD authenticate)")
+	flag.StringVar(&opts.emailFrom, "email-from", "secret-hound@localhost", "Sender `address` of the --email-to report")
+	flag.StringVar(&opts.emailFormat, "email-format", "html", "Body of the --email-to report: html (with a plain-text alternative) or text")
+	flag.StringVar(&opts.emailOn, "email-on", "findings", "When to send the --email-to report: findings, or always (clean runs too)")
+	flag.StringVar(&opts.smtpServer, "smtp-server", "localhost:587", "SMTP server (host:port) the --email-to report is sent through")
+	flag.StringVar(&opts.smtpTLS, "smtp-tls", smtpStartTLS, "Connection to --smtp-server: starttls, tls (implicit, port 465) or none")
+	flag.StringVar(&opts.jiraURL, "jira-url", "", "Create and update one Jira issue per leaked secret at this base `URL` after the run (JIRA_USER and JIRA_API_TOKEN, or JIRA_TOKEN)")
+	flag.StringVar(&opts.jiraProject, "jira-project", "", "Key of the project --jira-url issues are created in")
+	flag.StringVar(&opts.jiraIssueType, "jira-issue-type", "Bug", "Issue type of the issues --jira-url creates")
+	flag.Var(&opts.jiraLabels, "jira-label", "Extra label of the issues --jira-url manages (repeatable)")
+	flag.Var(&opts.jiraFields, "jira-field", "Set an issue field from a template over the secret: <field>=<template>, e.g. customfield_10042={{.Severity}} (repeatable)")
+	flag.StringVar(&opts.jiraResolve, "jira-resolve-transition", "Done", "Transition of the issues of secrets --secret-index finds remediated (\"\" = leave them open)")
+	flag.StringVar(&opts.jiraReopen, "jira-reopen-transition", "", "Transition of resolved issues whose secret is found again, e.g. Reopen (\"\" = leave them)")
+	flag.StringVar(&opts.servicenowURL, "servicenow-url", "", "Create and update one ServiceNow record per leaked secret on this instance `URL` after the run (SERVICENOW_USER and SERVICENOW_PASSWORD, or SERVICENOW_TOKEN)")
+	flag.StringVar(&opts.servicenowTable, "servicenow-table", "sn_vul_vulnerable_item", "Table of the records --servicenow-url manages: Vulnerability Response's vulnerable items, or any task table")
+	flag.Var(&opts.servicenowFields, "servicenow-field", "Set a record field from a template over the secret: <field>=<template>, e.g. assignment_group=AppSec (repeatable)")
+	flag.StringVar(&opts.servicenowResolve, "servicenow-resolve-state", "3", "State of the records of secrets --secret-index finds remediated (\"\" = leave them open)")
+	flag.StringVar(&opts.servicenowReopen, "servicenow-reopen-state", "", "State of closed records whose secret is found again, e.g. 1 (\"\" = create a new record)")
+	flag.StringVar(&opts.secretIndex, "secret-index", "", "Index the locations of every secret across repositories in a `file` read by git_analyzer report incidents")
+	flag.StringVar(&opts.scanOrder, "scan-order", scanOrderHistory, "Order blobs are scanned in: history (newest commit first) or risk (credential and config files first)")
+	flag.StringVar(&opts.profile, "profile", "", "Preset of flags for a workflow: pre-commit, ci or deep-audit (other flags override it)")
+	flag.BoolVar(&opts.staged, "staged", false, "Scan only the changes staged for commit instead of history, e.g. in a pre-commit hook")
+	flag.BoolVar(&opts.redact, "redact", false, "Mask secrets in the output to their first characters")
+	flag.StringVar(&opts.failOn, "fail-on", "", "Exit with status 3 if a finding of at least this severity is written: info, low, medium, high or critical")
+	flag.StringVar(&opts.base, "base", "", "Walk only the commits not reachable from this ref (base..HEAD), or auto for the target branch of the CI merge request")
+	flag.BoolVar(&opts.allRefs, "all-refs", false, "Walk from every branch, tag and other ref instead of HEAD")
+	flag.BoolVar(&opts.reflog, "reflog", false, "Also walk commits only reflogs point at (amended, reset or rebased away)")
+	flag.BoolVar(&opts.unreachable, "unreachable", false, "Also walk dangling commits found by git fsck (dropped stashes, expired reflogs)")
+	flag.BoolVar(&opts.lfs, "lfs", false, "Scan the content of fetched Git LFS objects instead of their pointer files")
+	flag.StringVar(&opts.hook, "hook", "", "Run as a git pre-receive or pre-push hook: scan only the pushed commits (ref updates on stdin) and reject the push on findings")
+	flag.StringVar(&opts.rejectTemplate, "reject-template", "", "Go text/template `file` of the message shown when --hook rejects a push")
+	flag.BoolVar(&opts.allowBypass, "allow-bypass", false, "With --hook, accept a push with findings if the tip of every pushed ref has a \"Hound-Bypass: <ticket>\" trailer, and record it in the --audit-log")
+	flag.StringVar(&opts.bypassPattern, "bypass-ticket-pattern", "", "Regular expression a Hound-Bypass ticket must match, e.g. SEC-[0-9]+ (default: any value)")
+	flag.BoolVar(&opts.nestedRepos, "nested-repos", true, "Also scan the history of git bundles and repository directories committed inside the scanned repository")
+	flag.BoolVar(&opts.strict, "strict", false, "Exit with status 4 if any blob could not be read, scanned or written (listed at the end of the run)")
+	flag.StringVar(&opts.quarantineFile, "quarantine-file", "", "Keep the blobs the core scanner crashed on in this file; they are scanned with the native engine on later runs")
+	flag.StringVar(&opts.queueFile, "queue-file", "", "Journal queued and scanned blobs in this file; after a crash, the next run scans the unfinished blobs first and skips finished ones")
+	flag.StringVar(&opts.compareWith, "compare-with", "", "After the scan, run gitleaks or trufflehog on the same commits and report the findings only one of the tools found")
+	flag.DurationVar(&opts.timeBudget, "time-budget", 0, "Scan the riskiest and newest blobs first and start no new scan after this long, e.g. 10m (partial scan)")
+	flag.StringVar(&opts.resumeToken, "resume-token", "", "Continue a scan cut short by --time-budget, skipping the blobs it scanned")
+	flag.StringVar(&opts.coveragePath, "coverage-report", "", "Write which refs, commits and filters were scanned and every skipped blob to this JSON `file`")
+	flag.StringVar(&opts.auditLog, "audit-log", "", "Append who ran the scan, its configuration and what it covered to this hash-chained `file`")
+	flag.StringVar(&opts.policyPath, "policy", "", "Policy file (JSON) of conditions that suppress findings, change their severity or fail the run")
+	flag.StringVar(&opts.policyBundle, "policy-bundle", "", "Fetch the rules, policy and allowlist from this signed bundle at startup (https://host/bundle.tar.gz or oci://registry/repository:tag)")
+	flag.StringVar(&opts.policyBundleKey, "policy-bundle-key", "", "PEM Ed25519 public key `file` the --policy-bundle must be signed with")
+	flag.StringVar(&opts.policyBundleCache, "policy-bundle-cache", defaultPolicyBundleCache(), "Directory keeping the last verified --policy-bundle, used when it cannot be fetched")
+	flag.StringVar(&opts.detectors, "detectors", "all", "Native detectors to run: all, none, or a comma-separated list (config, pem, jwt, docker, terraform, ci)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer [options] <path_to_hound_core> <depth>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer [options] --snapshot <ref> <path_to_hound_core>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer [options] --between-tags <old> <new> <path_to_hound_core>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer [options] --engine native <depth>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer doctor [options] [path_to_hound_core]")
+		fmt.Fprintln(os.Stderr, "       git_analyzer report trend --history-file <file> [options]")
+		fmt.Fprintln(os.Stderr, "       git_analyzer report incidents --secret-index <file> [options]")
+		fmt.Fprintln(os.Stderr, "       git_analyzer triage --secret-index <file> --status <status> <fingerprint>...")
+		fmt.Fprintln(os.Stderr, "       git_analyzer bench [options] [path_to_hound_core]")
+		fmt.Fprintln(os.Stderr, "       git_analyzer audit-verify <audit log>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer import [options] <gitleaks or trufflehog report>")
+		fmt.Fprintln(os.Stderr, "       git_analyzer daemon (--schedule <cron> | --schedule-file <file>) [-- scan options]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Merge commits: by default every reachable commit is walked and a merge only")
+		fmt.Fprintln(os.Stderr, "contributes files whose merged content differs from all of its parents")
+		fmt.Fprintln(os.Stderr, "(conflict resolutions); branch changes are scanned in the branch's own commits.")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Every flag can also be set as SECRET_HOUND_<FLAG> (e.g. SECRET_HOUND_GIT_DIR), and")
+		fmt.Fprintln(os.Stderr, "the positional arguments as SECRET_HOUND_CORE and SECRET_HOUND_DEPTH.")
+		fmt.Fprintln(os.Stderr, "")
+		flag.PrintDefaults()
+	}
+	if err := applyProfile(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --profile: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyEnvFlags(flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	flag.CommandLine.Parse(joinBetweenTagsArgs(os.Args[1:]))
+
+	if *printVersionFlag {
+		printVersion(os.Stdout)
+		os.Exit(0)
+	}
+	if *printSchema {
+		fmt.Print(findingSchema)
+		os.Exit(0)
+	}
+	var err error
+	if opts.objectEnc, err = parseObjectEncryption(*objectSSE, *objectKMSKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --object-sse: %v\n", err)
+		os.Exit(1)
+	}
+	// The sinks below stage files in it, so it is resolved first.
+	if opts.tmpDir, err = resolveTempDir(opts.tmpDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --tmp-dir: %v\n", err)
+		os.Exit(1)
+	}
+	var destination io.Writer = os.Stdout
+	if *outputPath != "" {
+		path, compression, err := resolveSinkPath(*outputPath, *compress)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --compress: %v\n", err)
+			os.Exit(1)
+		}
+		var encryptCmd *exec.Cmd
+		if *encryptTo != "" {
+			scheme, err := detectEncryptionScheme(*encryptTo)
+			if err == nil {
+				err = verifyRecipient(scheme, *encryptTo)
+			}
+			if err == nil {
+				encryptCmd, err = encryptionCommand(scheme, *encryptTo)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --encrypt-to: %v\n", err)
+				os.Exit(1)
+			}
+			path = encryptedPath(path, scheme)
+		}
+		if isObjectURL(path) {
+			destination, err = openObjectSink(path, opts.objectEnc, compression, encryptCmd)
+		} else {
+			destination, err = openFileSink(path, compression, encryptCmd)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --output: %v\n", err)
+			os.Exit(1)
+		}
+	} else if *compress != "" {
+		fmt.Fprintln(os.Stderr, "Error: --compress requires --output")
+		os.Exit(1)
+	} else if *encryptTo != "" {
+		fmt.Fprintln(os.Stderr, "Error: --encrypt-to requires --output")
+		os.Exit(1)
+	}
+	if opts.hook != "" && *outputPath == "" {
+		// A pre-receive hook's stdout goes to the pusher; the rejection message is what they see.
+		destination = io.Discard
+	}
+	if opts.metricsOnly && *outputFormat != "json" {
+		fmt.Fprintln(os.Stderr, "Error: --metrics-only writes a JSON record; --output-format must be json")
+		os.Exit(1)
+	}
+	if messages, err = loadCatalog(*lang, *messagesFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --messages: %v\n", err)
+		os.Exit(1)
+	}
+	if *attestation != "" {
+		if findingsAttestation, err = newScanAttestation(*attestation, *attestationKey, *outputPath, *outputFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --attestation: %v\n", err)
+			os.Exit(1)
+		}
+		destination = findingsAttestation.wrap(destination)
+	} else if *attestationKey != "" {
+		fmt.Fprintln(os.Stderr, "Error: --attestation-key requires --attestation")
+		os.Exit(1)
+	}
+	if *publish != "" {
+		if findingsPublisher, err = newReportPublisher(*publish, *outputFormat, destination, opts.tmpDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --publish: %v\n", err)
+			os.Exit(1)
+		}
+		destination = findingsPublisher
+	}
+	writer, err := newFindingWriter(destination, *outputFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --output-format: %v\n", err)
+		os.Exit(1)
+	}
+	findingsSink = writer
+	closeSinkOnSignal()
+
+	if *betweenTags != "" {
+		if opts.release, err = parseReleaseRange(*betweenTags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --between-tags: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *shard != "" {
+		if opts.shard, err = parseShard(*shard); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --shard: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Snapshots, release audits and staged changes do not walk to a depth, so
+	// it is optional; bundles, mailboxes, base..HEAD ranges and pushes are
+	// walked in full unless one is given, and images have no history.
+	// The native engine needs no core path.
+	if opts.engine != "core" && opts.engine != "native" {
+		fmt.Fprintf(os.Stderr, "Error: --engine: unknown engine %q (want core or native)\n", opts.engine)
+		os.Exit(1)
+	}
+	if opts.hook != "" {
+		if opts.hookUpdates, err = parseHookInput(opts.hook, os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --hook: %v\n", err)
+			os.Exit(1)
+		}
+		if opts.failOn == "" {
+			opts.failOn = "low" // Reject on anything that is not allowlisted
+		}
+	}
+	if opts.base == baseAuto {
+		if opts.base = ciBaseRef(); opts.base == "" {
+			fmt.Fprintln(os.Stderr, "Go analyzer: --base auto: not a merge or pull request build; walking the full depth")
+		}
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		args = envPositionalArgs(opts.engine)
+	}
+	required := 2
+	if opts.snapshot != "" || opts.release.to != "" || opts.bundle != "" || opts.mailbox != "" || opts.image != "" || opts.staged || opts.base != "" || opts.hook != "" {
+		required--
+	}
+	if opts.engine == "native" {
+		required--
+	}
+	if len(args) < required || (opts.engine == "core" && len(args) < 1) {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if opts.engine == "core" {
+		opts.houndCorePath, args = args[0], args[1:]
+	}
+	depthArg := ""
+	if len(args) > 0 {
+		depthArg = args[0]
+	}
+	depth, err := strconv.Atoi(depthArg)
+	if err != nil && depthArg == "" && (opts.bundle != "" || opts.mailbox != "" || opts.base != "" || opts.hook != "") {
+		depth = math.MaxInt32 // The whole unpacked history, or every commit since the base or of the push
+	} else if err != nil {
+		depth = 100 // Default to a safe depth if parsing fails
+	}
+	opts.depth = depth
+
+	if *maxMemory != "" {
+		opts.maxMemory, err = parseByteSize(*maxMemory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --max-memory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.chunkSize, err = parseByteSize(*chunkSize); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --chunk-size: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.chunkOverlap, err = parseByteSize(*chunkOverlap); err != nil || (opts.chunkSize > 0 && opts.chunkOverlap*4 > opts.chunkSize) {
+		fmt.Fprintln(os.Stderr, "Error: --chunk-overlap: want a size of at most a quarter of --chunk-size")
+		os.Exit(1)
+	}
+	if opts.prefilter != prefilterOff && opts.prefilter != prefilterSafe && opts.prefilter != prefilterAggressive {
+		fmt.Fprintf(os.Stderr, "Error: --prefilter: unknown level %q (want off, safe or aggressive)\n", opts.prefilter)
+		os.Exit(1)
+	}
+	if opts.prefilterSample, err = parseByteSize(*prefilterSample); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --prefilter-sample: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.generated, err = parseGeneratedMode(opts.generated); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --generated: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.scanOrder, err = parseScanOrder(opts.scanOrder); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --scan-order: %v\n", err)
+		os.Exit(1)
+	}
+	if (opts.staged || opts.base != "" || customHistoryRevs(opts)) && (opts.snapshot != "" || opts.release.to != "") {
+		fmt.Fprintln(os.Stderr, "Error: --staged, --base, --all-refs, --reflog and --unreachable cannot be combined with --snapshot or --between-tags")
+		os.Exit(1)
+	}
+	if opts.hook != "" && (opts.staged || opts.base != "" || opts.allRefs || opts.reflog || opts.unreachable ||
+		opts.bundle != "" || opts.mailbox != "" || opts.image != "" || len(opts.remotes) > 0) {
+		fmt.Fprintln(os.Stderr, "Error: --hook scans the pushed commits of the repository it runs in; it cannot be combined with --staged, --base, --all-refs, --reflog, --unreachable, --bundle, --mailbox, --image or --remote")
+		os.Exit(1)
+	}
+	if opts.rejectTemplate != "" && opts.hook == "" {
+		fmt.Fprintln(os.Stderr, "Error: --reject-template requires --hook")
+		os.Exit(1)
+	}
+	if opts.allowBypass && (opts.hook == "" || opts.auditLog == "") {
+		fmt.Fprintln(os.Stderr, "Error: --allow-bypass requires --hook and an --audit-log to record bypasses in")
+		os.Exit(1)
+	}
+	if opts.staged && customHistoryRevs(opts) {
+		fmt.Fprintln(os.Stderr, "Error: --staged scans no history; it cannot be combined with --base, --all-refs, --reflog or --unreachable")
+		os.Exit(1)
+	}
+	if *remotesFile != "" {
+		urls, err := readLines(*remotesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --remotes-file: %v\n", err)
+			os.Exit(1)
+		}
+		opts.remotes = append(opts.remotes, urls...)
+	}
+	return opts
 }
 
 /**
  * @brief Main entry point for the Git analyzer.
  */
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: git_analyzer <path_to_hound_core> <depth>")
+	if len(os.Args) > 1 && os.Args[1] == "decode" {
+		os.Exit(runDecode(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(runDoctor(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "triage" {
+		os.Exit(runTriage(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit-verify" {
+		os.Exit(runAuditVerify(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		os.Exit(runDaemon(os.Args[2:]))
+	}
+
+	opts := parseOptions()
+	if opts.policyBundle != "" {
+		bundle, err := loadPolicyBundle(opts.policyBundle, opts.policyBundleKey, opts.policyBundleCache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --policy-bundle: %v\n", err)
+			os.Exit(1)
+		}
+		bundle.apply(&opts)
+		fmt.Fprintf(os.Stderr, "Go analyzer: using policy bundle %.12s from %s\n", bundle.digest, opts.policyBundle)
+	}
+	a := &analyzer{
+		opts:   opts,
+		budget: newMemoryBudget(opts.maxMemory),
+		sched:  newScheduler(opts.workers),
+	}
+	if opts.debugAddr != "" {
+		if err := startDebugServer(opts.debugAddr, a); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --debug-addr: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Profiles come from the same rules file the core scanner uses; without
+	// one, the rules built into the binary are used.
+	var rules *ruleSet
+	var err error
+	switch {
+	case opts.rulesPath != "":
+		rules, err = loadRuleSet(opts.rulesPath)
+	case opts.engine == "core":
+		rules, err = loadRuleSet(defaultRulesPath(opts.houndCorePath))
+		if os.IsNotExist(err) {
+			rules, err = embeddedRuleSet()
+		}
+	default:
+		rules, err = embeddedRuleSet()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --rules: %v\n", err)
 		os.Exit(1)
 	}
-	houndCorePath := os.Args[1]
-	depthStr := os.Args[2]
-	depth, err := strconv.Atoi(depthStr)
+	if err = rules.addOverrides(opts.disableRules, opts.ruleSeverity); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	a.rules = rules
+	if opts.engine == "native" {
+		a.engine = newNativeEngine(rules)
+	} else {
+		if a.core, err = handshakeCore(opts.houndCorePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if opts.rulesPath != "" && !a.core.has("rules") {
+			fmt.Fprintf(os.Stderr, "Error: --rules: core scanner %s does not accept a rules file\n", a.core.Version)
+			os.Exit(1)
+		}
+		if a.prefilter, err = newCorePrefilter(opts.prefilter, int(opts.prefilterSample), rules); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --prefilter: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if removed := cleanOrphanedTempFiles(a.opts.tmpDir); removed > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: removed %d orphaned temporary file(s) from %s\n", removed, a.opts.tmpDir)
+	}
+
+	if opts.stateURL != "" {
+		if a.state, err = openRemoteState(opts.stateURL, opts.objectEnc, a.opts.tmpDir); err == nil && opts.commitCache == "" {
+			opts.commitCache, err = a.state.file(stateCommitCache)
+		}
+		if err == nil && opts.historyFile == "" {
+			opts.historyFile, err = a.state.file(stateHistory)
+		}
+		if err == nil && opts.secretIndex == "" {
+			opts.secretIndex, err = a.state.file(stateSecretIndex)
+		}
+		if err == nil && opts.quarantineFile == "" && opts.engine == "core" {
+			opts.quarantineFile, err = a.state.file(stateQuarantine)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --state-url: %v\n", err)
+			os.Exit(1)
+		}
+		a.opts.commitCache, a.opts.historyFile, a.opts.secretIndex = opts.commitCache, opts.historyFile, opts.secretIndex
+	}
+
+	if opts.engine == "core" && !opts.dryRun {
+		if a.quarantine, err = loadCoreQuarantine(opts.quarantineFile, rules); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --quarantine-file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if a.cache, err = loadCommitCache(opts.commitCache); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --commit-cache: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.exportDir != "" && !opts.dryRun {
+		if a.exporter, err = newBlobExporter(opts.exportDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --export-blobs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if a.detectors, err = selectDetectors(opts.detectors); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --detectors: %v\n", err)
+		os.Exit(1)
+	}
+	if pii, err := newPIIDetector(opts.detectPII, opts.piiPatterns); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --detect-pii: %v\n", err)
+		os.Exit(1)
+	} else if pii != nil {
+		a.detectors = append(a.detectors, pii)
+	}
+	if external, err := newExternalDetectors(opts.externalDetectors, opts.externalTimeout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --external-detector: %v\n", err)
+		os.Exit(1)
+	} else {
+		a.detectors = append(a.detectors, external...)
+	}
+
+	if opts.coordinator != "" || opts.worker != "" {
+		switch {
+		case opts.coordinator != "" && opts.worker != "":
+			err = fmt.Errorf("--coordinator and --worker are mutually exclusive")
+		case len(opts.remotes) > 0:
+			err = fmt.Errorf("a distributed scan covers a single repository, not --remote sweeps")
+		case opts.coordinator != "" && (opts.includeWorktree || opts.dryRun):
+			err = fmt.Errorf("--coordinator cannot be combined with --include-worktree or --dry-run")
+		default:
+			a.dist, err = openDistributed(opts.coordinator != "", opts.coordinator+opts.worker, opts.coordStore)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if opts.resultCache != "" && !opts.dryRun {
+		if a.results, err = loadResultCache(opts.resultCache, scannerFingerprint(a), opts.resultLimit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --result-cache: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	allow, err := loadAllowlist(opts.defaultAllowlist, opts.allowlists)
 	if err != nil {
-		depth = 100 // Default to a safe depth if parsing fails
+		fmt.Fprintf(os.Stderr, "Error: --allowlist: %v\n", err)
+		os.Exit(1)
+	}
+	managed, err := loadManagedInventory(opts.managedInventory, opts.vaultPaths, opts.awsSecretsManager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if a.enrichers, err = selectEnrichers(opts.enrichers, opts, allow, managed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --enrichers: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.autoRevokeConfirm && strings.TrimSpace(opts.autoRevoke) == "none" {
+		fmt.Fprintln(os.Stderr, "Error: --auto-revoke-confirm requires --auto-revoke")
+		os.Exit(1)
+	}
+	if a.revoker, err = newAutoRevoker(opts.autoRevoke, opts.autoRevokeConfirm); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --auto-revoke: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.policyPath != "" {
+		if a.policy, err = loadPolicySet(opts.policyPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --policy: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.failOn != "" {
+		if a.policy, err = a.policy.withFailOn(opts.failOn); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --fail-on: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	a.scope = scanScope(opts, rules, a.policy)
+	if !opts.dryRun {
+		a.runlog = newRunRecorder(opts.historyFile)
+		if a.incidents, err = loadSecretIndex(opts.secretIndex, a.scope); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --secret-index: %v\n", err)
+			os.Exit(1)
+		}
+		if a.alerter, err = newAlerter(opts.alert, opts.alertSeverity, a.revoker, a.incidents); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if a.jira, err = newJiraSink(&opts, a.incidents); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if a.servicenow, err = newServiceNowSink(&opts, a.incidents); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if (opts.timeBudget > 0 || opts.resumeToken != "") && !opts.dryRun {
+		if opts.worker != "" || opts.coordinator != "" {
+			fmt.Fprintln(os.Stderr, "Error: --time-budget and --resume-token cannot be combined with distributed scanning")
+			os.Exit(1)
+		}
+		if a.deadline, err = newTimeBudget(opts.timeBudget, opts.resumeToken); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --resume-token: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.queueFile != "" && !opts.dryRun {
+		if opts.image != "" || opts.worker != "" || opts.coordinator != "" {
+			fmt.Fprintln(os.Stderr, "Error: --queue-file cannot be combined with --image or distributed scanning")
+			os.Exit(1)
+		}
+		if a.journal, err = openBlobJournal(opts.queueFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --queue-file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.compareWith != "" && !opts.dryRun {
+		if opts.image != "" || len(opts.remotes) > 0 || opts.worker != "" || opts.coordinator != "" || opts.snapshot != "" || opts.release.to != "" {
+			fmt.Fprintln(os.Stderr, "Error: --compare-with compares history scans of one repository; it cannot be combined with --image, --remote, --snapshot, --between-tags or distributed scanning")
+			os.Exit(1)
+		}
+		if a.compare, err = newCrossCheck(opts.compareWith); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --compare-with: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.coveragePath != "" && !opts.dryRun {
+		a.coverage = newScanCoverage(opts.coveragePath)
+	}
+	if opts.metricsOnly {
+		if opts.exportDir != "" {
+			fmt.Fprintln(os.Stderr, "Error: --metrics-only cannot be combined with --export-blobs")
+			os.Exit(1)
+		}
+		a.metrics = newScanMetrics()
+	}
+	if a.grouper, err = newSecretGrouper(opts.groupBy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --group-by: %v\n", err)
+		os.Exit(1)
+	}
+	if a.mailer, err = newEmailReport(&opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// 1. Get a list of all file blobs from the git history.
-	blobs, err := getGitBlobs(depth)
+	if opts.lfs {
+		a.lfs = newLFSStore()
+	}
+	if opts.hook != "" {
+		if a.hook, err = newHookSession(opts.hook, opts.hookUpdates, opts.rejectTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --reject-template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if opts.allowBypass {
+		if a.hook.bypass, err = newBreakGlass(opts.bypassPattern); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --bypass-ticket-pattern: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if opts.autoscale && !opts.dryRun {
+		a.scaler = startAutoscaler(a.sched, opts.workers, opts.maxWorkers)
+	}
+	if opts.image != "" && (opts.gitDir != "" || opts.bundle != "" || opts.mailbox != "" || len(opts.remotes) > 0 ||
+		opts.includeWorktree || opts.worker != "" || opts.coordinator != "" || opts.snapshot != "" || opts.release.to != "") {
+		fmt.Fprintln(os.Stderr, "Error: --image cannot be combined with other inputs, --include-worktree, --snapshot, --between-tags or distributed scanning")
+		os.Exit(1)
+	}
+	local, unpacked, err := unpackInput(a.opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.auditLog != "" {
+		if a.audit, err = openAuditLog(opts.auditLog); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --audit-log: %v\n", err)
+			os.Exit(1)
+		}
+		a.audit.runStarted(a)
+	}
+	if opts.image != "" {
+		err = a.scanImage(opts.image)
+	} else if len(opts.remotes) > 0 {
+		err = a.sweepRemotes()
+	} else if opts.worker != "" {
+		err = a.runWorker(local)
+	} else {
+		err = a.scanRepository(local)
+		if err == nil {
+			if compareErr := a.compare.run(local, opts.depth, opts.tmpDir); compareErr != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: --compare-with: %v\n", compareErr)
+			}
+		}
+	}
+	var provenance reportProvenance
+	if findingsPublisher != nil {
+		scanned := local
+		if opts.image != "" || len(opts.remotes) > 0 || opts.worker != "" {
+			scanned = nil
+		}
+		provenance = a.reportProvenance(scanned)
+	}
+	if unpacked != "" {
+		os.RemoveAll(unpacked)
+	}
+	a.scaler.close()
+	if err == nil && opts.coordinator != "" {
+		if err = a.dist.finish(); err != nil {
+			err = fmt.Errorf("--coordination-store: %v", err)
+		}
+	}
+	partial := a.deadline.report()
+	a.quarantine.report()
+	a.lfs.report()
+	scanFailures.report()
+	if summary := a.prefilter.summary(); summary != "" {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s\n", summary)
+	}
+	a.journal.close(err == nil && !partial)
+	bypassed := a.hook.breakGlass(a.audit, os.Stderr)
+	a.audit.runFinished(err, partial)
+	if saveErr := a.coverage.save(err == nil && !partial); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: writing --coverage-report: %v\n", saveErr)
+	}
+	if opts.dryRun {
+		a.plan.print(os.Stdout)
+	}
+	if a.metrics != nil && !opts.dryRun {
+		record, _ := json.Marshal(a.metrics.finish())
+		if writeErr := findingsSink.queue(append(record, '\n')); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: writing metrics: %v\n", writeErr)
+		}
+	}
+	if a.grouper != nil {
+		for _, f := range a.grouper.collapse() {
+			if opts.redact {
+				f.Match = maskSecret(f.Match)
+			}
+			if writeErr := findingsSink.write(f); writeErr != nil {
+				scanFailures.record(&sinkError{sink: "findings output", err: writeErr})
+			}
+		}
+	}
+	if a.exporter != nil {
+		if closeErr := a.exporter.close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: writing export manifest: %v\n", closeErr)
+		}
+	}
+	if err == nil {
+		if saveErr := a.incidents.save(!partial); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: writing --secret-index: %v\n", saveErr)
+		}
+		// A failed, partial or scoped scan is incomplete; recording it would report
+		// false resolutions, and a report of it would read as the state of the repository.
+		if complete := !partial && a.runScope() == ""; !complete {
+			if a.runlog != nil || a.mailer != nil || a.jira != nil || a.servicenow != nil {
+				fmt.Fprintln(os.Stderr, "Go analyzer: incomplete or scoped scan, not recorded or reported (--history-file, --email-to, --jira-url, --servicenow-url)")
+			}
+		} else {
+			if saveErr := a.runlog.save(); saveErr != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: writing --history-file: %v\n", saveErr)
+			}
+			if sendErr := a.mailer.send(); sendErr != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: sending the --email-to report: %v\n", sendErr)
+			}
+			a.jira.sync() // After the index was saved: they resolve the secrets the save remediated
+			a.servicenow.sync()
+		}
+	}
+	if saveErr := a.cache.save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: saving commit cache: %v\n", saveErr)
+	}
+	if saveErr := a.results.save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: saving result cache: %v\n", saveErr)
+	}
+	if saveErr := a.quarantine.save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: writing --quarantine-file: %v\n", saveErr)
+	}
+	if saveErr := a.state.save(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: uploading state to --state-url: %v\n", saveErr)
+	}
+	if closeErr := findingsSink.close(); closeErr != nil {
+		fmt.Fprintf(os.Stderr, "Error: closing output: %v\n", closeErr)
+		os.Exit(1)
+	}
+	if err == nil {
+		if writeErr := findingsAttestation.write(a, findingsSink.written.Load(), partial); writeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: --attestation: %v\n", writeErr)
+			os.Exit(1)
+		}
+	}
+	if findingsPublisher != nil {
+		if err != nil {
+			findingsPublisher.discard() // An incomplete report is not published
+		} else if digest, publishErr := findingsPublisher.publish(provenance, findingsSink.written.Load(), partial); publishErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: --publish: %v\n", publishErr)
+			os.Exit(1)
+		} else {
+			fmt.Fprintf(os.Stderr, "Go analyzer: published the findings to %s@%s\n", findingsPublisher.ref, digest)
+		}
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting git blobs: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	
+	if opts.strict && scanFailures.count() > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: --strict: %d blob-level error(s)\n", scanFailures.count())
+		os.Exit(strictFailExitCode)
+	}
+	if !bypassed {
+		a.hook.reject(os.Stderr)
+	}
+	if a.policy != nil && a.policy.failures.Load() > 0 && !bypassed {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %d finding(s) failed the policy\n", a.policy.failures.Load())
+		os.Exit(policyFailExitCode)
+	}
+}
+
+/**
+ * @brief Scans the history of a single repository.
+ * @param repo The repository to scan.
+ * @return An error if the repository could not be enumerated.
+ */
+func (a *analyzer) scanRepository(repo *repository) error {
+	opts := a.opts
+	bare, err := repo.check()
+	if err != nil {
+		return err
+	}
+	if bare && opts.includeWorktree && repo.nesting == 0 {
+		return fmt.Errorf("--include-worktree cannot be used with a bare repository")
+	}
+
+	if repo.nesting == 0 {
+		if repo.ignoreRevs, err = loadIgnoreRevs(repo, opts.ignoreRevs); err != nil {
+			return fmt.Errorf("--ignore-revs-file: %v", err)
+		}
+		if len(repo.ignoreRevs) > 0 {
+			a.narrow(orDefault(opts.ignoreRevs, ignoreRevsFile)) // The skipped commits were not scanned
+		}
+	}
+
+	// Finish what an interrupted run left in the --queue-file first.
+	if pending := a.journal.drain(repo); len(pending) > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %sscanning %d blob(s) left queued by the interrupted run\n", labelPrefix(repo.label), len(pending))
+		a.runlog.begin(repo)
+		a.incidents.begin(repo)
+		a.runPipeline(repo, pending, nil, opts.workers)
+	}
+
+	// 1. Get a list of all file blobs from the git history, or from one tree.
+	var coverage historyCoverage
+	var blobs []fileBlob
+	if repo.nesting > 0 {
+		// The options selecting commits apply to the outer repository only.
+		var commits int
+		if blobs, commits, err = getNestedBlobs(repo, opts.depth, a.cache, opts.merges); err != nil {
+			return err
+		}
+		coverage = historyCoverage{requested: opts.depth, available: commits,
+			scope: fmt.Sprintf("all refs of a nested repository (%d commits, %d blobs)", commits, len(blobs))}
+	} else if opts.snapshot != "" {
+		var commit string
+		blobs, commit, err = getSnapshotBlobs(repo, opts.snapshot)
+		if err != nil {
+			return fmt.Errorf("--snapshot: %v", err)
+		}
+		coverage = historyCoverage{requested: 1, available: 1, scope: fmt.Sprintf("snapshot of %s (%.12s)", opts.snapshot, commit)}
+	} else if opts.staged {
+		if blobs, err = getStagedBlobs(repo); err != nil {
+			return fmt.Errorf("--staged: %v", err)
+		}
+		coverage = historyCoverage{scope: fmt.Sprintf("staged changes (%d files)", len(blobs))}
+	} else if opts.release.to != "" {
+		var commits int
+		blobs, commits, err = getReleaseBlobs(repo, opts.release, a.cache, opts.merges)
+		if err != nil {
+			return fmt.Errorf("--between-tags: %v", err)
+		}
+		coverage = historyCoverage{requested: commits, available: commits,
+			scope: fmt.Sprintf("release %s (%d commits, %d blobs introduced)", opts.release, commits, len(blobs))}
+	} else if customHistoryRevs(opts) {
+		var commits int
+		if blobs, commits, err = a.getHistoryBlobs(repo); err != nil {
+			return err
+		}
+		if err = a.hook.readBypass(repo); err != nil {
+			return fmt.Errorf("--allow-bypass: %v", err)
+		}
+		scope := strings.Join(historyRevs(opts), " ")
+		if opts.hook != "" {
+			scope = fmt.Sprintf("%s hook, %d ref update(s)", opts.hook, len(opts.hookUpdates))
+		}
+		coverage = historyCoverage{requested: opts.depth, available: commits,
+			scope: fmt.Sprintf("%s (%d commits, %d blobs)", scope, commits, len(blobs))}
+	} else {
+		// Make sure the requested depth is actually available (shallow CI clones).
+		coverage = ensureHistoryDepth(repo, opts.depth, opts.autoDeepen, opts.merges)
+		blobs, err = getGitBlobs(repo, opts.depth, a.cache, opts.merges)
+		if err != nil {
+			return fmt.Errorf("getting git blobs: %v", err)
+		}
+	}
+
+	// Optionally add the current checkout, so one run covers history and disk.
+	if opts.includeWorktree && repo.nesting == 0 {
+		worktreeBlobs, err := getWorktreeBlobs(repo, opts.worktreeUntracked, opts.worktreeIgnored)
+		if err != nil {
+			return fmt.Errorf("listing working tree files: %v", err)
+		}
+		blobs = append(blobs, worktreeBlobs...)
+	}
+	walked := blobs // Nested repositories are looked for before paths are filtered
+
+	a.coverage.begin(repo, coverage, coverageWalk(opts), coverageFilters(opts, repo), len(blobs))
+	if !coverage.entire() {
+		a.incidents.truncate(repo)
+	}
+
+	// Drop vendored and generated paths the way GitHub classifies them.
+	if opts.linguist {
+		var skipped []fileBlob
+		blobs, skipped = filterLinguistBlobs(repo, blobs)
+		if len(skipped) > 0 {
+			fmt.Fprintf(os.Stderr, "Go analyzer: %sskipped %d vendored/generated files (.gitattributes)\n", labelPrefix(repo.label), len(skipped))
+		}
+		for _, blob := range skipped {
+			a.coverage.skip(blob, "linguist")
+			a.incidents.skip(blob)
+		}
+	}
+
 	// Use a map to track scanned content hashes, preventing redundant scans of identical files.
+	// Symlinks are keyed separately since their content is checked differently.
 	scannedHashes := make(map[string]bool)
-	
-	// 2. Set up a concurrent pipeline using a work queue (buffered channel) and worker goroutines.
-	var wg sync.WaitGroup
-	blobChan := make(chan fileBlob, len(blobs))
-
-	numWorkers := 4 // A reasonable number of concurrent file scanners
-	wg.Add(numWorkers)
-
-	for i := 0; i < numWorkers; i++ {
-		go func() {
-			defer wg.Done()
-			for blob := range blobChan {
-				if _, exists := scannedHashes[blob.hash]; exists {
-					continue // Skip if this exact content has already been scanned
+	unique := blobs[:0:0]
+	for _, blob := range blobs {
+		key := blob.hash
+		if blob.mode == modeSymlink {
+			key += ":" + modeSymlink
+		}
+		if scannedHashes[key] {
+			continue // Skip if this exact content has already been scanned
+		}
+		scannedHashes[key] = true
+		unique = append(unique, blob)
+	}
+	a.coverage.skipCount(repo, "duplicate", len(blobs)-len(unique))
+	blobs = unique
+
+	// Keep only this job's share of a --shard split.
+	if opts.shard.count > 1 {
+		total := len(blobs)
+		blobs = opts.shard.filter(blobs)
+		a.coverage.skipCount(repo, "shard", total-len(blobs))
+		fmt.Fprintf(os.Stderr, "Go analyzer: %sshard %s: scanning %d of %d blobs\n", labelPrefix(repo.label), opts.shard, len(blobs), total)
+	}
+
+	if opts.scanOrder == scanOrderRisk {
+		blobs = orderByRisk(blobs)
+	}
+
+	// A coordinator hands the blobs to the workers (see distributed.go).
+	if a.dist != nil && a.dist.coordinator {
+		return a.dist.publish(blobs)
+	}
+
+	blobs = a.deadline.plan(repo, blobs)
+	if a.journal != nil {
+		planned := blobs
+		blobs = a.journal.enqueue(repo, blobs)
+		a.coverage.skipCount(repo, "resumed", len(planned)-len(blobs))
+		for i, kept := 0, 0; i < len(planned); i++ { // blobs is planned without the finished ones
+			if kept < len(blobs) && blobs[kept] == planned[i] {
+				kept++
+			} else {
+				a.incidents.skip(planned[i])
+			}
+		}
+	}
+
+	// Blob sizes are only needed when a memory ceiling is enforced, to find the
+	// blobs --chunk-size windows, or for a dry-run plan.
+	var blobSizes map[string]int64
+	if opts.maxMemory > 0 || opts.chunkSize > 0 || opts.dryRun {
+		hashes := make([]string, 0, len(blobs))
+		for _, blob := range blobs {
+			hashes = append(hashes, blob.hash)
+		}
+		blobSizes, err = getBlobSizes(repo, hashes)
+		if err != nil {
+			return fmt.Errorf("getting blob sizes: %v", err)
+		}
+		// Untracked working tree files are not in the object store.
+		for _, blob := range blobs {
+			if _, ok := blobSizes[blob.hash]; !ok && blob.diskPath != "" {
+				if info, err := os.Stat(longPath(blob.diskPath)); err == nil {
+					blobSizes[blob.hash] = info.Size()
 				}
-				scannedHashes[blob.hash] = true
-				
-				scanBlobContent(houndCorePath, blob)
 			}
-		}()
+		}
 	}
 
-	// 3. Feed the work queue with all the collected blobs.
-	for _, blob := range blobs {
-		blobChan <- blob
+	if opts.dryRun {
+		a.plan.add(repo.label, coverage.available, blobs, blobSizes)
+		return nil
+	}
+
+	a.runlog.begin(repo)
+	a.incidents.begin(repo)
+	debugRepositories.Add(1)
+	a.metrics.addRepository(coverage.available, len(blobs))
+
+	// 2. Run the blobs through the fetch, scan and enrich stages (see pipeline.go).
+	// The per-repository cap is the number of scan workers; the scheduler enforces
+	// the global budget and decides which repository's scan runs next.
+	numWorkers := opts.workers
+	if repo.workers > 0 {
+		numWorkers = repo.workers
+	} else if opts.repoWorkers > 0 {
+		numWorkers = opts.repoWorkers
+	} else if a.scaler != nil {
+		numWorkers = a.scaler.max // The scheduler decides how many of them run
 	}
-	close(blobChan) // Signal to workers that no more jobs will be added.
+	scanned := a.runPipeline(repo, blobs, blobSizes, numWorkers)
+
+	coverage.report(repo.label)
+	a.coverage.scanned(repo, scanned)
+	a.audit.repositoryScanned(repo, coverage, scanned)
+	findingsAttestation.repositoryScanned(repo, opts, coverage, scanned)
 
-	wg.Wait() // Wait for all worker goroutines to complete.
+	if opts.nestedRepos {
+		a.scanNestedRepos(repo, walked)
+	}
+	return nil
+}
+
+/**
+ * @brief Reads the content of a blob, either from disk or from the object store.
+ * @param blob The fileBlob to read.
+ * @return The raw content and an error if it could not be read.
+ */
+func readBlobContent(blob fileBlob) ([]byte, error) {
+	if blob.diskPath != "" {
+		return ioutil.ReadFile(longPath(blob.diskPath))
+	}
+	// Get the content of the blob from git using 'cat-file'.
+	return blob.repo.command("cat-file", "-p", blob.hash).Output()
 }
 
 /**
- * @brief Retrieves a list of all unique file blobs within the specified commit depth.
- * It parses the output of `git log` to find added/modified files and then uses
- * `git ls-tree` to get their corresponding blob hashes.
- * @param depth The maximum number of commits to look back.
- * @return A slice of fileBlob structs and an error if one occurred.
+ * @struct blobWork
+ * @brief One blob on its way through the pipeline stages.
  */
-func getGitBlobs(depth int) ([]fileBlob, error) {
-	cmd := exec.Command("git", "log", fmt.Sprintf("--max-count=%d", depth), "--name-status", "--pretty=format:COMMIT %H", "--no-renames")
-	
-	stdout, err := cmd.StdoutPipe()
+type blobWork struct {
+	blob           fileBlob
+	raw            []byte // Content as stored
+	content        []byte // Content as scanned (transcoded to UTF-8 if needed)
+	sourceEncoding string // Encoding the content was transcoded from ("" if none)
+	findings       []*finding
+
+	chunk     *chunkedBlob // The large blob this is a window of (nil = the whole blob)
+	window    int          // Index of the window in the blob
+	firstLine int          // Lines of the blob before the window
+}
+
+/**
+ * @brief Fetch stage: reads a blob and transcodes UTF-16 and Latin-1 text to UTF-8.
+ * @param blob The fileBlob to read.
+ * @return The work item, or a gitError if the blob could not be read.
+ */
+func (a *analyzer) fetchBlob(blob fileBlob) (*blobWork, error) {
+	raw, err := readBlobContent(blob)
 	if err != nil {
-		return nil, err
+		a.coverage.skip(blob, "unreadable")
+		a.incidents.skip(blob)
+		return nil, &gitError{op: "read", blob: blob, err: err}
 	}
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	raw = a.lfs.resolve(blob, raw)
+	debugBlobsScanned.Add(1)
+	debugBytesScanned.Add(int64(len(raw)))
+	w := &blobWork{blob: blob, raw: raw, content: raw}
+	if a.opts.transcode && blob.mode != modeSymlink {
+		switch encoding := detectEncoding(raw); encoding {
+		case encodingUTF16LE, encodingUTF16BE, encodingCP1252:
+			w.content, w.sourceEncoding = transcodeToUTF8(raw, encoding), encoding
+		}
 	}
+	return w, nil
+}
 
-	var blobs []fileBlob
-	var currentCommit string
-	scanner := bufio.NewScanner(stdout)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		
-		if len(parts) > 1 && parts[0] == "COMMIT" {
-			currentCommit = parts[1]
+/**
+ * @brief Scan stage: finds the secrets in a fetched blob.
+ * Findings from the content itself and from any base64/hex payloads embedded
+ * in it are collected. Minified and generated blobs are skipped or down-ranked
+ * according to --generated. Symbolic links only have their target checked for
+ * credentials.
+ * @param w The fetched blob; its findings are filled in.
+ * @return False if the blob is skipped.
+ */
+func (a *analyzer) scanBlob(w *blobWork) bool {
+	blob, content := w.blob, w.content
+	if blob.mode == modeSymlink {
+		profile := a.rules.profileFor(blob.path)
+		for _, det := range symlinkDetections(content) {
+			if f := newDetectorFinding(det, blob); profile.apply(f) && a.rules.applyOverride(f) {
+				w.findings = append(w.findings, f)
+			}
+		}
+		return true
+	}
+	generated := ""
+	if a.opts.generated != generatedScan {
+		generated = generatedReason(blob.path, content, a.opts.notGenerated)
+	}
+	if generated != "" && a.opts.generated == generatedSkip {
+		a.coverage.skip(blob, "generated")
+		a.incidents.skip(blob)
+		return false
+	}
+	cached, ok := []*finding(nil), false
+	if w.chunk == nil {
+		cached, ok = a.results.lookup(blob)
+	}
+	if ok {
+		w.findings = cached
+	} else {
+		if syntax := literalSyntaxFor(blob.path); a.opts.stringLiterals && syntax != nil {
+			content = maskToLiterals(content, syntax) // Enrichment still sees the whole file
+		}
+		found, err := a.scanContent(blob, content)
+		scanFailures.record(err)
+		w.findings = append(found, a.unwrapEncoded(blob, content)...)
+		if w.chunk != nil {
+			w.findings = w.chunk.place(w.findings, w.firstLine)
+		}
+		logLike := w.chunk != nil && w.chunk.log // Judged on the first window of a chunked blob
+		if w.chunk == nil {
+			logLike = a.opts.normalizeLogs && isLogLike(blob.path, content)
+		}
+		if logLike {
+			w.findings = collapseLogFindings(w.findings)
+		}
+		if w.chunk == nil {
+			a.results.store(blob, w.findings)
+		}
+	}
+	if generated != "" {
+		for _, f := range w.findings {
+			downrankGenerated(f, generated)
+		}
+	}
+	return true
+}
+
+/**
+ * @brief Enrich stage: enriches the findings of a scanned blob, applies the
+ * policy and writes the survivors to the findings sink.
+ * @param w The scanned blob.
+ */
+func (a *analyzer) finishBlob(w *blobWork) {
+	in := &enrichInput{blob: w.blob, content: w.content, sourceEncoding: w.sourceEncoding, firstLine: w.firstLine}
+	kept := w.findings[:0]
+	for _, f := range w.findings {
+		a.enrich(f, in)
+		if !a.policy.apply(f, w.blob) {
 			continue
 		}
-		
-		// We only care about Added ('A') or Modified ('M') files.
-		if len(parts) > 1 && (parts[0] == "A" || parts[0] == "M") {
-			filePath := parts[1]
-			// Get the blob hash for the file within its specific commit.
-			blobHashCmd := exec.Command("git", "ls-tree", currentCommit, filePath)
-			output, err := blobHashCmd.Output()
-			if err == nil {
-				treeParts := strings.Fields(string(output))
-				if len(treeParts) > 2 {
-					blobs = append(blobs, fileBlob{
-						hash:   treeParts[2],
-						path:   filePath,
-						commit: currentCommit,
-					})
-				}
-			}
+		a.revoker.handle(f)
+		a.alerter.handle(f, w.blob)
+		a.emit(f)
+		kept = append(kept, f)
+	}
+	if a.exporter != nil && len(kept) > 0 && w.chunk == nil {
+		if err := a.exporter.export(w.blob, w.raw, kept); err != nil {
+			scanFailures.record(&sinkError{sink: "export", blob: w.blob, err: err})
 		}
 	}
-	
-	if err := cmd.Wait(); err != nil {
-		// Suppress exit code 1, which can happen in empty repos.
-        if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-            // This is not a fatal error.
-        } else {
-		    return nil, err
-        }
+}
+
+/**
+ * @brief Runs the rule engine and the native detectors over one piece of content.
+ * @param blob The blob the content belongs to, used for Git context and profiles.
+ * @param content The bytes to scan.
+ * @return The findings that survived the blob's scanning profile, and a
+ * scannerError if the core failed on the content (the findings are then
+ * incomplete or come from the native fallback).
+ */
+func (a *analyzer) scanContent(blob fileBlob, content []byte) ([]*finding, error) {
+	var findings []*finding
+
+	var ruleFindings []*finding
+	var err error
+	start := time.Now()
+	if a.engine != nil {
+		ruleFindings = a.engine.scan(content, blob)
+	} else {
+		if a.prefilter.admit(content) {
+			ruleFindings, err = a.runCore(blob, content)
+		}
+	}
+	a.scaler.observe(time.Since(start))
+
+	profile := a.rules.profileFor(blob.path)
+	for _, f := range ruleFindings {
+		a.rules.fillConfidence(f)
+		if !profile.apply(f) || !a.rules.applyOverride(f) {
+			continue
+		}
+		enrichCloudAccount(f, content)
+		findings = append(findings, f)
+	}
+
+	// Run the native detectors on the same content.
+	for _, d := range a.detectors {
+		for _, det := range d.detect(blob.path, content) {
+			f := newDetectorFinding(det, blob)
+			if profile.apply(f) && a.rules.applyOverride(f) {
+				enrichCloudAccount(f, content)
+				findings = append(findings, f)
+			}
+		}
 	}
-	
-	return blobs, nil
+	return findings, err
 }
 
 /**
- * @brief Scans the content of a single Git blob for secrets.
- * It writes the blob's content to a temporary file and then executes the
- * C++ core scanner on that file.
- * @param houndCorePath The path to the C++ core scanner executable.
- * @param blob The fileBlob to scan.
+ * @brief Runs the C++ core scanner on content and parses its findings.
+ * The content is piped on stdin if the core supports it, and written to a
+ * temporary file otherwise. A blob the core fails on is quarantined and
+ * scanned with the native engine instead (see quarantine.go).
+ * @param blob The blob the content belongs to.
+ * @param content The bytes to scan.
+ * @return The core's findings with the blob's git context attached, and a
+ * scannerError if the core or its output failed.
  */
-func scanBlobContent(houndCorePath string, blob fileBlob) {
-	// Create a temporary file to hold the blob's content.
-	tmpfile, err := ioutil.TempFile("", "secret-hound-git-*.tmp")
-	if err != nil {
-		return
+func (a *analyzer) runCore(blob fileBlob, content []byte) ([]*finding, error) {
+	if a.quarantine.has(blob) {
+		return a.quarantine.scan(blob, content), nil
+	}
+	// Execute the C++ core scanner in its internal, single-file mode.
+	scanArgs := []string{"--scan-file", "-"}
+	var stdin io.Reader
+	tmpPath := ""
+	if a.core.has("stdin") {
+		stdin = bytes.NewReader(content)
+	} else {
+		// Create a temporary file to hold the blob's content.
+		var err error
+		if tmpPath, err = a.writeTempFile(content); err != nil {
+			return nil, &scannerError{scanner: "core", blob: blob, err: err}
+		}
+		scanArgs[1] = tmpPath
+	}
+	if a.opts.rulesPath != "" {
+		scanArgs = append(scanArgs, "--rules", a.opts.rulesPath)
 	}
-	defer os.Remove(tmpfile.Name())
+	scanCmd := exec.Command(a.opts.houndCorePath, scanArgs...)
+	scanCmd.Stdin = stdin
 
-	// Get the content of the blob from git using 'cat-file'.
-	contentCmd := exec.Command("git", "cat-file", "-p", blob.hash)
-	content, err := contentCmd.Output()
+	output, err := runTracked(scanCmd)
 	if err != nil {
-		return
+		a.quarantine.add(blob, err)
+		if a.opts.keepTemp {
+			if tmpPath == "" {
+				tmpPath, _ = a.writeTempFile(content)
+			}
+			if tmpPath != "" {
+				fmt.Fprintf(os.Stderr, "Go analyzer: kept input of blob %s (%s) in %s\n", blob.hash, blob.path, tmpPath)
+				tmpPath = ""
+			}
+		}
+	}
+	if tmpPath != "" {
+		os.Remove(tmpPath)
+	}
+	if err != nil {
+		return a.quarantine.scan(blob, content), &scannerError{scanner: "core", blob: blob, err: fmt.Errorf("%v; quarantined, scanned with the native engine", err)}
 	}
-	tmpfile.Write(content)
-	tmpfile.Close()
 
-	// Execute the C++ core scanner in its internal, single-file mode.
-	scanCmd := exec.Command(houndCorePath, "--scan-file", tmpfile.Name())
-	
-	output, err := scanCmd.Output()
+	findings, err := parseCoreOutput(output, blob)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Go analyzer: core scanner failed on blob %s: %v\n", blob.hash, err)
+		return findings, &scannerError{scanner: "core output", blob: blob, err: err}
+	}
+	return findings, nil
+}
+
+/**
+ * @brief Writes a finding to the findings sink, recording write errors as sinkErrors.
+ * @param f The finding to write.
+ */
+func (a *analyzer) emit(f *finding) {
+	if !a.dist.claim(f) {
+		return // Written by another worker that scanned the same blob
+	}
+	debugFindings.Add(1)
+	if a.opts.release.to != "" {
+		setMetadata(f, "release_range", a.opts.release.String())
+	}
+	a.runlog.record(f)
+	a.incidents.record(f)
+	a.compare.record(f)
+	a.hook.record(f)
+	a.mailer.record(f)
+	a.jira.record(f)
+	a.servicenow.record(f)
+	if a.metrics != nil {
+		a.metrics.addFinding(f)
 		return
 	}
-	
-	// Process each line of JSON output from the core scanner.
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		// Enrich the raw JSON finding with Git context and print it.
-		// The result is a new, more detailed JSON object.
-		fmt.Printf("{\"commit\": \"%s\", \"original_path\": \"%s\", %s\n",
-			blob.commit,
-			blob.path,
-			scanner.Text()[1:], // Efficiently skip the opening '{' of the inner JSON.
-		)
+	if a.grouper != nil {
+		a.grouper.add(f)
+		return
+	}
+	if a.opts.redact {
+		f.Match = maskSecret(f.Match)
+	}
+	if err := findingsSink.write(f); err != nil {
+		a.dist.release(f) // Left to a redelivery of the blob
+		scanFailures.record(&sinkError{sink: "findings output", err: err})
 	}
 }
@@ -0,0 +1,300 @@
+/**
+ * @file managed.go
+ * @brief Cross-check of findings against a secrets manager inventory.
+ *
+ * A secret that is stored in Vault or AWS Secrets Manager and also found in
+ * git is the worst case: a production credential that was supposed to be
+ * managed has leaked. The analyzer builds an in-memory set of SHA-256 hashes
+ * of the managed values and flags findings whose match hashes into it with
+ * critical severity and metadata managed_secret naming where it is stored.
+ * Only hashes are kept and compared; the values fetched from a secrets
+ * manager are discarded as soon as they are hashed.
+ *
+ * Inventory sources (all optional, combinable):
+ *   --managed-inventory FILE   JSON Lines of {"sha256": "...", "source": "..."}, e.g.
+ *                              exported by a job that has secrets manager access
+ *   --vault-path MOUNT/PATH    Vault KV v2 subtree (VAULT_ADDR, VAULT_TOKEN)
+ *   --aws-secrets-manager      Every secret in AWS Secrets Manager (AWS_* credentials)
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// managedHTTPTimeout bounds each request to a secrets manager.
+const managedHTTPTimeout = 30 * time.Second
+
+/**
+ * @struct managedInventory
+ * @brief Hashes of managed secret values and where each is stored.
+ */
+type managedInventory struct {
+	sources map[string]string // Hex SHA-256 of the value -> storage location
+}
+
+/**
+ * @brief Hashes a secret value for inventory comparison.
+ * Surrounding whitespace and quotes are ignored, as in the allowlist.
+ */
+func managedHash(value string) string {
+	sum := sha256.Sum256([]byte(strings.Trim(strings.TrimSpace(value), `"'`)))
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * @brief Adds a value to the inventory; short values are ignored as too generic.
+ */
+func (inv *managedInventory) addValue(value, source string) {
+	if len(strings.TrimSpace(value)) >= 8 {
+		inv.sources[managedHash(value)] = source
+	}
+}
+
+/**
+ * @brief Adds a secret string, and each string field if it is a JSON object.
+ */
+func (inv *managedInventory) addSecretString(secret, source string) {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(secret), &fields) == nil {
+		for key, value := range fields {
+			if s, ok := value.(string); ok {
+				inv.addValue(s, source+"#"+key)
+			}
+		}
+		return
+	}
+	inv.addValue(secret, source)
+}
+
+/**
+ * @brief Builds the inventory from the configured sources.
+ * @param file A JSON Lines inventory file ("" for none).
+ * @param vaultPaths Vault KV v2 subtrees to read.
+ * @param awsSecretsManager Whether to read every AWS Secrets Manager secret.
+ * @return The inventory (nil if no source is configured) and an error naming the failing source.
+ */
+func loadManagedInventory(file string, vaultPaths []string, awsSecretsManager bool) (*managedInventory, error) {
+	if file == "" && len(vaultPaths) == 0 && !awsSecretsManager {
+		return nil, nil
+	}
+	inv := &managedInventory{sources: make(map[string]string)}
+	if file != "" {
+		if err := inv.loadFile(file); err != nil {
+			return nil, fmt.Errorf("--managed-inventory: %v", err)
+		}
+	}
+	client := &http.Client{Timeout: managedHTTPTimeout}
+	for _, path := range vaultPaths {
+		if err := inv.loadVault(client, path); err != nil {
+			return nil, fmt.Errorf("--vault-path %s: %v", path, err)
+		}
+	}
+	if awsSecretsManager {
+		if err := inv.loadAWSSecretsManager(client); err != nil {
+			return nil, fmt.Errorf("--aws-secrets-manager: %v", err)
+		}
+	}
+	return inv, nil
+}
+
+/**
+ * @brief Reads a JSON Lines file of pre-computed hashes.
+ */
+func (inv *managedInventory) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry struct {
+			SHA256 string `json:"sha256"`
+			Source string `json:"source"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil || len(entry.SHA256) != 64 {
+			return fmt.Errorf("%s:%d: expected {\"sha256\": \"<64 hex digits>\", \"source\": \"...\"}", path, n)
+		}
+		inv.sources[strings.ToLower(entry.SHA256)] = orDefault(entry.Source, path)
+	}
+	return scanner.Err()
+}
+
+/**
+ * @brief Reads every secret below a Vault KV v2 path.
+ * @param path "mount/sub/path"; the first element is the KV mount.
+ */
+func (inv *managedInventory) loadVault(client *http.Client, path string) error {
+	addr, token := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"), os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	mount, prefix, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	get := func(url string, out interface{}) (int, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("X-Vault-Token", token)
+		if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+			req.Header.Set("X-Vault-Namespace", ns)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp.StatusCode, nil
+		}
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+	}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		var list struct {
+			Data struct {
+				Keys []string `json:"keys"`
+			} `json:"data"`
+		}
+		status, err := get(addr+"/v1/"+mount+"/metadata/"+dir+"?list=true", &list)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusNotFound && dir == prefix && prefix != "" {
+			// The path is a single secret rather than a folder.
+			return inv.readVaultSecret(get, addr, mount, prefix)
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("listing %s/%s: HTTP %d", mount, dir, status)
+		}
+		for _, key := range list.Data.Keys {
+			child := strings.TrimPrefix(dir+"/"+key, "/")
+			if strings.HasSuffix(key, "/") {
+				if err := walk(strings.TrimSuffix(child, "/")); err != nil {
+					return err
+				}
+			} else if err := inv.readVaultSecret(get, addr, mount, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(prefix)
+}
+
+func (inv *managedInventory) readVaultSecret(get func(string, interface{}) (int, error), addr, mount, path string) error {
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	status, err := get(addr+"/v1/"+mount+"/data/"+path, &secret)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("reading %s/%s: HTTP %d", mount, path, status)
+	}
+	for key, value := range secret.Data.Data {
+		if s, ok := value.(string); ok {
+			inv.addValue(s, "vault:"+mount+"/"+path+"#"+key)
+		}
+	}
+	return nil
+}
+
+/**
+ * @brief Reads every secret in AWS Secrets Manager in the configured region.
+ */
+func (inv *managedInventory) loadAWSSecretsManager(client *http.Client) error {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	region := awsRegionFromEnv("us-east-1")
+	call := func(target string, input, output interface{}) error {
+		body, _ := json.Marshal(input)
+		req, err := http.NewRequest("POST", "https://secretsmanager."+region+".amazonaws.com/", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "secretsmanager."+target)
+		if err := signAWSRequest(req, creds, region, "secretsmanager", time.Now()); err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("%s: HTTP %d: %s", target, resp.StatusCode, strings.TrimSpace(string(msg)))
+		}
+		return json.NewDecoder(resp.Body).Decode(output)
+	}
+
+	token := ""
+	for {
+		input := map[string]interface{}{"MaxResults": 100}
+		if token != "" {
+			input["NextToken"] = token
+		}
+		var page struct {
+			SecretList []struct {
+				ARN  string `json:"ARN"`
+				Name string `json:"Name"`
+			} `json:"SecretList"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := call("ListSecrets", input, &page); err != nil {
+			return err
+		}
+		for _, s := range page.SecretList {
+			var value struct {
+				SecretString string `json:"SecretString"`
+			}
+			if err := call("GetSecretValue", map[string]string{"SecretId": s.ARN}, &value); err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: aws-secrets-manager: skipping %s: %v\n", s.Name, err)
+				continue
+			}
+			inv.addSecretString(value.SecretString, "aws-secrets-manager:"+s.Name)
+		}
+		if token = page.NextToken; token == "" {
+			return nil
+		}
+	}
+}
+
+// managedEnricher flags findings whose value is a managed secret.
+type managedEnricher struct{ inv *managedInventory }
+
+func (managedEnricher) name() string { return "managed" }
+
+func (e managedEnricher) enrich(f *finding, in *enrichInput) {
+	if e.inv == nil {
+		return
+	}
+	if source, ok := e.inv.sources[managedHash(f.Match)]; ok {
+		f.Severity = "critical"
+		setMetadata(f, "managed_secret", source)
+	}
+}
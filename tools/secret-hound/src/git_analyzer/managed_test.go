@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManagedInventoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.jsonl")
+	line, _ := json.Marshal(map[string]string{"sha256": strings.ToUpper(managedHash("prod-db-password")), "source": "vault:kv/db"})
+	os.WriteFile(path, append(line, []byte("\n\n{\"sha256\": \""+managedHash("no-source-value")+"\"}\n")...), 0o600)
+	inv, err := loadManagedInventory(path, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.sources[managedHash(`"prod-db-password"`)] != "vault:kv/db" || inv.sources[managedHash("no-source-value")] != path {
+		t.Errorf("inventory %v", inv.sources)
+	}
+
+	os.WriteFile(path, []byte("{\"sha256\": \"abc\"}\n"), 0o600)
+	if _, err := loadManagedInventory(path, nil, false); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("a short hash: %v", err)
+	}
+	if inv, err := loadManagedInventory("", nil, false); inv != nil || err != nil {
+		t.Errorf("no sources: %v, %v", inv, err)
+	}
+}
+
+func TestManagedSecretStrings(t *testing.T) {
+	inv := &managedInventory{sources: make(map[string]string)}
+	inv.addSecretString(`{"username": "svc", "password": "correct-horse-battery"}`, "aws:db")
+	inv.addSecretString("plain-api-token-123", "aws:api")
+	inv.addValue("short", "aws:pin")
+	if inv.sources[managedHash("correct-horse-battery")] != "aws:db#password" || inv.sources[managedHash("plain-api-token-123")] != "aws:api" {
+		t.Errorf("inventory %v", inv.sources)
+	}
+	if _, ok := inv.sources[managedHash("svc")]; ok {
+		t.Error("a short value was added")
+	}
+	if _, ok := inv.sources[managedHash("short")]; ok {
+		t.Error("a short value was added")
+	}
+}
+
+func TestManagedInventoryFromVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			w.Write([]byte(`{"data": {"keys": ["db", "nested/"]}}`))
+		case "/v1/kv/metadata/app/nested":
+			w.Write([]byte(`{"data": {"keys": ["api"]}}`))
+		case "/v1/kv/data/app/db":
+			w.Write([]byte(`{"data": {"data": {"password": "vault-db-password"}}}`))
+		case "/v1/kv/data/app/nested/api":
+			w.Write([]byte(`{"data": {"data": {"token": "vault-api-token", "port": 5432}}}`))
+		case "/v1/kv/data/single":
+			w.Write([]byte(`{"data": {"data": {"key": "vault-single-key"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	inv, err := loadManagedInventory("", []string{"kv/app", "kv/single"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for value, source := range map[string]string{
+		"vault-db-password": "vault:kv/app/db#password",
+		"vault-api-token":   "vault:kv/app/nested/api#token",
+		"vault-single-key":  "vault:kv/single#key",
+	} {
+		if got := inv.sources[managedHash(value)]; got != source {
+			t.Errorf("%s: source %q, want %q", value, got, source)
+		}
+	}
+
+	t.Setenv("VAULT_TOKEN", "s.expired")
+	if _, err := loadManagedInventory("", []string{"kv/app"}, false); err == nil || !strings.Contains(err.Error(), "--vault-path kv/app") {
+		t.Errorf("a rejected token: %v", err)
+	}
+}
+
+func TestManagedSecretsBecomeCritical(t *testing.T) {
+	inv := &managedInventory{sources: make(map[string]string)}
+	inv.addValue("leaked-prod-token", "vault:kv/prod#token")
+	a := &analyzer{}
+	a.enrichers, _ = selectEnrichers("severity,managed", options{}, nil, inv)
+
+	managed := &finding{Match: "'leaked-prod-token'", Confidence: "Low"}
+	a.enrich(managed, &enrichInput{})
+	if managed.Severity != "critical" || managed.Metadata["managed_secret"] != "vault:kv/prod#token" {
+		t.Errorf("a managed secret: %+v", managed)
+	}
+	other := &finding{Match: "unrelated-token", Confidence: "Low"}
+	a.enrich(other, &enrichInput{})
+	if other.Severity != "low" || other.Metadata["managed_secret"] != "" {
+		t.Errorf("an unmanaged secret: %+v", other)
+	}
+}
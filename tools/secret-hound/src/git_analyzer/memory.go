@@ -0,0 +1,131 @@
+/**
+ * @file memory.go
+ * @brief Memory accounting for blob content held by the scanning pipeline.
+ *
+ * The producer reserves the size of every blob before handing it to a worker
+ * and the worker releases it once the scan has finished. When the configured
+ * budget is exhausted the producer blocks, which keeps the amount of blob
+ * content in flight bounded regardless of how large the repository is.
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * @struct memoryBudget
+ * @brief A blocking counter of bytes currently held by in-flight blobs.
+ */
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64 // Maximum number of bytes allowed in flight (0 = unlimited)
+	inUse int64 // Number of bytes currently reserved
+}
+
+/**
+ * @brief Creates a new memory budget.
+ * @param limit The maximum number of bytes allowed in flight, or 0 for no limit.
+ * @return A pointer to the initialized memoryBudget.
+ */
+func newMemoryBudget(limit int64) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+/**
+ * @brief Reserves n bytes, blocking until enough of the budget is free.
+ * A single blob larger than the whole budget is still admitted once nothing
+ * else is in flight, so oversized files are scanned alone instead of deadlocking.
+ * @param n The number of bytes to reserve.
+ */
+func (b *memoryBudget) acquire(n int64) {
+	if b.limit <= 0 {
+		return
+	}
+	b.mu.Lock()
+	for b.inUse > 0 && b.inUse+n > b.limit {
+		b.cond.Wait()
+	}
+	b.inUse += n
+	b.mu.Unlock()
+}
+
+/**
+ * @brief Returns n previously reserved bytes to the budget and wakes the producer.
+ * @param n The number of bytes to release.
+ */
+func (b *memoryBudget) release(n int64) {
+	if b.limit <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.inUse -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+/**
+ * @brief Parses a human-readable byte size such as "512M", "2G" or "1048576".
+ * Suffixes are binary multiples (K = 1024) and an optional trailing "B" is accepted.
+ * @param s The string to parse.
+ * @return The size in bytes and an error if the string is malformed.
+ */
+func parseByteSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "B")
+	multiplier := int64(1)
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+	value, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return value * multiplier, nil
+}
+
+/**
+ * @brief Looks up the size of many blobs with a single `git cat-file --batch-check` call.
+ * @param repo The repository containing the blobs.
+ * @param hashes The blob hashes to look up.
+ * @return A map of blob hash to size in bytes and an error if git failed.
+ */
+func getBlobSizes(repo *repository, hashes []string) (map[string]int64, error) {
+	cmd := repo.command("cat-file", "--batch-check=%(objectname) %(objectsize)")
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(hashes))
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue // e.g. "<hash> missing"
+		}
+		if size, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			sizes[parts[0]] = size
+		}
+	}
+	return sizes, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for input, want := range map[string]int64{
+		"1048576": 1 << 20,
+		"512M":    512 << 20,
+		"2g":      2 << 30,
+		"64KB":    64 << 10,
+		" 10 ":    10,
+	} {
+		if got, err := parseByteSize(input); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "M", "-1K", "1.5G", "12T"} {
+		if _, err := parseByteSize(input); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded", input)
+		}
+	}
+}
+
+// acquired reports whether acquire(n) returns within a short time.
+func acquired(b *memoryBudget, n int64) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		b.acquire(n)
+		close(done)
+	}()
+	return done
+}
+
+func TestMemoryBudgetBlocksUntilReleased(t *testing.T) {
+	b := newMemoryBudget(100)
+	b.acquire(60)
+	waiting := acquired(b, 50)
+	select {
+	case <-waiting:
+		t.Fatal("acquire(50) returned with 60 of 100 bytes in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(60)
+	select {
+	case <-waiting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire(50) still blocked after the release")
+	}
+}
+
+func TestMemoryBudgetAdmitsOversizedBlobAlone(t *testing.T) {
+	b := newMemoryBudget(100)
+	select {
+	case <-acquired(b, 1000):
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blob larger than the budget deadlocked with nothing in flight")
+	}
+	waiting := acquired(b, 1)
+	select {
+	case <-waiting:
+		t.Fatal("acquire(1) returned while the oversized blob was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(1000)
+	<-waiting
+}
+
+func TestMemoryBudgetUnlimited(t *testing.T) {
+	b := newMemoryBudget(0)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-acquired(b, 1<<40):
+		case <-time.After(5 * time.Second):
+			t.Fatal("an unlimited budget blocked")
+		}
+	}
+}
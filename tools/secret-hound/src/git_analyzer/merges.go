@@ -0,0 +1,86 @@
+/**
+ * @file merges.go
+ * @brief Decides which parent diffs of merge commits are scanned.
+ *
+ * A merge commit has no single "change": every file brought in from the
+ * merged branch differs from the first parent, and every file changed on the
+ * main line differs from the other parents. The walk therefore follows one
+ * of three well-defined policies:
+ *
+ *   - default: every reachable commit is walked. Branch work is scanned in
+ *     the branch's own commits, and a merge only contributes files whose
+ *     merged content differs from all of its parents, i.e. conflict
+ *     resolutions and changes made in the merge itself.
+ *   - --first-parent: only the first-parent chain is walked (the history of
+ *     the mainline), and each merge is diffed against its first parent, so
+ *     everything a merged branch introduced is scanned at the merge.
+ *   - --include-merge-diffs: merges are additionally diffed against each of
+ *     their parents, scanning every file a merge changed relative to any
+ *     parent. Combined with --first-parent, a merge is diffed against all of
+ *     its parents while the walk still follows the mainline.
+ *
+ * Root commits are diffed against the empty tree under every policy, and an
+ * octopus merge is handled exactly like a two-parent merge.
+ */
+
+package main
+
+import "strings"
+
+/**
+ * @struct mergePolicy
+ * @brief How the history walk treats merge commits.
+ */
+type mergePolicy struct {
+	firstParent bool // Walk only first parents; diff merges against the first parent
+	allParents  bool // Diff merges against every parent
+}
+
+/**
+ * @brief Names the policy, for cache keys.
+ */
+func (m mergePolicy) name() string {
+	switch {
+	case m.allParents:
+		return "all-parents"
+	case m.firstParent:
+		return "first-parent"
+	}
+	return "combined"
+}
+
+/**
+ * @brief Returns the commit cache key of a commit under this policy.
+ * Only merges depend on the policy, so other commits share one entry.
+ */
+func (m mergePolicy) cacheKey(commit commitRef) string {
+	if len(commit.parents) < 2 {
+		return commit.hash
+	}
+	return commit.hash + "/" + m.name()
+}
+
+/**
+ * @brief Returns the `git diff-tree --stdin` request lines for a commit.
+ * A line naming only the commit diffs it against its parents (combined for
+ * merges); a line "<commit> <parent>" diffs it against that parent alone.
+ */
+func (m mergePolicy) diffRequests(commit commitRef) []string {
+	if len(commit.parents) < 2 {
+		return []string{commit.hash}
+	}
+	var parents []string
+	switch {
+	case m.allParents:
+		parents = commit.parents
+	case m.firstParent:
+		parents = commit.parents[:1]
+	default:
+		return []string{commit.hash}
+	}
+	lines := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		lines = append(lines, strings.Join([]string{commit.hash, parent}, " "))
+	}
+	return lines
+}
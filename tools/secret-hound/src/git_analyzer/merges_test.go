@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMergePolicies(t *testing.T) {
+	fx := newFixtureRepo(t)
+	labels := map[string]string{fx.commit("root", map[string]string{"a.txt": "a"}): "root"}
+	fx.git("checkout", "-q", "-b", "feature")
+	labels[fx.commit("feature", map[string]string{"b.txt": "b"})] = "feature"
+	fx.git("checkout", "-q", "main")
+	labels[fx.commit("mainline", map[string]string{"c.txt": "c"})] = "mainline"
+	fx.git("merge", "-q", "--no-ff", "--no-commit", "feature")
+	labels[fx.commit("merge with a change of its own", map[string]string{"m.txt": "m"})] = "merge"
+	t.Chdir(fx.dir)
+
+	// One cache for every policy: merge entries must not leak between them.
+	cache := &commitCache{commits: make(map[string][]commitChange)}
+	for _, tc := range []struct {
+		policy mergePolicy
+		want   []string
+	}{
+		{mergePolicy{}, []string{"feature:b.txt", "mainline:c.txt", "merge:m.txt", "root:a.txt"}},
+		{mergePolicy{firstParent: true}, []string{"mainline:c.txt", "merge:b.txt", "merge:m.txt", "root:a.txt"}},
+		{mergePolicy{allParents: true}, []string{"feature:b.txt", "mainline:c.txt", "merge:b.txt", "merge:c.txt", "merge:m.txt", "root:a.txt"}},
+		{mergePolicy{firstParent: true, allParents: true}, []string{"mainline:c.txt", "merge:b.txt", "merge:c.txt", "merge:m.txt", "root:a.txt"}},
+	} {
+		blobs, err := getGitBlobs(&repository{}, 10, cache, tc.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, blob := range blobs {
+			got = append(got, labels[blob.commit]+":"+blob.path)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: %s, want %s", tc.policy.name(), strings.Join(got, " "), strings.Join(tc.want, " "))
+		}
+	}
+}
+
+func TestMergePolicyDiffRequests(t *testing.T) {
+	merge := commitRef{hash: "m", parents: []string{"p1", "p2", "p3"}}
+	plain := commitRef{hash: "c", parents: []string{"p"}}
+	for _, tc := range []struct {
+		policy mergePolicy
+		want   []string
+	}{
+		{mergePolicy{}, []string{"m"}},
+		{mergePolicy{firstParent: true}, []string{"m p1"}},
+		{mergePolicy{allParents: true}, []string{"m p1", "m p2", "m p3"}},
+	} {
+		if got := tc.policy.diffRequests(merge); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: %q, want %q", tc.policy.name(), got, tc.want)
+		}
+		if got := tc.policy.diffRequests(plain); !reflect.DeepEqual(got, []string{"c"}) || tc.policy.cacheKey(plain) != "c" {
+			t.Errorf("%s: a regular commit depends on the merge policy", tc.policy.name())
+		}
+	}
+}
+
+func TestFirstParentSummaryCountsTheWalk(t *testing.T) {
+	fx := newFixtureRepo(t)
+	fx.commit("root", map[string]string{"a.txt": "a"})
+	fx.branch("feature", "")
+	fx.commit("feature one", map[string]string{"b.txt": "b"})
+	fx.commit("feature two", map[string]string{"b.txt": "bb"})
+	fx.checkout("main")
+	fx.merge("merge feature", nil, "feature")
+
+	// Four commits in all; the first-parent chain is the root and the merge.
+	if result := fx.scan(); !strings.Contains(result.stderr, "scanned entire history (4 commits, 1000 requested)") {
+		t.Errorf("full walk summary:\n%s", result.stderr)
+	}
+	if result := fx.scan("--first-parent"); !strings.Contains(result.stderr, "scanned entire first-parent history (2 commits, 1000 requested)") {
+		t.Errorf("--first-parent summary:\n%s", result.stderr)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCountWithoutSecretMaterial(t *testing.T) {
+	m := newScanMetrics()
+	m.addRepository(3, 10)
+	m.addRepository(2, 4)
+	for _, f := range sampleFindings() {
+		m.addFinding(f)
+	}
+	m.addFinding(&finding{Repository: "billing", OriginalPath: "config/prod.env", RuleID: "AWS_ACCESS_KEY", Match: "AKIA2", Severity: "critical", Confidence: "High"})
+
+	var out bytes.Buffer
+	fw, _ := newFindingWriter(&out, "json")
+	record, _ := json.Marshal(m.finish())
+	fw.queue(append(record, '\n'))
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"AKIA", "prod.env", "c0ffee", "billing"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("the metrics record leaks %q: %s", secret, out.String())
+		}
+	}
+
+	var got scanMetrics
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Repositories != 2 || got.Commits != 5 || got.Blobs != 14 || got.Findings != 3 || got.FilesWithFindings != 2 {
+		t.Errorf("counts %s", out.String())
+	}
+	if !reflect.DeepEqual(got.ByRule, map[string]int{"AWS_ACCESS_KEY": 2, "X": 1}) ||
+		!reflect.DeepEqual(got.BySeverity, map[string]int{"critical": 1, "unassessed": 2}) ||
+		!reflect.DeepEqual(got.ByConfidence, map[string]int{"high": 2, "unassessed": 1}) {
+		t.Errorf("breakdowns %v %v %v", got.ByRule, got.BySeverity, got.ByConfidence)
+	}
+}
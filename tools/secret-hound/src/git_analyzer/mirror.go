@@ -0,0 +1,157 @@
+/**
+ * @file mirror.go
+ * @brief Sweeps remote repositories through a cache of bare mirror clones.
+ *
+ * The first sweep of a remote creates a `git clone --mirror` under the cache
+ * directory. Subsequent sweeps only run `git remote update --prune` on the
+ * existing mirror, so nightly runs transfer new objects instead of recloning.
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var unsafeMirrorChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+/**
+ * @brief Returns the default mirror cache directory (the user cache dir, or a temp dir).
+ * @return An absolute directory path.
+ */
+func defaultMirrorCache() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "secret-hound", "mirrors")
+}
+
+/**
+ * @brief Computes the cache location of a remote's mirror.
+ * The name keeps a readable tail of the URL plus a short hash so that
+ * different remotes with the same repository name never collide.
+ * @param cacheDir The mirror cache directory.
+ * @param url The remote URL.
+ * @return The path of the bare mirror repository.
+ */
+func mirrorPath(cacheDir, url string) string {
+	sum := sha1.Sum([]byte(url))
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+	name = unsafeMirrorChars.ReplaceAllString(name, "_")
+	return filepath.Join(cacheDir, fmt.Sprintf("%s-%s.git", name, hex.EncodeToString(sum[:])[:12]))
+}
+
+/**
+ * @brief Creates or refreshes the cached mirror of a remote.
+ * @param cacheDir The mirror cache directory.
+ * @param url The remote URL.
+ * @return The path of the up-to-date mirror and an error if git failed.
+ */
+func syncMirror(cacheDir, url string) (string, error) {
+	path := mirrorPath(cacheDir, url)
+
+	var cmd *exec.Cmd
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+		cmd = exec.Command("git", "--git-dir", path, "remote", "update", "--prune")
+	} else {
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			return "", err
+		}
+		os.RemoveAll(path) // Remove leftovers of an interrupted clone.
+		cmd = exec.Command("git", "clone", "--quiet", "--mirror", url, path)
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("syncing mirror of %s: %v", url, err)
+	}
+	return path, nil
+}
+
+/**
+ * @brief Syncs and scans every configured remote, highest priority first.
+ * Up to --parallel-repos repositories are processed at once; their blob scans
+ * share the global worker budget through the scheduler. A failing remote is
+ * reported and skipped so one broken repository does not abort the whole sweep.
+ * @return An error if any remote could not be scanned.
+ */
+func (a *analyzer) sweepRemotes() error {
+	repos := make([]*repository, 0, len(a.opts.remotes))
+	for _, spec := range a.opts.remotes {
+		repo, err := parseRemoteSpec(spec)
+		if err != nil {
+			return err
+		}
+		repos = append(repos, repo)
+	}
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].priority > repos[j].priority })
+
+	parallel := a.opts.parallelRepos
+	if parallel < 1 {
+		parallel = 1
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	slots := make(chan struct{}, parallel)
+	for _, repo := range repos {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(repo *repository) {
+			defer func() { <-slots; wg.Done() }()
+			path, err := syncMirror(a.opts.mirrorCache, repo.label)
+			if err == nil {
+				repo.gitDir = path
+				err = a.scanRepository(repo)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: skipping %s: %v\n", repo.label, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(repo)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d remotes could not be scanned", failed, len(repos))
+	}
+	return nil
+}
+
+/**
+ * @brief Reads non-empty, non-comment lines from a text file.
+ * @param path The file to read.
+ * @return The trimmed lines and an error if the file could not be read.
+ */
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
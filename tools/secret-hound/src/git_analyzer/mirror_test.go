@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMirrorPathNames(t *testing.T) {
+	a := mirrorPath("/cache", "https://github.com/acme/api.git")
+	b := mirrorPath("/cache", "https://gitlab.com/acme/api")
+	if filepath.Dir(a) != "/cache" || !strings.HasPrefix(filepath.Base(a), "api-") || !strings.HasSuffix(a, ".git") {
+		t.Errorf("mirror path %q", a)
+	}
+	if a == b {
+		t.Errorf("two remotes named api share the mirror %q", a)
+	}
+	if got := filepath.Base(mirrorPath("/cache", "ssh://host/odd name?.git")); strings.ContainsAny(got, " ?") {
+		t.Errorf("unsafe characters kept in %q", got)
+	}
+}
+
+func TestSyncMirrorClonesThenUpdates(t *testing.T) {
+	repo := newFixtureRepo(t)
+	repo.commit("one", map[string]string{"a.txt": "1\n"})
+	cache := filepath.Join(t.TempDir(), "mirrors")
+
+	path, err := syncMirror(cache, repo.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &repository{gitDir: path}
+	if isBare, err := r.check(); err != nil || !isBare {
+		t.Fatalf("mirror at %s: bare=%v, %v", path, isBare, err)
+	}
+
+	second := repo.commit("two", map[string]string{"a.txt": "2\n"})
+	marker := filepath.Join(path, "kept")
+	if err := os.WriteFile(marker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := syncMirror(cache, repo.dir); err != nil || again != path {
+		t.Fatalf("second sync: %s, %v", again, err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the second sync recloned the mirror instead of updating it")
+	}
+	blobs, err := getGitBlobs(r, 10, &commitCache{commits: make(map[string][]commitChange)}, mergePolicy{})
+	if err != nil || len(blobs) != 2 || blobs[0].commit != second {
+		t.Errorf("blobs after the update: %+v, %v", blobs, err)
+	}
+}
+
+func TestReadLinesSkipsCommentsAndBlanks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "remotes")
+	os.WriteFile(path, []byte("# nightly sweep\nhttps://a/x.git\n\n  https://b/y.git  \n"), 0o644)
+	lines, err := readLines(path)
+	if err != nil || strings.Join(lines, " ") != "https://a/x.git https://b/y.git" {
+		t.Errorf("readLines = %q, %v", lines, err)
+	}
+}
@@ -0,0 +1,222 @@
+/**
+ * @file msgpack.go
+ * @brief A minimal MessagePack codec for JSON-compatible values.
+ *
+ * Findings are converted through their JSON representation, so the MessagePack
+ * output always carries exactly the same keys as the JSON output. Map keys are
+ * written in sorted order to keep the encoding deterministic.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+/**
+ * @brief Encodes a value as MessagePack via its JSON representation.
+ * @param v Any value that encoding/json can marshal.
+ * @return The encoded bytes and an error if the value cannot be represented.
+ */
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, generic), nil
+}
+
+func appendMsgpack(buf []byte, v interface{}) []byte {
+	switch x := v.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case bool:
+		if x {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case float64:
+		if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+			return appendMsgpackInt(buf, int64(x))
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(x))
+	case string:
+		switch n := len(x); {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n < 1<<8:
+			buf = append(buf, 0xd9, byte(n))
+		case n < 1<<16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+		}
+		return append(buf, x...)
+	case []interface{}:
+		buf = appendMsgpackHeader(buf, len(x), 0x90, 0xdc, 0xdd)
+		for _, elem := range x {
+			buf = appendMsgpack(buf, elem)
+		}
+		return buf
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf = appendMsgpackHeader(buf, len(x), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			buf = appendMsgpack(buf, k)
+			buf = appendMsgpack(buf, x[k])
+		}
+		return buf
+	}
+	return append(buf, 0xc0)
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n < 128:
+		return append(buf, byte(n))
+	case n < 0 && n >= -32:
+		return append(buf, byte(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+	}
+}
+
+func appendMsgpackHeader(buf []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16(append(buf, b16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, b32), uint32(n))
+	}
+}
+
+/**
+ * @brief Decodes one MessagePack value from a stream.
+ * @param r The source reader.
+ * @return The decoded value (JSON-compatible types), or io.EOF at the end of the stream.
+ */
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return float64(b), nil
+	case b >= 0xe0:
+		return float64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return readMsgpackString(r, int(b&0x1f))
+	case b&0xf0 == 0x90:
+		return readMsgpackArray(r, int(b&0x0f))
+	case b&0xf0 == 0x80:
+		return readMsgpackMap(r, int(b&0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcb:
+		bits, err := readMsgpackUint(r, 8)
+		return math.Float64frombits(bits), err
+	case 0xd3:
+		n, err := readMsgpackUint(r, 8)
+		return float64(int64(n)), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackUint(r, 1<<(b-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackString(r, int(n))
+	case 0xdc, 0xdd:
+		n, err := readMsgpackUint(r, 2<<(b-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, int(n))
+	case 0xde, 0xdf:
+		n, err := readMsgpackUint(r, 2<<(b-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", b)
+}
+
+func readMsgpackUint(r *bufio.Reader, size int) (uint64, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var n uint64
+	for _, b := range buf {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+func readMsgpackString(r *bufio.Reader, n int) (interface{}, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return string(buf), nil
+}
+
+func readMsgpackArray(r *bufio.Reader, n int) (interface{}, error) {
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		elem, err := readMsgpack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		arr = append(arr, elem)
+	}
+	return arr, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := readMsgpack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		value, err := readMsgpack(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
+
+// unexpectedEOF turns a clean EOF in the middle of a value into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
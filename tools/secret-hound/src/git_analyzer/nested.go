@@ -0,0 +1,255 @@
+/**
+ * @file nested.go
+ * @brief Repositories committed inside the scanned one (--nested-repos).
+ *
+ * A vendored dependency sometimes arrives with its own history: a `git
+ * bundle` file checked in as a fixture or backup, or a whole repository
+ * directory (HEAD, objects/, refs/) committed as plain files, e.g. a bare
+ * vendor/lib.git or a .git directory added by a tool that does not know
+ * better. Its objects are compressed, so scanning those files finds nothing,
+ * yet every secret ever committed to the vendored project is in there.
+ *
+ * After a repository is scanned, the blobs of its walk are checked for both:
+ *   - a blob whose path ends in .bundle and whose content starts with a
+ *     bundle header is written out and cloned like --bundle;
+ *   - a directory holding a HEAD file and an objects/ directory is extracted
+ *     from the newest walked commit that touched it (`git archive`), or used
+ *     in place for working tree files, and opened as a bare repository.
+ * Each nested repository is then scanned with all its refs, up to --depth
+ * commits, and its findings carry the label "<outer>:<path>". Nested
+ * repositories found inside it are scanned the same way, up to
+ * maxNestingDepth levels, so a crafted chain of bundles cannot recurse
+ * forever. A nested repository that cannot be unpacked is a blob-level error.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxNestingDepth is how many levels of repositories within repositories are scanned.
+const maxNestingDepth = 3
+
+// bundleHeaders start the content of git bundle files (v2 and v3).
+var bundleHeaders = [][]byte{[]byte("# v2 git bundle\n"), []byte("# v3 git bundle\n")}
+
+/**
+ * @struct nestedRepo
+ * @brief A repository found among the blobs of another.
+ */
+type nestedRepo struct {
+	path   string   // Path of the bundle file or repository directory in the outer repository
+	bundle bool     // A bundle file rather than a committed repository directory
+	blob   fileBlob // The bundle blob, or the newest blob of the directory (its commit is extracted)
+}
+
+/**
+ * @brief Finds bundle files and repository directories among the walked blobs.
+ * Bundles are recognized by name here and by content when unpacked.
+ * @param blobs The blobs of the walk, newest commit first.
+ * @return The candidates, each path once.
+ */
+func findNestedRepos(blobs []fileBlob) []nestedRepo {
+	var found []nestedRepo
+	seen := make(map[string]bool)
+	heads := make(map[string]fileBlob) // Directory -> newest blob of its HEAD file
+	hasObjects := make(map[string]bool)
+	for _, blob := range blobs {
+		if blob.mode == modeSymlink {
+			continue
+		}
+		if strings.HasSuffix(blob.path, ".bundle") && !seen[blob.hash] {
+			seen[blob.hash] = true
+			found = append(found, nestedRepo{path: blob.path, bundle: true, blob: blob})
+		}
+		dir, name := path.Split(blob.path)
+		dir = strings.TrimSuffix(dir, "/")
+		if name == "HEAD" && dir != "" {
+			if _, ok := heads[dir]; !ok {
+				heads[dir] = blob
+			}
+		}
+		if i := strings.Index(blob.path, "/objects/"); i > 0 {
+			hasObjects[blob.path[:i]] = true
+		}
+	}
+	// Each directory is extracted from the newest commit that touched it.
+	for _, blob := range blobs {
+		for dir := range heads {
+			if hasObjects[dir] && !seen[dir] && strings.HasPrefix(blob.path, dir+"/") {
+				seen[dir] = true
+				found = append(found, nestedRepo{path: dir, blob: blob})
+			}
+		}
+	}
+	return found
+}
+
+/**
+ * @brief Scans the repositories nested in a scanned one, recursively.
+ * @param outer The repository that was scanned.
+ * @param blobs The blobs of its walk.
+ */
+func (a *analyzer) scanNestedRepos(outer *repository, blobs []fileBlob) {
+	for _, nested := range findNestedRepos(blobs) {
+		if !a.opts.shard.contains(nested.blob.hash) {
+			continue // Another --shard job scans it
+		}
+		label := nested.path
+		if outer.label != "" {
+			label = outer.label + ":" + nested.path
+		}
+		if outer.nesting >= maxNestingDepth {
+			fmt.Fprintf(os.Stderr, "Go analyzer: %snot scanning nested repository %s: nested more than %d levels deep\n",
+				labelPrefix(outer.label), nested.path, maxNestingDepth)
+			continue
+		}
+		gitDir, cleanup, err := a.openNestedRepo(nested)
+		if err != nil {
+			scanFailures.record(&gitError{op: "unpack nested repository", blob: nested.blob, err: err})
+			continue
+		}
+		if gitDir == "" {
+			continue // A .bundle file that is not a bundle
+		}
+		fmt.Fprintf(os.Stderr, "Go analyzer: %sscanning the history of nested repository %s (commit %.12s)\n",
+			labelPrefix(outer.label), nested.path, nested.blob.commit)
+		repo := &repository{gitDir: gitDir, label: label, nesting: outer.nesting + 1, workers: outer.workers, priority: outer.priority}
+		if err := a.scanRepository(repo); err != nil {
+			scanFailures.record(&gitError{op: "scan nested repository", blob: nested.blob, err: err})
+		}
+		cleanup()
+	}
+}
+
+/**
+ * @brief Collects the blobs of the --depth newest commits of every ref of a nested repository.
+ * @return The blobs, the number of commits walked, and an error.
+ */
+func getNestedBlobs(repo *repository, depth int, cache *commitCache, merges mergePolicy) ([]fileBlob, int, error) {
+	args := []string{"rev-list", "--parents", fmt.Sprintf("--max-count=%d", depth)}
+	if merges.firstParent {
+		args = append(args, "--first-parent")
+	}
+	output, err := repo.command(append(args, "--all")...).Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("git rev-list: %v", err)
+	}
+	commits := parseRevList(output)
+	blobs, err := collectCommitBlobs(repo, commits, cache, merges)
+	return blobs, len(commits), err
+}
+
+/**
+ * @brief Makes a nested repository available as a git directory.
+ * @return The git directory ("" if a .bundle file is not a bundle), a
+ *         function removing what was unpacked, and an error.
+ */
+func (a *analyzer) openNestedRepo(nested nestedRepo) (string, func(), error) {
+	none := func() {}
+	if !nested.bundle && nested.blob.diskPath != "" {
+		// A directory of the working tree: the blob is a file somewhere below it.
+		root := strings.TrimSuffix(nested.blob.diskPath, filepath.FromSlash(nested.blob.path))
+		return filepath.Join(root, filepath.FromSlash(nested.path)), none, nil
+	}
+	if nested.bundle {
+		content, err := readBlobContent(nested.blob)
+		if err != nil {
+			return "", none, err
+		}
+		isBundle := false
+		for _, header := range bundleHeaders {
+			isBundle = isBundle || bytes.HasPrefix(content, header)
+		}
+		if !isBundle {
+			return "", none, nil
+		}
+		file, err := ioutil.TempFile(a.opts.tmpDir, "secret-hound-nested-*.bundle")
+		if err != nil {
+			return "", none, err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.Write(content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", none, err
+		}
+		dir, gitDir, err := unpackBundle(file.Name(), a.opts.tmpDir)
+		if err != nil {
+			return "", none, err
+		}
+		return gitDir, func() { os.RemoveAll(dir) }, nil
+	}
+
+	dir, err := ioutil.TempDir(a.opts.tmpDir, "secret-hound-nested-")
+	if err != nil {
+		return "", none, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	if err := extractTreeDir(nested.blob.repo, nested.blob.commit, nested.path, dir); err != nil {
+		cleanup()
+		return "", none, err
+	}
+	// Empty directories are not committed, but git needs them to open the repository.
+	for _, sub := range []string{"objects", "refs/heads", "refs/tags"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0o755); err != nil {
+			cleanup()
+			return "", none, err
+		}
+	}
+	return dir, cleanup, nil
+}
+
+/**
+ * @brief Writes the files of a directory of a commit's tree to a local directory.
+ * @param repo The outer repository.
+ * @param commit The commit whose tree holds the directory.
+ * @param treeDir The directory in the tree.
+ * @param dest The local directory; the treeDir prefix is removed from the paths.
+ * @return An error if git archive fails or a path leaves dest.
+ */
+func extractTreeDir(repo *repository, commit, treeDir, dest string) error {
+	output, err := repo.command("archive", "--format=tar", commit, "--", treeDir).Output()
+	if err != nil {
+		return fmt.Errorf("git archive %.12s %s: %v", commit, treeDir, err)
+	}
+	archive := tar.NewReader(bytes.NewReader(output))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue // Directories are created with their files; links are not needed
+		}
+		rel := strings.TrimPrefix(header.Name, treeDir+"/")
+		if rel == header.Name || rel == "" || strings.HasPrefix(path.Clean(rel), "..") {
+			continue
+		}
+		target := filepath.Join(dest, filepath.FromSlash(path.Clean(rel)))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, content, 0o644); err != nil {
+			return err
+		}
+	}
+}
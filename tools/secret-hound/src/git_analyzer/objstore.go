@@ -0,0 +1,534 @@
+/**
+ * @file objstore.go
+ * @brief Minimal S3 and GCS clients for state and findings in object storage.
+ *
+ * Ephemeral runners (Kubernetes CronJobs, serverless tasks) have no disk that
+ * outlives the run, so --state-url and an object URL as --output keep the
+ * state and the findings in a bucket instead:
+ *   s3://bucket/prefix   AWS S3, or any S3-compatible store when
+ *                        AWS_ENDPOINT_URL is set (path-style requests, e.g. MinIO).
+ *                        Credentials and region come from the AWS_* variables.
+ *   gs://bucket/prefix   Google Cloud Storage through the JSON API, with the token
+ *                        in GOOGLE_OAUTH_ACCESS_TOKEN or, on GKE and GCE, from the
+ *                        metadata server. STORAGE_EMULATOR_HOST selects an emulator.
+ *
+ * Findings are streamed: an S3 multipart upload or a GCS resumable upload
+ * sends each part as it fills, so memory stays at one part however large the
+ * result set grows and nothing is staged on local disk. --object-sse and
+ * --object-kms-key request server-side encryption of everything written,
+ * state included: SSE-S3 (AES256) or SSE-KMS (aws:kms) on S3, and a
+ * customer-managed KMS key on GCS, which always encrypts at rest.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// objectHTTPTimeout bounds each request to an object store.
+const objectHTTPTimeout = 5 * time.Minute
+
+// objectPartSize is the size of multipart and resumable upload parts. S3 needs
+// at least 5 MiB, GCS a multiple of 256 KiB.
+const objectPartSize = 8 << 20
+
+// errObjectNotFound is returned by get for a missing object.
+var errObjectNotFound = errors.New("object not found")
+
+/**
+ * @brief A bucket in an object store.
+ */
+type objectStore interface {
+	// get downloads an object; a missing object is errObjectNotFound.
+	get(key string) ([]byte, error)
+	// put uploads an object, replacing any existing one.
+	put(key string, data []byte) error
+	// create starts a streaming upload; the object appears when the writer is closed.
+	create(key string) (objectWriter, error)
+	// url returns the s3:// or gs:// URL of an object, for messages.
+	url(key string) string
+}
+
+/**
+ * @brief A streaming upload.
+ */
+type objectWriter interface {
+	io.WriteCloser
+	// abort cancels the upload, leaving any existing object unchanged.
+	abort()
+}
+
+/**
+ * @struct objectEncryption
+ * @brief Server-side encryption requested for uploads (--object-sse, --object-kms-key).
+ */
+type objectEncryption struct {
+	mode   string // "" (the bucket default), "AES256" or "aws:kms"
+	kmsKey string // KMS key ID or ARN (S3), or key resource name (GCS)
+}
+
+/**
+ * @brief Validates the encryption flags.
+ * A KMS key without a mode implies aws:kms.
+ * @return The settings and an error for an unknown mode or a missing key.
+ */
+func parseObjectEncryption(mode, kmsKey string) (objectEncryption, error) {
+	if mode == "" && kmsKey != "" {
+		mode = "aws:kms"
+	}
+	switch mode {
+	case "", "none":
+		return objectEncryption{}, nil
+	case "AES256":
+		if kmsKey != "" {
+			return objectEncryption{}, fmt.Errorf("--object-kms-key needs --object-sse aws:kms")
+		}
+	case "aws:kms":
+	default:
+		return objectEncryption{}, fmt.Errorf("unknown mode %q (expected AES256 or aws:kms)", mode)
+	}
+	return objectEncryption{mode: mode, kmsKey: kmsKey}, nil
+}
+
+/**
+ * @brief Reports whether a path is an object store URL rather than a local path.
+ */
+func isObjectURL(path string) bool {
+	return strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://")
+}
+
+/**
+ * @brief Opens the bucket of an s3:// or gs:// URL.
+ * @param raw The URL.
+ * @param enc The server-side encryption for uploads.
+ * @return The store, the key (or prefix) within the bucket, and an error for a malformed URL.
+ */
+func openObjectURL(raw string, enc objectEncryption) (objectStore, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("%s: missing bucket name", raw)
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	client := &http.Client{Timeout: objectHTTPTimeout}
+	switch u.Scheme {
+	case "s3":
+		return &s3Store{client: client, bucket: u.Host, region: awsRegionFromEnv("us-east-1"),
+			endpoint: strings.TrimRight(os.Getenv("AWS_ENDPOINT_URL"), "/"), enc: enc}, key, nil
+	case "gs":
+		if enc.mode == "aws:kms" && enc.kmsKey == "" {
+			return nil, "", fmt.Errorf("%s: GCS needs --object-kms-key for KMS encryption", raw)
+		}
+		return &gcsStore{client: client, bucket: u.Host, enc: enc}, key, nil
+	}
+	return nil, "", fmt.Errorf("%s: unsupported scheme %q (expected s3 or gs)", raw, u.Scheme)
+}
+
+/**
+ * @brief Joins a prefix and a name into an object key.
+ */
+func objectKey(prefix, name string) string {
+	if prefix = strings.Trim(prefix, "/"); prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+// s3Store is a bucket in S3 or an S3-compatible store.
+type s3Store struct {
+	client   *http.Client
+	bucket   string
+	region   string
+	endpoint string // Custom endpoint for S3-compatible stores ("" = AWS)
+	enc      objectEncryption
+}
+
+func (s *s3Store) url(key string) string { return "s3://" + s.bucket + "/" + key }
+
+// objectURL builds the request URL: virtual-hosted style on AWS, path style on custom endpoints.
+func (s *s3Store) objectURL(key string, query url.Values) *url.URL {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = awsEscape(segment)
+	}
+	rawPath := "/" + strings.Join(segments, "/")
+	base := "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com"
+	if s.endpoint != "" {
+		base = s.endpoint
+		rawPath = "/" + awsEscape(s.bucket) + rawPath
+	}
+	u, _ := url.Parse(base)
+	u.RawPath = rawPath
+	u.Path, _ = url.PathUnescape(rawPath)
+	u.RawQuery = query.Encode()
+	return u
+}
+
+// do signs and sends a request, returning the response body of a 2xx response.
+func (s *s3Store) do(method, key string, query url.Values, header http.Header, body []byte) ([]byte, http.Header, error) {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequest(method, s.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(body))
+	if err := signAWSRequest(req, creds, s.region, "s3", time.Now()); err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && method == "GET":
+		return nil, nil, errObjectNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, nil, fmt.Errorf("%s %s: HTTP %d: %s", method, s.url(key), resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, resp.Header, nil
+}
+
+func (s *s3Store) get(key string) ([]byte, error) {
+	data, _, err := s.do("GET", key, nil, nil, nil)
+	return data, err
+}
+
+func (s *s3Store) put(key string, data []byte) error {
+	_, _, err := s.do("PUT", key, nil, s.encryptionHeader(), data)
+	return err
+}
+
+// encryptionHeader returns the SSE headers for object creation.
+func (s *s3Store) encryptionHeader() http.Header {
+	header := http.Header{}
+	if s.enc.mode != "" {
+		header.Set("X-Amz-Server-Side-Encryption", s.enc.mode)
+	}
+	if s.enc.kmsKey != "" {
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.enc.kmsKey)
+	}
+	return header
+}
+
+func (s *s3Store) create(key string) (objectWriter, error) {
+	return &s3Upload{store: s, key: key}, nil
+}
+
+/**
+ * @struct s3Upload
+ * @brief A multipart upload, started once the first part is full.
+ * Objects smaller than one part are sent with a single PUT on close.
+ */
+type s3Upload struct {
+	store    *s3Store
+	key      string
+	uploadID string
+	buf      []byte
+	parts    []s3Part
+	err      error // Sticky: a failed part fails the upload
+}
+
+// s3Part is a finished part, as listed in CompleteMultipartUpload.
+type s3Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.buf = append(u.buf, p...)
+	for len(u.buf) >= objectPartSize && u.err == nil {
+		u.err = u.sendPart(u.buf[:objectPartSize])
+		u.buf = append(u.buf[:0], u.buf[objectPartSize:]...)
+	}
+	if u.err != nil {
+		u.abort()
+		return 0, u.err
+	}
+	return len(p), nil
+}
+
+// sendPart uploads one part, starting the multipart upload if needed.
+func (u *s3Upload) sendPart(part []byte) error {
+	if u.uploadID == "" {
+		body, _, err := u.store.do("POST", u.key, url.Values{"uploads": {""}}, u.store.encryptionHeader(), nil)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+			return fmt.Errorf("%s: no upload ID in CreateMultipartUpload response", u.store.url(u.key))
+		}
+		u.uploadID = result.UploadID
+	}
+	number := len(u.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	_, header, err := u.store.do("PUT", u.key, query, nil, part)
+	if err != nil {
+		return err
+	}
+	u.parts = append(u.parts, s3Part{PartNumber: number, ETag: header.Get("ETag")})
+	return nil
+}
+
+func (u *s3Upload) Close() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.uploadID == "" {
+		u.err = u.store.put(u.key, u.buf)
+		return u.err
+	}
+	if len(u.buf) > 0 {
+		if u.err = u.sendPart(u.buf); u.err != nil {
+			u.abort()
+			return u.err
+		}
+	}
+	body, _ := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: u.parts})
+	_, _, u.err = u.store.do("POST", u.key, url.Values{"uploadId": {u.uploadID}}, nil, body)
+	if u.err != nil {
+		u.abort()
+	}
+	return u.err
+}
+
+func (u *s3Upload) abort() {
+	if u.uploadID != "" {
+		u.store.do("DELETE", u.key, url.Values{"uploadId": {u.uploadID}}, nil, nil)
+		u.uploadID = ""
+	}
+	if u.err == nil {
+		u.err = fmt.Errorf("%s: upload aborted", u.store.url(u.key))
+	}
+}
+
+// gcsStore is a bucket in Google Cloud Storage.
+type gcsStore struct {
+	client *http.Client
+	bucket string
+	token  string // Cached OAuth access token
+	enc    objectEncryption
+}
+
+func (g *gcsStore) url(key string) string { return "gs://" + g.bucket + "/" + key }
+
+// endpoint returns the API root, honouring STORAGE_EMULATOR_HOST.
+func (g *gcsStore) endpoint() string {
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		return strings.TrimRight(host, "/")
+	}
+	return "https://storage.googleapis.com"
+}
+
+// accessToken returns the OAuth token from the environment or the metadata server.
+func (g *gcsStore) accessToken() (string, error) {
+	if g.token != "" {
+		return g.token, nil
+	}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		g.token = token
+		return token, nil
+	}
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		return "", nil // Emulators do not check credentials
+	}
+	req, _ := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("no GOOGLE_OAUTH_ACCESS_TOKEN and no metadata server: %v", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("metadata server returned no access token (HTTP %d)", resp.StatusCode)
+	}
+	g.token = token.AccessToken
+	return g.token, nil
+}
+
+// do sends an authorized request, returning the response body of a 2xx response.
+func (g *gcsStore) do(method, rawURL string, header http.Header, body io.Reader) ([]byte, http.Header, int, error) {
+	token, err := g.accessToken()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, resp.StatusCode, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return data, resp.Header, resp.StatusCode, fmt.Errorf("%s: HTTP %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, resp.Header, resp.StatusCode, nil
+}
+
+func (g *gcsStore) get(key string) ([]byte, error) {
+	data, _, status, err := g.do("GET", g.endpoint()+"/storage/v1/b/"+url.PathEscape(g.bucket)+"/o/"+url.PathEscape(key)+"?alt=media", nil, nil)
+	if status == http.StatusNotFound {
+		return nil, errObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", g.url(key), err)
+	}
+	return data, nil
+}
+
+func (g *gcsStore) put(key string, data []byte) error {
+	query := url.Values{"uploadType": {"media"}, "name": {key}}
+	if g.enc.kmsKey != "" {
+		query.Set("kmsKeyName", g.enc.kmsKey)
+	}
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	_, _, _, err := g.do("POST", g.endpoint()+"/upload/storage/v1/b/"+url.PathEscape(g.bucket)+"/o?"+query.Encode(), header, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %v", g.url(key), err)
+	}
+	return nil
+}
+
+func (g *gcsStore) create(key string) (objectWriter, error) {
+	return &gcsUpload{store: g, key: key}, nil
+}
+
+/**
+ * @struct gcsUpload
+ * @brief A resumable upload, started once the first chunk is full.
+ * Objects smaller than one chunk are sent with a single request on close.
+ */
+type gcsUpload struct {
+	store   *gcsStore
+	key     string
+	session string // Resumable session URI
+	offset  int64  // Bytes already sent
+	buf     []byte
+	err     error // Sticky: a failed chunk fails the upload
+}
+
+func (u *gcsUpload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.buf = append(u.buf, p...)
+	for len(u.buf) > objectPartSize && u.err == nil {
+		// Keep the last bytes back: only the final chunk may be short.
+		u.err = u.sendChunk(u.buf[:objectPartSize], false)
+		u.buf = append(u.buf[:0], u.buf[objectPartSize:]...)
+	}
+	if u.err != nil {
+		u.abort()
+		return 0, u.err
+	}
+	return len(p), nil
+}
+
+// sendChunk uploads a chunk, starting the session if needed.
+func (u *gcsUpload) sendChunk(chunk []byte, last bool) error {
+	if u.session == "" {
+		query := url.Values{"uploadType": {"resumable"}, "name": {u.key}}
+		if u.store.enc.kmsKey != "" {
+			query.Set("kmsKeyName", u.store.enc.kmsKey)
+		}
+		header := http.Header{"Content-Type": {"application/json"}}
+		_, respHeader, _, err := u.store.do("POST", u.store.endpoint()+"/upload/storage/v1/b/"+url.PathEscape(u.store.bucket)+"/o?"+query.Encode(), header, strings.NewReader("{}"))
+		if err != nil {
+			return fmt.Errorf("%s: %v", u.store.url(u.key), err)
+		}
+		if u.session = respHeader.Get("Location"); u.session == "" {
+			return fmt.Errorf("%s: no session URI in resumable upload response", u.store.url(u.key))
+		}
+	}
+	end := u.offset + int64(len(chunk))
+	total := "*"
+	if last {
+		total = strconv.FormatInt(end, 10)
+	}
+	contentRange := fmt.Sprintf("bytes %d-%d/%s", u.offset, end-1, total)
+	if len(chunk) == 0 {
+		contentRange = "bytes */" + total
+	}
+	_, _, status, err := u.store.do("PUT", u.session, http.Header{"Content-Range": {contentRange}}, bytes.NewReader(chunk))
+	if err != nil && !(status == http.StatusPermanentRedirect && !last) {
+		return fmt.Errorf("%s: %v", u.store.url(u.key), err)
+	}
+	u.offset = end
+	return nil
+}
+
+func (u *gcsUpload) Close() error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.session == "" {
+		u.err = u.store.put(u.key, u.buf)
+		return u.err
+	}
+	if u.err = u.sendChunk(u.buf, true); u.err != nil {
+		u.abort()
+	}
+	return u.err
+}
+
+func (u *gcsUpload) abort() {
+	if u.session != "" {
+		u.store.do("DELETE", u.session, nil, nil)
+		u.session = ""
+	}
+	if u.err == nil {
+		u.err = fmt.Errorf("%s: upload aborted", u.store.url(u.key))
+	}
+}
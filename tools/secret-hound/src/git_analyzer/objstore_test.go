@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStore is an in-memory objectStore.
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryStore() *memoryStore { return &memoryStore{objects: make(map[string][]byte)} }
+
+func (m *memoryStore) get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errObjectNotFound
+	}
+	return data, nil
+}
+
+func (m *memoryStore) put(key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memoryStore) create(key string) (objectWriter, error) {
+	return &memoryUpload{store: m, key: key}, nil
+}
+
+func (m *memoryStore) url(key string) string { return "mem://" + key }
+
+// memoryUpload stores its object in a memoryStore when closed.
+type memoryUpload struct {
+	bytes.Buffer
+	store   *memoryStore
+	key     string
+	aborted bool
+}
+
+func (u *memoryUpload) Close() error {
+	if u.aborted {
+		return fmt.Errorf("%s: upload aborted", u.key)
+	}
+	return u.store.put(u.key, u.Bytes())
+}
+
+func (u *memoryUpload) abort() { u.aborted = true }
+
+// fakeS3 is a path-style S3 endpoint with single-request and multipart uploads.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	sse     map[string]string         // Object path -> X-Amz-Server-Side-Encryption at creation
+	uploads map[string]map[int][]byte // Upload ID -> part number -> data
+	aborted int
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	s := &fakeS3{objects: make(map[string][]byte), sse: make(map[string]string), uploads: make(map[string]map[int][]byte)}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	return s
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	path, query := r.URL.EscapedPath(), r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	_, startsUpload := query["uploads"]
+	uploadID := query.Get("uploadId")
+	switch {
+	case r.Method == "GET":
+		data, ok := s.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == "POST" && startsUpload:
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = make(map[int][]byte)
+		s.sse[path] = r.Header.Get("X-Amz-Server-Side-Encryption")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == "PUT" && uploadID != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		s.uploads[uploadID][number] = body
+		w.Header().Set("ETag", `"part`+strconv.Itoa(number)+`"`)
+	case r.Method == "POST" && uploadID != "":
+		var numbers []int
+		for n := range s.uploads[uploadID] {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, s.uploads[uploadID][n]...)
+		}
+		s.objects[path] = data
+		delete(s.uploads, uploadID)
+	case r.Method == "DELETE" && uploadID != "":
+		delete(s.uploads, uploadID)
+		s.aborted++
+	case r.Method == "PUT":
+		s.objects[path] = body
+		s.sse[path] = r.Header.Get("X-Amz-Server-Side-Encryption")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestOpenObjectURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"s3://bucket/scans/prod/": "scans/prod/",
+		"gs://bucket/findings.gz": "findings.gz",
+		"s3://bucket":             "",
+	} {
+		if _, key, err := openObjectURL(raw, objectEncryption{}); err != nil || key != want {
+			t.Errorf("openObjectURL(%q) = %q, %v", raw, key, err)
+		}
+	}
+	for _, raw := range []string{"s3:///key", "azure://bucket/key"} {
+		if _, _, err := openObjectURL(raw, objectEncryption{}); err == nil {
+			t.Errorf("%s was accepted", raw)
+		}
+	}
+	if _, _, err := openObjectURL("gs://bucket/key", objectEncryption{mode: "aws:kms"}); err == nil {
+		t.Error("GCS KMS encryption without a key was accepted")
+	}
+	if objectKey("/scans/", "history.jsonl") != "scans/history.jsonl" || objectKey("", "history.jsonl") != "history.jsonl" {
+		t.Error("objectKey does not join prefixes")
+	}
+	if !isObjectURL("gs://b/k") || isObjectURL("out/s3://x") {
+		t.Error("isObjectURL")
+	}
+}
+
+func TestParseObjectEncryption(t *testing.T) {
+	for _, tc := range []struct {
+		mode, key string
+		want      objectEncryption
+	}{
+		{"", "", objectEncryption{}},
+		{"none", "", objectEncryption{}},
+		{"AES256", "", objectEncryption{mode: "AES256"}},
+		{"", "alias/scans", objectEncryption{mode: "aws:kms", kmsKey: "alias/scans"}},
+	} {
+		if got, err := parseObjectEncryption(tc.mode, tc.key); err != nil || got != tc.want {
+			t.Errorf("parseObjectEncryption(%q, %q) = %+v, %v", tc.mode, tc.key, got, err)
+		}
+	}
+	for _, tc := range [][2]string{{"AES256", "alias/scans"}, {"aws:fsx", ""}} {
+		if _, err := parseObjectEncryption(tc[0], tc[1]); err == nil {
+			t.Errorf("parseObjectEncryption(%q, %q) was accepted", tc[0], tc[1])
+		}
+	}
+}
+
+func TestS3StoreOnCustomEndpoint(t *testing.T) {
+	s3 := newFakeS3(t)
+	store, key, err := openObjectURL("s3://scans/prod/a b.json", objectEncryption{mode: "AES256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.get(key); err != errObjectNotFound {
+		t.Errorf("missing object: %v", err)
+	}
+	if err := store.put(key, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if string(s3.objects["/scans/prod/a%20b.json"]) != "{}" || s3.sse["/scans/prod/a%20b.json"] != "AES256" {
+		t.Errorf("objects %v, sse %v", s3.objects, s3.sse)
+	}
+	if data, err := store.get(key); err != nil || string(data) != "{}" {
+		t.Errorf("get: %q, %v", data, err)
+	}
+}
+
+func TestS3UploadStreamsParts(t *testing.T) {
+	s3 := newFakeS3(t)
+	store, key, _ := openObjectURL("s3://scans/findings.jsonl", objectEncryption{mode: "aws:kms", kmsKey: "alias/scans"})
+
+	small, _ := store.create(key)
+	small.Write([]byte("tiny\n"))
+	if err := small.Close(); err != nil || string(s3.objects["/scans/findings.jsonl"]) != "tiny\n" {
+		t.Errorf("single request upload: %v", err)
+	}
+
+	large, _ := store.create(key)
+	want := bytes.Repeat([]byte("0123456789abcde\n"), (2*objectPartSize+100)/16)
+	for chunk := want; len(chunk) > 0; {
+		n := 1 << 20
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		if _, err := large.Write(chunk[:n]); err != nil {
+			t.Fatal(err)
+		}
+		chunk = chunk[n:]
+	}
+	if len(s3.uploads) != 1 {
+		t.Errorf("%d multipart uploads in progress before close, want 1", len(s3.uploads))
+	}
+	if err := large.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(s3.objects["/scans/findings.jsonl"], want) || s3.sse["/scans/findings.jsonl"] != "aws:kms" {
+		t.Errorf("multipart upload: %d bytes, sse %q", len(s3.objects["/scans/findings.jsonl"]), s3.sse["/scans/findings.jsonl"])
+	}
+
+	aborted, _ := store.create("scans/partial.jsonl")
+	aborted.Write(want[:objectPartSize+1])
+	aborted.abort()
+	if err := aborted.Close(); err == nil || s3.aborted != 1 || s3.objects["/scans/partial.jsonl"] != nil {
+		t.Errorf("abort: close %v, %d aborted", err, s3.aborted)
+	}
+}
+
+// fakeGCS is a storage emulator with media and resumable uploads.
+type fakeGCS struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	sessions map[string][]byte
+	kmsKeys  map[string]string
+}
+
+func newFakeGCS(t *testing.T) *fakeGCS {
+	g := &fakeGCS{objects: make(map[string][]byte), sessions: make(map[string][]byte), kmsKeys: make(map[string]string)}
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	return g
+}
+
+func (g *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == "GET":
+		data, ok := g.objects[strings.TrimPrefix(r.URL.Path, "/storage/v1/b/scans/o/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == "POST" && query.Get("uploadType") == "media":
+		g.objects[query.Get("name")] = body
+		g.kmsKeys[query.Get("name")] = query.Get("kmsKeyName")
+	case r.Method == "POST" && query.Get("uploadType") == "resumable":
+		g.sessions[query.Get("name")] = []byte{}
+		g.kmsKeys[query.Get("name")] = query.Get("kmsKeyName")
+		w.Header().Set("Location", "http://"+r.Host+"/session/"+query.Get("name"))
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/session/"):
+		name := strings.TrimPrefix(r.URL.Path, "/session/")
+		g.sessions[name] = append(g.sessions[name], body...)
+		if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		g.objects[name] = g.sessions[name]
+	case r.Method == "DELETE":
+		delete(g.sessions, strings.TrimPrefix(r.URL.Path, "/session/"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestGCSStoreOnEmulator(t *testing.T) {
+	gcs := newFakeGCS(t)
+	store, key, _ := openObjectURL("gs://scans/prod/history.jsonl", objectEncryption{})
+	if _, err := store.get(key); err != errObjectNotFound {
+		t.Errorf("missing object: %v", err)
+	}
+	if err := store.put(key, []byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if string(gcs.objects["prod/history.jsonl"]) != "line\n" {
+		t.Errorf("objects %v", gcs.objects)
+	}
+	if data, err := store.get(key); err != nil || string(data) != "line\n" {
+		t.Errorf("get: %q, %v", data, err)
+	}
+}
+
+func TestGCSUploadStreamsChunks(t *testing.T) {
+	gcs := newFakeGCS(t)
+	store, key, _ := openObjectURL("gs://scans/findings.jsonl", objectEncryption{mode: "aws:kms", kmsKey: "projects/p/keys/k"})
+	upload, _ := store.create(key)
+	want := bytes.Repeat([]byte("x"), objectPartSize*2+7)
+	if _, err := upload.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := gcs.sessions["findings.jsonl"]; !ok {
+		t.Error("no resumable session before close")
+	}
+	if err := upload.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gcs.objects["findings.jsonl"], want) || gcs.kmsKeys["findings.jsonl"] != "projects/p/keys/k" {
+		t.Errorf("resumable upload: %d bytes, kms key %q", len(gcs.objects["findings.jsonl"]), gcs.kmsKeys["findings.jsonl"])
+	}
+}
@@ -0,0 +1,266 @@
+/**
+ * @file output.go
+ * @brief Serialization of findings to stdout in the selected output format.
+ *
+ * JSON Lines is the default and what the Python reporter consumes. For very
+ * large scans the compact binary formats avoid most of the size and parsing
+ * cost: "proto" writes varint-length-delimited protobuf messages (see
+ * finding.proto) and "msgpack" writes a stream of MessagePack maps. Use
+ * `git_analyzer decode` to turn either back into JSON Lines. "defectdojo"
+ * and "aspm" write a single JSON document for import into vulnerability
+ * management tools (see aspm.go); it is complete once the writer is closed.
+ * "pretty" is a grouped, colorized report for people (see pretty.go), also
+ * written when the writer is closed.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// outputFormats lists the values accepted by --output-format.
+var outputFormats = []string{"json", "proto", "msgpack", "defectdojo", "aspm", "pretty"}
+
+// findingQueueSize is how many encoded findings may wait for the writer.
+const findingQueueSize = 256
+
+/**
+ * @struct findingWriter
+ * @brief Serializes findings to a stream; safe for concurrent use by workers.
+ *
+ * Workers encode findings themselves, in parallel, and hand the finished
+ * records to a single writer goroutine over a channel. Only that goroutine
+ * touches the destination, so records are never interleaved or torn no
+ * matter how many workers there are or how the destination splits writes
+ * (pipes, compressors). Output is buffered and flushed whenever the queue
+ * runs empty, so consumers reading stdout still see findings promptly.
+ */
+type findingWriter struct {
+	mu      sync.RWMutex // Held for reading while sending, for writing while closing
+	w       io.Writer
+	format  string
+	closed  bool
+	start   sync.Once
+	records chan []byte // A nil record asks the goroutine to flush (see flush)
+	flushMu sync.Mutex  // Serializes flushes
+	flushed chan error
+	done    chan struct{}
+	err     error // First write error, set by the writer goroutine before done is closed
+	failed  atomic.Bool
+	pretty  *prettyReport // Findings of the pretty format, rendered when closed
+	written atomic.Int64  // Findings accepted for writing
+}
+
+/**
+ * @brief Creates a writer for the given output format.
+ * @param w The destination stream.
+ * @param format One of outputFormats.
+ * @return The writer and an error if the format is unknown.
+ */
+func newFindingWriter(w io.Writer, format string) (*findingWriter, error) {
+	for _, known := range outputFormats {
+		if format == known {
+			fw := &findingWriter{w: w, format: format}
+			if format == "pretty" {
+				fw.pretty = newPrettyReport(w)
+			}
+			return fw, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output format %q (expected json, proto, msgpack, defectdojo, aspm or pretty)", format)
+}
+
+/**
+ * @brief Starts the writer goroutine on first use.
+ */
+func (fw *findingWriter) run() {
+	fw.start.Do(func() {
+		fw.records = make(chan []byte, findingQueueSize)
+		fw.flushed = make(chan error)
+		fw.done = make(chan struct{})
+		go func() {
+			defer close(fw.done)
+			buffered := bufio.NewWriter(fw.w)
+			open, separator, end := documentFraming(fw.format)
+			_, fw.err = buffered.Write(open)
+			first := true
+			for record := range fw.records {
+				if record == nil {
+					fw.flushed <- fw.flushDestination(buffered)
+					continue
+				}
+				if fw.err != nil {
+					continue // Drain so that senders never block on a dead stream
+				}
+				if !first {
+					buffered.Write(separator)
+				}
+				first = false
+				if _, err := buffered.Write(record); err != nil {
+					fw.err = err
+				} else if len(fw.records) == 0 {
+					fw.err = buffered.Flush()
+				}
+				if fw.err != nil {
+					fw.failed.Store(true)
+				}
+			}
+			if fw.err == nil && fw.pretty != nil {
+				fw.err = fw.pretty.render(buffered)
+			}
+			if fw.err == nil {
+				buffered.Write(end)
+				fw.err = buffered.Flush()
+			}
+		}()
+	})
+}
+
+/**
+ * @brief Encodes one finding and queues it as a single record.
+ * @param f The finding to write.
+ * @return An error if encoding failed, the writer is closed, or an earlier write failed.
+ */
+func (fw *findingWriter) write(f *finding) error {
+	if fw.pretty != nil {
+		return fw.count(fw.collect(f))
+	}
+	var record []byte
+	var err error
+	switch fw.format {
+	case "proto":
+		record = delimited(marshalProto(f))
+	case "msgpack":
+		record, err = marshalMsgpack(f)
+	case "defectdojo":
+		record, err = marshalDefectDojo(f)
+	case "aspm":
+		record, err = marshalASPM(f)
+	default:
+		record, err = json.Marshal(f)
+		record = append(record, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	return fw.count(fw.queue(record))
+}
+
+// count adds a finding to the written total if it was accepted.
+func (fw *findingWriter) count(err error) error {
+	if err == nil {
+		fw.written.Add(1)
+	}
+	return err
+}
+
+/**
+ * @brief Queues an already encoded record for the writer goroutine.
+ * @param record The complete record, including any delimiter.
+ * @return An error if the writer is closed or an earlier write failed.
+ */
+func (fw *findingWriter) queue(record []byte) error {
+	fw.run()
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	if fw.closed {
+		return os.ErrClosed
+	}
+	if fw.failed.Load() {
+		return errors.New("output stream failed; see the error reported when it is closed")
+	}
+	fw.records <- record
+	return nil
+}
+
+/**
+ * @brief Waits until every queued finding is written and flushed to the
+ * destination, e.g. before a queue item is acknowledged.
+ * Document formats are only complete once closed; their records are still
+ * handed to the destination.
+ * @return An error if the writer is closed or writing or flushing failed.
+ */
+func (fw *findingWriter) flush() error {
+	if fw.pretty != nil {
+		return nil // Rendered when closed
+	}
+	fw.run()
+	fw.flushMu.Lock()
+	defer fw.flushMu.Unlock()
+	fw.mu.RLock()
+	if fw.closed {
+		fw.mu.RUnlock()
+		return os.ErrClosed
+	}
+	fw.records <- nil
+	fw.mu.RUnlock()
+	return <-fw.flushed
+}
+
+// flushDestination runs on the writer goroutine for flush.
+func (fw *findingWriter) flushDestination(buffered *bufio.Writer) error {
+	if fw.err == nil {
+		fw.err = buffered.Flush()
+	}
+	if flusher, ok := fw.w.(interface{ Flush() error }); ok && fw.err == nil {
+		fw.err = flusher.Flush()
+	}
+	if fw.err != nil {
+		fw.failed.Store(true)
+	}
+	return fw.err
+}
+
+/**
+ * @brief Keeps a finding of the pretty format until the writer is closed.
+ * @return An error if the writer is closed.
+ */
+func (fw *findingWriter) collect(f *finding) error {
+	fw.run()
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	if fw.closed {
+		return os.ErrClosed
+	}
+	fw.pretty.add(f)
+	return nil
+}
+
+/**
+ * @brief Drains the queue and closes the underlying stream if it is closable
+ * (e.g. a compressed file). Further writes fail with os.ErrClosed; closing
+ * twice is a no-op.
+ * @return An error if writing, flushing or closing failed.
+ */
+func (fw *findingWriter) close() error {
+	fw.run()
+	fw.mu.Lock()
+	if fw.closed {
+		fw.mu.Unlock()
+		<-fw.done // Let a concurrent close (e.g. on a signal) finish draining
+		return nil
+	}
+	fw.closed = true
+	close(fw.records)
+	fw.mu.Unlock()
+
+	<-fw.done
+	err := fw.err
+	if closer, ok := fw.w.(io.Closer); ok && fw.w != os.Stdout {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// findingsSink is the process-wide writer used by the scanning workers.
+var findingsSink = &findingWriter{w: os.Stdout, format: "json"}
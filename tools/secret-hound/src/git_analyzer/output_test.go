@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sampleFindings are findings with every kind of value the encoders handle.
+func sampleFindings() []*finding {
+	return []*finding{
+		{SchemaVersion: findingSchemaVersion, Repository: "billing", Commit: "c0ffee", OriginalPath: "config/prod.env", File: "/tmp/blob",
+			Line: 70000, RuleID: "AWS_ACCESS_KEY", Description: "AWS key", Match: "AKIAÄ✓", Entropy: 4.25, Confidence: "High",
+			Context: []contextLine{{Line: 69999, Text: "# prod"}, {Line: 70000, Text: "KEY=[REDACTED]"}}},
+		{SchemaVersion: findingSchemaVersion, Commit: "WORKTREE", OriginalPath: "a", Line: 1, RuleID: "X", Match: ""},
+	}
+}
+
+// jsonValue decodes the JSON encoding of v into generic values, for comparisons across formats.
+func jsonValue(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generic interface{}
+	json.Unmarshal(data, &generic)
+	return generic
+}
+
+func TestProtoOutputRoundTrips(t *testing.T) {
+	var out bytes.Buffer
+	fw, err := newFindingWriter(&out, "proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range sampleFindings() {
+		if err := fw.write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(&out)
+	for _, want := range sampleFindings() {
+		msg, err := readDelimited(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := &finding{}
+		if err := unmarshalProto(msg, got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decoded %+v, want %+v", got, want)
+		}
+	}
+	if _, err := readDelimited(reader); err != io.EOF {
+		t.Errorf("after the last record: %v, want EOF", err)
+	}
+}
+
+func TestMsgpackOutputRoundTrips(t *testing.T) {
+	var out bytes.Buffer
+	fw, _ := newFindingWriter(&out, "msgpack")
+	for _, f := range sampleFindings() {
+		if err := fw.write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(&out)
+	for _, want := range sampleFindings() {
+		got, err := readMsgpack(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(jsonValue(t, got), jsonValue(t, want)) {
+			t.Errorf("decoded %v, want %+v", got, want)
+		}
+	}
+	if _, err := readMsgpack(reader); err != io.EOF {
+		t.Errorf("after the last record: %v, want EOF", err)
+	}
+}
+
+func TestUnknownOutputFormat(t *testing.T) {
+	if _, err := newFindingWriter(io.Discard, "xml"); err == nil {
+		t.Error("--output-format xml was accepted")
+	}
+}
+
+// trickleWriter accepts at most 7 bytes per call, like a pipe under pressure.
+type trickleWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *trickleWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	written := len(p)
+	for len(p) > 0 {
+		n := len(p)
+		if n > 7 {
+			n = 7
+		}
+		w.buf.Write(p[:n])
+		p = p[n:]
+		runtime.Gosched()
+	}
+	return written, nil
+}
+
+func TestConcurrentWritesAreNeverInterleaved(t *testing.T) {
+	out := &trickleWriter{}
+	fw, err := newFindingWriter(out, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fw.write(&finding{RuleID: fmt.Sprintf("W%d", worker), Line: i + 1, Match: strings.Repeat("x", 100)})
+			}
+		}(worker)
+	}
+	wg.Wait()
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.buf.String()), "\n")
+	if len(lines) != 16*50 {
+		t.Fatalf("%d records, want %d", len(lines), 16*50)
+	}
+	for _, line := range lines {
+		var f finding
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatalf("torn record %q: %v", line, err)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteErrorsSurface(t *testing.T) {
+	fw, _ := newFindingWriter(failingWriter{}, "json")
+	fw.write(&finding{RuleID: "A"})
+	// The writer goroutine records the failure; later writes report it.
+	deadline := time.Now().Add(5 * time.Second)
+	for fw.write(&finding{RuleID: "B"}) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("writes keep succeeding on a failed stream")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := fw.close(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("close = %v, want the write error", err)
+	}
+}
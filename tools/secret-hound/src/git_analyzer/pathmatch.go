@@ -0,0 +1,105 @@
+/**
+ * @file pathmatch.go
+ * @brief gitignore-style glob matching for repository paths.
+ *
+ * Patterns without a slash match the file name in any directory ("*.pem").
+ * Patterns with a slash match the whole path from the repository root
+ * ("config/*.yml"), "**" crosses directory boundaries ("docs/**"), and a
+ * trailing slash matches everything below a directory of that name ("dist/").
+ */
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	globCacheMu sync.Mutex
+	globCache   = make(map[string]*regexp.Regexp)
+)
+
+/**
+ * @brief Reports whether a repository path matches a glob pattern.
+ * @param pattern The gitignore-style pattern.
+ * @param path The slash-separated, repository-relative path.
+ * @return True on a match.
+ */
+func matchPathGlob(pattern, path string) bool {
+	return compileGlob(pattern).MatchString(path)
+}
+
+/**
+ * @brief Reports whether a path matches any of the patterns.
+ * @param patterns The gitignore-style patterns.
+ * @param path The slash-separated, repository-relative path.
+ * @return True if at least one pattern matches.
+ */
+func matchAnyGlob(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlob(pattern string) *regexp.Regexp {
+	globCacheMu.Lock()
+	defer globCacheMu.Unlock()
+	if re, ok := globCache[pattern]; ok {
+		return re
+	}
+
+	p := pattern
+	prefix, suffix := "^", "$"
+	if strings.HasSuffix(p, "/") {
+		// A directory: match anything below it, at any depth unless anchored.
+		p = strings.TrimSuffix(p, "/")
+		suffix = "/.*$"
+	}
+	if strings.HasPrefix(p, "/") {
+		p = strings.TrimPrefix(p, "/")
+	} else if !strings.Contains(p, "/") {
+		prefix = "^(.*/)?"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '*' && strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(p[i:], ']'); end > 0 {
+				class := p[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(`\[`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	re, err := regexp.Compile(prefix + b.String() + suffix)
+	if err != nil {
+		re = regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	globCache[pattern] = re
+	return re
+}
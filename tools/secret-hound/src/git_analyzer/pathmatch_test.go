@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestMatchPathGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.pem", "key.pem", true},
+		{"*.pem", "deep/dir/key.pem", true},
+		{"*.pem", "key.pem.bak", false},
+		{"config/*.yml", "config/app.yml", true},
+		{"config/*.yml", "config/sub/app.yml", false},
+		{"config/*.yml", "other/config/app.yml", false},
+		{"docs/**", "docs/a/b/c.md", true},
+		{"**/fixtures/*.json", "fixtures/a.json", true},
+		{"**/fixtures/*.json", "src/test/fixtures/a.json", true},
+		{"dist/", "dist/app.js", true},
+		{"dist/", "web/dist/app.js", true},
+		{"dist/", "dist", false},
+		{"/build/", "build/out.js", true},
+		{"/build/", "src/build/out.js", false},
+		{"id_?sa", "id_rsa", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+	} {
+		if got := matchPathGlob(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+	if !matchAnyGlob([]string{"*.key", "*.env"}, "prod.env") || matchAnyGlob(nil, "prod.env") {
+		t.Error("matchAnyGlob")
+	}
+}
@@ -0,0 +1,239 @@
+/**
+ * @file pem_detector.go
+ * @brief Detects PEM-encoded private keys and extracts their metadata.
+ *
+ * Unlike a regex on the BEGIN line, the key is parsed where possible so the
+ * finding can report the key type, its size, and whether it is protected by a
+ * passphrase. An unencrypted private key is critical; an encrypted one still
+ * leaks but requires an offline brute-force first. Certificates found in the
+ * same file are not reported on their own, but their subject and expiry are
+ * attached to the key so responders know what the key was used for.
+ * Binary PKCS#12 keystores (.p12/.pfx) are reported from their file name.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * @struct pemDetector
+ * @brief The "pem" detector.
+ */
+type pemDetector struct{}
+
+func (pemDetector) name() string { return "pem" }
+
+func (pemDetector) detect(filePath string, content []byte) []detection {
+	ext := strings.ToLower(path.Ext(filePath))
+	if ext == ".p12" || ext == ".pfx" {
+		return []detection{{
+			ruleID:      "PKCS12_KEYSTORE",
+			description: "PKCS#12 keystore (private key container)",
+			line:        1,
+			match:       path.Base(filePath),
+			confidence:  "High",
+			severity:    "high",
+			metadata:    map[string]string{"format": "pkcs12", "encrypted": "unknown"},
+		}}
+	}
+	if !bytes.Contains(content, []byte("-----BEGIN ")) {
+		return nil
+	}
+
+	var found []detection
+	var certMeta map[string]string
+	rest := content
+	for {
+		start := bytes.Index(rest, []byte("-----BEGIN "))
+		if start < 0 {
+			break
+		}
+		offset := len(content) - len(rest) + start
+		block, remaining := pem.Decode(rest[start:])
+		if block == nil {
+			rest = rest[start+len("-----BEGIN "):]
+			continue
+		}
+		rest = remaining
+
+		if block.Type == "CERTIFICATE" {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil && certMeta == nil {
+				certMeta = map[string]string{
+					"certificate_subject": cert.Subject.String(),
+					"certificate_expires": cert.NotAfter.UTC().Format(time.RFC3339),
+				}
+			}
+			continue
+		}
+		if !strings.Contains(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		meta := privateKeyMetadata(block)
+		severity := "critical"
+		if meta["encrypted"] == "true" {
+			severity = "medium"
+		}
+		found = append(found, detection{
+			ruleID:      "PRIVATE_KEY",
+			description: "Private key (" + meta["key_type"] + ")",
+			line:        bytes.Count(content[:offset], []byte("\n")) + 1,
+			match:       "-----BEGIN " + block.Type + "-----",
+			confidence:  "High",
+			severity:    severity,
+			metadata:    meta,
+		})
+	}
+
+	for i := range found {
+		for k, v := range certMeta {
+			found[i].metadata[k] = v
+		}
+	}
+	return found
+}
+
+/**
+ * @brief Parses a private key block and describes it.
+ * @param block The decoded PEM block.
+ * @return Metadata with key_type, bits (when known), encrypted, and format.
+ */
+func privateKeyMetadata(block *pem.Block) map[string]string {
+	meta := map[string]string{"key_type": "unknown", "encrypted": "false"}
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		meta["encrypted"] = "true"
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		meta["key_type"], meta["format"] = "RSA", "pkcs1"
+		if meta["encrypted"] == "false" {
+			if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+				meta["bits"] = strconv.Itoa(key.N.BitLen())
+			}
+		}
+	case "EC PRIVATE KEY":
+		meta["key_type"], meta["format"] = "EC", "sec1"
+		if meta["encrypted"] == "false" {
+			if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+				meta["bits"] = strconv.Itoa(key.Curve.Params().BitSize)
+				meta["curve"] = key.Curve.Params().Name
+			}
+		}
+	case "DSA PRIVATE KEY":
+		meta["key_type"], meta["format"] = "DSA", "openssl"
+	case "ENCRYPTED PRIVATE KEY":
+		meta["format"], meta["encrypted"] = "pkcs8", "true"
+	case "PRIVATE KEY":
+		meta["format"] = "pkcs8"
+		if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			switch k := key.(type) {
+			case *rsa.PrivateKey:
+				meta["key_type"], meta["bits"] = "RSA", strconv.Itoa(k.N.BitLen())
+			case *ecdsa.PrivateKey:
+				meta["key_type"], meta["bits"] = "EC", strconv.Itoa(k.Curve.Params().BitSize)
+				meta["curve"] = k.Curve.Params().Name
+			case ed25519.PrivateKey:
+				meta["key_type"], meta["bits"] = "Ed25519", "256"
+			default:
+				meta["key_type"] = "X25519"
+			}
+		}
+	case "OPENSSH PRIVATE KEY":
+		meta["format"] = "openssh"
+		if keyType, bits, encrypted, err := parseOpenSSHKeyHeader(block.Bytes); err == nil {
+			meta["key_type"] = keyType
+			if bits > 0 {
+				meta["bits"] = strconv.Itoa(bits)
+			}
+			meta["encrypted"] = strconv.FormatBool(encrypted)
+		}
+	case "PGP PRIVATE KEY BLOCK":
+		meta["key_type"], meta["format"] = "PGP", "openpgp"
+	}
+	return meta
+}
+
+/**
+ * @brief Reads the unencrypted header of an openssh-key-v1 private key.
+ * The public key and cipher name are stored in clear even when the private
+ * part is encrypted, so type, size and protection can always be reported.
+ * @param data The base64-decoded body of the PEM block.
+ * @return The SSH key type, its size in bits (0 if unknown), whether it is encrypted, and an error.
+ */
+func parseOpenSSHKeyHeader(data []byte) (string, int, bool, error) {
+	const magic = "openssh-key-v1\x00"
+	if !bytes.HasPrefix(data, []byte(magic)) {
+		return "", 0, false, errors.New("not an openssh-key-v1 key")
+	}
+	r := sshReader{data: data[len(magic):]}
+	cipher := r.str()
+	r.str() // kdfname
+	r.str() // kdfoptions
+	if r.uint32() < 1 {
+		return "", 0, false, errors.New("no keys")
+	}
+	pub := sshReader{data: r.bytes()}
+	if r.err != nil {
+		return "", 0, false, r.err
+	}
+
+	keyType := pub.str()
+	bits := 0
+	switch {
+	case keyType == "ssh-rsa":
+		pub.bytes() // e
+		bits = new(big.Int).SetBytes(pub.bytes()).BitLen()
+	case keyType == "ssh-ed25519":
+		bits = 256
+	case strings.HasPrefix(keyType, "ecdsa-sha2-nistp"):
+		bits, _ = strconv.Atoi(strings.TrimPrefix(keyType, "ecdsa-sha2-nistp"))
+	}
+	return keyType, bits, cipher != "none", pub.err
+}
+
+/**
+ * @struct sshReader
+ * @brief Reads SSH wire-format values (uint32 length-prefixed strings).
+ */
+type sshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sshReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = errors.New("truncated ssh data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *sshReader) bytes() []byte {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = errors.New("truncated ssh data")
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *sshReader) str() string { return string(r.bytes()) }
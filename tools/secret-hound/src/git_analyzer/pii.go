@@ -0,0 +1,212 @@
+/**
+ * @file pii.go
+ * @brief Personal data detection for privacy audits (--detect-pii).
+ *
+ * A privacy audit asks the same question of a repository's history as a
+ * secret scan, about different data: which commits ever contained email
+ * addresses, phone numbers or national identifiers. --detect-pii runs this
+ * pass in the same sweep, so the walk, deduplication and sinks are shared.
+ * PII findings carry "category": "pii" and PII_* rule ids, so consumers can
+ * route them apart from credentials. The pass is off by default; its value
+ * selects the kinds ("all" or a comma-separated list of email, phone,
+ * us-ssn, uk-nino, iban, credit-card), and --pii-patterns adds
+ * organization-specific patterns from a JSON file:
+ *   [{"id": "EMPLOYEE_ID", "description": "Employee number", "regex": "\\bE[0-9]{6}\\b"}]
+ * Candidates are validated where the format allows (IBAN and card check
+ * digits, SSN reserved ranges), and documentation addresses (example.com,
+ * noreply senders) are not reported.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/**
+ * @struct piiKind
+ * @brief One kind of personal data and how to recognize it.
+ */
+type piiKind struct {
+	id          string                  // Selection name, e.g. "email"
+	ruleID      string                  // Rule id of the findings
+	description string                  // Finding description
+	pattern     *regexp.Regexp          // Candidate matcher
+	valid       func(match string) bool // Rejects candidates that are not real data (nil = accept all)
+	severity    string
+}
+
+var builtinPIIKinds = []piiKind{
+	{"email", "PII_EMAIL", "Email address", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`), validEmail, "low"},
+	{"phone", "PII_PHONE", "Phone number", regexp.MustCompile(`(?:\+[1-9][0-9]{0,2}[ .-]?)?\(?[0-9]{3}\)?[ .-][0-9]{3}[ .-][0-9]{4}\b|\+[1-9][0-9]{9,14}\b`), nil, "low"},
+	{"us-ssn", "PII_US_SSN", "US Social Security number", regexp.MustCompile(`\b[0-9]{3}-[0-9]{2}-[0-9]{4}\b`), validSSN, "high"},
+	{"uk-nino", "PII_UK_NINO", "UK National Insurance number", regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?[0-9]{2} ?[0-9]{2} ?[0-9]{2} ?[A-D]\b`), nil, "high"},
+	{"iban", "PII_IBAN", "International bank account number", regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`), validIBAN, "medium"},
+	{"credit-card", "PII_CREDIT_CARD", "Payment card number", regexp.MustCompile(`\b(?:[0-9][ -]?){12,18}[0-9]\b`), validCardNumber, "high"},
+}
+
+/**
+ * @struct piiDetector
+ * @brief The PII pass; a detector enabled by --detect-pii instead of --detectors.
+ */
+type piiDetector struct {
+	kinds []piiKind
+}
+
+func (*piiDetector) name() string { return "pii" }
+
+func (p *piiDetector) detect(filePath string, content []byte) []detection {
+	var found []detection
+	for _, kind := range p.kinds {
+		for _, loc := range kind.pattern.FindAllIndex(content, -1) {
+			match := string(content[loc[0]:loc[1]])
+			if kind.valid != nil && !kind.valid(match) {
+				continue
+			}
+			found = append(found, detection{
+				ruleID:      kind.ruleID,
+				description: kind.description,
+				line:        bytes.Count(content[:loc[0]], []byte("\n")) + 1,
+				match:       match,
+				confidence:  "Medium",
+				severity:    kind.severity,
+				category:    "pii",
+			})
+		}
+	}
+	return found
+}
+
+/**
+ * @brief Creates the PII pass from the --detect-pii and --pii-patterns values.
+ * @param spec "all" or a comma-separated list of kinds ("" = off).
+ * @param patternsPath A JSON file of extra patterns ("" = none).
+ * @return The detector (nil if the pass is off) and an error for unknown
+ *         kinds or an unreadable patterns file.
+ */
+func newPIIDetector(spec, patternsPath string) (*piiDetector, error) {
+	if spec == "" || spec == "none" {
+		if patternsPath != "" {
+			return nil, fmt.Errorf("--pii-patterns requires --detect-pii")
+		}
+		return nil, nil
+	}
+	p := &piiDetector{}
+	if spec == "all" {
+		p.kinds = append(p.kinds, builtinPIIKinds...)
+	} else {
+		byID := make(map[string]piiKind)
+		var ids []string
+		for _, kind := range builtinPIIKinds {
+			byID[kind.id] = kind
+			ids = append(ids, kind.id)
+		}
+		sort.Strings(ids)
+		for _, id := range strings.Split(spec, ",") {
+			kind, ok := byID[strings.TrimSpace(id)]
+			if !ok {
+				return nil, fmt.Errorf("unknown PII kind %q (available: %s)", id, strings.Join(ids, ", "))
+			}
+			p.kinds = append(p.kinds, kind)
+		}
+	}
+
+	if patternsPath == "" {
+		return p, nil
+	}
+	data, err := ioutil.ReadFile(patternsPath)
+	if err != nil {
+		return nil, err
+	}
+	var custom []struct {
+		ID          string `json:"id"`
+		Description string `json:"description"`
+		Regex       string `json:"regex"`
+		Severity    string `json:"severity"`
+	}
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("%s: %v", patternsPath, err)
+	}
+	for _, c := range custom {
+		if c.ID == "" || c.Regex == "" {
+			return nil, fmt.Errorf("%s: every pattern needs an id and a regex", patternsPath)
+		}
+		re, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", patternsPath, c.ID, err)
+		}
+		if _, ok := severityRanks[orDefault(c.Severity, "low")]; !ok {
+			return nil, fmt.Errorf("%s: %s: unknown severity %q", patternsPath, c.ID, c.Severity)
+		}
+		p.kinds = append(p.kinds, piiKind{strings.ToLower(c.ID), "PII_" + strings.TrimPrefix(strings.ToUpper(c.ID), "PII_"),
+			orDefault(c.Description, c.ID), re, nil, orDefault(c.Severity, "low")})
+	}
+	return p, nil
+}
+
+// exampleEmailDomains are documentation and placeholder domains, never personal data.
+var exampleEmailDomains = regexp.MustCompile(`(?i)(^|\.)(example\.(com|org|net)|test|invalid|localhost|local)$`)
+
+func validEmail(match string) bool {
+	local, domain, _ := strings.Cut(match, "@")
+	local = strings.ToLower(local)
+	if exampleEmailDomains.MatchString(domain) || strings.HasSuffix(strings.ToLower(domain), "users.noreply.github.com") {
+		return false
+	}
+	return !strings.Contains(local, "noreply") && !strings.Contains(local, "no-reply")
+}
+
+// validSSN rejects numbers in ranges the SSA never assigns.
+func validSSN(match string) bool {
+	area, group, serial := match[:3], match[4:6], match[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validIBAN checks the ISO 13616 mod-97 check digits.
+func validIBAN(match string) bool {
+	iban := strings.ReplaceAll(match, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprint(&digits, int(c-'A')+10)
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validCardNumber checks the Luhn digit of a 13 to 19 digit number.
+func validCardNumber(match string) bool {
+	var digits []int
+	for _, c := range match {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 || digits[0] == 0 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
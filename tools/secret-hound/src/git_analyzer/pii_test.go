@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPIIDetections(t *testing.T) {
+	p, err := newPIIDetector("all", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := `owner: jane.doe@acme-corp.io
+docs: someone@example.com, 123+bot@users.noreply.github.com, no-reply@acme-corp.io
+call +14155550123 or (555) 867-5309
+ssn: 219-09-9999 invalid: 666-12-3456 000-12-3456
+nino: AB 12 34 56 C
+iban: GB82 WEST 1234 5698 7654 32 bad: GB00 WEST 1234 5698 7654 32
+card: 4111 1111 1111 1111 bad: 4111 1111 1111 1112
+`
+	found := make(map[string][]string)
+	for _, d := range p.detect("contacts.txt", []byte(content)) {
+		if d.category != "pii" {
+			t.Errorf("%s has category %q", d.ruleID, d.category)
+		}
+		found[d.ruleID] = append(found[d.ruleID], d.match)
+	}
+	for rule, want := range map[string][]string{
+		"PII_EMAIL":       {"jane.doe@acme-corp.io"},
+		"PII_US_SSN":      {"219-09-9999"},
+		"PII_UK_NINO":     {"AB 12 34 56 C"},
+		"PII_IBAN":        {"GB82 WEST 1234 5698 7654 32"},
+		"PII_CREDIT_CARD": {"4111 1111 1111 1111"},
+	} {
+		if len(found[rule]) != len(want) || found[rule][0] != want[0] {
+			t.Errorf("%s: %q, want %q", rule, found[rule], want)
+		}
+	}
+	if len(found["PII_PHONE"]) < 2 {
+		t.Errorf("phone numbers %q", found["PII_PHONE"])
+	}
+}
+
+func TestPIIKindSelection(t *testing.T) {
+	if p, err := newPIIDetector("", ""); p != nil || err != nil {
+		t.Errorf("off: %v, %v", p, err)
+	}
+	p, err := newPIIDetector("email, iban", "")
+	if err != nil || len(p.kinds) != 2 {
+		t.Fatalf("email, iban: %v, %v", p, err)
+	}
+	if found := p.detect("a", []byte("219-09-9999 jane@acme-corp.io")); len(found) != 1 || found[0].ruleID != "PII_EMAIL" {
+		t.Errorf("unselected kinds were reported: %+v", found)
+	}
+	if _, err := newPIIDetector("passport", ""); err == nil {
+		t.Error("an unknown kind was accepted")
+	}
+	if _, err := newPIIDetector("", "patterns.json"); err == nil {
+		t.Error("--pii-patterns without --detect-pii was accepted")
+	}
+}
+
+func TestCustomPIIPatterns(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "patterns.json")
+		os.WriteFile(path, []byte(content), 0o644)
+		return path
+	}
+	p, err := newPIIDetector("email", write(`[{"id": "employee_id", "description": "Employee number", "regex": "\\bE[0-9]{6}\\b", "severity": "medium"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := p.detect("hr.csv", []byte("id,name\nE123456,Jane\n"))
+	if len(found) != 1 || found[0].ruleID != "PII_EMPLOYEE_ID" || found[0].severity != "medium" || found[0].line != 2 {
+		t.Errorf("custom pattern detections %+v", found)
+	}
+	for _, bad := range []string{`{}`, `[{"id": "x"}]`, `[{"id": "x", "regex": "("}]`, `[{"id": "x", "regex": "y", "severity": "dire"}]`} {
+		if _, err := newPIIDetector("all", write(bad)); err == nil {
+			t.Errorf("patterns %s were accepted", bad)
+		}
+	}
+}
+
+func TestPIIFindingCategory(t *testing.T) {
+	f := newDetectorFinding(detection{ruleID: "PII_EMAIL", category: "pii"}, fileBlob{repo: &repository{}})
+	if f.Category != "pii" {
+		t.Errorf("category %q", f.Category)
+	}
+}
@@ -0,0 +1,142 @@
+/**
+ * @file pipeline.go
+ * @brief Staged scan pipeline of one repository: discover → fetch → scan → enrich.
+ *
+ * Reading a blob is git IO (a `git cat-file` per blob), scanning it is CPU in
+ * the core scanner or the native engine, and enriching it may wait on the
+ * network (revocation, the managed inventory). Running all three inside one
+ * worker left the CPU idle while git was reading and vice versa. Each stage
+ * now has its own goroutines, connected by bounded queues:
+ *   - fetch: --fetch-workers goroutines read and transcode blobs ahead of the
+ *     scanners;
+ *   - scan: the repository's scan workers, each holding a scheduler token
+ *     only while it scans, so --workers and --autoscale size the CPU stage;
+ *   - enrich: --enrich-workers goroutines enrich the findings, apply the
+ *     policy and write them to the sink.
+ * The memory budget is taken when a blob is discovered and returned when it
+ * leaves the pipeline, so prefetching never holds more than --max-memory of
+ * blob content. Blobs larger than --chunk-size are fetched as several
+ * windows (see chunk.go), each taking the budget for itself.
+ */
+
+package main
+
+import "sync"
+
+/**
+ * @brief Scans a repository's blobs through the fetch, scan and enrich stages.
+ * @param repo The repository being scanned.
+ * @param blobs The blobs to scan.
+ * @param blobSizes The size of every blob, for the memory budget.
+ * @param scanWorkers The number of scan stage goroutines.
+ * @return The number of blobs fed to the pipeline (fewer than len(blobs) if --time-budget ran out).
+ */
+func (a *analyzer) runPipeline(repo *repository, blobs []fileBlob, blobSizes map[string]int64, scanWorkers int) int {
+	if scanWorkers < 1 {
+		scanWorkers = 1
+	}
+	fetchWorkers := a.opts.fetchWorkers
+	if fetchWorkers < 1 {
+		fetchWorkers = 1
+	}
+	enrichWorkers := a.opts.enrichWorkers
+	if enrichWorkers < 1 {
+		enrichWorkers = 1
+	}
+
+	// The queues hold one item per downstream worker: enough to keep every
+	// stage busy, while the memory budget bounds what is read ahead.
+	fetchQueue := make(chan fileBlob, fetchWorkers)
+	scanQueue := make(chan *blobWork, scanWorkers)
+	enrichQueue := make(chan *blobWork, enrichWorkers)
+	cost := func(blob fileBlob) int64 {
+		if a.chunked(blob, blobSizes[blob.hash]) {
+			return 0 // Taken per window
+		}
+		return blobSizes[blob.hash]
+	}
+	done := func(blob fileBlob) {
+		a.budget.release(cost(blob))
+		a.journal.finish(blob)
+	}
+	// finish is done for work items, which are windows of chunked blobs.
+	finish := func(w *blobWork) {
+		if w.chunk == nil {
+			done(w.blob)
+			return
+		}
+		a.budget.release(int64(len(w.raw)))
+		if w.chunk.release() {
+			done(w.blob)
+		}
+	}
+
+	stage := func(workers int, out func(), body func()) {
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				body()
+			}()
+		}
+		go func() {
+			wg.Wait()
+			out()
+		}()
+	}
+
+	stage(fetchWorkers, func() { close(scanQueue) }, func() {
+		for blob := range fetchQueue {
+			if a.chunked(blob, blobSizes[blob.hash]) {
+				a.fetchChunks(blob, scanQueue, done)
+				continue
+			}
+			w, err := a.fetchBlob(blob)
+			if err != nil {
+				scanFailures.record(err)
+				done(blob)
+				continue
+			}
+			scanQueue <- w
+		}
+	})
+	stage(scanWorkers, func() { close(enrichQueue) }, func() {
+		for w := range scanQueue {
+			a.sched.acquire(repo.priority)
+			kept := a.scanBlob(w)
+			a.sched.release()
+			if w.chunk != nil {
+				w.chunk.settle(w) // After the release: it may wait for another worker's window
+			}
+			if !kept || len(w.findings) == 0 {
+				finish(w)
+				continue
+			}
+			enrichQueue <- w
+		}
+	})
+	finished := make(chan struct{})
+	stage(enrichWorkers, func() { close(finished) }, func() {
+		for w := range enrichQueue {
+			a.finishBlob(w)
+			finish(w)
+		}
+	})
+
+	// Discover: feed the pipeline, pausing whenever the memory budget is
+	// exhausted until blobs leaving it have released enough.
+	// A --time-budget stops the feed; blobs in flight are finished.
+	fed := 0
+	for _, blob := range blobs {
+		if !a.deadline.admit(repo) {
+			break
+		}
+		a.budget.acquire(cost(blob))
+		fetchQueue <- blob
+		fed++
+	}
+	close(fetchQueue)
+	<-finished
+	return fed
+}
@@ -0,0 +1,98 @@
+/**
+ * @file plan.go
+ * @brief The scan plan reported by --dry-run.
+ *
+ * A dry run performs the history walk, filtering and deduplication exactly as a
+ * real scan would, then stops short of invoking the core scanner. The plan tells
+ * users how much work a real run would do, so they can estimate runtime and tune
+ * depth and filters first.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+/**
+ * @struct planEntry
+ * @brief Work that a real scan would perform on one repository.
+ */
+type planEntry struct {
+	label   string
+	commits int
+	blobs   int
+	bytes   int64
+}
+
+/**
+ * @struct scanPlan
+ * @brief Collects plan entries from (possibly concurrent) repository scans.
+ */
+type scanPlan struct {
+	mu      sync.Mutex
+	entries []planEntry
+}
+
+/**
+ * @brief Records the work planned for one repository.
+ * @param label The repository label ("" for the current repository).
+ * @param commits The number of commits covered by the walk.
+ * @param blobs The unique blobs that would be scanned.
+ * @param sizes The size of each blob by hash.
+ */
+func (p *scanPlan) add(label string, commits int, blobs []fileBlob, sizes map[string]int64) {
+	entry := planEntry{label: label, commits: commits, blobs: len(blobs)}
+	for _, blob := range blobs {
+		entry.bytes += sizes[blob.hash]
+	}
+	p.mu.Lock()
+	p.entries = append(p.entries, entry)
+	p.mu.Unlock()
+}
+
+/**
+ * @brief Prints the plan, with a total line when several repositories were planned.
+ * @param w The destination writer.
+ */
+func (p *scanPlan) print(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var total planEntry
+	for _, e := range p.entries {
+		name := e.label
+		if name == "" {
+			name = "repository"
+		}
+		fmt.Fprintf(w, "Dry run: %s: %d commits, %d unique blobs, %s would be scanned\n",
+			name, e.commits, e.blobs, formatBytes(e.bytes))
+		total.commits += e.commits
+		total.blobs += e.blobs
+		total.bytes += e.bytes
+	}
+	if len(p.entries) > 1 {
+		fmt.Fprintf(w, "Dry run: total: %d repositories, %d commits, %d unique blobs, %s would be scanned\n",
+			len(p.entries), total.commits, total.blobs, formatBytes(total.bytes))
+	}
+}
+
+/**
+ * @brief Formats a byte count with a binary unit suffix (e.g. "1.5 MiB").
+ * @param n The number of bytes.
+ * @return The formatted string.
+ */
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
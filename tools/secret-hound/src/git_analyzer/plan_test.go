@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KiB", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestScanPlanTotals(t *testing.T) {
+	var plan scanPlan
+	sizes := map[string]int64{"a": 1000, "b": 2000, "c": 1 << 20}
+	plan.add("", 3, []fileBlob{{hash: "a"}, {hash: "b"}}, sizes)
+	var out bytes.Buffer
+	plan.print(&out)
+	if want := "Dry run: repository: 3 commits, 2 unique blobs, 2.9 KiB would be scanned\n"; out.String() != want {
+		t.Errorf("single repository plan:\n%s", out.String())
+	}
+
+	plan.add("https://git.example.com/api.git", 7, []fileBlob{{hash: "c"}}, sizes)
+	out.Reset()
+	plan.print(&out)
+	want := "Dry run: repository: 3 commits, 2 unique blobs, 2.9 KiB would be scanned\n" +
+		"Dry run: https://git.example.com/api.git: 7 commits, 1 unique blobs, 1.0 MiB would be scanned\n" +
+		"Dry run: total: 2 repositories, 10 commits, 3 unique blobs, 1.0 MiB would be scanned\n"
+	if out.String() != want {
+		t.Errorf("sweep plan:\n%s", out.String())
+	}
+}
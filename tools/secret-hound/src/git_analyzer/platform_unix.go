@@ -0,0 +1,30 @@
+//go:build !windows
+
+/**
+ * @file platform_unix.go
+ * @brief Unix implementations of the platform hooks in process.go.
+ */
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminationSignals are the signals that stop a scan gracefully.
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+/**
+ * @brief Asks a child process to stop; the core scanner exits on SIGTERM.
+ */
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+/**
+ * @brief Returns a path usable with the os package; no conversion is needed on Unix.
+ */
+func longPath(path string) string {
+	return path
+}
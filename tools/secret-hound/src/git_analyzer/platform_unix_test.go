@@ -0,0 +1,44 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestTerminateChildrenStopsRunningProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	done := make(chan error, 1)
+	go func() {
+		_, err := runTracked(cmd)
+		done <- err
+	}()
+	// Wait until runTracked has registered the process.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		children.Lock()
+		registered := len(children.procs) > 0
+		children.Unlock()
+		if registered {
+			break
+		}
+	}
+	terminateChildren()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("terminated process reported success")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("child process still running after terminateChildren")
+	}
+}
+
+func TestLongPathIsIdentity(t *testing.T) {
+	for _, path := range []string{"a/b", "/tmp/x", ""} {
+		if got := longPath(path); got != path {
+			t.Errorf("longPath(%q) = %q", path, got)
+		}
+	}
+}
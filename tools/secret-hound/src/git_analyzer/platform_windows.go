@@ -0,0 +1,54 @@
+//go:build windows
+
+/**
+ * @file platform_windows.go
+ * @brief Windows implementations of the platform hooks in process.go.
+ *
+ * Windows has no SIGTERM: Ctrl-C and Ctrl-Break arrive as os.Interrupt, and
+ * the only way to stop another process is TerminateProcess (Process.Kill).
+ * Paths longer than MAX_PATH are only accepted by the file APIs in their
+ * extended-length "\\?\" form, which deep node_modules checkouts and long
+ * %TEMP% directories routinely need.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// terminationSignals are the signals that stop a scan gracefully.
+var terminationSignals = []os.Signal{os.Interrupt}
+
+// maxPath is the classic Win32 path limit, minus room for a file name.
+const maxPath = 248
+
+/**
+ * @brief Stops a child process; Windows cannot deliver a termination signal.
+ */
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+/**
+ * @brief Converts a long path to its extended-length form.
+ * Relative and short paths are returned unchanged (apart from separators).
+ * @param path A path with forward or backward slashes.
+ * @return The path, prefixed with \\?\ (or \\?\UNC\) if it exceeds MAX_PATH.
+ */
+func longPath(path string) string {
+	path = filepath.FromSlash(path)
+	if len(path) < maxPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,72 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLongPathAddsExtendedPrefix(t *testing.T) {
+	short := `C:\repo\file.txt`
+	if got := longPath(short); got != short {
+		t.Errorf("longPath(%q) = %q, want unchanged", short, got)
+	}
+
+	long := `C:\` + strings.Repeat(`node_modules\pkg\`, 20) + "index.js"
+	if got := longPath(long); got != `\\?\`+long {
+		t.Errorf("longPath(long) = %q, want \\\\?\\ prefix", got)
+	}
+
+	unc := `\\server\share\` + strings.Repeat("d", 260)
+	if got := longPath(unc); got != `\\?\UNC\server\share\`+strings.Repeat("d", 260) {
+		t.Errorf("longPath(unc) = %q, want \\\\?\\UNC\\ prefix", got)
+	}
+
+	slashed := "C:/" + strings.Repeat("a/", 130) + "f"
+	if got := longPath(slashed); strings.Contains(got, "/") {
+		t.Errorf("longPath(%q) kept forward slashes: %q", slashed, got)
+	}
+}
+
+func TestLongPathFilesAreReadable(t *testing.T) {
+	dir := t.TempDir()
+	deep := dir
+	for len(deep) < 300 {
+		deep = filepath.Join(deep, "nested-directory-level")
+	}
+	if err := os.MkdirAll(longPath(deep), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	path := filepath.Join(deep, "secret.env")
+	if err := os.WriteFile(longPath(path), []byte("TOKEN=x\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	content, err := readBlobContent(fileBlob{diskPath: path})
+	if err != nil || string(content) != "TOKEN=x\n" {
+		t.Errorf("readBlobContent = %q, %v", content, err)
+	}
+}
+
+func TestTerminateChildrenKillsRunningProcess(t *testing.T) {
+	cmd := exec.Command("ping", "-n", "30", "127.0.0.1")
+	done := make(chan error, 1)
+	go func() {
+		_, err := runTracked(cmd)
+		done <- err
+	}()
+	for deadline := time.Now().Add(5 * time.Second); cmd.Process == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond) // Let runTracked register the process
+	terminateChildren()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("child process still running after terminateChildren")
+	}
+}
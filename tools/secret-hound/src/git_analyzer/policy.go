@@ -0,0 +1,491 @@
+/**
+ * @file policy.go
+ * @brief Policy evaluation: suppress, re-rate or gate on findings with expressions.
+ *
+ * A policy file (--policy) lists rules, each with a CEL-style condition over
+ * the finding and an action:
+ *
+ *   {"policies": [
+ *     {"name": "tests are noise", "when": "path.startsWith(\"test/\")", "action": "suppress"},
+ *     {"name": "keys in prod", "when": "rule_id == \"AWS_ACCESS_KEY\" && path.contains(\"prod\")",
+ *      "action": "severity", "severity": "critical"},
+ *     {"name": "block live secrets", "when": "severity >= \"high\" && present_at_head", "action": "fail"}
+ *   ]}
+ *
+ * Rules are applied in order to every enriched finding; a suppressed finding
+ * is not written, and a finding matched by a "fail" rule makes the run exit
+ * with policyFailExitCode once the scan completes. Conditions use the common
+ * subset of CEL and Go expression syntax (&&, ||, !, comparisons, string
+ * literals, numbers, true/false, metadata["key"], and the startsWith,
+ * endsWith, contains and matches methods). Ordering comparisons between two
+ * severity or confidence levels compare by rank (low < medium < high <
+ * critical). Rego is not supported: it would need an OPA runtime.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// policyFailExitCode is the exit code of a run in which a "fail" policy matched.
+const policyFailExitCode = 3
+
+// Policy actions.
+const (
+	policySuppress = "suppress"
+	policySeverity = "severity"
+	policyFail     = "fail"
+)
+
+// severityRanks orders severity and confidence levels for comparisons.
+var severityRanks = map[string]int{"info": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+
+/**
+ * @struct policyRule
+ * @brief One rule of a policy file.
+ */
+type policyRule struct {
+	Name     string `json:"name"`
+	When     string `json:"when"`
+	Action   string `json:"action"`
+	Severity string `json:"severity"` // New severity for the "severity" action
+
+	expr ast.Expr
+}
+
+/**
+ * @struct policySet
+ * @brief The rules of a policy file and the outcome of applying them.
+ */
+type policySet struct {
+	Policies []policyRule `json:"policies"`
+
+	failures atomic.Int64 // Findings matched by a "fail" rule
+
+	head *headTree
+}
+
+/**
+ * @brief Loads and compiles a policy file.
+ * Unknown keys and a file without policies are errors: either would
+ * otherwise turn policy enforcement off without a word.
+ * @param path The JSON policy file.
+ * @return The policies and an error naming the first invalid rule.
+ */
+func loadPolicySet(path string) (*policySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &policySet{head: newHeadTree()}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(set); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	if len(set.Policies) == 0 {
+		return nil, fmt.Errorf("%s: no policies (want {\"policies\": [...]})", path)
+	}
+	for i := range set.Policies {
+		rule := &set.Policies[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		switch rule.Action {
+		case policySuppress, policyFail:
+		case policySeverity:
+			if _, ok := severityRanks[rule.Severity]; !ok {
+				return nil, fmt.Errorf("policy %s: unknown severity %q", rule.Name, rule.Severity)
+			}
+		default:
+			return nil, fmt.Errorf("policy %s: unknown action %q (expected suppress, severity or fail)", rule.Name, rule.Action)
+		}
+		if rule.expr, err = parser.ParseExpr(rule.When); err != nil {
+			return nil, fmt.Errorf("policy %s: %v", rule.Name, err)
+		}
+		// Evaluate once against an empty finding to catch unknown names early.
+		if _, err := evalPolicyExpr(rule.expr, &policyEnv{f: &finding{}}); err != nil {
+			return nil, fmt.Errorf("policy %s: %v", rule.Name, err)
+		}
+	}
+	return set, nil
+}
+
+/**
+ * @brief Adds a "fail" rule for findings of at least a severity (--fail-on).
+ * @param severity The lowest severity that fails the run.
+ * @return The policies (a new set if s is nil) and an error for an unknown severity.
+ */
+func (s *policySet) withFailOn(severity string) (*policySet, error) {
+	if _, ok := severityRanks[severity]; !ok {
+		return nil, fmt.Errorf("unknown severity %q (want info, low, medium, high or critical)", severity)
+	}
+	if s == nil {
+		s = &policySet{head: newHeadTree()}
+	}
+	rule := policyRule{Name: "--fail-on " + severity, When: fmt.Sprintf("severity >= %q", severity), Action: policyFail}
+	var err error
+	if rule.expr, err = parser.ParseExpr(rule.When); err != nil {
+		return nil, err
+	}
+	s.Policies = append(s.Policies, rule)
+	return s, nil
+}
+
+// suppresses reports whether any policy drops findings.
+func (s *policySet) suppresses() bool {
+	if s == nil {
+		return false
+	}
+	for _, rule := range s.Policies {
+		if rule.Action == policySuppress {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Applies the policies to a finding.
+ * @param f The enriched finding; its severity may be changed.
+ * @param blob The blob the finding came from.
+ * @return False if the finding is suppressed.
+ */
+func (s *policySet) apply(f *finding, blob fileBlob) bool {
+	if s == nil {
+		return true
+	}
+	env := &policyEnv{f: f, blob: blob, set: s}
+	for i := range s.Policies {
+		rule := &s.Policies[i]
+		result, err := evalPolicyExpr(rule.expr, env)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: policy %s: %v\n", rule.Name, err)
+			continue
+		}
+		if matched, _ := result.(bool); !matched {
+			continue
+		}
+		switch rule.Action {
+		case policySuppress:
+			return false
+		case policySeverity:
+			f.Severity = rule.Severity
+		case policyFail:
+			s.failures.Add(1)
+			setMetadata(f, "policy_failed", rule.Name)
+		}
+	}
+	return true
+}
+
+/**
+ * @brief Returns the severity of a finding, derived from its confidence if unset.
+ * Policies see the same severity whether or not the severity enricher ran.
+ */
+func findingSeverity(f *finding) string {
+	switch {
+	case f.Severity != "":
+		return strings.ToLower(f.Severity)
+	case f.Confidence != "":
+		return strings.ToLower(f.Confidence)
+	}
+	return "medium"
+}
+
+/**
+ * @brief Reports whether a blob is part of the tree at HEAD.
+ */
+func (s *policySet) presentAtHead(blob fileBlob) bool {
+	return s.head.contains(blob)
+}
+
+/**
+ * @struct headTree
+ * @brief The blobs of each repository's HEAD tree, listed once per repository.
+ */
+type headTree struct {
+	mu    sync.Mutex
+	blobs map[*repository]map[string]bool // Blob hashes in each repository's HEAD tree
+}
+
+// newHeadTree returns an empty cache.
+func newHeadTree() *headTree {
+	return &headTree{blobs: make(map[*repository]map[string]bool)}
+}
+
+// contains reports whether a blob is in its repository's HEAD tree; worktree content always is.
+func (h *headTree) contains(blob fileBlob) bool {
+	if blob.commit == worktreeCommit {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	blobs, ok := h.blobs[blob.repo]
+	if !ok {
+		blobs = make(map[string]bool)
+		output, _ := blob.repo.command("ls-tree", "-r", "-z", "HEAD").Output()
+		for _, entry := range strings.Split(string(output), "\x00") {
+			// "<mode> <type> <hash>\t<path>"
+			if fields := strings.Fields(strings.SplitN(entry, "\t", 2)[0]); len(fields) == 3 {
+				blobs[fields[2]] = true
+			}
+		}
+		h.blobs[blob.repo] = blobs
+	}
+	return blobs[blob.hash]
+}
+
+/**
+ * @struct policyEnv
+ * @brief The names a policy condition can refer to.
+ */
+type policyEnv struct {
+	f    *finding
+	blob fileBlob
+	set  *policySet // nil while validating
+}
+
+/**
+ * @brief Resolves an identifier in a policy condition.
+ */
+func (e *policyEnv) lookup(name string) (interface{}, error) {
+	f := e.f
+	switch name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "rule_id":
+		return f.RuleID, nil
+	case "description":
+		return f.Description, nil
+	case "match":
+		return f.Match, nil
+	case "path":
+		return f.OriginalPath, nil
+	case "commit":
+		return f.Commit, nil
+	case "repository":
+		return f.Repository, nil
+	case "line":
+		return float64(f.Line), nil
+	case "column":
+		return float64(f.Column), nil
+	case "entropy":
+		return f.Entropy, nil
+	case "confidence":
+		return strings.ToLower(f.Confidence), nil
+	case "severity":
+		return findingSeverity(f), nil
+	case "key_path":
+		return f.KeyPath, nil
+	case "metadata":
+		return f.Metadata, nil
+	case "worktree":
+		return f.Commit == worktreeCommit, nil
+	case "present_at_head":
+		return e.set != nil && e.set.presentAtHead(e.blob), nil
+	}
+	return nil, fmt.Errorf("unknown name %q", name)
+}
+
+/**
+ * @brief Evaluates a parsed policy condition.
+ * @param expr The expression.
+ * @param env The finding the expression is evaluated for.
+ * @return A bool, float64, string or map value, or an error for unsupported syntax or types.
+ */
+func evalPolicyExpr(expr ast.Expr, env *policyEnv) (interface{}, error) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return evalPolicyExpr(e.X, env)
+	case *ast.Ident:
+		return env.lookup(e.Name)
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.STRING:
+			return strconv.Unquote(e.Value)
+		case token.INT, token.FLOAT:
+			return strconv.ParseFloat(e.Value, 64)
+		}
+		return nil, fmt.Errorf("unsupported literal %s", e.Value)
+	case *ast.UnaryExpr:
+		if e.Op != token.NOT {
+			return nil, fmt.Errorf("unsupported operator %s", e.Op)
+		}
+		x, err := evalPolicyBool(e.X, env)
+		return !x, err
+	case *ast.IndexExpr:
+		x, err := evalPolicyExpr(e.X, env)
+		if err != nil {
+			return nil, err
+		}
+		key, err := evalPolicyExpr(e.Index, env)
+		if err != nil {
+			return nil, err
+		}
+		m, ok1 := x.(map[string]string)
+		k, ok2 := key.(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("only metadata[\"key\"] can be indexed")
+		}
+		return m[k], nil // A missing key reads as ""
+	case *ast.CallExpr:
+		return evalPolicyCall(e, env)
+	case *ast.BinaryExpr:
+		return evalPolicyBinary(e, env)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+func evalPolicyBool(expr ast.Expr, env *policyEnv) (bool, error) {
+	v, err := evalPolicyExpr(expr, env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean, got %T", v)
+	}
+	return b, nil
+}
+
+func evalPolicyBinary(e *ast.BinaryExpr, env *policyEnv) (interface{}, error) {
+	switch e.Op {
+	case token.LAND, token.LOR:
+		x, err := evalPolicyBool(e.X, env)
+		if err != nil || (e.Op == token.LAND && !x) || (e.Op == token.LOR && x) {
+			return x, err
+		}
+		return evalPolicyBool(e.Y, env)
+	}
+
+	x, err := evalPolicyExpr(e.X, env)
+	if err != nil {
+		return nil, err
+	}
+	y, err := evalPolicyExpr(e.Y, env)
+	if err != nil {
+		return nil, err
+	}
+	var cmp int
+	switch xv := x.(type) {
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %T", y)
+		}
+		xr, xok := severityRanks[xv]
+		yr, yok := severityRanks[yv]
+		switch {
+		case xok && yok && e.Op != token.EQL && e.Op != token.NEQ:
+			cmp = xr - yr
+		default:
+			cmp = strings.Compare(xv, yv)
+		}
+	case float64:
+		yv, ok := y.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare number with %T", y)
+		}
+		switch {
+		case xv < yv:
+			cmp = -1
+		case xv > yv:
+			cmp = 1
+		}
+	case bool:
+		yv, ok := y.(bool)
+		if !ok || (e.Op != token.EQL && e.Op != token.NEQ) {
+			return nil, fmt.Errorf("booleans only support == and !=")
+		}
+		if xv != yv {
+			cmp = 1
+		}
+	default:
+		return nil, fmt.Errorf("cannot compare %T", x)
+	}
+
+	switch e.Op {
+	case token.EQL:
+		return cmp == 0, nil
+	case token.NEQ:
+		return cmp != 0, nil
+	case token.LSS:
+		return cmp < 0, nil
+	case token.LEQ:
+		return cmp <= 0, nil
+	case token.GTR:
+		return cmp > 0, nil
+	case token.GEQ:
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %s", e.Op)
+}
+
+func evalPolicyCall(e *ast.CallExpr, env *policyEnv) (interface{}, error) {
+	sel, ok := e.Fun.(*ast.SelectorExpr)
+	if !ok || len(e.Args) != 1 {
+		return nil, fmt.Errorf("only string methods with one argument are supported")
+	}
+	recv, err := evalPolicyExpr(sel.X, env)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := evalPolicyExpr(e.Args[0], env)
+	if err != nil {
+		return nil, err
+	}
+	s, ok1 := recv.(string)
+	a, ok2 := arg.(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("%s expects string operands", sel.Sel.Name)
+	}
+	switch sel.Sel.Name {
+	case "startsWith":
+		return strings.HasPrefix(s, a), nil
+	case "endsWith":
+		return strings.HasSuffix(s, a), nil
+	case "contains":
+		return strings.Contains(s, a), nil
+	case "matches":
+		re, err := compilePolicyRegexp(a)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("unknown method %s", sel.Sel.Name)
+}
+
+var (
+	policyRegexpMu sync.Mutex
+	policyRegexps  = make(map[string]*regexp.Regexp)
+)
+
+func compilePolicyRegexp(pattern string) (*regexp.Regexp, error) {
+	policyRegexpMu.Lock()
+	defer policyRegexpMu.Unlock()
+	if re, ok := policyRegexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	policyRegexps[pattern] = re
+	return re, nil
+}
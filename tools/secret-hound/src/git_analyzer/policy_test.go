@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writePolicy writes a policy file and loads it.
+func writePolicy(t *testing.T, content string) (*policySet, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return loadPolicySet(path)
+}
+
+func TestLoadPolicySetRejectsInvalidRules(t *testing.T) {
+	for _, tc := range []struct {
+		rule, wantErr string
+	}{
+		{`{"when": "true", "action": "ignore"}`, "unknown action"},
+		{`{"when": "true", "action": "severity", "severity": "urgent"}`, "unknown severity"},
+		{`{"when": "path.startsWith(", "action": "suppress"}`, "policy #1"},
+		{`{"when": "filename == \"x\"", "action": "suppress"}`, `unknown name "filename"`},
+		{`{"when": "path.lower() == \"x\"", "action": "suppress"}`, "one argument"},
+	} {
+		if _, err := writePolicy(t, `{"policies": [`+tc.rule+`]}`); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error %v, want %q", tc.rule, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLoadPolicySetRejectsFilesWithoutPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		content, wantErr string
+	}{
+		{`{"rules": [{"when": "true", "action": "suppress"}]}`, `unknown field "rules"`},
+		{`{"policies": [{"when": "true", "acton": "suppress"}]}`, `unknown field "acton"`},
+		{`{"policies": []}`, "no policies"},
+		{`{}`, "no policies"},
+	} {
+		if _, err := writePolicy(t, tc.content); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error %v, want %q", tc.content, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPolicyConditions(t *testing.T) {
+	f := &finding{
+		RuleID: "AWS_ACCESS_KEY", OriginalPath: "deploy/prod/app.env", Commit: "c0ffee", Line: 12,
+		Entropy: 4.2, Confidence: "High", Severity: "high", Metadata: map[string]string{"cloud_account": "123456789012"},
+	}
+	for _, tc := range []struct {
+		when string
+		want bool
+	}{
+		{`rule_id == "AWS_ACCESS_KEY" && path.contains("prod")`, true},
+		{`path.startsWith("test/") || path.endsWith(".env")`, true},
+		{`!(line > 10)`, false},
+		{`entropy >= 4.2 && line == 12`, true},
+		{`severity >= "medium" && severity < "critical"`, true},
+		{`confidence == "high"`, true},
+		{`metadata["cloud_account"].matches("^[0-9]{12}$")`, true},
+		{`metadata["missing"] == ""`, true},
+		{`worktree == false`, true},
+		{`rule_id > "AWS"`, true}, // Plain strings compare lexically
+	} {
+		rule := `{"policies": [{"when": ` + strconv.Quote(tc.when) + `, "action": "suppress"}]}`
+		set, err := writePolicy(t, rule)
+		if err != nil {
+			t.Errorf("%s: %v", tc.when, err)
+			continue
+		}
+		if kept := set.apply(f, fileBlob{}); kept == tc.want {
+			t.Errorf("%s matched = %v, want %v", tc.when, !kept, tc.want)
+		}
+	}
+}
+
+func TestPolicyActionsApplyInOrder(t *testing.T) {
+	set, err := writePolicy(t, `{"policies": [
+		{"name": "fixtures", "when": "path.startsWith(\"test/\")", "action": "suppress"},
+		{"name": "prod keys", "when": "path.contains(\"prod\")", "action": "severity", "severity": "critical"},
+		{"name": "block criticals", "when": "severity == \"critical\"", "action": "fail"}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := &finding{OriginalPath: "test/prod.env", Severity: "high"}
+	if set.apply(fixture, fileBlob{}) {
+		t.Error("a finding in test/ was not suppressed")
+	}
+	prod := &finding{OriginalPath: "deploy/prod.env", Severity: "high"}
+	if !set.apply(prod, fileBlob{}) || prod.Severity != "critical" || prod.Metadata["policy_failed"] != "block criticals" {
+		t.Errorf("production finding: %+v", prod)
+	}
+	if set.failures.Load() != 1 {
+		t.Errorf("%d policy failures, want 1", set.failures.Load())
+	}
+	var none *policySet
+	if !none.apply(fixture, fileBlob{}) {
+		t.Error("findings are suppressed without a policy")
+	}
+}
+
+func TestPolicyPresentAtHead(t *testing.T) {
+	fx := newFixtureRepo(t)
+	fx.commit("add", map[string]string{"old.env": "TOKEN=1", "kept.env": "TOKEN=2"})
+	old := fx.git("rev-parse", "HEAD:old.env")
+	kept := fx.git("rev-parse", "HEAD:kept.env")
+	fx.git("rm", "-q", "old.env")
+	fx.commit("remove", nil)
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+
+	set, err := writePolicy(t, `{"policies": [{"when": "present_at_head", "action": "fail"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blob := range []fileBlob{
+		{hash: old, repo: repo},
+		{hash: kept, repo: repo},
+		{hash: "any", commit: worktreeCommit, repo: repo},
+	} {
+		set.apply(&finding{}, blob)
+	}
+	if set.failures.Load() != 2 {
+		t.Errorf("%d findings present at HEAD, want 2", set.failures.Load())
+	}
+}
+
+func TestPolicySeverityWithoutSeverityEnricher(t *testing.T) {
+	set, err := writePolicy(t, `{"policies": [{"when": "severity >= \"high\"", "action": "fail"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With --enrichers excluding severity, core findings only carry a confidence.
+	a := &analyzer{policy: set}
+	a.enrichers, _ = selectEnrichers("position", options{}, nil, nil)
+	for _, f := range []*finding{
+		{Confidence: "High", Match: "x"},
+		{Confidence: "Low", Match: "x"},
+		{Match: "x"}, // Neither: medium
+	} {
+		a.enrich(f, &enrichInput{content: []byte("x")})
+		a.policy.apply(f, fileBlob{})
+	}
+	if got := set.failures.Load(); got != 1 {
+		t.Errorf("%d findings rated high or above, want 1", got)
+	}
+}
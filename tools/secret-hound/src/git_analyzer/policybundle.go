@@ -0,0 +1,394 @@
+/**
+ * @file policybundle.go
+ * @brief Signed organization policy bundles (--policy-bundle).
+ *
+ * Rolling out a new rule to every CI runner and developer machine should not
+ * mean shipping a new binary. A security team instead publishes a policy
+ * bundle, a tar.gz holding any of:
+ *   rules.json      used as --rules
+ *   policy.json     used as --policy
+ *   allowlist.json  added as an --allowlist
+ * signed with an Ed25519 key, and every scan fetches it at startup:
+ *   --policy-bundle https://security.example.com/hound/bundle.tar.gz
+ *       the signature is fetched from the same URL plus ".sig";
+ *   --policy-bundle oci://registry.example.com/security/hound-policy:stable
+ *       an OCI artifact with a layer of type policyBundleMediaType and one of
+ *       type policySignatureMediaType, e.g. pushed with
+ *       `oras push <ref> bundle.tar.gz:<bundle type> bundle.tar.gz.sig:<signature type>`.
+ * The signature is the raw or base64 Ed25519 signature of the bundle file
+ * (`openssl pkeyutl -sign -rawin -inkey key.pem -in bundle.tar.gz`), checked
+ * against the PEM public key of --policy-bundle-key; a bundle is never used
+ * unverified. Verified bundles are cached in --policy-bundle-cache, so a
+ * runner that cannot reach the URL scans with the last good bundle and says
+ * so; unpacked files that no longer match the bundle are unpacked again. Files given with --rules or --policy take precedence over the bundle's.
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Media types of the layers of an OCI policy bundle artifact.
+const (
+	policyBundleMediaType    = "application/vnd.limearch.secret-hound.policy.v1.tar+gzip"
+	policySignatureMediaType = "application/vnd.limearch.secret-hound.policy.signature.v1"
+)
+
+// policyBundleHTTPTimeout bounds the download of a bundle and its signature.
+const policyBundleHTTPTimeout = 2 * time.Minute
+
+// policyBundleFiles maps the files a bundle may hold to the flag they stand in for.
+var policyBundleFiles = map[string]string{
+	"rules.json":     "--rules",
+	"policy.json":    "--policy",
+	"allowlist.json": "--allowlist",
+}
+
+/**
+ * @struct policyBundle
+ * @brief A verified bundle, unpacked in the cache.
+ */
+type policyBundle struct {
+	digest string            // sha256 of the bundle file
+	files  map[string]string // Unpacked path of each file of policyBundleFiles present
+}
+
+// defaultPolicyBundleCache is the --policy-bundle-cache default, next to the mirror cache.
+func defaultPolicyBundleCache() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "secret-hound", "policy")
+}
+
+/**
+ * @brief Fetches, verifies and unpacks a policy bundle, or falls back to the cached one.
+ * @param source An https:// or oci:// location.
+ * @param keyFile The PEM Ed25519 public key the bundle must be signed with.
+ * @param cacheDir Where verified bundles are kept.
+ * @return The bundle, and an error if neither the source nor the cache has a valid one.
+ */
+func loadPolicyBundle(source, keyFile, cacheDir string) (*policyBundle, error) {
+	key, err := readEd25519PublicKey(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("--policy-bundle-key: %v", err)
+	}
+	sum := sha256.Sum256([]byte(source))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(sum[:8]))
+
+	bundle, signature, fetchErr := fetchPolicyBundle(source)
+	if fetchErr == nil {
+		if !verifyBundleSignature(key, bundle, signature) {
+			return nil, fmt.Errorf("%s: the signature does not match --policy-bundle-key", source)
+		}
+		b, err := unpackPolicyBundle(bundle, signature, dir)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		// Point the cache at the new bundle for runs that cannot fetch it.
+		tmp := filepath.Join(dir, "current.tmp")
+		if err := ioutil.WriteFile(tmp, []byte(b.digest), 0o644); err == nil {
+			err = os.Rename(tmp, filepath.Join(dir, "current"))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: --policy-bundle-cache: %v\n", err)
+		}
+		return b, nil
+	}
+
+	// The last verified bundle, checked again since the cache is only a directory.
+	current, err := ioutil.ReadFile(filepath.Join(dir, "current"))
+	if digest := strings.TrimSpace(string(current)); err != nil || len(digest) < 16 {
+		return nil, fetchErr
+	}
+	digestDir := filepath.Join(dir, strings.TrimSpace(string(current))[:16])
+	bundle, err = ioutil.ReadFile(filepath.Join(digestDir, "bundle.tar.gz"))
+	if err == nil {
+		signature, err = ioutil.ReadFile(filepath.Join(digestDir, "bundle.tar.gz.sig"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v; cached bundle: %v", fetchErr, err)
+	}
+	if !verifyBundleSignature(key, bundle, signature) {
+		return nil, fmt.Errorf("%v; the cached bundle does not match --policy-bundle-key", fetchErr)
+	}
+	b, err := unpackPolicyBundle(bundle, signature, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%v; cached bundle: %v", fetchErr, err)
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: --policy-bundle: %v; using the cached bundle %.12s\n", fetchErr, b.digest)
+	return b, nil
+}
+
+/**
+ * @brief Applies a bundle to the options: its rules, policy and allowlist.
+ * Files given on the command line are kept.
+ */
+func (b *policyBundle) apply(opts *options) {
+	if path := b.files["rules.json"]; path != "" {
+		if opts.rulesPath == "" {
+			opts.rulesPath = path
+		} else {
+			fmt.Fprintf(os.Stderr, "Go analyzer: --rules %s takes precedence over the policy bundle's rules\n", opts.rulesPath)
+		}
+	}
+	if path := b.files["policy.json"]; path != "" {
+		if opts.policyPath == "" {
+			opts.policyPath = path
+		} else {
+			fmt.Fprintf(os.Stderr, "Go analyzer: --policy %s takes precedence over the policy bundle's policy\n", opts.policyPath)
+		}
+	}
+	if path := b.files["allowlist.json"]; path != "" {
+		opts.allowlists = append(opts.allowlists, path)
+	}
+}
+
+/**
+ * @brief Downloads a bundle and its signature.
+ * @return The bundle file, its signature, and an error.
+ */
+func fetchPolicyBundle(source string) ([]byte, []byte, error) {
+	switch {
+	case strings.HasPrefix(source, "oci://"):
+		return fetchOCIPolicyBundle(strings.TrimPrefix(source, "oci://"))
+	case strings.HasPrefix(source, "https://"):
+		client := &http.Client{Timeout: policyBundleHTTPTimeout}
+		bundle, err := httpGetBody(client, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		signature, err := httpGetBody(client, source+".sig")
+		if err != nil {
+			return nil, nil, err
+		}
+		return bundle, signature, nil
+	}
+	return nil, nil, fmt.Errorf("%s: want an https:// or oci:// location", source)
+}
+
+// httpGetBody downloads a URL, failing on any status but 200.
+func httpGetBody(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+/**
+ * @brief Pulls the bundle and signature layers of an OCI artifact.
+ * @param ref The artifact reference without oci://, e.g. "ghcr.io/org/policy:stable".
+ */
+func fetchOCIPolicyBundle(ref string) ([]byte, []byte, error) {
+	c, reference := newRegistryClient(ref, policyBundleHTTPTimeout)
+
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	if err := c.getJSON("/manifests/"+reference, "application/vnd.oci.image.manifest.v1+json", &manifest); err != nil {
+		return nil, nil, err
+	}
+	var bundle, signature []byte
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != policyBundleMediaType && layer.MediaType != policySignatureMediaType {
+			continue
+		}
+		resp, err := c.get("/blobs/"+layer.Digest, "*/*")
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if sum := sha256.Sum256(data); layer.Digest != "sha256:"+hex.EncodeToString(sum[:]) {
+			return nil, nil, fmt.Errorf("%s: layer %s does not match its digest", ref, layer.Digest)
+		}
+		if layer.MediaType == policyBundleMediaType {
+			bundle = data
+		} else {
+			signature = data
+		}
+	}
+	if bundle == nil || signature == nil {
+		return nil, nil, fmt.Errorf("%s: no %s and %s layers", ref, policyBundleMediaType, policySignatureMediaType)
+	}
+	return bundle, signature, nil
+}
+
+/**
+ * @brief Reads a PEM-encoded Ed25519 public key (openssl pkey -pubout).
+ */
+func readEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("a public key is required to verify the policy bundle")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM public key", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// verifyBundleSignature checks a raw or base64 Ed25519 signature of a bundle.
+func verifyBundleSignature(key ed25519.PublicKey, bundle, signature []byte) bool {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return false
+		}
+		signature = decoded
+	}
+	return len(signature) == ed25519.SignatureSize && ed25519.Verify(key, bundle, signature)
+}
+
+/**
+ * @brief Unpacks the files of a verified bundle into a directory named after its digest.
+ * The bundle and its signature are kept next to them. The signature covers
+ * only the archive, so an already unpacked digest is reused only if every
+ * file matches the archive; otherwise it is unpacked again. The directory is
+ * renamed into place whole, so concurrent scans never see a partial bundle.
+ * @param bundle The bundle file (tar.gz).
+ * @param signature Its signature.
+ * @param cacheDir The cache directory of the bundle's source.
+ */
+func unpackPolicyBundle(bundle, signature []byte, cacheDir string) (*policyBundle, error) {
+	sum := sha256.Sum256(bundle)
+	b := &policyBundle{digest: hex.EncodeToString(sum[:]), files: make(map[string]string)}
+	files, err := extractPolicyFiles(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("the bundle holds none of rules.json, policy.json and allowlist.json")
+	}
+	dir := filepath.Join(cacheDir, b.digest[:16])
+	if !unpackedPolicyMatches(dir, files) {
+		if err := replaceUnpackedPolicy(dir, files, bundle, signature); err != nil {
+			return nil, err
+		}
+	}
+	for name := range files {
+		b.files[name] = filepath.Join(dir, name)
+	}
+	return b, nil
+}
+
+// unpackedPolicyMatches reports whether a directory holds exactly the policy files of a bundle.
+func unpackedPolicyMatches(dir string, files map[string][]byte) bool {
+	for name := range policyBundleFiles {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		want, ok := files[name]
+		if ok && (err != nil || !bytes.Equal(content, want)) || !ok && !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @brief Writes the files of a bundle to a fresh directory and renames it
+ * over the unpacked one, which is moved aside and removed.
+ */
+func replaceUnpackedPolicy(dir string, files map[string][]byte, bundle, signature []byte) error {
+	cacheDir := filepath.Dir(dir)
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(cacheDir, ".unpack-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	files["bundle.tar.gz"] = bundle
+	files["bundle.tar.gz.sig"] = signature
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmp, name), content, 0o644); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		stale, err := ioutil.TempDir(cacheDir, ".stale-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(stale)
+		if err := os.Rename(dir, filepath.Join(stale, "unpacked")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		delete(files, "bundle.tar.gz")
+		delete(files, "bundle.tar.gz.sig")
+		if !unpackedPolicyMatches(dir, files) {
+			return err // Not another process winning the race
+		}
+	}
+	return nil
+}
+
+// extractPolicyFiles reads the known files at the top of a tar.gz bundle.
+func extractPolicyFiles(bundle []byte) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(bundle))
+	if err != nil {
+		return nil, fmt.Errorf("not a tar.gz bundle: %v", err)
+	}
+	archive := tar.NewReader(gz)
+	files := make(map[string][]byte)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		if header.Typeflag != tar.TypeReg || policyBundleFiles[name] == "" {
+			continue
+		}
+		if files[name], err = io.ReadAll(archive); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyBundleVerifiesAndFallsBackToTheCache(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "bundle.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{"policy.json": `{"policies": [{"when": "present_at_head", "action": "fail"}]}`, "README": "ignored"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	bundle := archive.Bytes()
+	signature := ed25519.Sign(private, bundle)
+
+	served := map[string][]byte{"/bundle.tar.gz": bundle, "/bundle.tar.gz.sig": signature}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if data, ok := served[r.URL.Path]; ok {
+			w.Write(data)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	transport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = transport }()
+
+	source := server.URL + "/bundle.tar.gz"
+	cache := t.TempDir()
+	b, err := loadPolicyBundle(source, keyFile, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var opts options
+	b.apply(&opts)
+	if content, err := os.ReadFile(opts.policyPath); err != nil || !strings.Contains(string(content), "policies") {
+		t.Errorf("--policy from the bundle = %q (%v)", opts.policyPath, err)
+	}
+	if opts.rulesPath != "" || len(opts.allowlists) != 0 {
+		t.Errorf("files the bundle does not hold were applied: %q %q", opts.rulesPath, opts.allowlists)
+	}
+
+	// Whoever can write the cache cannot change what a verified bundle applies.
+	tamper := func() {
+		t.Helper()
+		if err := os.WriteFile(opts.policyPath, []byte(`{"policies": [{"when": "always", "action": "pass"}]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	untampered := func(b *policyBundle) {
+		t.Helper()
+		if content, err := os.ReadFile(b.files["policy.json"]); err != nil || !strings.Contains(string(content), "present_at_head") {
+			t.Errorf("a tampered unpacked policy was used: %q (%v)", content, err)
+		}
+	}
+	tamper()
+	again, err := loadPolicyBundle(source, keyFile, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untampered(again)
+
+	served["/bundle.tar.gz.sig"] = ed25519.Sign(private, []byte("another bundle"))
+	if _, err := loadPolicyBundle(source, keyFile, cache); err == nil {
+		t.Error("bundle with a wrong signature accepted")
+	}
+
+	server.Close() // Unreachable: the last verified bundle is used
+	cached, err := loadPolicyBundle(source, keyFile, cache)
+	if err != nil {
+		t.Fatalf("no fallback to the cache: %v", err)
+	}
+	if cached.digest != b.digest {
+		t.Errorf("cached digest %s, want %s", cached.digest, b.digest)
+	}
+	tamper()
+	if cached, err = loadPolicyBundle(source, keyFile, cache); err != nil {
+		t.Fatal(err)
+	}
+	untampered(cached)
+	if _, err := loadPolicyBundle(source, keyFile, t.TempDir()); err == nil {
+		t.Error("unreachable bundle without a cache accepted")
+	}
+}
@@ -0,0 +1,79 @@
+/**
+ * @file position.go
+ * @brief Validates and corrects the reported position of a finding.
+ *
+ * The core scanner counts lines on the bytes it is given; depending on how it
+ * treats a UTF-8 byte order mark or CRLF line endings, the reported line can
+ * drift from what editors, GitHub checks and SARIF viewers display. Every
+ * finding is therefore re-checked against the blob: if the match is not on
+ * the reported line, the closest occurrence is used instead and the original
+ * line is kept in the "reported_line" metadata. The 1-based column of the
+ * match, counted in Unicode code points after any BOM, is filled in as well.
+ */
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const utf8BOM = "\uFEFF"
+
+/**
+ * @brief Checks a finding's line against the content and sets its column.
+ * Findings inside decoded payloads point at the encoded run and are left alone.
+ * @param f The finding to validate.
+ * @param content The content of the blob the finding was found in.
+ */
+func validatePosition(f *finding, content []byte) {
+	if f.Match == "" || f.Metadata["encoding"] != "" {
+		return
+	}
+	lines := strings.Split(strings.TrimPrefix(string(content), utf8BOM), "\n")
+	// Multi-line matches are located by their first line.
+	needle := strings.TrimRight(strings.SplitN(f.Match, "\n", 2)[0], "\r")
+	if needle == "" {
+		return
+	}
+
+	column := func(number int) int {
+		i := strings.Index(strings.TrimRight(lines[number-1], "\r"), needle)
+		if i < 0 {
+			return 0
+		}
+		return utf8.RuneCountInString(lines[number-1][:i]) + 1
+	}
+
+	if f.Line >= 1 && f.Line <= len(lines) {
+		if col := column(f.Line); col > 0 {
+			f.Column = col
+			return
+		}
+	}
+
+	// Pick the occurrence closest to the reported line.
+	best := 0
+	for number := 1; number <= len(lines); number++ {
+		if column(number) > 0 && (best == 0 || absInt(number-f.Line) < absInt(best-f.Line)) {
+			best = number
+		}
+	}
+	if best == 0 {
+		return // Not found verbatim (e.g. a normalized match); keep what the scanner said
+	}
+	setMetadata(f, "reported_line", strconv.Itoa(f.Line))
+	f.Line = best
+	f.Column = column(best)
+}
+
+/**
+ * @brief Returns the absolute value of n.
+ */
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestValidatePosition(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		content      string
+		f            finding
+		line, column int
+		reportedLine string
+	}{
+		{"correct line", "a\nkey = AKIA1234\n", finding{Line: 2, Match: "AKIA1234"}, 2, 7, ""},
+		{"code points after a BOM", utf8BOM + "naïve = AKIA1234\n", finding{Line: 1, Match: "AKIA1234"}, 1, 9, ""},
+		{"CRLF line drift", "a\r\n\r\nb\r\ntoken AKIA1234\r\n", finding{Line: 3, Match: "AKIA1234"}, 4, 7, "3"},
+		{"closest occurrence", "AKIA1234\nx\nx\nx\nx\nx\n  AKIA1234\n", finding{Line: 6, Match: "AKIA1234"}, 7, 3, "6"},
+		{"multi-line match", "\n\n-----BEGIN KEY-----\nbody\n", finding{Line: 1, Match: "-----BEGIN KEY-----\nbody"}, 3, 1, "1"},
+		{"not found verbatim", "a\nb\n", finding{Line: 2, Match: "normalized"}, 2, 0, ""},
+		{"decoded payload", "x\nQUtJQTEyMzQ=\n", finding{Line: 2, Match: "AKIA1234", Metadata: map[string]string{"encoding": "base64"}}, 2, 0, ""},
+	} {
+		f := tc.f
+		validatePosition(&f, []byte(tc.content))
+		if f.Line != tc.line || f.Column != tc.column || f.Metadata["reported_line"] != tc.reportedLine {
+			t.Errorf("%s: line %d column %d reported_line %q, want %d, %d, %q",
+				tc.name, f.Line, f.Column, f.Metadata["reported_line"], tc.line, tc.column, tc.reportedLine)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/**
+ * @file prefilter.go
+ * @brief Cheap in-process check that spares the core scanner hopeless blobs (--prefilter).
+ *
+ * Every blob scanned with the core costs a process start, and usually a
+ * temporary file, even though on code-heavy repositories most blobs cannot
+ * match a single rule. The prefilter runs the rules in Go first, and blobs
+ * that cannot match are not sent to the core (the native detectors and the
+ * unwrapping of encoded payloads still see them):
+ *   off         every blob goes to the core (the default);
+ *   safe        a blob is skipped only if no rule can match anywhere in it:
+ *               each rule's pattern and lookaheads must all occur, and rules
+ *               with a min_entropy need a line with a match that random. It
+ *               skips nothing the core would report, as long as the core
+ *               applies only the rules of the rules file;
+ *   aggressive  only a sample of large blobs is checked, its first and last
+ *               --prefilter-sample bytes, and min_entropy rules need a match
+ *               prefilterEntropyMargin bits more random than the rule asks
+ *               (the entropy sketch). Secrets deep inside large files or
+ *               barely above a rule's threshold can be missed.
+ * The prefilter only applies to --engine core. If a rule cannot be compiled
+ * in Go, it would have to be ignored, so the prefilter is turned off instead.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sync/atomic"
+)
+
+// Prefilter levels of --prefilter.
+const (
+	prefilterOff        = "off"
+	prefilterSafe       = "safe"
+	prefilterAggressive = "aggressive"
+)
+
+// prefilterEntropyMargin is how much more random than its min_entropy an aggressive prefilter wants a match.
+const prefilterEntropyMargin = 0.5
+
+/**
+ * @struct prefilterRule
+ * @brief A rule compiled to be checked against whole blobs.
+ */
+type prefilterRule struct {
+	pattern    *regexp.Regexp   // Multi-line, so ^ and $ still match at line boundaries
+	lookahead  []*regexp.Regexp // Each must occur somewhere
+	line       engineRule       // For min_entropy rules, which are checked line by line
+	minEntropy float64
+}
+
+/**
+ * @struct corePrefilter
+ * @brief Decides which blobs are worth a core scanner invocation.
+ */
+type corePrefilter struct {
+	rules   []prefilterRule
+	sample  int     // Bytes checked at each end of a blob (0 = all of it)
+	margin  float64 // Added to every min_entropy
+	checked atomic.Int64
+	skipped atomic.Int64
+}
+
+/**
+ * @brief Compiles the prefilter of a --prefilter level.
+ * @param level off, safe or aggressive.
+ * @param sample The --prefilter-sample size, used by aggressive.
+ * @param set The rules the core applies.
+ * @return The prefilter (nil when off or not every rule can be checked), and an error for an unknown level.
+ */
+func newCorePrefilter(level string, sample int, set *ruleSet) (*corePrefilter, error) {
+	p := &corePrefilter{}
+	switch level {
+	case "", prefilterOff:
+		return nil, nil
+	case prefilterSafe:
+	case prefilterAggressive:
+		p.sample, p.margin = sample, prefilterEntropyMargin
+	default:
+		return nil, fmt.Errorf("unknown level %q (want off, safe or aggressive)", level)
+	}
+	if set == nil {
+		return nil, nil
+	}
+	for _, def := range set.Rules {
+		rule, err := compileEngineRule(def)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: --prefilter: rule %s cannot be checked in Go (%v); every blob goes to the core\n", def.ID, err)
+			return nil, nil
+		}
+		whole := prefilterRule{lookahead: rule.lookahead, line: rule, minEntropy: def.MinEntropy}
+		if whole.pattern, err = regexp.Compile("(?m)" + rule.pattern.String()); err != nil {
+			return nil, err
+		}
+		p.rules = append(p.rules, whole)
+	}
+	return p, nil
+}
+
+/**
+ * @brief Reports whether the core should scan some content.
+ * @param content The content about to be sent to the core.
+ * @return false if no rule can match it; always true for a nil prefilter.
+ */
+func (p *corePrefilter) admit(content []byte) bool {
+	if p == nil {
+		return true
+	}
+	p.checked.Add(1)
+	sample := content
+	if p.sample > 0 && len(content) > 2*p.sample {
+		sample = make([]byte, 0, 2*p.sample+1)
+		sample = append(append(append(sample, content[:p.sample]...), '\n'), content[len(content)-p.sample:]...)
+	}
+	for i := range p.rules {
+		if p.rules[i].matches(sample, p.margin) {
+			return true
+		}
+	}
+	p.skipped.Add(1)
+	return false
+}
+
+// matches reports whether a rule may match the content.
+func (r *prefilterRule) matches(content []byte, margin float64) bool {
+	if !r.pattern.Match(content) {
+		return false
+	}
+	for _, re := range r.lookahead {
+		if !re.Match(content) {
+			return false
+		}
+	}
+	if r.minEntropy <= 0 {
+		return true
+	}
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		for _, match := range r.line.lineMatches(string(line)) {
+			if shannonEntropy(match) >= r.minEntropy+margin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Summarizes what the prefilter spared, for the end of the run.
+ * @return "" if nothing was checked.
+ */
+func (p *corePrefilter) summary() string {
+	if p == nil || p.checked.Load() == 0 {
+		return ""
+	}
+	checked, skipped := p.checked.Load(), p.skipped.Load()
+	return fmt.Sprintf("prefilter spared the core %d of %d blob(s) (%.0f%%)", skipped, checked, 100*float64(skipped)/float64(checked))
+}
@@ -0,0 +1,234 @@
+/**
+ * @file pretty.go
+ * @brief Human-readable console output (--output-format pretty).
+ *
+ * JSON Lines is what pipelines consume, but a developer running the tool on
+ * their own checkout has to pipe it through jq to read it. The "pretty"
+ * format prints findings grouped by commit and then by file, one line per
+ * finding with its position, severity, rule and the secret masked to its
+ * first characters, followed by a summary by severity. Grouping needs every
+ * finding, so the report is printed when the scan ends rather than as
+ * findings arrive. Its text comes from the message catalog (see i18n.go).
+ *
+ * Severities are colored when the output is a terminal. NO_COLOR (see
+ * no-color.org) or TERM=dumb turns colors off, and they are never written
+ * to an --output file.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ANSI escape sequences of the pretty format.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+	ansiBgRed  = "\x1b[41;97m"
+)
+
+// severityColors are the colors of each severity in the pretty format.
+var severityColors = map[string]string{
+	"critical": ansiBgRed + ansiBold,
+	"high":     ansiRed + ansiBold,
+	"medium":   ansiYellow,
+	"low":      ansiBlue,
+	"info":     ansiDim,
+}
+
+/**
+ * @struct prettyReport
+ * @brief Findings collected for the pretty format until the end of the scan.
+ */
+type prettyReport struct {
+	mu       sync.Mutex
+	color    bool
+	findings []*finding
+}
+
+/**
+ * @brief Creates a report for a destination, with colors if it is a terminal.
+ */
+func newPrettyReport(w io.Writer) *prettyReport {
+	return &prettyReport{color: w == os.Stdout && colorTerminal(os.Stdout)}
+}
+
+// colorTerminal reports whether ANSI colors should be written to a file.
+func colorTerminal(file *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+/**
+ * @brief Keeps a finding for the report.
+ * The finding is copied, since enrichers of other sinks may still change it.
+ */
+func (r *prettyReport) add(f *finding) {
+	clone := *f
+	r.mu.Lock()
+	r.findings = append(r.findings, &clone)
+	r.mu.Unlock()
+}
+
+// paint wraps text in an escape sequence if colors are on.
+func (r *prettyReport) paint(code, text string) string {
+	if !r.color || code == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+/**
+ * @struct commitGroup
+ * @brief The findings of one commit, by file.
+ */
+type commitGroup struct {
+	repository, commit string
+	paths              []string // Sorted
+	files              map[string][]*finding
+}
+
+/**
+ * @brief Groups the findings by repository and commit, then by file.
+ * Commits keep the order their first finding arrived in (newest first for a
+ * single worker); files and findings are sorted by path and position. r.mu
+ * must be held.
+ * @return The commits, the number of findings by severity and the number of files.
+ */
+func (r *prettyReport) group() ([]*commitGroup, map[string]int, int) {
+	var commits []*commitGroup
+	index := make(map[string]*commitGroup)
+	counts := make(map[string]int)
+	files := make(map[string]bool)
+	for _, f := range r.findings {
+		key := f.Repository + "\x00" + f.Commit
+		group := index[key]
+		if group == nil {
+			group = &commitGroup{repository: f.Repository, commit: f.Commit, files: make(map[string][]*finding)}
+			index[key] = group
+			commits = append(commits, group)
+		}
+		group.files[f.OriginalPath] = append(group.files[f.OriginalPath], f)
+		counts[findingSeverity(f)]++
+		files[f.Repository+"\x00"+f.OriginalPath] = true
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].repository < commits[j].repository })
+	for _, group := range commits {
+		for path, findings := range group.files {
+			group.paths = append(group.paths, path)
+			sort.SliceStable(findings, func(i, j int) bool {
+				if findings[i].Line != findings[j].Line {
+					return findings[i].Line < findings[j].Line
+				}
+				return findings[i].Column < findings[j].Column
+			})
+		}
+		sort.Strings(group.paths)
+	}
+	return commits, counts, len(files)
+}
+
+/**
+ * @brief Writes the findings grouped by repository and commit, then by file.
+ */
+func (r *prettyReport) render(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.findings) == 0 {
+		_, err := fmt.Fprintln(w, r.paint(ansiBold, messages.text("pretty.no_findings")))
+		return err
+	}
+
+	commits, counts, files := r.group()
+	var out strings.Builder
+	for _, group := range commits {
+		header := messages.text("pretty.commit", "commit", shortCommit(group.commit))
+		if group.repository != "" {
+			header = group.repository + " " + header
+		}
+		out.WriteString(r.paint(ansiBold+ansiYellow, header))
+		if author := group.files[group.paths[0]][0].Metadata["author"]; author != "" {
+			out.WriteString(r.paint(ansiDim, "  "+author))
+		}
+		out.WriteString("\n")
+
+		for _, path := range group.paths {
+			fmt.Fprintf(&out, "  %s\n", r.paint(ansiCyan, path))
+			for _, f := range group.files[path] {
+				r.renderFinding(&out, f)
+			}
+		}
+		out.WriteString("\n")
+	}
+	r.renderSummary(&out, counts, files, len(commits))
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// renderFinding writes the line of one finding, and its context if any.
+func (r *prettyReport) renderFinding(out *strings.Builder, f *finding) {
+	severity := findingSeverity(f)
+	position := fmt.Sprintf("%d", f.Line)
+	if f.Column > 0 {
+		position = fmt.Sprintf("%d:%d", f.Line, f.Column)
+	}
+	label := fmt.Sprintf("%-8s", strings.ToUpper(messages.text("severity."+severity)))
+	fmt.Fprintf(out, "    %7s  %s  %s  %s", position, r.paint(severityColors[severity], label), f.RuleID, maskSecret(f.Match))
+	if f.KeyPath != "" {
+		fmt.Fprintf(out, "  %s", r.paint(ansiDim, "("+f.KeyPath+")"))
+	}
+	if reason := f.Metadata["allowlisted"]; reason != "" {
+		fmt.Fprintf(out, "  %s", r.paint(ansiDim, messages.text("pretty.allowlisted", "reason", reason)))
+	}
+	if len(f.Occurrences) > 1 {
+		fmt.Fprintf(out, "  %s", r.paint(ansiDim, messages.text("pretty.more_occurrences", "count", len(f.Occurrences)-1)))
+	}
+	out.WriteString("\n")
+	for _, line := range f.Context {
+		fmt.Fprintf(out, "    %7d  %s\n", line.Line, r.paint(ansiDim, "| "+line.Text))
+	}
+}
+
+// renderSummary writes the totals by severity, most severe first.
+func (r *prettyReport) renderSummary(out *strings.Builder, counts map[string]int, files, commits int) {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	out.WriteString(messages.text("pretty.summary",
+		"findings", r.paint(ansiBold, messages.text("pretty.summary.findings", "count", total)),
+		"files", messages.text("pretty.summary.files", "count", files),
+		"commits", messages.text("pretty.summary.commits", "count", commits)))
+	var parts []string
+	for _, severity := range []string{"critical", "high", "medium", "low", "info"} {
+		if counts[severity] > 0 {
+			parts = append(parts, r.paint(severityColors[severity], fmt.Sprintf("%d %s", counts[severity], messages.text("severity."+severity))))
+		}
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(out, ": %s", strings.Join(parts, ", "))
+	}
+	out.WriteString("\n")
+}
+
+// shortCommit abbreviates a commit hash; other commit labels (WORKTREE, layer digests) are kept.
+func shortCommit(commit string) string {
+	if len(commit) == 40 && strings.Trim(commit, "0123456789abcdef") == "" {
+		return commit[:12]
+	}
+	return commit
+}
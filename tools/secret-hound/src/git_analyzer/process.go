@@ -0,0 +1,59 @@
+/**
+ * @file process.go
+ * @brief Tracks child processes so they are stopped when the analyzer is.
+ *
+ * On Unix, Ctrl-C reaches the whole foreground process group, but a SIGTERM
+ * from a CI runner or `kill` only reaches the analyzer; on Windows a killed
+ * console process leaves its children running. Core scanner processes are
+ * therefore registered while they run and terminated explicitly (see
+ * terminateProcess in platform_*.go) before the analyzer exits on a signal.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// children holds the running child processes, keyed by PID.
+var children = struct {
+	sync.Mutex
+	procs map[int]*os.Process
+}{procs: make(map[int]*os.Process)}
+
+/**
+ * @brief Runs a command like exec.Cmd.Output, registering it while it runs.
+ * @param cmd The prepared command; its Stdout must not be set.
+ * @return The standard output and the error from Wait.
+ */
+func runTracked(cmd *exec.Cmd) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	children.Lock()
+	children.procs[cmd.Process.Pid] = cmd.Process
+	children.Unlock()
+
+	err := cmd.Wait()
+
+	children.Lock()
+	delete(children.procs, cmd.Process.Pid)
+	children.Unlock()
+	return stdout.Bytes(), err
+}
+
+/**
+ * @brief Terminates every registered child process.
+ */
+func terminateChildren() {
+	children.Lock()
+	defer children.Unlock()
+	for _, p := range children.procs {
+		terminateProcess(p)
+	}
+}
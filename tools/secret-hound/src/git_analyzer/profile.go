@@ -0,0 +1,139 @@
+/**
+ * @file profile.go
+ * @brief Presets of flags for common workflows (--profile).
+ *
+ * The analyzer has grown a flag for every situation, and the three most
+ * common setups each need half a dozen of them. --profile sets them at once:
+ *   - pre-commit: only the changes staged for commit, with the native engine
+ *     (no core process per blob), no base64/hex decoding, generated files
+ *     skipped, the cheap enrichers only, secrets masked in the output, and a
+ *     failing exit code for high or critical findings;
+ *   - ci: only the commits of the branch, base..HEAD, with the base taken from
+ *     the CI system's environment (--base auto), failing on high or critical
+ *     findings;
+ *   - deep-audit: every branch and tag, commits only left in reflogs,
+ *     dangling commits, Git LFS content, every parent of every merge, and
+ *     generated files scanned like any other.
+ * A profile only changes defaults: SECRET_HOUND_* variables and flags on the
+ * command line still override every value it sets.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+/**
+ * @struct profileFlag
+ * @brief One flag value set by a profile.
+ */
+type profileFlag struct {
+	name  string
+	value string
+}
+
+// scanProfiles are the presets selectable with --profile.
+var scanProfiles = map[string][]profileFlag{
+	"pre-commit": {
+		{"staged", "true"},
+		{"engine", "native"},
+		{"decode-min-length", "0"},
+		{"generated", generatedSkip},
+		{"enrichers", "position,severity,allowlist"},
+		{"redact", "true"},
+		{"fail-on", "high"},
+	},
+	"ci": {
+		{"base", baseAuto},
+		{"fail-on", "high"},
+	},
+	"deep-audit": {
+		{"all-refs", "true"},
+		{"reflog", "true"},
+		{"unreachable", "true"},
+		{"lfs", "true"},
+		{"include-merge-diffs", "true"},
+		{"generated", generatedScan},
+	},
+}
+
+// profileNames returns the names of the presets, sorted.
+func profileNames() []string {
+	names := make([]string, 0, len(scanProfiles))
+	for name := range scanProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**
+ * @brief Applies the preset named by --profile (or SECRET_HOUND_PROFILE) to the flags.
+ * Must run before the environment and the command line are applied, so both override it.
+ * @param fs The flag set, with a "profile" flag defined.
+ * @param args The command-line arguments without the program name.
+ * @return An error for an unknown profile.
+ */
+func applyProfile(fs *flag.FlagSet, args []string) error {
+	name := os.Getenv(flagEnvName("profile"))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if value, ok := cutFlag(arg, "profile"); ok {
+			name = value
+		} else if (arg == "--profile" || arg == "-profile") && i+1 < len(args) {
+			name = args[i+1]
+			i++
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	preset, ok := scanProfiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (want %s)", name, strings.Join(profileNames(), ", "))
+	}
+	for _, p := range preset {
+		if err := fs.Set(p.name, p.value); err != nil {
+			return fmt.Errorf("profile %s: --%s: %v", name, p.name, err)
+		}
+	}
+	return nil
+}
+
+// cutFlag returns the value of an argument of the form --name=value or -name=value.
+func cutFlag(arg, name string) (string, bool) {
+	for _, prefix := range []string{"--" + name + "=", "-" + name + "="} {
+		if strings.HasPrefix(arg, prefix) {
+			return arg[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+// baseAuto is the --base value that takes the base ref from the CI environment.
+const baseAuto = "auto"
+
+/**
+ * @brief Finds the target branch of the merge or pull request being built.
+ * Covers GitHub Actions, GitLab CI, Bitbucket Pipelines and Azure Pipelines.
+ * @return The base ref, or "" outside a merge or pull request build.
+ */
+func ciBaseRef() string {
+	if sha := os.Getenv("CI_MERGE_REQUEST_DIFF_BASE_SHA"); sha != "" {
+		return sha // GitLab
+	}
+	for _, name := range []string{"GITHUB_BASE_REF", "BITBUCKET_PR_DESTINATION_BRANCH", "SYSTEM_PULLREQUEST_TARGETBRANCH"} {
+		if branch := os.Getenv(name); branch != "" {
+			return "origin/" + strings.TrimPrefix(branch, "refs/heads/")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,247 @@
+/**
+ * @file proto.go
+ * @brief A minimal protobuf wire-format codec driven by `proto:"N"` struct tags.
+ *
+ * Only the scalar types used by the finding record are supported: strings,
+ * booleans, integers (varint), float64 (fixed64), repeated strings, nested
+ * structs or slices of structs (embedded messages), and map<string, string>. The message definition for
+ * consumers in other languages lives in finding.proto next to this file.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Protobuf wire types used by this codec.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+/**
+ * @brief Encodes a tagged struct as a protobuf message.
+ * @param v A struct or pointer to struct whose fields carry `proto:"N"` tags.
+ * @return The encoded message.
+ */
+func marshalProto(v interface{}) []byte {
+	return appendProtoMessage(nil, reflect.Indirect(reflect.ValueOf(v)))
+}
+
+func appendProtoMessage(buf []byte, v reflect.Value) []byte {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		num, err := strconv.Atoi(t.Field(i).Tag.Get("proto"))
+		if err != nil {
+			continue
+		}
+		buf = appendProtoField(buf, uint64(num), v.Field(i))
+	}
+	return buf
+}
+
+func appendProtoField(buf []byte, num uint64, fv reflect.Value) []byte {
+	switch fv.Kind() {
+	case reflect.String:
+		if fv.Len() > 0 {
+			buf = binary.AppendUvarint(buf, num<<3|wireBytes)
+			buf = binary.AppendUvarint(buf, uint64(fv.Len()))
+			buf = append(buf, fv.String()...)
+		}
+	case reflect.Bool:
+		if fv.Bool() {
+			buf = binary.AppendUvarint(buf, num<<3|wireVarint)
+			buf = append(buf, 1)
+		}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		if fv.Int() != 0 {
+			buf = binary.AppendUvarint(buf, num<<3|wireVarint)
+			buf = binary.AppendUvarint(buf, uint64(fv.Int()))
+		}
+	case reflect.Float64:
+		if fv.Float() != 0 {
+			buf = binary.AppendUvarint(buf, num<<3|wireFixed64)
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(fv.Float()))
+		}
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			buf = appendProtoField(buf, num, fv.Elem())
+		}
+	case reflect.Map:
+		// A map is a repeated entry message with key = 1 and value = 2, in key order.
+		keys := fv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			entry := appendProtoField(nil, 1, k)
+			entry = appendProtoField(entry, 2, fv.MapIndex(k))
+			buf = binary.AppendUvarint(buf, num<<3|wireBytes)
+			buf = binary.AppendUvarint(buf, uint64(len(entry)))
+			buf = append(buf, entry...)
+		}
+	case reflect.Struct:
+		msg := appendProtoMessage(nil, fv)
+		buf = binary.AppendUvarint(buf, num<<3|wireBytes)
+		buf = binary.AppendUvarint(buf, uint64(len(msg)))
+		buf = append(buf, msg...)
+	case reflect.Slice:
+		for i := 0; i < fv.Len(); i++ {
+			elem := fv.Index(i)
+			if elem.Kind() == reflect.String {
+				// Repeated strings keep empty elements, unlike singular fields.
+				buf = binary.AppendUvarint(buf, num<<3|wireBytes)
+				buf = binary.AppendUvarint(buf, uint64(elem.Len()))
+				buf = append(buf, elem.String()...)
+				continue
+			}
+			buf = appendProtoField(buf, num, elem)
+		}
+	}
+	return buf
+}
+
+/**
+ * @brief Decodes a protobuf message into a tagged struct. Unknown fields are skipped.
+ * @param data The encoded message.
+ * @param v A pointer to the struct to fill.
+ * @return An error if the message is truncated or malformed.
+ */
+func unmarshalProto(data []byte, v interface{}) error {
+	return decodeProtoMessage(data, reflect.ValueOf(v).Elem())
+}
+
+func decodeProtoMessage(data []byte, v reflect.Value) error {
+	fields := make(map[uint64]reflect.Value)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if num, err := strconv.Atoi(t.Field(i).Tag.Get("proto")); err == nil {
+			fields[uint64(num)] = v.Field(i)
+		}
+	}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("proto: bad field key")
+		}
+		data = data[n:]
+		num, wire := key>>3, key&7
+
+		var scalar uint64
+		var payload []byte
+		switch wire {
+		case wireVarint:
+			scalar, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.New("proto: bad varint")
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			scalar = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			scalar = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return io.ErrUnexpectedEOF
+			}
+			payload = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("proto: unsupported wire type %d", wire)
+		}
+
+		fv, ok := fields[num]
+		if !ok {
+			continue
+		}
+		if err := setProtoField(fv, scalar, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setProtoField(fv reflect.Value, scalar uint64, payload []byte) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(string(payload))
+	case reflect.Bool:
+		fv.SetBool(scalar != 0)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		fv.SetInt(int64(scalar))
+	case reflect.Float64:
+		fv.SetFloat(math.Float64frombits(scalar))
+	case reflect.Ptr:
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setProtoField(fv.Elem(), scalar, payload)
+	case reflect.Struct:
+		return decodeProtoMessage(payload, fv)
+	case reflect.Map:
+		var entry struct {
+			Key   string `proto:"1"`
+			Value string `proto:"2"`
+		}
+		if err := decodeProtoMessage(payload, reflect.ValueOf(&entry).Elem()); err != nil {
+			return err
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+		fv.SetMapIndex(reflect.ValueOf(entry.Key), reflect.ValueOf(entry.Value))
+	case reflect.Slice:
+		elem := reflect.New(fv.Type().Elem()).Elem()
+		if err := setProtoField(elem, scalar, payload); err != nil {
+			return err
+		}
+		fv.Set(reflect.Append(fv, elem))
+	}
+	return nil
+}
+
+/**
+ * @brief Prefixes a message with its varint length (protobuf delimited format).
+ * @param msg The encoded message.
+ * @return The length prefix followed by the message.
+ */
+func delimited(msg []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(len(msg))), msg...)
+}
+
+/**
+ * @brief Reads one varint-length-prefixed message.
+ * @param r The source reader.
+ * @return The message bytes, or io.EOF when the stream ends cleanly.
+ */
+func readDelimited(r io.ByteReader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, length)
+	for i := range msg {
+		if msg[i], err = r.ReadByte(); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+	}
+	return msg, nil
+}
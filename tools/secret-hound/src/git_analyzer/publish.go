@@ -0,0 +1,317 @@
+/**
+ * @file publish.go
+ * @brief Publishing the findings report to an OCI registry (--publish).
+ *
+ * Build artifacts, SBOMs and signatures already live in the organization's
+ * registry, next to the images they describe; scan reports belong there too,
+ * with the same retention, access control and replication. With
+ *   --publish oci://registry.example.com/reports/app:<commit>
+ * the findings written to the output are also kept aside and, once the scan
+ * has finished, pushed as an OCI artifact (image manifest with artifactType
+ * reportArtifactType) of two layers:
+ *   - the findings, in the --output-format encoding, uncompressed and
+ *     unencrypted whatever --compress and --encrypt-to do to the --output file;
+ *   - a provenance record: analyzer and core version, engine, scanner
+ *     configuration fingerprint, redacted arguments, the scanned source and
+ *     revision, the CI run, the number of findings and the timing.
+ * The main provenance facts are also manifest annotations, so `oras
+ * manifest fetch` or a registry UI show them without pulling a layer.
+ * Credentials are those of --image (docker config or REGISTRY_USERNAME and
+ * REGISTRY_PASSWORD). A scan that failed is not published; failing to
+ * publish is an error of the run.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Media types of the published report artifact.
+const (
+	reportArtifactType   = "application/vnd.limearch.secret-hound.report.v1"
+	reportProvenanceType = "application/vnd.limearch.secret-hound.provenance.v1+json"
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociEmptyMediaType    = "application/vnd.oci.empty.v1+json"
+)
+
+// Annotations of the published report manifest and its layers.
+const (
+	reportAnnotationPrefix = "org.limearch.secret-hound."
+	ociTitleAnnotation     = "org.opencontainers.image.title"
+	ociCreatedAnnotation   = "org.opencontainers.image.created"
+	ociRevisionAnnotation  = "org.opencontainers.image.revision"
+	ociSourceAnnotation    = "org.opencontainers.image.source"
+)
+
+// publishHTTPTimeout bounds each request to the registry.
+const publishHTTPTimeout = 10 * time.Minute
+
+// reportMediaTypes are the layer media type and file extension of each --output-format.
+var reportMediaTypes = map[string][2]string{
+	"json":       {"application/vnd.limearch.secret-hound.findings.v1+jsonl", ".jsonl"},
+	"proto":      {"application/vnd.limearch.secret-hound.findings.v1+protobuf", ".pb"},
+	"msgpack":    {"application/vnd.limearch.secret-hound.findings.v1+msgpack", ".msgpack"},
+	"defectdojo": {"application/vnd.limearch.secret-hound.findings.defectdojo.v1+json", ".json"},
+	"aspm":       {"application/vnd.limearch.secret-hound.findings.aspm.v1+json", ".json"},
+	"pretty":     {"text/plain; charset=utf-8", ".txt"},
+}
+
+// findingsPublisher keeps the output for --publish (nil = off).
+var findingsPublisher *reportPublisher
+
+/**
+ * @struct reportPublisher
+ * @brief Keeps a copy of the findings output for --publish.
+ * It is the findings writer's destination and passes everything on to the
+ * real one, so the report is exactly what --output received.
+ */
+type reportPublisher struct {
+	ref     string // The reference without oci://
+	format  string
+	dest    io.Writer
+	spool   *os.File  // The copy of the output
+	started time.Time // Start of the run, for the provenance
+}
+
+/**
+ * @brief Starts keeping the output for publishing.
+ * @param ref An oci:// reference with a tag, e.g. oci://registry/reports/app:abc123.
+ * @param format The --output-format.
+ * @param dest The output the findings go to.
+ * @param tmpDir Directory of the copy ("" = the system's).
+ * @return The publisher, and an error for a reference that is not oci:// or a temporary file that cannot be created.
+ */
+func newReportPublisher(ref, format string, dest io.Writer, tmpDir string) (*reportPublisher, error) {
+	if !strings.HasPrefix(ref, "oci://") {
+		return nil, fmt.Errorf("%s: want an oci://registry/repository:tag reference", ref)
+	}
+	spool, err := ioutil.TempFile(tmpDir, "secret-hound-publish-*")
+	if err != nil {
+		return nil, err
+	}
+	return &reportPublisher{ref: strings.TrimPrefix(ref, "oci://"), format: format, dest: dest, spool: spool, started: time.Now().UTC()}, nil
+}
+
+func (p *reportPublisher) Write(data []byte) (int, error) {
+	if _, err := p.spool.Write(data); err != nil {
+		return 0, fmt.Errorf("--publish: %v", err)
+	}
+	return p.dest.Write(data)
+}
+
+// Close closes the real output; the copy is kept until publish.
+func (p *reportPublisher) Close() error {
+	if closer, ok := p.dest.(io.Closer); ok && p.dest != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// discard removes the copy of the output without publishing it.
+func (p *reportPublisher) discard() {
+	if p == nil {
+		return
+	}
+	p.spool.Close()
+	os.Remove(p.spool.Name())
+}
+
+/**
+ * @struct reportProvenance
+ * @brief How a published report was produced.
+ */
+type reportProvenance struct {
+	Analyzer   string   `json:"analyzer_version"`
+	Core       string   `json:"core_version,omitempty"`
+	Engine     string   `json:"engine"`
+	Config     string   `json:"config"` // Fingerprint of rules, detectors and filters (see auditlog.go)
+	Arguments  []string `json:"arguments"`
+	Source     string   `json:"source,omitempty"`   // Remote URL of the scanned repository, or the image
+	Revision   string   `json:"revision,omitempty"` // Commit at HEAD of the scanned repository
+	CIRun      string   `json:"ci_run,omitempty"`   // URL of the CI job that ran the scan
+	Format     string   `json:"format"`
+	Findings   int64    `json:"findings"`
+	Partial    bool     `json:"partial"` // The --time-budget ran out before every blob was scanned
+	StartedAt  string   `json:"started_at"`
+	FinishedAt string   `json:"finished_at"`
+}
+
+/**
+ * @brief Describes the run for the published report; the totals are added by publish.
+ * @param repo The scanned repository (nil for images, sweeps and workers),
+ *             while it still exists (bundles are unpacked temporarily).
+ */
+func (a *analyzer) reportProvenance(repo *repository) reportProvenance {
+	p := reportProvenance{
+		Analyzer:  analyzerVersion,
+		Engine:    a.opts.engine,
+		Config:    scannerFingerprint(a),
+		Arguments: redactArguments(os.Args[1:]),
+		Source:    a.opts.image,
+		CIRun:     ciRunURL(),
+		Format:    findingsSink.format,
+	}
+	if a.core != nil {
+		p.Core = a.core.Version
+	}
+	if repo != nil {
+		if output, err := repo.command("rev-parse", "--verify", "-q", "HEAD").Output(); err == nil {
+			p.Revision = strings.TrimSpace(string(output))
+		}
+		if output, err := repo.command("config", "--get", "remote.origin.url").Output(); err == nil {
+			p.Source = redactArguments([]string{strings.TrimSpace(string(output))})[0]
+		}
+	}
+	return p
+}
+
+// ciRunURL returns the URL of the CI job the analyzer runs in ("" outside CI).
+func ciRunURL() string {
+	if run := os.Getenv("GITHUB_RUN_ID"); run != "" && os.Getenv("GITHUB_REPOSITORY") != "" {
+		return orDefault(os.Getenv("GITHUB_SERVER_URL"), "https://github.com") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + run
+	}
+	for _, name := range []string{"CI_JOB_URL", "BUILD_URL", "CIRCLE_BUILD_URL"} {
+		if run := os.Getenv(name); run != "" {
+			return run
+		}
+	}
+	return ""
+}
+
+/**
+ * @struct ociDescriptor
+ * @brief A content descriptor of an OCI manifest.
+ */
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+/**
+ * @brief Pushes the kept output and its provenance, and removes the copy.
+ * @param provenance How the report was produced.
+ * @param findings The number of findings written.
+ * @param partial Whether the scan stopped at its --time-budget.
+ * @return The digest of the pushed manifest, and an error if the registry refused any part.
+ */
+func (p *reportPublisher) publish(provenance reportProvenance, findings int64, partial bool) (string, error) {
+	defer p.discard()
+	provenance.Findings = findings
+	provenance.Partial = partial
+	provenance.StartedAt = p.started.Format(time.RFC3339)
+	provenance.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	report, err := ioutil.ReadFile(p.spool.Name())
+	if err != nil {
+		return "", err
+	}
+	record, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	empty := []byte("{}")
+
+	c, reference := newRegistryClient(p.ref, publishHTTPTimeout)
+	if strings.HasPrefix(reference, "sha256:") {
+		return "", fmt.Errorf("%s: publish to a tag, not a digest", p.ref)
+	}
+	kind := reportMediaTypes[p.format]
+	layers := []ociDescriptor{
+		{MediaType: kind[0], Annotations: map[string]string{ociTitleAnnotation: "findings" + kind[1]}},
+		{MediaType: reportProvenanceType, Annotations: map[string]string{ociTitleAnnotation: "provenance.json"}},
+	}
+	for i, data := range [][]byte{report, record} {
+		if layers[i].Digest, err = c.pushBlob(data); err != nil {
+			return "", err
+		}
+		layers[i].Size = int64(len(data))
+	}
+	config := ociDescriptor{MediaType: ociEmptyMediaType, Size: int64(len(empty))}
+	if config.Digest, err = c.pushBlob(empty); err != nil {
+		return "", err
+	}
+
+	annotations := map[string]string{
+		ociCreatedAnnotation:                    provenance.FinishedAt,
+		reportAnnotationPrefix + "version":      provenance.Analyzer,
+		reportAnnotationPrefix + "engine":       provenance.Engine,
+		reportAnnotationPrefix + "format":       provenance.Format,
+		reportAnnotationPrefix + "findings":     fmt.Sprint(provenance.Findings),
+		reportAnnotationPrefix + "partial":      fmt.Sprint(provenance.Partial),
+		reportAnnotationPrefix + "config":       provenance.Config,
+		reportAnnotationPrefix + "core-version": provenance.Core,
+		ociRevisionAnnotation:                   provenance.Revision,
+		ociSourceAnnotation:                     provenance.Source,
+	}
+	for key, value := range annotations {
+		if value == "" {
+			delete(annotations, key)
+		}
+	}
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     ociManifestMediaType,
+		"artifactType":  reportArtifactType,
+		"config":        config,
+		"layers":        layers,
+		"annotations":   annotations,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do("PUT", "/manifests/"+reference, http.Header{"Content-Type": {ociManifestMediaType}}, manifest)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return blobDigest(manifest), nil
+}
+
+// blobDigest is the OCI digest of some content.
+func blobDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+/**
+ * @brief Uploads a blob in one request, unless the registry already has it.
+ * @return The blob's digest.
+ */
+func (c *registryClient) pushBlob(data []byte) (string, error) {
+	digest := blobDigest(data)
+	if resp, err := c.do("HEAD", "/blobs/"+digest, nil, nil); err == nil {
+		resp.Body.Close()
+		return digest, nil
+	}
+	resp, err := c.do("POST", "/blobs/uploads/", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", fmt.Errorf("%s: blob upload without a Location", c.host)
+	}
+	separator := "?"
+	if strings.Contains(location, "?") {
+		separator = "&"
+	}
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if resp, err = c.do("PUT", location+separator+"digest="+url.QueryEscape(digest), header, data); err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return digest, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPublishPushesTheReportArtifact(t *testing.T) {
+	var mu sync.Mutex
+	blobs := make(map[string][]byte)
+	var manifest []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method == "HEAD" && strings.HasPrefix(r.URL.Path, "/v2/reports/app/blobs/"):
+			http.NotFound(w, r)
+		case r.Method == "POST" && r.URL.Path == "/v2/reports/app/blobs/uploads/":
+			w.Header().Set("Location", "/v2/reports/app/blobs/uploads/1?state=x")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "PUT" && r.URL.Path == "/v2/reports/app/blobs/uploads/1":
+			digest := r.URL.Query().Get("digest")
+			if digest != blobDigest(body) || r.URL.Query().Get("state") != "x" {
+				http.Error(w, "digest mismatch", http.StatusBadRequest)
+				return
+			}
+			blobs[digest] = body
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == "PUT" && r.URL.Path == "/v2/reports/app/manifests/abc123":
+			manifest = body
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.Error(w, r.Method+" "+r.URL.Path, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	transport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = transport }()
+
+	var output strings.Builder
+	host := strings.TrimPrefix(server.URL, "https://")
+	p, err := newReportPublisher("oci://"+host+"/reports/app:abc123", "json", &output, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := `{"rule_id":"AWS_ACCESS_KEY"}` + "\n"
+	io.WriteString(p, report)
+	if output.String() != report {
+		t.Errorf("output = %q, want the report passed through", output.String())
+	}
+	digest, err := p.publish(reportProvenance{Analyzer: analyzerVersion, Engine: "native", Format: "json"}, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != blobDigest(manifest) {
+		t.Errorf("digest %s, want that of the pushed manifest", digest)
+	}
+
+	var pushed struct {
+		ArtifactType string            `json:"artifactType"`
+		Layers       []ociDescriptor   `json:"layers"`
+		Annotations  map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(manifest, &pushed); err != nil {
+		t.Fatal(err)
+	}
+	if pushed.ArtifactType != reportArtifactType || len(pushed.Layers) != 2 {
+		t.Fatalf("manifest %s", manifest)
+	}
+	if got := string(blobs[pushed.Layers[0].Digest]); got != report {
+		t.Errorf("findings layer = %q, want %q", got, report)
+	}
+	var provenance reportProvenance
+	if err := json.Unmarshal(blobs[pushed.Layers[1].Digest], &provenance); err != nil || provenance.Findings != 1 || provenance.FinishedAt == "" {
+		t.Errorf("provenance layer %s (%v)", blobs[pushed.Layers[1].Digest], err)
+	}
+	if pushed.Annotations[reportAnnotationPrefix+"findings"] != "1" {
+		t.Errorf("annotations %v", pushed.Annotations)
+	}
+}
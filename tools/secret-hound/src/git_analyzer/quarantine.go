@@ -0,0 +1,180 @@
+/**
+ * @file quarantine.go
+ * @brief Isolation of blobs that crash the core scanner (--quarantine-file).
+ *
+ * The C++ core runs in its own process per blob, so a segfault on some
+ * unusual input only loses that blob, but it used to lose it silently
+ * apart from one stderr line. Now a blob the core fails on is quarantined:
+ * it is retried once with the native engine (the same rules, evaluated in
+ * Go), its findings carry metadata core_fallback, and the end of the run
+ * lists the unscannable objects with the core's error. With
+ * --quarantine-file (or quarantine.json under --state-url) the list is kept
+ * across runs, and quarantined blobs go straight to the native engine
+ * instead of crashing the core again. Delete an entry, or the file, after
+ * upgrading the core to give it another try.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+/**
+ * @struct quarantinedBlob
+ * @brief A blob the core scanner failed on.
+ */
+type quarantinedBlob struct {
+	Hash       string `json:"hash"`
+	Path       string `json:"path"`
+	Repository string `json:"repository,omitempty"`
+	Commit     string `json:"commit"`
+	Reason     string `json:"reason"` // The core's error, e.g. "signal: segmentation fault"
+	Since      string `json:"since"`  // RFC 3339 time it was first quarantined
+}
+
+/**
+ * @struct coreQuarantine
+ * @brief The quarantine list and the native engine blobs on it are scanned with.
+ */
+type coreQuarantine struct {
+	mu       sync.Mutex
+	path     string                      // --quarantine-file ("" = this run only)
+	entries  map[string]*quarantinedBlob // By blob hash
+	fresh    []*quarantinedBlob          // Quarantined by this run
+	rescans  int                         // Blobs of earlier runs' list seen again
+	fallback *nativeEngine
+}
+
+/**
+ * @brief Loads the quarantine list.
+ * @param path The --quarantine-file value ("" keeps the list in memory).
+ * @param rules The rules the native engine retries blobs with.
+ * @return The quarantine and an error if the file exists but cannot be read.
+ */
+func loadCoreQuarantine(path string, rules *ruleSet) (*coreQuarantine, error) {
+	q := &coreQuarantine{path: path, entries: make(map[string]*quarantinedBlob), fallback: newNativeEngine(rules)}
+	if path == "" {
+		return q, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*quarantinedBlob
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, entry := range list {
+		q.entries[entry.Hash] = entry
+	}
+	return q, nil
+}
+
+/**
+ * @brief Reports whether a blob is on the quarantine list.
+ */
+func (q *coreQuarantine) has(blob fileBlob) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.entries[blob.hash]
+	if ok {
+		q.rescans++
+	}
+	return ok
+}
+
+/**
+ * @brief Puts a blob the core failed on in quarantine.
+ * @param blob The blob.
+ * @param reason The core's error.
+ */
+func (q *coreQuarantine) add(blob fileBlob, reason error) {
+	if q == nil {
+		return
+	}
+	entry := &quarantinedBlob{
+		Hash:       blob.hash,
+		Path:       blob.path,
+		Repository: blob.repo.label,
+		Commit:     blob.commit,
+		Reason:     reason.Error(),
+		Since:      time.Now().UTC().Format(time.RFC3339),
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.entries[blob.hash]; !ok {
+		q.entries[blob.hash] = entry
+		q.fresh = append(q.fresh, entry)
+	}
+}
+
+/**
+ * @brief Scans a quarantined blob with the native engine.
+ * @return The findings, with metadata core_fallback=native.
+ */
+func (q *coreQuarantine) scan(blob fileBlob, content []byte) []*finding {
+	if q == nil {
+		return nil
+	}
+	findings := q.fallback.scan(content, blob)
+	for _, f := range findings {
+		setMetadata(f, "core_fallback", "native")
+	}
+	return findings
+}
+
+/**
+ * @brief Prints the unscannable objects section of the run summary on stderr.
+ */
+func (q *coreQuarantine) report() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.rescans > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %d quarantined blob(s) scanned with the native engine only\n", q.rescans)
+	}
+	if len(q.fresh) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: unscannable objects: the core scanner failed on %d blob(s); they were scanned with the native engine:\n", len(q.fresh))
+	for _, entry := range q.fresh {
+		fmt.Fprintf(os.Stderr, "  %s %s%s (commit %.12s): %s\n", entry.Hash, labelPrefix(entry.Repository), entry.Path, entry.Commit, entry.Reason)
+	}
+}
+
+/**
+ * @brief Writes the quarantine list to --quarantine-file.
+ * @return An error if the file could not be written.
+ */
+func (q *coreQuarantine) save() error {
+	if q == nil || q.path == "" || len(q.fresh) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]*quarantinedBlob, 0, len(q.entries))
+	for _, entry := range q.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Since+list[i].Hash < list[j].Since+list[j].Hash })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(q.path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCoreQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	core := fakeCore(t, `echo run >>`+calls+`; grep -q CRASH && kill -SEGV $$; exit 0`)
+	rules, _ := embeddedRuleSet()
+	listPath := filepath.Join(dir, "quarantine.json")
+	blob := fileBlob{hash: "c0ffee", path: "deploy.env", commit: "abc123", repo: &repository{label: "app"}}
+	content := []byte("# CRASH\nAWS_KEY=AKIAY34FZKBOKMUTVV01\n")
+
+	newAnalyzer := func() *analyzer {
+		q, err := loadCoreQuarantine(listPath, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &analyzer{opts: options{houndCorePath: core}, core: &coreInfo{Capabilities: []string{"scan-file", "stdin"}}, quarantine: q}
+	}
+	checkFallback := func(run string, findings []*finding) {
+		if len(findings) == 0 {
+			t.Fatalf("%s: the native retry found nothing", run)
+		}
+		for _, f := range findings {
+			if f.Metadata["core_fallback"] != "native" {
+				t.Errorf("%s: finding %s without core_fallback metadata", run, f.RuleID)
+			}
+		}
+	}
+
+	a := newAnalyzer()
+	var findings []*finding
+	output := captureStderr(t, func() {
+		findings, _ = a.runCore(blob, content)
+		a.quarantine.report()
+	})
+	checkFallback("first run", findings)
+	if !strings.Contains(output, "unscannable objects: the core scanner failed on 1 blob(s)") || !strings.Contains(output, "c0ffee app: deploy.env (commit abc123)") {
+		t.Errorf("unexpected report:\n%s", output)
+	}
+	if err := a.quarantine.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	a = newAnalyzer()
+	output = captureStderr(t, func() {
+		findings, _ = a.runCore(blob, content)
+		a.quarantine.report()
+	})
+	checkFallback("second run", findings)
+	if data, _ := os.ReadFile(calls); strings.Count(string(data), "run") != 1 {
+		t.Errorf("the core ran %d time(s), want once: quarantined blobs go to the native engine", strings.Count(string(data), "run"))
+	}
+	if !strings.Contains(output, "1 quarantined blob(s) scanned with the native engine only") || strings.Contains(output, "unscannable objects") {
+		t.Errorf("unexpected report:\n%s", output)
+	}
+}
+
+func TestLoadCoreQuarantineRejectsACorruptFile(t *testing.T) {
+	rules, _ := embeddedRuleSet()
+	path := writeFile(t, "quarantine.json", "{not json")
+	if _, err := loadCoreQuarantine(path, rules); err == nil {
+		t.Error("a corrupt quarantine file was accepted")
+	}
+	empty := writeFile(t, "empty.json", "")
+	if q, err := loadCoreQuarantine(empty, rules); err != nil || len(q.entries) != 0 {
+		t.Errorf("empty file: %v, %v", q, err)
+	}
+}
@@ -0,0 +1,194 @@
+/**
+ * @file refs.go
+ * @brief Where the history walk starts: HEAD, every ref, reflogs, dangling
+ *        commits, or only the commits of a branch (--all-refs, --reflog,
+ *        --unreachable, --base).
+ *
+ * By default the walk covers the --depth newest commits reachable from HEAD.
+ * A secret pushed to another branch, or committed and then removed with
+ * `git commit --amend` or a reset, is not on that path but still sits in the
+ * repository. --all-refs starts from every branch, tag and other ref,
+ * --reflog adds the commits reflogs still point at, and --unreachable adds
+ * the dangling commits `git fsck` finds (dropped stashes, amended commits
+ * whose reflog expired). --base goes the other way for branch builds: only
+ * commits not reachable from the base ref (base..HEAD) are walked, and
+ * --hook walks the commits of a push (see hook.go).
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * @brief Returns the rev-list revisions the history walk starts from.
+ * Dangling commits are listed per repository by unreachableCommits.
+ */
+func historyRevs(opts options) []string {
+	if opts.hook != "" {
+		return hookRevs(opts.hook, opts.hookUpdates)
+	}
+	revs := []string{"HEAD"}
+	if opts.allRefs {
+		revs = []string{"--all"}
+	}
+	if opts.reflog {
+		revs = append(revs, "--reflog")
+	}
+	if opts.base != "" {
+		revs = append(revs, "^"+opts.base)
+	}
+	return revs
+}
+
+/**
+ * @brief Reports whether the walk starts anywhere but HEAD.
+ */
+func customHistoryRevs(opts options) bool {
+	return opts.allRefs || opts.reflog || opts.unreachable || opts.base != "" || opts.hook != ""
+}
+
+/**
+ * @brief Names the option that limits a run to part of the history, or to
+ * part of the rules, detectors or findings.
+ * A run limited this way has not seen every location of a secret, so it
+ * must not replace or resolve what full scans recorded. Commits skipped by
+ * an ignore-revs file are only known once a repository is walked; see
+ * analyzer.narrow.
+ * @param rules The loaded rules; a set lacking default rules narrows detection.
+ * @param policy The loaded policy; suppressing rules narrow the findings.
+ * @return The option, or "" if the run walks the whole history with every rule.
+ */
+func scanScope(opts options, rules *ruleSet, policy *policySet) string {
+	switch {
+	case opts.shard.count > 1:
+		return "--shard"
+	case opts.base != "":
+		return "--base"
+	case opts.staged:
+		return "--staged"
+	case opts.hook != "":
+		return "--hook"
+	case opts.snapshot != "":
+		return "--snapshot"
+	case opts.release.to != "":
+		return "--between-tags"
+	case opts.worker != "":
+		return "--worker" // Scans the queue items it claims
+	case opts.merges.firstParent:
+		return "--first-parent" // Skips the commits of merged branches
+	case len(opts.disableRules) > 0:
+		return "--disable-rule"
+	case rules.reduced():
+		return "--rules"
+	case !allDetectors(opts.detectors):
+		return "--detectors"
+	case opts.stringLiterals:
+		return "--string-literals" // Masks code and comments
+	case opts.engine == "core" && opts.prefilter == prefilterAggressive:
+		return "--prefilter aggressive" // Checks only a sample of large blobs
+	case opts.decodeMinLength == 0:
+		return "--decode-min-length 0"
+	case !opts.transcode:
+		return "--transcode=false"
+	case policy.suppresses():
+		return "--policy"
+	}
+	return ""
+}
+
+/**
+ * @brief Narrows the run once a repository's walk turns out to skip commits.
+ * @param scope The option or file responsible.
+ */
+func (a *analyzer) narrow(scope string) {
+	a.scopeMu.Lock()
+	if a.scope == "" {
+		a.scope = scope
+	}
+	a.scopeMu.Unlock()
+	a.incidents.narrow(scope)
+}
+
+// runScope returns the option limiting the run ("" = complete, see scanScope).
+func (a *analyzer) runScope() string {
+	a.scopeMu.Lock()
+	defer a.scopeMu.Unlock()
+	return a.scope
+}
+
+/**
+ * @brief Lists the commits no ref or reflog points at, with `git fsck`.
+ * @param repo The repository.
+ * @return The hashes of the dangling and otherwise unreachable commits.
+ */
+func unreachableCommits(repo *repository) ([]string, error) {
+	output, err := repo.command("fsck", "--unreachable", "--no-progress").Output()
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("git fsck: %v", err)
+	}
+	var commits []string
+	for _, line := range strings.Split(string(output), "\n") {
+		// "unreachable commit <hash>"; fsck also reports trees, blobs and tags.
+		if fields := strings.Fields(line); len(fields) == 3 && fields[1] == "commit" {
+			commits = append(commits, fields[2])
+		}
+	}
+	return commits, nil
+}
+
+/**
+ * @brief Checks that --base names a commit of the repository.
+ */
+func checkBaseRef(repo *repository, base string) error {
+	if repo.command("rev-parse", "--verify", "-q", base+"^{commit}").Run() != nil {
+		return fmt.Errorf("%s does not name a commit; fetch it first, e.g. git fetch origin %s", base, strings.TrimPrefix(base, "origin/"))
+	}
+	return nil
+}
+
+/**
+ * @brief Collects the blobs of the --depth newest commits from the configured revisions.
+ * @param repo The repository.
+ * @return The blobs, the number of commits walked, and an error.
+ */
+func (a *analyzer) getHistoryBlobs(repo *repository) ([]fileBlob, int, error) {
+	opts := a.opts
+	if opts.base != "" {
+		if err := checkBaseRef(repo, opts.base); err != nil {
+			return nil, 0, fmt.Errorf("--base: %v", err)
+		}
+	}
+	var dangling []string
+	if opts.unreachable {
+		var err error
+		if dangling, err = unreachableCommits(repo); err != nil {
+			return nil, 0, fmt.Errorf("--unreachable: %v", err)
+		}
+	}
+	revs := historyRevs(opts)
+	if opts.hook != "" && len(revs) == 0 {
+		return nil, 0, nil // The push only deletes refs
+	}
+	if repo.command("rev-parse", "--verify", "-q", "HEAD").Run() != nil && !opts.allRefs && !opts.reflog && opts.hook == "" && len(dangling) == 0 {
+		return nil, 0, nil // No commits yet
+	}
+
+	// Dangling commits can be many; they go to rev-list on stdin.
+	args := []string{"rev-list", "--parents", fmt.Sprintf("--max-count=%d", opts.depth)}
+	if opts.merges.firstParent {
+		args = append(args, "--first-parent")
+	}
+	args = append(args, revs...)
+	cmd := repo.command(append(args, "--stdin")...)
+	cmd.Stdin = strings.NewReader(strings.Join(dangling, "\n") + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, 0, fmt.Errorf("git rev-list: %v", err)
+	}
+	commits := parseRevList(output)
+	blobs, err := collectCommitBlobs(repo, commits, a.cache, opts.merges)
+	return blobs, len(commits), err
+}
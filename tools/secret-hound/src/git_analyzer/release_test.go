@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestJoinBetweenTagsArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args, want []string
+	}{
+		{[]string{"--between-tags", "v1", "v2", "core"}, []string{"--between-tags=v1..v2", "core"}},
+		{[]string{"-between-tags", "v1", "v2", "core"}, []string{"-between-tags=v1..v2", "core"}},
+		{[]string{"--between-tags", "v1..v2", "core"}, []string{"--between-tags", "v1..v2", "core"}},
+		{[]string{"--between-tags", "v1", "--json"}, []string{"--between-tags", "v1", "--json"}},
+		{[]string{"--", "--between-tags", "a", "b"}, []string{"--", "--between-tags", "a", "b"}},
+	} {
+		if got := joinBetweenTagsArgs(tc.args); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("joinBetweenTagsArgs(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestParseReleaseRange(t *testing.T) {
+	if r, err := parseReleaseRange("v1.2.0..v1.3.0"); err != nil || r.from != "v1.2.0" || r.to != "v1.3.0" || r.String() != "v1.2.0..v1.3.0" {
+		t.Errorf("parseReleaseRange = %+v, %v", r, err)
+	}
+	for _, bad := range []string{"v1.2.0", "..v1", "v1..", "a..b..c"} {
+		if _, err := parseReleaseRange(bad); err == nil {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
+
+func TestReleaseBlobsLeaveOutShippedContent(t *testing.T) {
+	fx := newFixtureRepo(t)
+	fx.commit("v1 content", map[string]string{"app.env": "TOKEN=old", "README": "r"})
+	fx.git("tag", "v1")
+	fx.commit("new secret", map[string]string{"app.env": "TOKEN=new", "keys.pem": "k"})
+	fx.commit("revert to the shipped value", map[string]string{"app.env": "TOKEN=old"})
+	fx.git("tag", "v2")
+	fx.commit("unreleased", map[string]string{"next.txt": "n"})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+
+	blobs, commits, err := getReleaseBlobs(repo, releaseRange{from: "v1", to: "v2"}, &commitCache{commits: make(map[string][]commitChange)}, mergePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, blob := range blobs {
+		content, _ := readBlobContent(blob)
+		got = append(got, blob.path+"="+string(content))
+	}
+	sort.Strings(got)
+	if want := []string{"app.env=TOKEN=new", "keys.pem=k"}; commits != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("%d commits, blobs %q; want 2 commits, %q", commits, got, want)
+	}
+
+	if _, _, err := getReleaseBlobs(repo, releaseRange{from: "v0", to: "v2"}, &commitCache{commits: make(map[string][]commitChange)}, mergePolicy{}); err == nil {
+		t.Error("an unknown tag was accepted")
+	}
+}
@@ -0,0 +1,239 @@
+/**
+ * @file resultcache.go
+ * @brief Scan results of blobs persisted across runs (--result-cache).
+ *
+ * A blob hash names its content for good, so the findings of a blob at a
+ * given path never change as long as the scanner configuration does not.
+ * With --result-cache the scan stage looks every blob up in a cache of
+ * earlier results before running the rule engine and the native detectors,
+ * and records what it finds; re-scanning unchanged history only reads the
+ * blobs and re-enriches their findings. The cache is keyed by blob hash and
+ * path (profiles and detectors depend on the path), holds at most
+ * --result-cache-entries blobs with the least recently used evicted first,
+ * and is discarded whole when the rules, engine, core version or detector
+ * selection differ from the run that wrote it.
+ */
+
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// resultCacheFormat is bumped whenever the layout of cached findings changes.
+const resultCacheFormat = 1
+
+/**
+ * @struct resultEntry
+ * @brief The scan result of one blob at one path.
+ */
+type resultEntry struct {
+	Key      string          `json:"key"`
+	Findings json.RawMessage `json:"findings"` // Encoded so every hit decodes a private copy
+}
+
+// resultCacheFile is the on-disk layout of a persisted cache, least recently used first.
+type resultCacheFile struct {
+	Format  int           `json:"format"`
+	Config  string        `json:"config"`
+	Entries []resultEntry `json:"entries"`
+}
+
+/**
+ * @struct resultCache
+ * @brief A bounded LRU map from blob hash and path to scan findings.
+ */
+type resultCache struct {
+	mu      sync.Mutex
+	path    string                   // File the cache is persisted to
+	config  string                   // Fingerprint of the scanner configuration
+	max     int                      // Most entries kept
+	order   *list.List               // *resultEntry, most recently used at the front
+	entries map[string]*list.Element // Key -> element of order
+	dirty   bool
+	hits    int
+	misses  int
+}
+
+/**
+ * @brief Computes the fingerprint of everything that decides a blob's findings.
+ * @param a The configured analyzer.
+ * @return A hex SHA-256 digest.
+ */
+func scannerFingerprint(a *analyzer) string {
+	config := struct {
+		Analyzer  string   `json:"analyzer"`
+		Engine    string   `json:"engine"`
+		Core      coreInfo `json:"core"`
+		Rules     *ruleSet `json:"rules"`
+		Detectors string   `json:"detectors"`
+		Transcode bool     `json:"transcode"`
+		Decode    int      `json:"decode_min_length"`
+		Literals  bool     `json:"string_literals"`
+		PII       string   `json:"pii,omitempty"`
+		Patterns  string   `json:"pii_patterns,omitempty"`
+		External  []string `json:"external_detectors,omitempty"`
+		Prefilter string   `json:"prefilter,omitempty"` // Only aggressive can change what is found
+		Logs      bool     `json:"normalize_logs,omitempty"`
+	}{analyzerVersion, a.opts.engine, coreInfo{}, a.rules, a.opts.detectors, a.opts.transcode, a.opts.decodeMinLength, a.opts.stringLiterals,
+		a.opts.detectPII, a.opts.piiPatterns, a.opts.externalDetectors, "", a.opts.normalizeLogs}
+	if a.core != nil {
+		config.Core = *a.core
+	}
+	if a.prefilter != nil && a.opts.prefilter == prefilterAggressive {
+		config.Prefilter = fmt.Sprintf("%s/%d", prefilterAggressive, a.opts.prefilterSample)
+	}
+	data, _ := json.Marshal(config)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * @brief Creates a result cache, loading it from path if the file exists.
+ * A cache written by another format or scanner configuration starts empty.
+ * @param path The persistence file.
+ * @param config The scanner fingerprint (see scannerFingerprint).
+ * @param max The most entries kept.
+ * @return The cache and an error if an existing file could not be read.
+ */
+func loadResultCache(path, config string, max int) (*resultCache, error) {
+	if max < 1 {
+		return nil, fmt.Errorf("--result-cache-entries must be at least 1")
+	}
+	c := &resultCache{path: path, config: config, max: max, order: list.New(), entries: make(map[string]*list.Element)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file resultCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if file.Format != resultCacheFormat || file.Config != config {
+		c.dirty = true // Replace the stale file even if nothing new is scanned
+		return c, nil
+	}
+	for i := range file.Entries {
+		c.entries[file.Entries[i].Key] = c.order.PushFront(&file.Entries[i])
+	}
+	c.evict()
+	return c, nil
+}
+
+// resultKey identifies a blob's content at a path.
+func resultKey(blob fileBlob) string {
+	return blob.hash + ":" + blob.path
+}
+
+/**
+ * @brief Returns the cached findings of a blob, with the blob's git context attached.
+ * @param blob The blob about to be scanned.
+ * @return The findings (nil if the blob had none) and whether the blob was cached.
+ */
+func (c *resultCache) lookup(blob fileBlob) ([]*finding, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	elem, ok := c.entries[resultKey(blob)]
+	if !ok {
+		c.misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	encoded := elem.Value.(*resultEntry).Findings
+	c.mu.Unlock()
+
+	var findings []*finding
+	if err := json.Unmarshal(encoded, &findings); err != nil {
+		return nil, false
+	}
+	for _, f := range findings {
+		f.Repository = blob.repo.label
+		f.Commit = blob.commit
+		f.OriginalPath = blob.path
+	}
+	return findings, true
+}
+
+/**
+ * @brief Records the findings of a scanned blob.
+ * Must be called before the findings are enriched, which is not cached.
+ */
+func (c *resultCache) store(blob fileBlob, findings []*finding) {
+	if c == nil {
+		return
+	}
+	encoded, err := json.Marshal(findings)
+	if err != nil {
+		return
+	}
+	key := resultKey(blob)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*resultEntry).Findings = encoded
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(&resultEntry{Key: key, Findings: encoded})
+		c.evict()
+	}
+	c.dirty = true
+}
+
+// evict drops the least recently used entries beyond the size limit. c.mu must be held.
+func (c *resultCache) evict() {
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		delete(c.entries, oldest.Value.(*resultEntry).Key)
+		c.order.Remove(oldest)
+	}
+}
+
+/**
+ * @brief Writes the cache to its file if it changed and reports the hit rate.
+ * The file is replaced atomically so an interrupted run never corrupts it.
+ * @return An error if the file could not be written.
+ */
+func (c *resultCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hits+c.misses > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: result cache: %d of %d blob(s) served from %s\n", c.hits, c.hits+c.misses, c.path)
+	}
+	if !c.dirty {
+		return nil
+	}
+	file := resultCacheFile{Format: resultCacheFormat, Config: c.config, Entries: make([]resultEntry, 0, c.order.Len())}
+	for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+		file.Entries = append(file.Entries, *elem.Value.(*resultEntry))
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
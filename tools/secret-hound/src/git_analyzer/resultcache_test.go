@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResultCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	repo := &repository{label: "billing"}
+	blob := fileBlob{hash: "b10b", path: "config/app.env", commit: "c1", repo: repo}
+
+	c, err := loadResultCache(path, "config-1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.lookup(blob); ok {
+		t.Error("a hit in an empty cache")
+	}
+	c.store(blob, []*finding{{RuleID: "TOKEN", Match: "s3cr3t", Line: 2, Commit: "c1", Repository: "billing"}})
+	c.store(fileBlob{hash: "c1ea", path: "README.md", repo: repo}, nil)
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadResultCache(path, "config-1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same content in a later commit and another repository.
+	later := fileBlob{hash: "b10b", path: "config/app.env", commit: "c2", repo: &repository{label: "fork"}}
+	findings, ok := reloaded.lookup(later)
+	if !ok || len(findings) != 1 || findings[0].Match != "s3cr3t" || findings[0].Commit != "c2" || findings[0].Repository != "fork" {
+		t.Errorf("lookup: %v, %+v", ok, findings)
+	}
+	findings[0].Severity = "critical" // Enrichment must not leak into the cache
+	if again, _ := reloaded.lookup(later); again[0].Severity != "" {
+		t.Error("a hit shares findings with an earlier hit")
+	}
+	if clean, ok := reloaded.lookup(fileBlob{hash: "c1ea", path: "README.md", repo: repo}); !ok || clean != nil {
+		t.Errorf("a clean blob: %v, %v", ok, clean)
+	}
+	if _, ok := reloaded.lookup(fileBlob{hash: "b10b", path: "config/other.env", repo: repo}); ok {
+		t.Error("a hit for the same content at another path")
+	}
+}
+
+func TestResultCacheDiscardsOtherConfigurations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	blob := fileBlob{hash: "b10b", path: "a.env", repo: &repository{}}
+	c, _ := loadResultCache(path, "config-1", 10)
+	c.store(blob, []*finding{{RuleID: "TOKEN"}})
+	c.save()
+
+	other, err := loadResultCache(path, "config-2", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := other.lookup(blob); ok {
+		t.Error("results of another scanner configuration were served")
+	}
+	other.save() // Replaced although nothing was scanned
+	if again, _ := loadResultCache(path, "config-1", 10); again.order.Len() != 0 {
+		t.Error("the stale cache file was kept")
+	}
+
+	os.WriteFile(path, []byte("{"), 0o600)
+	if _, err := loadResultCache(path, "config-1", 10); err == nil {
+		t.Error("a corrupt cache file was accepted")
+	}
+	if _, err := loadResultCache(path, "config-1", 0); err == nil {
+		t.Error("--result-cache-entries 0 was accepted")
+	}
+}
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := loadResultCache(filepath.Join(t.TempDir(), "results.json"), "config", 2)
+	repo := &repository{}
+	a, b, d := fileBlob{hash: "a", repo: repo}, fileBlob{hash: "b", repo: repo}, fileBlob{hash: "d", repo: repo}
+	c.store(a, nil)
+	c.store(b, nil)
+	c.lookup(a) // b is now the least recently used
+	c.store(d, nil)
+	if _, ok := c.lookup(b); ok {
+		t.Error("the least recently used entry was kept")
+	}
+	if _, ok := c.lookup(a); !ok {
+		t.Error("a recently used entry was evicted")
+	}
+}
+
+func TestScannerFingerprintFollowsTheConfiguration(t *testing.T) {
+	rules, _ := embeddedRuleSet()
+	a := &analyzer{opts: options{engine: "native", detectors: "all"}, rules: rules}
+	base := scannerFingerprint(a)
+	a.opts.detectors = "config"
+	if scannerFingerprint(a) == base {
+		t.Error("the detector selection does not change the fingerprint")
+	}
+	a.opts.detectors = "all"
+	a.core = &coreInfo{Version: "2.0.0"}
+	if scannerFingerprint(a) == base {
+		t.Error("the core version does not change the fingerprint")
+	}
+}
@@ -0,0 +1,352 @@
+/**
+ * @file revoke.go
+ * @brief Opt-in revocation of live secrets as they are found (--auto-revoke).
+ *
+ * For secret types with a revocation API, the analyzer can respond to a
+ * finding instead of only reporting it. Each revoker first checks that the
+ * secret is live and revocable, then revokes it:
+ *   github  GitHub tokens (ghp_, gho_, ghu_, ghs_, github_pat_): checked with
+ *           GET /user and revoked with the credential revocation API, which
+ *           needs no credentials of its own.
+ *   aws     AWS access keys (AKIA...) owned by the account of the AWS_*
+ *           credentials in the environment: the owning user is looked up with
+ *           GetAccessKeyLastUsed and an active key is set to Inactive with
+ *           UpdateAccessKey. Keys can be reactivated; they are not deleted.
+ *
+ * Revocation breaks whatever still uses the secret, so it is a dry run unless
+ * --auto-revoke-confirm is also given: liveness is checked and the findings
+ * say what would be revoked. Each secret is handled once per run, findings
+ * suppressed by policy or demoted to info (allowlisted) are never touched, and
+ * the outcome is recorded in metadata revocation and logged to stderr.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes recorded in metadata revocation.
+const (
+	revocationRevoked     = "revoked"
+	revocationWouldRevoke = "would_revoke" // Live, but --auto-revoke-confirm was not given
+	revocationNotLive     = "not_live"     // Invalid, already inactive, or not owned by this account
+	revocationFailed      = "failed"
+)
+
+// revokeHTTPTimeout bounds each call to a revocation API.
+const revokeHTTPTimeout = 30 * time.Second
+
+/**
+ * @brief A handler for one kind of revocable secret.
+ */
+type revoker interface {
+	// name is the identifier used by --auto-revoke.
+	name() string
+	// supports reports whether the finding is a secret this revoker handles.
+	supports(f *finding) bool
+	// check reports whether the secret is live and can be revoked, with a description for the log.
+	check(secret string) (live bool, detail string, err error)
+	// revoke disables the secret.
+	revoke(secret string) error
+}
+
+/**
+ * @brief Lists every built-in revoker.
+ */
+func builtinRevokers(client *http.Client) []revoker {
+	return []revoker{
+		githubRevoker{client: client, api: strings.TrimRight(orDefault(os.Getenv("GITHUB_API_URL"), "https://api.github.com"), "/")},
+		&awsRevoker{client: client},
+	}
+}
+
+/**
+ * @struct autoRevoker
+ * @brief Applies the selected revokers to findings, once per secret.
+ */
+type autoRevoker struct {
+	revokers []revoker
+	confirm  bool
+
+	mu       sync.Mutex
+	outcomes map[string]*revocation // Keyed by revoker name and secret
+}
+
+// revocation is the outcome of handling one secret, shared by all its findings.
+type revocation struct {
+	once    sync.Once
+	outcome string
+}
+
+/**
+ * @brief Creates the revoker for an --auto-revoke value.
+ * @param spec "all", "none", or a comma-separated list of revoker names.
+ * @param confirm Whether to revoke rather than only report (--auto-revoke-confirm).
+ * @return The revoker (nil for "none") and an error naming any unknown revoker.
+ */
+func newAutoRevoker(spec string, confirm bool) (*autoRevoker, error) {
+	all := builtinRevokers(&http.Client{Timeout: revokeHTTPTimeout})
+	var selected []revoker
+	switch strings.TrimSpace(spec) {
+	case "", "none":
+		return nil, nil
+	case "all":
+		selected = all
+	default:
+		byName := make(map[string]revoker, len(all))
+		var names []string
+		for _, r := range all {
+			byName[r.name()] = r
+			names = append(names, r.name())
+		}
+		sort.Strings(names)
+		for _, name := range strings.Split(spec, ",") {
+			r, ok := byName[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown revoker %q (available: %s)", name, strings.Join(names, ", "))
+			}
+			selected = append(selected, r)
+		}
+	}
+	return &autoRevoker{revokers: selected, confirm: confirm, outcomes: make(map[string]*revocation)}, nil
+}
+
+/**
+ * @brief Checks and, if confirmed, revokes the secret of a finding.
+ * @param f The finding, after enrichment and policy.
+ */
+func (r *autoRevoker) handle(f *finding) {
+	if r == nil || f.Severity == "info" {
+		return
+	}
+	secret := strings.Trim(strings.TrimSpace(f.Match), `"'`)
+	for _, rv := range r.revokers {
+		if !rv.supports(f) {
+			continue
+		}
+		r.mu.Lock()
+		key := rv.name() + "\x00" + secret
+		rec, ok := r.outcomes[key]
+		if !ok {
+			rec = &revocation{}
+			r.outcomes[key] = rec
+		}
+		r.mu.Unlock()
+
+		rec.once.Do(func() { rec.outcome = r.process(rv, secret, f) })
+		setMetadata(f, "revocation", rec.outcome)
+		return
+	}
+}
+
+// process runs the check and the revocation for a secret seen for the first time.
+func (r *autoRevoker) process(rv revoker, secret string, f *finding) string {
+	where := fmt.Sprintf("%s %s:%d", shortHash(f.Commit), f.File, f.Line)
+	live, detail, err := rv.check(secret)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Go analyzer: auto-revoke: %s: checking %s secret at %s: %v\n", rv.name(), f.RuleID, where, err)
+		return revocationFailed
+	case !live:
+		return revocationNotLive
+	case !r.confirm:
+		fmt.Fprintf(os.Stderr, "Go analyzer: auto-revoke: %s: would revoke %s (found at %s); pass --auto-revoke-confirm to revoke\n", rv.name(), detail, where)
+		return revocationWouldRevoke
+	}
+	if err := rv.revoke(secret); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: auto-revoke: %s: revoking %s: %v\n", rv.name(), detail, err)
+		return revocationFailed
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: auto-revoke: %s: revoked %s (found at %s)\n", rv.name(), detail, where)
+	return revocationRevoked
+}
+
+// shortHash abbreviates a commit hash for log lines.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// githubRevoker handles GitHub tokens.
+type githubRevoker struct {
+	client *http.Client
+	api    string // REST API root, from GITHUB_API_URL for GitHub Enterprise
+}
+
+func (githubRevoker) name() string { return "github" }
+
+func (githubRevoker) supports(f *finding) bool {
+	for _, prefix := range []string{"ghp_", "gho_", "ghu_", "ghs_", "github_pat_"} {
+		if strings.HasPrefix(strings.Trim(f.Match, `"'`), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g githubRevoker) check(token string) (bool, string, error) {
+	req, err := http.NewRequest("GET", g.api+"/user", nil)
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var user struct {
+			Login string `json:"login"`
+		}
+		json.NewDecoder(resp.Body).Decode(&user)
+		return true, "GitHub token of " + orDefault(user.Login, "an installation"), nil
+	case http.StatusUnauthorized:
+		return false, "", nil
+	}
+	return false, "", fmt.Errorf("GET /user: HTTP %d", resp.StatusCode)
+}
+
+func (g githubRevoker) revoke(token string) error {
+	body, _ := json.Marshal(map[string][]string{"credentials": {token}})
+	req, err := http.NewRequest("POST", g.api+"/credentials/revoke", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("POST /credentials/revoke: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// awsRevoker handles long-term AWS access keys of the current account.
+type awsRevoker struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	users map[string]string // Access key ID -> owning IAM user, from check
+}
+
+func (*awsRevoker) name() string { return "aws" }
+
+func (*awsRevoker) supports(f *finding) bool {
+	match := strings.Trim(f.Match, `"'`)
+	return strings.HasPrefix(match, "AKIA") && len(match) == 20
+}
+
+func (a *awsRevoker) check(keyID string) (bool, string, error) {
+	var lastUsed struct {
+		UserName string `xml:"GetAccessKeyLastUsedResult>UserName"`
+	}
+	if err := a.call(url.Values{"Action": {"GetAccessKeyLastUsed"}, "AccessKeyId": {keyID}}, &lastUsed); err != nil {
+		if apiErr, ok := err.(*awsAPIError); ok && (apiErr.Code == "AccessDenied" || apiErr.Code == "NoSuchEntity") {
+			return false, "", nil // Not a key of this account
+		}
+		return false, "", err
+	}
+	if lastUsed.UserName == "" {
+		return false, "", nil
+	}
+	var keys struct {
+		Members []struct {
+			AccessKeyID string `xml:"AccessKeyId"`
+			Status      string `xml:"Status"`
+		} `xml:"ListAccessKeysResult>AccessKeyMetadata>member"`
+	}
+	if err := a.call(url.Values{"Action": {"ListAccessKeys"}, "UserName": {lastUsed.UserName}}, &keys); err != nil {
+		return false, "", err
+	}
+	for _, k := range keys.Members {
+		if k.AccessKeyID == keyID && k.Status == "Active" {
+			a.mu.Lock()
+			if a.users == nil {
+				a.users = make(map[string]string)
+			}
+			a.users[keyID] = lastUsed.UserName
+			a.mu.Unlock()
+			return true, "AWS access key " + keyID + " of IAM user " + lastUsed.UserName, nil
+		}
+	}
+	return false, "", nil
+}
+
+func (a *awsRevoker) revoke(keyID string) error {
+	a.mu.Lock()
+	user := a.users[keyID]
+	a.mu.Unlock()
+	return a.call(url.Values{
+		"Action":      {"UpdateAccessKey"},
+		"AccessKeyId": {keyID},
+		"UserName":    {user},
+		"Status":      {"Inactive"},
+	}, nil)
+}
+
+// awsAPIError is an error response of an AWS query API.
+type awsAPIError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (e *awsAPIError) Error() string { return e.Code + ": " + e.Message }
+
+// call invokes an IAM query API action and decodes the XML response into out (if not nil).
+func (a *awsRevoker) call(params url.Values, out interface{}) error {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return err
+	}
+	params.Set("Version", "2010-05-08")
+	req, err := http.NewRequest("POST", "https://iam.amazonaws.com/", strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := signAWSRequest(req, creds, "us-east-1", "iam", time.Now()); err != nil {
+		return err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &awsAPIError{}
+		if xml.Unmarshal(body, apiErr) != nil || apiErr.Code == "" {
+			return fmt.Errorf("%s: HTTP %d", params.Get("Action"), resp.StatusCode)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return xml.Unmarshal(body, out)
+}
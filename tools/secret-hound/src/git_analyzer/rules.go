@@ -0,0 +1,266 @@
+/**
+ * @file rules.go
+ * @brief Loading of the rules file and file-type aware scanning profiles.
+ *
+ * The rules file is either a plain JSON array of rules or an object of the form
+ * {"rules": [...], "profiles": [...], "overrides": {...}}. The core scanner only reads the rules;
+ * the analyzer reads the profiles and applies them to every finding based on
+ * the path of the file it came from. The first profile whose paths match wins.
+ *
+ * A profile may restrict the rules that apply ("rules"), drop noisy rules
+ * ("exclude_rules"), require a higher entropy for entropy-scored findings
+ * ("min_entropy"), and override the reported confidence ("confidence").
+ *
+ * Overrides apply to every path, keyed by rule id, and cover the rules of the
+ * core and the native engine as well as the native detectors:
+ *   "overrides": {"GENERIC_HIGH_ENTROPY": {"severity": "low"}, "JWT": {"disabled": true}}
+ * --disable-rule and --rule-severity add overrides from the command line, e.g.
+ * to downgrade a noisy rule in a documentation repository's CI job.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * @struct ruleDef
+ * @brief One detection rule as defined in the rules file.
+ */
+type ruleDef struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	Regex       string  `json:"regex"`
+	Confidence  string  `json:"confidence"`
+	MinEntropy  float64 `json:"min_entropy"`
+}
+
+/**
+ * @struct scanProfile
+ * @brief Rule subset and thresholds applied to files matching some paths.
+ */
+type scanProfile struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Paths        []string `json:"paths"`
+	Rules        []string `json:"rules"`
+	ExcludeRules []string `json:"exclude_rules"`
+	MinEntropy   float64  `json:"min_entropy"`
+	Confidence   string   `json:"confidence"`
+}
+
+/**
+ * @struct ruleOverride
+ * @brief Disables a rule or replaces its default severity everywhere.
+ */
+type ruleOverride struct {
+	Disabled bool   `json:"disabled,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+/**
+ * @struct ruleSet
+ * @brief The parsed content of a rules file.
+ */
+type ruleSet struct {
+	path      string
+	Rules     []ruleDef               `json:"rules"`
+	Profiles  []scanProfile           `json:"profiles"`
+	Overrides map[string]ruleOverride `json:"overrides,omitempty"`
+}
+
+/**
+ * @brief Returns the default rules file shipped next to the core scanner.
+ * The core lives in <tool root>/bin, and the rules in <tool root>/rules.
+ * @param houndCorePath The path to the core scanner executable.
+ * @return The path of rules/default.json relative to the core's tool root.
+ */
+func defaultRulesPath(houndCorePath string) string {
+	if resolved, err := filepath.EvalSymlinks(houndCorePath); err == nil {
+		houndCorePath = resolved
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(houndCorePath)), "rules", "default.json")
+}
+
+/**
+ * @brief Reads and parses a rules file in either supported layout.
+ * @param path The rules file to load.
+ * @return The parsed rule set and an error if the file is unreadable or invalid.
+ */
+func loadRuleSet(path string) (*ruleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRuleSet(data, path)
+}
+
+/**
+ * @brief Parses rules file content in either supported layout.
+ * @param data The JSON content.
+ * @param path The file name, used in error messages.
+ * @return The parsed rule set and an error if the content is invalid.
+ */
+func parseRuleSet(data []byte, path string) (*ruleSet, error) {
+	set := &ruleSet{path: path}
+	if err := json.Unmarshal(data, &set.Rules); err == nil {
+		return set, nil
+	}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for id, o := range set.Overrides {
+		if _, ok := severityRanks[o.Severity]; o.Severity != "" && !ok {
+			return nil, fmt.Errorf("parsing %s: override of %s: unknown severity %q", path, id, o.Severity)
+		}
+	}
+	return set, nil
+}
+
+/**
+ * @brief Adds the overrides given on the command line.
+ * @param disabled Rule ids to disable (--disable-rule).
+ * @param severities "<rule id>=<severity>" pairs (--rule-severity).
+ * @return An error for a malformed pair or an unknown severity.
+ */
+func (s *ruleSet) addOverrides(disabled, severities []string) error {
+	if s.Overrides == nil && len(disabled)+len(severities) > 0 {
+		s.Overrides = make(map[string]ruleOverride)
+	}
+	for _, id := range disabled {
+		o := s.Overrides[id]
+		o.Disabled = true
+		s.Overrides[id] = o
+	}
+	for _, pair := range severities {
+		id, severity, ok := strings.Cut(pair, "=")
+		if !ok || id == "" {
+			return fmt.Errorf("--rule-severity: want <rule id>=<severity>, got %q", pair)
+		}
+		severity = strings.ToLower(severity)
+		if _, known := severityRanks[severity]; !known {
+			return fmt.Errorf("--rule-severity: unknown severity %q (expected info, low, medium, high or critical)", severity)
+		}
+		o := s.Overrides[id]
+		o.Severity = severity
+		s.Overrides[id] = o
+	}
+	return nil
+}
+
+/**
+ * @brief Reports whether the set lacks, or disables, a rule of the built-in rules.
+ * A custom --rules file or bundle may add rules; dropping one narrows detection.
+ */
+func (s *ruleSet) reduced() bool {
+	if s == nil {
+		return false
+	}
+	defaults, err := embeddedRuleSet()
+	if err != nil {
+		return false
+	}
+	ids := make(map[string]bool, len(s.Rules))
+	for _, r := range s.Rules {
+		ids[r.ID] = !s.Overrides[r.ID].Disabled
+	}
+	for _, r := range defaults.Rules {
+		if !ids[r.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Applies the override of a finding's rule.
+ * @param f The finding; its severity may be replaced.
+ * @return False if the rule is disabled.
+ */
+func (s *ruleSet) applyOverride(f *finding) bool {
+	if s == nil {
+		return true
+	}
+	o, ok := s.Overrides[f.RuleID]
+	if !ok {
+		return true
+	}
+	if o.Severity != "" {
+		f.Severity = o.Severity
+	}
+	return !o.Disabled
+}
+
+/**
+ * @brief Finds the profile that applies to a repository path.
+ * @param path The repository-relative path of the scanned file.
+ * @return The first matching profile, or nil if none applies.
+ */
+func (s *ruleSet) profileFor(path string) *scanProfile {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Profiles {
+		if matchAnyGlob(s.Profiles[i].Paths, path) {
+			return &s.Profiles[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * @brief Applies a profile to a finding.
+ * @param f The finding to check; its confidence may be overridden.
+ * @return False if the profile suppresses the finding.
+ */
+func (p *scanProfile) apply(f *finding) bool {
+	if p == nil {
+		return true
+	}
+	if len(p.Rules) > 0 && !containsString(p.Rules, f.RuleID) {
+		return false
+	}
+	if containsString(p.ExcludeRules, f.RuleID) {
+		return false
+	}
+	if p.MinEntropy > 0 && f.Entropy > 0 && f.Entropy < p.MinEntropy {
+		return false
+	}
+	if p.Confidence != "" {
+		f.Confidence = p.Confidence
+	}
+	return true
+}
+
+/**
+ * @brief Sets a finding's confidence from its rule definition if the core did not report one.
+ * @param f The finding to complete.
+ */
+func (s *ruleSet) fillConfidence(f *finding) {
+	if s == nil || f.Confidence != "" {
+		return
+	}
+	for _, rule := range s.Rules {
+		if rule.ID == f.RuleID {
+			f.Confidence = rule.Confidence
+			return
+		}
+	}
+}
+
+/**
+ * @brief Reports whether a slice contains a string.
+ */
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRules writes a rules file and returns its path.
+func writeRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRuleSetLayouts(t *testing.T) {
+	plain, err := loadRuleSet(writeRules(t, `[{"id": "A", "regex": "a", "confidence": "High"}]`))
+	if err != nil || len(plain.Rules) != 1 || len(plain.Profiles) != 0 {
+		t.Errorf("plain array: %+v, %v", plain, err)
+	}
+	withProfiles, err := loadRuleSet(writeRules(t, `{"rules": [{"id": "A"}, {"id": "B"}], "profiles": [{"name": "tests", "paths": ["test/"]}]}`))
+	if err != nil || len(withProfiles.Rules) != 2 || withProfiles.Profiles[0].Name != "tests" {
+		t.Errorf("object: %+v, %v", withProfiles, err)
+	}
+	if _, err := loadRuleSet(writeRules(t, `{"rules": 3}`)); err == nil {
+		t.Error("an invalid rules file was accepted")
+	}
+}
+
+func TestShippedRulesLoad(t *testing.T) {
+	set, err := loadRuleSet(filepath.Join("..", "..", "rules", "default.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Rules) == 0 {
+		t.Error("rules/default.json has no rules")
+	}
+}
+
+func TestScanProfiles(t *testing.T) {
+	set, err := loadRuleSet(writeRules(t, `{
+		"rules": [{"id": "AWS_KEY", "confidence": "High"}, {"id": "GENERIC", "confidence": "Low"}],
+		"profiles": [
+			{"name": "tests", "paths": ["test/", "*_test.go"], "exclude_rules": ["GENERIC"], "confidence": "Low"},
+			{"name": "configs", "paths": ["*.env"], "rules": ["AWS_KEY"], "min_entropy": 3.5},
+			{"name": "shadowed", "paths": ["test/"]}
+		]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := set.profileFor("test/fixtures/a.env"); p == nil || p.Name != "tests" {
+		t.Errorf("the first matching profile should win, got %+v", p)
+	}
+	if p := set.profileFor("src/main.go"); p != nil {
+		t.Errorf("profile for an unmatched path: %+v", p)
+	}
+
+	tests, configs := set.profileFor("pkg/a_test.go"), set.profileFor("prod.env")
+	for _, tc := range []struct {
+		profile        *scanProfile
+		f              finding
+		keep           bool
+		wantConfidence string
+	}{
+		{tests, finding{RuleID: "GENERIC"}, false, ""},
+		{tests, finding{RuleID: "AWS_KEY", Confidence: "High"}, true, "Low"},
+		{configs, finding{RuleID: "GENERIC"}, false, ""},
+		{configs, finding{RuleID: "AWS_KEY", Entropy: 3.0}, false, ""},
+		{configs, finding{RuleID: "AWS_KEY", Entropy: 4.0, Confidence: "High"}, true, "High"},
+		{configs, finding{RuleID: "AWS_KEY", Confidence: "High"}, true, "High"}, // No entropy score
+		{nil, finding{RuleID: "GENERIC", Confidence: "Low"}, true, "Low"},
+	} {
+		f := tc.f
+		if keep := tc.profile.apply(&f); keep != tc.keep || keep && f.Confidence != tc.wantConfidence {
+			t.Errorf("%+v through %v: keep=%v confidence=%q", tc.f, tc.profile, keep, f.Confidence)
+		}
+	}
+
+	f := &finding{RuleID: "GENERIC"}
+	set.fillConfidence(f)
+	if f.Confidence != "Low" {
+		t.Errorf("confidence from the rule definition: %q", f.Confidence)
+	}
+}
+
+func TestRuleOverrides(t *testing.T) {
+	set, err := loadRuleSet(writeRules(t, `{"rules": [{"id": "A"}], "overrides": {"JWT": {"disabled": true}, "A": {"severity": "low"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.addOverrides([]string{"PEM"}, []string{"GENERIC_HIGH_ENTROPY=INFO", "A=critical"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		rule, severity string
+		kept           bool
+	}{
+		{"A", "critical", true}, // The command line wins over the file
+		{"JWT", "high", false},
+		{"PEM", "high", false},
+		{"GENERIC_HIGH_ENTROPY", "info", true},
+		{"OTHER", "high", true},
+	} {
+		f := &finding{RuleID: tc.rule, Severity: "high"}
+		if kept := set.applyOverride(f); kept != tc.kept || f.Severity != tc.severity {
+			t.Errorf("%s: kept %v with severity %s, want %v and %s", tc.rule, kept, f.Severity, tc.kept, tc.severity)
+		}
+	}
+	if !(*ruleSet)(nil).applyOverride(&finding{RuleID: "A"}) {
+		t.Error("a nil rule set dropped a finding")
+	}
+}
+
+func TestInvalidRuleOverrides(t *testing.T) {
+	if _, err := loadRuleSet(writeRules(t, `{"rules": [], "overrides": {"A": {"severity": "urgent"}}}`)); err == nil {
+		t.Error("an unknown severity in the rules file was accepted")
+	}
+	for _, pair := range []string{"A", "=low", "A=urgent"} {
+		if err := (&ruleSet{}).addOverrides(nil, []string{pair}); err == nil {
+			t.Errorf("--rule-severity %s was accepted", pair)
+		}
+	}
+}
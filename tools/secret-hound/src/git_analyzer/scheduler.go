@@ -0,0 +1,167 @@
+/**
+ * @file scheduler.go
+ * @brief Two-level scheduling of scan work across many repositories.
+ *
+ * A fleet sweep runs several repositories at once. Every scan needs a token
+ * from one global pool (the worker budget of the whole process), and every
+ * repository is additionally capped to its own number of workers. When tokens
+ * are scarce they are handed out by repository priority, so one enormous
+ * monorepo cannot monopolize the sweep while small repositories queue behind it.
+ */
+
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * @struct scheduler
+ * @brief A counting semaphore whose waiters are served by priority, then FIFO.
+ */
+type scheduler struct {
+	mu        sync.Mutex
+	capacity  int         // Total worker tokens; changed by the autoscaler
+	available int         // Free global worker tokens (negative while shrinking)
+	waiters   waiterQueue // Goroutines blocked in acquire
+	seq       uint64      // Arrival counter used to keep equal priorities FIFO
+}
+
+/**
+ * @struct waiter
+ * @brief A goroutine blocked waiting for a worker token.
+ */
+type waiter struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+// waiterQueue is a max-heap on priority with FIFO ordering for ties.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q waiterQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *waiterQueue) Push(x interface{}) { *q = append(*q, x.(*waiter)) }
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return w
+}
+
+/**
+ * @brief Creates a scheduler with a fixed number of global worker tokens.
+ * @param workers The global worker budget (at least 1).
+ * @return A pointer to the initialized scheduler.
+ */
+func newScheduler(workers int) *scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	return &scheduler{capacity: workers, available: workers}
+}
+
+/**
+ * @brief Takes a worker token, blocking until one is free.
+ * @param priority Higher values are served first when several scans are waiting.
+ */
+func (s *scheduler) acquire(priority int) {
+	s.mu.Lock()
+	if s.available > 0 && len(s.waiters) == 0 {
+		s.available--
+		s.mu.Unlock()
+		return
+	}
+	w := &waiter{priority: priority, seq: s.seq, ready: make(chan struct{})}
+	s.seq++
+	heap.Push(&s.waiters, w)
+	s.mu.Unlock()
+	<-w.ready
+}
+
+/**
+ * @brief Returns a worker token, handing it directly to the best waiter if any.
+ */
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.available < 0 {
+		s.available++ // Retired by a shrink
+		return
+	}
+	if len(s.waiters) > 0 {
+		w := heap.Pop(&s.waiters).(*waiter)
+		close(w.ready)
+		return
+	}
+	s.available++
+}
+
+/**
+ * @brief Changes the number of worker tokens.
+ * Growing wakes waiters at once; shrinking takes effect as busy workers release.
+ * @param workers The new global worker budget (at least 1).
+ */
+func (s *scheduler) resize(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.available += workers - s.capacity
+	s.capacity = workers
+	for s.available > 0 && len(s.waiters) > 0 {
+		s.available--
+		close(heap.Pop(&s.waiters).(*waiter).ready)
+	}
+}
+
+/**
+ * @brief Returns the number of tokens and how many of them are in use.
+ * Right after a shrink, busy can exceed capacity until workers release.
+ */
+func (s *scheduler) usage() (capacity, busy int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capacity, s.capacity - s.available
+}
+
+/**
+ * @brief Parses a remote specification of the form "URL [priority=N] [workers=N]".
+ * @param spec The specification from --remote or a line of --remotes-file.
+ * @return The repository to sweep (label and per-repo limits set) and an error if malformed.
+ */
+func parseRemoteSpec(spec string) (*repository, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty remote specification")
+	}
+	repo := &repository{label: fields[0]}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		number, err := strconv.Atoi(value)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid option %q for remote %s", field, repo.label)
+		}
+		switch key {
+		case "priority":
+			repo.priority = number
+		case "workers":
+			repo.workers = number
+		default:
+			return nil, fmt.Errorf("unknown option %q for remote %s", key, repo.label)
+		}
+	}
+	return repo, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForWaiters blocks until n goroutines are queued in the scheduler.
+func waitForWaiters(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		queued := len(s.waiters)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("%d waiters never queued", n)
+}
+
+func TestSchedulerServesWaitersByPriorityThenArrival(t *testing.T) {
+	s := newScheduler(1)
+	s.acquire(0) // Hold the only token while the others queue up
+
+	order := make(chan string, 4)
+	for i, w := range []struct {
+		name     string
+		priority int
+	}{{"low", 0}, {"high-1", 5}, {"mid", 2}, {"high-2", 5}} {
+		go func(name string, priority int) {
+			s.acquire(priority)
+			order <- name
+			s.release()
+		}(w.name, w.priority)
+		waitForWaiters(t, s, i+1) // Fixes the arrival order
+	}
+	s.release()
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, <-order)
+	}
+	if strings.Join(got, " ") != "high-1 high-2 mid low" {
+		t.Errorf("served %v, want high-1 high-2 mid low", got)
+	}
+}
+
+func TestSchedulerLimitsConcurrency(t *testing.T) {
+	s := newScheduler(2)
+	s.acquire(0)
+	s.acquire(0)
+	third := make(chan struct{})
+	go func() {
+		s.acquire(0)
+		close(third)
+	}()
+	select {
+	case <-third:
+		t.Fatal("a third token was handed out with a budget of 2")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.release()
+	select {
+	case <-third:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the released token was not handed to the waiter")
+	}
+	if newScheduler(0).available != 1 {
+		t.Error("a budget below 1 was not raised to 1")
+	}
+}
+
+func TestParseRemoteSpec(t *testing.T) {
+	repo, err := parseRemoteSpec("https://git.example.com/mono.git  priority=10 workers=4")
+	if err != nil || repo.label != "https://git.example.com/mono.git" || repo.priority != 10 || repo.workers != 4 {
+		t.Errorf("parseRemoteSpec = %+v, %v", repo, err)
+	}
+	for _, spec := range []string{"", "url workers", "url workers=x", "url depth=3"} {
+		if _, err := parseRemoteSpec(spec); err == nil {
+			t.Errorf("parseRemoteSpec(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestSchedulerResize(t *testing.T) {
+	s := newScheduler(1)
+	s.acquire(0)
+	acquired := make(chan bool)
+	go func() {
+		s.acquire(0)
+		acquired <- true
+	}()
+	waitForWaiters(t, s, 1)
+	s.resize(2) // Growing wakes the waiter at once
+	<-acquired
+	if capacity, busy := s.usage(); capacity != 2 || busy != 2 {
+		t.Errorf("after growing: capacity %d, busy %d", capacity, busy)
+	}
+
+	s.resize(1) // Shrinking retires a token as a worker releases
+	if capacity, busy := s.usage(); capacity != 1 || busy != 2 {
+		t.Errorf("right after shrinking: capacity %d, busy %d", capacity, busy)
+	}
+	s.release()
+	s.release()
+	if capacity, busy := s.usage(); capacity != 1 || busy != 0 {
+		t.Errorf("after releasing: capacity %d, busy %d", capacity, busy)
+	}
+}
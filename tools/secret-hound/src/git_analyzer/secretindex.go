@@ -0,0 +1,571 @@
+/**
+ * @file secretindex.go
+ * @brief Fleet-wide index of secrets by fingerprint (--secret-index) and
+ *        the `report incidents` subcommand built on it.
+ *
+ * A credential pasted into a shared template or copied between services
+ * leaks in many repositories at once, and a sweep of an organization reports
+ * it once per repository, to different owners, as unrelated findings. The
+ * secret index keys every secret found by its fingerprint (a hash of rule and
+ * value, see trend.go, so the index holds no secret material) and lists all
+ * of its locations across every repository ever scanned into it:
+ *   - each finding carries metadata incident (the fingerprint) and
+ *     incident_repositories (how many repositories the index knows it in,
+ *     including earlier runs), so consumers can open one incident per secret;
+ *   - at the end of a run, the locations of the repositories it scanned are
+ *     replaced by what this run found, so fixed secrets drop out, while other
+ *     repositories' locations are kept. Only a complete scan of the whole
+ *     history with every rule and detector replaces locations: runs scoped
+ *     with --shard, --base, --staged, --hook, --snapshot, --between-tags,
+ *     --worker or --first-parent, runs whose walk skipped the commits of an
+ *     ignore-revs file, runs narrowed by --disable-rule, a --rules file
+ *     lacking built-in rules, --detectors, --string-literals, --prefilter
+ *     aggressive, --decode-min-length 0, --transcode=false or a policy that
+ *     suppresses findings (see scanScope), and runs cut short by
+ *     --time-budget, mark their findings but leave the index as it was. A complete run keeps the locations in
+ *     blobs it left out (vendored, generated, unreadable, or finished before
+ *     a --queue-file resume) and all locations of a repository whose walk
+ *     stopped short of its first commit (--depth, shallow clones);
+ *   - `git_analyzer report incidents --secret-index <file>` lists the secrets
+ *     found in at least --min-repositories repositories with every location.
+ *
+ * Every secret also has a status: new, triaged, false-positive or
+ * remediated. Secrets enter the index as new, `git_analyzer triage` sets the
+ * status, and a new or triaged secret left without locations is remediated
+ * automatically. Remediated secrets stay in the index without locations, so
+ * a later run that finds one again re-opens it as new and marks its findings
+ * with metadata incident_event=regression (a secret never seen before gets
+ * incident_event=new). Findings also carry incident_status.
+ * Sweeps on several runners share one index through --state-url. Runs
+ * updating the same index must not overlap: the last one to save wins.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+/**
+ * @struct indexLocation
+ * @brief One place an indexed secret was found.
+ */
+type indexLocation struct {
+	Repository string `json:"repository"`
+	Commit     string `json:"commit"`
+	Path       string `json:"path"`
+	Line       int    `json:"line"`
+}
+
+/**
+ * @struct secretIncident
+ * @brief Everything known about one secret across the fleet.
+ */
+type secretIncident struct {
+	Fingerprint string          `json:"fingerprint"`
+	RuleID      string          `json:"rule_id"`
+	Severity    string          `json:"severity,omitempty"` // Highest severity seen
+	FirstSeen   string          `json:"first_seen"`         // RFC 3339, UTC
+	LastSeen    string          `json:"last_seen"`
+	Locations   []indexLocation `json:"locations"`
+
+	Status        string `json:"status,omitempty"` // One of the incident statuses ("" in older indexes = new)
+	StatusChanged string `json:"status_changed,omitempty"`
+	Note          string `json:"note,omitempty"`        // Why the status was set, from triage
+	Regressions   int    `json:"regressions,omitempty"` // Times the secret came back after remediation
+}
+
+// Incident statuses.
+const (
+	incidentNew           = "new"
+	incidentTriaged       = "triaged"
+	incidentFalsePositive = "false-positive"
+	incidentRemediated    = "remediated"
+)
+
+// incidentStatuses lists the statuses in lifecycle order.
+var incidentStatuses = []string{incidentNew, incidentTriaged, incidentFalsePositive, incidentRemediated}
+
+// status returns the incident's status, defaulting to new.
+func (i *secretIncident) status() string {
+	return orDefault(i.Status, incidentNew)
+}
+
+// setStatus changes the status and records when.
+func (i *secretIncident) setStatus(status, now string) {
+	i.Status, i.StatusChanged = status, now
+}
+
+// repositories returns the distinct repositories of an incident, sorted.
+func (i *secretIncident) repositories() []string {
+	seen := make(map[string]bool)
+	var repos []string
+	for _, loc := range i.Locations {
+		if !seen[loc.Repository] {
+			seen[loc.Repository] = true
+			repos = append(repos, loc.Repository)
+		}
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+/**
+ * @struct secretIndex
+ * @brief The index as loaded, and what this run found.
+ */
+type secretIndex struct {
+	path  string
+	now   string
+	scope string // Option limiting the run to part of the history or rules ("" = complete, see scanScope)
+
+	mu        sync.Mutex
+	incidents map[string]*secretIncident // By fingerprint, as loaded
+	names     map[string]string          // Repository label -> stable name (see repositoryName)
+	found     map[string]*secretIncident // By fingerprint, this run's locations only
+	skipped   map[string]bool            // Label, commit and path of the blobs this run left out
+	truncated map[string]bool            // Labels of the repositories whose history was not walked to the end
+	resolved  []string                   // Fingerprints save remediated
+}
+
+/**
+ * @brief Loads the secret index; a missing file is an empty index.
+ * @param path The --secret-index value ("" disables the index).
+ * @param scope The option limiting the run to part of the history or rules ("" = none).
+ * @return The index (nil if disabled), and an error if the file cannot be read.
+ */
+func loadSecretIndex(path, scope string) (*secretIndex, error) {
+	if path == "" {
+		return nil, nil
+	}
+	x := &secretIndex{path: path, now: time.Now().UTC().Format(time.RFC3339), scope: scope,
+		incidents: make(map[string]*secretIncident), names: make(map[string]string), found: make(map[string]*secretIncident),
+		skipped: make(map[string]bool), truncated: make(map[string]bool)}
+	incidents, err := readSecretIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, incident := range incidents {
+		x.incidents[incident.Fingerprint] = incident
+	}
+	return x, nil
+}
+
+// readSecretIndex reads the incidents of an index file (none if it does not exist).
+func readSecretIndex(path string) ([]*secretIncident, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var incidents []*secretIncident
+	if err := json.Unmarshal(data, &incidents); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return incidents, nil
+}
+
+/**
+ * @brief Registers a repository as scanned in this run, so its old locations are replaced.
+ */
+func (x *secretIndex) begin(repo *repository) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.names[repo.label]; !ok {
+		x.names[repo.label] = repositoryName(repo)
+	}
+}
+
+/**
+ * @brief Records a blob left out of the scan, so its old locations are kept.
+ */
+func (x *secretIndex) skip(blob fileBlob) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.skipped[blob.repo.label+"\x00"+blob.commit+"\x00"+blob.path] = true
+}
+
+/**
+ * @brief Records that a repository's walk did not reach its first commit, so
+ * none of its old locations are replaced.
+ */
+func (x *secretIndex) truncate(repo *repository) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.truncated[repo.label] = true
+}
+
+/**
+ * @brief Adds a finding's location and marks the finding with its incident.
+ * @param f The finding, before it is written.
+ */
+func (x *secretIndex) record(f *finding) {
+	if x == nil {
+		return
+	}
+	fingerprint := secretFingerprint(f)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	name := orDefault(x.names[f.Repository], f.Repository)
+	status, event := incidentNew, ""
+	switch known := x.incidents[fingerprint]; {
+	case known == nil:
+		event = "new"
+	case known.status() == incidentRemediated:
+		event = "regression"
+	default:
+		status = known.status()
+	}
+	incident := x.found[fingerprint]
+	if incident == nil {
+		incident = &secretIncident{Fingerprint: fingerprint, RuleID: f.RuleID, FirstSeen: x.now, LastSeen: x.now}
+		x.found[fingerprint] = incident
+	}
+	if incident.Severity == "" || severityRanks[findingSeverity(f)] > severityRanks[incident.Severity] {
+		incident.Severity = findingSeverity(f)
+	}
+	incident.Locations = append(incident.Locations, indexLocation{Repository: name, Commit: f.Commit, Path: f.OriginalPath, Line: f.Line})
+
+	repos := make(map[string]bool)
+	for _, loc := range incident.Locations {
+		repos[loc.Repository] = true
+	}
+	if known := x.incidents[fingerprint]; known != nil {
+		for _, loc := range known.Locations {
+			if !x.replaced(loc) {
+				repos[loc.Repository] = true
+			}
+		}
+	}
+	setMetadata(f, "incident", fingerprint)
+	setMetadata(f, "incident_repositories", strconv.Itoa(len(repos)))
+	setMetadata(f, "incident_status", status)
+	setMetadata(f, "incident_event", event)
+}
+
+/**
+ * @brief Returns the status of a secret as of the start of the run.
+ * @return The status, or "" for a secret the index does not know (or no index).
+ */
+func (x *secretIndex) status(fingerprint string) string {
+	if x == nil {
+		return ""
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if known := x.incidents[fingerprint]; known != nil {
+		return known.status()
+	}
+	return ""
+}
+
+// narrow keeps the index as it was, like a run scoped from the start.
+func (x *secretIndex) narrow(scope string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.scope == "" {
+		x.scope = scope
+	}
+}
+
+// remediated returns the fingerprints of the secrets the run remediated, once saved.
+func (x *secretIndex) remediated() []string {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.resolved
+}
+
+// replaced reports whether this run's findings replace a recorded location; the caller holds mu.
+func (x *secretIndex) replaced(loc indexLocation) bool {
+	if x.scope != "" {
+		return false
+	}
+	for label, name := range x.names {
+		if name == loc.Repository {
+			return !x.truncated[label] && !x.skipped[label+"\x00"+loc.Commit+"\x00"+loc.Path]
+		}
+	}
+	return false // Not scanned by this run
+}
+
+/**
+ * @brief Merges this run into the index and writes it.
+ * Locations in repositories this run scanned are replaced by what it found;
+ * a new or triaged secret left without locations is remediated, and a
+ * remediated secret found again is re-opened. A run narrowed by any option
+ * scanScope names, or an incomplete run, leaves the index unchanged.
+ * @param complete Whether the run scanned every blob it planned to.
+ * @return An error if the file could not be written.
+ */
+func (x *secretIndex) save(complete bool) error {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.scope != "" || !complete {
+		fmt.Fprintf(os.Stderr, "Go analyzer: %s narrowed the scan; --secret-index left unchanged\n", orDefault(x.scope, "--time-budget"))
+		return nil
+	}
+	merged := make(map[string]*secretIncident)
+	reopened := 0
+	for fingerprint, known := range x.incidents {
+		var kept []indexLocation
+		for _, loc := range known.Locations {
+			if !x.replaced(loc) {
+				kept = append(kept, loc)
+			}
+		}
+		known.Locations = kept
+		if status := known.status(); len(kept) == 0 && x.found[fingerprint] == nil && (status == incidentNew || status == incidentTriaged) {
+			known.setStatus(incidentRemediated, x.now)
+			x.resolved = append(x.resolved, fingerprint)
+		}
+		merged[fingerprint] = known
+	}
+	for fingerprint, found := range x.found {
+		incident := merged[fingerprint]
+		if incident == nil {
+			incident = found
+			incident.setStatus(incidentNew, x.now)
+			merged[fingerprint] = incident
+			continue
+		}
+		for _, loc := range found.Locations {
+			if !containsLocation(incident.Locations, loc) { // Kept from a repository not walked to the end
+				incident.Locations = append(incident.Locations, loc)
+			}
+		}
+		incident.LastSeen = x.now
+		if severityRanks[found.Severity] > severityRanks[incident.Severity] {
+			incident.Severity = found.Severity
+		}
+		if incident.status() == incidentRemediated {
+			incident.setStatus(incidentNew, x.now)
+			incident.Regressions++
+			reopened++
+		}
+	}
+	sort.Strings(x.resolved)
+	if len(x.resolved)+reopened > 0 {
+		fmt.Fprintf(os.Stderr, "Go analyzer: secret index: %d secret(s) remediated, %d re-opened after remediation\n", len(x.resolved), reopened)
+	}
+
+	incidents := make([]*secretIncident, 0, len(merged))
+	for _, incident := range merged {
+		incidents = append(incidents, incident)
+	}
+	return writeSecretIndex(x.path, incidents)
+}
+
+// writeSecretIndex atomically replaces an index file, in a stable order.
+func writeSecretIndex(path string, incidents []*secretIncident) error {
+	for _, incident := range incidents {
+		sortLocations(incident.Locations)
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].Fingerprint < incidents[j].Fingerprint })
+	data, err := json.MarshalIndent(incidents, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// containsLocation reports whether a location is in a list.
+func containsLocation(locations []indexLocation, loc indexLocation) bool {
+	for _, l := range locations {
+		if l == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// sortLocations orders locations by repository, path, line and commit.
+func sortLocations(locations []indexLocation) {
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		switch {
+		case a.Repository != b.Repository:
+			return a.Repository < b.Repository
+		case a.Path != b.Path:
+			return a.Path < b.Path
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		}
+		return a.Commit < b.Commit
+	})
+}
+
+/**
+ * @brief Implements `git_analyzer report incidents`.
+ * @param args The arguments following "incidents".
+ * @return The process exit code.
+ */
+func runIncidentReport(args []string) int {
+	fs := flag.NewFlagSet("report incidents", flag.ExitOnError)
+	indexFile := fs.String("secret-index", "", "Secret index written by scans with --secret-index")
+	minRepos := fs.Int("min-repositories", 2, "Only report secrets found in at least this many repositories (0 includes remediated secrets)")
+	status := fs.String("status", "", "Only report secrets with one of these comma-separated statuses (new, triaged, false-positive, remediated)")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer report incidents --secret-index <file> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *indexFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --secret-index is required")
+		return 2
+	}
+	incidents, err := readSecretIndex(*indexFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	statuses := make(map[string]bool)
+	for _, name := range strings.Split(*status, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !validIncidentStatus(name) {
+			fmt.Fprintf(os.Stderr, "Error: --status: unknown status %q (want %s)\n", name, strings.Join(incidentStatuses, ", "))
+			return 2
+		}
+		statuses[name] = true
+	}
+	selected := incidents[:0]
+	for _, incident := range incidents {
+		if len(statuses) > 0 && !statuses[incident.status()] {
+			continue
+		}
+		if len(incident.repositories()) >= *minRepos {
+			selected = append(selected, incident)
+		}
+	}
+	// Most widespread first.
+	sort.SliceStable(selected, func(i, j int) bool {
+		return len(selected[i].repositories()) > len(selected[j].repositories())
+	})
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, incident := range selected {
+			encoder.Encode(incident)
+		}
+	case "text":
+		printIncidents(os.Stdout, selected)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --format: expected text or json, got %q\n", *format)
+		return 2
+	}
+	return 0
+}
+
+/**
+ * @brief Prints each incident with its locations.
+ */
+func printIncidents(w io.Writer, incidents []*secretIncident) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, incident := range incidents {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d repositories\tfirst seen %s\n", incident.Fingerprint, incident.RuleID,
+			orDefault(incident.Severity, "-"), incident.status(), len(incident.repositories()), incident.FirstSeen)
+		for _, loc := range incident.Locations {
+			fmt.Fprintf(tw, "  %s\t%s:%d\t%s\n", loc.Repository, loc.Path, loc.Line, shortCommit(loc.Commit))
+		}
+	}
+	tw.Flush()
+}
+
+// validIncidentStatus reports whether a status is one of incidentStatuses.
+func validIncidentStatus(status string) bool {
+	for _, known := range incidentStatuses {
+		if status == known {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @brief Implements `git_analyzer triage`: sets the status of secrets in an index.
+ * @param args The arguments following "triage".
+ * @return The process exit code.
+ */
+func runTriage(args []string) int {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	indexFile := fs.String("secret-index", "", "Secret index written by scans with --secret-index")
+	status := fs.String("status", "", "New status: new, triaged, false-positive or remediated")
+	note := fs.String("note", "", "Why the status is set, e.g. the ticket or the reason it is a false positive")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer triage --secret-index <file> --status <status> [--note <text>] <fingerprint>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *indexFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if !validIncidentStatus(*status) {
+		fmt.Fprintf(os.Stderr, "Error: --status: unknown status %q (want %s)\n", *status, strings.Join(incidentStatuses, ", "))
+		return 2
+	}
+	incidents, err := readSecretIndex(*indexFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	byFingerprint := make(map[string]*secretIncident)
+	for _, incident := range incidents {
+		byFingerprint[incident.Fingerprint] = incident
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, fingerprint := range fs.Args() {
+		incident := byFingerprint[fingerprint]
+		if incident == nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: no such secret in %s\n", fingerprint, *indexFile)
+			return 1
+		}
+		incident.setStatus(*status, now)
+		incident.Note = *note
+	}
+	if err := writeSecretIndex(*indexFile, incidents); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
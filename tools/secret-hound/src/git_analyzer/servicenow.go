@@ -0,0 +1,244 @@
+/**
+ * @file servicenow.go
+ * @brief ServiceNow records for leaked secrets, kept in step by each run (--servicenow-url).
+ *
+ * Enterprises that track remediation in ServiceNow otherwise need middleware
+ * to turn findings into records. With --servicenow-url, the findings of a run
+ * are grouped by secret (see tracker.go) and written through the Table API to
+ * --servicenow-table when a complete run ends: Vulnerability Response's
+ * vulnerable items (sn_vul_vulnerable_item) by default, or any table
+ * extending task, such as incident. A record is found again by its
+ * correlation_id, secret-hound-<fingerprint>:
+ *   - secrets without an active record get one;
+ *   - existing records get their short description, description and mapped
+ *     fields rewritten with the current locations; an inactive (closed)
+ *     record whose secret came back is set to --servicenow-reopen-state, if
+ *     given, and otherwise a new record is created;
+ *   - with --secret-index, active records of secrets the run remediated are
+ *     set to --servicenow-resolve-state with a work note. A run narrowed by
+ *     --disable-rule, --first-parent or another option scanScope names is
+ *     not synchronized, so it closes nothing.
+ * --servicenow-field <field>=<template> sets any other column, e.g.
+ * assignment_group, or the vulnerability and configuration item references
+ * an instance's Vulnerability Response setup requires.
+ *
+ * Credentials come from SERVICENOW_USER and SERVICENOW_PASSWORD (basic
+ * authentication) or SERVICENOW_TOKEN (an OAuth access token). Failures are
+ * reported on stderr and do not fail the run.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceNowHTTPTimeout bounds each call to the ServiceNow API.
+const serviceNowHTTPTimeout = 60 * time.Second
+
+// serviceNowBatch is the most records looked up in one request.
+const serviceNowBatch = 50
+
+// serviceNowRecord is a record found by its correlation ID.
+type serviceNowRecord struct {
+	SysID  string `json:"sys_id"`
+	Number string `json:"number"`
+	Active string `json:"active"` // "true" or "false"
+}
+
+/**
+ * @struct serviceNowSink
+ * @brief Collects findings by secret and synchronizes them with a ServiceNow table.
+ */
+type serviceNowSink struct {
+	restAPI
+	table   string
+	fields  []templateField
+	resolve string // State of remediated secrets' records ("" = leave them)
+	reopen  string // State of closed records whose secret came back ("" = create a new record)
+	index   *secretIndex
+
+	groups secretGroups
+}
+
+/**
+ * @brief Validates the ServiceNow options.
+ * @param opts The parsed options.
+ * @param index The secret index (may be nil).
+ * @return The sink (nil without --servicenow-url), and an error for an invalid option.
+ */
+func newServiceNowSink(opts *options, index *secretIndex) (*serviceNowSink, error) {
+	if opts.servicenowURL == "" {
+		return nil, nil
+	}
+	if opts.servicenowTable == "" || strings.ContainsAny(opts.servicenowTable, "/?#") {
+		return nil, fmt.Errorf("--servicenow-table %q: want a table name", opts.servicenowTable)
+	}
+	s := &serviceNowSink{restAPI: restAPI{client: &http.Client{Timeout: serviceNowHTTPTimeout}, url: strings.TrimRight(opts.servicenowURL, "/")},
+		table: opts.servicenowTable, resolve: opts.servicenowResolve, reopen: opts.servicenowReopen, index: index}
+	var err error
+	if s.fields, err = parseTemplateFields("--servicenow-field", opts.servicenowFields); err != nil {
+		return nil, err
+	}
+	if user := os.Getenv("SERVICENOW_USER"); user != "" {
+		s.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+os.Getenv("SERVICENOW_PASSWORD")))
+	} else if token := os.Getenv("SERVICENOW_TOKEN"); token != "" {
+		s.auth = "Bearer " + token
+	}
+	return s, nil
+}
+
+/**
+ * @brief Adds a finding to the group of its secret.
+ * @param f The finding, before it is written (and possibly redacted).
+ */
+func (s *serviceNowSink) record(f *finding) {
+	if s != nil {
+		s.groups.record(f)
+	}
+}
+
+/**
+ * @brief Creates, updates and resolves the records of the run's secrets.
+ * Called once a complete run succeeded and the secret index was saved.
+ */
+func (s *serviceNowSink) sync() {
+	if s == nil {
+		return
+	}
+	groups := s.groups.finish(s.index)
+	resolved := s.index.remediated()
+	fingerprints := append([]string(nil), resolved...)
+	for _, group := range groups {
+		fingerprints = append(fingerprints, group.Fingerprint)
+	}
+
+	records, err := s.search(fingerprints)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: searching %s: %v\n", s.table, err)
+		return
+	}
+	created, updated, reopened, closed := 0, 0, 0, 0
+	for _, group := range groups {
+		fields, err := s.recordFields(group)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: %v\n", err)
+			continue
+		}
+		record, ok := records[group.Fingerprint]
+		if ok && record.Active == "false" && s.reopen == "" {
+			ok = false // Leave the closed record be and track the secret in a new one
+		}
+		if !ok {
+			fields["correlation_id"] = fingerprintLabel(group.Fingerprint)
+			fields["correlation_display"] = trackerLabel
+			if err := s.call("POST", "/api/now/table/"+s.table, fields, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: creating the record of %s: %v\n", group.Fingerprint, err)
+				continue
+			}
+			created++
+			continue
+		}
+		if record.Active == "false" {
+			fields["state"] = s.reopen
+			fields["work_notes"] = "Secret Hound found the secret again."
+		}
+		if err := s.call("PATCH", "/api/now/table/"+s.table+"/"+record.SysID, fields, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: updating %s: %v\n", record.Number, err)
+			continue
+		}
+		updated++
+		if record.Active == "false" {
+			reopened++
+		}
+	}
+	if s.resolve != "" {
+		for _, fingerprint := range resolved {
+			record, ok := records[fingerprint]
+			if !ok || record.Active == "false" {
+				continue
+			}
+			fields := map[string]interface{}{"state": s.resolve, "work_notes": "Secret Hound no longer finds the secret in any scanned repository."}
+			if err := s.call("PATCH", "/api/now/table/"+s.table+"/"+record.SysID, fields, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: resolving %s: %v\n", record.Number, err)
+			} else {
+				closed++
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Go analyzer: servicenow: %s: %d record(s) created, %d updated, %d reopened, %d resolved\n", s.table, created, updated, reopened, closed)
+}
+
+/**
+ * @brief Finds the records of secrets in the table.
+ * @return The records by fingerprint; an active record wins over closed ones.
+ */
+func (s *serviceNowSink) search(fingerprints []string) (map[string]serviceNowRecord, error) {
+	records := make(map[string]serviceNowRecord)
+	for start := 0; start < len(fingerprints); start += serviceNowBatch {
+		batch := fingerprints[start:minInt(start+serviceNowBatch, len(fingerprints))]
+		ids := make([]string, len(batch))
+		for i, fingerprint := range batch {
+			ids[i] = fingerprintLabel(fingerprint)
+		}
+		query := url.Values{
+			"sysparm_query":  {"correlation_idIN" + strings.Join(ids, ",") + "^ORDERBYDESCsys_created_on"},
+			"sysparm_fields": {"sys_id,number,active,correlation_id"},
+			"sysparm_limit":  {fmt.Sprint(4 * serviceNowBatch)},
+		}
+		var result struct {
+			Result []struct {
+				serviceNowRecord
+				CorrelationID string `json:"correlation_id"`
+			} `json:"result"`
+		}
+		if err := s.call("GET", "/api/now/table/"+s.table+"?"+query.Encode(), nil, &result); err != nil {
+			return nil, err
+		}
+		for _, record := range result.Result {
+			fingerprint := strings.TrimPrefix(record.CorrelationID, trackerLabel+"-")
+			if known, ok := records[fingerprint]; !ok || known.Active == "false" && record.Active == "true" {
+				records[fingerprint] = record.serviceNowRecord
+			}
+		}
+	}
+	return records, nil
+}
+
+/**
+ * @brief Builds the fields of a record that every sync rewrites.
+ * @return The fields, and an error if a --servicenow-field template fails.
+ */
+func (s *serviceNowSink) recordFields(group *secretGroup) (map[string]interface{}, error) {
+	fields := map[string]interface{}{"short_description": group.summary(), "description": serviceNowDescription(group)}
+	if err := applyTemplateFields(s.fields, group, fields); err != nil {
+		return nil, fmt.Errorf("--servicenow-field %v", err)
+	}
+	return fields, nil
+}
+
+// serviceNowDescription lists the locations of a secret in plain text.
+func serviceNowDescription(group *secretGroup) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Secret Hound found a %s secret (%s, severity %s) in %d location(s):\n\n", group.RuleID, group.Match, group.Severity, len(group.Locations))
+	for i, loc := range group.Locations {
+		if i == trackerMaxLocations {
+			fmt.Fprintf(&b, "... and %d more.\n", len(group.Locations)-i)
+			break
+		}
+		where := loc.Path
+		if loc.Repository != "" {
+			where = loc.Repository + ": " + where
+		}
+		fmt.Fprintf(&b, "- %s:%d (commit %s)\n", where, loc.Line, shortCommit(loc.Commit))
+	}
+	fmt.Fprintf(&b, "\nFingerprint: %s\n\n", group.Fingerprint)
+	b.WriteString("Rotate the secret first, then remove it from the repositories. This record is updated by every scan and resolved once no scanned repository contains the secret.")
+	return b.String()
+}
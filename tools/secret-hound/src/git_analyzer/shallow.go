@@ -0,0 +1,127 @@
+/**
+ * @file shallow.go
+ * @brief Detection of shallow clones and optional on-demand deepening.
+ *
+ * CI systems usually check out repositories with a small --depth, in which case
+ * the requested scan depth silently exceeds the history that exists locally.
+ * The analyzer detects this, can fetch the missing commits, and always reports
+ * how much history was actually covered.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/**
+ * @struct historyCoverage
+ * @brief Describes how much of the requested history is available locally.
+ */
+type historyCoverage struct {
+	requested int  // Number of commits the user asked to scan
+	available int  // Number of commits reachable within that depth
+	shallow   bool // Whether the repository is (still) a shallow clone
+
+	firstParent bool // Whether only the first-parent chain was walked (--first-parent)
+
+	scope string // Set instead of a depth when a snapshot or release range was scanned
+}
+
+/**
+ * @brief Reports whether the repository is a shallow clone.
+ * @param repo The repository to inspect.
+ * @return True if git reports a shallow repository.
+ */
+func isShallowRepository(repo *repository) bool {
+	output, err := repo.command("rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+/**
+ * @brief Counts the commits reachable from HEAD, capped at the requested depth.
+ * @param repo The repository to inspect.
+ * @param depth The maximum number of commits to count.
+ * @param firstParent Whether to count only the first-parent chain.
+ * @return The number of available commits (0 for an empty repository).
+ */
+func countAvailableCommits(repo *repository, depth int, firstParent bool) int {
+	args := []string{"rev-list", "--count", fmt.Sprintf("--max-count=%d", depth)}
+	if firstParent {
+		args = append(args, "--first-parent")
+	}
+	output, err := repo.command(append(args, "HEAD")...).Output()
+	if err != nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(string(output)))
+	return count
+}
+
+/**
+ * @brief Checks history availability and deepens a shallow clone if allowed.
+ * @param repo The repository to inspect.
+ * @param depth The number of commits the user asked to scan.
+ * @param autoDeepen Whether `git fetch --deepen` may be run to fetch missing history.
+ * @param merges Which commits are walked; with --first-parent only the first-parent chain counts.
+ * @return The resulting history coverage.
+ */
+func ensureHistoryDepth(repo *repository, depth int, autoDeepen bool, merges mergePolicy) historyCoverage {
+	coverage := historyCoverage{
+		requested:   depth,
+		available:   countAvailableCommits(repo, depth, merges.firstParent),
+		shallow:     isShallowRepository(repo),
+		firstParent: merges.firstParent,
+	}
+	if !coverage.shallow || coverage.available >= depth || !autoDeepen {
+		return coverage
+	}
+
+	missing := depth - coverage.available
+	fmt.Fprintf(os.Stderr, "Go analyzer: shallow clone has %d of %d requested commits, deepening by %d...\n",
+		coverage.available, depth, missing)
+	fetchCmd := repo.command("fetch", "--quiet", fmt.Sprintf("--deepen=%d", missing))
+	fetchCmd.Stdout = os.Stderr
+	fetchCmd.Stderr = os.Stderr
+	if err := fetchCmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Go analyzer: git fetch --deepen failed: %v\n", err)
+	}
+
+	coverage.available = countAvailableCommits(repo, depth, merges.firstParent)
+	coverage.shallow = isShallowRepository(repo)
+	return coverage
+}
+
+/**
+ * @brief Reports whether the walk reached the first commit of the history.
+ */
+func (c historyCoverage) entire() bool {
+	return !c.shallow && c.available < c.requested
+}
+
+/**
+ * @brief Prints a one-line summary of the covered history to stderr.
+ * A warning is emitted whenever a shallow clone truncated the scan.
+ * @param label The repository name to prefix in sweeps ("" for none).
+ */
+func (c historyCoverage) report(label string) {
+	prefix := "Go analyzer: " + labelPrefix(label)
+	walk := ""
+	if c.firstParent {
+		walk = "first-parent "
+	}
+	switch {
+	case c.scope != "":
+		fmt.Fprintf(os.Stderr, "%sscanned %s\n", prefix, c.scope)
+	case c.shallow && c.available < c.requested:
+		fmt.Fprintf(os.Stderr, "%sWARNING: shallow clone, scanned %d of %d requested commits (use --auto-deepen to fetch more)\n",
+			prefix, c.available, c.requested)
+	case c.available < c.requested:
+		fmt.Fprintf(os.Stderr, "%sscanned entire %shistory (%d commits, %d requested)\n", prefix, walk, c.available, c.requested)
+	default:
+		fmt.Fprintf(os.Stderr, "%sscanned %d %scommits\n", prefix, c.available, walk)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// shallowClone clones a fixture with only its newest commits.
+func shallowClone(t *testing.T, repo *fixtureRepo, depth int) string {
+	t.Helper()
+	clone := filepath.Join(t.TempDir(), "clone")
+	cmd := exec.Command("git", "clone", "-q", fmt.Sprintf("--depth=%d", depth), "file://"+repo.dir, clone)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git clone --depth: %v\n%s", err, output)
+	}
+	return filepath.Join(clone, ".git")
+}
+
+func TestHistoryDepthOfShallowClone(t *testing.T) {
+	repo := newFixtureRepo(t)
+	for i := 0; i < 5; i++ {
+		repo.commit(fmt.Sprint("commit ", i), map[string]string{"file.txt": fmt.Sprint(i, "\n")})
+	}
+	r := &repository{gitDir: shallowClone(t, repo, 2)}
+
+	coverage := ensureHistoryDepth(r, 4, false, mergePolicy{})
+	if coverage != (historyCoverage{requested: 4, available: 2, shallow: true}) {
+		t.Errorf("without --auto-deepen: %+v", coverage)
+	}
+	coverage = ensureHistoryDepth(r, 4, true, mergePolicy{})
+	if coverage.available != 4 {
+		t.Errorf("with --auto-deepen: %+v, want 4 commits available", coverage)
+	}
+	coverage = ensureHistoryDepth(r, 10, true, mergePolicy{})
+	if coverage != (historyCoverage{requested: 10, available: 5, shallow: false}) {
+		t.Errorf("deepened to the root: %+v", coverage)
+	}
+}
+
+func TestHistoryDepthOfFullClone(t *testing.T) {
+	repo := newFixtureRepo(t)
+	repo.commit("one", map[string]string{"a": "1\n"})
+	repo.commit("two", map[string]string{"a": "2\n"})
+	r := &repository{gitDir: filepath.Join(repo.dir, ".git")}
+	if coverage := ensureHistoryDepth(r, 100, true, mergePolicy{}); coverage != (historyCoverage{requested: 100, available: 2}) {
+		t.Errorf("full clone: %+v", coverage)
+	}
+}
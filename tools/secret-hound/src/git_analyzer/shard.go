@@ -0,0 +1,88 @@
+/**
+ * @file shard.go
+ * @brief Deterministic partitioning of a repository's blobs across CI jobs (--shard).
+ *
+ * A CI matrix of n jobs can split one history scan without any coordinator:
+ * job k runs with --shard k/n and scans only the blobs whose hash falls into
+ * shard k. Every job walks the same history and deduplicates it the same way,
+ * and a blob hash always maps to the same shard, so the shards are disjoint
+ * and together cover every blob: concatenating the n outputs gives the
+ * findings of an unsharded run.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/**
+ * @struct shardSpec
+ * @brief One shard out of a fixed number (the zero value scans everything).
+ */
+type shardSpec struct {
+	index int // 1-based shard number
+	count int // Number of shards (0 = not sharded)
+}
+
+/**
+ * @brief Formats the shard as "<k>/<n>".
+ */
+func (s shardSpec) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
+
+/**
+ * @brief Parses a --shard value of the form "<k>/<n>" with 1 <= k <= n.
+ */
+func parseShard(value string) (shardSpec, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) == 2 {
+		k, errK := strconv.Atoi(parts[0])
+		n, errN := strconv.Atoi(parts[1])
+		if errK == nil && errN == nil && n >= 1 && k >= 1 && k <= n {
+			return shardSpec{index: k, count: n}, nil
+		}
+	}
+	return shardSpec{}, fmt.Errorf("want <k>/<n> with 1 <= k <= n, e.g. --shard 3/8")
+}
+
+/**
+ * @brief Reports whether a blob belongs to this shard.
+ * The shard is chosen from the leading 32 bits of the blob hash, which are
+ * uniformly distributed for SHA-1 and SHA-256 object names alike.
+ */
+func (s shardSpec) contains(hash string) bool {
+	if s.count <= 1 {
+		return true
+	}
+	prefix := hash
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+	v, err := strconv.ParseUint(prefix, 16, 32)
+	if err != nil {
+		return s.index == 1 // Not an object name; keep it in exactly one shard
+	}
+	return int(v%uint64(s.count)) == s.index-1
+}
+
+/**
+ * @brief Keeps the blobs of this shard.
+ * @param blobs The deduplicated blobs of a repository.
+ * @return The blobs in the shard, in their original order.
+ */
+func (s shardSpec) filter(blobs []fileBlob) []fileBlob {
+	if s.count <= 1 {
+		return blobs
+	}
+	kept := blobs[:0:0]
+	for _, blob := range blobs {
+		if s.contains(blob.hash) {
+			kept = append(kept, blob)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	if s, err := parseShard("3/8"); err != nil || s != (shardSpec{index: 3, count: 8}) || s.String() != "3/8" {
+		t.Errorf("parseShard(3/8) = %v, %v", s, err)
+	}
+	for _, value := range []string{"0/4", "5/4", "1/0", "3", "a/b", "1/2/3", "-1/2"} {
+		if _, err := parseShard(value); err == nil {
+			t.Errorf("--shard %s was accepted", value)
+		}
+	}
+}
+
+func TestShardsPartitionTheBlobs(t *testing.T) {
+	var blobs []fileBlob
+	for i := 0; i < 200; i++ {
+		blobs = append(blobs, fileBlob{hash: fmt.Sprintf("%08x%032x", uint32(i)*2654435761, i)})
+	}
+	blobs = append(blobs, fileBlob{hash: "not-a-hash"})
+	seen := make(map[string]int)
+	for k := 1; k <= 4; k++ {
+		shard := shardSpec{index: k, count: 4}
+		kept := shard.filter(blobs)
+		if len(kept) == 0 {
+			t.Errorf("shard %s is empty", shard)
+		}
+		for _, blob := range kept {
+			seen[blob.hash]++
+		}
+	}
+	for _, blob := range blobs {
+		if seen[blob.hash] != 1 {
+			t.Errorf("blob %s is in %d shards, want exactly 1", blob.hash, seen[blob.hash])
+		}
+	}
+	if got := (shardSpec{}).filter(blobs); len(got) != len(blobs) {
+		t.Errorf("an unsharded run kept %d of %d blobs", len(got), len(blobs))
+	}
+	if !(shardSpec{index: 1, count: 1}).contains("ffffffff") {
+		t.Error("1/1 dropped a blob")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Credentials and time of the AWS Signature Version 4 test suite.
+var sigv4TestCredentials = awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+var sigv4TestTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSignAWSRequestGetVanilla(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := signAWSRequest(req, sigv4TestCredentials, "us-east-1", "service", sigv4TestTime); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization:\n got %s\nwant %s", got, want)
+	}
+	if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %q", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestSignAWSRequestKeepsTheBody(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://example.amazonaws.com/", strings.NewReader("Param1=value1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	creds := sigv4TestCredentials
+	creds.sessionToken = "session"
+	if err := signAWSRequest(req, creds, "us-east-1", "service", sigv4TestTime); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != "Param1=value1" {
+		t.Errorf("body after signing: %q", body)
+	}
+	auth := req.Header.Get("Authorization")
+	if !strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") || req.Header.Get("X-Amz-Security-Token") != "session" {
+		t.Errorf("the session token is not signed: %s", auth)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/?b=2&a=x y&a=1", nil)
+	if got := canonicalQuery(req.URL.Query()); got != "a=1&a=x%20y&b=2" {
+		t.Errorf("canonicalQuery = %q", got)
+	}
+}
+
+func TestAWSCredentialsFromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := awsCredentialsFromEnv(); err == nil {
+		t.Error("missing credentials were accepted")
+	}
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+	if got := awsRegionFromEnv("us-east-1"); got != "eu-west-1" {
+		t.Errorf("region %q", got)
+	}
+}
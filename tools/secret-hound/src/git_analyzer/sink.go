@@ -0,0 +1,222 @@
+/**
+ * @file sink.go
+ * @brief The file sink for findings, with optional gzip or zstd compression
+ * and encryption (see encrypt.go).
+ *
+ * Multi-gigabyte finding streams from monorepo scans are written compressed so
+ * they don't fill CI artifact storage. gzip is built in; zstd is delegated to
+ * the `zstd` command-line tool, which must be installed. The compressor is
+ * finalized on normal exit and on a termination signal (SIGINT/SIGTERM, or
+ * Ctrl-C on Windows) so a cancelled scan still leaves a readable (if partial)
+ * file behind.
+ */
+
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+)
+
+// compressionExtensions maps each --compress value to its file extension.
+var compressionExtensions = map[string]string{
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+/**
+ * @brief Determines the compression and final file name for the sink.
+ * An explicit --compress adds the matching extension if it is missing; without
+ * it the compression is inferred from a .gz or .zst extension.
+ * @param path The --output path.
+ * @param compress The --compress value ("" for auto-detection).
+ * @return The final path, the compression ("" for none), and an error if unknown.
+ */
+func resolveSinkPath(path, compress string) (string, string, error) {
+	if compress == "" || compress == "none" {
+		for name, ext := range compressionExtensions {
+			if strings.HasSuffix(path, ext) && compress == "" {
+				return path, name, nil
+			}
+		}
+		return path, "", nil
+	}
+	ext, ok := compressionExtensions[compress]
+	if !ok {
+		return "", "", fmt.Errorf("unknown compression %q (expected gzip or zstd)", compress)
+	}
+	if !strings.HasSuffix(path, ext) {
+		path += ext
+	}
+	return path, compress, nil
+}
+
+/**
+ * @struct fileSink
+ * @brief A file, optionally behind a compressor and an encryptor, that findings are written to.
+ */
+type fileSink struct {
+	file       io.WriteCloser // The output file, or an object store upload
+	compressor io.WriteCloser // gzip writer or the stdin of the zstd process (nil if uncompressed)
+	zstdCmd    *exec.Cmd
+	encryptIn  io.WriteCloser // stdin of the encryption process (nil if unencrypted)
+	encryptCmd *exec.Cmd
+}
+
+/**
+ * @brief Opens the output file and sets up compression and encryption.
+ * @param path The final output path (see resolveSinkPath).
+ * @param compress The compression to use ("" for none).
+ * @param encryptCmd The encryption process to pipe the output through (nil for none).
+ * @return The sink and an error if the file, compressor or encryptor could not be created.
+ */
+func openFileSink(path, compress string, encryptCmd *exec.Cmd) (*fileSink, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newSink(file, compress, encryptCmd)
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+	}
+	return sink, err
+}
+
+/**
+ * @brief Sets up compression and encryption in front of a destination.
+ * @param file The destination; the caller disposes of it if setup fails.
+ * @param compress The compression to use ("" for none).
+ * @param encryptCmd The encryption process to pipe the output through (nil for none).
+ * @return The sink and an error if the compressor or encryptor could not be started.
+ */
+func newSink(file io.WriteCloser, compress string, encryptCmd *exec.Cmd) (*fileSink, error) {
+	var err error
+	sink := &fileSink{file: file}
+
+	// Encryption comes last: ciphertext does not compress.
+	var target io.Writer = file
+	if encryptCmd != nil {
+		sink.encryptCmd = encryptCmd
+		encryptCmd.Stdout = file
+		if sink.encryptIn, err = encryptCmd.StdinPipe(); err == nil {
+			err = encryptCmd.Start()
+		}
+		if err != nil {
+			return nil, err
+		}
+		target = sink.encryptIn
+	}
+
+	switch compress {
+	case "gzip":
+		sink.compressor = gzip.NewWriter(target)
+	case "zstd":
+		if _, err := exec.LookPath("zstd"); err != nil {
+			sink.stopEncryptor()
+			return nil, fmt.Errorf("--compress zstd requires the zstd command-line tool")
+		}
+		zstdCmd := exec.Command("zstd", "-q", "-c")
+		zstdCmd.Stdout = target
+		zstdCmd.Stderr = os.Stderr
+		if sink.compressor, err = zstdCmd.StdinPipe(); err == nil {
+			err = zstdCmd.Start()
+		}
+		if err != nil {
+			sink.stopEncryptor()
+			return nil, err
+		}
+		sink.zstdCmd = zstdCmd
+	}
+	return sink, nil
+}
+
+/**
+ * @brief Stops the encryptor of a sink whose setup failed.
+ * An encryptor that was already started is given EOF and waited for, so it
+ * neither lingers nor writes into a destination that is being discarded.
+ */
+func (s *fileSink) stopEncryptor() {
+	if s.encryptIn != nil {
+		s.encryptIn.Close()
+		s.encryptCmd.Wait()
+	}
+}
+
+func (s *fileSink) Write(p []byte) (int, error) {
+	switch {
+	case s.compressor != nil:
+		return s.compressor.Write(p)
+	case s.encryptIn != nil:
+		return s.encryptIn.Write(p)
+	}
+	return s.file.Write(p)
+}
+
+/**
+ * @brief Pushes what was written so far to the file, as far as the stream
+ * allows: a gzip block is flushed, zstd and encryptors keep their buffers.
+ * @return An error if flushing or syncing failed.
+ */
+func (s *fileSink) Flush() error {
+	if gz, ok := s.compressor.(*gzip.Writer); ok {
+		if err := gz.Flush(); err != nil {
+			return err
+		}
+	}
+	if file, ok := s.file.(*os.File); ok && s.encryptIn == nil && s.zstdCmd == nil {
+		return file.Sync()
+	}
+	return nil
+}
+
+/**
+ * @brief Flushes the compressor, waits for zstd and the encryptor if used, and closes the file.
+ * @return The first error encountered.
+ */
+func (s *fileSink) Close() error {
+	var err error
+	if s.compressor != nil {
+		err = s.compressor.Close()
+	}
+	if s.zstdCmd != nil {
+		if waitErr := s.zstdCmd.Wait(); err == nil {
+			err = waitErr
+		}
+	}
+	if s.encryptIn != nil {
+		if closeErr := s.encryptIn.Close(); err == nil {
+			err = closeErr
+		}
+		if waitErr := s.encryptCmd.Wait(); err == nil {
+			err = waitErr
+		}
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+/**
+ * @brief Finalizes the findings sink when the process is interrupted.
+ * Without this a gzip stream would be missing its trailer after Ctrl-C.
+ */
+func closeSinkOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, terminationSignals...)
+	go func() {
+		sig := <-signals
+		terminateChildren()
+		if err := findingsSink.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: closing output: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Go analyzer: interrupted by %v, output flushed\n", sig)
+		os.Exit(130)
+	}()
+}
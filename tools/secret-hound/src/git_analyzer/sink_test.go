@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSinkPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, compress, wantPath, wantCompress string
+	}{
+		{"out.jsonl", "", "out.jsonl", ""},
+		{"out.jsonl.gz", "", "out.jsonl.gz", "gzip"},
+		{"out.jsonl.zst", "", "out.jsonl.zst", "zstd"},
+		{"out.jsonl", "gzip", "out.jsonl.gz", "gzip"},
+		{"out.jsonl.zst", "zstd", "out.jsonl.zst", "zstd"},
+		{"out.jsonl.gz", "none", "out.jsonl.gz", ""},
+	} {
+		path, compress, err := resolveSinkPath(tc.path, tc.compress)
+		if err != nil || path != tc.wantPath || compress != tc.wantCompress {
+			t.Errorf("resolveSinkPath(%q, %q) = %q, %q, %v", tc.path, tc.compress, path, compress, err)
+		}
+	}
+	if _, _, err := resolveSinkPath("out", "brotli"); err == nil {
+		t.Error("--compress brotli was accepted")
+	}
+}
+
+// writeThroughSink writes two findings to a sink at path and closes it.
+func writeThroughSink(t *testing.T, path, compress string) {
+	t.Helper()
+	sink, err := openFileSink(path, compress, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw, _ := newFindingWriter(sink, "json")
+	for _, f := range sampleFindings() {
+		if err := fw.write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.write(sampleFindings()[0]); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("write after close: %v", err)
+	}
+	if err := fw.close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
+
+// countLines counts the lines of a stream.
+func countLines(t *testing.T, r io.Reader) int {
+	t.Helper()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, b := range data {
+		if b == '\n' {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGzipSinkIsComplete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "findings.jsonl.gz")
+	writeThroughSink(t, path, "gzip")
+	file, _ := os.Open(path)
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(t, r); n != 2 {
+		t.Errorf("%d findings decompressed, want 2", n)
+	}
+}
+
+func TestZstdSinkIsComplete(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not available")
+	}
+	path := filepath.Join(t.TempDir(), "findings.jsonl.zst")
+	writeThroughSink(t, path, "zstd")
+	output, err := exec.Command("zstd", "-q", "-d", "-c", path).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(t, bytes.NewReader(output)); n != 2 {
+		t.Errorf("%d findings decompressed, want 2", n)
+	}
+}
+
+func TestFailedZstdSetupStopsTheEncryptor(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "encryptor-exited")
+	encryptor := fakeCore(t, "PATH=/usr/bin:/bin; cat >/dev/null; touch '"+marker+"'")
+	t.Setenv("PATH", t.TempDir()) // No zstd
+	path := filepath.Join(t.TempDir(), "findings.jsonl.zst.age")
+	if _, err := openFileSink(path, "zstd", exec.Command(encryptor)); err == nil {
+		t.Fatal("zstd compression without the zstd tool was accepted")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("the encryptor was not given EOF and waited for")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the output file was left behind: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+/**
+ * @file snapshot.go
+ * @brief Scans the full tree at a single ref instead of walking history.
+ *
+ * `--snapshot <ref>` answers "what secrets ship in this release?": every blob
+ * in the tree at the ref is scanned once, regardless of which commit
+ * introduced it. Findings carry the commit the ref resolves to.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * @brief Lists every blob in the tree of a ref.
+ * @param repo The repository.
+ * @param ref Any commit-ish: a tag, branch or commit hash.
+ * @return The blobs (gitlinks excluded), the resolved commit hash, and an error.
+ */
+func getSnapshotBlobs(repo *repository, ref string) ([]fileBlob, string, error) {
+	output, err := repo.command("rev-parse", "--verify", "-q", ref+"^{commit}").Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("%s does not name a commit", ref)
+	}
+	commit := strings.TrimSpace(string(output))
+
+	output, err = repo.command("ls-tree", "-r", "-z", "--full-tree", commit).Output()
+	if err != nil {
+		return nil, "", err
+	}
+	var blobs []fileBlob
+	for _, entry := range strings.Split(string(output), "\x00") {
+		// Each entry is "<mode> <type> <hash>\t<path>".
+		tab := strings.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(entry[:tab])
+		if len(fields) != 3 || fields[1] != "blob" {
+			continue // Gitlinks are commits of other repositories
+		}
+		blobs = append(blobs, fileBlob{hash: fields[2], path: entry[tab+1:], commit: commit, repo: repo, mode: fields[0]})
+	}
+	return blobs, commit, nil
+}
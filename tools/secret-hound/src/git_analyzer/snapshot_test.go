@@ -0,0 +1,44 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotListsTheWholeTreeAtARef(t *testing.T) {
+	fx := newFixtureRepo(t)
+	fx.commit("old", map[string]string{"removed.txt": "gone", "kept/config.env": "v1"})
+	fx.git("rm", "-q", "removed.txt")
+	release := fx.commit("release", map[string]string{"kept/config.env": "v2", "new file.txt": "n"})
+	fx.git("tag", "v1.0")
+	fx.commit("after the release", map[string]string{"later.txt": "l"})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git")}
+
+	blobs, commit, err := getSnapshotBlobs(repo, "v1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != release {
+		t.Errorf("v1.0 resolved to %s, want %s", commit, release)
+	}
+	var paths []string
+	for _, blob := range blobs {
+		paths = append(paths, blob.path)
+		if blob.commit != release || blob.mode != "100644" {
+			t.Errorf("blob %s: commit %s mode %s", blob.path, blob.commit, blob.mode)
+		}
+	}
+	sort.Strings(paths)
+	if got := strings.Join(paths, ","); got != "kept/config.env,new file.txt" {
+		t.Errorf("snapshot paths = %s", got)
+	}
+	if content, err := readBlobContent(blobs[0]); err != nil || string(content) != "v2" {
+		t.Errorf("content at the ref: %q, %v", content, err)
+	}
+
+	if _, _, err := getSnapshotBlobs(repo, "no-such-ref"); err == nil {
+		t.Error("an unknown ref was accepted")
+	}
+}
@@ -0,0 +1,92 @@
+/**
+ * @file tempdir.go
+ * @brief Location, retention and cleanup of temporary files.
+ *
+ * Cores without stdin support get each blob through a temporary file. By
+ * default these go to the system temp directory ($TMPDIR on Unix); --tmp-dir
+ * moves them elsewhere, e.g. to a tmpfs such as /dev/shm so that blob
+ * content never touches a disk. A crashed run can leave files behind, so
+ * each run first removes stale secret-hound-git-* files from the directory.
+ * With --keep-temp-on-failure, the input of a failed core run is kept for
+ * debugging instead, including content that was piped on stdin.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tempFilePattern names every temporary file the analyzer creates.
+const tempFilePattern = "secret-hound-git-*.tmp"
+
+// orphanAge is how old a temporary file must be before it is treated as a
+// leftover from a crashed run; live runs hold each file only for one blob.
+const orphanAge = time.Hour
+
+/**
+ * @brief Resolves and validates the temporary directory.
+ * @param dir The --tmp-dir value ("" for the system default, which honors TMPDIR).
+ * @return The directory to use, or an error if it is not a writable directory.
+ */
+func resolveTempDir(dir string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	info, err := os.Stat(longPath(dir))
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	probe, err := ioutil.TempFile(longPath(dir), tempFilePattern)
+	if err != nil {
+		return "", err
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return dir, nil
+}
+
+/**
+ * @brief Removes temporary files left behind by crashed runs.
+ * @param dir The temporary directory.
+ * @return The number of files removed.
+ */
+func cleanOrphanedTempFiles(dir string) int {
+	matches, _ := filepath.Glob(filepath.Join(dir, tempFilePattern))
+	removed := 0
+	for _, path := range matches {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || time.Since(info.ModTime()) < orphanAge {
+			continue
+		}
+		if os.Remove(path) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+/**
+ * @brief Writes content to a new temporary file.
+ * @param content The content to write.
+ * @return The file's path, or an error.
+ */
+func (a *analyzer) writeTempFile(content []byte) (string, error) {
+	file, err := ioutil.TempFile(longPath(a.opts.tmpDir), tempFilePattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
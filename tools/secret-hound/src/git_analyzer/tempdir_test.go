@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveTempDir(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := resolveTempDir(dir); err != nil || got != dir {
+		t.Errorf("resolveTempDir(%s) = %s, %v", dir, got, err)
+	}
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, nil, 0o600)
+	for _, bad := range []string{file, filepath.Join(dir, "missing")} {
+		if _, err := resolveTempDir(bad); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+	t.Setenv("TMPDIR", dir)
+	if got, err := resolveTempDir(""); err != nil || got != dir {
+		t.Errorf("default = %s, %v; want $TMPDIR", got, err)
+	}
+}
+
+func TestTempDirIsResolvedBeforeTheSinks(t *testing.T) {
+	repo := newFixtureRepo(t)
+	repo.commit("one", map[string]string{"a.txt": "a\n"})
+	output := filepath.Join(t.TempDir(), "findings.jsonl")
+	_, stderr, exitCode := runAnalyzer(t, repo.dir, "--tmp-dir", filepath.Join(t.TempDir(), "missing"), "--output", output, os.Args[0], "10")
+	if exitCode == 0 || !strings.Contains(stderr, "--tmp-dir") {
+		t.Errorf("exit code %d\n%s", exitCode, stderr)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("--output was opened before --tmp-dir was checked: %v", err)
+	}
+}
+
+func TestCleanOrphanedTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "secret-hound-git-1.tmp")
+	fresh := filepath.Join(dir, "secret-hound-git-2.tmp")
+	other := filepath.Join(dir, "unrelated.tmp")
+	for _, path := range []string{stale, fresh, other} {
+		os.WriteFile(path, []byte("x"), 0o600)
+	}
+	old := time.Now().Add(-2 * orphanAge)
+	os.Chtimes(stale, old, old)
+	os.Chtimes(other, old, old)
+
+	if removed := cleanOrphanedTempFiles(dir); removed != 1 {
+		t.Errorf("removed %d files, want 1", removed)
+	}
+	for path, exists := range map[string]bool{stale: false, fresh: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), err == nil, exists)
+		}
+	}
+}
+
+func TestRunCoreTempFiles(t *testing.T) {
+	failing := fakeCore(t, `cat >/dev/null; exit 3`)
+	blob := fileBlob{hash: "b10b", path: "a.env", repo: &repository{}}
+	for _, tc := range []struct {
+		name     string
+		core     *coreInfo
+		keepTemp bool
+		kept     int
+	}{
+		{"temp file removed", nil, false, 0},
+		{"temp file kept on failure", nil, true, 1},
+		{"stdin content kept on failure", &coreInfo{Capabilities: []string{"scan-file", "stdin"}}, true, 1},
+	} {
+		dir := t.TempDir()
+		a := &analyzer{opts: options{houndCorePath: failing, tmpDir: dir, keepTemp: tc.keepTemp}, core: tc.core}
+		a.runCore(blob, []byte("TOKEN=abc"))
+		left, _ := filepath.Glob(filepath.Join(dir, tempFilePattern))
+		if len(left) != tc.kept {
+			t.Errorf("%s: %d files left", tc.name, len(left))
+			continue
+		}
+		if tc.kept > 0 {
+			if content, _ := os.ReadFile(left[0]); string(content) != "TOKEN=abc" {
+				t.Errorf("%s: kept %q", tc.name, content)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/**
+ * @file terraform_detector.go
+ * @brief Detects secrets in Terraform state and variable files.
+ *
+ * A state file (*.tfstate, *.tfstate.backup) holds every attribute of every
+ * managed resource in plain text: database passwords, generated keys, access
+ * tokens. Line matches in a state file point at an anonymous JSON line, so
+ * the detector walks the resources instead and reports each secret under its
+ * resource address (module.db.aws_db_instance.main[0].password). An attribute
+ * is reported when its name is secret-like or the state marks it sensitive;
+ * outputs declared sensitive are reported as well. Variable files (*.tfvars,
+ * *.tfvars.json) are read as assignments, including nested maps, and report
+ * values under secret-like variable names.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/**
+ * @struct terraformDetector
+ * @brief The "terraform" detector.
+ */
+type terraformDetector struct{}
+
+func (terraformDetector) name() string { return "terraform" }
+
+// terraformState is the part of a version 4 state file the detector reads.
+type terraformState struct {
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey            interface{} `json:"index_key"`
+			SensitiveAttributes [][]struct {
+				Type  string      `json:"type"`
+				Value interface{} `json:"value"`
+			} `json:"sensitive_attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+	Outputs map[string]struct {
+		Sensitive bool `json:"sensitive"`
+	} `json:"outputs"`
+}
+
+func (terraformDetector) detect(filePath string, content []byte) []detection {
+	base := strings.ToLower(path.Base(filePath))
+	switch {
+	case strings.HasSuffix(base, ".tfstate") || strings.HasSuffix(base, ".tfstate.backup"):
+		return detectTerraformState(content)
+	case strings.HasSuffix(base, ".tfvars.json"):
+		return tfvarsDetections(parseJSONConfig(content))
+	case strings.HasSuffix(base, ".tfvars"):
+		return tfvarsDetections(parseTFVars(content))
+	}
+	return nil
+}
+
+/**
+ * @brief Reports secret attributes of the resources and sensitive outputs in a state file.
+ */
+func detectTerraformState(content []byte) []detection {
+	var state terraformState
+	if json.Unmarshal(content, &state) != nil {
+		return nil
+	}
+	// Resolve "resources.<i>.instances.<j>" to addresses and collect sensitive attribute paths.
+	addresses := make(map[string]string)
+	types := make(map[string]string)
+	sensitive := make(map[string]bool)
+	for i, r := range state.Resources {
+		address := r.Type + "." + r.Name
+		if r.Mode == "data" {
+			address = "data." + address
+		}
+		if r.Module != "" {
+			address = r.Module + "." + address
+		}
+		for j, inst := range r.Instances {
+			key := fmt.Sprintf("resources.%d.instances.%d", i, j)
+			types[key] = r.Type
+			switch index := inst.IndexKey.(type) {
+			case float64:
+				addresses[key] = fmt.Sprintf("%s[%d]", address, int(index))
+			case string:
+				addresses[key] = address + "[" + strconv.Quote(index) + "]"
+			default:
+				addresses[key] = address
+			}
+			for _, steps := range inst.SensitiveAttributes {
+				var attr []string
+				for _, step := range steps {
+					value := step.Value
+					if typed, ok := value.(map[string]interface{}); ok {
+						value = typed["value"] // Index steps are typed: {"type": "number", "value": 0}
+					}
+					attr = append(attr, fmt.Sprint(value))
+				}
+				sensitive[key+".attributes."+strings.Join(attr, ".")] = true
+			}
+		}
+	}
+
+	var found []detection
+	for _, v := range parseJSONConfig(content) {
+		segments := strings.Split(v.keyPath, ".")
+		switch {
+		case len(segments) == 3 && segments[0] == "outputs" && segments[2] == "value":
+			if !state.Outputs[segments[1]].Sensitive || len(v.value) < 4 {
+				continue
+			}
+			found = append(found, detection{
+				ruleID:      "TERRAFORM_STATE_OUTPUT",
+				description: "Sensitive Terraform output '" + segments[1] + "' stored in state",
+				line:        v.line,
+				match:       v.value,
+				keyPath:     "output." + segments[1],
+				confidence:  "High",
+				severity:    "high",
+			})
+		case len(segments) > 5 && segments[0] == "resources" && segments[4] == "attributes":
+			instance := strings.Join(segments[:4], ".")
+			attr := strings.Join(segments[5:], ".")
+			marked := sensitive[v.keyPath] && len(v.value) >= 4
+			if !marked && !isSecretAssignment(lastNonIndexSegment(segments[5:]), v.value) {
+				continue
+			}
+			d := detection{
+				ruleID:      "TERRAFORM_STATE_SECRET",
+				description: "Secret attribute '" + attr + "' of " + addresses[instance] + " stored in Terraform state",
+				line:        v.line,
+				match:       v.value,
+				keyPath:     addresses[instance] + "." + attr,
+				confidence:  "Medium",
+				severity:    "high",
+				metadata: map[string]string{
+					"resource_address": addresses[instance],
+					"resource_type":    types[instance],
+					"attribute":        attr,
+				},
+			}
+			if marked {
+				d.confidence = "High"
+				d.metadata["sensitive"] = "true"
+			}
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// lastNonIndexSegment returns the last key of an attribute path, skipping list indexes.
+func lastNonIndexSegment(segments []string) string {
+	for i := len(segments) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(segments[i]); err != nil {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+/**
+ * @brief Turns the assignments of a variable file into detections.
+ */
+func tfvarsDetections(values []configValue) []detection {
+	var found []detection
+	for _, v := range values {
+		segments := strings.Split(v.keyPath, ".")
+		if !isSecretAssignment(lastNonIndexSegment(segments), v.value) {
+			continue
+		}
+		found = append(found, detection{
+			ruleID:      "TERRAFORM_TFVARS_SECRET",
+			description: "Secret assigned to Terraform variable '" + v.keyPath + "'",
+			line:        v.line,
+			match:       v.value,
+			keyPath:     "var." + v.keyPath,
+			confidence:  "Medium",
+			severity:    "high",
+		})
+	}
+	return found
+}
+
+/**
+ * @brief Extracts the string assignments of an HCL variable file.
+ * Nested maps ({ ... }) contribute their keys to the path ("db.password");
+ * lists, heredocs and expressions are skipped.
+ */
+func parseTFVars(content []byte) []configValue {
+	var values []configValue
+	var stack []string
+	heredoc := ""
+	for i, raw := range strings.Split(string(content), "\n") {
+		line := strings.TrimSpace(raw)
+		if heredoc != "" {
+			if line == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if line == "}" || line == "}," {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:sep]), `"`)
+		value := strings.TrimSuffix(strings.TrimSpace(line[sep+1:]), ",")
+		switch {
+		case value == "{":
+			stack = append(stack, key)
+		case strings.HasPrefix(value, "<<"):
+			heredoc = strings.TrimPrefix(strings.TrimPrefix(value, "<<"), "-")
+		case strings.HasPrefix(value, `"`) && !strings.Contains(value, "${"):
+			values = append(values, configValue{joinKeyPath(strings.Join(stack, "."), key), unquoteConfigValue(value), i + 1})
+		}
+	}
+	return values
+}
@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+const testState = `{
+  "version": 4,
+  "outputs": {
+    "db_url": {"value": "postgres://admin:pw@db/app", "sensitive": true},
+    "region": {"value": "eu-west-1", "sensitive": false}
+  },
+  "resources": [
+    {
+      "module": "module.db",
+      "mode": "managed",
+      "type": "aws_db_instance",
+      "name": "main",
+      "instances": [
+        {
+          "index_key": 0,
+          "attributes": {"password": "hunter2hunter2", "engine": "postgres", "endpoint": "db.internal:5432"},
+          "sensitive_attributes": []
+        }
+      ]
+    },
+    {
+      "mode": "managed",
+      "type": "tls_private_key",
+      "name": "deploy",
+      "instances": [
+        {
+          "index_key": "ci",
+          "attributes": {"private_key_pem": "-----BEGIN KEY-----", "algorithm": "RSA", "settings": [{"blob": "opaque-value"}]},
+          "sensitive_attributes": [[{"type": "get_attr", "value": "settings"}, {"type": "index", "value": {"type": "number", "value": 0}}, {"type": "get_attr", "value": "blob"}]]
+        }
+      ]
+    }
+  ]
+}
+`
+
+func TestTerraformStateDetections(t *testing.T) {
+	found := terraformDetector{}.detect("envs/prod/terraform.tfstate", []byte(testState))
+	byKey := make(map[string]detection)
+	for _, d := range found {
+		byKey[d.keyPath] = d
+	}
+	if len(found) != 4 {
+		t.Fatalf("%d detections, want 4: %+v", len(found), found)
+	}
+	if d := byKey["output.db_url"]; d.ruleID != "TERRAFORM_STATE_OUTPUT" || d.line != 4 {
+		t.Errorf("sensitive output: %+v", d)
+	}
+	if d := byKey["module.db.aws_db_instance.main[0].password"]; d.match != "hunter2hunter2" || d.metadata["resource_type"] != "aws_db_instance" || d.confidence != "Medium" {
+		t.Errorf("secret-like attribute: %+v", d)
+	}
+	if d := byKey[`tls_private_key.deploy["ci"].private_key_pem`]; d.ruleID != "TERRAFORM_STATE_SECRET" {
+		t.Errorf("string-indexed resource: %+v", d)
+	}
+	if d := byKey[`tls_private_key.deploy["ci"].settings.0.blob`]; d.metadata["sensitive"] != "true" || d.confidence != "High" {
+		t.Errorf("attribute marked sensitive: %+v", d)
+	}
+	if found := (terraformDetector{}).detect("terraform.tfstate.backup", []byte("{not json")); found != nil {
+		t.Errorf("invalid state: %+v", found)
+	}
+}
+
+func TestTFVarsDetections(t *testing.T) {
+	tfvars := `# Production
+region       = "eu-west-1"
+db_password  = "hunter2hunter2"
+api_token    = "${var.other}"
+service = {
+  name   = "api"
+  secret = "s3rv1c3S3cr3t"
+}
+script = <<-EOT
+  password = "in a heredoc"
+EOT
+`
+	found := terraformDetector{}.detect("prod.tfvars", []byte(tfvars))
+	if len(found) != 2 || found[0].keyPath != "var.db_password" || found[0].line != 3 ||
+		found[1].keyPath != "var.service.secret" || found[1].match != "s3rv1c3S3cr3t" {
+		t.Errorf("tfvars detections %+v", found)
+	}
+	found = terraformDetector{}.detect("prod.tfvars.json", []byte(`{"db": {"password": "hunter2hunter2"}}`))
+	if len(found) != 1 || found[0].keyPath != "var.db.password" {
+		t.Errorf("tfvars.json detections %+v", found)
+	}
+	if found := (terraformDetector{}).detect("main.tf", []byte(tfvars)); found != nil {
+		t.Errorf("a configuration file was read as variables: %+v", found)
+	}
+}
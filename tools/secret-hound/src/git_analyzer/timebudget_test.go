@@ -0,0 +1,138 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRiskOrder(t *testing.T) {
+	for p, want := range map[string]int{
+		".env.production": 3, "deploy/id_ed25519": 3, "certs/server.pem": 3, "prod.tfvars": 3, ".npmrc": 3,
+		"config/app.yaml": 2, "Dockerfile": 2, ".github/workflows/ci.yml": 2,
+		"src/main.go": 1, "README.md": 0,
+	} {
+		if got := riskTier(p); got != want {
+			t.Errorf("riskTier(%q) = %d, want %d", p, got, want)
+		}
+	}
+	blobs := []fileBlob{
+		{path: "README.md", commit: "c1"}, {path: "src/a.go", commit: "c1"}, {path: "config/app.yaml", commit: "c1"},
+		{path: ".env", commit: "c1"}, {path: "src/b.go", commit: worktreeCommit}, {path: ".env", commit: "c0"},
+	}
+	var got []string
+	for _, blob := range orderByRisk(blobs) {
+		got = append(got, blob.commit+":"+blob.path)
+	}
+	want := []string{"c1:.env", "c0:.env", "c1:config/app.yaml", worktreeCommit + ":src/b.go", "c1:src/a.go", "c1:README.md"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTimeBudgetResume(t *testing.T) {
+	fx := newFixtureRepo(t)
+	fx.commit("first", map[string]string{"a": "1\n"})
+	repo := &repository{gitDir: filepath.Join(fx.dir, ".git"), label: "app"}
+	blobs := []fileBlob{{hash: "1", path: "a.go"}, {hash: "2", path: ".env"}, {hash: "3", path: "b.yaml"}, {hash: "4", path: "c.txt"}}
+
+	// The first run scans the two riskiest blobs before its budget runs out.
+	first, _ := newTimeBudget(time.Hour, "")
+	planned := first.plan(repo, blobs)
+	if planned[0].path != ".env" || len(planned) != 4 {
+		t.Fatalf("plan %v", planned)
+	}
+	first.admit(repo)
+	first.admit(repo)
+	first.deadline = time.Now().Add(-time.Second)
+	if first.admit(repo) || !first.report() {
+		t.Fatal("the budget did not run out")
+	}
+	if first.progress["app"].Done != 2 {
+		t.Fatalf("progress %v", first.progress)
+	}
+	token := first.token()
+
+	resumed, err := newTimeBudget(0, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest := resumed.plan(repo, blobs)
+	if len(rest) != 2 || rest[0].path != "a.go" || rest[1].path != "c.txt" {
+		t.Errorf("resumed plan %v", rest)
+	}
+	for range rest {
+		if !resumed.admit(repo) {
+			t.Error("a run without a limit stopped")
+		}
+	}
+	if resumed.report() {
+		t.Error("a finished run reported a partial scan")
+	}
+
+	// A new commit invalidates the place in the order.
+	fx.commit("second", map[string]string{"b": "2\n"})
+	moved, _ := newTimeBudget(0, token)
+	if got := moved.plan(repo, blobs); len(got) != 4 {
+		t.Errorf("plan after HEAD moved %v", got)
+	}
+}
+
+func TestMalformedResumeToken(t *testing.T) {
+	for _, token := range []string{"!!!", "bm90IGpzb24"} {
+		if _, err := newTimeBudget(0, token); err == nil {
+			t.Errorf("token %q was accepted", token)
+		}
+	}
+	var off *timeBudget
+	if !off.admit(&repository{}) || off.report() || len(off.plan(&repository{}, []fileBlob{{}})) != 1 {
+		t.Error("a run without a budget was limited")
+	}
+}
+
+func TestPipelineStopsAtTheDeadline(t *testing.T) {
+	rules, _ := embeddedRuleSet()
+	deadline, _ := newTimeBudget(time.Nanosecond, "")
+	time.Sleep(time.Millisecond)
+	a := &analyzer{
+		opts:     options{fetchWorkers: 1, enrichWorkers: 1},
+		budget:   newMemoryBudget(0),
+		sched:    newScheduler(1),
+		rules:    rules,
+		engine:   newNativeEngine(rules),
+		grouper:  &secretGrouper{groups: make(map[string][]*finding)},
+		deadline: deadline,
+	}
+	repo := &repository{label: "app"}
+	if fed := a.runPipeline(repo, []fileBlob{{hash: "1", path: ".env", repo: repo}}, map[string]int64{}, 1); fed != 0 {
+		t.Errorf("%d blobs were fed after the deadline", fed)
+	}
+	if !deadline.exhausted {
+		t.Error("the budget was not marked exhausted")
+	}
+}
+
+func TestScanOrder(t *testing.T) {
+	for _, order := range []string{scanOrderHistory, scanOrderRisk} {
+		if got, err := parseScanOrder(order); err != nil || got != order {
+			t.Errorf("parseScanOrder(%q) = %q, %v", order, got, err)
+		}
+	}
+	if _, err := parseScanOrder("size"); err == nil {
+		t.Error("--scan-order size was accepted")
+	}
+	if riskTier("deploy/prod.env") != 3 {
+		t.Error("an .env file is not in the top tier")
+	}
+	// --scan-order risk with --time-budget orders twice; the second pass keeps the order.
+	blobs := []fileBlob{{path: "a.go", commit: "c2"}, {path: "b.yaml", commit: "c2"}, {path: "a.go", commit: "c1"}, {path: "x.env", commit: "c1"}}
+	once := orderByRisk(blobs)
+	twice := orderByRisk(once)
+	for i := range once {
+		if once[i] != twice[i] {
+			t.Fatalf("reordering changed %v to %v", once, twice)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/**
+ * @file tracker.go
+ * @brief What the issue tracker sinks (jira.go, servicenow.go) share.
+ *
+ * Both keep one record per leaked secret in step with the runs: findings are
+ * grouped by secret (the fingerprint of rule and value, see trend.go), the
+ * groups are written when a complete run ends (a partial or scoped run is not
+ * the state of the repositories, see scanScope), and records of secrets the
+ * --secret-index finds remediated are closed. Records never contain the
+ * secret, only its first characters, and secrets the index marks
+ * false-positive are left out. Fields can be mapped from Go text/templates
+ * over a group (.Fingerprint, .RuleID, .Severity, .Match, .Repositories,
+ * .Locations); a value that is a JSON object or array is sent as such.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// trackerLabel marks every record the analyzer manages.
+const trackerLabel = "secret-hound"
+
+// trackerMaxLocations is the most locations listed in a record's description.
+const trackerMaxLocations = 50
+
+/**
+ * @struct secretGroup
+ * @brief The findings of one secret in a run; the data of field templates.
+ */
+type secretGroup struct {
+	Fingerprint  string
+	RuleID       string
+	Severity     string // Highest seen
+	Match        string // Masked
+	Repositories []string
+	Locations    []indexLocation
+}
+
+// summary is the one-line title of a secret's record.
+func (g *secretGroup) summary() string {
+	summary := fmt.Sprintf("Leaked %s secret in %s", g.RuleID, g.Locations[0].Path) // A single unnamed repository
+	if len(g.Repositories) > 0 {
+		summary = fmt.Sprintf("Leaked %s secret in %s", g.RuleID, g.Repositories[0])
+	}
+	if more := len(g.Repositories) - 1; more > 0 {
+		summary += fmt.Sprintf(" and %d more repositories", more)
+	}
+	return summary
+}
+
+/**
+ * @struct secretGroups
+ * @brief Findings collected by secret for a tracker.
+ */
+type secretGroups struct {
+	mu     sync.Mutex
+	groups map[string]*secretGroup // By fingerprint
+}
+
+/**
+ * @brief Adds a finding to the group of its secret.
+ * @param f The finding, before it is written (and possibly redacted).
+ */
+func (s *secretGroups) record(f *finding) {
+	fingerprint := secretFingerprint(f)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.groups == nil {
+		s.groups = make(map[string]*secretGroup)
+	}
+	group := s.groups[fingerprint]
+	if group == nil {
+		group = &secretGroup{Fingerprint: fingerprint, RuleID: f.RuleID, Match: maskSecret(f.Match)}
+		s.groups[fingerprint] = group
+	}
+	if severity := findingSeverity(f); group.Severity == "" || severityRanks[severity] > severityRanks[group.Severity] {
+		group.Severity = severity
+	}
+	group.Locations = append(group.Locations, indexLocation{Repository: f.Repository, Commit: f.Commit, Path: f.OriginalPath, Line: f.Line})
+}
+
+/**
+ * @brief Completes the groups at the end of the run.
+ * @param index The secret index (may be nil); its false positives are dropped.
+ * @return The groups, by fingerprint.
+ */
+func (s *secretGroups) finish(index *secretIndex) []*secretGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var groups []*secretGroup
+	for fingerprint, group := range s.groups {
+		if index.status(fingerprint) == incidentFalsePositive {
+			continue
+		}
+		sortLocations(group.Locations)
+		group.Repositories = nil
+		seen := make(map[string]bool)
+		for _, loc := range group.Locations {
+			if loc.Repository != "" && !seen[loc.Repository] {
+				seen[loc.Repository] = true
+				group.Repositories = append(group.Repositories, loc.Repository)
+			}
+		}
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Fingerprint < groups[j].Fingerprint })
+	return groups
+}
+
+// fingerprintLabel is the label or correlation ID a secret's record is found by.
+func fingerprintLabel(fingerprint string) string {
+	return trackerLabel + "-" + fingerprint
+}
+
+// templateField is one <field>=<template> mapping.
+type templateField struct {
+	name  string
+	value *template.Template
+}
+
+/**
+ * @brief Parses <field>=<template> mappings.
+ * @param flagName The flag they were given with, for errors.
+ * @param mappings The flag values.
+ */
+func parseTemplateFields(flagName string, mappings []string) ([]templateField, error) {
+	var fields []templateField
+	for _, mapping := range mappings {
+		name, value, ok := strings.Cut(mapping, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s %q: want <field>=<template>", flagName, mapping)
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", flagName, name, err)
+		}
+		fields = append(fields, templateField{name: name, value: tmpl})
+	}
+	return fields, nil
+}
+
+/**
+ * @brief Sets the mapped fields of a group's record.
+ * @param fields The record's fields, added to.
+ * @return An error naming the field whose template failed.
+ */
+func applyTemplateFields(mappings []templateField, group *secretGroup, fields map[string]interface{}) error {
+	for _, field := range mappings {
+		var value bytes.Buffer
+		if err := field.value.Execute(&value, group); err != nil {
+			return fmt.Errorf("%s: %v", field.name, err)
+		}
+		var structured interface{}
+		if text := strings.TrimSpace(value.String()); (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) && json.Unmarshal([]byte(text), &structured) == nil {
+			fields[field.name] = structured
+		} else {
+			fields[field.name] = value.String()
+		}
+	}
+	return nil
+}
+
+// minInt returns the smaller of two ints.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/**
+ * @struct restAPI
+ * @brief A JSON REST API of a tracker.
+ */
+type restAPI struct {
+	client *http.Client
+	url    string // Base URL, without a trailing slash
+	auth   string // Authorization header value ("" = anonymous)
+}
+
+/**
+ * @brief Calls the API.
+ * @param method The HTTP method.
+ * @param path The API path, with any query.
+ * @param body Encoded as the JSON request body (nil = none).
+ * @param into Receives the decoded JSON response (nil = ignored); also decoded on errors.
+ * @return An error for a transport failure or a status other than 2xx.
+ */
+func (r *restAPI) call(method, path string, body, into interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, r.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.auth != "" {
+		req.Header.Set("Authorization", r.auth)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if into != nil && len(data) > 0 {
+		json.Unmarshal(data, into)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s %s", method, strings.SplitN(path, "?", 2)[0], resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeTracker is a tracker API served to a sink in tests.
+type fakeTracker struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+}
+
+/**
+ * @brief Starts a fake tracker API.
+ * @param authorized Whether a request carries the expected credentials; others get a 401.
+ * @param serve Answers an authorized request, given its decoded JSON body; false = 404.
+ * Requests are served one at a time, so serve needs no locking.
+ */
+func newFakeTracker(t *testing.T, authorized func(r *http.Request) bool, serve func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) bool) *fakeTracker {
+	tracker := &fakeTracker{}
+	tracker.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		tracker.requests++
+		if !authorized(r) {
+			http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !serve(w, r, body) {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(tracker.Close)
+	return tracker
+}
+
+// calls returns the number of requests the tracker received.
+func (f *fakeTracker) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// trackerSink is what the tracker tests drive.
+type trackerSink interface {
+	record(f *finding)
+	sync()
+}
+
+/**
+ * @brief Returns a function that runs a complete scan of the billing
+ * repository with the given findings: they are recorded in the secret index
+ * at path and in a sink, the index is saved and the sink synchronized.
+ * @param newSink Builds the sink of a run over its index.
+ */
+func trackerRun(t *testing.T, path string, newSink func(index *secretIndex) (trackerSink, error)) func(findings ...*finding) {
+	return func(findings ...*finding) {
+		t.Helper()
+		x, err := loadSecretIndex(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink, err := newSink(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x.begin(&repository{label: "billing"})
+		for _, f := range findings {
+			x.record(f)
+			sink.record(f)
+		}
+		if err := x.save(true); err != nil {
+			t.Fatal(err)
+		}
+		sink.sync()
+	}
+}
@@ -0,0 +1,333 @@
+/**
+ * @file trend.go
+ * @brief The scan history file and the `report trend` subcommand built on it.
+ *
+ * With --history-file, every run appends one JSON line per scanned
+ * repository: the time, the repository, and a fingerprint of each secret
+ * found (a hash of rule and value, so the file holds no secret material).
+ * `git_analyzer report trend` replays the file and shows, for every run, how
+ * many secrets were introduced and resolved since the previous run of the
+ * same repository, per repository or per rule. Runs should use the same
+ * depth to be comparable: a shallower scan reports secrets it did not reach
+ * as resolved. Runs scoped to part of the history (see scanScope) or cut
+ * short by --time-budget are not recorded for the same reason.
+ */
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+/**
+ * @struct runRecord
+ * @brief One repository's result in one run, as stored in the history file.
+ */
+type runRecord struct {
+	Time       string            `json:"time"` // RFC 3339, UTC
+	Repository string            `json:"repository"`
+	Findings   map[string]string `json:"findings"` // Fingerprint -> rule ID
+}
+
+/**
+ * @struct runRecorder
+ * @brief Collects the fingerprints of one run and appends them to the history file.
+ */
+type runRecorder struct {
+	mu      sync.Mutex
+	path    string
+	started time.Time
+	records map[string]*runRecord // Keyed by repository label ("" outside sweeps)
+}
+
+/**
+ * @brief Creates a recorder for the history file.
+ * @param path The --history-file value ("" disables recording).
+ * @return The recorder, or nil if disabled.
+ */
+func newRunRecorder(path string) *runRecorder {
+	if path == "" {
+		return nil
+	}
+	return &runRecorder{path: path, started: time.Now().UTC(), records: make(map[string]*runRecord)}
+}
+
+/**
+ * @brief Registers a repository as scanned in this run, even if nothing is found.
+ */
+func (r *runRecorder) begin(repo *repository) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[repo.label]; ok {
+		return // Already begun, e.g. by draining a --queue-file
+	}
+	r.records[repo.label] = &runRecord{
+		Time:       r.started.Format(time.RFC3339),
+		Repository: repositoryName(repo),
+		Findings:   make(map[string]string),
+	}
+}
+
+/**
+ * @brief Records the fingerprint of a written finding.
+ */
+func (r *runRecorder) record(f *finding) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rec, ok := r.records[f.Repository]; ok {
+		rec.Findings[secretFingerprint(f)] = f.RuleID
+	}
+}
+
+/**
+ * @brief Appends this run's records to the history file.
+ * @return An error if the file could not be written.
+ */
+func (r *runRecorder) save() error {
+	if r == nil || len(r.records) == 0 {
+		return nil
+	}
+	var lines []byte
+	labels := make([]string, 0, len(r.records))
+	for label := range r.records {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		line, err := json.Marshal(r.records[label])
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	// A single write keeps concurrent runs from interleaving their lines.
+	if _, err := file.Write(lines); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+/**
+ * @brief Identifies a secret without storing it.
+ * @return A hex digest of the rule ID and the matched value.
+ */
+func secretFingerprint(f *finding) string {
+	sum := sha256.Sum256([]byte(f.RuleID + "\x00" + f.Match))
+	return hex.EncodeToString(sum[:16])
+}
+
+/**
+ * @brief Names a repository stably across runs.
+ * @return The sweep label, or the repository's absolute path.
+ */
+func repositoryName(repo *repository) string {
+	if repo.label != "" {
+		return repo.label
+	}
+	output, err := repo.command("rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return repo.gitDir
+	}
+	dir := strings.TrimSpace(string(output))
+	if filepath.Base(dir) == ".git" {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.ToSlash(dir)
+}
+
+/**
+ * @brief Reads every record of a history file, in file order.
+ * @param path The history file.
+ * @return The records and an error naming the first malformed line.
+ */
+func loadRunRecords(path string) ([]runRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []runRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+/**
+ * @struct trendRow
+ * @brief Changes in one run for one repository (and rule, with --by rule).
+ */
+type trendRow struct {
+	Time       string `json:"time"`
+	Repository string `json:"repository"`
+	Rule       string `json:"rule,omitempty"`
+	Introduced int    `json:"introduced"`
+	Resolved   int    `json:"resolved"`
+	Open       int    `json:"open"`
+}
+
+/**
+ * @brief Computes introduced and resolved counts between consecutive runs.
+ * @param records The history, oldest first.
+ * @param byRule Whether to split each run's counts by rule.
+ * @return One row per run and repository (and rule), in history order.
+ */
+func computeTrend(records []runRecord, byRule bool) []trendRow {
+	previous := make(map[string]map[string]string) // Repository -> last run's findings
+	var rows []trendRow
+	for _, rec := range records {
+		prev := previous[rec.Repository]
+		counts := make(map[string]*trendRow)
+		row := func(rule string) *trendRow {
+			if !byRule {
+				rule = ""
+			}
+			if counts[rule] == nil {
+				counts[rule] = &trendRow{Time: rec.Time, Repository: rec.Repository, Rule: rule}
+			}
+			return counts[rule]
+		}
+		for fp, rule := range rec.Findings {
+			r := row(rule)
+			r.Open++
+			if _, ok := prev[fp]; !ok {
+				r.Introduced++
+			}
+		}
+		for fp, rule := range prev {
+			if _, ok := rec.Findings[fp]; !ok {
+				row(rule).Resolved++
+			}
+		}
+		if len(counts) == 0 {
+			row("")
+		}
+		keys := make([]string, 0, len(counts))
+		for rule := range counts {
+			keys = append(keys, rule)
+		}
+		sort.Strings(keys)
+		for _, rule := range keys {
+			rows = append(rows, *counts[rule])
+		}
+		previous[rec.Repository] = rec.Findings
+	}
+	return rows
+}
+
+/**
+ * @brief Runs the report subcommand.
+ * @param args The arguments following "report".
+ * @return The process exit code.
+ */
+func runReport(args []string) int {
+	if len(args) > 0 && args[0] == "incidents" {
+		return runIncidentReport(args[1:])
+	}
+	if len(args) == 0 || args[0] != "trend" {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer report trend --history-file <file> [options]")
+		fmt.Fprintln(os.Stderr, "       git_analyzer report incidents --secret-index <file> [options]")
+		return 2
+	}
+	fs := flag.NewFlagSet("report trend", flag.ExitOnError)
+	historyFile := fs.String("history-file", "", "History file written by scans with --history-file")
+	by := fs.String("by", "repo", "Group changes by repo, or by rule within each repo")
+	repoFilter := fs.String("repository", "", "Only report this repository")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: git_analyzer report trend --history-file <file> [options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+
+	if *historyFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --history-file is required")
+		return 2
+	}
+	if *by != "repo" && *by != "rule" {
+		fmt.Fprintf(os.Stderr, "Error: --by: expected repo or rule, got %q\n", *by)
+		return 2
+	}
+	records, err := loadRunRecords(*historyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	rows := computeTrend(records, *by == "rule")
+	if *repoFilter != "" {
+		filtered := rows[:0]
+		for _, row := range rows {
+			if row.Repository == *repoFilter {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			encoder.Encode(row)
+		}
+	case "text":
+		printTrend(os.Stdout, rows, *by == "rule")
+	default:
+		fmt.Fprintf(os.Stderr, "Error: --format: expected text or json, got %q\n", *format)
+		return 2
+	}
+	return 0
+}
+
+/**
+ * @brief Prints trend rows as an aligned table.
+ */
+func printTrend(w io.Writer, rows []trendRow, byRule bool) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if byRule {
+		fmt.Fprintln(tw, "RUN\tREPOSITORY\tRULE\tINTRODUCED\tRESOLVED\tOPEN")
+	} else {
+		fmt.Fprintln(tw, "RUN\tREPOSITORY\tINTRODUCED\tRESOLVED\tOPEN")
+	}
+	for _, row := range rows {
+		if byRule {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t+%d\t-%d\t%d\n", row.Time, row.Repository, row.Rule, row.Introduced, row.Resolved, row.Open)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t+%d\t-%d\t%d\n", row.Time, row.Repository, row.Introduced, row.Resolved, row.Open)
+		}
+	}
+	tw.Flush()
+}
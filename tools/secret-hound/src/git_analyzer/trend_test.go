@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunRecorderAppendsFingerprints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	for _, matches := range [][]string{{"one", "two"}, {"two"}} {
+		r := newRunRecorder(path)
+		r.begin(&repository{label: "billing"})
+		r.begin(&repository{label: "quiet"})
+		for _, m := range matches {
+			r.record(&finding{Repository: "billing", RuleID: "TOKEN", Match: m})
+		}
+		if err := r.save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("one")) || bytes.Contains(data, []byte("two")) {
+		t.Errorf("the history file holds secret material:\n%s", data)
+	}
+	records, err := loadRunRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0].Repository != "billing" || len(records[0].Findings) != 2 || len(records[1].Findings) != 0 {
+		t.Errorf("records %+v", records)
+	}
+	if newRunRecorder("") != nil {
+		t.Error("recording without --history-file")
+	}
+}
+
+func TestLoadRunRecordsNamesTheBadLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	os.WriteFile(path, []byte("{\"repository\": \"a\"}\n\nnot json\n"), 0o600)
+	if _, err := loadRunRecords(path); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("error %v does not name line 3", err)
+	}
+}
+
+func TestComputeTrend(t *testing.T) {
+	records := []runRecord{
+		{Time: "t1", Repository: "a", Findings: map[string]string{"f1": "AWS", "f2": "JWT"}},
+		{Time: "t1", Repository: "b", Findings: map[string]string{}},
+		{Time: "t2", Repository: "a", Findings: map[string]string{"f2": "JWT", "f3": "AWS"}},
+	}
+	want := []trendRow{
+		{Time: "t1", Repository: "a", Introduced: 2, Open: 2},
+		{Time: "t1", Repository: "b"},
+		{Time: "t2", Repository: "a", Introduced: 1, Resolved: 1, Open: 2},
+	}
+	if got := computeTrend(records, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("by repo:\n got %+v\nwant %+v", got, want)
+	}
+	byRule := computeTrend(records[2:], true)
+	if len(byRule) != 2 || byRule[0].Rule != "AWS" || byRule[1].Rule != "JWT" {
+		t.Errorf("by rule: %+v", byRule)
+	}
+
+	var out bytes.Buffer
+	printTrend(&out, want, false)
+	if !strings.Contains(out.String(), "+1") || !strings.Contains(out.String(), "-1") {
+		t.Errorf("table:\n%s", out.String())
+	}
+}
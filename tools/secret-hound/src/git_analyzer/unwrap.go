@@ -0,0 +1,143 @@
+/**
+ * @file unwrap.go
+ * @brief Decodes base64 and hex payloads and rescans what they contain.
+ *
+ * Credentials are often committed encoded (Kubernetes secrets, CI variables,
+ * "obfuscated" config). Runs of base64 or hex characters at least
+ * --decode-min-length long are decoded one level deep; if the result is
+ * text it is scanned again. Findings from decoded content keep the line of
+ * the encoded run and carry the encoding in their "encoding" metadata.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxDecodedPayloads = 64      // Encoded runs rescanned per blob
+	maxDecodedBytes    = 1 << 20 // Decoded bytes rescanned per blob
+)
+
+var (
+	hexRunPattern    = regexp.MustCompile(`[0-9a-fA-F]+`)
+	base64RunPattern = regexp.MustCompile(`[A-Za-z0-9+/_-]+={0,2}`)
+)
+
+/**
+ * @struct encodedPayload
+ * @brief A decoded run of base64 or hex found in a blob.
+ */
+type encodedPayload struct {
+	encoding string // "base64" or "hex"
+	line     int    // 1-based line of the encoded run
+	decoded  []byte
+}
+
+/**
+ * @brief Scans the decoded form of every encoded payload in a blob.
+ * @param blob The blob being scanned.
+ * @param content The blob's content.
+ * @return Findings located in decoded payloads.
+ */
+func (a *analyzer) unwrapEncoded(blob fileBlob, content []byte) []*finding {
+	var findings []*finding
+	for _, p := range findEncodedPayloads(content, a.opts.decodeMinLength) {
+		found, err := a.scanContent(blob, p.decoded)
+		scanFailures.record(err)
+		for _, f := range found {
+			f.Line = p.line
+			setMetadata(f, "encoding", p.encoding)
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+/**
+ * @brief Finds and decodes the encoded runs in content.
+ * Only runs that decode to mostly printable text are returned, which
+ * discards hashes, binary data and ordinary identifiers.
+ * @param content The content to search.
+ * @param minLength The shortest run considered (0 disables decoding).
+ * @return The decoded payloads, bounded by maxDecodedPayloads and maxDecodedBytes.
+ */
+func findEncodedPayloads(content []byte, minLength int) []encodedPayload {
+	if minLength <= 0 || isBinary(content) {
+		return nil
+	}
+	var payloads []encodedPayload
+	total := 0
+	for i, line := range strings.Split(string(content), "\n") {
+		if len(line) < minLength {
+			continue
+		}
+		for _, run := range base64RunPattern.FindAllString(line, -1) {
+			if len(run) < minLength {
+				continue
+			}
+			encoding, decoded := decodeRun(run)
+			if decoded == nil || !isPrintable(decoded) {
+				continue
+			}
+			if len(payloads) == maxDecodedPayloads || total+len(decoded) > maxDecodedBytes {
+				return payloads
+			}
+			total += len(decoded)
+			payloads = append(payloads, encodedPayload{encoding: encoding, line: i + 1, decoded: decoded})
+		}
+	}
+	return payloads
+}
+
+/**
+ * @brief Decodes a run as hex if it is pure hex, otherwise as (URL-safe) base64.
+ * @return The encoding name and decoded bytes, or a nil slice if neither applies.
+ */
+func decodeRun(run string) (string, []byte) {
+	if hexRunPattern.FindString(run) == run && len(run)%2 == 0 {
+		if decoded, err := hex.DecodeString(run); err == nil {
+			return "hex", decoded
+		}
+	}
+	trimmed := strings.TrimRight(run, "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(trimmed); err == nil {
+			return "base64", decoded
+		}
+	}
+	return "", nil
+}
+
+/**
+ * @brief Reports whether at least 95% of the bytes are printable ASCII or whitespace.
+ */
+func isPrintable(data []byte) bool {
+	printable := 0
+	for _, b := range data {
+		if (b >= 0x20 && b < 0x7f) || b == '\n' || b == '\r' || b == '\t' {
+			printable++
+		}
+	}
+	return printable*100 >= len(data)*95
+}
+
+/**
+ * @brief Reports whether content looks binary (a NUL byte in the first 8 KiB).
+ */
+func isBinary(content []byte) bool {
+	head := content
+	if len(head) > 8192 {
+		head = head[:8192]
+	}
+	for _, b := range head {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestFindEncodedPayloads(t *testing.T) {
+	secret := "aws_secret_access_key = wJalrXUtnFEMI/K7MDENG"
+	content := strings.Join([]string{
+		"plain: " + secret,
+		"b64: " + base64.StdEncoding.EncodeToString([]byte(secret)),
+		"hex: " + hex.EncodeToString([]byte(secret)),
+		"sha: 3f786850e387550fdab836ed7e6dc881de23001b3f786850e387550fdab836ed", // Decodes to binary
+		"short: " + base64.StdEncoding.EncodeToString([]byte("hi")),
+	}, "\n")
+
+	payloads := findEncodedPayloads([]byte(content), 32)
+	if len(payloads) != 2 {
+		t.Fatalf("%d payloads, want 2: %+v", len(payloads), payloads)
+	}
+	for i, want := range []encodedPayload{{encoding: "base64", line: 2}, {encoding: "hex", line: 3}} {
+		p := payloads[i]
+		if p.encoding != want.encoding || p.line != want.line || string(p.decoded) != secret {
+			t.Errorf("payload %d: %s on line %d = %q", i, p.encoding, p.line, p.decoded)
+		}
+	}
+
+	if findEncodedPayloads([]byte(content), 0) != nil {
+		t.Error("payloads decoded with decoding disabled")
+	}
+	if findEncodedPayloads(append([]byte(content), 0), 32) != nil {
+		t.Error("payloads decoded from a binary blob")
+	}
+}
+
+func TestDecodeRunURLSafeBase64(t *testing.T) {
+	encoding, decoded := decodeRun(base64.URLEncoding.EncodeToString([]byte("token>>>??>>>value")))
+	if encoding != "base64" || string(decoded) != "token>>>??>>>value" {
+		t.Errorf("decodeRun = %q, %q", encoding, decoded)
+	}
+}
+
+func TestUnwrapEncodedRescansWithBlobContext(t *testing.T) {
+	a := &analyzer{
+		opts:      options{houndCorePath: "true", decodeMinLength: 16},
+		detectors: []detector{configDetector{}},
+	}
+	blob := fileBlob{hash: "abc", path: "deploy/.env", commit: "c0ffee", repo: &repository{label: "svc"}}
+	content := "# generated\nBUNDLE=" + base64.StdEncoding.EncodeToString([]byte("DB_PASSWORD=hunter2hunter2")) + "\n"
+
+	findings := a.unwrapEncoded(blob, []byte(content))
+	if len(findings) != 1 {
+		t.Fatalf("%d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.Line != 2 || f.Match != "hunter2hunter2" || f.Metadata["encoding"] != "base64" || f.Commit != "c0ffee" {
+		t.Errorf("finding: %+v", f)
+	}
+}
@@ -0,0 +1,106 @@
+/**
+ * @file version.go
+ * @brief Version reporting and the startup handshake with the core scanner.
+ *
+ * Before scanning, the analyzer asks the core for its version and
+ * capabilities (`hound-core --capabilities`, one JSON line) and adapts:
+ * content is piped on stdin when the core supports it, saving a temporary
+ * file per blob, and --rules is only forwarded to cores that accept it. A
+ * core speaking an unknown protocol is rejected up front with a clear
+ * message instead of failing on every blob. Cores that predate the handshake
+ * are treated as protocol 1 with the scan-file and rules capabilities.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// analyzerVersion is the release version of git_analyzer.
+const analyzerVersion = "1.2.0"
+
+// coreProtocol is the core scanner protocol this analyzer speaks.
+const coreProtocol = 1
+
+// coreHandshakeTimeout bounds the --capabilities query.
+const coreHandshakeTimeout = 10 * time.Second
+
+/**
+ * @struct coreInfo
+ * @brief What the core scanner reported about itself.
+ */
+type coreInfo struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Protocol     int      `json:"protocol"`
+	Capabilities []string `json:"capabilities"`
+}
+
+/**
+ * @brief Reports whether the core advertised a capability.
+ */
+func (c *coreInfo) has(capability string) bool {
+	return c != nil && containsString(c.Capabilities, capability)
+}
+
+/**
+ * @brief Queries the core scanner's version and capabilities.
+ * @param path The core scanner executable.
+ * @return The core's self-description, or an error if it cannot run or is incompatible.
+ */
+func handshakeCore(path string) (*coreInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), coreHandshakeTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "--capabilities").Output()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("core scanner %s cannot be executed: %v", path, err)
+	}
+	info := &coreInfo{}
+	if err != nil || json.Unmarshal(bytes.TrimSpace(output), info) != nil || info.Protocol == 0 {
+		// A core from before the handshake: it treats the flag as a path.
+		return &coreInfo{Name: "hound-core", Version: "unknown (pre-1.1)", Protocol: 1,
+			Capabilities: []string{"scan-file", "rules"}}, nil
+	}
+	if info.Protocol != coreProtocol {
+		return nil, fmt.Errorf("core scanner %s %s speaks protocol %d, but this git_analyzer (%s) requires protocol %d; install matching versions",
+			path, info.Version, info.Protocol, analyzerVersion, coreProtocol)
+	}
+	if !info.has("scan-file") {
+		return nil, fmt.Errorf("core scanner %s %s does not support --scan-file", path, info.Version)
+	}
+	return info, nil
+}
+
+/**
+ * @brief Prints the analyzer's version and build information.
+ * @param w The destination.
+ */
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "git_analyzer %s\n", analyzerVersion)
+	fmt.Fprintf(w, "finding schema %s, core protocol %d\n", findingSchemaVersion, coreProtocol)
+	fmt.Fprintf(w, "built with %s for %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := make(map[string]string)
+		for _, s := range info.Settings {
+			settings[s.Key] = s.Value
+		}
+		if revision := settings["vcs.revision"]; revision != "" {
+			if settings["vcs.modified"] == "true" {
+				revision += " (modified)"
+			}
+			fmt.Fprintf(w, "revision %s\n", revision)
+		}
+	}
+}
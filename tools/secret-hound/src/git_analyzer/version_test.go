@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeCore writes an executable shell script standing in for the core scanner.
+func fakeCore(t *testing.T, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake cores are shell scripts")
+	}
+	path := filepath.Join(t.TempDir(), "hound-core")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHandshakeCore(t *testing.T) {
+	current := fakeCore(t, `echo '{"name": "hound-core", "version": "1.1.0", "protocol": 1, "capabilities": ["scan-file", "stdin"]}'`)
+	info, err := handshakeCore(current)
+	if err != nil || info.Version != "1.1.0" || !info.has("stdin") || info.has("rules") {
+		t.Errorf("current core: %+v, %v", info, err)
+	}
+
+	// Old cores treat --capabilities as a file name and fail.
+	legacy := fakeCore(t, `echo "cannot open $1" >&2; exit 1`)
+	info, err = handshakeCore(legacy)
+	if err != nil || info.Protocol != 1 || !info.has("scan-file") || !info.has("rules") || info.has("stdin") {
+		t.Errorf("legacy core: %+v, %v", info, err)
+	}
+
+	future := fakeCore(t, `echo '{"version": "3.0.0", "protocol": 2, "capabilities": ["scan-file"]}'`)
+	if _, err := handshakeCore(future); err == nil || !strings.Contains(err.Error(), "protocol 2") {
+		t.Errorf("incompatible core: %v", err)
+	}
+	noScanFile := fakeCore(t, `echo '{"version": "1.1.0", "protocol": 1, "capabilities": ["stdin"]}'`)
+	if _, err := handshakeCore(noScanFile); err == nil {
+		t.Error("a core without --scan-file was accepted")
+	}
+	if _, err := handshakeCore(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("a missing core was accepted")
+	}
+}
+
+func TestRunCorePipesContentOnStdin(t *testing.T) {
+	// The fake core reports one finding on whatever line of stdin mentions "secret".
+	core := fakeCore(t, `[ "$1 $2" = "--scan-file -" ] || exit 2
+grep -n secret | while IFS=: read n text; do
+  printf '{"file": "-", "line": %s, "rule_id": "FAKE", "match": "secret"}\n' "$n"
+done`)
+	a := &analyzer{opts: options{houndCorePath: core}, core: &coreInfo{Capabilities: []string{"scan-file", "stdin"}}}
+	blob := fileBlob{hash: "b10b", path: "conf/app.ini", commit: "c0ffee", repo: &repository{}}
+
+	findings, err := a.runCore(blob, []byte("a\nsecret\n"))
+	if err != nil || len(findings) != 1 || findings[0].Line != 2 || findings[0].File != "conf/app.ini" {
+		t.Errorf("findings: %+v, %v", findings, err)
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	var out bytes.Buffer
+	printVersion(&out)
+	if !strings.HasPrefix(out.String(), "git_analyzer "+analyzerVersion+"\n") || !strings.Contains(out.String(), "schema "+findingSchemaVersion) {
+		t.Errorf("version output:\n%s", out.String())
+	}
+}
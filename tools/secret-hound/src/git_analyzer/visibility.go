@@ -0,0 +1,240 @@
+/**
+ * @file visibility.go
+ * @brief Escalation of secrets found in public and internal repositories.
+ *
+ * A secret in a private repository is exposed to the team; the same secret
+ * in a public repository is exposed to every scraper on the internet within
+ * minutes, and in an internal one to the whole organization. In sweeps of
+ * GitHub and GitLab remotes the visibility enricher looks up each repository
+ * once through the forge's API and
+ *   - records it on every finding (metadata repository_visibility: public,
+ *     internal or private);
+ *   - raises the severity of findings in a public repository to critical,
+ *     and in an internal one to at least high, keeping the assessed one in
+ *     metadata severity_escalated_from;
+ *   - sets metadata urgency (immediate for public, high for internal), which
+ *     notifiers use to page rather than report.
+ * Allowlisted findings (severity info) are never escalated.
+ *
+ * The forge is recognized from the remote URL: github.com, or the host of
+ * GITHUB_SERVER_URL (API at GITHUB_API_URL, or /api/v3 of GitHub Enterprise),
+ * with GITHUB_TOKEN; gitlab.com, or the host of GITLAB_URL or CI_SERVER_URL,
+ * with GITLAB_TOKEN. Without a token only public repositories are visible to
+ * the API, so a repository the API does not find is taken as private. Other
+ * hosts and local repositories are left alone.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository visibilities, as named by GitHub and GitLab.
+const (
+	visibilityPublic   = "public"
+	visibilityInternal = "internal"
+	visibilityPrivate  = "private"
+)
+
+// visibilityHTTPTimeout bounds each visibility lookup.
+const visibilityHTTPTimeout = 15 * time.Second
+
+/**
+ * @struct visibilityEnricher
+ * @brief Adds the visibility of the finding's repository and escalates public and internal ones.
+ */
+type visibilityEnricher struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	repos map[*repository]*repoVisibility
+}
+
+// repoVisibility is the looked-up visibility of one repository ("" = unknown).
+type repoVisibility struct {
+	once       sync.Once
+	visibility string
+}
+
+func (*visibilityEnricher) name() string { return "visibility" }
+
+func (e *visibilityEnricher) enrich(f *finding, in *enrichInput) {
+	repo := in.blob.repo
+	if repo == nil || repo.label == "" {
+		return // Not a sweep
+	}
+	e.mu.Lock()
+	v, ok := e.repos[repo]
+	if !ok {
+		v = &repoVisibility{}
+		e.repos[repo] = v
+	}
+	e.mu.Unlock()
+	v.once.Do(func() {
+		var err error
+		if v.visibility, err = lookupVisibility(e.client, repo.label); err != nil {
+			fmt.Fprintf(os.Stderr, "Go analyzer: visibility of %s: %v\n", redactArguments([]string{repo.label})[0], err)
+		}
+	})
+	if v.visibility == "" {
+		return
+	}
+	setMetadata(f, "repository_visibility", v.visibility)
+	escalateForVisibility(f, v.visibility)
+}
+
+/**
+ * @brief Raises the severity and urgency of a finding in a repository others can read.
+ * @param f The finding, after severity assessment and allowlisting.
+ * @param visibility The repository's visibility.
+ */
+func escalateForVisibility(f *finding, visibility string) {
+	var floor, urgency string
+	switch visibility {
+	case visibilityPublic:
+		floor, urgency = "critical", "immediate"
+	case visibilityInternal:
+		floor, urgency = "high", "high"
+	default:
+		return
+	}
+	severity := findingSeverity(f)
+	if severity == "info" {
+		return
+	}
+	if severityRanks[severity] < severityRanks[floor] {
+		setMetadata(f, "severity_escalated_from", severity)
+		f.Severity = floor
+	}
+	setMetadata(f, "urgency", urgency)
+}
+
+/**
+ * @brief Looks up the visibility of a swept remote on GitHub or GitLab.
+ * @param remote The remote URL (https, ssh:// or scp-like).
+ * @return The visibility ("" for hosts that are not a known forge), and an error if the API failed.
+ */
+func lookupVisibility(client *http.Client, remote string) (string, error) {
+	host, path := splitRemoteURL(remote)
+	if host == "" || path == "" {
+		return "", nil
+	}
+	if api := githubAPIFor(host); api != "" {
+		var repo struct {
+			Private    bool   `json:"private"`
+			Visibility string `json:"visibility"`
+		}
+		found, err := getForgeJSON(client, api+"/repos/"+path, "Authorization", bearer(os.Getenv("GITHUB_TOKEN")), &repo)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return visibilityPrivate, nil // Hidden from this token
+		}
+		if repo.Visibility != "" {
+			return repo.Visibility, nil
+		}
+		if repo.Private {
+			return visibilityPrivate, nil
+		}
+		return visibilityPublic, nil
+	}
+	if api := gitlabAPIFor(host); api != "" {
+		var project struct {
+			Visibility string `json:"visibility"`
+		}
+		found, err := getForgeJSON(client, api+"/projects/"+url.PathEscape(path), "PRIVATE-TOKEN", os.Getenv("GITLAB_TOKEN"), &project)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return visibilityPrivate, nil // Hidden from this token
+		}
+		return project.Visibility, nil
+	}
+	return "", nil
+}
+
+/**
+ * @brief Splits a remote URL into its host and repository path.
+ * @return The lower-case host and the path without .git, or "" for local paths.
+ */
+func splitRemoteURL(remote string) (host, path string) {
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, "@"); at >= 0 && strings.Contains(remote[at:], ":") {
+		// scp-like: git@github.com:org/repo.git
+		host, path, _ = strings.Cut(remote[at+1:], ":")
+	} else {
+		return "", ""
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	return strings.ToLower(host), path
+}
+
+// githubAPIFor returns the REST API of a GitHub host ("" if it is not one).
+func githubAPIFor(host string) string {
+	if host == "github.com" {
+		return strings.TrimRight(orDefault(os.Getenv("GITHUB_API_URL"), "https://api.github.com"), "/")
+	}
+	if server, err := url.Parse(os.Getenv("GITHUB_SERVER_URL")); err == nil && strings.EqualFold(server.Hostname(), host) {
+		return strings.TrimRight(orDefault(os.Getenv("GITHUB_API_URL"), "https://"+host+"/api/v3"), "/")
+	}
+	return ""
+}
+
+// gitlabAPIFor returns the REST API of a GitLab host ("" if it is not one).
+func gitlabAPIFor(host string) string {
+	if host == "gitlab.com" {
+		return "https://gitlab.com/api/v4"
+	}
+	for _, name := range []string{"GITLAB_URL", "CI_SERVER_URL"} {
+		if server, err := url.Parse(os.Getenv(name)); err == nil && server.Host != "" && strings.EqualFold(server.Hostname(), host) {
+			return strings.TrimRight(server.String(), "/") + "/api/v4"
+		}
+	}
+	return ""
+}
+
+// bearer returns an Authorization value for a token ("" without one).
+func bearer(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "Bearer " + token
+}
+
+/**
+ * @brief GETs a forge API resource as JSON.
+ * @return Whether it exists (false for 404), and an error for any other failure.
+ */
+func getForgeJSON(client *http.Client, endpoint, authHeader, auth string, into interface{}) (bool, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if auth != "" {
+		req.Header.Set(authHeader, auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("GET %s: %s", endpoint, resp.Status)
+	}
+	return true, json.NewDecoder(resp.Body).Decode(into)
+}
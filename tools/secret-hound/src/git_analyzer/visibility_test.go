@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVisibilityEscalatesPublicRepositories(t *testing.T) {
+	visibilities := map[string]string{"/repos/acme/site": `{"private": false, "visibility": "public"}`, "/repos/acme/tools": `{"visibility": "internal"}`}
+	lookups := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		if body, ok := visibilities[r.URL.Path]; ok {
+			w.Write([]byte(body))
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	t.Setenv("GITHUB_SERVER_URL", "https://"+u.Hostname())
+	t.Setenv("GITHUB_API_URL", server.URL)
+	t.Setenv("GITHUB_TOKEN", "t0ken")
+
+	e := &visibilityEnricher{client: server.Client(), repos: make(map[*repository]*repoVisibility)}
+	run := func(repo *repository, severity string) *finding {
+		f := &finding{RuleID: "GITHUB_PAT", Severity: severity}
+		e.enrich(f, &enrichInput{blob: fileBlob{repo: repo}})
+		return f
+	}
+
+	site := &repository{label: "git@" + u.Hostname() + ":acme/site.git"}
+	f := run(site, "medium")
+	if f.Severity != "critical" || f.Metadata["severity_escalated_from"] != "medium" || f.Metadata["urgency"] != "immediate" {
+		t.Errorf("public repository: severity %s, metadata %v", f.Severity, f.Metadata)
+	}
+	if f := run(site, "info"); f.Severity != "info" || f.Metadata["urgency"] != "" {
+		t.Errorf("allowlisted finding escalated: %s %v", f.Severity, f.Metadata)
+	}
+	if lookups != 1 {
+		t.Errorf("%d lookups of one repository, want 1", lookups)
+	}
+
+	f = run(&repository{label: "https://" + u.Hostname() + "/acme/tools"}, "critical")
+	if f.Severity != "critical" || f.Metadata["repository_visibility"] != "internal" || f.Metadata["severity_escalated_from"] != "" {
+		t.Errorf("internal repository: severity %s, metadata %v", f.Severity, f.Metadata)
+	}
+	f = run(&repository{label: "https://" + u.Hostname() + "/acme/secret-project"}, "low")
+	if f.Severity != "low" || f.Metadata["repository_visibility"] != "private" {
+		t.Errorf("hidden repository: severity %s, metadata %v", f.Severity, f.Metadata)
+	}
+	if f := run(&repository{label: "/srv/git/local.git"}, "low"); f.Metadata != nil {
+		t.Errorf("local repository: metadata %v", f.Metadata)
+	}
+}
@@ -0,0 +1,110 @@
+/**
+ * @file worktree.go
+ * @brief Enumerates files of the current checkout so they can be scanned
+ *        alongside the history.
+ *
+ * Working tree files are hashed with `git hash-object`, which yields the same
+ * blob hash git would store for them. Unmodified tracked files are therefore
+ * deduplicated against the history scan for free, and only content that differs
+ * from history (local edits, untracked and ignored files) is scanned again.
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// worktreeCommit is the pseudo commit reported for findings in the working tree.
+const worktreeCommit = "WORKTREE"
+
+/**
+ * @brief Lists the files of the working tree as blobs to be scanned.
+ * @param repo The repository whose checkout is listed.
+ * @param includeUntracked Whether untracked files should be included.
+ * @param includeIgnored Whether files matched by .gitignore should be included.
+ * @return A slice of fileBlob structs read from disk and an error if one occurred.
+ */
+func getWorktreeBlobs(repo *repository, includeUntracked, includeIgnored bool) ([]fileBlob, error) {
+	listings := [][]string{{"ls-files", "-z", "--cached"}}
+	if includeUntracked {
+		listings = append(listings, []string{"ls-files", "-z", "--others", "--exclude-standard"})
+	}
+	if includeIgnored {
+		listings = append(listings, []string{"ls-files", "-z", "--others", "--ignored", "--exclude-standard"})
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, args := range listings {
+		output, err := repo.command(args...).Output()
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range strings.Split(string(output), "\x00") {
+			if path == "" || seen[path] {
+				continue
+			}
+			seen[path] = true
+
+			// Deleted files, symlinks and submodule directories have no content to scan.
+			info, err := os.Lstat(longPath(path))
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// Hash all files in one batch so identical content dedupes against history.
+	hashCmd := repo.command("hash-object", "--stdin-paths")
+	hashCmd.Stdin = strings.NewReader(strings.Join(paths, "\n") + "\n")
+	hashOutput, err := hashCmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	hashes := strings.Fields(string(bytes.TrimSpace(hashOutput)))
+
+	blobs := make([]fileBlob, 0, len(paths))
+	for i, path := range paths {
+		if i >= len(hashes) {
+			break
+		}
+		blobs = append(blobs, fileBlob{
+			hash:     hashes[i],
+			path:     path,
+			commit:   worktreeCommit,
+			diskPath: filepath.FromSlash(path),
+			repo:     repo,
+		})
+	}
+	return blobs, nil
+}
+
+/**
+ * @brief Lists the files staged for commit (--staged), as blobs of the index.
+ * Only files the next commit adds or changes are listed; their content is
+ * read from the object store, so edits made after `git add` are not scanned,
+ * just as they would not be committed. Findings carry the WORKTREE pseudo
+ * commit, since the commit does not exist yet.
+ * @param repo The repository.
+ * @return The staged blobs and an error if the index could not be diffed.
+ */
+func getStagedBlobs(repo *repository) ([]fileBlob, error) {
+	output, err := repo.command("diff", "--cached", "--raw", "-z", "--no-renames", "--no-abbrev", "--diff-filter=AMT").Output()
+	if err != nil {
+		return nil, err
+	}
+	// The records have the diff-tree format, without a leading commit id.
+	var blobs []fileBlob
+	for _, change := range parseDiffTree(output)[""] {
+		blobs = append(blobs, fileBlob{hash: change.Blob, path: change.Path, commit: worktreeCommit, mode: change.Mode, repo: repo})
+	}
+	return blobs, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// worktreePaths lists the paths getWorktreeBlobs returns in the current directory.
+func worktreePaths(t *testing.T, untracked, ignored bool) string {
+	t.Helper()
+	blobs, err := getWorktreeBlobs(&repository{}, untracked, ignored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, blob := range blobs {
+		if blob.commit != worktreeCommit || blob.diskPath != blob.path {
+			t.Errorf("%s: commit %q, disk path %q", blob.path, blob.commit, blob.diskPath)
+		}
+		paths = append(paths, blob.path)
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+func TestWorktreeBlobsSelection(t *testing.T) {
+	repo := newFixtureRepo(t)
+	repo.commit("init", map[string]string{".gitignore": "*.log\n", "tracked.env": "A=1\n", "gone.txt": "x\n"})
+	repo.write(map[string]string{"tracked.env": "A=2\n", "new.env": "B=1\n", "debug.log": "C=1\n"})
+	if err := os.Remove(repo.dir + "/gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo.dir)
+
+	for _, tc := range []struct {
+		untracked, ignored bool
+		want               string
+	}{
+		{false, false, ".gitignore tracked.env"},
+		{true, false, ".gitignore new.env tracked.env"},
+		{true, true, ".gitignore debug.log new.env tracked.env"},
+	} {
+		if got := worktreePaths(t, tc.untracked, tc.ignored); got != tc.want {
+			t.Errorf("untracked=%v ignored=%v: %q, want %q", tc.untracked, tc.ignored, got, tc.want)
+		}
+	}
+}
+
+func TestWorktreeBlobsHashLikeGit(t *testing.T) {
+	repo := newFixtureRepo(t)
+	repo.commit("init", map[string]string{"same.txt": "unchanged\n", "edited.txt": "old\n"})
+	repo.write(map[string]string{"edited.txt": "new\n"})
+	t.Chdir(repo.dir)
+
+	blobs, err := getWorktreeBlobs(&repository{}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blob := range blobs {
+		stored := repo.git("rev-parse", "HEAD:"+blob.path)
+		if (blob.hash == stored) != (blob.path == "same.txt") {
+			t.Errorf("%s: hash %s, committed %s", blob.path, blob.hash, stored)
+		}
+	}
+}
@@ -35,16 +35,22 @@ std::vector<DetectionRule> RuleParser::parse_rules_from_file(const std::string&
         throw std::runtime_error(error_msg);
     }
 
-    if (!cJSON_IsArray(json)) {
+    // The root is either the rule array itself or an object whose "rules" key holds it.
+    // Other keys of the object (e.g. "profiles") are consumed by the Go git analyzer.
+    cJSON* rules_json = json;
+    if (cJSON_IsObject(json)) {
+        rules_json = cJSON_GetObjectItemCaseSensitive(json, "rules");
+    }
+    if (!cJSON_IsArray(rules_json)) {
         cJSON_Delete(json);
-        throw std::runtime_error("Rule file must contain a JSON array at the root.");
+        throw std::runtime_error("Rule file must contain a JSON array at the root or in a \"rules\" key.");
     }
 
     std::vector<DetectionRule> rules;
     cJSON* rule_json = NULL;
 
     // Iterate over the JSON array
-    cJSON_ArrayForEach(rule_json, json) {
+    cJSON_ArrayForEach(rule_json, rules_json) {
         DetectionRule rule;
 
         cJSON* id = cJSON_GetObjectItemCaseSensitive(rule_json, "id");
@@ -22,7 +22,8 @@ struct DetectionRule {
 class RuleParser {
 public:
     /**
-     * @brief Parses a JSON file containing an array of detection rules.
+     * @brief Parses a JSON file containing an array of detection rules, either at
+     *        the root or under the "rules" key of a root object.
      * @param filepath The path to the JSON rule file.
      * @return A vector of DetectionRule structs.
      * @throws std::runtime_error if the file cannot be read or parsed.
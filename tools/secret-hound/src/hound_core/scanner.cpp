@@ -130,9 +130,16 @@ void Scanner::scan_file(const std::string& file_path) {
         return;
     }
 
+    scan_stream(file_stream, file_path);
+
+    // Decrement the counter to signal that this task is complete.
+    active_tasks--;
+}
+
+void Scanner::scan_stream(std::istream& stream, const std::string& file_path) {
     std::string line;
     int line_num = 1;
-    while (std::getline(file_stream, line)) {
+    while (std::getline(stream, line)) {
         for (const auto& rule : rules) {
             std::smatch match;
             std::string::const_iterator search_start(line.cbegin());
@@ -170,7 +177,4 @@ void Scanner::scan_file(const std::string& file_path) {
         }
         line_num++;
     }
-
-    // Decrement the counter to signal that this task is complete.
-    active_tasks--;
 }
@@ -6,6 +6,7 @@
 
 #include "rule_parser.hpp"
 #include "threadpool.hpp"
+#include <istream>
 #include <string>
 #include <vector>
 #include <atomic>
@@ -42,6 +43,13 @@ public:
      * @param file_path The path of the file to scan.
      */
     void scan_file(const std::string& file_path);
+
+    /**
+     * @brief Scans already-open content line by line, synchronously.
+     * @param stream The content to scan (e.g. std::cin).
+     * @param display_path The path reported in the "file" field of findings.
+     */
+    void scan_stream(std::istream& stream, const std::string& display_path);
     
     /**
      * @brief Waits for all pending scan tasks in the thread pool to complete.
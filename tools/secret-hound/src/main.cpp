@@ -10,6 +10,7 @@
 
 #include "hound_core/scanner.hpp"
 #include "hound_core/rule_parser.hpp"
+#include <cstring>
 #include <iostream>
 #include <string>
 #include <vector>
@@ -23,24 +24,52 @@ extern "C" {
     #include "sniper_c_utils.h"
 }
 
+// Version of this core scanner, and of the protocol spoken with git_analyzer.
+// The protocol version changes only when the command line or output format
+// changes incompatibly; git_analyzer refuses cores with an unknown protocol.
+#define HOUND_CORE_VERSION "1.1.0"
+#define HOUND_CORE_PROTOCOL 1
+
 // Prototypes
 std::string find_tool_root_path(const char* argv0);
 
 int main(int argc, char* argv[]) {
     if (argc < 2) {
         fprintf(stderr, "Usage: %s <path_to_scan> [--rules /path/to/rules.json]\n", argv[0]);
+        fprintf(stderr, "       %s --scan-file <file|-> [--rules /path/to/rules.json]\n", argv[0]);
+        fprintf(stderr, "       %s --version | --capabilities\n", argv[0]);
         return 1;
     }
 
-    const char* target_path = argv[1];
+    // Handshake queries used by git_analyzer before it starts scanning.
+    if (strcmp(argv[1], "--version") == 0) {
+        printf("hound-core %s\n", HOUND_CORE_VERSION);
+        return 0;
+    }
+    if (strcmp(argv[1], "--capabilities") == 0) {
+        printf("{\"name\": \"hound-core\", \"version\": \"%s\", \"protocol\": %d, "
+               "\"capabilities\": [\"scan-file\", \"stdin\", \"rules\"]}\n",
+               HOUND_CORE_VERSION, HOUND_CORE_PROTOCOL);
+        return 0;
+    }
+
+    const char* target_path = NULL;
     const char* rules_file_path = NULL;
 
     // Manual, simple argument parsing for this internal tool.
-    for (int i = 2; i < argc; ++i) {
+    for (int i = 1; i < argc; ++i) {
         if (strcmp(argv[i], "--rules") == 0 && i + 1 < argc) {
             rules_file_path = argv[++i];
+        } else if (strcmp(argv[i], "--scan-file") == 0 && i + 1 < argc) {
+            target_path = argv[++i];
+        } else if (!target_path) {
+            target_path = argv[i];
         }
     }
+    if (!target_path) {
+        fprintf(stderr, "Error: no path to scan\n");
+        return 1;
+    }
 
     try {
         std::string final_rules_path;
@@ -62,7 +91,10 @@ int main(int argc, char* argv[]) {
         Scanner scanner(rules, num_threads);
         
         struct stat s;
-        if (stat(target_path, &s) == 0) {
+        if (strcmp(target_path, "-") == 0) {
+            // Content piped on stdin (git_analyzer's stdin mode).
+            scanner.scan_stream(std::cin, "-");
+        } else if (stat(target_path, &s) == 0) {
             if (S_ISDIR(s.st_mode)) {
                 scanner.scan_directory(target_path);
             } else if (S_ISREG(s.st_mode)) {
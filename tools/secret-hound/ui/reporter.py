@@ -5,6 +5,8 @@
 # - Groups matches by file and prints a rich Table inside Panels per file
 # - Handles large matches safely, no unsupported args used
 # - Optional: --html <path> to save an HTML copy (requires 'rich[html]' to be installed)
+# - Optional: --lang / --messages to localize the report text, using the message
+#   catalogs of the git analyzer (src/git_analyzer/locales)
 
 from __future__ import annotations
 import sys
@@ -20,7 +22,59 @@ from rich.syntax import Syntax
 from rich.text import Text
 from rich.markdown import Markdown
 
-APP_TITLE = "🔍 Secret Hound Report"
+LOCALES_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), "..", "src", "git_analyzer", "locales")
+DEFAULT_LANGUAGE = "en"
+
+# Messages of the selected language, with English merged in for missing keys.
+MESSAGES: Dict[str, Any] = {}
+
+
+def language_candidates(lang: str) -> List[str]:
+    """
+    Tags to try for a language, most specific first, ending with English,
+    as the git analyzer does: "de_AT.UTF-8" gives de-AT, de, en.
+    """
+    lang = lang.split(".", 1)[0].split("@", 1)[0].replace("_", "-").lower()
+    candidates: List[str] = []
+    if lang and lang not in ("c", "posix"):
+        parts = lang.split("-")
+        for i in range(len(parts), 0, -1):
+            tag = parts[0]
+            if i > 1:
+                tag += "-" + "-".join(parts[1:i]).upper()
+            candidates.append(tag)
+    if not candidates or candidates[-1] != DEFAULT_LANGUAGE:
+        candidates.append(DEFAULT_LANGUAGE)
+    return candidates
+
+
+def load_messages(lang: Optional[str], overrides: Optional[str]) -> None:
+    """Loads the catalog of a language (or of the POSIX locale) and an optional override file."""
+    if not lang:
+        lang = os.environ.get("LC_ALL") or os.environ.get("LC_MESSAGES") or os.environ.get("LANG") or ""
+    for tag in reversed(language_candidates(lang)):
+        path = os.path.join(LOCALES_DIR, tag + ".json")
+        if os.path.exists(path):
+            with open(path, "r", encoding="utf-8") as f:
+                MESSAGES.update(json.load(f))
+    if overrides:
+        with open(overrides, "r", encoding="utf-8") as f:
+            MESSAGES.update(json.load(f))
+
+
+def t(key: str, **params: Any) -> str:
+    """Returns a message with its {placeholders} filled; a "count" parameter selects the plural form."""
+    entry = MESSAGES.get(key, key)
+    if isinstance(entry, dict):
+        entry = entry.get("one") if params.get("count") == 1 and entry.get("one") else entry.get("other", key)
+    text = str(entry)
+    for name, value in params.items():
+        text = text.replace("{" + name + "}", str(value))
+    return text
+
+
+def app_title() -> str:
+    return "🔍 " + t("report.title")
 
 
 def load_json_from_stdin() -> Optional[Any]:
@@ -75,10 +129,10 @@ def group_by_file(entries: List[Dict[str, Any]]) -> Dict[str, List[Dict[str, Any
 
 def build_table_for_entries(entries: List[Dict[str, Any]]) -> Table:
     table = Table(show_header=True, header_style="bold blue", show_lines=True, expand=True)
-    table.add_column("Line", style="cyan", width=8, justify="center")
-    table.add_column("Rule ID", style="yellow", width=18, overflow="ellipsis")
-    table.add_column("Description", style="green", overflow="fold")
-    table.add_column("Match (preview)", style="magenta", overflow="fold")
+    table.add_column(t("report.column.line"), style="cyan", width=8, justify="center")
+    table.add_column(t("report.column.rule"), style="yellow", width=18, overflow="ellipsis")
+    table.add_column(t("report.column.description"), style="green", overflow="fold")
+    table.add_column(t("report.column.match"), style="magenta", overflow="fold")
 
     for entry in entries:
         line = str(entry.get("line", "-"))
@@ -104,7 +158,7 @@ def build_table_for_entries(entries: List[Dict[str, Any]]) -> Table:
 
 
 def print_report(console: Console, grouped: Dict[str, List[Dict[str, Any]]], total: int) -> None:
-    console.rule(APP_TITLE)
+    console.rule(app_title())
     panels: List[Panel] = []
 
     # Sort files for deterministic output
@@ -115,20 +169,20 @@ def print_report(console: Console, grouped: Dict[str, List[Dict[str, Any]]], tot
             Panel(
                 tbl,
                 title=f"[bold cyan]{os.path.basename(file_name)}[/bold cyan]",
-                subtitle=f"{len(entries)} potential secret(s)",
+                subtitle=t("report.file_findings", count=len(entries)),
                 border_style="bright_black",
                 padding=(1, 2),
             )
         )
 
     if not panels:
-        console.print("[yellow]No secrets or no input data found.[/yellow]")
+        console.print(f"[yellow]{t('report.no_findings')}[/yellow]")
         return
 
     # Print grouped panels
     console.print(Group(*panels))
     console.rule()
-    console.print(f"[bold magenta]Total secrets found:[/bold magenta] {total}")
+    console.print(f"[bold magenta]{t('report.total')}[/bold magenta] {total}")
 
 
 def save_html(console: Console, grouped: Dict[str, List[Dict[str, Any]]], total: int, path: str) -> bool:
@@ -138,7 +192,7 @@ def save_html(console: Console, grouped: Dict[str, List[Dict[str, Any]]], total:
     """
     try:
         # Build a simple markdown summary and tables as text
-        md_lines = [f"# {APP_TITLE}\n", f"**Total secrets found**: {total}\n\n"]
+        md_lines = [f"# {app_title()}\n", f"**{t('report.total')}** {total}\n\n"]
         for file_name in sorted(grouped.keys()):
             md_lines.append(f"## {os.path.basename(file_name)} ({len(grouped[file_name])})\n")
             for entry in grouped[file_name]:
@@ -146,7 +200,7 @@ def save_html(console: Console, grouped: Dict[str, List[Dict[str, Any]]], total:
                 rid = entry.get("rule_id", "N/A")
                 desc = entry.get("description", "")
                 match_str = entry.get("match", "")
-                md_lines.append(f"- **Line {line}** — `{rid}` — {desc}\n\n")
+                md_lines.append(f"- **{t('report.line', line=line)}** — `{rid}` — {desc}\n\n")
                 # include the match as a code block
                 md_lines.append(f"```\n{match_str}\n```\n\n")
         md_text = "".join(md_lines)
@@ -159,7 +213,7 @@ def save_html(console: Console, grouped: Dict[str, List[Dict[str, Any]]], total:
         # We'll use console.print and Console().export_html if present.
         export_console = Console(record=True)
         export_console.print(Markdown(md_text))
-        html = export_console.export_html(title=APP_TITLE)
+        html = export_console.export_html(title=app_title())
         with open(path, "w", encoding="utf-8") as f:
             f.write(html)
         return True
@@ -171,12 +225,19 @@ def parse_args() -> argparse.Namespace:
     p = argparse.ArgumentParser(description="Secret Hound reporter (renders JSON to terminal using rich).")
     p.add_argument("--input", "-i", help="Path to report.json (if not provided, read from stdin)")
     p.add_argument("--html", help="Optional: save an HTML copy to this path")
+    p.add_argument("--lang", help="Language of the report text (default: from LC_ALL, LC_MESSAGES or LANG)")
+    p.add_argument("--messages", help="JSON message catalog whose text replaces the built-in messages")
     return p.parse_args()
 
 
 def main() -> int:
     args = parse_args()
     console = Console()
+    try:
+        load_messages(args.lang, args.messages)
+    except (OSError, ValueError) as e:
+        console.print(f"[red]Error: --messages: {e}[/red]")
+        return 1
 
     raw = None
     # Priority: stdin (if piped) -> --input path -> interactive prompt
@@ -202,9 +263,9 @@ def main() -> int:
     if args.html:
         ok = save_html(console, grouped, total, args.html)
         if ok:
-            console.print(f"[green]Saved HTML report to:[/green] {args.html}")
+            console.print(f"[green]{t('report.html_saved')}[/green] {args.html}")
         else:
-            console.print(f"[red]Failed to save HTML report to:[/red] {args.html}")
+            console.print(f"[red]{t('report.html_failed')}[/red] {args.html}")
 
     return 0
 